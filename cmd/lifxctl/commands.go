@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/effects"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/effects/adapters"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/inventory"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// result is the outcome of a command on a device.
+type result struct {
+	Serial string `json:"serial"`
+	Label  string `json:"label"`
+	Error  string `json:"error,omitempty"`
+}
+
+// discover prints the devices as they are discovered, until the timeout expires.
+func (c *cli) discover(ctx context.Context, args []string) error {
+	if err := parseArgs(c.newFlagSet("discover", ""), args, 0, false); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	seen := make(map[device.Serial]bool)
+	for {
+		for _, d := range c.ctrl.FindDevices(ready) {
+			if seen[d.Serial] {
+				continue
+			}
+			seen[d.Serial] = true
+			if c.json {
+				c.printJSON(inventory.NewEntry(d))
+			} else {
+				e := inventory.NewEntry(d)
+				fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\n", e.Serial, e.Address, e.Product, e.Label)
+			}
+		}
+		if c.noBroadcast && len(seen) == len(c.static) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// list prints the inventory of the devices discovered.
+func (c *cli) list(ctx context.Context, args []string) error {
+	if err := parseArgs(c.newFlagSet("list", ""), args, 0, false); err != nil {
+		return err
+	}
+	format := inventory.FormatText
+	if c.json {
+		format = inventory.FormatJSON
+	}
+	return inventory.Write(c.stdout, c.waitDevices(ctx), format)
+}
+
+// watch prints the device events until interrupted.
+func (c *cli) watch(ctx context.Context, args []string) error {
+	if err := parseArgs(c.newFlagSet("watch", ""), args, 0, false); err != nil {
+		return err
+	}
+	events, cancel := c.ctrl.Subscribe(controller.EventFilter{})
+	defer cancel()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if c.json {
+				c.printJSON(map[string]any{
+					"type":       e.Type.String(),
+					"serial":     e.Serial.String(),
+					"label":      e.Device.Label,
+					"at":         e.At,
+					"powered_on": e.Device.PoweredOn,
+					"color":      e.Device.Color,
+				})
+			} else {
+				fmt.Fprintf(c.stdout, "%s\t%s\t%s\t%s\n", e.At.Format(time.TimeOnly), e.Type, e.Serial, e.Device.Label)
+			}
+		}
+	}
+}
+
+// power turns the selected devices on or off.
+func (c *cli) power(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("power", "[-duration d] <selector> on|off")
+	duration := fs.Duration("duration", 0, "transition duration")
+	if err := parseArgs(fs, args, 2, false); err != nil {
+		return err
+	}
+	on, err := parseOnOff(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+	return c.forEach(ctx, fs.Arg(0), func(d device.Device) error {
+		return c.ctrl.SetPower(d.Serial, on, *duration)
+	})
+}
+
+// setColor sets the color of the selected devices, changing only the given components.
+func (c *cli) setColor(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("set-color", "[flags] <selector>")
+	hue := fs.Float64("hue", 0, "hue in degrees")
+	saturation := fs.Float64("saturation", 0, "saturation in percent")
+	brightness := fs.Float64("brightness", 0, "brightness in percent")
+	kelvin := fs.Uint("kelvin", 0, "white temperature in kelvin")
+	duration := fs.Duration("duration", 0, "transition duration")
+	if err := parseArgs(fs, args, 1, false); err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	return c.forEach(ctx, fs.Arg(0), func(d device.Device) error {
+		color := d.Color
+		if set["hue"] {
+			color.Hue = *hue
+		}
+		if set["saturation"] {
+			color.Saturation = *saturation
+		}
+		if set["brightness"] {
+			color.Brightness = *brightness
+		}
+		if set["kelvin"] {
+			color.Kelvin = uint16(*kelvin)
+		}
+		return c.ctrl.SetColor(d.Serial, color, *duration)
+	})
+}
+
+// setZones spreads colors over the zones of the selected multizone devices.
+func (c *cli) setZones(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("set-zones", "[-duration d] <selector> <color>...")
+	duration := fs.Duration("duration", 0, "transition duration")
+	if err := parseArgs(fs, args, 2, true); err != nil {
+		return err
+	}
+	var colors []device.Color
+	for _, arg := range fs.Args()[1:] {
+		color, err := parseColor(arg)
+		if err != nil {
+			return err
+		}
+		colors = append(colors, color)
+	}
+
+	return c.forEach(ctx, fs.Arg(0), func(d device.Device) error {
+		if d.LightType != device.LightTypeMultiZone || len(d.MultizoneProperties.Zones) == 0 {
+			return errors.New("not a multizone device")
+		}
+		zones := spreadColors(colors, len(d.MultizoneProperties.Zones))
+		for _, msg := range messages.SetMultizoneColors(d.MultizoneProperties, 0, zones, *duration) {
+			if err := c.ctrl.Send(d.Serial, msg); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// effect runs a software effect on the selected devices until interrupted or for the duration.
+func (c *cli) effect(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("effect", "[-duration d] [-step d] <selector> <id> [key=value]...")
+	duration := fs.Duration("duration", 0, "how long to run the effect, until interrupted if 0")
+	step := fs.Duration("step", 0, "frame duration, the effect default if 0")
+	if err := parseArgs(fs, args, 2, true); err != nil {
+		return err
+	}
+	params, err := parseParams(fs.Args()[2:])
+	if err != nil {
+		return err
+	}
+	cfg := effects.Config{ID: effects.EffectID(fs.Arg(1)), Params: params}
+
+	return c.forEach(ctx, fs.Arg(0), func(d device.Device) error {
+		effect, err := effects.New(cfg, effects.CapabilitiesFromDevice(d))
+		if err != nil {
+			return err
+		}
+		err = adapters.RunEffects(ctx, d, func(msg *protocol.Message) error { return c.ctrl.Send(d.Serial, msg) },
+			effects.RunConfig{Effect: effect, Duration: *duration, Step: *step})
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return err
+	})
+}
+
+// sceneEntry is the state of a device in a scene file.
+type sceneEntry struct {
+	Serial    string                `json:"serial"`
+	Label     string                `json:"label"`
+	PoweredOn bool                  `json:"powered_on"`
+	Color     device.Color          `json:"color"`
+	Zones     []packets.LightHsbk   `json:"zones,omitempty"`
+	Chain     [][]packets.LightHsbk `json:"chain,omitempty"`
+}
+
+// scene captures the state of the selected lights to a file, or applies it.
+func (c *cli) scene(ctx context.Context, args []string) error {
+	fs := c.newFlagSet("scene", "[-duration d] capture <selector> <file> | apply <file>")
+	duration := fs.Duration("duration", 0, "transition duration when applying")
+	if err := parseArgs(fs, args, 2, true); err != nil {
+		return err
+	}
+
+	switch {
+	case fs.Arg(0) == "capture" && fs.NArg() == 3:
+		devices, err := selectDevices(c.waitDevices(ctx), fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		serials := make([]device.Serial, 0, len(devices))
+		for _, d := range devices {
+			serials = append(serials, d.Serial)
+		}
+		var entries []sceneEntry
+		for _, d := range c.ctrl.CaptureScene(controller.BySerial(serials...)) {
+			entries = append(entries, sceneEntry{
+				Serial:    d.Serial.String(),
+				Label:     d.Label,
+				PoweredOn: d.PoweredOn,
+				Color:     d.Color,
+				Zones:     d.MultizoneProperties.Zones,
+				Chain:     d.MatrixProperties.ChainZones,
+			})
+		}
+		data, err := json.MarshalIndent(entries, "", "  ")
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(fs.Arg(2), data, 0o644)
+
+	case fs.Arg(0) == "apply" && fs.NArg() == 2:
+		data, err := os.ReadFile(fs.Arg(1))
+		if err != nil {
+			return err
+		}
+		var entries []sceneEntry
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return fmt.Errorf("invalid scene file: %w", err)
+		}
+		// Apply the saved colors over the current state of the devices, which holds the
+		// properties needed to set their zones.
+		devices := c.waitDevices(ctx)
+		var scene controller.Scene
+		for _, e := range entries {
+			for _, d := range devices {
+				if d.Serial.String() == e.Serial {
+					d.PoweredOn, d.Color = e.PoweredOn, e.Color
+					d.MultizoneProperties.Zones, d.MatrixProperties.ChainZones = e.Zones, e.Chain
+					scene = append(scene, d)
+				}
+			}
+		}
+		return c.ctrl.ApplyScene(scene, *duration)
+	}
+	fs.Usage()
+	return errUsage
+}
+
+// forEach runs f concurrently on the devices matching selector and reports the results.
+func (c *cli) forEach(ctx context.Context, selector string, f func(device.Device) error) error {
+	devices, err := selectDevices(c.waitDevices(ctx), selector)
+	if err != nil {
+		return err
+	}
+
+	results := make([]result, len(devices))
+	var wg sync.WaitGroup
+	for i, d := range devices {
+		results[i] = result{Serial: d.Serial.String(), Label: d.Label}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := f(d); err != nil {
+				results[i].Error = err.Error()
+			}
+		}()
+	}
+	wg.Wait()
+
+	var failed int
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+		}
+		if !c.json {
+			status := "ok"
+			if r.Error != "" {
+				status = r.Error
+			}
+			fmt.Fprintf(c.stdout, "%s\t%s\t%s\n", r.Serial, r.Label, status)
+		}
+	}
+	if c.json {
+		c.printJSON(results)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed on %d of %d devices", failed, len(results))
+	}
+	return nil
+}
+
+// printJSON prints v as a line of JSON.
+func (c *cli) printJSON(v any) {
+	json.NewEncoder(c.stdout).Encode(v)
+}
+
+// parseOnOff parses an on or off argument.
+func parseOnOff(s string) (bool, error) {
+	switch s {
+	case "on":
+		return true, nil
+	case "off":
+		return false, nil
+	}
+	if b, err := strconv.ParseBool(s); err == nil {
+		return b, nil
+	}
+	return false, fmt.Errorf("invalid power %q, expected on or off", s)
+}
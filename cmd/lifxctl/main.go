@@ -0,0 +1,185 @@
+// Command lifxctl discovers and controls LIFX devices on the LAN.
+//
+// Usage:
+//
+//	lifxctl [flags] <command> [arguments]
+//
+// Commands:
+//
+//	discover                      print devices as they are discovered
+//	list                          print the inventory of the devices
+//	watch                         print device events until interrupted
+//	power <selector> on|off       turn devices on or off
+//	set-color <selector>          set the color of devices, see -hue, -saturation, -brightness and -kelvin
+//	set-zones <selector> <color>  spread colors over the zones of multizone devices, colors are h,s,b,k
+//	effect <selector> <id> [k=v]  run a software effect, until interrupted or for -duration
+//	scene capture <selector> <file>
+//	scene apply <file>
+//
+// Selectors are "all", a serial, a label, "group:<label>" or "location:<label>".
+// Global flags go before the command and command flags after it, e.g.
+//
+//	lifxctl -json list
+//	lifxctl set-color -hue 240 -saturation 100 group:Kitchen
+//
+// Commands are parsed with the flag package, each having its own FlagSet, to keep the module
+// free of a command line framework dependency.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// errUsage is returned for invalid command lines, after printing the usage.
+var errUsage = errors.New("invalid usage")
+
+// cli holds the global options and the Controller of a command.
+type cli struct {
+	stdout, stderr io.Writer
+	json           bool
+	timeout        time.Duration
+	static         []string
+	noBroadcast    bool
+
+	ctrl *controller.Controller
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if !errors.Is(err, errUsage) {
+			fmt.Fprintln(os.Stderr, "lifxctl:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run runs the command line args.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	c := &cli{stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("lifxctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.BoolVar(&c.json, "json", false, "print JSON output")
+	fs.DurationVar(&c.timeout, "timeout", 2*time.Second, "how long to wait for devices to be discovered")
+	static := fs.String("static", "", "comma-separated IP addresses of devices to probe by unicast")
+	fs.BoolVar(&c.noBroadcast, "no-broadcast", false, "only probe the -static devices")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: lifxctl [flags] <command> [arguments]")
+		fmt.Fprintln(stderr, "Commands: discover, list, watch, power, set-color, set-zones, effect, scene")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *static != "" {
+		c.static = strings.Split(*static, ",")
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	commands := map[string]func(context.Context, []string) error{
+		"discover":  c.discover,
+		"list":      c.list,
+		"watch":     c.watch,
+		"power":     c.power,
+		"set-color": c.setColor,
+		"set-zones": c.setZones,
+		"effect":    c.effect,
+		"scene":     c.scene,
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fmt.Fprintf(stderr, "lifxctl: unknown command %q\n", fs.Arg(0))
+		fs.Usage()
+		return errUsage
+	}
+
+	opts := []controller.Option{controller.WithStaticDevices(c.static...)}
+	if c.noBroadcast {
+		opts = append(opts, controller.WithoutDiscovery())
+	}
+	ctrl, err := controller.New(opts...)
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+	c.ctrl = ctrl
+
+	return cmd(ctx, fs.Args()[1:])
+}
+
+// ready reports whether the state of a device is known, including its zones.
+func ready(d device.Device) bool {
+	switch {
+	case !d.Profiled() || d.Label == "":
+		return false
+	case d.Type == device.DeviceTypeSwitch:
+		return true
+	case d.LightType == device.LightTypeMultiZone:
+		return len(d.MultizoneProperties.Zones) > 0
+	case d.LightType == device.LightTypeMatrix:
+		return len(d.MatrixProperties.ChainZones) > 0
+	}
+	return true
+}
+
+// waitDevices waits for the devices to be discovered and their state to be known, until the timeout expires
+// or, when only static devices are probed, until all of them are ready.
+func (c *cli) waitDevices(ctx context.Context) []device.Device {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		devices := c.ctrl.FindDevices(ready)
+		if c.noBroadcast && len(devices) == len(c.static) {
+			return devices
+		}
+		select {
+		case <-ctx.Done():
+			return devices
+		case <-ticker.C:
+		}
+	}
+}
+
+// newFlagSet returns the flag set of a command, printing its usage on errors.
+func (c *cli) newFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: lifxctl %s %s\n", name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of a command and checks it has n positional arguments,
+// or at least n if atLeast is set.
+func parseArgs(fs *flag.FlagSet, args []string, n int, atLeast bool) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() < n || (!atLeast && fs.NArg() > n) {
+		fs.Usage()
+		return errUsage
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/emulator"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/inventory"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	bulb, err := emulator.New(emulator.Config{Label: "Desk", Group: "Office"})
+	require.NoError(t, err)
+	defer bulb.Close()
+	strip, err := emulator.New(emulator.Config{Label: "Shelf", Group: "Lounge", ProductID: 38, Zones: 4})
+	require.NoError(t, err)
+	defer strip.Close()
+
+	lifxctl := func(args ...string) (string, error) {
+		var stdout, stderr bytes.Buffer
+		static := bulb.Addr().String() + "," + strip.Addr().String()
+		args = append([]string{"-static", static, "-no-broadcast", "-timeout", "2s"}, args...)
+		err := run(context.Background(), args, &stdout, &stderr)
+		return stdout.String(), err
+	}
+
+	t.Run("Lists devices", func(t *testing.T) {
+		out, err := lifxctl("-json", "list")
+		require.NoError(t, err)
+		var groups []inventory.Group
+		require.NoError(t, json.Unmarshal([]byte(out), &groups))
+		var labels []string
+		for _, g := range groups {
+			for _, e := range g.Devices {
+				labels = append(labels, e.Label)
+			}
+		}
+		assert.ElementsMatch(t, []string{"Desk", "Shelf"}, labels)
+	})
+
+	t.Run("Discovers devices", func(t *testing.T) {
+		out, err := lifxctl("discover")
+		require.NoError(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 2)
+		assert.Contains(t, out, bulb.Serial().String())
+	})
+
+	t.Run("Turns devices on and off", func(t *testing.T) {
+		out, err := lifxctl("power", "Desk", "on")
+		require.NoError(t, err)
+		assert.Equal(t, bulb.Serial().String()+"\tDesk\tok\n", out)
+		assert.Eventually(t, func() bool { return bulb.State().Power == 65535 }, time.Second, 10*time.Millisecond)
+
+		_, err = lifxctl("power", "group:Office", "off")
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return bulb.State().Power == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Sets the color of devices", func(t *testing.T) {
+		_, err := lifxctl("set-color", "-hue", "120", "-saturation", "100", "-brightness", "50", "-kelvin", "3500", "Desk")
+		require.NoError(t, err)
+		want := device.Color{Hue: 120, Saturation: 100, Brightness: 50, Kelvin: 3500}.ToDeviceColor()
+		assert.Eventually(t, func() bool { return bulb.State().Color == want }, time.Second, 10*time.Millisecond)
+
+		// Only the brightness changes.
+		_, err = lifxctl("set-color", "-brightness", "100", "Desk")
+		require.NoError(t, err)
+		want = device.Color{Hue: 120, Saturation: 100, Brightness: 100, Kelvin: 3500}.ToDeviceColor()
+		assert.Eventually(t, func() bool { return bulb.State().Color == want }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Sets the zones of multizone devices", func(t *testing.T) {
+		red := device.Color{Hue: 0, Saturation: 100, Brightness: 100, Kelvin: 3500}.ToDeviceColor()
+		blue := device.Color{Hue: 240, Saturation: 100, Brightness: 100, Kelvin: 3500}.ToDeviceColor()
+		out, err := lifxctl("-json", "set-zones", "all", "0,100,100", "240,100,100")
+		assert.EqualError(t, err, "failed on 1 of 2 devices")
+
+		var results []result
+		require.NoError(t, json.Unmarshal([]byte(out), &results))
+		assert.ElementsMatch(t, []result{
+			{Serial: bulb.Serial().String(), Label: "Desk", Error: "not a multizone device"},
+			{Serial: strip.Serial().String(), Label: "Shelf"},
+		}, results)
+		assert.Eventually(t, func() bool {
+			return slices.Equal(strip.State().Zones, []packets.LightHsbk{red, red, blue, blue})
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Captures and applies scenes", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "scene.json")
+		green := device.Color{Hue: 120, Saturation: 100, Brightness: 100, Kelvin: 3500}
+		_, err := lifxctl("set-color", "-hue", "120", "-saturation", "100", "-brightness", "100", "Desk")
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return bulb.State().Color == green.ToDeviceColor() }, time.Second, 10*time.Millisecond)
+
+		_, err = lifxctl("scene", "capture", "Desk", file)
+		require.NoError(t, err)
+		_, err = lifxctl("set-color", "-hue", "0", "Desk")
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return bulb.State().Color.Hue == 0 }, time.Second, 10*time.Millisecond)
+
+		_, err = lifxctl("scene", "apply", file)
+		require.NoError(t, err)
+		assert.Eventually(t, func() bool { return bulb.State().Color == green.ToDeviceColor() }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Fails on unmatched selectors", func(t *testing.T) {
+		_, err := lifxctl("power", "Kitchen", "on")
+		assert.EqualError(t, err, `no device matches "Kitchen"`)
+	})
+
+	t.Run("Fails on invalid usage", func(t *testing.T) {
+		for _, args := range [][]string{{}, {"unknown"}, {"power", "Desk"}, {"scene", "restore", "file"}} {
+			var stdout, stderr bytes.Buffer
+			err := run(context.Background(), args, &stdout, &stderr)
+			assert.ErrorIs(t, err, errUsage, args)
+			assert.NotEmpty(t, stderr.String(), args)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// selectDevices returns the devices matching a selector: "all", a serial, a label,
+// "group:<label>" or "location:<label>". Labels are matched ignoring case.
+func selectDevices(devices []device.Device, selector string) ([]device.Device, error) {
+	match := func(d device.Device) bool {
+		return strings.EqualFold(d.Label, selector) || d.Serial.String() == strings.ToLower(selector)
+	}
+	switch kind, label, _ := strings.Cut(selector, ":"); {
+	case selector == "all":
+		match = func(device.Device) bool { return true }
+	case kind == "group":
+		match = func(d device.Device) bool { return strings.EqualFold(d.Group, label) }
+	case kind == "location":
+		match = func(d device.Device) bool { return strings.EqualFold(d.Location, label) }
+	}
+
+	var selected []device.Device
+	for _, d := range devices {
+		if match(d) {
+			selected = append(selected, d)
+		}
+	}
+	if len(selected) == 0 {
+		return nil, fmt.Errorf("no device matches %q", selector)
+	}
+	return selected, nil
+}
+
+// parseColor parses a color in the h,s,b,k form, e.g. 240,100,50,3500, with the hue in degrees
+// and the saturation and brightness in percent. The kelvin can be omitted and defaults to 3500.
+func parseColor(s string) (device.Color, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) < 3 || len(parts) > 4 {
+		return device.Color{}, fmt.Errorf("invalid color %q, expected h,s,b[,k]", s)
+	}
+	var values [3]float64
+	for i := range values {
+		v, err := strconv.ParseFloat(strings.TrimSpace(parts[i]), 64)
+		if err != nil {
+			return device.Color{}, fmt.Errorf("invalid color %q: %w", s, err)
+		}
+		values[i] = v
+	}
+	if values[0] < 0 || values[0] > 360 || values[1] < 0 || values[1] > 100 || values[2] < 0 || values[2] > 100 {
+		return device.Color{}, fmt.Errorf("invalid color %q: out of range", s)
+	}
+
+	kelvin := uint64(3500)
+	if len(parts) == 4 {
+		var err error
+		if kelvin, err = strconv.ParseUint(strings.TrimSpace(parts[3]), 10, 16); err != nil {
+			return device.Color{}, fmt.Errorf("invalid color %q: %w", s, err)
+		}
+	}
+	return device.Color{Hue: values[0], Saturation: values[1], Brightness: values[2], Kelvin: uint16(kelvin)}, nil
+}
+
+// parseParams parses effect parameters in the key=value form. Values are decoded as JSON
+// when valid, e.g. numbers, booleans and colors, and kept as strings otherwise.
+func parseParams(args []string) (map[string]any, error) {
+	params := make(map[string]any, len(args))
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid parameter %q, expected key=value", arg)
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			v = value
+		}
+		params[key] = v
+	}
+	return params, nil
+}
+
+// spreadColors spreads colors over n zones in equal segments, in order.
+func spreadColors(colors []device.Color, n int) []packets.LightHsbk {
+	zones := make([]packets.LightHsbk, n)
+	if len(colors) == 0 {
+		return zones
+	}
+	for i := range zones {
+		zones[i] = colors[i*len(colors)/n].ToDeviceColor()
+	}
+	return zones
+}
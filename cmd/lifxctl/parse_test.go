@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectDevices(t *testing.T) {
+	devices := []device.Device{
+		{Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 1}, Label: "Desk", Group: "Office", Location: "Home"},
+		{Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 2}, Label: "Lamp", Group: "Lounge", Location: "Home"},
+	}
+
+	testCases := map[string]struct {
+		selector string
+		want     []string
+		wantErr  string
+	}{
+		"All":            {selector: "all", want: []string{"Desk", "Lamp"}},
+		"Label":          {selector: "desk", want: []string{"Desk"}},
+		"Serial":         {selector: "D073D5000002", want: []string{"Lamp"}},
+		"Group":          {selector: "group:lounge", want: []string{"Lamp"}},
+		"Location":       {selector: "location:Home", want: []string{"Desk", "Lamp"}},
+		"No match":       {selector: "Kitchen", wantErr: `no device matches "Kitchen"`},
+		"No group match": {selector: "group:Desk", wantErr: `no device matches "group:Desk"`},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := selectDevices(devices, tc.selector)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var labels []string
+			for _, d := range got {
+				labels = append(labels, d.Label)
+			}
+			assert.Equal(t, tc.want, labels)
+		})
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	testCases := map[string]struct {
+		s       string
+		want    device.Color
+		wantErr bool
+	}{
+		"With kelvin":    {s: "240,100,50,2700", want: device.Color{Hue: 240, Saturation: 100, Brightness: 50, Kelvin: 2700}},
+		"Default kelvin": {s: "120, 50, 25", want: device.Color{Hue: 120, Saturation: 50, Brightness: 25, Kelvin: 3500}},
+		"Too few values": {s: "120,50", wantErr: true},
+		"Not a number":   {s: "red,50,25", wantErr: true},
+		"Out of range":   {s: "120,150,25", wantErr: true},
+		"Invalid kelvin": {s: "120,50,25,-1", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseColor(tc.s)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseParams(t *testing.T) {
+	got, err := parseParams([]string{"speed=2.5", "reverse=true", "palette=sunset", `color={"hue":120}`})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{
+		"speed":   2.5,
+		"reverse": true,
+		"palette": "sunset",
+		"color":   map[string]any{"hue": float64(120)},
+	}, got)
+
+	_, err = parseParams([]string{"speed"})
+	assert.EqualError(t, err, `invalid parameter "speed", expected key=value`)
+}
+
+func TestSpreadColors(t *testing.T) {
+	red := device.Color{Hue: 0, Saturation: 100, Brightness: 100, Kelvin: 3500}
+	blue := device.Color{Hue: 240, Saturation: 100, Brightness: 100, Kelvin: 3500}
+
+	zones := spreadColors([]device.Color{red, blue}, 5)
+	assert.Equal(t, []packets.LightHsbk{
+		red.ToDeviceColor(), red.ToDeviceColor(), red.ToDeviceColor(), blue.ToDeviceColor(), blue.ToDeviceColor(),
+	}, zones)
+	assert.Len(t, spreadColors(nil, 3), 3)
+}
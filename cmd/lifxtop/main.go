@@ -0,0 +1,125 @@
+// Command lifxtop shows a live table of the LIFX devices on the LAN, with their power,
+// color, WiFi signal and when they were last seen.
+//
+// Usage:
+//
+//	lifxtop [flags]
+//
+// The table is redrawn as the Controller events arrive, which makes lifxtop a reference
+// for consuming them: the table is seeded with the devices known at start, each event
+// replaces the row of its device, and a periodic snapshot covers the state that changes
+// without events.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+)
+
+const (
+	// clearScreen moves the cursor home and clears the terminal.
+	clearScreen = "\x1b[H\x1b[2J"
+	hideCursor  = "\x1b[?25l"
+	showCursor  = "\x1b[?25h"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout, os.Stderr); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(os.Stderr, "lifxtop:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+// run shows the device table until ctx is done.
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	fs := flag.NewFlagSet("lifxtop", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	static := fs.String("static", "", "comma-separated IP addresses of devices to probe by unicast")
+	noBroadcast := fs.Bool("no-broadcast", false, "only probe the -static devices")
+	refresh := fs.Duration("refresh", time.Second, "how often to redraw the table when no event arrives")
+	plain := fs.Bool("plain", false, "print the table without clearing the screen or drawing colors")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return flag.ErrHelp
+	}
+	if *refresh <= 0 {
+		return fmt.Errorf("invalid refresh %s", *refresh)
+	}
+
+	var opts []controller.Option
+	if *static != "" {
+		opts = append(opts, controller.WithStaticDevices(strings.Split(*static, ",")...))
+	}
+	if *noBroadcast {
+		opts = append(opts, controller.WithoutDiscovery())
+	}
+	ctrl, err := controller.New(opts...)
+	if err != nil {
+		return err
+	}
+	defer ctrl.Close()
+
+	// Subscribe before taking the first snapshot, so that no change is missed in between.
+	events, cancel := ctrl.Subscribe(controller.EventFilter{})
+	defer cancel()
+	t := newTable()
+	t.sync(ctrl.GetDevices())
+
+	if !*plain {
+		fmt.Fprint(stdout, hideCursor)
+		defer fmt.Fprint(stdout, showCursor)
+	}
+	draw := func() {
+		if !*plain {
+			fmt.Fprint(stdout, clearScreen)
+		}
+		t.render(stdout, time.Now(), !*plain)
+	}
+	draw()
+
+	ticker := time.NewTicker(*refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			t.sync(ctrl.GetDevices())
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			t.apply(e)
+			// Apply the events already queued before drawing, so that bursts, e.g. from
+			// an effect, are drawn once.
+			for drained := false; !drained; {
+				select {
+				case e, ok := <-events:
+					if !ok {
+						return nil
+					}
+					t.apply(e)
+				default:
+					drained = true
+				}
+			}
+		}
+		draw()
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("Draws the devices as they are discovered", func(t *testing.T) {
+		bulb, err := emulator.New(emulator.Config{Label: "Desk", Group: "Office"})
+		require.NoError(t, err)
+		defer bulb.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+		defer cancel()
+		var stdout, stderr bytes.Buffer
+		err = run(ctx, []string{"-static", bulb.Addr().String(), "-no-broadcast", "-plain", "-refresh", "100ms"}, &stdout, &stderr)
+		require.NoError(t, err)
+
+		tables := strings.Split(stdout.String(), "Devices: ")
+		assert.Contains(t, tables[len(tables)-1], "Desk")
+		assert.Contains(t, tables[len(tables)-1], "Office")
+		assert.NotContains(t, stdout.String(), clearScreen)
+	})
+
+	t.Run("Fails on invalid usage", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		assert.ErrorIs(t, run(context.Background(), []string{"top"}, &stdout, &stderr), flag.ErrHelp)
+		assert.EqualError(t, run(context.Background(), []string{"-refresh", "0s"}, &stdout, &stderr), "invalid refresh 0s")
+	})
+}
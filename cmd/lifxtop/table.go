@@ -0,0 +1,176 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// row is the state of a device shown in the table.
+type row struct {
+	device  device.Device
+	offline bool
+}
+
+// table is the device table, kept up to date from the controller events.
+type table struct {
+	rows map[device.Serial]*row
+}
+
+func newTable() *table {
+	return &table{rows: make(map[device.Serial]*row)}
+}
+
+// apply updates the table with an event. Every event carries a snapshot of the device,
+// so the row is replaced whatever the event type. A device is offline from an
+// EventDeviceOffline until any other event shows it is reachable again.
+func (t *table) apply(e controller.Event) {
+	r := t.row(e.Serial)
+	r.device = e.Device
+	r.offline = e.Type == controller.EventDeviceOffline
+}
+
+// sync updates the table with a snapshot of the devices, for the state that changes without
+// an event, e.g. the WiFi signal and when devices were last seen, and for the events that were
+// dropped while the table was being drawn.
+func (t *table) sync(devices []device.Device) {
+	for _, d := range devices {
+		t.row(d.Serial).device = d
+	}
+}
+
+// row returns the row of a device, adding it if missing.
+func (t *table) row(serial device.Serial) *row {
+	r, ok := t.rows[serial]
+	if !ok {
+		r = &row{}
+		t.rows[serial] = r
+	}
+	return r
+}
+
+var columns = []string{"LABEL", "GROUP", "POWER", "COLOR", "SIGNAL", "LAST SEEN"}
+
+// colorColumn is the index of the column prefixed by a color swatch.
+const colorColumn = 3
+
+// render writes the table sorted by group and label, with the device ages relative to now.
+// Color swatches are drawn with ANSI 24-bit colors when ansi is set.
+func (t *table) render(w io.Writer, now time.Time, ansi bool) {
+	rows := slices.SortedFunc(maps.Values(t.rows), func(a, b *row) int {
+		return cmp.Or(
+			cmp.Compare(a.device.Group, b.device.Group),
+			cmp.Compare(a.device.Label, b.device.Label),
+			cmp.Compare(a.device.Serial.String(), b.device.Serial.String()),
+		)
+	})
+
+	cells := [][]string{columns}
+	var on, offline int
+	for _, r := range rows {
+		cells = append(cells, r.cells(now))
+		if r.offline {
+			offline++
+		} else if r.device.PoweredOn {
+			on++
+		}
+	}
+
+	widths := make([]int, len(columns))
+	for _, line := range cells {
+		for i, cell := range line {
+			widths[i] = max(widths[i], utf8.RuneCountInString(cell))
+		}
+	}
+
+	fmt.Fprintf(w, "Devices: %d  On: %d  Offline: %d  %s\n\n", len(rows), on, offline, now.Format(time.TimeOnly))
+	for i, line := range cells {
+		var b strings.Builder
+		for j, cell := range line {
+			if j == colorColumn && ansi {
+				swatch := "  "
+				if i > 0 {
+					swatch = rows[i-1].swatch()
+				}
+				b.WriteString(swatch + " ")
+			}
+			b.WriteString(cell)
+			if j < len(line)-1 {
+				b.WriteString(strings.Repeat(" ", widths[j]-utf8.RuneCountInString(cell)+2))
+			}
+		}
+		fmt.Fprintln(w, b.String())
+	}
+}
+
+// cells returns the text of the row columns.
+func (r *row) cells(now time.Time) []string {
+	d := r.device
+	label := d.Label
+	if label == "" {
+		label = d.Serial.String()
+	}
+
+	power := "off"
+	switch {
+	case r.offline:
+		power = "offline"
+	case d.PoweredOn:
+		power = "on"
+	}
+
+	color := "-"
+	if d.Type != device.DeviceTypeSwitch {
+		if d.Color.Saturation == 0 {
+			color = fmt.Sprintf("%dK %.0f%%", d.Color.Kelvin, d.Color.Brightness)
+		} else {
+			color = fmt.Sprintf("%.0f° %.0f%% %.0f%%", d.Color.Hue, d.Color.Saturation, d.Color.Brightness)
+		}
+	}
+
+	signal := "-"
+	// A zero value means the signal has not been reported yet.
+	if d.WifiRSSI != 0 {
+		signal = fmt.Sprintf("%s (%d)", d.WifiRSSI, d.WifiRSSI)
+	}
+
+	return []string{label, cmp.Or(d.Group, "-"), power, color, signal, age(d.LastSeenAt, now)}
+}
+
+// swatch returns two cells of the device color, blank for switches and devices that are off.
+func (r *row) swatch() string {
+	d := r.device
+	if d.Type == device.DeviceTypeSwitch || !d.PoweredOn || r.offline {
+		return "  "
+	}
+
+	c := d.Color
+	red, green, blue := c.HSBToRGB()
+	if c.Saturation == 0 {
+		// Whites are shown with the tint of their temperature.
+		red, green, blue = c.KelvinToRGB()
+		scale := c.Brightness / 100
+		red, green, blue = int(float64(red)*scale), int(float64(green)*scale), int(float64(blue)*scale)
+	}
+	return fmt.Sprintf("\x1b[48;2;%d;%d;%dm  \x1b[0m", red, green, blue)
+}
+
+// age returns how long before now t was, rounded to the second.
+func age(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t).Round(time.Second)
+	if d < time.Second {
+		return "now"
+	}
+	return d.String() + " ago"
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	now := time.Date(2024, 5, 1, 20, 30, 0, 0, time.UTC)
+	desk := device.Device{
+		Serial:     device.Serial{0xd0, 0x73, 0xd5, 0, 0, 1},
+		Label:      "Desk",
+		Group:      "Office",
+		PoweredOn:  true,
+		Color:      device.Color{Hue: 240, Saturation: 100, Brightness: 50, Kelvin: 3500},
+		WifiRSSI:   -55,
+		LastSeenAt: now.Add(-3 * time.Second),
+	}
+	lamp := device.Device{
+		Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 2},
+		Label:  "Lamp",
+		Group:  "Lounge",
+		Color:  device.Color{Brightness: 80, Kelvin: 2700},
+	}
+	relay := device.Device{
+		Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 3},
+		Type:   device.DeviceTypeSwitch,
+	}
+
+	testCases := map[string]struct {
+		devices []device.Device
+		events  []controller.Event
+		want    string
+	}{
+		"Renders devices sorted by group and label": {
+			devices: []device.Device{lamp, relay, desk},
+			want: "Devices: 3  On: 1  Offline: 0  20:30:00\n\n" +
+				"LABEL         GROUP   POWER  COLOR          SIGNAL      LAST SEEN\n" +
+				"d073d5000003  -       off    -              -           -\n" +
+				"Lamp          Lounge  off    2700K 80%      -           -\n" +
+				"Desk          Office  on     240° 100% 50%  Good (-55)  3s ago\n",
+		},
+		"Applies events": {
+			devices: []device.Device{desk},
+			events: []controller.Event{
+				{Type: controller.EventPowerChanged, Serial: desk.Serial, Device: withPower(desk, false)},
+				{Type: controller.EventDeviceDiscovered, Serial: lamp.Serial, Device: lamp},
+				{Type: controller.EventDeviceOffline, Serial: lamp.Serial, Device: lamp},
+			},
+			want: "Devices: 2  On: 0  Offline: 1  20:30:00\n\n" +
+				"LABEL  GROUP   POWER    COLOR          SIGNAL      LAST SEEN\n" +
+				"Lamp   Lounge  offline  2700K 80%      -           -\n" +
+				"Desk   Office  off      240° 100% 50%  Good (-55)  3s ago\n",
+		},
+		"Brings devices back online on any event": {
+			events: []controller.Event{
+				{Type: controller.EventDeviceOffline, Serial: lamp.Serial, Device: lamp},
+				{Type: controller.EventColorChanged, Serial: lamp.Serial, Device: lamp},
+			},
+			want: "Devices: 1  On: 0  Offline: 0  20:30:00\n\n" +
+				"LABEL  GROUP   POWER  COLOR      SIGNAL  LAST SEEN\n" +
+				"Lamp   Lounge  off    2700K 80%  -       -\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tbl := newTable()
+			tbl.sync(tc.devices)
+			for _, e := range tc.events {
+				tbl.apply(e)
+			}
+			var b bytes.Buffer
+			tbl.render(&b, now, false)
+			assert.Equal(t, tc.want, b.String())
+		})
+	}
+}
+
+func TestSwatch(t *testing.T) {
+	testCases := map[string]struct {
+		row  row
+		want string
+	}{
+		"Color": {
+			row:  row{device: device.Device{PoweredOn: true, Color: device.Color{Hue: 0, Saturation: 100, Brightness: 100}}},
+			want: "\x1b[48;2;255;0;0m  \x1b[0m",
+		},
+		"White scaled by brightness": {
+			row:  row{device: device.Device{PoweredOn: true, Color: device.Color{Brightness: 50, Kelvin: 6600}}},
+			want: "\x1b[48;2;127;127;127m  \x1b[0m",
+		},
+		"Off":     {row: row{device: device.Device{Color: device.Color{Saturation: 100, Brightness: 100}}}, want: "  "},
+		"Offline": {row: row{device: device.Device{PoweredOn: true}, offline: true}, want: "  "},
+		"Switch":  {row: row{device: device.Device{PoweredOn: true, Type: device.DeviceTypeSwitch}}, want: "  "},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.row.swatch())
+		})
+	}
+}
+
+func TestAge(t *testing.T) {
+	now := time.Now()
+	assert.Equal(t, "-", age(time.Time{}, now))
+	assert.Equal(t, "now", age(now.Add(-100*time.Millisecond), now))
+	assert.Equal(t, "1m30s ago", age(now.Add(-90*time.Second), now))
+}
+
+func withPower(d device.Device, on bool) device.Device {
+	d.PoweredOn = on
+	return d
+}
@@ -1,3 +1,6 @@
+// Package protocol implements the wire format of the LIFX message header. It backs the
+// pkg/protocol Message, which exposes the header fields through its accessors and should be
+// used to encode and decode messages rather than this package.
 package protocol
 
 import (
@@ -114,7 +117,13 @@ func (h *Header) SetResponseRequired(v bool) {
 }
 
 func (h *Header) MarshalBinary() ([]byte, error) {
-	buf := make([]byte, 36)
+	return h.AppendBinary(make([]byte, 0, HeaderSize))
+}
+
+// AppendBinary appends the binary format of the header to b.
+func (h *Header) AppendBinary(b []byte) ([]byte, error) {
+	b = append(b, make([]byte, HeaderSize)...)
+	buf := b[len(b)-HeaderSize:]
 	binary.LittleEndian.PutUint16(buf[0:], h.Size)
 	binary.LittleEndian.PutUint16(buf[2:], h.FrameFlags)
 	binary.LittleEndian.PutUint32(buf[4:], h.Source)
@@ -125,7 +134,7 @@ func (h *Header) MarshalBinary() ([]byte, error) {
 	copy(buf[24:], h.Reserved2[:])
 	binary.LittleEndian.PutUint16(buf[32:], h.Type)
 	binary.LittleEndian.PutUint16(buf[34:], h.Reserved3)
-	return buf, nil
+	return b, nil
 }
 
 func (h *Header) UnmarshalBinary(data []byte) error {
@@ -0,0 +1,103 @@
+// Package sun computes sunrise, sunset and daylight at a given position.
+package sun
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// julianUnixEpoch is the Julian date of the Unix epoch.
+	julianUnixEpoch = 2440587.5
+	// julian2000 is the Julian date of 2000-01-01 12:00 UTC.
+	julian2000 = 2451545.0
+	// sunAltitude is the apparent altitude of the sun center at sunrise and sunset,
+	// accounting for atmospheric refraction and the solar disc.
+	sunAltitude = -0.833
+	// earthTilt is the axial tilt of the Earth in degrees.
+	earthTilt = 23.4397
+)
+
+// Phase describes the daylight of a given date at a given position.
+type Phase int
+
+const (
+	// PhaseNormal is a day with both sunrise and sunset.
+	PhaseNormal Phase = iota
+	// PhasePolarNight is a day where the sun never rises.
+	PhasePolarNight
+	// PhaseMidnightSun is a day where the sun never sets.
+	PhaseMidnightSun
+)
+
+// Times returns sunrise and sunset for the day of date at the given latitude and longitude
+// (in degrees, north and east positive). Times are returned in the location of date.
+// If the sun does not rise or set that day, sunrise and sunset are zero and the phase says why.
+func Times(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, phase Phase) {
+	// Days since 2000-01-01 12:00 UTC of the calendar day of date.
+	noon := time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, time.UTC)
+	n := math.Round(toJulian(noon) - julian2000)
+
+	// Mean solar time
+	j := n - longitude/360
+	// Solar mean anomaly
+	m := math.Mod(357.5291+0.98560028*j, 360)
+	mRad := radians(m)
+	// Equation of the center
+	c := 1.9148*math.Sin(mRad) + 0.02*math.Sin(2*mRad) + 0.0003*math.Sin(3*mRad)
+	// Ecliptic longitude
+	l := radians(math.Mod(m+c+180+102.9372, 360))
+	// Solar transit
+	transit := julian2000 + j + 0.0053*math.Sin(mRad) - 0.0069*math.Sin(2*l)
+	// Declination of the sun
+	sinD := math.Sin(l) * math.Sin(radians(earthTilt))
+	cosD := math.Cos(math.Asin(sinD))
+	// Hour angle
+	lat := radians(latitude)
+	cosW := (math.Sin(radians(sunAltitude)) - math.Sin(lat)*sinD) / (math.Cos(lat) * cosD)
+
+	switch {
+	case cosW > 1:
+		return time.Time{}, time.Time{}, PhasePolarNight
+	case cosW < -1:
+		return time.Time{}, time.Time{}, PhaseMidnightSun
+	}
+
+	w := degrees(math.Acos(cosW))
+	sunrise = fromJulian(transit - w/360).In(date.Location())
+	sunset = fromJulian(transit + w/360).In(date.Location())
+	return sunrise, sunset, PhaseNormal
+}
+
+// Daylight returns a factor between 0 (night) and 1 (solar noon) at time t for the given
+// latitude and longitude. It follows a sine curve between sunrise and sunset.
+func Daylight(t time.Time, latitude, longitude float64) float64 {
+	sunrise, sunset, phase := Times(t, latitude, longitude)
+	switch phase {
+	case PhasePolarNight:
+		return 0
+	case PhaseMidnightSun:
+		return 1
+	}
+	if t.Before(sunrise) || !t.Before(sunset) {
+		return 0
+	}
+	progress := float64(t.Sub(sunrise)) / float64(sunset.Sub(sunrise))
+	return math.Sin(math.Pi * progress)
+}
+
+func toJulian(t time.Time) float64 {
+	return float64(t.Unix())/86400 + julianUnixEpoch
+}
+
+func fromJulian(j float64) time.Time {
+	return time.Unix(0, int64((j-julianUnixEpoch)*86400*float64(time.Second)))
+}
+
+func radians(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+func degrees(r float64) float64 {
+	return r * 180 / math.Pi
+}
@@ -0,0 +1,33 @@
+package sun
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaylight(t *testing.T) {
+	const lat, lon = 51.5074, -0.1278
+	sunrise, sunset, phase := Times(time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), lat, lon)
+	assert.Equal(t, PhaseNormal, phase)
+
+	testCases := map[string]struct {
+		t    time.Time
+		lat  float64
+		want float64
+	}{
+		"before sunrise": {t: sunrise.Add(-time.Minute), lat: lat, want: 0},
+		"at sunrise":     {t: sunrise, lat: lat, want: 0},
+		"solar noon":     {t: sunrise.Add(sunset.Sub(sunrise) / 2), lat: lat, want: 1},
+		"at sunset":      {t: sunset, lat: lat, want: 0},
+		"polar night":    {t: time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC), lat: 80, want: 0},
+		"midnight sun":   {t: time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC), lat: 80, want: 1},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.InDelta(t, tc.want, Daylight(tc.t, tc.lat, lon), 1e-6)
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package testutil
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// maxFrameBuffers is the number of frame buffers of a matrix device, 0 being the visible one.
+const maxFrameBuffers = 8
+
+var update = flag.Bool("update", false, "update golden files")
+
+// Frame is the visible state of a matrix chain after a message was applied.
+type Frame struct {
+	Duration time.Duration
+	// Tiles holds the visible colors of each tile in the chain, row by row.
+	Tiles [][]packets.LightHsbk
+}
+
+// FrameRecorder models the frame buffers of a chain of matrix devices and records
+// each frame made visible by the messages it is sent, including frame buffer copies
+// used by devices with more than 64 zones.
+// Its Send method can be used as a matrix.SendFunc.
+type FrameRecorder struct {
+	width, height int
+
+	mu sync.Mutex
+	// fbs holds the frame buffers of each tile in the chain.
+	fbs    [][maxFrameBuffers][]packets.LightHsbk
+	frames []Frame
+}
+
+// NewFrameRecorder returns a FrameRecorder for a chain of chainLength tiles of the given size.
+func NewFrameRecorder(width, height, chainLength int) *FrameRecorder {
+	fbs := make([][maxFrameBuffers][]packets.LightHsbk, chainLength)
+	for i := range fbs {
+		for fb := range fbs[i] {
+			fbs[i][fb] = make([]packets.LightHsbk, width*height)
+		}
+	}
+	return &FrameRecorder{width: width, height: height, fbs: fbs}
+}
+
+// Send applies msg to the frame buffers, recording a frame if the visible buffer changed.
+// Messages other than TileSet64 and TileCopyFrameBuffer are ignored.
+func (r *FrameRecorder) Send(msg *protocol.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch p := msg.Payload.(type) {
+	case *packets.TileSet64:
+		for _, fbs := range r.tiles(p.TileIndex, p.Length) {
+			r.setRect(fbs[p.Rect.FbIndex%maxFrameBuffers], int(p.Rect.X), int(p.Rect.Y), int(p.Rect.Width), p.Colors[:])
+		}
+		if p.Rect.FbIndex == 0 {
+			r.record(time.Duration(p.Duration) * time.Millisecond)
+		}
+	case *packets.TileCopyFrameBuffer:
+		for _, fbs := range r.tiles(p.TileIndex, p.Length) {
+			src, dst := fbs[p.SrcFbIndex%maxFrameBuffers], fbs[p.DstFbIndex%maxFrameBuffers]
+			for y := range int(p.Height) {
+				for x := range int(p.Width) {
+					sx, sy := int(p.SrcX)+x, int(p.SrcY)+y
+					dx, dy := int(p.DstX)+x, int(p.DstY)+y
+					if r.inBounds(sx, sy) && r.inBounds(dx, dy) {
+						dst[dy*r.width+dx] = src[sy*r.width+sx]
+					}
+				}
+			}
+		}
+		if p.DstFbIndex == 0 {
+			r.record(time.Duration(p.Duration) * time.Millisecond)
+		}
+	}
+	return nil
+}
+
+// Frames returns the recorded frames.
+func (r *FrameRecorder) Frames() []Frame {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Frame(nil), r.frames...)
+}
+
+// Golden returns the recorded frames in the golden file format.
+// The file starts with a legend of the colors used, each assigned a symbol,
+// followed by each frame as a header and the rows of each tile, with unset pixels written as dots.
+func (r *FrameRecorder) Golden() []byte {
+	frames := r.Frames()
+
+	symbols := make(map[packets.LightHsbk]string)
+	var legend bytes.Buffer
+	for _, f := range frames {
+		for _, colors := range f.Tiles {
+			for _, c := range colors {
+				if _, ok := symbols[c]; ok || c == (packets.LightHsbk{}) {
+					continue
+				}
+				symbols[c] = goldenSymbol(len(symbols))
+				fmt.Fprintf(&legend, "%s hue=%d saturation=%d brightness=%d kelvin=%d\n",
+					symbols[c], c.Hue, c.Saturation, c.Brightness, c.Kelvin)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	b.Write(legend.Bytes())
+	for i, f := range frames {
+		fmt.Fprintf(&b, "# frame %d duration %s\n", i, f.Duration)
+		for ti, colors := range f.Tiles {
+			fmt.Fprintf(&b, "tile %d\n", ti)
+			for y := range r.height {
+				for x := range r.width {
+					if x > 0 {
+						b.WriteByte(' ')
+					}
+					if s, ok := symbols[colors[y*r.width+x]]; ok {
+						b.WriteString(s)
+					} else {
+						b.WriteString(".")
+					}
+				}
+				b.WriteByte('\n')
+			}
+		}
+	}
+	return b.Bytes()
+}
+
+// goldenSymbols are the symbols assigned to colors in golden files.
+const goldenSymbols = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+
+// goldenSymbol returns the symbol of the i-th color of a golden file,
+// falling back to its index once single character symbols are exhausted.
+func goldenSymbol(i int) string {
+	if i < len(goldenSymbols) {
+		return goldenSymbols[i : i+1]
+	}
+	return fmt.Sprintf("#%d", i)
+}
+
+// tiles returns the frame buffers of the tiles addressed by a message.
+func (r *FrameRecorder) tiles(index, length uint8) [][maxFrameBuffers][]packets.LightHsbk {
+	start := min(int(index), len(r.fbs))
+	end := min(start+max(int(length), 1), len(r.fbs))
+	return r.fbs[start:end]
+}
+
+// setRect writes colors into fb, row by row, in a rectangle of the given width at (x, y).
+func (r *FrameRecorder) setRect(fb []packets.LightHsbk, x, y, width int, colors []packets.LightHsbk) {
+	if width == 0 {
+		return
+	}
+	for i, c := range colors {
+		px, py := x+i%width, y+i/width
+		if r.inBounds(px, py) {
+			fb[py*r.width+px] = c
+		}
+	}
+}
+
+func (r *FrameRecorder) inBounds(x, y int) bool {
+	return x >= 0 && x < r.width && y >= 0 && y < r.height
+}
+
+// record appends the current visible frame of every tile.
+func (r *FrameRecorder) record(d time.Duration) {
+	f := Frame{Duration: d, Tiles: make([][]packets.LightHsbk, len(r.fbs))}
+	for i, fbs := range r.fbs {
+		f.Tiles[i] = append([]packets.LightHsbk(nil), fbs[0]...)
+	}
+	r.frames = append(r.frames, f)
+}
+
+// AssertGolden compares got with the content of the golden file at path.
+// When tests are run with -update the golden file is written instead.
+func AssertGolden(t *testing.T, path string, got []byte) {
+	t.Helper()
+
+	if *update {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, got, 0o644))
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	require.NoError(t, err, "missing golden file, run tests with -update to create it")
+	assert.Equal(t, string(want), string(got))
+}
+
+// AssertFrames compares the frames recorded by r with the golden file at path.
+func AssertFrames(t *testing.T, r *FrameRecorder, path string) {
+	t.Helper()
+	AssertGolden(t, path, r.Golden())
+}
@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameRecorder(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	blue := packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	t.Run("Records frames set to the visible buffer", func(t *testing.T) {
+		rec := NewFrameRecorder(2, 2, 2)
+		require.NoError(t, rec.Send(protocol.NewMessage(&packets.TileSet64{
+			TileIndex: 0, Length: 2, Rect: packets.TileBufferRect{Width: 2}, Duration: 100,
+			Colors: [64]packets.LightHsbk{red, {}, {}, blue},
+		})))
+		// Messages other than tile frames are ignored.
+		require.NoError(t, rec.Send(protocol.NewMessage(&packets.LightGet{})))
+
+		want := []Frame{{
+			Duration: 100 * time.Millisecond,
+			Tiles:    [][]packets.LightHsbk{{red, {}, {}, blue}, {red, {}, {}, blue}},
+		}}
+		assert.Equal(t, want, rec.Frames())
+		assert.Equal(t, "A hue=0 saturation=65535 brightness=65535 kelvin=3500\n"+
+			"B hue=43690 saturation=65535 brightness=65535 kelvin=3500\n"+
+			"# frame 0 duration 100ms\n"+
+			"tile 0\nA .\n. B\n"+
+			"tile 1\nA .\n. B\n", string(rec.Golden()))
+	})
+
+	t.Run("Records frame buffer flips", func(t *testing.T) {
+		rec := NewFrameRecorder(2, 2, 1)
+		require.NoError(t, rec.Send(protocol.NewMessage(&packets.TileSet64{
+			Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 2, Y: 1},
+			Colors: [64]packets.LightHsbk{red, blue},
+		})))
+		assert.Empty(t, rec.Frames())
+
+		require.NoError(t, rec.Send(protocol.NewMessage(&packets.TileCopyFrameBuffer{
+			Length: 1, SrcFbIndex: 1, Width: 2, Height: 2, Duration: 50,
+		})))
+		want := []Frame{{
+			Duration: 50 * time.Millisecond,
+			Tiles:    [][]packets.LightHsbk{{{}, {}, red, blue}},
+		}}
+		assert.Equal(t, want, rec.Frames())
+	})
+}
@@ -1,6 +1,7 @@
 package testutil
 
 import (
+	"errors"
 	"net"
 	"testing"
 
@@ -8,27 +9,46 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+// recvBufferSize is the size of the buffer datagrams are read into.
+const recvBufferSize = 1024
+
 func NewMockUDPServer(t *testing.T, handler func(*protocol.Message, *net.UDPAddr)) (*net.UDPConn, *net.UDPAddr) {
 	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}
 	conn, err := net.ListenUDP("udp", addr)
 	require.NoError(t, err)
 
-	go func() {
-		buf := make([]byte, 1024)
-		for {
-			n, src, err := conn.ReadFromUDP(buf)
-			if err != nil {
-				return
-			}
+	go ServeUDP(conn, func(msg *protocol.Message, src *net.UDPAddr, err error) {
+		// skip undecodable payloads
+		if err == nil {
+			handler(msg, src)
+		}
+	})
+
+	return conn, conn.LocalAddr().(*net.UDPAddr)
+}
 
-			var msg protocol.Message
-			if err := msg.UnmarshalBinary(buf[:n]); err != nil {
-				// skip malformed
-				continue
+// ServeUDP calls handler with each message received on conn, returning once conn is closed.
+// Messages whose payload cannot be decoded are passed with the *protocol.DecodeError, so that
+// their header can still be read. Malformed messages are skipped.
+func ServeUDP(conn *net.UDPConn, handler func(msg *protocol.Message, src *net.UDPAddr, err error)) {
+	buf := make([]byte, recvBufferSize)
+	for {
+		n, src, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
 			}
-			handler(&msg, src)
+			continue
 		}
-	}()
 
-	return conn, conn.LocalAddr().(*net.UDPAddr)
+		var (
+			msg       protocol.Message
+			decodeErr *protocol.DecodeError
+		)
+		err = msg.UnmarshalBinary(buf[:n])
+		if err != nil && !errors.As(err, &decodeErr) {
+			continue
+		}
+		handler(&msg, src, err)
+	}
 }
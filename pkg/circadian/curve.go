@@ -0,0 +1,10 @@
+package circadian
+
+import "github.com/alessio-palumbo/lifxlan-go/pkg/device"
+
+// Curve maps the daylight of a position to a white color temperature and brightness,
+// see device.CircadianCurve.
+type Curve = device.CircadianCurve
+
+// DefaultCurve is a warm evening and cool daylight curve, the one device.CircadianColor follows.
+var DefaultCurve = device.DefaultCircadianCurve
@@ -0,0 +1,231 @@
+// Package circadian adjusts the white color temperature and brightness of devices
+// through the day following the daylight of a given position.
+package circadian
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+const (
+	defaultInterval   = time.Minute
+	defaultTransition = 5 * time.Second
+	defaultResetAt    = 4 * time.Hour
+
+	// Tolerances used to compare device state against the values set by the daemon,
+	// accounting for rounding of device values.
+	kelvinTolerance     = 50
+	brightnessTolerance = 2
+	saturationTolerance = 2
+)
+
+// ErrMissingController is returned when a Daemon is created without a Controller.
+var ErrMissingController = errors.New("missing controller")
+
+// Controller gives access to devices state and sends messages to them.
+// *controller.Controller implements it.
+type Controller interface {
+	GetDevices() []device.Device
+	Send(serial device.Serial, msg *protocol.Message) error
+}
+
+// Config configures a Daemon.
+type Config struct {
+	// Latitude and Longitude of the devices, in degrees, north and east positive.
+	Latitude, Longitude float64
+	// Curve defines the kelvin and brightness range. If zero DefaultCurve is used.
+	Curve Curve
+	// Serials selects the devices to adjust. If empty all lights are adjusted.
+	Serials []device.Serial
+	// Interval is how often devices are adjusted. Defaults to one minute.
+	Interval time.Duration
+	// Transition is the duration of each adjustment. Defaults to 5 seconds.
+	Transition time.Duration
+	// ResetAt is the time of the day, as an offset from midnight, at which manual overrides
+	// are cleared and devices are adjusted again. Defaults to 4am if nil, use a zero
+	// duration to reset at midnight.
+	ResetAt *time.Duration
+}
+
+// Daemon periodically adjusts devices following a circadian Curve.
+// Devices reported as externally modified by the Controller (see device.Device.ExternallyModified),
+// or whose color or brightness is changed by other means, are considered manually overridden
+// and are skipped until the next reset time.
+// Devices that are powered off are left untouched.
+type Daemon struct {
+	ctrl Controller
+	cfg  Config
+	// resetAt is the offset from midnight of the daily overrides reset.
+	resetAt time.Duration
+
+	mu     sync.Mutex
+	states map[device.Serial]*deviceState
+}
+
+type deviceState struct {
+	// targets are the last two values set by the daemon, as a device snapshot
+	// might be taken before or during the last transition.
+	targets         []target
+	overriddenUntil time.Time
+	// resetAt is when overrides were last cleared, external modifications
+	// observed before then are ignored.
+	resetAt time.Time
+}
+
+type target struct {
+	kelvin     uint16
+	brightness float64
+}
+
+// New returns a Daemon adjusting the devices of ctrl according to cfg.
+func New(ctrl Controller, cfg Config) (*Daemon, error) {
+	if ctrl == nil {
+		return nil, ErrMissingController
+	}
+	if cfg.Latitude < -90 || cfg.Latitude > 90 || cfg.Longitude < -180 || cfg.Longitude > 180 {
+		return nil, fmt.Errorf("invalid coordinates %v, %v", cfg.Latitude, cfg.Longitude)
+	}
+	if cfg.Curve == (Curve{}) {
+		cfg.Curve = DefaultCurve
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultInterval
+	}
+	if cfg.Transition <= 0 {
+		cfg.Transition = defaultTransition
+	}
+	resetAt := defaultResetAt
+	if cfg.ResetAt != nil {
+		resetAt = (*cfg.ResetAt%(24*time.Hour) + 24*time.Hour) % (24 * time.Hour)
+	}
+
+	return &Daemon{ctrl: ctrl, cfg: cfg, resetAt: resetAt, states: make(map[device.Serial]*deviceState)}, nil
+}
+
+// Run adjusts the devices every configured interval until ctx is cancelled.
+func (d *Daemon) Run(ctx context.Context) error {
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		_ = d.Step(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// Step adjusts the selected devices once for the given time.
+func (d *Daemon) Step(now time.Time) error {
+	kelvin, brightness := d.cfg.Curve.At(now, d.cfg.Latitude, d.cfg.Longitude)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var errs []error
+	for _, dev := range d.ctrl.GetDevices() {
+		if !d.selected(dev) || !dev.PoweredOn {
+			continue
+		}
+
+		state := d.states[dev.Serial]
+		if state == nil {
+			state = &deviceState{}
+			d.states[dev.Serial] = state
+		}
+
+		if now.Before(state.overriddenUntil) {
+			continue
+		}
+		if !state.overriddenUntil.IsZero() {
+			// Override expired, start over.
+			*state = deviceState{resetAt: state.overriddenUntil}
+		}
+		if state.externallyModified(dev) || (len(state.targets) > 0 && !state.matches(dev.Color)) {
+			state.overriddenUntil = d.nextReset(now)
+			state.targets = nil
+			continue
+		}
+
+		saturation := 0.0
+		msg := messages.SetColor(nil, &saturation, &brightness, &kelvin, d.cfg.Transition, 0)
+		if err := d.ctrl.Send(dev.Serial, msg); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		state.targets = append(state.targets, target{kelvin: kelvin, brightness: brightness})
+		if len(state.targets) > 2 {
+			state.targets = state.targets[1:]
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Overridden returns whether the device with the given serial is currently skipped
+// because of a manual override.
+func (d *Daemon) Overridden(serial device.Serial, now time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.states[serial]
+	return s != nil && now.Before(s.overriddenUntil)
+}
+
+// ClearOverrides clears all manual overrides, so devices are adjusted on the next step.
+func (d *Daemon) ClearOverrides() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	for _, s := range d.states {
+		*s = deviceState{resetAt: now}
+	}
+}
+
+func (d *Daemon) selected(dev device.Device) bool {
+	if len(d.cfg.Serials) > 0 {
+		return slices.Contains(d.cfg.Serials, dev.Serial)
+	}
+	return dev.Type != device.DeviceTypeSwitch
+}
+
+// nextReset returns the next occurrence of the reset time after now.
+func (d *Daemon) nextReset(now time.Time) time.Time {
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	reset := midnight.Add(d.resetAt)
+	if !reset.After(now) {
+		reset = midnight.AddDate(0, 0, 1).Add(d.resetAt)
+	}
+	return reset
+}
+
+// externallyModified returns whether the device was modified by someone else since the last reset.
+func (s *deviceState) externallyModified(dev device.Device) bool {
+	return dev.ExternallyModified() && dev.ExternallyModifiedAt.After(s.resetAt)
+}
+
+// matches returns whether the color is within the range of the last values set by the daemon.
+func (s *deviceState) matches(c device.Color) bool {
+	if c.Saturation > saturationTolerance {
+		return false
+	}
+
+	minK, maxK := s.targets[0].kelvin, s.targets[0].kelvin
+	minB, maxB := s.targets[0].brightness, s.targets[0].brightness
+	for _, t := range s.targets[1:] {
+		minK, maxK = min(minK, t.kelvin), max(maxK, t.kelvin)
+		minB, maxB = min(minB, t.brightness), max(maxB, t.brightness)
+	}
+
+	return float64(c.Kelvin) >= float64(minK)-kelvinTolerance && float64(c.Kelvin) <= float64(maxK)+kelvinTolerance &&
+		c.Brightness >= minB-brightnessTolerance && c.Brightness <= maxB+brightnessTolerance
+}
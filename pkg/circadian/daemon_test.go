@@ -0,0 +1,213 @@
+package circadian
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	serial0 = device.Serial{1, 0, 0, 0, 0, 0, 0, 0}
+	serial1 = device.Serial{2, 0, 0, 0, 0, 0, 0, 0}
+)
+
+// mockController applies sent colors to its devices, as a device would after a transition.
+type mockController struct {
+	mu      sync.Mutex
+	devices map[device.Serial]*device.Device
+	sends   map[device.Serial]int
+}
+
+func newMockController(devices ...device.Device) *mockController {
+	m := &mockController{devices: make(map[device.Serial]*device.Device), sends: make(map[device.Serial]int)}
+	for _, d := range devices {
+		m.devices[d.Serial] = &d
+	}
+	return m
+}
+
+func (m *mockController) GetDevices() []device.Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var devices []device.Device
+	for _, d := range m.devices {
+		devices = append(devices, *d)
+	}
+	device.SortDevices(devices)
+	return devices
+}
+
+func (m *mockController) Send(serial device.Serial, msg *protocol.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := msg.Payload.(*packets.LightSetWaveformOptional)
+	m.devices[serial].Color = device.NewColor(p.Color)
+	m.devices[serial].ExternallyModifiedAt = time.Time{}
+	m.sends[serial]++
+	return nil
+}
+
+func (m *mockController) setColor(serial device.Serial, c device.Color) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices[serial].Color = c
+}
+
+func (m *mockController) setExternallyModified(serial device.Serial, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices[serial].ExternallyModifiedAt = at
+}
+
+func (m *mockController) sendCount(serial device.Serial) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sends[serial]
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(nil, Config{})
+	assert.ErrorIs(t, err, ErrMissingController)
+
+	_, err = New(newMockController(), Config{Latitude: 91})
+	assert.Error(t, err)
+
+	d, err := New(newMockController(), Config{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultCurve, d.cfg.Curve)
+	assert.Equal(t, defaultInterval, d.cfg.Interval)
+	assert.Equal(t, defaultTransition, d.cfg.Transition)
+	assert.Equal(t, defaultResetAt, d.resetAt)
+
+	for resetAt, want := range map[time.Duration]time.Duration{
+		0:              0,
+		26 * time.Hour: 2 * time.Hour,
+		-time.Hour:     23 * time.Hour,
+	} {
+		d, err = New(newMockController(), Config{ResetAt: &resetAt})
+		require.NoError(t, err)
+		assert.Equal(t, want, d.resetAt)
+	}
+}
+
+func TestDaemonStep(t *testing.T) {
+	noon := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	t.Run("Adjusts selected powered on devices", func(t *testing.T) {
+		ctrl := newMockController(
+			device.Device{Serial: serial0, PoweredOn: true, Color: device.Color{Hue: 120, Saturation: 100}},
+			device.Device{Serial: serial1, PoweredOn: false},
+			device.Device{Serial: device.Serial{3}, PoweredOn: true},
+		)
+		d, err := New(ctrl, Config{Serials: []device.Serial{serial0, serial1}})
+		require.NoError(t, err)
+
+		require.NoError(t, d.Step(noon))
+		assert.Equal(t, 1, ctrl.sendCount(serial0))
+		assert.Equal(t, 0, ctrl.sendCount(serial1))
+		assert.Equal(t, 0, ctrl.sendCount(device.Serial{3}))
+
+		got := ctrl.GetDevices()[0].Color
+		assert.Zero(t, got.Saturation)
+		assert.InDelta(t, float64(DefaultCurve.MaxKelvin), float64(got.Kelvin), 5)
+	})
+
+	t.Run("Skips manually overridden devices until reset", func(t *testing.T) {
+		ctrl := newMockController(device.Device{Serial: serial0, PoweredOn: true})
+		d, err := New(ctrl, Config{ResetAt: ptr(4 * time.Hour)})
+		require.NoError(t, err)
+
+		require.NoError(t, d.Step(noon))
+		require.NoError(t, d.Step(noon.Add(time.Minute)))
+		assert.Equal(t, 2, ctrl.sendCount(serial0))
+
+		// Stale snapshot from before the last transition is not an override.
+		ctrl.setColor(serial0, device.NewColor(packets.LightHsbk{Kelvin: 5500, Brightness: 65535}))
+		require.NoError(t, d.Step(noon.Add(2*time.Minute)))
+		assert.Equal(t, 3, ctrl.sendCount(serial0))
+
+		// User sets a color.
+		ctrl.setColor(serial0, device.Color{Hue: 240, Saturation: 100, Brightness: 50, Kelvin: 3500})
+		require.NoError(t, d.Step(noon.Add(3*time.Minute)))
+		assert.Equal(t, 3, ctrl.sendCount(serial0))
+		assert.True(t, d.Overridden(serial0, noon.Add(3*time.Minute)))
+
+		require.NoError(t, d.Step(noon.Add(10*time.Hour)))
+		assert.Equal(t, 3, ctrl.sendCount(serial0))
+
+		// Next day after 4am.
+		resetAt := time.Date(2024, 3, 21, 4, 0, 0, 0, time.UTC)
+		assert.False(t, d.Overridden(serial0, resetAt))
+		require.NoError(t, d.Step(resetAt))
+		assert.Equal(t, 4, ctrl.sendCount(serial0))
+	})
+
+	t.Run("Skips externally modified devices until reset", func(t *testing.T) {
+		ctrl := newMockController(device.Device{Serial: serial0, PoweredOn: true})
+		d, err := New(ctrl, Config{ResetAt: ptr(4 * time.Hour)})
+		require.NoError(t, err)
+
+		require.NoError(t, d.Step(noon))
+		assert.Equal(t, 1, ctrl.sendCount(serial0))
+
+		// User powers the device off and on from the app, leaving the color unchanged.
+		ctrl.setExternallyModified(serial0, noon.Add(30*time.Second))
+		require.NoError(t, d.Step(noon.Add(time.Minute)))
+		assert.Equal(t, 1, ctrl.sendCount(serial0))
+		assert.True(t, d.Overridden(serial0, noon.Add(time.Minute)))
+
+		// The modification observed before the reset does not override the device again.
+		resetAt := time.Date(2024, 3, 21, 4, 0, 0, 0, time.UTC)
+		require.NoError(t, d.Step(resetAt))
+		assert.Equal(t, 2, ctrl.sendCount(serial0))
+	})
+
+	t.Run("Clears overrides", func(t *testing.T) {
+		ctrl := newMockController(device.Device{Serial: serial0, PoweredOn: true})
+		d, err := New(ctrl, Config{})
+		require.NoError(t, err)
+
+		require.NoError(t, d.Step(noon))
+		ctrl.setColor(serial0, device.Color{Brightness: 10, Kelvin: 2700})
+		require.NoError(t, d.Step(noon.Add(time.Minute)))
+		assert.True(t, d.Overridden(serial0, noon.Add(time.Minute)))
+
+		d.ClearOverrides()
+		require.NoError(t, d.Step(noon.Add(2*time.Minute)))
+		assert.Equal(t, 2, ctrl.sendCount(serial0))
+	})
+}
+
+func TestDaemonRun(t *testing.T) {
+	ctrl := newMockController(device.Device{Serial: serial0, PoweredOn: true})
+	d, err := New(ctrl, Config{Interval: time.Millisecond})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, d.Run(ctx), context.DeadlineExceeded)
+	assert.Greater(t, ctrl.sendCount(serial0), 1)
+}
+
+func TestDaemonNextReset(t *testing.T) {
+	noon := time.Date(2024, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	d, err := New(newMockController(), Config{ResetAt: ptr(time.Duration(0))})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 21, 0, 0, 0, 0, time.UTC), d.nextReset(noon))
+
+	d, err = New(newMockController(), Config{ResetAt: ptr(13 * time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 20, 13, 0, 0, 0, time.UTC), d.nextReset(noon))
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,27 @@
+package circadian
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/sun"
+)
+
+// DayPhase describes the daylight of a given date at a given position.
+type DayPhase int
+
+const (
+	// DayPhaseNormal is a day with both sunrise and sunset.
+	DayPhaseNormal = DayPhase(sun.PhaseNormal)
+	// DayPhasePolarNight is a day where the sun never rises.
+	DayPhasePolarNight = DayPhase(sun.PhasePolarNight)
+	// DayPhaseMidnightSun is a day where the sun never sets.
+	DayPhaseMidnightSun = DayPhase(sun.PhaseMidnightSun)
+)
+
+// SunTimes returns sunrise and sunset for the day of date at the given latitude and longitude
+// (in degrees, north and east positive). Times are returned in the location of date.
+// If the sun does not rise or set that day, sunrise and sunset are zero and the phase says why.
+func SunTimes(date time.Time, latitude, longitude float64) (sunrise, sunset time.Time, phase DayPhase) {
+	sunrise, sunset, p := sun.Times(date, latitude, longitude)
+	return sunrise, sunset, DayPhase(p)
+}
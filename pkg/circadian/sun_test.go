@@ -0,0 +1,86 @@
+package circadian
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSunTimes(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("timezone data not available")
+	}
+
+	testCases := map[string]struct {
+		date        time.Time
+		lat, lon    float64
+		wantSunrise time.Time
+		wantSunset  time.Time
+		wantPhase   DayPhase
+	}{
+		"London summer solstice": {
+			date:        time.Date(2024, 6, 21, 0, 0, 0, 0, london),
+			lat:         51.5074,
+			lon:         -0.1278,
+			wantSunrise: time.Date(2024, 6, 21, 4, 43, 0, 0, london),
+			wantSunset:  time.Date(2024, 6, 21, 21, 21, 0, 0, london),
+		},
+		"London winter solstice": {
+			date:        time.Date(2024, 12, 21, 15, 0, 0, 0, london),
+			lat:         51.5074,
+			lon:         -0.1278,
+			wantSunrise: time.Date(2024, 12, 21, 8, 4, 0, 0, london),
+			wantSunset:  time.Date(2024, 12, 21, 15, 53, 0, 0, london),
+		},
+		"Polar night": {
+			date:      time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC),
+			lat:       69.6492,
+			lon:       18.9553,
+			wantPhase: DayPhasePolarNight,
+		},
+		"Midnight sun": {
+			date:      time.Date(2024, 6, 21, 12, 0, 0, 0, time.UTC),
+			lat:       69.6492,
+			lon:       18.9553,
+			wantPhase: DayPhaseMidnightSun,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			sunrise, sunset, phase := SunTimes(tc.date, tc.lat, tc.lon)
+			assert.Equal(t, tc.wantPhase, phase)
+			if tc.wantPhase != DayPhaseNormal {
+				return
+			}
+			assert.WithinDuration(t, tc.wantSunrise, sunrise, 3*time.Minute)
+			assert.WithinDuration(t, tc.wantSunset, sunset, 3*time.Minute)
+			assert.Equal(t, london, sunrise.Location())
+		})
+	}
+}
+
+func TestCurveAt(t *testing.T) {
+	curve := Curve{MinKelvin: 2000, MaxKelvin: 6000, MinBrightness: 20, MaxBrightness: 100}
+	// Equator at the equinox: sunrise ~6am, sunset ~6pm UTC.
+	day := time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC)
+
+	k, b := curve.At(day.Add(2*time.Hour), 0, 0)
+	assert.Equal(t, uint16(2000), k)
+	assert.Equal(t, 20.0, b)
+
+	k, b = curve.At(day.Add(12*time.Hour), 0, 0)
+	assert.InDelta(t, 6000, float64(k), 5)
+	assert.InDelta(t, 100, b, 0.1)
+
+	k, b = curve.At(day.Add(9*time.Hour), 0, 0)
+	assert.Greater(t, k, uint16(4000))
+	assert.Less(t, k, uint16(6000))
+	assert.Greater(t, b, 60.0)
+
+	k, b = curve.At(day.Add(23*time.Hour), 0, 0)
+	assert.Equal(t, uint16(2000), k)
+	assert.Equal(t, 20.0, b)
+}
@@ -1,8 +1,17 @@
+// Package client sends and receives LIFX LAN messages over UDP, or another Transport, without
+// tracking devices. Applications managing devices should use the controller package, whose
+// sessions handle discovery, state polling, acknowledgements and liveness on top of a Client.
 package client
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
@@ -13,16 +22,46 @@ const (
 	lifxPort = 56700
 
 	recvBufferSize        = 1024
+	sendBufferSize        = 1024
 	defaultSource  uint32 = 0x00000002
 
 	broadcastUpIface = net.FlagUp | net.FlagBroadcast
+	multicastUpIface = net.FlagUp | net.FlagMulticast
 )
 
 // Client is a UDP client that can be used to send and receive LIFX messages on the LAN.
 type Client struct {
-	conn          *net.UDPConn
+	conn          Transport
 	source        uint32
-	broadcastAddr *net.UDPAddr
+	broadcasts    []broadcastTarget
+	onDecodeError func(*net.UDPAddr, error)
+	tap           PacketTap
+	logger        *slog.Logger
+	// recvBufferSize and decodeWorkers configure Receive, see Config.
+	recvBufferSize int
+	decodeWorkers  int
+	// recvBuffers holds the buffers packets are read into when decoded by the workers.
+	recvBuffers sync.Pool
+
+	mu sync.RWMutex
+	// responders maps the IP of devices to the interface they responded on.
+	responders map[string]string
+
+	pendingMu sync.Mutex
+	// pending holds the messages sent with retries, see SendWithRetries.
+	pending map[pendingKey]*pendingRequest
+}
+
+// broadcastTarget is a broadcast address, with the interface and networks it reaches if known.
+type broadcastTarget struct {
+	iface    string
+	networks []*net.IPNet
+	addr     *net.UDPAddr
+}
+
+// reaches reports whether ip belongs to one of the target networks.
+func (b broadcastTarget) reaches(ip net.IP) bool {
+	return slices.ContainsFunc(b.networks, func(n *net.IPNet) bool { return n.Contains(ip) })
 }
 
 // Config contains optional user-configurable fields.
@@ -32,6 +71,39 @@ type Config struct {
 	// Source must be greater than 1 or some devices on older firmware
 	// might either ignore (0) or broadcast the response (1).
 	Source uint32
+	// OnDecodeError, if set, is called with the sender address and the error of each
+	// received packet that could not be decoded, which is otherwise ignored.
+	// Errors decoding the payload of a packet are a *protocol.DecodeError holding its type.
+	OnDecodeError func(addr *net.UDPAddr, err error)
+	// PacketTap, if set, receives every message sent and received, see NewJSONLTap.
+	PacketTap PacketTap
+	// Logger receives the client logs. By default, logs are discarded.
+	Logger *slog.Logger
+	// Interfaces restricts broadcasts to the named network interfaces.
+	// By default, broadcasts are sent on all the interfaces that are up and support broadcast.
+	Interfaces []string
+	// BroadcastAddrs, if set, are used as broadcast addresses instead of the interfaces ones,
+	// e.g. to reach networks whose broadcasts are relayed.
+	BroadcastAddrs []*net.UDPAddr
+	// BindAddr is the local address and port the client listens on, e.g. "192.168.1.10:0"
+	// to bind the interface with that address. By default, the client listens on all the
+	// IPv4 addresses on a random port.
+	// Binding an IPv6 address, e.g. "[::]:0", creates an IPv6 socket, which broadcasts to the
+	// link-local all-nodes multicast group of each interface for devices that support IPv6.
+	BindAddr string
+	// Transport, if set, is used to send and receive messages instead of a UDP socket, which
+	// makes BindAddr and Interfaces irrelevant. Broadcasts are sent to BroadcastAddrs,
+	// or to 255.255.255.255:56700 by default. The Client closes the Transport when closed.
+	Transport Transport
+	// ReceiveBufferSize is the size of the buffers datagrams are read into, 1024 bytes by default.
+	// Larger datagrams are truncated and fail to decode.
+	ReceiveBufferSize int
+	// DecodeWorkers, if set, is the number of goroutines decoding the received packets and invoking
+	// the Receive handler, so that bursts of packets from many devices are not serialized behind
+	// decoding. The packets of each device are handled in order by the same worker, but the handler
+	// is invoked concurrently for different devices, so it must be safe for concurrent use.
+	// By default, packets are decoded and handled one at a time as they are read.
+	DecodeWorkers int
 }
 
 // HandlerFunc processes a received message and address.
@@ -39,34 +111,87 @@ type HandlerFunc func(*protocol.Message, *net.UDPAddr)
 
 // NewClient returns an instance of Client with an initialised UDP connection.
 func NewClient(cfg *Config) (*Client, error) {
-	addr := &net.UDPAddr{Port: 0, IP: net.IPv4zero}
-	conn, err := net.ListenUDP("udp", addr)
-	if err != nil {
-		return nil, err
-	}
-	bAddr, err := resolveBroadcastUDPAddress(lifxPort)
-	if err != nil {
-		return nil, err
+	if cfg == nil {
+		cfg = &Config{}
 	}
 
 	source := defaultSource
-	if cfg != nil {
-		if cfg.Source != 0 {
-			if cfg.Source < defaultSource {
-				return nil, fmt.Errorf("source must be greater than 1")
-			}
-			source = cfg.Source
+	if cfg.Source != 0 {
+		if cfg.Source < defaultSource {
+			return nil, fmt.Errorf("source must be greater than 1")
+		}
+		source = cfg.Source
+	}
+	if cfg.ReceiveBufferSize < 0 || cfg.DecodeWorkers < 0 {
+		return nil, fmt.Errorf("invalid receive buffer size %d or decode workers %d", cfg.ReceiveBufferSize, cfg.DecodeWorkers)
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+
+	network, addr := "udp", &net.UDPAddr{Port: 0, IP: net.IPv4zero}
+	if cfg.BindAddr != "" {
+		var err error
+		if addr, err = net.ResolveUDPAddr("udp", cfg.BindAddr); err != nil {
+			return nil, fmt.Errorf("invalid bind address %q: %w", cfg.BindAddr, err)
+		}
+		if isIPv6(addr.IP) {
+			network = "udp6"
+		} else {
+			network = "udp4"
+		}
+	}
+
+	var broadcasts []broadcastTarget
+	if len(cfg.BroadcastAddrs) > 0 {
+		for _, addr := range cfg.BroadcastAddrs {
+			broadcasts = append(broadcasts, broadcastTarget{addr: addr})
+		}
+	} else if cfg.Transport != nil {
+		broadcasts = []broadcastTarget{{addr: defaultTransportBroadcastAddr}}
+	} else {
+		var err error
+		if broadcasts, err = resolveBroadcastTargets(lifxPort, cfg.Interfaces, addr.IP); err != nil {
+			return nil, err
+		}
+	}
+
+	conn := cfg.Transport
+	if conn == nil {
+		udpConn, err := net.ListenUDP(network, addr)
+		if err != nil {
+			return nil, err
 		}
+		conn = udpConn
 	}
 
 	return &Client{
-		conn:          conn,
-		source:        source,
-		broadcastAddr: bAddr,
+		conn:           conn,
+		source:         source,
+		broadcasts:     broadcasts,
+		onDecodeError:  cfg.OnDecodeError,
+		tap:            cfg.PacketTap,
+		logger:         logger,
+		recvBufferSize: cfg.ReceiveBufferSize,
+		decodeWorkers:  cfg.DecodeWorkers,
+		responders:     make(map[string]string),
+		pending:        make(map[pendingKey]*pendingRequest),
 	}, nil
 }
 
-// Close closes the Client underlying UDP connection.
+// LocalAddr returns the local address the client is bound to, or nil if its Transport
+// does not have a local UDP address.
+func (c *Client) LocalAddr() *net.UDPAddr {
+	conn, ok := c.conn.(interface{ LocalAddr() net.Addr })
+	if !ok {
+		return nil
+	}
+	addr, _ := conn.LocalAddr().(*net.UDPAddr)
+	return addr
+}
+
+// Close closes the Client underlying connection.
 func (c *Client) Close() error {
 	return c.conn.Close()
 }
@@ -75,52 +200,103 @@ func (c *Client) Close() error {
 func (c *Client) Send(dst *net.UDPAddr, msg *protocol.Message) error {
 	msg.SetSource(c.source)
 
-	data, err := msg.MarshalBinary()
+	buf := sendBuffers.Get().(*[]byte)
+	defer sendBuffers.Put(buf)
+	data, err := msg.AppendBinary((*buf)[:0])
 	if err != nil {
 		return err
 	}
+	*buf = data[:0]
+
+	if c.tap != nil {
+		c.tap(DirectionSent, dst, msg)
+	}
 
-	_, err = c.conn.WriteToUDP(data, dst)
+	_, err = c.conn.WriteTo(data, dst)
 	return err
 }
 
-// SendBroadcast sends a LIFX protocol message to the broadcast address.
+// sendBuffers holds the buffers messages are encoded into by Send, so that sending frames at a
+// high rate does not allocate.
+var sendBuffers = sync.Pool{
+	New: func() any {
+		buf := make([]byte, 0, sendBufferSize)
+		return &buf
+	},
+}
+
+// SendBroadcast sends a LIFX protocol message to the broadcast address of each interface.
+// It returns the errors of the sends that failed, if any.
 func (c *Client) SendBroadcast(msg *protocol.Message) error {
 	msg.SetTarget(protocol.TargetBroadcast)
-	return c.Send(c.broadcastAddr, msg)
+	var errs []error
+	for _, b := range c.broadcasts {
+		if err := c.Send(b.addr, msg); err != nil {
+			errs = append(errs, fmt.Errorf("broadcast to %s: %w", b.addr, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Interface returns the name of the network interface whose subnet the device at addr
+// responded from, or an empty string if unknown, e.g. to diagnose multi-homed hosts.
+// It is informational only: the client sends unicasts on a single socket and the host routing
+// table, not this interface, selects the interface they leave from.
+func (c *Client) Interface(addr *net.UDPAddr) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.responders[addr.IP.String()]
+}
+
+// trackResponder records the interface a device responded on.
+func (c *Client) trackResponder(addr *net.UDPAddr) {
+	for _, b := range c.broadcasts {
+		if !b.reaches(addr.IP) {
+			continue
+		}
+		ip := addr.IP.String()
+		c.mu.RLock()
+		known := c.responders[ip] == b.iface
+		c.mu.RUnlock()
+		if !known {
+			c.mu.Lock()
+			c.responders[ip] = b.iface
+			c.mu.Unlock()
+		}
+		return
+	}
 }
 
 // Receive listens for incoming UDP packets and decodes them into LIFX protocol messages.
 // It reads from the underlying connection until the specified timeout expires or a single
 // message is received (if recvOne is true). For each successfully decoded message,
 // the provided handler function is invoked with the message and sender's address.
-// Malformed messages are silently ignored.
+// Malformed messages are ignored, after being reported to Config.OnDecodeError if set.
+// Packets are decoded concurrently if Config.DecodeWorkers is set, unless recvOne is true.
 func (c *Client) Receive(timeout time.Duration, recvOne bool, handler HandlerFunc) error {
 	if timeout > 0 {
 		c.conn.SetReadDeadline(time.Now().Add(timeout))
 		// Reset deadline after reading
 		defer c.conn.SetReadDeadline(time.Time{})
 	}
+	if c.decodeWorkers > 0 && !recvOne {
+		return c.receiveConcurrent(handler)
+	}
 
-	buf := make([]byte, recvBufferSize)
+	buf := make([]byte, c.receiveBufferSize())
 
 	for {
-		n, addr, err := c.conn.ReadFromUDP(buf)
+		n, addr, err := c.read(buf)
 		if err != nil {
 			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
 				break
 			}
 			return err
 		}
-
-		var msg protocol.Message
-		if err := msg.UnmarshalBinary(buf[:n]); err != nil {
-			// skip malformed
+		if addr == nil {
 			continue
 		}
-
-		handler(&msg, addr)
-		if recvOne {
+		if c.handle(buf[:n], addr, handler) && recvOne {
 			break
 		}
 	}
@@ -128,22 +304,99 @@ func (c *Client) Receive(timeout time.Duration, recvOne bool, handler HandlerFun
 	return nil
 }
 
-// SetConnDeadline sets the connection deadline.
+// read reads a packet into buf, returning a nil address for packets that are skipped.
+func (c *Client) read(buf []byte) (int, *net.UDPAddr, error) {
+	n, from, err := c.conn.ReadFrom(buf)
+	if err != nil {
+		return 0, nil, err
+	}
+	addr, err := udpAddr(from)
+	if err != nil {
+		if c.logger != nil {
+			c.logger.Debug("Skipping packet", "address", from, "error", err)
+		}
+		return 0, nil, nil
+	}
+	return n, addr, nil
+}
+
+// handle decodes a packet received from addr and passes the message to the waiting sender or to
+// handler, reporting whether handler was invoked.
+func (c *Client) handle(data []byte, addr *net.UDPAddr, handler HandlerFunc) bool {
+	var msg protocol.Message
+	if err := msg.UnmarshalBinary(data); err != nil {
+		// skip malformed
+		if c.logger != nil {
+			c.logger.Debug("Skipping malformed packet", "address", addr, "error", err)
+		}
+		if c.onDecodeError != nil {
+			c.onDecodeError(addr, err)
+		}
+		return false
+	}
+
+	if c.tap != nil {
+		c.tap(DirectionReceived, addr, &msg)
+	}
+	c.trackResponder(addr)
+	if c.deliverPending(addr, &msg) {
+		return false
+	}
+	handler(&msg, addr)
+	return true
+}
+
+// ReceiveCtx listens for incoming UDP packets until ctx is cancelled, invoking handler
+// for each successfully decoded message. It returns the context error once cancelled,
+// or any error returned by the underlying connection.
+func (c *Client) ReceiveCtx(ctx context.Context, handler HandlerFunc) error {
+	stop := context.AfterFunc(ctx, func() {
+		// Unblock any pending read.
+		c.conn.SetReadDeadline(time.Now())
+	})
+	defer func() {
+		if !stop() {
+			// Reset the deadline set on cancellation.
+			c.conn.SetReadDeadline(time.Time{})
+		}
+	}()
+
+	if err := c.Receive(0, false, handler); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// SetConnDeadline sets the connection deadline, or only its read deadline if the Transport
+// does not support write deadlines.
 func (c *Client) SetConnDeadline(t time.Time) error {
-	return c.conn.SetDeadline(t)
+	if conn, ok := c.conn.(interface{ SetDeadline(time.Time) error }); ok {
+		return conn.SetDeadline(t)
+	}
+	return c.conn.SetReadDeadline(t)
 }
 
-// resolveBroadcastUDPAddress computes and returns the subnet-specific UDP
-// broadcast address for the first suitable network interface.
-// It uses the interface's IPv4 address and netmask to calculate the address.
-func resolveBroadcastUDPAddress(port int) (*net.UDPAddr, error) {
+// resolveBroadcastTargets computes the broadcast addresses of the network interfaces that
+// are up and support broadcast, optionally restricted to the given names.
+// When bindIP is an IPv6 address, the link-local all-nodes multicast address of each interface
+// is used instead. When bindIP is a specific IPv4 address, only its networks are used.
+func resolveBroadcastTargets(port int, names []string, bindIP net.IP) ([]broadcastTarget, error) {
 	ifaces, err := net.Interfaces()
 	if err != nil {
 		return nil, fmt.Errorf("could not list interfaces: %w", err)
 	}
 
+	ipv6 := isIPv6(bindIP)
+	var targets []broadcastTarget
 	for _, iface := range ifaces {
-		if iface.Flags&broadcastUpIface != broadcastUpIface {
+		if ipv6 {
+			if iface.Flags&multicastUpIface != multicastUpIface {
+				continue
+			}
+		} else if iface.Flags&broadcastUpIface != broadcastUpIface {
+			continue
+		}
+		if len(names) > 0 && !slices.Contains(names, iface.Name) {
 			continue
 		}
 
@@ -152,26 +405,75 @@ func resolveBroadcastUDPAddress(port int) (*net.UDPAddr, error) {
 			// skip bad interface
 			continue
 		}
-
-		for _, addr := range addrs {
-			ipnet, ok := addr.(*net.IPNet)
-			if !ok || ipnet.IP.To4() == nil {
-				continue
+		if ipv6 {
+			targets = append(targets, interfaceMulticastTargets(iface.Name, addrs, port)...)
+			continue
+		}
+		for _, t := range interfaceBroadcastTargets(iface.Name, addrs, port) {
+			if bindIP == nil || bindIP.IsUnspecified() || t.reaches(bindIP) {
+				targets = append(targets, t)
 			}
+		}
+	}
 
-			ip := ipnet.IP.To4()
-			mask := ipnet.Mask
-			broadcast := make(net.IP, 4)
-			for i := range 4 {
-				broadcast[i] = ip[i] | ^mask[i]
-			}
+	if len(targets) == 0 {
+		if len(names) > 0 {
+			return nil, fmt.Errorf("no suitable broadcast interface found in %v", names)
+		}
+		return nil, fmt.Errorf("no suitable broadcast interface found")
+	}
+	return targets, nil
+}
 
-			return &net.UDPAddr{
-				IP:   broadcast,
-				Port: port,
-			}, nil
+// interfaceBroadcastTargets computes the broadcast address of each IPv4 network of an interface,
+// using its address and netmask.
+func interfaceBroadcastTargets(name string, addrs []net.Addr, port int) []broadcastTarget {
+	var targets []broadcastTarget
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || ipnet.IP.To4() == nil {
+			continue
+		}
+
+		ip := ipnet.IP.To4()
+		mask := ipnet.Mask
+		if len(mask) == net.IPv6len {
+			mask = mask[12:]
 		}
+		broadcast := make(net.IP, 4)
+		for i := range 4 {
+			broadcast[i] = ip[i] | ^mask[i]
+		}
+
+		targets = append(targets, broadcastTarget{
+			iface:    name,
+			networks: []*net.IPNet{{IP: ip.Mask(mask), Mask: mask}},
+			addr:     &net.UDPAddr{IP: broadcast, Port: port},
+		})
 	}
+	return targets
+}
+
+// interfaceMulticastTargets returns the link-local all-nodes multicast address of an interface
+// with IPv6 networks, which replaces broadcast in IPv6.
+func interfaceMulticastTargets(name string, addrs []net.Addr, port int) []broadcastTarget {
+	var networks []*net.IPNet
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok && isIPv6(ipnet.IP) {
+			networks = append(networks, ipnet)
+		}
+	}
+	if len(networks) == 0 {
+		return nil
+	}
+	return []broadcastTarget{{
+		iface:    name,
+		networks: networks,
+		addr:     &net.UDPAddr{IP: net.IPv6linklocalallnodes, Port: port, Zone: name},
+	}}
+}
 
-	return nil, fmt.Errorf("no suitable broadcast interface found")
+// isIPv6 reports whether ip is an IPv6 address which is not an IPv4-mapped address.
+func isIPv6(ip net.IP) bool {
+	return ip != nil && ip.To4() == nil
 }
@@ -1,6 +1,9 @@
 package client
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"net"
 	"testing"
 	"time"
@@ -51,9 +54,7 @@ func TestClient_SendBroadcast(t *testing.T) {
 	})
 	defer conn.Close()
 
-	client, err := NewClient(nil)
-	// Manually set broadcast address to mock server
-	client.broadcastAddr = saddr
+	client, err := NewClient(&Config{BroadcastAddrs: []*net.UDPAddr{saddr}})
 	require.NoError(t, err)
 	defer client.Close()
 
@@ -105,7 +106,7 @@ func TestClient_Receive(t *testing.T) {
 	require.NoError(t, err)
 
 	// Write to the client's own listening address
-	_, err = c.conn.WriteToUDP(data, c.conn.LocalAddr().(*net.UDPAddr))
+	_, err = conn.WriteToUDP(data, c.LocalAddr())
 	require.NoError(t, err)
 
 	select {
@@ -115,3 +116,240 @@ func TestClient_Receive(t *testing.T) {
 		t.Fatal("Did not receive message")
 	}
 }
+
+func TestClient_ReceiveCtx(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}
+	conn, err := net.ListenUDP("udp", addr)
+	require.NoError(t, err)
+	c := &Client{conn: conn}
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	recvCh := make(chan *protocol.Message, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.ReceiveCtx(ctx, func(msg *protocol.Message, addr *net.UDPAddr) {
+			recvCh <- msg
+		})
+	}()
+
+	data, err := protocol.NewMessage(&packets.DeviceGetService{}).MarshalBinary()
+	require.NoError(t, err)
+	_, err = conn.WriteToUDP(data, c.LocalAddr())
+	require.NoError(t, err)
+
+	select {
+	case <-recvCh:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Did not receive message")
+	}
+
+	cancel()
+	select {
+	case err := <-errCh:
+		assert.ErrorIs(t, err, context.Canceled)
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("ReceiveCtx did not return after cancel")
+	}
+}
+
+func TestClient_ReceiveReportsDecodeErrors(t *testing.T) {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0}
+	conn, err := net.ListenUDP("udp", addr)
+	require.NoError(t, err)
+	errCh := make(chan error, 1)
+	var logs bytes.Buffer
+	c := &Client{
+		conn:          conn,
+		onDecodeError: func(_ *net.UDPAddr, err error) { errCh <- err },
+		logger:        slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	}
+	defer c.Close()
+
+	recvCh := make(chan *protocol.Message, 1)
+	go c.Receive(time.Second, true, func(msg *protocol.Message, addr *net.UDPAddr) {
+		recvCh <- msg
+	})
+
+	// A malformed packet is reported and skipped.
+	_, err = conn.WriteToUDP([]byte{1, 2, 3}, c.LocalAddr())
+	require.NoError(t, err)
+	data, err := protocol.NewMessage(&packets.DeviceGetService{}).MarshalBinary()
+	require.NoError(t, err)
+	_, err = conn.WriteToUDP(data, c.LocalAddr())
+	require.NoError(t, err)
+
+	select {
+	case err := <-errCh:
+		assert.Error(t, err)
+		assert.Contains(t, logs.String(), "Skipping malformed packet")
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Decode error not reported")
+	}
+	select {
+	case <-recvCh:
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Did not receive message")
+	}
+}
+
+func TestClient_SendBroadcastAllInterfaces(t *testing.T) {
+	recvCh := make(chan struct{}, 2)
+	conn0, saddr0 := testutil.NewMockUDPServer(t, func(*protocol.Message, *net.UDPAddr) { recvCh <- struct{}{} })
+	defer conn0.Close()
+	conn1, saddr1 := testutil.NewMockUDPServer(t, func(*protocol.Message, *net.UDPAddr) { recvCh <- struct{}{} })
+	defer conn1.Close()
+
+	client, err := NewClient(&Config{BroadcastAddrs: []*net.UDPAddr{saddr0, saddr1}})
+	require.NoError(t, err)
+	defer client.Close()
+
+	require.NoError(t, client.SendBroadcast(protocol.NewMessage(&packets.DeviceGetService{})))
+	for range 2 {
+		select {
+		case <-recvCh:
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("Broadcast not received on all addresses")
+		}
+	}
+}
+
+func TestClient_Interface(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	require.NoError(t, err)
+	c := &Client{
+		conn:       conn,
+		responders: make(map[string]string),
+		broadcasts: interfaceBroadcastTargets("lo", []net.Addr{
+			&net.IPNet{IP: net.IPv4(127, 0, 0, 1), Mask: net.CIDRMask(8, 32)},
+		}, lifxPort),
+	}
+	defer c.Close()
+
+	self := conn.LocalAddr().(*net.UDPAddr)
+	assert.Empty(t, c.Interface(self))
+
+	data, err := protocol.NewMessage(&packets.DeviceStateService{}).MarshalBinary()
+	require.NoError(t, err)
+	_, err = conn.WriteToUDP(data, self)
+	require.NoError(t, err)
+	require.NoError(t, c.Receive(time.Second, true, func(*protocol.Message, *net.UDPAddr) {}))
+
+	assert.Equal(t, "lo", c.Interface(self))
+	assert.Empty(t, c.Interface(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}))
+}
+
+func TestInterfaceBroadcastTargets(t *testing.T) {
+	tests := map[string]struct {
+		addrs []net.Addr
+		want  []broadcastTarget
+	}{
+		"IPv4 networks": {
+			addrs: []net.Addr{
+				&net.IPNet{IP: net.IPv4(192, 168, 1, 20), Mask: net.CIDRMask(24, 32)},
+				&net.IPNet{IP: net.IPv4(10, 0, 3, 4).To4(), Mask: net.CIDRMask(16, 32)},
+			},
+			want: []broadcastTarget{
+				{
+					iface:    "eth0",
+					networks: []*net.IPNet{{IP: net.IP{192, 168, 1, 0}, Mask: net.CIDRMask(24, 32)}},
+					addr:     &net.UDPAddr{IP: net.IP{192, 168, 1, 255}, Port: lifxPort},
+				},
+				{
+					iface:    "eth0",
+					networks: []*net.IPNet{{IP: net.IP{10, 0, 0, 0}, Mask: net.CIDRMask(16, 32)}},
+					addr:     &net.UDPAddr{IP: net.IP{10, 0, 255, 255}, Port: lifxPort},
+				},
+			},
+		},
+		"IPv6 mask": {
+			addrs: []net.Addr{&net.IPNet{IP: net.IPv4(192, 168, 1, 20), Mask: net.CIDRMask(120, 128)}},
+			want: []broadcastTarget{{
+				iface:    "eth0",
+				networks: []*net.IPNet{{IP: net.IP{192, 168, 1, 0}, Mask: net.CIDRMask(24, 32)}},
+				addr:     &net.UDPAddr{IP: net.IP{192, 168, 1, 255}, Port: lifxPort},
+			}},
+		},
+		"Skips IPv6 networks": {
+			addrs: []net.Addr{&net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, interfaceBroadcastTargets("eth0", tt.addrs, lifxPort))
+		})
+	}
+}
+
+func TestInterfaceMulticastTargets(t *testing.T) {
+	ll := &net.IPNet{IP: net.ParseIP("fe80::1"), Mask: net.CIDRMask(64, 128)}
+	ula := &net.IPNet{IP: net.ParseIP("fd00::1"), Mask: net.CIDRMask(64, 128)}
+	addrs := []net.Addr{&net.IPNet{IP: net.IPv4(192, 168, 1, 20), Mask: net.CIDRMask(24, 32)}, ll, ula}
+
+	assert.Equal(t, []broadcastTarget{{
+		iface:    "eth0",
+		networks: []*net.IPNet{ll, ula},
+		addr:     &net.UDPAddr{IP: net.IPv6linklocalallnodes, Port: lifxPort, Zone: "eth0"},
+	}}, interfaceMulticastTargets("eth0", addrs, lifxPort))
+	assert.Empty(t, interfaceMulticastTargets("eth0", addrs[:1], lifxPort))
+}
+
+func TestNewClient_BindAddr(t *testing.T) {
+	t.Run("Binds IPv4 address", func(t *testing.T) {
+		c, err := NewClient(&Config{BindAddr: "127.0.0.1:0", BroadcastAddrs: []*net.UDPAddr{{IP: net.IPv4bcast, Port: lifxPort}}})
+		require.NoError(t, err)
+		defer c.Close()
+		assert.True(t, c.LocalAddr().IP.Equal(net.IPv4(127, 0, 0, 1)))
+		assert.NotZero(t, c.LocalAddr().Port)
+	})
+
+	t.Run("Binds IPv6 address", func(t *testing.T) {
+		c, err := NewClient(&Config{BindAddr: "[::1]:0", BroadcastAddrs: []*net.UDPAddr{{IP: net.IPv6loopback, Port: lifxPort}}})
+		if err != nil {
+			t.Skipf("IPv6 not available: %v", err)
+		}
+		defer c.Close()
+		assert.True(t, c.LocalAddr().IP.Equal(net.IPv6loopback))
+
+		// Exchange a message over IPv6.
+		msg := protocol.NewMessage(&packets.DeviceGetService{})
+		require.NoError(t, c.Send(c.LocalAddr(), msg))
+		require.NoError(t, c.Receive(time.Second, true, func(m *protocol.Message, addr *net.UDPAddr) {
+			assert.Equal(t, msg.Type(), m.Type())
+			assert.True(t, addr.IP.Equal(net.IPv6loopback))
+		}))
+	})
+
+	t.Run("Invalid address", func(t *testing.T) {
+		_, err := NewClient(&Config{BindAddr: "nope"})
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkClient_Send(b *testing.B) {
+	sink, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(b, err)
+	defer sink.Close()
+	go func() {
+		buf := make([]byte, recvBufferSize)
+		for {
+			if _, _, err := sink.ReadFrom(buf); err != nil {
+				return
+			}
+		}
+	}()
+
+	client, err := NewClient(nil)
+	require.NoError(b, err)
+	defer client.Close()
+
+	dst := sink.LocalAddr().(*net.UDPAddr)
+	msg := protocol.NewMessage(&packets.TileSet64{})
+	b.ReportAllocs()
+	for b.Loop() {
+		if err := client.Send(dst, msg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
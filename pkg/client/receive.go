@@ -0,0 +1,80 @@
+package client
+
+import (
+	"hash/maphash"
+	"net"
+	"sync"
+)
+
+// decodeQueueSize is the number of packets read ahead of each decode worker.
+const decodeQueueSize = 64
+
+// packet is a datagram read into a pooled buffer, waiting to be decoded.
+type packet struct {
+	buf  *[]byte
+	n    int
+	addr *net.UDPAddr
+}
+
+// receiveBufferSize returns the size of the buffers datagrams are read into.
+func (c *Client) receiveBufferSize() int {
+	if c.recvBufferSize > 0 {
+		return c.recvBufferSize
+	}
+	return recvBufferSize
+}
+
+// receiveConcurrent reads packets into pooled buffers and decodes them on the decode workers
+// until the read deadline expires. Packets are sharded by sender address, so that the messages
+// of each device are handled in order.
+func (c *Client) receiveConcurrent(handler HandlerFunc) error {
+	var (
+		wg     sync.WaitGroup
+		seed   = maphash.MakeSeed()
+		queues = make([]chan packet, c.decodeWorkers)
+	)
+	for i := range queues {
+		queues[i] = make(chan packet, decodeQueueSize)
+		wg.Add(1)
+		go func(queue <-chan packet) {
+			defer wg.Done()
+			for p := range queue {
+				c.handle((*p.buf)[:p.n], p.addr, handler)
+				c.recvBuffers.Put(p.buf)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		wg.Wait()
+	}()
+
+	for {
+		buf := c.receiveBuffer()
+		n, addr, err := c.read(*buf)
+		if err != nil {
+			c.recvBuffers.Put(buf)
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				return nil
+			}
+			return err
+		}
+		if addr == nil {
+			c.recvBuffers.Put(buf)
+			continue
+		}
+		shard := maphash.Bytes(seed, addr.IP) % uint64(len(queues))
+		queues[shard] <- packet{buf: buf, n: n, addr: addr}
+	}
+}
+
+// receiveBuffer returns a buffer from the pool, or a new one if the pool is empty.
+func (c *Client) receiveBuffer() *[]byte {
+	if buf, ok := c.recvBuffers.Get().(*[]byte); ok {
+		return buf
+	}
+	buf := make([]byte, c.receiveBufferSize())
+	return &buf
+}
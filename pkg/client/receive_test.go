@@ -0,0 +1,97 @@
+package client
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_ReceiveDecodeWorkers(t *testing.T) {
+	testCases := map[string]struct {
+		bufferSize  int
+		wantDecoded bool
+	}{
+		"default buffer": {
+			wantDecoded: true,
+		},
+		"buffer larger than the datagrams": {
+			bufferSize:  4096,
+			wantDecoded: true,
+		},
+		"buffer smaller than the datagrams": {
+			bufferSize: 128,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			require.NoError(t, err)
+			var (
+				mu           sync.Mutex
+				sequences    []uint8
+				decodeErrors int
+			)
+			c := &Client{
+				conn:           conn,
+				decodeWorkers:  4,
+				recvBufferSize: tc.bufferSize,
+				onDecodeError: func(*net.UDPAddr, error) {
+					mu.Lock()
+					decodeErrors++
+					mu.Unlock()
+				},
+			}
+			defer c.Close()
+
+			const count = 50
+			sender, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+			require.NoError(t, err)
+			defer sender.Close()
+			for i := range count {
+				msg := protocol.NewMessage(&packets.TileState64{})
+				msg.SetSequence(uint8(i))
+				data, err := msg.MarshalBinary()
+				require.NoError(t, err)
+				_, err = sender.WriteToUDP(data, c.LocalAddr())
+				require.NoError(t, err)
+			}
+
+			err = c.Receive(100*time.Millisecond, false, func(msg *protocol.Message, _ *net.UDPAddr) {
+				mu.Lock()
+				sequences = append(sequences, msg.Sequence())
+				mu.Unlock()
+			})
+			require.NoError(t, err)
+
+			if !tc.wantDecoded {
+				assert.Empty(t, sequences)
+				assert.Equal(t, count, decodeErrors)
+				return
+			}
+			// The messages of a single sender are handled in order.
+			require.Len(t, sequences, count)
+			for i, seq := range sequences {
+				assert.Equal(t, uint8(i), seq)
+			}
+			assert.Zero(t, decodeErrors)
+		})
+	}
+}
+
+func TestNewClient_ReceiveConfig(t *testing.T) {
+	_, err := NewClient(&Config{DecodeWorkers: -1})
+	assert.Error(t, err)
+
+	c, err := NewClient(&Config{DecodeWorkers: 2, ReceiveBufferSize: 2048})
+	require.NoError(t, err)
+	defer c.Close()
+	assert.Equal(t, 2, c.decodeWorkers)
+	assert.Equal(t, 2048, c.receiveBufferSize())
+}
@@ -0,0 +1,192 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+const (
+	defaultRetries        = 3
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = time.Second
+)
+
+var (
+	// ErrNoResponse is returned when a message sent with retries is never acknowledged.
+	ErrNoResponse = errors.New("no response")
+	// ErrSequenceInUse is returned when a message is sent with retries while another one
+	// with the same sequence is waiting for a response from the same device.
+	ErrSequenceInUse = errors.New("sequence in use")
+)
+
+// SendOption configures SendWithRetries.
+type SendOption func(*sendOptions)
+
+type sendOptions struct {
+	retries        int
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+}
+
+// WithRetries sets the number of times a message is resent when no response is received.
+// It defaults to 3.
+func WithRetries(n int) SendOption {
+	return func(o *sendOptions) {
+		o.retries = max(n, 0)
+	}
+}
+
+// WithBackoff sets the time waited for a response after the first send, which doubles after each
+// retry up to maxBackoff. It defaults to 100ms, up to 1s.
+func WithBackoff(initial, maxBackoff time.Duration) SendOption {
+	return func(o *sendOptions) {
+		if initial > 0 {
+			o.initialBackoff = initial
+		}
+		o.maxBackoff = maxBackoff
+	}
+}
+
+// pendingKey identifies the responses to a message by device address and sequence.
+type pendingKey struct {
+	ip  string
+	seq uint8
+}
+
+// pendingRequest is a message sent with retries.
+type pendingRequest struct {
+	// reply receives the first response, and is nil once it has been received.
+	reply chan *protocol.Message
+	// seen holds the responses received, to drop the duplicates caused by retries.
+	seen map[string]bool
+	// completed is set once the sender stopped waiting, after which responses are tracked until expiresAt.
+	completed bool
+	expiresAt time.Time
+}
+
+// SendWithRetries sends a message to dst with ack_required set and resends it with exponential
+// backoff until an acknowledgement or response with the same sequence is received, returning it.
+// It returns ErrNoResponse once retries are exhausted, or the context error if ctx is done first.
+//
+// Responses are observed by Receive, so a receive loop must be running. The first response is
+// returned to the caller instead of being passed to the Receive handler, while further responses,
+// e.g. the state following an acknowledgement, are passed to the handler. Duplicate responses
+// caused by retries are dropped. Every attempt shares the sequence of msg and the request stays
+// registered until SendWithRetries returns, so a late response to an earlier attempt satisfies
+// the call. Messages sent concurrently to the same device must use different sequences.
+func (c *Client) SendWithRetries(ctx context.Context, dst *net.UDPAddr, msg *protocol.Message, opts ...SendOption) (*protocol.Message, error) {
+	o := sendOptions{retries: defaultRetries, initialBackoff: defaultInitialBackoff, maxBackoff: defaultMaxBackoff}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.maxBackoff = max(o.maxBackoff, o.initialBackoff)
+
+	key := pendingKey{ip: dst.IP.String(), seq: msg.Sequence()}
+	reply := make(chan *protocol.Message, 1)
+	if err := c.addPending(key, reply); err != nil {
+		return nil, err
+	}
+	defer c.completePending(key, o.maxBackoff)
+
+	msg.SetAckRequired(true)
+	backoff := o.initialBackoff
+	for attempt := 0; ; attempt++ {
+		if err := c.Send(dst, msg); err != nil {
+			return nil, err
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case r := <-reply:
+			timer.Stop()
+			return r, nil
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		// A response to an earlier attempt may have arrived as the backoff expired.
+		select {
+		case r := <-reply:
+			return r, nil
+		default:
+		}
+		if attempt == o.retries {
+			return nil, fmt.Errorf("%w from %s after %d attempts", ErrNoResponse, dst, attempt+1)
+		}
+		c.logger.Debug("Retrying message", "address", dst, "sequence", msg.Sequence(), "attempt", attempt+1)
+		backoff = min(backoff*2, o.maxBackoff)
+	}
+}
+
+// addPending registers a request waiting for a response.
+func (c *Client) addPending(key pendingKey, reply chan *protocol.Message) error {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	now := time.Now()
+	for k, p := range c.pending {
+		if p.completed && now.After(p.expiresAt) {
+			delete(c.pending, k)
+		}
+	}
+	if p, ok := c.pending[key]; ok && !p.completed {
+		return fmt.Errorf("%w: %d to %s", ErrSequenceInUse, key.seq, key.ip)
+	}
+	c.pending[key] = &pendingRequest{reply: reply, seen: make(map[string]bool)}
+	return nil
+}
+
+// completePending marks a request as completed, dropping late duplicates for the given window.
+func (c *Client) completePending(key pendingKey, window time.Duration) {
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+	if p, ok := c.pending[key]; ok {
+		p.completed = true
+		p.expiresAt = time.Now().Add(window)
+	}
+}
+
+// deliverPending passes a received message to the request waiting for it, if any.
+// It reports whether the message was consumed, either by the request or as a duplicate.
+func (c *Client) deliverPending(addr *net.UDPAddr, msg *protocol.Message) bool {
+	if msg.Source() != c.source {
+		return false
+	}
+
+	c.pendingMu.Lock()
+	defer c.pendingMu.Unlock()
+
+	key := pendingKey{ip: addr.IP.String(), seq: msg.Sequence()}
+	p, ok := c.pending[key]
+	if !ok {
+		return false
+	}
+	if p.completed && time.Now().After(p.expiresAt) {
+		delete(c.pending, key)
+		return false
+	}
+
+	data, err := msg.Payload.MarshalBinary()
+	if err != nil {
+		return false
+	}
+	response := fmt.Sprintf("%d:%x", msg.Type(), data)
+	if p.seen[response] {
+		return true
+	}
+	p.seen[response] = true
+
+	if p.reply != nil {
+		p.reply <- msg
+		p.reply = nil
+		return true
+	}
+	return false
+}
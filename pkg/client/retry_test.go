@@ -0,0 +1,165 @@
+package client
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newAckServer returns a server acknowledging messages after dropping the first drop ones.
+// Each acknowledgement is sent twice followed by a state response, as duplicated by the network.
+func newAckServer(t *testing.T, drop int32) (*net.UDPAddr, *atomic.Int32) {
+	return newReplyServer(t, func(n int32) (time.Duration, bool) { return 0, n > drop })
+}
+
+// newReplyServer returns a server acknowledging the nth message received, counting from 1,
+// after the delay returned by reply, unless it reports false.
+func newReplyServer(t *testing.T, reply func(n int32) (time.Duration, bool)) (*net.UDPAddr, *atomic.Int32) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	var received atomic.Int32
+	go func() {
+		buf := make([]byte, recvBufferSize)
+		for {
+			n, src, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			var msg protocol.Message
+			if err := msg.UnmarshalBinary(buf[:n]); err != nil {
+				continue
+			}
+			delay, ok := reply(received.Add(1))
+			if !ok {
+				continue
+			}
+			time.AfterFunc(delay, func() {
+				for _, p := range []packets.Payload{&packets.DeviceAcknowledgement{}, &packets.DeviceAcknowledgement{}, &packets.LightStatePower{Level: 65535}} {
+					reply := protocol.NewMessage(p)
+					reply.SetSource(msg.Source())
+					reply.SetSequence(msg.Sequence())
+					data, _ := reply.MarshalBinary()
+					conn.WriteToUDP(data, src)
+				}
+			})
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr), &received
+}
+
+func newLoopbackClient(t *testing.T) (*Client, chan *protocol.Message) {
+	c, err := NewClient(&Config{BindAddr: "127.0.0.1:0", BroadcastAddrs: []*net.UDPAddr{{IP: net.IPv4bcast, Port: lifxPort}}})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan *protocol.Message, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.ReceiveCtx(ctx, func(msg *protocol.Message, _ *net.UDPAddr) { handled <- msg })
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+		c.Close()
+	})
+	return c, handled
+}
+
+func TestClient_SendWithRetries(t *testing.T) {
+	tests := map[string]struct {
+		drop      int32
+		opts      []SendOption
+		wantSends int32
+		wantErr   error
+	}{
+		"Acknowledged": {
+			wantSends: 1,
+		},
+		"Retried": {
+			drop:      2,
+			opts:      []SendOption{WithBackoff(10*time.Millisecond, 20*time.Millisecond)},
+			wantSends: 3,
+		},
+		"Retries exhausted": {
+			drop:      10,
+			opts:      []SendOption{WithRetries(2), WithBackoff(10*time.Millisecond, 20*time.Millisecond)},
+			wantSends: 3,
+			wantErr:   ErrNoResponse,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			addr, received := newAckServer(t, tt.drop)
+			c, handled := newLoopbackClient(t)
+
+			msg := protocol.NewMessage(&packets.LightSetPower{Level: 65535})
+			msg.SetSequence(9)
+			reply, err := c.SendWithRetries(context.Background(), addr, msg, tt.opts...)
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, tt.wantSends, received.Load())
+			if tt.wantErr != nil {
+				return
+			}
+
+			require.NotNil(t, reply)
+			assert.IsType(t, &packets.DeviceAcknowledgement{}, reply.Payload)
+			// The duplicate acknowledgement is dropped and the state response is handled.
+			select {
+			case msg := <-handled:
+				assert.IsType(t, &packets.LightStatePower{}, msg.Payload)
+			case <-time.After(time.Second):
+				t.Fatal("State response not handled")
+			}
+			select {
+			case msg := <-handled:
+				t.Fatalf("Unexpected message handled: %v", msg)
+			case <-time.After(20 * time.Millisecond):
+			}
+		})
+	}
+}
+
+func TestClient_SendWithRetriesLateResponse(t *testing.T) {
+	// Only the first attempt is acknowledged, after the following ones are sent.
+	addr, received := newReplyServer(t, func(n int32) (time.Duration, bool) { return 50 * time.Millisecond, n == 1 })
+	c, _ := newLoopbackClient(t)
+
+	msg := protocol.NewMessage(&packets.LightSetPower{Level: 65535})
+	msg.SetSequence(9)
+	reply, err := c.SendWithRetries(context.Background(), addr, msg, WithRetries(5), WithBackoff(10*time.Millisecond, 20*time.Millisecond))
+	require.NoError(t, err)
+	assert.IsType(t, &packets.DeviceAcknowledgement{}, reply.Payload)
+	assert.Greater(t, received.Load(), int32(1))
+}
+
+func TestClient_SendWithRetriesContext(t *testing.T) {
+	addr, _ := newAckServer(t, 10)
+	c, _ := newLoopbackClient(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	_, err := c.SendWithRetries(ctx, addr, protocol.NewMessage(&packets.LightGet{}), WithRetries(100))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestClient_SendWithRetriesSequenceInUse(t *testing.T) {
+	c := &Client{pending: make(map[pendingKey]*pendingRequest)}
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+	require.NoError(t, c.addPending(pendingKey{ip: addr.IP.String(), seq: 1}, make(chan *protocol.Message, 1)))
+
+	msg := protocol.NewMessage(&packets.LightGet{})
+	msg.SetSequence(1)
+	_, err := c.SendWithRetries(context.Background(), addr, msg)
+	assert.ErrorIs(t, err, ErrSequenceInUse)
+}
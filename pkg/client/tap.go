@@ -0,0 +1,94 @@
+package client
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// Direction is the direction of a message passed to a PacketTap.
+type Direction int
+
+const (
+	// DirectionSent marks a message sent by the client.
+	DirectionSent Direction = iota
+	// DirectionReceived marks a message received by the client.
+	DirectionReceived
+)
+
+// String implements the Stringer interface.
+func (d Direction) String() string {
+	switch d {
+	case DirectionSent:
+		return "sent"
+	case DirectionReceived:
+		return "received"
+	default:
+		return fmt.Sprintf("Direction(%d)", d)
+	}
+}
+
+// PacketTap receives every message sent or received by a Client, for debugging.
+// Sent messages are passed after being marshaled and received messages after being unmarshaled,
+// together with the destination or sender address.
+// It is called synchronously from the sending and receiving goroutines, so it must be safe
+// for concurrent use, must not block and must not modify the message.
+type PacketTap func(dir Direction, addr *net.UDPAddr, msg *protocol.Message)
+
+// traceRecord is a line of the trace written by a JSONL tap.
+type traceRecord struct {
+	Time        time.Time       `json:"time"`
+	Direction   string          `json:"direction"`
+	Address     string          `json:"address"`
+	Source      uint32          `json:"source"`
+	Target      string          `json:"target"`
+	Sequence    uint8           `json:"sequence"`
+	Type        uint16          `json:"type"`
+	PayloadName string          `json:"payload_name"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Data        string          `json:"data,omitempty"`
+}
+
+// NewJSONLTap returns a PacketTap writing a JSON line to w for each message, with its header fields,
+// decoded payload and hex-encoded wire format. Messages that cannot be encoded are skipped.
+// Writes are serialized, so w need not be safe for concurrent use.
+//
+//	f, _ := os.Create("trace.jsonl")
+//	c, err := client.NewClient(&client.Config{PacketTap: client.NewJSONLTap(f)})
+func NewJSONLTap(w io.Writer) PacketTap {
+	var mu sync.Mutex
+	enc := json.NewEncoder(w)
+
+	return func(dir Direction, addr *net.UDPAddr, msg *protocol.Message) {
+		r := traceRecord{
+			Time:      time.Now(),
+			Direction: dir.String(),
+			Address:   addr.String(),
+			Source:    msg.Source(),
+			Sequence:  msg.Sequence(),
+			Type:      msg.Type(),
+		}
+		target := msg.Target()
+		r.Target = hex.EncodeToString(target[:6])
+		if msg.Payload != nil {
+			r.PayloadName = strings.TrimPrefix(fmt.Sprintf("%T", msg.Payload), "*packets.")
+			if payload, err := json.Marshal(msg.Payload); err == nil {
+				r.Payload = payload
+			}
+			if data, err := msg.MarshalBinary(); err == nil {
+				r.Data = hex.EncodeToString(data)
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		enc.Encode(r)
+	}
+}
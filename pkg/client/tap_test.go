@@ -0,0 +1,112 @@
+package client
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_PacketTap(t *testing.T) {
+	type tapped struct {
+		dir  Direction
+		addr *net.UDPAddr
+		msg  *protocol.Message
+	}
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 0})
+	require.NoError(t, err)
+
+	var (
+		mu   sync.Mutex
+		seen []tapped
+	)
+	c := &Client{conn: conn, source: defaultSource, tap: func(dir Direction, addr *net.UDPAddr, msg *protocol.Message) {
+		mu.Lock()
+		seen = append(seen, tapped{dir, addr, msg})
+		mu.Unlock()
+	}}
+	defer c.Close()
+
+	self := conn.LocalAddr().(*net.UDPAddr)
+	msg := protocol.NewMessage(&packets.LightGet{})
+	msg.SetSequence(7)
+	require.NoError(t, c.Send(self, msg))
+	require.NoError(t, c.Receive(time.Second, true, func(*protocol.Message, *net.UDPAddr) {}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, seen, 2)
+	assert.Equal(t, DirectionSent, seen[0].dir)
+	assert.Equal(t, self, seen[0].addr)
+	assert.Same(t, msg, seen[0].msg)
+	assert.Equal(t, DirectionReceived, seen[1].dir)
+	assert.Equal(t, self.String(), seen[1].addr.String())
+	assert.Equal(t, uint8(7), seen[1].msg.Sequence())
+	assert.Equal(t, defaultSource, seen[1].msg.Source())
+}
+
+func TestNewJSONLTap(t *testing.T) {
+	var buf bytes.Buffer
+	tap := NewJSONLTap(&buf)
+	addr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+
+	msg := protocol.NewMessage(&packets.LightSetPower{Level: 65535, Duration: 1000})
+	msg.SetTarget([8]byte{0xd0, 0x73, 0xd5, 0, 0, 1})
+	msg.SetSource(defaultSource)
+	msg.SetSequence(3)
+	data, err := msg.MarshalBinary()
+	require.NoError(t, err)
+
+	tap(DirectionSent, addr, msg)
+	tap(DirectionReceived, addr, protocol.NewMessage(&packets.DeviceAcknowledgement{}))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var got map[string]any
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &got))
+	assert.NotEmpty(t, got["time"])
+	delete(got, "time")
+	assert.Equal(t, map[string]any{
+		"direction":    "sent",
+		"address":      "192.168.0.10:56700",
+		"source":       float64(defaultSource),
+		"target":       "d073d5000001",
+		"sequence":     float64(3),
+		"type":         float64(117),
+		"payload_name": "LightSetPower",
+		"payload":      map[string]any{"Level": float64(65535), "Duration": float64(1000)},
+		"data":         hex.EncodeToString(data),
+	}, got)
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &got))
+	assert.Equal(t, "received", got["direction"])
+	assert.Equal(t, "DeviceAcknowledgement", got["payload_name"])
+}
+
+func TestDirection_String(t *testing.T) {
+	tests := map[string]struct {
+		dir  Direction
+		want string
+	}{
+		"Sent":     {DirectionSent, "sent"},
+		"Received": {DirectionReceived, "received"},
+		"Unknown":  {Direction(5), "Direction(5)"},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.dir.String())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+)
+
+// defaultTransportBroadcastAddr is the broadcast address used by a Client with a custom Transport
+// and no Config.BroadcastAddrs, as the interfaces of the host may not be the ones it reaches.
+var defaultTransportBroadcastAddr = &net.UDPAddr{IP: net.IPv4bcast, Port: lifxPort}
+
+// Transport is the packet connection a Client sends and receives LIFX messages over.
+// A *net.UDPConn, or any net.PacketConn, implements it; other implementations can run the
+// Client over in-memory pipes in tests, over a remote UDP relay or through a proxy process.
+//
+// ReadFrom must return a timeout net.Error, e.g. os.ErrDeadlineExceeded, once the read deadline
+// set with SetReadDeadline expires, including for reads that are already blocked.
+// Addresses are expected to be *net.UDPAddr values, or to have the ip:port string form.
+type Transport interface {
+	ReadFrom(p []byte) (n int, addr net.Addr, err error)
+	WriteTo(p []byte, addr net.Addr) (n int, err error)
+	SetReadDeadline(t time.Time) error
+	Close() error
+}
+
+// udpAddr returns addr as a *net.UDPAddr, parsing its string form if it is of another type.
+func udpAddr(addr net.Addr) (*net.UDPAddr, error) {
+	if a, ok := addr.(*net.UDPAddr); ok {
+		return a, nil
+	}
+	ap, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return nil, fmt.Errorf("invalid transport address %q: %w", addr, err)
+	}
+	return net.UDPAddrFromAddrPort(ap), nil
+}
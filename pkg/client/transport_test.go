@@ -0,0 +1,166 @@
+package client
+
+import (
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient_Transport(t *testing.T) {
+	deviceAddr := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+
+	t.Run("Sends over the transport", func(t *testing.T) {
+		tr := newPipeTransport()
+		c, err := NewClient(&Config{Transport: tr})
+		require.NoError(t, err)
+		defer c.Close()
+
+		require.NoError(t, c.Send(deviceAddr, protocol.NewMessage(&packets.LightGet{})))
+		require.NoError(t, c.SendBroadcast(protocol.NewMessage(&packets.DeviceGetService{})))
+
+		p := <-tr.out
+		assert.Equal(t, deviceAddr, p.addr)
+		assert.Equal(t, uint16(packets.PayloadTypeLightGet), decodePacket(t, p.data).Type())
+		p = <-tr.out
+		assert.Equal(t, defaultTransportBroadcastAddr, p.addr)
+		assert.Equal(t, uint16(packets.PayloadTypeDeviceGetService), decodePacket(t, p.data).Type())
+	})
+
+	t.Run("Receives over the transport", func(t *testing.T) {
+		tr := newPipeTransport()
+		c, err := NewClient(&Config{Transport: tr})
+		require.NoError(t, err)
+		defer c.Close()
+
+		data, err := protocol.NewMessage(&packets.DeviceStateLabel{}).MarshalBinary()
+		require.NoError(t, err)
+		// Addresses which are not UDP addresses are parsed from their string form.
+		tr.in <- pipePacket{data: data, addr: pipeAddr("192.168.0.10:56700")}
+
+		var from *net.UDPAddr
+		require.NoError(t, c.Receive(time.Second, true, func(msg *protocol.Message, addr *net.UDPAddr) {
+			from = addr
+		}))
+		assert.Equal(t, deviceAddr.String(), from.String())
+	})
+
+	t.Run("Stops receiving at the deadline", func(t *testing.T) {
+		tr := newPipeTransport()
+		c, err := NewClient(&Config{Transport: tr})
+		require.NoError(t, err)
+		defer c.Close()
+
+		done := make(chan error, 1)
+		go func() { done <- c.Receive(0, false, func(*protocol.Message, *net.UDPAddr) {}) }()
+		time.Sleep(time.Millisecond)
+		require.NoError(t, c.SetConnDeadline(time.Now()))
+
+		select {
+		case err := <-done:
+			assert.NoError(t, err)
+		case <-time.After(100 * time.Millisecond):
+			t.Fatal("Receive did not return at the deadline")
+		}
+		assert.Nil(t, c.LocalAddr())
+	})
+
+	t.Run("Closes the transport", func(t *testing.T) {
+		tr := newPipeTransport()
+		c, err := NewClient(&Config{Transport: tr})
+		require.NoError(t, err)
+
+		require.NoError(t, c.Close())
+		assert.ErrorIs(t, c.Receive(0, false, func(*protocol.Message, *net.UDPAddr) {}), net.ErrClosed)
+	})
+}
+
+func decodePacket(t *testing.T, data []byte) *protocol.Message {
+	var msg protocol.Message
+	require.NoError(t, msg.UnmarshalBinary(data))
+	return &msg
+}
+
+// pipeAddr is a net.Addr of a network other than UDP.
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+type pipePacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// pipeTransport is an in-memory Transport, which reads the packets sent to in and
+// writes the packets to out.
+type pipeTransport struct {
+	in, out chan pipePacket
+
+	mu       sync.Mutex
+	deadline time.Time
+	// wake is closed when the deadline changes, to unblock pending reads.
+	wake      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeTransport() *pipeTransport {
+	return &pipeTransport{
+		in:     make(chan pipePacket, 10),
+		out:    make(chan pipePacket, 10),
+		wake:   make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+func (p *pipeTransport) ReadFrom(b []byte) (int, net.Addr, error) {
+	for {
+		p.mu.Lock()
+		deadline, wake := p.deadline, p.wake
+		p.mu.Unlock()
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timeout = time.After(time.Until(deadline))
+		}
+		select {
+		case pkt := <-p.in:
+			return copy(b, pkt.data), pkt.addr, nil
+		case <-timeout:
+			return 0, nil, os.ErrDeadlineExceeded
+		case <-p.closed:
+			return 0, nil, net.ErrClosed
+		case <-wake:
+		}
+	}
+}
+
+func (p *pipeTransport) WriteTo(b []byte, addr net.Addr) (int, error) {
+	select {
+	case <-p.closed:
+		return 0, net.ErrClosed
+	case p.out <- pipePacket{data: append([]byte(nil), b...), addr: addr}:
+		return len(b), nil
+	}
+}
+
+func (p *pipeTransport) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deadline = t
+	close(p.wake)
+	p.wake = make(chan struct{})
+	return nil
+}
+
+func (p *pipeTransport) Close() error {
+	p.closeOnce.Do(func() { close(p.closed) })
+	return nil
+}
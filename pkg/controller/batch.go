@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// defaultBatchTimeout is the time SendBatch waits for the device to acknowledge a batch.
+const defaultBatchTimeout = time.Second
+
+// SendBatch sends the messages to the device in order, requiring an acknowledgement for the last one,
+// and returns once the device acknowledges it, or ErrSendTimeout after a second.
+// It is meant for updates spanning several messages, such as a messages.Plan setting the zones of a
+// strip or matrix, which the device applies when it handles the last one: once SendBatch returns the
+// whole update is known to be applied. Messages are validated as with Send before any is sent.
+func (c *Controller) SendBatch(serial device.Serial, msgs ...*protocol.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultBatchTimeout)
+	defer cancel()
+	return c.SendBatchCtx(ctx, serial, 0, msgs...)
+}
+
+// SendBatchCtx is like SendBatch but waits for the acknowledgement until ctx is done, and waits for
+// the given pacing between messages, e.g. for devices that drop messages sent in quick succession.
+// It returns ErrSendTimeout if the ctx deadline is exceeded and the context error if it is cancelled.
+func (c *Controller) SendBatchCtx(ctx context.Context, serial device.Serial, pacing time.Duration, msgs ...*protocol.Message) error {
+	if len(msgs) == 0 {
+		return nil
+	}
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+	if err := s.validate(msgs...); err != nil {
+		return err
+	}
+
+	err := s.sendBatch(ctx, pacing, msgs...)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: device %s did not acknowledge %d messages: %w", ErrSendTimeout, serial, len(msgs), err)
+	}
+	return err
+}
+
+// sendBatch sends the messages with the given pacing between them, requiring an acknowledgement for
+// the last one, and waits for it until ctx is done.
+func (s *deviceSession) sendBatch(ctx context.Context, pacing time.Duration, msgs ...*protocol.Message) error {
+	s.sending.Add(1)
+	defer s.sending.Add(-1)
+
+	last := len(msgs) - 1
+	for i, msg := range msgs[:last] {
+		if err := s.sendOne(ctx, msg, nil); err != nil {
+			return fmt.Errorf("message %d of %d: %w", i+1, len(msgs), err)
+		}
+		if err := sleepCtx(ctx, pacing); err != nil {
+			return err
+		}
+	}
+
+	wait, err := s.sendAcked(ctx, msgs[last])
+	if err != nil {
+		return fmt.Errorf("message %d of %d: %w", len(msgs), len(msgs), err)
+	}
+	return wait()
+}
+
+// sendAcked sends msg requiring an acknowledgement and returns the function waiting for it until
+// ctx is done, which must be called to release the acknowledgement waiter.
+func (s *deviceSession) sendAcked(ctx context.Context, msg *protocol.Message) (wait func() error, err error) {
+	acked := make(chan struct{})
+	var seq uint8
+	msg.SetAckRequired(true)
+	if err := s.sendOne(ctx, msg, func(n uint8) {
+		seq = n
+		s.ackWaiters.Store(seq, acked)
+	}); err != nil {
+		s.ackWaiters.CompareAndDelete(seq, acked)
+		return nil, err
+	}
+
+	return func() error {
+		defer s.ackWaiters.CompareAndDelete(seq, acked)
+		select {
+		case <-acked:
+			return nil
+		case <-s.done:
+			return s.closedError()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}, nil
+}
+
+// acknowledged notifies the batch waiting for the acknowledgement of the message with the given sequence.
+func (s *deviceSession) acknowledged(seq uint8) {
+	if acked, ok := s.ackWaiters.LoadAndDelete(seq); ok {
+		close(acked.(chan struct{}))
+	}
+}
+
+// sleepCtx waits for d, returning early with the context error if ctx is done.
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
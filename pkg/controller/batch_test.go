@@ -0,0 +1,137 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ackingSender records the messages sent and, if ack is set, acknowledges those requiring it.
+type ackingSender struct {
+	mu      sync.Mutex
+	sent    []*protocol.Message
+	sentAt  []time.Time
+	ack     bool
+	inbound chan *protocol.Message
+}
+
+func (a *ackingSender) Send(dst *net.UDPAddr, msg *protocol.Message) error {
+	a.mu.Lock()
+	a.sent = append(a.sent, msg)
+	a.sentAt = append(a.sentAt, time.Now())
+	a.mu.Unlock()
+	if a.ack && msg.AckRequired() {
+		ack := protocol.NewMessage(&packets.DeviceAcknowledgement{})
+		ack.SetSequence(msg.Sequence())
+		go func() { a.inbound <- ack }()
+	}
+	return nil
+}
+
+func (a *ackingSender) SendBroadcast(msg *protocol.Message) error { return nil }
+
+func TestSendBatch(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	batch := func() []*protocol.Message {
+		return []*protocol.Message{
+			protocol.NewMessage(&packets.LightSetColor{}),
+			protocol.NewMessage(&packets.LightSetColor{}),
+			protocol.NewMessage(&packets.LightSetColor{}),
+		}
+	}
+
+	tests := map[string]struct {
+		ack     bool
+		timeout time.Duration
+		pacing  time.Duration
+		wantErr error
+	}{
+		"returns once the last message is acknowledged": {
+			ack:     true,
+			timeout: time.Second,
+		},
+		"waits the pacing between messages": {
+			ack:     true,
+			timeout: time.Second,
+			pacing:  10 * time.Millisecond,
+		},
+		"times out without an acknowledgement": {
+			timeout: 20 * time.Millisecond,
+			wantErr: ErrSendTimeout,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			inbound := make(chan *protocol.Message)
+			sender := &ackingSender{ack: tt.ack, inbound: inbound}
+			s := &deviceSession{
+				sender:  sender,
+				logger:  discardLogger(),
+				device:  device.NewDevice(addr0, serial0),
+				inbound: inbound,
+				done:    make(chan struct{}),
+				cfg:     &Config{},
+			}
+			go s.recvloop()
+			defer s.close()
+
+			ctrl := &Controller{cfg: &Config{}, sessions: map[device.Serial]*deviceSession{serial0: s}}
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+
+			msgs := batch()
+			err := ctrl.SendBatchCtx(ctx, serial0, tt.pacing, msgs...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			sender.mu.Lock()
+			defer sender.mu.Unlock()
+			require.Len(t, sender.sent, len(msgs))
+			for i, msg := range sender.sent {
+				assert.Equal(t, i == len(msgs)-1, msg.AckRequired())
+				assert.Equal(t, serial0, device.Serial(msg.Target()))
+				if i > 0 {
+					assert.GreaterOrEqual(t, sender.sentAt[i].Sub(sender.sentAt[i-1]), tt.pacing)
+				}
+			}
+		})
+	}
+
+	t.Run("Errors without a session", func(t *testing.T) {
+		ctrl := &Controller{cfg: &Config{}, sessions: map[device.Serial]*deviceSession{}}
+		err := ctrl.SendBatch(serial0, batch()...)
+		assert.Error(t, err)
+	})
+
+	t.Run("Returns the context error when cancelled", func(t *testing.T) {
+		s := &deviceSession{
+			sender: &ackingSender{},
+			logger: discardLogger(),
+			device: device.NewDevice(addr0, serial0),
+			done:   make(chan struct{}),
+			cfg:    &Config{},
+		}
+		ctrl := &Controller{cfg: &Config{}, sessions: map[device.Serial]*deviceSession{serial0: s}}
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		err := ctrl.SendBatchCtx(ctx, serial0, 0, batch()...)
+		assert.True(t, errors.Is(err, context.Canceled))
+		assert.NotErrorIs(t, err, ErrSendTimeout)
+	})
+}
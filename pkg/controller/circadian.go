@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/circadian"
+)
+
+// StartCircadian starts adjusting the white color temperature and brightness of the devices
+// selected by cfg following the daylight at its position, see circadian.Daemon.
+// Devices are adjusted every cfg.Interval until the returned stop function is called
+// or the Controller is closed.
+func (c *Controller) StartCircadian(cfg circadian.Config) (stop func(), err error) {
+	d, err := circadian.New(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	done := make(chan struct{})
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		defer close(done)
+		_ = d.Run(ctx)
+	}()
+
+	return func() {
+		cancel()
+		<-done
+	}, nil
+}
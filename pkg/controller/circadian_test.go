@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/circadian"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartCircadian(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	newController := func(t *testing.T) (*Controller, *mockClient) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+
+		// Do not use newDeviceSession to prevent running state update goroutine.
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn = true
+		ctrl.sessions[serial0] = &deviceSession{
+			sender: mockClient,
+			logger: discardLogger(),
+			device: d,
+			done:   make(chan struct{}),
+		}
+		return ctrl, mockClient
+	}
+
+	t.Run("Adjusts devices until stopped", func(t *testing.T) {
+		ctrl, mockClient := newController(t)
+		defer ctrl.Close()
+
+		stop, err := ctrl.StartCircadian(circadian.Config{Latitude: 51.5, Longitude: -0.1, Interval: time.Hour})
+		require.NoError(t, err)
+
+		select {
+		case msg := <-mockClient.sends:
+			assert.IsType(t, &packets.LightSetWaveformOptional{}, msg.Payload)
+		case <-time.After(time.Second):
+			t.Fatal("device not adjusted")
+		}
+		stop()
+	})
+
+	t.Run("Stops when the Controller is closed", func(t *testing.T) {
+		ctrl, _ := newController(t)
+
+		_, err := ctrl.StartCircadian(circadian.Config{Interval: time.Millisecond})
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			ctrl.Close()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(sessionsTerminationTimeout):
+			t.Fatal("Controller did not close")
+		}
+	})
+
+	t.Run("Rejects invalid configuration", func(t *testing.T) {
+		ctrl, _ := newController(t)
+		defer ctrl.Close()
+
+		_, err := ctrl.StartCircadian(circadian.Config{Latitude: 100})
+		assert.Error(t, err)
+	})
+}
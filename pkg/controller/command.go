@@ -0,0 +1,152 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// SetLabel sets the device label.
+// The device state is updated as soon as the message is sent, without waiting for the next refresh,
+// and it returns once the device acknowledges it, or ErrSendTimeout after a second.
+func (c *Controller) SetLabel(serial device.Serial, label string) error {
+	msg, err := messages.SetLabel(label)
+	if err != nil {
+		return err
+	}
+	return c.sendCommand(serial, msg, func(*device.Device) packets.Payload {
+		return &packets.DeviceStateLabel{Label: msg.Payload.(*packets.DeviceSetLabel).Label}
+	})
+}
+
+// SetPower turns the device on or off over the duration d.
+// The device state is updated as soon as the message is sent, without waiting for the next refresh,
+// and it returns once the device acknowledges it, or ErrSendTimeout after a second.
+func (c *Controller) SetPower(serial device.Serial, on bool, d time.Duration) error {
+	var level uint16
+	if on {
+		level = math.MaxUint16
+	}
+	return c.sendCommand(serial, powerMessage(on, d), func(*device.Device) packets.Payload {
+		return &packets.DeviceStatePower{Level: level}
+	})
+}
+
+// powerMessage returns the message turning a device on or off over the duration d.
+func powerMessage(on bool, d time.Duration) *protocol.Message {
+	var durations []time.Duration
+	if d > 0 {
+		durations = append(durations, d)
+	}
+	if on {
+		return messages.SetPowerOn(durations...)
+	}
+	return messages.SetPowerOff(durations...)
+}
+
+// SetColor sets the device color over the duration d.
+// The device state is updated as soon as the message is sent, without waiting for the next refresh,
+// and it returns once the device acknowledges it, or ErrSendTimeout after a second.
+func (c *Controller) SetColor(serial device.Serial, color device.Color, d time.Duration) error {
+	hsbk := color.ToDeviceColor()
+	msg := protocol.NewMessage(&packets.LightSetColor{Color: hsbk, Duration: uint32(d.Milliseconds())})
+	return c.sendCommand(serial, msg, func(dev *device.Device) packets.Payload {
+		if dev.Type == device.DeviceTypeSwitch {
+			return nil
+		}
+		var power uint16
+		if dev.PoweredOn {
+			power = math.MaxUint16
+		}
+		return &packets.LightState{Color: hsbk, Power: power}
+	})
+}
+
+// SetColorClamped sets the device color over the duration d as SetColor does, after clamping it to
+// what the device can display with device.Device.ClampColor, e.g. kelvin to its temperature range.
+// Saturated colors sent to white only lights return ErrUnsupportedCapability rather than being
+// turned into white. Colors are sent unchanged until the device product is known.
+func (c *Controller) SetColorClamped(serial device.Serial, color device.Color, d time.Duration) error {
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+
+	s.mu.RLock()
+	dev := s.device
+	var err error
+	if dev.Profiled() && dev.Type != device.DeviceTypeSwitch {
+		if !dev.ColorProperties.HasColor && color.Saturation > 0 {
+			err = fmt.Errorf("%w: device %s is white only", ErrUnsupportedCapability, serial)
+		}
+		color = dev.ClampColor(color)
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+	return c.SetColor(serial, color, d)
+}
+
+// sendCommand sends msg to the device requiring an acknowledgement and, once sent, applies the state
+// returned by expected as if it was reported by the device, publishing the resulting events.
+// It then waits for the acknowledgement, returning ErrSendTimeout if the device does not acknowledge
+// the command within a second, in which case the next refresh reverts the expected state.
+// If expected returns nil the command is not supported by the device and ErrNotLight is returned.
+func (c *Controller) sendCommand(serial device.Serial, msg *protocol.Message, expected func(*device.Device) packets.Payload) error {
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+
+	s.mu.RLock()
+	state := expected(s.device)
+	s.mu.RUnlock()
+	if state == nil {
+		return ErrNotLight
+	}
+
+	s.sending.Add(1)
+	defer s.sending.Add(-1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultBatchTimeout)
+	defer cancel()
+	wait, err := s.sendAcked(ctx, msg)
+	if err != nil {
+		return err
+	}
+	s.applyExpectedState(state)
+
+	if err := wait(); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("%w: device %s did not acknowledge the command: %w", ErrSendTimeout, serial, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// applyExpectedState updates the device with the state expected after a command,
+// so that snapshots and subscribers see the change before the device reports it.
+func (s *deviceSession) applyExpectedState(p packets.Payload) {
+	s.mu.Lock()
+	changes, updated, _ := applyState(s.device, p)
+	if updated {
+		s.device.LastUpdatedAt = time.Now()
+		s.device.StateVersion = s.cfg.nextStateVersion()
+	}
+	var events []Event
+	if len(changes) > 0 && s.events != nil {
+		events = s.newEvents(changes...)
+	}
+	s.mu.Unlock()
+
+	s.events.publish(events...)
+}
@@ -0,0 +1,211 @@
+package controller
+
+import (
+	"math"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommands(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		blue    = device.Color{Hue: 240, Saturation: 100, Brightness: 50, Kelvin: 3500}
+	)
+
+	testCases := map[string]struct {
+		command     func(c *Controller) error
+		wantPayload packets.Payload
+		wantEvents  []EventType
+		check       func(t *testing.T, d device.Device)
+	}{
+		"SetLabel": {
+			command:     func(c *Controller) error { return c.SetLabel(serial0, "Desk") },
+			wantPayload: &packets.DeviceSetLabel{Label: [32]byte{'D', 'e', 's', 'k'}},
+			wantEvents:  []EventType{EventLabelChanged},
+			check: func(t *testing.T, d device.Device) {
+				assert.Equal(t, "Desk", d.Label)
+			},
+		},
+		"SetPower on": {
+			command:     func(c *Controller) error { return c.SetPower(serial0, true, 0) },
+			wantPayload: &packets.DeviceSetPower{Level: math.MaxUint16},
+			wantEvents:  []EventType{EventPowerChanged},
+			check: func(t *testing.T, d device.Device) {
+				assert.True(t, d.PoweredOn)
+			},
+		},
+		"SetPower with duration": {
+			command:     func(c *Controller) error { return c.SetPower(serial0, true, time.Second) },
+			wantPayload: &packets.LightSetPower{Level: math.MaxUint16, Duration: 1000},
+			wantEvents:  []EventType{EventPowerChanged},
+			check: func(t *testing.T, d device.Device) {
+				assert.True(t, d.PoweredOn)
+			},
+		},
+		"SetPower off is unchanged": {
+			command:     func(c *Controller) error { return c.SetPower(serial0, false, 0) },
+			wantPayload: &packets.DeviceSetPower{Level: 0},
+			check: func(t *testing.T, d device.Device) {
+				assert.False(t, d.PoweredOn)
+			},
+		},
+		"SetColor": {
+			command:     func(c *Controller) error { return c.SetColor(serial0, blue, 500*time.Millisecond) },
+			wantPayload: &packets.LightSetColor{Color: blue.ToDeviceColor(), Duration: 500},
+			wantEvents:  []EventType{EventColorChanged},
+			check: func(t *testing.T, d device.Device) {
+				assert.Equal(t, device.NewColor(blue.ToDeviceColor()), d.Color)
+				assert.False(t, d.PoweredOn)
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := newMockClient()
+			ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+			require.NoError(t, err)
+			defer ctrl.Close()
+
+			// Do not use newDeviceSession to prevent running state update goroutine.
+			s := &deviceSession{
+				sender: mockClient,
+				logger: discardLogger(),
+				device: device.NewDevice(addr0, serial0),
+				done:   make(chan struct{}),
+				events: ctrl.events,
+			}
+			ctrl.sessions[serial0] = s
+			mockClient.acknowledge = func(msg *protocol.Message) { s.acknowledged(msg.Sequence()) }
+			events, cancel := ctrl.Subscribe(EventFilter{})
+			defer cancel()
+
+			require.NoError(t, tc.command(ctrl))
+
+			msg := <-mockClient.sends
+			assert.Equal(t, tc.wantPayload, msg.Payload)
+			assert.True(t, msg.AckRequired())
+
+			var gotEvents []EventType
+			for len(events) > 0 {
+				gotEvents = append(gotEvents, (<-events).Type)
+			}
+			assert.Equal(t, tc.wantEvents, gotEvents)
+			tc.check(t, ctrl.GetDevices()[0])
+		})
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		d := device.NewDevice(addr0, serial0)
+		d.Type = device.DeviceTypeSwitch
+		s := &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+		ctrl.sessions[serial0] = s
+
+		assert.ErrorIs(t, ctrl.SetColor(serial0, blue, 0), ErrNotLight)
+		assert.ErrorIs(t, ctrl.SetLabel(serial0, ""), messages.ErrInvalidLabel)
+		assert.Error(t, ctrl.SetPower(device.Serial{9}, true, 0))
+		assert.Empty(t, mockClient.sends)
+
+		// Colors are only checked against the product features once known.
+		assert.ErrorIs(t, ctrl.SetColorClamped(serial0, blue, 0), ErrNotLight)
+		assert.ErrorIs(t, ctrl.SetColorClamped(device.Serial{9}, blue, 0), ErrNoSession)
+		assert.Empty(t, mockClient.sends)
+
+		// Commands time out without an acknowledgement.
+		assert.ErrorIs(t, ctrl.SetPower(serial0, true, 0), ErrSendTimeout)
+		assert.IsType(t, &packets.DeviceSetPower{}, (<-mockClient.sends).Payload)
+
+		// Switches can still be turned on.
+		mockClient.acknowledge = func(msg *protocol.Message) { s.acknowledged(msg.Sequence()) }
+		assert.NoError(t, ctrl.SetPower(serial0, true, 0))
+		assert.IsType(t, &packets.DeviceSetPower{}, (<-mockClient.sends).Payload)
+	})
+}
+
+func TestSetColorClamped(t *testing.T) {
+	serial0 := device.Serial{0xd0, 0x73, 0xd5, 0x00, 0x00, 0x01}
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	blue := device.Color{Hue: 240, Saturation: 100, Brightness: 50, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		pid       uint32
+		color     device.Color
+		wantColor device.Color
+		wantErr   error
+	}{
+		"unknown product": {
+			color:     device.Color{Brightness: 50, Kelvin: 20000},
+			wantColor: device.Color{Brightness: 50, Kelvin: 20000},
+		},
+		"color light": {
+			pid:       97,
+			color:     blue,
+			wantColor: blue,
+		},
+		"kelvin clamped": {
+			pid:       97,
+			color:     device.Color{Brightness: 120, Kelvin: 20000},
+			wantColor: device.Color{Brightness: 100, Kelvin: 9000},
+		},
+		"white light": {
+			pid:       88,
+			color:     device.Color{Hue: 240, Brightness: 50, Kelvin: 3500},
+			wantColor: device.Color{Hue: 240, Brightness: 50, Kelvin: 2700},
+		},
+		"color on white light": {
+			pid:     88,
+			color:   blue,
+			wantErr: ErrUnsupportedCapability,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			mockClient := newMockClient()
+			ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+			require.NoError(t, err)
+			defer ctrl.Close()
+
+			d := device.NewDevice(addr0, serial0)
+			if tc.pid != 0 {
+				d.SetProductInfo(tc.pid)
+			}
+			// Do not use newDeviceSession to prevent running state update goroutine.
+			s := &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+			ctrl.sessions[serial0] = s
+			mockClient.acknowledge = func(msg *protocol.Message) { s.acknowledged(msg.Sequence()) }
+
+			err = ctrl.SetColorClamped(serial0, tc.color, 0)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Empty(t, mockClient.sends)
+				return
+			}
+			require.NoError(t, err)
+			msg := <-mockClient.sends
+			assert.Equal(t, &packets.LightSetColor{Color: tc.wantColor.ToDeviceColor()}, msg.Payload)
+		})
+	}
+}
+
+func TestApplyStateAcknowledgement(t *testing.T) {
+	d := device.NewDevice(&net.UDPAddr{}, device.Serial{1})
+	changes, updated, known := applyState(d, &packets.DeviceAcknowledgement{})
+	assert.Empty(t, changes)
+	assert.False(t, updated)
+	assert.True(t, known)
+}
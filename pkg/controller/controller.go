@@ -1,14 +1,22 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
 	"net"
+	"net/netip"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -23,8 +31,14 @@ const (
 	preflightHandshakeWait    = time.Second
 	minLivenessTimeout        = 30 * time.Second
 	livenessTimeoutMultiplier = 5
+	// externalChangeWindowMultiplier defines the default window, in high frequency refresh periods,
+	// within which state changes are attributed to a command sent by the controller.
+	externalChangeWindowMultiplier = 2
 
 	sessionsTerminationTimeout = 2 * time.Second
+
+	// lifxPort is the port LIFX devices listen to.
+	lifxPort = 56700
 )
 
 // Controller manages discovery and message routing for multiple
@@ -34,11 +48,32 @@ type Controller struct {
 	logger   *slog.Logger
 	recvDone chan struct{}
 	cfg      *Config
+	events   *eventBus
+	effects  *matrix.EffectRunner
+	// ctx is cancelled when the Controller is closed, stopping background schedulers.
+	ctx    context.Context
+	cancel context.CancelFunc
 
 	closeOnce sync.Once
 	wg        sync.WaitGroup
 	mu        sync.RWMutex
 	sessions  map[device.Serial]*deviceSession
+	// lost holds the last state of the devices whose sessions were terminated by the liveness
+	// timeout, to resurrect their sessions when they are discovered again.
+	lost map[device.Serial]device.Device
+	// staticAddrs are the addresses of devices provisioned explicitly,
+	// which are probed directly on each discovery.
+	staticAddrs []*net.UDPAddr
+	// sweeping is set while a periodic subnet sweep is in progress.
+	sweeping atomic.Bool
+	// suspended is set between NotifySuspend and NotifyResume.
+	suspended atomic.Bool
+	// shuttingDown is set by Shutdown, stopping discovery while sessions are drained.
+	shuttingDown atomic.Bool
+	// store persists known devices between runs, if set.
+	store DeviceStore
+	// discoveredAt is the time, in unix nanoseconds, discovery packets were last sent.
+	discoveredAt atomic.Int64
 }
 
 type Client interface {
@@ -56,10 +91,40 @@ type Config struct {
 	highFrequencyStateRefreshPeriod time.Duration
 	lowFrequencyStateRefreshPeriod  time.Duration
 	preflightHandshakeTimeout       time.Duration
+	discoverySubnets                []netip.Prefix
+	subnetSweepConcurrency          int
+	subnetSweepRate                 int
+	rateLimit                       float64
+	rateLimitBurst                  int
+	rateLimitMaxWait                time.Duration
+	globalRateLimit                 float64
+	globalRateLimitBurst            int
+	inboundQueueSize                int
+	inboundOverflow                 InboundOverflowPolicy
+	inboundBlockTimeout             time.Duration
+	duplicateWindow                 time.Duration
+	externalChangeWindow            time.Duration
+	metrics                         MetricsRecorder
+	packetTap                       client.PacketTap
+	interfaces                      []string
+	bindAddr                        string
+	decodeWorkers                   int
+	discoveryDisabled               bool
+	statePollingDisabled            bool
+	livenessTimeout                 time.Duration
+	keepOfflineDevices              bool
 
 	// Non configurable
+	subnetSweepPeriod      time.Duration
 	deviceLivenessTimeout  time.Duration
 	preflightHandshakeWait time.Duration
+	stateHandlers          *stateHandlers
+	// globalLimiter limits the rate of messages sent to all devices, if configured.
+	globalLimiter *rateLimiter
+	// stateVersion is bumped on every device state change.
+	stateVersion *atomic.Uint64
+	// lastError is the last error met in the background, reported by Status.
+	lastError *lastError
 }
 
 // setLivenessTimeout sets the inactivity period after which a device is considered
@@ -73,7 +138,12 @@ type Config struct {
 // A minimum threshold is enforced to avoid overly aggressive checks when very low
 // refresh periods are configured (e.g. 1s). No maximum is applied, since the probe
 // intervals themselves define the heartbeat expectation.
+// A timeout set with WithDeviceLivenessTimeout is used as is.
 func (c *Config) setLivenessTimeout() {
+	if c.livenessTimeout > 0 {
+		c.deviceLivenessTimeout = c.livenessTimeout
+		return
+	}
 	t := min(c.highFrequencyStateRefreshPeriod, c.lowFrequencyStateRefreshPeriod) * time.Duration(livenessTimeoutMultiplier)
 	if t > minLivenessTimeout {
 		c.deviceLivenessTimeout = t
@@ -89,34 +159,63 @@ func New(opts ...Option) (*Controller, error) {
 		logger:   discardLogger(),
 		recvDone: make(chan struct{}),
 		sessions: make(map[device.Serial]*deviceSession),
+		lost:     make(map[device.Serial]device.Device),
+		events:   newEventBus(),
 		cfg: &Config{
 			discoveryPeriod:                 defaultDiscoveryPeriod,
 			highFrequencyStateRefreshPeriod: defaultHighFrequencyStateRefreshPeriod,
 			lowFrequencyStateRefreshPeriod:  defaultLowFrequencyStateRefreshPeriod,
 			preflightHandshakeTimeout:       preflightHandshakeTimeout,
 			preflightHandshakeWait:          preflightHandshakeWait,
+			subnetSweepConcurrency:          defaultSubnetSweepConcurrency,
+			subnetSweepRate:                 defaultSubnetSweepRate,
+			subnetSweepPeriod:               defaultSubnetSweepPeriod,
+			rateLimitMaxWait:                defaultRateLimitMaxWait,
+			stateHandlers:                   newStateHandlers(),
+			stateVersion:                    new(atomic.Uint64),
+			lastError:                       new(lastError),
 		},
 	}
+	ctrl.ctx, ctrl.cancel = context.WithCancel(context.Background())
+	ctrl.effects = matrix.NewEffectRunner(ctrl.Send)
 	for _, opt := range opts {
 		if err := opt(ctrl); err != nil {
 			return nil, err
 		}
 	}
-	// Set liveness timeout after any option has been applied.
+	// Set liveness timeout and external change window after any option has been applied.
 	ctrl.cfg.setLivenessTimeout()
+	ctrl.cfg.globalLimiter = newRateLimiter(ctrl.cfg.globalRateLimit, ctrl.cfg.globalRateLimitBurst, ctrl.cfg.rateLimitMaxWait)
+	if ctrl.cfg.externalChangeWindow == 0 {
+		ctrl.cfg.externalChangeWindow = ctrl.cfg.highFrequencyStateRefreshPeriod * externalChangeWindowMultiplier
+	}
 
 	if ctrl.client == nil {
-		c, err := client.NewClient(nil)
+		cfg := &client.Config{
+			Logger:        ctrl.logger.With("component", "client"),
+			PacketTap:     ctrl.cfg.packetTap,
+			Interfaces:    ctrl.cfg.interfaces,
+			BindAddr:      ctrl.cfg.bindAddr,
+			DecodeWorkers: ctrl.cfg.decodeWorkers,
+		}
+		if m := ctrl.cfg.metrics; m != nil {
+			cfg.OnDecodeError = func(*net.UDPAddr, error) { m.DecodeError() }
+		}
+		c, err := client.NewClient(cfg)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create client: %w", err)
 		}
 		ctrl.client = c
 	}
 
+	if ctrl.store != nil {
+		ctrl.restoreDevices()
+	}
+
 	go ctrl.recvloop()
 
 	// Perform an intial discovery and exit early, if needed.
-	if err := ctrl.Discover(); err != nil {
+	if err := ctrl.initialDiscovery(); err != nil {
 		return nil, fmt.Errorf("failed to discover devices: %w", err)
 	}
 	go ctrl.periodicDiscovery()
@@ -129,9 +228,12 @@ func New(opts ...Option) (*Controller, error) {
 func (c *Controller) Close() error {
 	// Close the client connection and wait for the recv loop to finish.
 	c.closeOnce.Do(func() {
+		c.cancel()
+		c.effects.StopAll()
 		c.client.SetConnDeadline(time.Now())
 		<-c.recvDone
 		c.client.Close()
+		c.saveDevices()
 
 		for serial := range c.sessions {
 			c.terminateSession(serial)
@@ -148,6 +250,7 @@ func (c *Controller) Close() error {
 		case <-time.After(sessionsTerminationTimeout):
 			c.logger.Warn("Session termination timeout reached")
 		}
+		c.events.close()
 
 		c.logger.Info("Controller closed")
 	})
@@ -155,23 +258,126 @@ func (c *Controller) Close() error {
 	return nil
 }
 
-// Discover broadcasts a LIFX discover packet.
+// Effects returns the runner of the software effects sent to devices through the Controller.
+// Effects are stopped when the Controller is closed.
+func (c *Controller) Effects() *matrix.EffectRunner {
+	return c.effects
+}
+
+// Discover broadcasts a LIFX discover packet and sends it directly to any static device.
 func (c *Controller) Discover() error {
-	msg := protocol.NewMessage(&packets.DeviceGetService{})
-	return c.client.SendBroadcast(msg)
+	return c.DiscoverCtx(context.Background())
 }
 
-// Send sends the given message to the given UDP address, if a session exists.
-func (c *Controller) Send(serial device.Serial, msg *protocol.Message) error {
+// DiscoverCtx is like Discover but stops sending to static devices when ctx is cancelled,
+// returning the context error.
+func (c *Controller) DiscoverCtx(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.discoveredAt.Store(time.Now().UnixNano())
+	c.metrics().DiscoveryRound()
+	errs := []error{c.sendDiscovery(nil)}
+
 	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if s, ok := c.sessions[serial]; ok {
-		return s.send(msg)
+	addrs := c.staticAddrs
+	c.mu.RUnlock()
+
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return errors.Join(append(errs, err)...)
+		}
+		errs = append(errs, c.sendDiscovery(addr))
 	}
+	return errors.Join(errs...)
+}
+
+// sendDiscovery sends a discovery packet to the given address, or broadcasts it if addr is nil.
+func (c *Controller) sendDiscovery(addr *net.UDPAddr) error {
+	msg := protocol.NewMessage(&packets.DeviceGetService{})
+	send := c.client.SendBroadcast
+	if addr != nil {
+		send = func(msg *protocol.Message) error { return c.client.Send(addr, msg) }
+	}
+	if err := send(msg); err != nil {
+		return err
+	}
+	c.metrics().MessageSent(msg.Payload.PayloadType())
 	return nil
 }
 
+// AddDevice provisions a device by its IP address, optionally followed by a port,
+// for networks where broadcast discovery is blocked.
+// The device is probed directly and its session is created as soon as it replies.
+// The address is probed again on each discovery, so that the device session is
+// recreated if it goes offline.
+func (c *Controller) AddDevice(ip string) error {
+	addr, err := parseDeviceAddr(ip)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.addStaticAddr(addr)
+	c.mu.Unlock()
+
+	return c.sendDiscovery(addr)
+}
+
+// addStaticAddr adds addr to the static devices addresses, if not already present.
+// It must be called with c.mu held.
+func (c *Controller) addStaticAddr(addr *net.UDPAddr) {
+	if slices.ContainsFunc(c.staticAddrs, func(a *net.UDPAddr) bool {
+		return a.IP.Equal(addr.IP) && a.Port == addr.Port
+	}) {
+		return
+	}
+	// Copy on write as Discover reads the slice outside of the lock.
+	c.staticAddrs = append(slices.Clip(c.staticAddrs), addr)
+}
+
+// parseDeviceAddr parses an IP address with an optional port into a UDP address.
+// If no port is given the default LIFX port is used.
+func parseDeviceAddr(s string) (*net.UDPAddr, error) {
+	host, port := s, lifxPort
+	if h, p, err := net.SplitHostPort(s); err == nil {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > math.MaxUint16 {
+			return nil, fmt.Errorf("invalid device port %q", p)
+		}
+		host, port = h, n
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid device IP %q", s)
+	}
+	return &net.UDPAddr{IP: ip, Port: port}, nil
+}
+
+// Send sends the given message to the device with the given serial.
+// It returns an error wrapping ErrNoSession if the device has no session.
+// Once the device product is resolved, messages that require a feature the device does not
+// support are not sent and an error wrapping ErrUnsupportedCapability is returned.
+func (c *Controller) Send(serial device.Serial, msg *protocol.Message) error {
+	return c.SendCtx(context.Background(), serial, msg)
+}
+
+// SendCtx is like Send but returns the context error if ctx is cancelled
+// before the message is sent, e.g. while waiting for the rate limiter.
+func (c *Controller) SendCtx(ctx context.Context, serial device.Serial, msg *protocol.Message) error {
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+	if err := s.validate(msg); err != nil {
+		return err
+	}
+	return s.sendCtx(ctx, msg)
+}
+
 // GetDevices returns the list of devices that have a session.
+// Devices are deep copies, which do not share zones state with the sessions.
 func (c *Controller) GetDevices() []device.Device {
 	c.mu.RLock()
 	devices := make([]device.Device, 0, len(c.sessions))
@@ -184,71 +390,215 @@ func (c *Controller) GetDevices() []device.Device {
 	return devices
 }
 
+// initialDiscovery discovers devices when the Controller starts.
+// If discovery is disabled only the static devices are probed, for their sessions to be created.
+func (c *Controller) initialDiscovery() error {
+	if !c.cfg.discoveryDisabled {
+		return c.Discover()
+	}
+	var errs []error
+	for _, addr := range c.staticAddrs {
+		errs = append(errs, c.sendDiscovery(addr))
+	}
+	return errors.Join(errs...)
+}
+
 // periodicDiscovery periodically looks for new devices on the network.
+// If discovery subnets are configured they are swept at a lower frequency.
+// If discovery is disabled it only saves the known devices to the store, if any.
 func (c *Controller) periodicDiscovery() {
 	ticker := time.NewTicker(c.cfg.discoveryPeriod)
+	defer ticker.Stop()
+	discoverC := ticker.C
+	if c.cfg.discoveryDisabled {
+		ticker.Stop()
+		discoverC = nil
+	}
+
+	var sweepC <-chan time.Time
+	if len(c.cfg.discoverySubnets) > 0 && !c.cfg.discoveryDisabled {
+		go c.sweepSubnets()
+		sweepTicker := time.NewTicker(c.cfg.subnetSweepPeriod)
+		defer sweepTicker.Stop()
+		sweepC = sweepTicker.C
+	}
+
+	var saveC <-chan time.Time
+	if c.store != nil {
+		saveTicker := time.NewTicker(c.cfg.lowFrequencyStateRefreshPeriod)
+		defer saveTicker.Stop()
+		saveC = saveTicker.C
+	}
 
 	for {
 		select {
 		case <-c.recvDone:
 			return
-		case <-ticker.C:
-			_ = c.Discover()
+		case <-discoverC:
+			if !c.suspended.Load() && !c.shuttingDown.Load() {
+				c.cfg.lastError.record(c.Discover())
+			}
 			ticker.Reset(c.cfg.discoveryPeriod)
+		case <-sweepC:
+			if !c.suspended.Load() && !c.shuttingDown.Load() {
+				go c.sweepSubnets()
+			}
+		case <-saveC:
+			c.saveDevices()
 		}
 	}
 }
 
-// addSession adds a new device session.
-func (c *Controller) addSession(addr *net.UDPAddr, serial device.Serial) {
+// sweepSubnets runs a subnet sweep unless one is already in progress,
+// so that paced sweeps of large subnets do not delay broadcast discovery.
+func (c *Controller) sweepSubnets() {
+	if !c.sweeping.CompareAndSwap(false, true) {
+		return
+	}
+	defer c.sweeping.Store(false)
+
+	if err := c.SweepSubnets(); err != nil {
+		c.logger.Debug("Subnet sweep failed", "error", err)
+		c.cfg.lastError.record(err)
+	}
+}
+
+// addSession adds a new device session, applying the given init functions to the device
+// before it is published, e.g. to restore cached state.
+func (c *Controller) addSession(addr *net.UDPAddr, serial device.Serial, init ...func(*device.Device)) {
 	c.wg.Add(1)
-	cb := func(serial device.Serial) { c.terminateSession(serial) }
-	session := newDeviceSession(addr, serial, c.client, c.cfg, c.wg.Done, cb, c.logger)
+	cb := func(serial device.Serial) {
+		if session := c.session(serial); session != nil {
+			c.events.publish(session.newEvent(EventDeviceOffline))
+			c.mu.Lock()
+			c.lost[serial] = session.deviceSnapshot()
+			c.mu.Unlock()
+		}
+		c.terminateSession(serial)
+	}
+	session := newDeviceSession(addr, serial, c.client, c.cfg, c.wg.Done, cb, c.events, c.logger, init...)
+	session.mu.Lock()
+	session.device.StateVersion = c.cfg.nextStateVersion()
+	session.mu.Unlock()
+	if c.suspended.Load() {
+		session.suspend()
+	}
 
 	c.mu.Lock()
 	c.sessions[serial] = session
 	c.mu.Unlock()
+	c.sessionsChanged()
+
+	c.events.publish(session.newEvent(EventDeviceDiscovered))
+}
+
+// resurrect returns the init function restoring the last state of a device whose session was
+// terminated by the liveness timeout, if any, so that the new session skips the preflight messages
+// fulfilled by it and only changes from the previous state emit events.
+// The address and the liveness and health of the device are not restored.
+func (c *Controller) resurrect(serial device.Serial) []func(*device.Device) {
+	c.mu.Lock()
+	last, ok := c.lost[serial]
+	delete(c.lost, serial)
+	c.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	c.logger.Debug("Resurrecting session of lost device", "serial", serial)
+	return []func(*device.Device){func(d *device.Device) {
+		addr := d.Address
+		*d = last
+		d.Address = addr
+		d.LastSeenAt = time.Time{}
+		d.Offline = false
+		d.Health = device.Health{}
+	}}
+}
+
+// session returns the session for the given serial, if any.
+func (c *Controller) session(serial device.Serial) *deviceSession {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sessions[serial]
+}
+
+// sessionList returns the current sessions, so that they can be iterated without holding the lock.
+func (c *Controller) sessionList() []*deviceSession {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	sessions := make([]*deviceSession, 0, len(c.sessions))
+	for _, s := range c.sessions {
+		sessions = append(sessions, s)
+	}
+	return sessions
 }
 
 // terminateSession terminates a device session.
 func (c *Controller) terminateSession(serial device.Serial) {
 	c.mu.Lock()
-	if session, ok := c.sessions[serial]; ok {
+	session, ok := c.sessions[serial]
+	if ok {
 		delete(c.sessions, serial)
 		session.close()
 	}
 	c.mu.Unlock()
+	if ok {
+		c.sessionsChanged()
+	}
 }
 
 // recv listens for incoming messages from devices and dispatches them to the appropriate session.
 func (c *Controller) recvloop() {
 	defer close(c.recvDone)
 
-	if err := c.client.Receive(0, false, func(msg *protocol.Message, addr *net.UDPAddr) {
-		serial := device.Serial(msg.Target())
+	if err := c.client.Receive(0, false, c.handleMessage); err != nil {
+		// If Receive exits due to an error make sure the Controller shuts down gracefully.
+		c.cfg.lastError.record(err)
+		c.Close()
+	}
+}
 
-		c.mu.RLock()
-		session, hasSession := c.sessions[serial]
-		c.mu.RUnlock()
+// handleMessage routes a message received from the given address to the session of the device
+// it comes from, creating a session for devices answering discovery.
+func (c *Controller) handleMessage(msg *protocol.Message, addr *net.UDPAddr) {
+	serial := device.Serial(msg.Target())
+	c.metrics().MessageReceived(msg.Payload.PayloadType())
 
-		if state, ok := msg.Payload.(*packets.DeviceStateService); ok {
-			if !hasSession && state.Service == enums.DeviceServiceDEVICESERVICEUDP {
-				c.addSession(addr, serial)
-			}
-		} else if hasSession {
-			select {
-			case session.inbound <- msg:
-			default:
-				// If the channel is full, we skip the message to avoid blocking.
-				c.logger.Warn(
-					"Channel full, skipping message",
-					"serial", serial,
-					"payload", msg.Payload.PayloadType(),
-				)
-			}
+	c.mu.RLock()
+	session, hasSession := c.sessions[serial]
+	c.mu.RUnlock()
+
+	if state, ok := msg.Payload.(*packets.DeviceStateService); ok {
+		if sent := c.discoveredAt.Load(); sent != 0 {
+			c.metrics().DiscoveryResponse(time.Since(time.Unix(0, sent)))
 		}
-	}); err != nil {
-		// If Receive exits due to an error make sure the Controller shuts down gracefully.
-		c.Close()
+		if !hasSession && state.Service == enums.DeviceServiceDEVICESERVICEUDP && !c.shuttingDown.Load() {
+			c.addSession(addr, serial, c.resurrect(serial)...)
+		}
+	} else if hasSession && !session.duplicate(msg) {
+		session.deliver(msg)
+	}
+}
+
+// InjectMessage handles msg as if it was received from a device at the given address, e.g. for tests
+// and simulators to feed state packets to the Controller without a network.
+// The device is identified by the message target, and a DeviceStateService message creates its
+// session as a discovery response does, e.g.
+//
+//	msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+//	msg.SetTarget(serial)
+//	err := ctrl.InjectMessage(addr, msg)
+//
+// Messages are processed asynchronously by the device session, as received messages are.
+// It returns ErrClosed if the Controller is closed.
+func (c *Controller) InjectMessage(addr *net.UDPAddr, msg *protocol.Message) error {
+	if msg == nil || msg.Payload == nil {
+		return fmt.Errorf("cannot inject message without payload")
+	}
+	if c.ctx.Err() != nil {
+		return ErrClosed
 	}
+	c.handleMessage(msg, addr)
+	return nil
 }
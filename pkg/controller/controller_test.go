@@ -2,6 +2,7 @@ package controller
 
 import (
 	"bytes"
+	"context"
 	"log/slog"
 	"math/rand"
 	"net"
@@ -12,6 +13,8 @@ import (
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -61,13 +64,13 @@ func TestController(t *testing.T) {
 		assert.Greater(t, len(mockClient.broadcasts), 5)
 	})
 
-	t.Run("Skips Send if an addr has no session", func(t *testing.T) {
+	t.Run("Errors on Send if an addr has no session", func(t *testing.T) {
 		mockClient := newMockClient()
 		ctrl, err := New(WithClient(mockClient))
 		require.NoError(t, err)
 
 		err = ctrl.Send(serial0, protocol.NewMessage(&packets.LightGet{}))
-		require.NoError(t, err)
+		assert.ErrorIs(t, err, ErrNoSession)
 
 		ctrl.Close()
 		assert.Equal(t, len(mockClient.sends), 0)
@@ -117,6 +120,23 @@ func TestController(t *testing.T) {
 		assert.Equal(t, msg.Payload.PayloadType(), recvMsg.Payload.PayloadType())
 	})
 
+	t.Run("Does not send unsupported messages", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		d := device.NewDevice(addr0, serial0)
+		d.SetProductInfo(97)
+		ctrl.sessions[serial0] = &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+
+		err = ctrl.Send(serial0, messages.SetHevCycle(true, 0))
+		assert.ErrorIs(t, err, messages.ErrUnsupported)
+		assert.ErrorIs(t, err, ErrUnsupportedCapability)
+		require.NoError(t, ctrl.Send(serial0, protocol.NewMessage(&packets.LightGet{})))
+		assert.Equal(t, 1, len(mockClient.sends))
+	})
+
 	t.Run("Return the current state of devices", func(t *testing.T) {
 		mockClient := newMockClient()
 		ctrl, err := New(WithClient(mockClient))
@@ -168,6 +188,157 @@ func TestController(t *testing.T) {
 		assert.Equal(t, serial0, ctrl.GetDevices()[0].Serial)
 	})
 
+	t.Run("Probes static devices on discovery", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithStaticDevices("192.168.0.20", "192.168.0.21:56701"))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		assert.Equal(t, []*net.UDPAddr{
+			{IP: net.ParseIP("192.168.0.20"), Port: lifxPort},
+			{IP: net.ParseIP("192.168.0.21"), Port: 56701},
+		}, ctrl.staticAddrs)
+		for range 2 {
+			msg := <-mockClient.sends
+			assert.Equal(t, uint16(packets.PayloadTypeDeviceGetService), msg.Type())
+		}
+	})
+
+	t.Run("Fails on invalid static devices", func(t *testing.T) {
+		_, err := New(WithClient(newMockClient()), WithStaticDevices("lifx.local"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Adds a device by IP", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		require.NoError(t, ctrl.AddDevice("192.168.0.10"))
+		require.NoError(t, ctrl.AddDevice("192.168.0.10:56700"))
+		assert.Error(t, ctrl.AddDevice("not-an-ip"))
+		assert.Equal(t, 1, len(ctrl.staticAddrs))
+		assert.Equal(t, 2, len(mockClient.sends))
+
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, 1, len(ctrl.GetDevices()))
+	})
+
+	t.Run("Resurrects the sessions of lost devices", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		defer ctrl.Close()
+		ctrl.cfg.preflightHandshakeTimeout = time.Millisecond
+		ctrl.cfg.preflightHandshakeWait = time.Millisecond
+		ctrl.cfg.deviceLivenessTimeout = 20 * time.Millisecond
+
+		events, cancel := ctrl.Subscribe(EventFilter{Types: []EventType{EventDeviceOffline}})
+		defer cancel()
+		ctrl.addSession(addr0, serial0, func(d *device.Device) { d.Label = "Lamp" })
+		select {
+		case <-events:
+		case <-time.After(time.Second):
+			t.Fatal("device did not go offline")
+		}
+		assert.Eventually(t, func() bool { return !ctrl.HasDevice(serial0) }, time.Second, time.Millisecond)
+
+		// The device comes back with a new address.
+		ctrl.cfg.deviceLivenessTimeout = time.Hour
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr1}
+		assert.Eventually(t, func() bool { return ctrl.HasDevice(serial0) }, time.Second, time.Millisecond)
+
+		d, _ := ctrl.GetDevice(serial0)
+		assert.Equal(t, "Lamp", d.Label)
+		assert.Equal(t, addr1, d.Address)
+		ctrl.mu.RLock()
+		assert.Empty(t, ctrl.lost)
+		ctrl.mu.RUnlock()
+	})
+
+	t.Run("Injects messages", func(t *testing.T) {
+		ctrl, err := New(WithClient(newMockClient()), WithoutDiscovery(), WithoutStatePolling())
+		require.NoError(t, err)
+
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		require.NoError(t, ctrl.InjectMessage(addr0, msg))
+		require.True(t, ctrl.HasDevice(serial0))
+
+		msg = protocol.NewMessage(&packets.DeviceStateLabel{Label: [32]byte{'L', 'a', 'm', 'p'}})
+		msg.SetTarget(serial0)
+		require.NoError(t, ctrl.InjectMessage(addr0, msg))
+		assert.Eventually(t, func() bool {
+			d, _ := ctrl.GetDevice(serial0)
+			return d.Label == "Lamp"
+		}, time.Second, time.Millisecond)
+
+		assert.Error(t, ctrl.InjectMessage(addr0, &protocol.Message{}))
+		ctrl.Close()
+		assert.ErrorIs(t, ctrl.InjectMessage(addr0, msg), ErrClosed)
+	})
+
+	t.Run("Drops duplicate messages", func(t *testing.T) {
+		recorder := newFakeRecorder()
+		ctrl, err := New(WithClient(newMockClient()), WithoutDiscovery(), WithoutStatePolling(),
+			WithDuplicateWindow(time.Second), WithMetrics(recorder))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		require.NoError(t, ctrl.InjectMessage(addr0, msg))
+
+		msg = protocol.NewMessage(&packets.DeviceStateLabel{Label: [32]byte{'L', 'a', 'm', 'p'}})
+		msg.SetTarget(serial0)
+		require.NoError(t, ctrl.InjectMessage(addr0, msg))
+		require.NoError(t, ctrl.InjectMessage(addr0, msg.Clone()))
+		assert.Equal(t, 1, get(recorder, func() int { return recorder.dropped[DropDuplicate] }))
+	})
+
+	t.Run("Does not discover devices if disabled", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithoutDiscovery(), WithDiscoveryPeriod(time.Millisecond),
+			WithStaticDevices("192.168.0.20"))
+		require.NoError(t, err)
+
+		time.Sleep(10 * time.Millisecond)
+		ctrl.Close()
+		assert.Equal(t, 0, len(mockClient.broadcasts))
+		require.Equal(t, 1, len(mockClient.sends))
+		assert.Equal(t, uint16(packets.PayloadTypeDeviceGetService), (<-mockClient.sends).Type())
+	})
+
+	t.Run("Does not poll devices if disabled", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithoutDiscovery(), WithoutStatePolling())
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		require.NoError(t, ctrl.AddDevice("192.168.0.10"))
+		<-mockClient.sends
+
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, 1, len(ctrl.GetDevices()))
+		assert.Equal(t, 0, len(mockClient.sends))
+
+		// State is still updated from received messages.
+		msg = protocol.NewMessage(&packets.DeviceStateLabel{Label: [32]byte{'L', 'a', 'm', 'p'}})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, "Lamp", ctrl.GetDevices()[0].Label)
+	})
+
 	t.Run("Terminate sessions when closed", func(t *testing.T) {
 		mockClient := newMockClient()
 		ctrl, err := New(WithClient(mockClient))
@@ -189,13 +360,109 @@ func TestController(t *testing.T) {
 			t.Fatal("Session channel was not closed")
 		}
 	})
+
+	t.Run("Stops sending when ctx is cancelled", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, ctrl.DiscoverCtx(ctx), context.Canceled)
+
+		// Do not use newDeviceSession to prevent running state update goroutine.
+		ctrl.sessions[serial0] = &deviceSession{
+			sender: mockClient,
+			logger: discardLogger(),
+			device: device.NewDevice(addr0, serial0),
+			done:   make(chan struct{}),
+		}
+		assert.ErrorIs(t, ctrl.SendCtx(ctx, serial0, protocol.NewMessage(&packets.LightGet{})), context.Canceled)
+		assert.NoError(t, ctrl.SendCtx(context.Background(), serial0, protocol.NewMessage(&packets.LightGet{})))
+		assert.Equal(t, 1, len(mockClient.sends))
+	})
+
+	t.Run("Stops effects when closed", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient))
+		require.NoError(t, err)
+
+		stopped := make(chan struct{})
+		ctrl.Effects().Start(serial0, "wait", func(ctx context.Context, send protocol.SendFunc) error {
+			<-ctx.Done()
+			close(stopped)
+			return ctx.Err()
+		})
+
+		ctrl.Close()
+		select {
+		case <-stopped:
+		default:
+			t.Fatal("Effect was not stopped")
+		}
+		status, _ := ctrl.Effects().Status(serial0)
+		assert.Equal(t, matrix.EffectStopped, status.State)
+	})
+}
+
+func Test_parseDeviceAddr(t *testing.T) {
+	testCases := map[string]struct {
+		input   string
+		want    *net.UDPAddr
+		wantErr bool
+	}{
+		"IPv4": {
+			input: "192.168.1.10",
+			want:  &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: lifxPort},
+		},
+		"IPv4 with port": {
+			input: "192.168.1.10:1234",
+			want:  &net.UDPAddr{IP: net.ParseIP("192.168.1.10"), Port: 1234},
+		},
+		"IPv6 with port": {
+			input: "[fe80::1]:1234",
+			want:  &net.UDPAddr{IP: net.ParseIP("fe80::1"), Port: 1234},
+		},
+		"Hostname": {
+			input:   "lifx.local",
+			wantErr: true,
+		},
+		"Invalid port": {
+			input:   "192.168.1.10:70000",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDeviceAddr(tc.input)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
 }
 
 func BenchmarkControllerGetDevices(b *testing.B) {
+	ctrl := newBenchmarkController(b)
+
+	b.ResetTimer()
+	for b.Loop() {
+		_ = ctrl.GetDevices()
+	}
+}
+
+// newBenchmarkController returns a controller with 100 device sessions with random labels.
+func newBenchmarkController(b *testing.B) *Controller {
 	mockClient := newMockClient()
 	ctrl, err := New(WithClient(mockClient))
 	require.NoError(b, err)
-	defer ctrl.Close()
+	b.Cleanup(func() { ctrl.Close() })
 
 	// Base address
 	ipBase := [4]byte{192, 168, 1, 100}
@@ -224,11 +491,7 @@ func BenchmarkControllerGetDevices(b *testing.B) {
 		ctrl.addSession(addr, serial)
 		ctrl.sessions[serial].device.Label = randomLabel()
 	}
-
-	b.ResetTimer()
-	for b.Loop() {
-		_ = ctrl.GetDevices()
-	}
+	return ctrl
 }
 
 // randomLabel returns a random string of 8–10 alphabetic characters.
@@ -248,6 +511,8 @@ type mockClient struct {
 	inbound    chan recvMsg
 	once       sync.Once
 	done       chan struct{}
+	// acknowledge, if set, is called with the messages sent requiring an acknowledgement.
+	acknowledge func(msg *protocol.Message)
 }
 
 type recvMsg struct {
@@ -266,6 +531,9 @@ func newMockClient() *mockClient {
 
 func (m *mockClient) Send(dst *net.UDPAddr, msg *protocol.Message) error {
 	m.sends <- msg
+	if m.acknowledge != nil && msg.AckRequired() {
+		m.acknowledge(msg)
+	}
 	return nil
 }
 
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// dedupeState holds the last message received by a session, to detect duplicates.
+type dedupeState struct {
+	mu         sync.Mutex
+	last       *protocol.Message
+	receivedAt time.Time
+}
+
+// duplicate reports whether msg is identical to the previous message received by the session
+// within the duplicate window, recording the drop if so. Messages outside the window replace
+// the previous one, so that a device resending the same message has it processed once per window.
+func (s *deviceSession) duplicate(msg *protocol.Message) bool {
+	if s.cfg == nil || s.cfg.duplicateWindow <= 0 {
+		return false
+	}
+
+	now := time.Now()
+	s.dedupe.mu.Lock()
+	last := s.dedupe.last
+	dup := last != nil && now.Sub(s.dedupe.receivedAt) < s.cfg.duplicateWindow &&
+		last.Source() == msg.Source() && last.Sequence() == msg.Sequence() && last.Type() == msg.Type() &&
+		reflect.DeepEqual(last.Payload, msg.Payload)
+	if !dup {
+		s.dedupe.last, s.dedupe.receivedAt = msg, now
+	}
+	s.dedupe.mu.Unlock()
+
+	if dup {
+		s.metrics().MessageDropped(DropDuplicate)
+		s.logger.Debug("Dropping duplicate message", "payload", msg.Payload.PayloadType(), "sequence", msg.Sequence())
+	}
+	return dup
+}
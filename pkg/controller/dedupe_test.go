@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDuplicate(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	newMsg := func(source uint32, seq uint8, payload packets.Payload) *protocol.Message {
+		msg := protocol.NewMessage(payload)
+		msg.SetSource(source)
+		msg.SetSequence(seq)
+		return msg
+	}
+	first := newMsg(2, 1, &packets.LightState{Label: [32]byte{'a'}})
+
+	tests := map[string]struct {
+		window time.Duration
+		// wait is the time between the first and the second message.
+		wait    time.Duration
+		msg     *protocol.Message
+		wantDup bool
+	}{
+		"identical message": {
+			window:  time.Second,
+			msg:     newMsg(2, 1, &packets.LightState{Label: [32]byte{'a'}}),
+			wantDup: true,
+		},
+		"without window": {
+			msg: newMsg(2, 1, &packets.LightState{Label: [32]byte{'a'}}),
+		},
+		"after window": {
+			window: 10 * time.Millisecond,
+			wait:   20 * time.Millisecond,
+			msg:    newMsg(2, 1, &packets.LightState{Label: [32]byte{'a'}}),
+		},
+		"different source": {
+			window: time.Second,
+			msg:    newMsg(3, 1, &packets.LightState{Label: [32]byte{'a'}}),
+		},
+		"different sequence": {
+			window: time.Second,
+			msg:    newMsg(2, 2, &packets.LightState{Label: [32]byte{'a'}}),
+		},
+		"different payload type": {
+			window: time.Second,
+			msg:    newMsg(2, 1, &packets.DeviceStatePower{}),
+		},
+		"different payload": {
+			window: time.Second,
+			msg:    newMsg(2, 1, &packets.LightState{Label: [32]byte{'b'}}),
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := newFakeRecorder()
+			s := &deviceSession{
+				logger: discardLogger(),
+				device: device.NewDevice(addr0, serial0),
+				cfg:    &Config{duplicateWindow: tt.window, metrics: recorder},
+			}
+			assert.False(t, s.duplicate(first))
+			time.Sleep(tt.wait)
+			assert.Equal(t, tt.wantDup, s.duplicate(tt.msg))
+
+			wantDropped := 0
+			if tt.wantDup {
+				wantDropped = 1
+			}
+			assert.Equal(t, wantDropped, recorder.dropped[DropDuplicate])
+		})
+	}
+}
+
+func TestWithDuplicateWindow(t *testing.T) {
+	ctrl := &Controller{cfg: &Config{}}
+	assert.NoError(t, WithDuplicateWindow(time.Second)(ctrl))
+	assert.Equal(t, time.Second, ctrl.cfg.duplicateWindow)
+	assert.Error(t, WithDuplicateWindow(0)(ctrl))
+}
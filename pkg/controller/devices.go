@@ -0,0 +1,120 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"iter"
+	"slices"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// DeviceFilter reports whether a device is selected by Devices and FindDevices.
+type DeviceFilter func(device.Device) bool
+
+// BySerial selects the devices with one of the given serials.
+func BySerial(serials ...device.Serial) DeviceFilter {
+	return func(d device.Device) bool {
+		return slices.Contains(serials, d.Serial)
+	}
+}
+
+// ByGroup selects the devices in the group with the given label.
+func ByGroup(label string) DeviceFilter {
+	return func(d device.Device) bool {
+		return d.Group == label
+	}
+}
+
+// ByLocation selects the devices in the location with the given label.
+func ByLocation(label string) DeviceFilter {
+	return func(d device.Device) bool {
+		return d.Location == label
+	}
+}
+
+// ByDeviceType selects the devices of one of the given types, e.g. lights or switches.
+func ByDeviceType(types ...device.DeviceType) DeviceFilter {
+	return func(d device.Device) bool {
+		return slices.Contains(types, d.Type)
+	}
+}
+
+// ByLightType selects the lights with one of the given capabilities, e.g. multizone or matrix.
+// Switches are never selected.
+func ByLightType(types ...device.LightType) DeviceFilter {
+	return func(d device.Device) bool {
+		return d.Type != device.DeviceTypeSwitch && slices.Contains(types, d.LightType)
+	}
+}
+
+// GetDevice returns the device with the given serial, if it has a session.
+func (c *Controller) GetDevice(serial device.Serial) (device.Device, bool) {
+	if s := c.session(serial); s != nil {
+		return s.deviceSnapshot(), true
+	}
+	return device.Device{}, false
+}
+
+// HasDevice reports whether the device with the given serial has a session,
+// i.e. whether messages can be sent to it.
+func (c *Controller) HasDevice(serial device.Serial) bool {
+	return c.session(serial) != nil
+}
+
+// WaitForDevice blocks until the device with the given serial has a session and returns it,
+// e.g. to send messages to a device right after creating the Controller, before it is discovered.
+// It returns the context error if ctx is done first, and an error wrapping ErrNoSession if the
+// Controller is closed. Devices are discovered by the periodic discovery, or by Discover.
+func (c *Controller) WaitForDevice(ctx context.Context, serial device.Serial) (device.Device, error) {
+	// Subscribe before checking for the session so that a device discovered in between is not missed.
+	events, cancel := c.Subscribe(EventFilter{Types: []EventType{EventDeviceDiscovered}, Serials: []device.Serial{serial}})
+	defer cancel()
+
+	for {
+		if d, ok := c.GetDevice(serial); ok {
+			return d, nil
+		}
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return device.Device{}, fmt.Errorf("%w for device %s: controller closed", ErrNoSession, serial)
+			}
+		case <-ctx.Done():
+			return device.Device{}, ctx.Err()
+		}
+	}
+}
+
+// Devices returns an iterator over the devices that have a session and match all the given filters.
+// Devices are yielded in no particular order, without building the whole list, and each device is
+// copied only when it is reached, so breaking early avoids copying the rest.
+// Devices added or removed during the iteration may or may not be yielded.
+func (c *Controller) Devices(filters ...DeviceFilter) iter.Seq[device.Device] {
+	return func(yield func(device.Device) bool) {
+		for _, s := range c.sessionList() {
+			d := s.deviceSnapshot()
+			if matchesAll(d, filters) && !yield(d) {
+				return
+			}
+		}
+	}
+}
+
+// FindDevices returns the devices that have a session and match all the given filters,
+// sorted like GetDevices.
+func (c *Controller) FindDevices(filters ...DeviceFilter) []device.Device {
+	devices := slices.Collect(c.Devices(filters...))
+	device.SortDevices(devices)
+	return devices
+}
+
+// matchesAll reports whether the device matches all filters.
+func matchesAll(d device.Device, filters []DeviceFilter) bool {
+	for _, f := range filters {
+		if !f(d) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerDevices(t *testing.T) {
+	var (
+		serial0 = device.Serial([8]byte{1})
+		serial1 = device.Serial([8]byte{2})
+		serial2 = device.Serial([8]byte{3})
+		serial3 = device.Serial([8]byte{4})
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	newDevice := func(serial device.Serial, label, group string, deviceType device.DeviceType, lightType device.LightType) *device.Device {
+		d := device.NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, serial[0])}, serial)
+		d.Label, d.Group, d.Location, d.Type, d.LightType = label, group, "Home", deviceType, lightType
+		return d
+	}
+	// Do not use newDeviceSession to prevent running state update goroutine.
+	for _, d := range []*device.Device{
+		newDevice(serial0, "Strip", "Kitchen", device.DeviceTypeLight, device.LightTypeMultiZone),
+		newDevice(serial1, "Bulb", "Kitchen", device.DeviceTypeLight, device.LightTypeSingleZone),
+		newDevice(serial2, "Tile", "Bedroom", device.DeviceTypeLight, device.LightTypeMatrix),
+		newDevice(serial3, "Switch", "Kitchen", device.DeviceTypeSwitch, device.LightTypeSingleZone),
+	} {
+		ctrl.sessions[d.Serial] = &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+	}
+
+	serials := func(devices []device.Device) []device.Serial {
+		var s []device.Serial
+		for _, d := range devices {
+			s = append(s, d.Serial)
+		}
+		return s
+	}
+
+	t.Run("GetDevice", func(t *testing.T) {
+		d, ok := ctrl.GetDevice(serial1)
+		require.True(t, ok)
+		assert.Equal(t, "Bulb", d.Label)
+
+		_, ok = ctrl.GetDevice(device.Serial{9})
+		assert.False(t, ok)
+	})
+
+	t.Run("HasDevice", func(t *testing.T) {
+		assert.True(t, ctrl.HasDevice(serial2))
+		assert.False(t, ctrl.HasDevice(device.Serial{9}))
+	})
+
+	t.Run("FindDevices", func(t *testing.T) {
+		tests := map[string]struct {
+			filters []DeviceFilter
+			want    []device.Serial
+		}{
+			"No filters": {
+				want: []device.Serial{serial1, serial0, serial3, serial2},
+			},
+			"By serial": {
+				filters: []DeviceFilter{BySerial(serial2, serial0)},
+				want:    []device.Serial{serial0, serial2},
+			},
+			"By group": {
+				filters: []DeviceFilter{ByGroup("Kitchen")},
+				want:    []device.Serial{serial1, serial0, serial3},
+			},
+			"By location": {
+				filters: []DeviceFilter{ByLocation("Office")},
+			},
+			"By device type": {
+				filters: []DeviceFilter{ByDeviceType(device.DeviceTypeSwitch)},
+				want:    []device.Serial{serial3},
+			},
+			"By light type": {
+				filters: []DeviceFilter{ByLightType(device.LightTypeSingleZone, device.LightTypeMatrix)},
+				want:    []device.Serial{serial1, serial2},
+			},
+			"All filters match": {
+				filters: []DeviceFilter{ByGroup("Kitchen"), ByLightType(device.LightTypeMultiZone)},
+				want:    []device.Serial{serial0},
+			},
+		}
+
+		for name, tt := range tests {
+			t.Run(name, func(t *testing.T) {
+				assert.Equal(t, tt.want, serials(ctrl.FindDevices(tt.filters...)))
+			})
+		}
+	})
+
+	t.Run("Devices stops early", func(t *testing.T) {
+		var n int
+		for range ctrl.Devices(ByGroup("Kitchen")) {
+			n++
+			break
+		}
+		assert.Equal(t, 1, n)
+	})
+}
+
+func BenchmarkControllerGetDevice(b *testing.B) {
+	ctrl := newBenchmarkController(b)
+	serial := device.Serial([8]byte{0, 0, 0, 0, 0, 50})
+
+	b.ResetTimer()
+	for b.Loop() {
+		_, _ = ctrl.GetDevice(serial)
+	}
+}
+
+func BenchmarkControllerDevices(b *testing.B) {
+	ctrl := newBenchmarkController(b)
+
+	b.ResetTimer()
+	for b.Loop() {
+		for range ctrl.Devices() {
+		}
+	}
+}
+
+func TestWaitForDevice(t *testing.T) {
+	serial0 := device.Serial([8]byte{1})
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 1)}
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	t.Run("Returns the context error if the device is not discovered", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		_, err := ctrl.WaitForDevice(ctx, serial0)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+
+	t.Run("Returns once the device is discovered", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		time.AfterFunc(10*time.Millisecond, func() { ctrl.addSession(addr0, serial0) })
+
+		d, err := ctrl.WaitForDevice(ctx, serial0)
+		require.NoError(t, err)
+		assert.Equal(t, serial0, d.Serial)
+	})
+
+	t.Run("Returns a device with a session right away", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		d, err := ctrl.WaitForDevice(ctx, serial0)
+		require.NoError(t, err)
+		assert.Equal(t, serial0, d.Serial)
+	})
+}
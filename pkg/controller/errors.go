@@ -0,0 +1,24 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+)
+
+// Errors returned by the Controller, to be matched with errors.Is.
+var (
+	// ErrNoSession is returned when a message or command targets a device that has no session,
+	// either because it was not discovered yet or because it went offline.
+	ErrNoSession = errors.New("no session")
+	// ErrDeviceOffline is returned when a send is interrupted by the session of the device being
+	// terminated because the device was not seen within the liveness timeout.
+	ErrDeviceOffline = errors.New("device offline")
+	// ErrSendTimeout is returned when a device does not acknowledge a message in time.
+	ErrSendTimeout = errors.New("send timeout")
+	// ErrClosed is returned when using a Controller that is closed.
+	ErrClosed = errors.New("controller closed")
+	// ErrUnsupportedCapability is returned when a device does not support a message, e.g. a multizone
+	// message sent to a bulb. It is messages.ErrUnsupported, so that either can be matched.
+	ErrUnsupportedCapability = messages.ErrUnsupported
+)
@@ -0,0 +1,56 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	t.Run("Commands without a session return ErrNoSession", func(t *testing.T) {
+		ctrl := &Controller{cfg: &Config{}, sessions: map[device.Serial]*deviceSession{}}
+		color := device.Color{Brightness: 100, Kelvin: 3500}
+
+		assert.ErrorIs(t, ctrl.Send(serial0, protocol.NewMessage(&packets.LightGet{})), ErrNoSession)
+		assert.ErrorIs(t, ctrl.SendBatch(serial0, protocol.NewMessage(&packets.LightGet{})), ErrNoSession)
+		assert.ErrorIs(t, ctrl.SetColor(serial0, color, 0), ErrNoSession)
+		assert.ErrorIs(t, ctrl.Flash(serial0, color, 1, time.Millisecond), ErrNoSession)
+	})
+
+	t.Run("Sends interrupted by the device going offline return ErrDeviceOffline", func(t *testing.T) {
+		for name, offline := range map[string]bool{"offline": true, "closed": false} {
+			t.Run(name, func(t *testing.T) {
+				s := &deviceSession{
+					sender:  &ackingSender{},
+					logger:  discardLogger(),
+					device:  device.NewDevice(addr0, serial0),
+					done:    make(chan struct{}),
+					cfg:     &Config{},
+					limiter: newRateLimiter(1, 1, time.Minute),
+				}
+				// Use up the burst so that the next send waits for the limiter.
+				assert.NoError(t, s.send(protocol.NewMessage(&packets.LightGet{})))
+				time.AfterFunc(10*time.Millisecond, func() {
+					s.offline.Store(offline)
+					s.close()
+				})
+
+				err := s.sendCtx(context.Background(), protocol.NewMessage(&packets.LightGet{}))
+				if offline {
+					assert.ErrorIs(t, err, ErrDeviceOffline)
+				} else {
+					assert.ErrorIs(t, err, errSessionClosed)
+				}
+			})
+		}
+	})
+}
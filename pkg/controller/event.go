@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+const (
+	defaultEventBufferSize = 64
+)
+
+// EventType describes the kind of device state change carried by an Event.
+type EventType int
+
+const (
+	// EventDeviceDiscovered is emitted when a new device session is created.
+	EventDeviceDiscovered EventType = iota
+	// EventDeviceOffline is emitted when a device has not been seen within the liveness timeout,
+	// before its session is terminated, or when it is marked offline, see WithOfflineDevices.
+	EventDeviceOffline
+	// EventLabelChanged is emitted when a device label changes.
+	EventLabelChanged
+	// EventPowerChanged is emitted when a device power state changes.
+	EventPowerChanged
+	// EventColorChanged is emitted when a device color changes.
+	EventColorChanged
+	// EventMatrixStateChanged is emitted when a matrix device layout or zone colors change.
+	EventMatrixStateChanged
+	// EventExternallyModified is emitted when a power or color change not originated by the
+	// Controller is observed, e.g. from the LIFX app or a physical switch.
+	EventExternallyModified
+	// EventMultizoneStateChanged is emitted when the zone colors of a multizone device change.
+	EventMultizoneStateChanged
+	// EventButtonsChanged is emitted when the actions or configuration of a device buttons change.
+	EventButtonsChanged
+	// EventButtonPressed is emitted for each button toggling a relay whose power changed without
+	// a command from the Controller, as reported by state polling. The LAN protocol does not report
+	// button presses, so these are inferred and may also result from toggling the relay in the app,
+	// while presses of buttons that only control other devices are not reported.
+	EventButtonPressed
+	// EventDeviceRebooted is emitted when the uptime reported by a device shows it booted again
+	// since its previous report.
+	EventDeviceRebooted
+	// EventEffectChanged is emitted when the firmware effect of a multizone or matrix device starts,
+	// stops or changes, e.g. from the LIFX app, with the new effect in Device.Effect.
+	EventEffectChanged
+	// EventDeviceOnline is emitted when a device marked offline is seen again, see WithOfflineDevices.
+	EventDeviceOnline
+)
+
+// String converts an EventType into a string.
+func (e EventType) String() string {
+	switch e {
+	case EventDeviceDiscovered:
+		return "device_discovered"
+	case EventDeviceOffline:
+		return "device_offline"
+	case EventLabelChanged:
+		return "label_changed"
+	case EventPowerChanged:
+		return "power_changed"
+	case EventColorChanged:
+		return "color_changed"
+	case EventMatrixStateChanged:
+		return "matrix_state_changed"
+	case EventExternallyModified:
+		return "externally_modified"
+	case EventMultizoneStateChanged:
+		return "multizone_state_changed"
+	case EventButtonsChanged:
+		return "buttons_changed"
+	case EventButtonPressed:
+		return "button_pressed"
+	case EventDeviceRebooted:
+		return "device_rebooted"
+	case EventEffectChanged:
+		return "effect_changed"
+	case EventDeviceOnline:
+		return "device_online"
+	}
+	return ""
+}
+
+// Event describes a change in a device state.
+// Device is a deep copy of the device state taken atomically with the change,
+// which is safe to retain and read concurrently.
+type Event struct {
+	Type   EventType
+	Serial device.Serial
+	Device device.Device
+	At     time.Time
+	// Button is the index of the pressed button for EventButtonPressed.
+	Button int
+}
+
+// EventFilter selects which events are delivered to a subscriber.
+// Empty fields match any value, so the zero EventFilter matches every event.
+type EventFilter struct {
+	Types   []EventType
+	Serials []device.Serial
+}
+
+// Match returns whether the event satisfies the filter.
+func (f EventFilter) Match(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	if len(f.Serials) > 0 && !slices.Contains(f.Serials, e.Serial) {
+		return false
+	}
+	return true
+}
+
+// Subscribe returns a channel receiving the events matching filter and a function
+// to cancel the subscription. The channel is closed when the subscription is
+// cancelled or the Controller is closed.
+// Events are delivered without blocking state updates; if the subscriber does not
+// keep up, events are dropped.
+func (c *Controller) Subscribe(filter EventFilter) (<-chan Event, func()) {
+	return c.events.subscribe(filter)
+}
+
+// eventBus fans out events to its subscribers.
+type eventBus struct {
+	mu     sync.RWMutex
+	subs   map[int]*subscriber
+	nextID int
+	closed bool
+}
+
+type subscriber struct {
+	ch     chan Event
+	filter EventFilter
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[int]*subscriber)}
+}
+
+// subscribe registers a new subscriber and returns its channel and cancel function.
+func (b *eventBus) subscribe(filter EventFilter) (<-chan Event, func()) {
+	ch := make(chan Event, defaultEventBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	id := b.nextID
+	b.nextID++
+	b.subs[id] = &subscriber{ch: ch, filter: filter}
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() { b.unsubscribe(id) })
+	}
+}
+
+func (b *eventBus) unsubscribe(id int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if s, ok := b.subs[id]; ok {
+		delete(b.subs, id)
+		close(s.ch)
+	}
+}
+
+// publish delivers the events to every matching subscriber.
+// It is safe to call on a nil eventBus.
+func (b *eventBus) publish(events ...Event) {
+	if b == nil {
+		return
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, e := range events {
+		for _, s := range b.subs {
+			if !s.filter.Match(e) {
+				continue
+			}
+			select {
+			case s.ch <- e:
+			default:
+				// Skip the event if the subscriber is not keeping up.
+			}
+		}
+	}
+}
+
+// close closes all subscribers channels and prevents new subscriptions.
+func (b *eventBus) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for id, s := range b.subs {
+		delete(b.subs, id)
+		close(s.ch)
+	}
+}
@@ -0,0 +1,404 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventFilter(t *testing.T) {
+	var (
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		serial1 = device.Serial([8]byte{2, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	testCases := map[string]struct {
+		filter EventFilter
+		event  Event
+		want   bool
+	}{
+		"zero filter matches all": {
+			event: Event{Type: EventLabelChanged, Serial: serial0},
+			want:  true,
+		},
+		"matches type": {
+			filter: EventFilter{Types: []EventType{EventPowerChanged, EventLabelChanged}},
+			event:  Event{Type: EventLabelChanged, Serial: serial0},
+			want:   true,
+		},
+		"does not match type": {
+			filter: EventFilter{Types: []EventType{EventPowerChanged}},
+			event:  Event{Type: EventLabelChanged, Serial: serial0},
+		},
+		"matches serial": {
+			filter: EventFilter{Serials: []device.Serial{serial0}},
+			event:  Event{Type: EventLabelChanged, Serial: serial0},
+			want:   true,
+		},
+		"does not match serial": {
+			filter: EventFilter{Types: []EventType{EventLabelChanged}, Serials: []device.Serial{serial1}},
+			event:  Event{Type: EventLabelChanged, Serial: serial0},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.filter.Match(tc.event))
+		})
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	t.Run("Delivers matching events", func(t *testing.T) {
+		bus := newEventBus()
+		ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventColorChanged}})
+		defer cancel()
+
+		bus.publish(
+			Event{Type: EventLabelChanged, Serial: serial0},
+			Event{Type: EventColorChanged, Serial: serial0},
+		)
+
+		select {
+		case e := <-ch:
+			assert.Equal(t, EventColorChanged, e.Type)
+		case <-time.After(10 * time.Millisecond):
+			t.Fatal("Expected event")
+		}
+		assert.Equal(t, 0, len(ch))
+	})
+
+	t.Run("Closes channel on cancel", func(t *testing.T) {
+		bus := newEventBus()
+		ch, cancel := bus.subscribe(EventFilter{})
+		cancel()
+		cancel()
+
+		_, ok := <-ch
+		assert.False(t, ok)
+	})
+
+	t.Run("Drops events when subscriber is full", func(t *testing.T) {
+		bus := newEventBus()
+		ch, cancel := bus.subscribe(EventFilter{})
+		defer cancel()
+
+		for range defaultEventBufferSize + 1 {
+			bus.publish(Event{Type: EventPowerChanged, Serial: serial0})
+		}
+		assert.Equal(t, defaultEventBufferSize, len(ch))
+	})
+
+	t.Run("Closed bus returns closed channels", func(t *testing.T) {
+		bus := newEventBus()
+		bus.close()
+
+		ch, _ := bus.subscribe(EventFilter{})
+		_, ok := <-ch
+		assert.False(t, ok)
+	})
+}
+
+func TestSessionEventSnapshot(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	bus := newEventBus()
+	ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventMatrixStateChanged}})
+	defer cancel()
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+		events:  bus,
+	}
+	s.device.MatrixProperties = device.MatrixProperties{
+		Width: 8, Height: 8, NZones: 64, ChainLength: 1,
+		ChainZones: [][]packets.LightHsbk{make([]packets.LightHsbk, 64)},
+	}
+	go s.recvloop()
+	defer s.close()
+
+	var got []Event
+	for _, hue := range []uint16{100, 200} {
+		p := &packets.TileState64{Rect: packets.TileBufferRect{Width: 8}}
+		for i := range p.Colors {
+			p.Colors[i].Hue = hue
+		}
+		s.inbound <- protocol.NewMessage(p)
+
+		select {
+		case e := <-ch:
+			got = append(got, e)
+		case <-time.After(10 * time.Millisecond):
+			t.Fatal("Expected event")
+		}
+	}
+
+	// Later updates do not change the zones of previous events.
+	assert.Equal(t, uint16(100), got[0].Device.MatrixProperties.ChainZones[0][0].Hue)
+	assert.Equal(t, uint16(200), got[1].Device.MatrixProperties.ChainZones[0][0].Hue)
+}
+
+func TestSessionMultizoneEvents(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	bus := newEventBus()
+	ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventMultizoneStateChanged}})
+	defer cancel()
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+		events:  bus,
+	}
+	go s.recvloop()
+	defer s.close()
+
+	p := &packets.MultiZoneExtendedStateMultiZone{Count: 4, Index: 1, ColorsCount: 2}
+	p.Colors[0].Hue, p.Colors[1].Hue = 100, 200
+	s.inbound <- protocol.NewMessage(p)
+
+	select {
+	case e := <-ch:
+		assert.Equal(t, []packets.LightHsbk{{}, {Hue: 100}, {Hue: 200}, {}}, e.Device.MultizoneProperties.Zones)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+
+	// Legacy multizone messages update zones too.
+	s.inbound <- protocol.NewMessage(&packets.MultiZoneStateMultiZone{Count: 4, Index: 0, Colors: [8]packets.LightHsbk{{Hue: 50}}})
+	select {
+	case e := <-ch:
+		assert.Equal(t, []packets.LightHsbk{{Hue: 50}, {}, {}, {}}, e.Device.MultizoneProperties.Zones)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+
+	// Unchanged zones do not emit events.
+	s.inbound <- protocol.NewMessage(&packets.MultiZoneStateMultiZone{Count: 4, Index: 0, Colors: [8]packets.LightHsbk{{Hue: 50}}})
+	// Wait for the previous message to be processed.
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
+	select {
+	case e := <-ch:
+		t.Fatalf("Unexpected event %v", e.Type)
+	default:
+	}
+}
+
+func TestSessionButtonEvents(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	bus := newEventBus()
+	ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventButtonsChanged, EventButtonPressed}})
+	defer cancel()
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+		events:  bus,
+	}
+	go s.recvloop()
+	defer s.close()
+
+	toggle := packets.ButtonAction{
+		Gesture:    enums.ButtonGestureBUTTONGESTUREPRESS,
+		TargetType: enums.ButtonTargetTypeBUTTONTARGETTYPEPOWERTOGGLERELAYS,
+	}
+	toggle.Target.SetPowerToggleRelays(&packets.ButtonTargetRelays{RelaysCount: 1, Relays: [15]uint8{1}})
+	s.inbound <- protocol.NewMessage(&packets.ButtonState{
+		Count: 2, ButtonsCount: 2,
+		Buttons: [8]packets.Button{{}, {ActionsCount: 1, Actions: [5]packets.ButtonAction{toggle}}},
+	})
+	select {
+	case e := <-ch:
+		assert.Equal(t, EventButtonsChanged, e.Type)
+		assert.Len(t, e.Device.Buttons, 2)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+
+	// The first relay state is not a press, as the previous state is unknown.
+	s.inbound <- protocol.NewMessage(&packets.RelayStatePower{RelayIndex: 1})
+	s.inbound <- protocol.NewMessage(&packets.RelayStatePower{RelayIndex: 1, Level: 65535})
+	select {
+	case e := <-ch:
+		assert.Equal(t, EventButtonPressed, e.Type)
+		assert.Equal(t, 1, e.Button)
+		assert.True(t, e.Device.RelayProperties.Relays[1].PoweredOn())
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+
+	// Relays not toggled by a button do not emit presses.
+	s.inbound <- protocol.NewMessage(&packets.RelayStatePower{RelayIndex: 0, Level: 65535})
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
+	select {
+	case e := <-ch:
+		t.Fatalf("Unexpected event %v", e.Type)
+	default:
+	}
+
+	s.inbound <- protocol.NewMessage(&packets.ButtonStateConfig{HapticDurationMs: 50})
+	select {
+	case e := <-ch:
+		assert.Equal(t, EventButtonsChanged, e.Type)
+		assert.Equal(t, 50*time.Millisecond, e.Device.ButtonConfig.HapticDuration)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+}
+
+func TestSessionRebootEvents(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	bus := newEventBus()
+	ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventDeviceRebooted}})
+	defer cancel()
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+		events:  bus,
+	}
+	go s.recvloop()
+	defer s.close()
+
+	// The first report sets the boot time.
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateInfo{Uptime: uint64(time.Hour)})
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
+	select {
+	case e := <-ch:
+		t.Fatalf("Unexpected event %v", e.Type)
+	default:
+	}
+
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateInfo{Uptime: uint64(time.Second)})
+	select {
+	case e := <-ch:
+		assert.InDelta(t, time.Second, e.Device.Uptime(), float64(time.Second))
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+}
+
+func TestControllerSubscribe(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient))
+	require.NoError(t, err)
+
+	ch, cancel := ctrl.Subscribe(EventFilter{Serials: []device.Serial{serial0}})
+	defer cancel()
+
+	ctrl.addSession(addr0, serial0)
+
+	msg := protocol.NewMessage(&packets.DeviceStateLabel{Label: [32]byte{'L', 'i', 'f', 'y'}})
+	msg.SetTarget(serial0)
+	mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+
+	// Duplicated state does not emit further events.
+	msg = protocol.NewMessage(&packets.DeviceStateLabel{Label: [32]byte{'L', 'i', 'f', 'y'}})
+	msg.SetTarget(serial0)
+	mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+
+	msg = protocol.NewMessage(&packets.DeviceStatePower{Level: 65535})
+	msg.SetTarget(serial0)
+	mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+
+	var got []EventType
+	timeout := time.After(20 * time.Millisecond)
+outer:
+	for {
+		select {
+		case e := <-ch:
+			assert.Equal(t, serial0, e.Serial)
+			got = append(got, e.Type)
+			if e.Type == EventLabelChanged {
+				assert.Equal(t, "Lify", e.Device.Label)
+			}
+		case <-timeout:
+			break outer
+		}
+	}
+	assert.Equal(t, []EventType{EventDeviceDiscovered, EventLabelChanged, EventPowerChanged}, got)
+
+	ctrl.Close()
+	_, ok := <-ch
+	assert.False(t, ok)
+}
+
+func TestSessionEffectEvents(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	bus := newEventBus()
+	ch, cancel := bus.subscribe(EventFilter{Types: []EventType{EventEffectChanged}})
+	defer cancel()
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+		events:  bus,
+	}
+	go s.recvloop()
+	defer s.close()
+
+	flame := &packets.TileStateEffect{Settings: packets.TileEffectSettings{Instanceid: 1, Type: enums.TileEffectTypeTILEEFFECTTYPEFLAME, Speed: 3000}}
+	s.inbound <- protocol.NewMessage(flame)
+	select {
+	case e := <-ch:
+		assert.Equal(t, device.EffectTypeFlame, e.Device.Effect.Type)
+		assert.Equal(t, 3*time.Second, e.Device.Effect.Speed)
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+
+	// The same effect does not emit an event.
+	s.inbound <- protocol.NewMessage(flame)
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
+	select {
+	case e := <-ch:
+		t.Fatalf("Unexpected event %v", e.Type)
+	default:
+	}
+
+	s.inbound <- protocol.NewMessage(&packets.TileStateEffect{})
+	select {
+	case e := <-ch:
+		assert.False(t, e.Device.Effect.Running())
+	case <-time.After(10 * time.Millisecond):
+		t.Fatal("Expected event")
+	}
+}
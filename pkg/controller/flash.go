@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrNotLight is returned when a light command is sent to a device that is not a light, e.g. a switch.
+var ErrNotLight = errors.New("device is not a light")
+
+// Flash pulses the device to the given color the given number of times, each lasting period,
+// e.g. for notifications, and blocks until it is done.
+// The device state known to the Controller is restored afterwards, including the colors of each zone
+// of multizone and matrix devices and its power, if the device was off.
+func (c *Controller) Flash(serial device.Serial, color device.Color, times int, period time.Duration) error {
+	return c.FlashCtx(context.Background(), serial, color, times, period)
+}
+
+// FlashCtx is like Flash but stops flashing when ctx is cancelled, returning the context error.
+// The device state is restored in any case.
+func (c *Controller) FlashCtx(ctx context.Context, serial device.Serial, color device.Color, times int, period time.Duration) error {
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+
+	s.mu.RLock()
+	saved := s.device.Clone()
+	s.mu.RUnlock()
+	if saved.Type == device.DeviceTypeSwitch {
+		return ErrNotLight
+	}
+
+	times = max(times, 1)
+	pulse, err := messages.Pulse(color.ToDeviceColor(), period, float64(times), 0.5, true)
+	if err != nil {
+		return err
+	}
+	msgs := []*protocol.Message{pulse}
+	if !saved.PoweredOn {
+		msgs = append([]*protocol.Message{messages.SetPowerOn()}, msgs...)
+	}
+
+	err = func() error {
+		for _, msg := range msgs {
+			if err := s.sendCtx(ctx, msg); err != nil {
+				return err
+			}
+		}
+		t := time.NewTimer(time.Duration(times) * period)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+			return nil
+		}
+	}()
+
+	// Restore regardless of ctx, so the device is not left mid flash.
+	errs := []error{err}
+	for _, msg := range restoreMessages(saved, 0) {
+		errs = append(errs, s.send(msg))
+	}
+	return errors.Join(errs...)
+}
+
+// restoreMessages returns the messages that set a device back to the colors of d over the
+// duration dur, and turn it off if d was off.
+func restoreMessages(d device.Device, dur time.Duration) []*protocol.Message {
+	var msgs []*protocol.Message
+	switch {
+	case d.LightType == device.LightTypeMatrix && len(d.MatrixProperties.ChainZones) > 0:
+		for i, zones := range d.MatrixProperties.ChainZones {
+			msgs = append(msgs, messages.SetMatrixDeviceColors(d.MatrixProperties, i, zones, dur)...)
+		}
+	case d.LightType == device.LightTypeMultiZone && len(d.MultizoneProperties.Zones) > 0:
+		msgs = messages.SetMultizoneColors(d.MultizoneProperties, 0, d.MultizoneProperties.Zones, dur)
+	default:
+		msgs = []*protocol.Message{protocol.NewMessage(&packets.LightSetColor{
+			Color:    d.Color.ToDeviceColor(),
+			Duration: uint32(dur.Milliseconds()),
+		})}
+	}
+
+	if !d.PoweredOn {
+		msgs = append(msgs, powerMessage(false, dur))
+	}
+	return msgs
+}
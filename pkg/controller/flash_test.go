@@ -0,0 +1,167 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlash(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		red     = device.Color{Hue: 0, Saturation: 100, Brightness: 100, Kelvin: 3500}
+		white   = device.Color{Brightness: 50, Kelvin: 2700}
+	)
+
+	newController := func(t *testing.T, d *device.Device) (*Controller, *mockClient) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		t.Cleanup(func() { ctrl.Close() })
+
+		// Do not use newDeviceSession to prevent running state update goroutine.
+		ctrl.sessions[serial0] = &deviceSession{
+			sender: mockClient,
+			logger: discardLogger(),
+			device: d,
+			done:   make(chan struct{}),
+		}
+		return ctrl, mockClient
+	}
+
+	// payloadTypes returns the types of the given payloads, or of the ones sent if none are given.
+	payloadTypes := func(m *mockClient, payloads ...packets.Payload) []uint16 {
+		var types []uint16
+		for _, p := range payloads {
+			types = append(types, p.PayloadType())
+		}
+		for m != nil && len(m.sends) > 0 {
+			types = append(types, (<-m.sends).Payload.PayloadType())
+		}
+		return types
+	}
+
+	testCases := map[string]struct {
+		device func() *device.Device
+		want   []packets.Payload
+	}{
+		"single zone": {
+			device: func() *device.Device {
+				d := device.NewDevice(addr0, serial0)
+				d.PoweredOn, d.Color = true, white
+				return d
+			},
+			want: []packets.Payload{&packets.LightSetWaveform{}, &packets.LightSetColor{}},
+		},
+		"powered off": {
+			device: func() *device.Device {
+				return device.NewDevice(addr0, serial0)
+			},
+			want: []packets.Payload{
+				&packets.DeviceSetPower{}, &packets.LightSetWaveform{},
+				&packets.LightSetColor{}, &packets.DeviceSetPower{},
+			},
+		},
+		"multizone": {
+			device: func() *device.Device {
+				d := device.NewDevice(addr0, serial0)
+				d.PoweredOn, d.LightType = true, device.LightTypeMultiZone
+				d.MultizoneProperties.Zones = make([]packets.LightHsbk, 16)
+				d.MultizoneProperties.Extended = true
+				return d
+			},
+			want: []packets.Payload{&packets.LightSetWaveform{}, &packets.MultiZoneExtendedSetColorZones{}},
+		},
+		"legacy multizone": {
+			device: func() *device.Device {
+				d := device.NewDevice(addr0, serial0)
+				d.PoweredOn, d.LightType = true, device.LightTypeMultiZone
+				d.MultizoneProperties.Zones = make([]packets.LightHsbk, 16)
+				d.MultizoneProperties.Zones[8].Hue = 100
+				return d
+			},
+			want: []packets.Payload{
+				&packets.LightSetWaveform{}, &packets.MultiZoneSetColorZones{},
+				&packets.MultiZoneSetColorZones{}, &packets.MultiZoneSetColorZones{},
+			},
+		},
+		"matrix": {
+			device: func() *device.Device {
+				d := device.NewDevice(addr0, serial0)
+				d.PoweredOn, d.LightType = true, device.LightTypeMatrix
+				d.MatrixProperties.Width, d.MatrixProperties.Height = 8, 8
+				d.MatrixProperties.ChainZones = [][]packets.LightHsbk{make([]packets.LightHsbk, 64), make([]packets.LightHsbk, 64)}
+				return d
+			},
+			want: []packets.Payload{&packets.LightSetWaveform{}, &packets.TileSet64{}, &packets.TileSet64{}},
+		},
+		"multizone with unknown zones": {
+			device: func() *device.Device {
+				d := device.NewDevice(addr0, serial0)
+				d.PoweredOn, d.LightType = true, device.LightTypeMultiZone
+				return d
+			},
+			want: []packets.Payload{&packets.LightSetWaveform{}, &packets.LightSetColor{}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctrl, mockClient := newController(t, tc.device())
+			require.NoError(t, ctrl.Flash(serial0, red, 2, time.Millisecond))
+			assert.Equal(t, payloadTypes(nil, tc.want...), payloadTypes(mockClient))
+		})
+	}
+
+	t.Run("Pulses the given color", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn = true
+		ctrl, mockClient := newController(t, d)
+		require.NoError(t, ctrl.Flash(serial0, red, 3, 10*time.Millisecond))
+
+		p := (<-mockClient.sends).Payload.(*packets.LightSetWaveform)
+		assert.True(t, p.Transient)
+		assert.Equal(t, red.ToDeviceColor(), p.Color)
+		assert.Equal(t, uint32(10), p.Period)
+		assert.Equal(t, float32(3), p.Cycles)
+	})
+
+	t.Run("Restores state when cancelled", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn, d.Color = true, white
+		ctrl, mockClient := newController(t, d)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := ctrl.FlashCtx(ctx, serial0, red, 1, time.Hour)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Equal(t, payloadTypes(nil, &packets.LightSetWaveform{}, &packets.LightSetColor{}), payloadTypes(mockClient))
+	})
+
+	t.Run("Rejects switches", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.Type = device.DeviceTypeSwitch
+		ctrl, mockClient := newController(t, d)
+		assert.ErrorIs(t, ctrl.Flash(serial0, red, 1, time.Millisecond), ErrNotLight)
+		assert.Empty(t, mockClient.sends)
+	})
+
+	t.Run("Rejects invalid period", func(t *testing.T) {
+		ctrl, mockClient := newController(t, device.NewDevice(addr0, serial0))
+		assert.ErrorIs(t, ctrl.Flash(serial0, red, 1, 0), messages.ErrInvalidWaveform)
+		assert.Empty(t, mockClient.sends)
+	})
+
+	t.Run("Unknown device", func(t *testing.T) {
+		ctrl, _ := newController(t, device.NewDevice(addr0, serial0))
+		assert.Error(t, ctrl.Flash(device.Serial{9}, red, 1, time.Millisecond))
+	})
+}
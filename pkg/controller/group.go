@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// SetGroup moves the device to the group with the given label.
+// It joins the group of another known device with the same label, if any, otherwise it creates a new one.
+func (c *Controller) SetGroup(serial device.Serial, label string) error {
+	return c.moveDevice(serial, label,
+		func(d device.Device) (string, [16]byte) { return d.Group, d.GroupID },
+		messages.SetGroup, &packets.DeviceGetGroup{},
+	)
+}
+
+// SetLocation moves the device to the location with the given label.
+// It joins the location of another known device with the same label, if any, otherwise it creates a new one.
+func (c *Controller) SetLocation(serial device.Serial, label string) error {
+	return c.moveDevice(serial, label,
+		func(d device.Device) (string, [16]byte) { return d.Location, d.LocationID },
+		messages.SetLocation, &packets.DeviceGetLocation{},
+	)
+}
+
+// moveDevice sets the group or location of a device, as selected by membership, reusing the id of
+// an existing one with the same label. It then requests the new state to refresh the device.
+func (c *Controller) moveDevice(serial device.Serial, label string,
+	membership func(device.Device) (string, [16]byte),
+	build func(string, [16]byte, time.Time) (*protocol.Message, error),
+	get packets.Payload,
+) error {
+	s := c.session(serial)
+	if s == nil {
+		return fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+
+	id, found := [16]byte{}, false
+	for d := range c.Devices() {
+		if l, i := membership(d); l == label && i != [16]byte{} {
+			id, found = i, true
+			break
+		}
+	}
+	if !found {
+		id = messages.NewID()
+	}
+
+	msg, err := build(label, id, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.send(msg, protocol.NewMessage(get))
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGroupAndLocation(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		addr1   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 11)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		serial1 = device.Serial([8]byte{2, 0, 0, 0, 0, 0, 0, 0})
+		kitchen = [16]byte{1}
+		home    = [16]byte{2}
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	d0 := device.NewDevice(addr0, serial0)
+	d0.Group, d0.GroupID, d0.Location, d0.LocationID = "Kitchen", kitchen, "Home", home
+	d1 := device.NewDevice(addr1, serial1)
+	d1.Group, d1.GroupID = "Bedroom", [16]byte{3}
+
+	// Do not use newDeviceSession to prevent running state update goroutine.
+	for _, d := range []*device.Device{d0, d1} {
+		ctrl.sessions[d.Serial] = &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+	}
+
+	t.Run("Joins an existing group", func(t *testing.T) {
+		require.NoError(t, ctrl.SetGroup(serial1, "Kitchen"))
+
+		msg := <-mockClient.sends
+		assert.Equal(t, serial1, device.Serial(msg.Target()))
+		p := msg.Payload.(*packets.DeviceSetGroup)
+		assert.Equal(t, kitchen, p.Group)
+		assert.Equal(t, "Kitchen", device.ParseLabel(p.Label))
+		assert.InDelta(t, time.Now().UnixNano(), int64(p.UpdatedAt), float64(time.Second))
+		assert.IsType(t, &packets.DeviceGetGroup{}, (<-mockClient.sends).Payload)
+	})
+
+	t.Run("Creates a new group", func(t *testing.T) {
+		require.NoError(t, ctrl.SetGroup(serial1, "Office"))
+
+		p := (<-mockClient.sends).Payload.(*packets.DeviceSetGroup)
+		assert.NotEqual(t, [16]byte{}, p.Group)
+		assert.NotEqual(t, kitchen, p.Group)
+		assert.Equal(t, "Office", device.ParseLabel(p.Label))
+		<-mockClient.sends
+	})
+
+	t.Run("Joins an existing location", func(t *testing.T) {
+		require.NoError(t, ctrl.SetLocation(serial1, "Home"))
+
+		p := (<-mockClient.sends).Payload.(*packets.DeviceSetLocation)
+		assert.Equal(t, home, p.Location)
+		assert.Equal(t, "Home", device.ParseLabel(p.Label))
+		assert.IsType(t, &packets.DeviceGetLocation{}, (<-mockClient.sends).Payload)
+	})
+
+	t.Run("Rejects invalid labels", func(t *testing.T) {
+		assert.ErrorIs(t, ctrl.SetLocation(serial1, ""), messages.ErrInvalidLabel)
+		assert.Empty(t, mockClient.sends)
+	})
+
+	t.Run("Unknown device", func(t *testing.T) {
+		assert.Error(t, ctrl.SetGroup(device.Serial{9}, "Kitchen"))
+	})
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// StateHandler updates the device state from a received payload and reports
+// whether the state changed.
+type StateHandler func(d *device.Device, p packets.Payload) (updated bool)
+
+// RegisterStateHandler registers h to update device state when a message of the given
+// payload type is received, replacing the built-in handling of that type, if any.
+// Handlers can extend the built-in handling by calling DefaultStateHandler.
+// A nil handler restores the built-in handling.
+//
+// Events are emitted for the label, power, color, matrix, multizone and effect state changes made by the handler,
+// and power and color changes are checked for external modifications, as with the built-in handling.
+//
+// Handlers run while the device session holds its lock, so they must not block or
+// call back into the Controller.
+// Payload types unknown to lifxprotocol-go must also be registered in packets.Payloads
+// for messages to be decoded.
+func (c *Controller) RegisterStateHandler(payloadType uint16, h StateHandler) {
+	c.cfg.stateHandlers.set(payloadType, h)
+}
+
+// DefaultStateHandler updates the device state from a received payload with the built-in
+// handling of its type, if any, and reports whether the state changed.
+func DefaultStateHandler(d *device.Device, p packets.Payload) (updated bool) {
+	_, updated, _ = applyState(d, p)
+	return updated
+}
+
+// applyCustomState updates the device state with the given handler and returns the state change
+// events by comparing the device before and after the update, and whether the state changed.
+func applyCustomState(d *device.Device, p packets.Payload, h StateHandler) (changes []EventType, updated bool) {
+	before := d.Clone()
+	if updated = h(d, p); !updated {
+		return nil, false
+	}
+
+	if before.Label != d.Label {
+		changes = append(changes, EventLabelChanged)
+	}
+	if before.Color != d.Color {
+		changes = append(changes, EventColorChanged)
+	}
+	if before.PoweredOn != d.PoweredOn || !slices.Equal(before.RelayProperties.Relays, d.RelayProperties.Relays) {
+		changes = append(changes, EventPowerChanged)
+	}
+	if !matrixPropertiesEqual(before.MatrixProperties, d.MatrixProperties) {
+		changes = append(changes, EventMatrixStateChanged)
+	}
+	if !slices.Equal(before.MultizoneProperties.Zones, d.MultizoneProperties.Zones) {
+		changes = append(changes, EventMultizoneStateChanged)
+	}
+	if !before.Effect.Equal(d.Effect) {
+		changes = append(changes, EventEffectChanged)
+	}
+	return changes, true
+}
+
+func matrixPropertiesEqual(a, b device.MatrixProperties) bool {
+	return a.Height == b.Height && a.Width == b.Width && a.NZones == b.NZones && a.ChainLength == b.ChainLength &&
+		slices.Equal(a.ChainOrientations, b.ChainOrientations) &&
+		slices.EqualFunc(a.ChainZones, b.ChainZones, slices.Equal)
+}
+
+// stateHandlers holds the user registered state handlers by payload type.
+type stateHandlers struct {
+	mu       sync.RWMutex
+	handlers map[uint16]StateHandler
+}
+
+func newStateHandlers() *stateHandlers {
+	return &stateHandlers{handlers: make(map[uint16]StateHandler)}
+}
+
+func (h *stateHandlers) set(payloadType uint16, handler StateHandler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if handler == nil {
+		delete(h.handlers, payloadType)
+		return
+	}
+	h.handlers[payloadType] = handler
+}
+
+// get returns the handler for the given payload type, if any.
+// It is safe to call on a nil stateHandlers.
+func (h *stateHandlers) get(payloadType uint16) StateHandler {
+	if h == nil {
+		return nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.handlers[payloadType]
+}
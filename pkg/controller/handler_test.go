@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterStateHandler(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	ctrl.RegisterStateHandler(uint16(packets.PayloadTypeDeviceStateLabel), func(d *device.Device, p packets.Payload) bool {
+		d.Label = "custom " + device.ParseLabel(p.(*packets.DeviceStateLabel).Label)
+		return true
+	})
+	ctrl.addSession(addr0, serial0)
+
+	sendLabel := func(label string) {
+		var l [32]byte
+		copy(l[:], label)
+		msg := protocol.NewMessage(&packets.DeviceStateLabel{Label: l})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	sendLabel("Lify")
+	d := ctrl.GetDevices()[0]
+	assert.Equal(t, "custom Lify", d.Label)
+	assert.False(t, d.LastUpdatedAt.IsZero())
+
+	// Restores built-in handling.
+	ctrl.RegisterStateHandler(uint16(packets.PayloadTypeDeviceStateLabel), nil)
+	sendLabel("Lify")
+	assert.Equal(t, "Lify", ctrl.GetDevices()[0].Label)
+}
+
+func TestRegisterStateHandlerEvents(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	var handled int
+	ctrl.RegisterStateHandler(uint16(packets.PayloadTypeLightState), func(d *device.Device, p packets.Payload) bool {
+		handled++
+		return DefaultStateHandler(d, p)
+	})
+	ch, cancel := ctrl.Subscribe(EventFilter{Serials: []device.Serial{serial0}})
+	defer cancel()
+	ctrl.addSession(addr0, serial0)
+
+	receive := func(brightness uint16) []EventType {
+		msg := protocol.NewMessage(&packets.LightState{Color: packets.LightHsbk{Brightness: brightness}, Power: 65535})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+
+		var got []EventType
+		timeout := time.After(20 * time.Millisecond)
+		for {
+			select {
+			case e := <-ch:
+				if e.Type != EventDeviceDiscovered {
+					got = append(got, e.Type)
+				}
+			case <-timeout:
+				return got
+			}
+		}
+	}
+
+	assert.Equal(t, []EventType{EventColorChanged, EventPowerChanged}, receive(1000))
+	// No command was sent by the controller.
+	assert.Equal(t, []EventType{EventColorChanged, EventExternallyModified}, receive(2000))
+	assert.Empty(t, receive(2000))
+
+	d := ctrl.GetDevices()[0]
+	assert.Equal(t, 3, handled)
+	assert.True(t, d.ExternallyModified())
+	assert.Equal(t, device.NewColor(packets.LightHsbk{Brightness: 2000}), d.Color)
+}
+
+func TestStateHandlers(t *testing.T) {
+	var h *stateHandlers
+	assert.Nil(t, h.get(1))
+
+	h = newStateHandlers()
+	h.set(1, func(*device.Device, packets.Payload) bool { return true })
+	assert.NotNil(t, h.get(1))
+	assert.Nil(t, h.get(2))
+
+	h.set(1, nil)
+	assert.Nil(t, h.get(1))
+}
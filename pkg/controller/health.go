@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// queryTimeout is the time after which a query without response is considered lost.
+const queryTimeout = 2 * time.Second
+
+// isQuery reports whether a payload is a Get message, which devices always answer with a state.
+func isQuery(p packets.Payload) bool {
+	switch p.(type) {
+	case *packets.DeviceGetService, *packets.DeviceGetHostFirmware, *packets.DeviceGetWifiInfo,
+		*packets.DeviceGetWifiFirmware, *packets.DeviceGetPower, *packets.DeviceGetLabel,
+		*packets.DeviceGetVersion, *packets.DeviceGetInfo, *packets.DeviceGetLocation, *packets.DeviceGetGroup,
+		*packets.LightGet, *packets.LightGetPower, *packets.LightGetInfrared, *packets.LightGetHevCycle,
+		*packets.LightGetHevCycleConfiguration, *packets.LightGetLastHevCycleResult,
+		*packets.MultiZoneGetColorZones, *packets.MultiZoneExtendedGetColorZones, *packets.MultiZoneGetEffect,
+		*packets.TileGetDeviceChain, *packets.TileGet64, *packets.TileGetEffect,
+		*packets.RelayGetPower, *packets.ButtonGet, *packets.ButtonGetConfig:
+		return true
+	}
+	return false
+}
+
+// updateHealth updates the device health with a received message.
+// It must be called while holding the session lock.
+func (s *deviceSession) updateHealth(msg *protocol.Message, now time.Time, rtt time.Duration, query, measured bool) {
+	if measured {
+		s.device.Health.AddRTT(rtt)
+		if query {
+			s.device.Health.AddQueryResult(false)
+		}
+	}
+	if _, ok := msg.Payload.(*packets.DeviceAcknowledgement); ok {
+		s.device.Health.LastAckAt = now
+	}
+}
+
+// checkLostQueries counts the queries sent more than queryTimeout before now that got no
+// response as lost in the device health.
+func (s *deviceSession) checkLostQueries(now time.Time) {
+	deadline := now.Add(-queryTimeout).UnixNano()
+	var lost int
+	for i := range s.sentAt {
+		sent := s.sentAt[i].Load()
+		if sent == 0 || sent > deadline || !s.queries[i].Load() {
+			continue
+		}
+		if s.sentAt[i].CompareAndSwap(sent, 0) {
+			lost++
+		}
+	}
+	if lost == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for range lost {
+		s.device.Health.AddQueryResult(true)
+	}
+}
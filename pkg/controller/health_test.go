@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsQuery(t *testing.T) {
+	tests := map[string]struct {
+		payload packets.Payload
+		want    bool
+	}{
+		"Light get":      {&packets.LightGet{}, true},
+		"Tile get":       {&packets.TileGet64{}, true},
+		"Multizone get":  {&packets.MultiZoneExtendedGetColorZones{}, true},
+		"Button get":     {&packets.ButtonGet{}, true},
+		"Relay get":      {&packets.RelayGetPower{}, true},
+		"Set color":      {&packets.LightSetColor{}, false},
+		"State":          {&packets.LightState{}, false},
+		"Acknowledgment": {&packets.DeviceAcknowledgement{}, false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isQuery(tt.payload))
+		})
+	}
+}
+
+func TestSessionHealth(t *testing.T) {
+	s := &deviceSession{
+		sender:  newMockClient(),
+		logger:  discardLogger(),
+		device:  device.NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}, device.Serial{1}),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+	}
+	go s.recvloop()
+	defer s.close()
+
+	health := func() device.Health {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.device.Health
+	}
+	reply := func(seq uint8, p packets.Payload) {
+		msg := protocol.NewMessage(p)
+		msg.SetSequence(seq)
+		s.inbound <- msg
+	}
+
+	t.Run("Measures round trip times", func(t *testing.T) {
+		s.recordSent(1, time.Now().Add(-50*time.Millisecond), true)
+		reply(1, &packets.LightState{})
+		assert.Eventually(t, func() bool { return health().RTT >= 50*time.Millisecond }, time.Second, time.Millisecond)
+		assert.Zero(t, health().LossRate)
+	})
+
+	t.Run("Records acknowledgements", func(t *testing.T) {
+		s.recordSent(2, time.Now(), false)
+		reply(2, &packets.DeviceAcknowledgement{})
+		assert.Eventually(t, func() bool { return !health().LastAckAt.IsZero() }, time.Second, time.Millisecond)
+	})
+
+	t.Run("Estimates lost queries", func(t *testing.T) {
+		now := time.Now()
+		s.recordSent(3, now.Add(-3*time.Second), true)
+		s.recordSent(4, now.Add(-3*time.Second), false)
+		s.recordSent(5, now, true)
+		s.checkLostQueries(now)
+		assert.InDelta(t, 0.1, health().LossRate, 1e-9)
+
+		// Late responses are not measured.
+		rtt := health().RTT
+		reply(3, &packets.LightState{})
+		// The inbound channel is unbuffered, so the previous message is processed once the next is received.
+		reply(0, &packets.DeviceStateService{})
+		assert.Equal(t, rtt, health().RTT)
+		assert.InDelta(t, 0.1, health().LossRate, 1e-9)
+	})
+}
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// InboundOverflowPolicy selects what happens to a received message when the inbound queue of its
+// device session is full, see WithInboundOverflowPolicy.
+type InboundOverflowPolicy int
+
+const (
+	// InboundDropNewest drops the received message. It is the default.
+	InboundDropNewest InboundOverflowPolicy = iota
+	// InboundDropOldest drops the oldest queued message to make room for the received one,
+	// so that the latest state reported by the device is processed.
+	InboundDropOldest
+	// InboundBlock waits up to the block timeout for room in the queue and drops the received
+	// message afterwards. The messages of all devices are delayed while waiting.
+	InboundBlock
+)
+
+// String converts an InboundOverflowPolicy into a string.
+func (p InboundOverflowPolicy) String() string {
+	switch p {
+	case InboundDropNewest:
+		return "drop_newest"
+	case InboundDropOldest:
+		return "drop_oldest"
+	case InboundBlock:
+		return "block"
+	}
+	return ""
+}
+
+// inboundSize returns the size of the session inbound queues.
+func (c *Config) inboundSize() int {
+	if c.inboundQueueSize > 0 {
+		return c.inboundQueueSize
+	}
+	return defaultRecvBufferSize
+}
+
+// deliver queues a received message to be processed by the session, applying the inbound
+// overflow policy if the queue is full.
+func (s *deviceSession) deliver(msg *protocol.Message) {
+	select {
+	case s.inbound <- msg:
+		return
+	default:
+	}
+
+	var (
+		policy       InboundOverflowPolicy
+		blockTimeout time.Duration
+	)
+	if s.cfg != nil {
+		policy, blockTimeout = s.cfg.inboundOverflow, s.cfg.inboundBlockTimeout
+	}
+	switch policy {
+	case InboundDropOldest:
+		// The queue is consumed concurrently, so retry until the message is queued.
+		for {
+			select {
+			case old := <-s.inbound:
+				s.dropInbound(old)
+			default:
+			}
+			select {
+			case s.inbound <- msg:
+				return
+			default:
+			}
+		}
+	case InboundBlock:
+		timer := time.NewTimer(blockTimeout)
+		defer timer.Stop()
+		select {
+		case s.inbound <- msg:
+			return
+		case <-s.done:
+			return
+		case <-timer.C:
+		}
+	}
+	s.dropInbound(msg)
+}
+
+// dropInbound records a received message dropped because the inbound queue is full.
+func (s *deviceSession) dropInbound(msg *protocol.Message) {
+	s.inboundDropped.Add(1)
+	s.metrics().MessageDropped(DropInboundFull)
+	s.logger.Warn("Inbound queue full, dropping message", "payload", msg.Payload.PayloadType())
+}
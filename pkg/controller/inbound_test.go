@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliver(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	newMsg := func(seq uint8) *protocol.Message {
+		msg := protocol.NewMessage(&packets.LightState{})
+		msg.SetSequence(seq)
+		return msg
+	}
+
+	tests := map[string]struct {
+		policy       InboundOverflowPolicy
+		blockTimeout time.Duration
+		// consume, if set, processes a queued message while delivering.
+		consume  bool
+		wantSeqs []uint8
+	}{
+		"drop newest": {
+			policy:   InboundDropNewest,
+			wantSeqs: []uint8{0, 1},
+		},
+		"drop oldest": {
+			policy:   InboundDropOldest,
+			wantSeqs: []uint8{1, 2},
+		},
+		"block times out": {
+			policy:       InboundBlock,
+			blockTimeout: 10 * time.Millisecond,
+			wantSeqs:     []uint8{0, 1},
+		},
+		"block waits for room": {
+			policy:       InboundBlock,
+			blockTimeout: time.Second,
+			consume:      true,
+			wantSeqs:     []uint8{1, 2},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			recorder := newFakeRecorder()
+			cfg := &Config{inboundQueueSize: 2, inboundOverflow: tt.policy, inboundBlockTimeout: tt.blockTimeout, metrics: recorder}
+			s := &deviceSession{
+				logger:  discardLogger(),
+				device:  device.NewDevice(addr0, serial0),
+				inbound: make(chan *protocol.Message, cfg.inboundSize()),
+				done:    make(chan struct{}),
+				cfg:     cfg,
+			}
+			s.deliver(newMsg(0))
+			s.deliver(newMsg(1))
+			if tt.consume {
+				time.AfterFunc(10*time.Millisecond, func() { <-s.inbound })
+			}
+			s.deliver(newMsg(2))
+
+			require.Len(t, s.inbound, len(tt.wantSeqs))
+			var gotSeqs []uint8
+			for range len(tt.wantSeqs) {
+				gotSeqs = append(gotSeqs, (<-s.inbound).Sequence())
+			}
+			assert.Equal(t, tt.wantSeqs, gotSeqs)
+
+			wantDropped := 1
+			if tt.consume {
+				wantDropped = 0
+			}
+			assert.Equal(t, uint64(wantDropped), s.status(time.Now()).InboundDropped)
+			assert.Equal(t, wantDropped, recorder.dropped[DropInboundFull])
+		})
+	}
+}
+
+func TestInboundOptions(t *testing.T) {
+	tests := map[string]struct {
+		opt     Option
+		wantErr bool
+	}{
+		"queue size":              {opt: WithInboundQueueSize(64)},
+		"invalid queue size":      {opt: WithInboundQueueSize(0), wantErr: true},
+		"drop oldest":             {opt: WithInboundOverflowPolicy(InboundDropOldest, 0)},
+		"block":                   {opt: WithInboundOverflowPolicy(InboundBlock, time.Millisecond)},
+		"block without timeout":   {opt: WithInboundOverflowPolicy(InboundBlock, 0), wantErr: true},
+		"unknown overflow policy": {opt: WithInboundOverflowPolicy(InboundOverflowPolicy(9), 0), wantErr: true},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := tt.opt(&Controller{cfg: &Config{}})
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
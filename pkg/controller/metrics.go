@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// DropReason describes why a message was dropped.
+type DropReason string
+
+const (
+	// DropInboundFull is reported when a received message is dropped because the session inbound queue is full.
+	DropInboundFull DropReason = "inbound_full"
+	// DropRateLimited is reported when an outbound message is dropped by the rate limiter.
+	DropRateLimited DropReason = "rate_limited"
+	// DropDuplicate is reported when a received message is dropped as a duplicate, see WithDuplicateWindow.
+	DropDuplicate DropReason = "duplicate"
+)
+
+// MetricsRecorder receives the Controller instrumentation, e.g. to export it to a monitoring system.
+// Methods are called synchronously from the Controller goroutines, so they must be safe for
+// concurrent use and must not block.
+type MetricsRecorder interface {
+	// MessageSent is called for each message sent, with its payload type.
+	MessageSent(payloadType uint16)
+	// MessageReceived is called for each message received, with its payload type.
+	MessageReceived(payloadType uint16)
+	// MessageDropped is called for each message dropped, with the reason why.
+	MessageDropped(reason DropReason)
+	// DecodeError is called for each received packet that could not be decoded.
+	// It is only reported when the Controller creates its own client.
+	DecodeError()
+	// Sessions is called with the number of device sessions whenever it changes.
+	Sessions(n int)
+	// DiscoveryRound is called each time discovery packets are sent.
+	DiscoveryRound()
+	// DiscoveryResponse is called for each discovery response, with the time since the last discovery round.
+	DiscoveryResponse(rtt time.Duration)
+	// DeviceRTT is called with the time between a message sent to a device and its response.
+	DeviceRTT(serial device.Serial, rtt time.Duration)
+}
+
+// nopRecorder is the MetricsRecorder used when no metrics are configured.
+type nopRecorder struct{}
+
+func (nopRecorder) MessageSent(uint16)                     {}
+func (nopRecorder) MessageReceived(uint16)                 {}
+func (nopRecorder) MessageDropped(DropReason)              {}
+func (nopRecorder) DecodeError()                           {}
+func (nopRecorder) Sessions(int)                           {}
+func (nopRecorder) DiscoveryRound()                        {}
+func (nopRecorder) DiscoveryResponse(time.Duration)        {}
+func (nopRecorder) DeviceRTT(device.Serial, time.Duration) {}
+
+// metrics returns the configured MetricsRecorder, which is a no-op if none is configured.
+func (c *Controller) metrics() MetricsRecorder {
+	if c.cfg == nil || c.cfg.metrics == nil {
+		return nopRecorder{}
+	}
+	return c.cfg.metrics
+}
+
+// metrics returns the MetricsRecorder of the session, which is a no-op if none is configured.
+func (s *deviceSession) metrics() MetricsRecorder {
+	if s.cfg == nil || s.cfg.metrics == nil {
+		return nopRecorder{}
+	}
+	return s.cfg.metrics
+}
+
+// recordSent records the time a message with the given sequence is sent to the device,
+// to measure the round trip time of its response, and whether it is a query expecting one.
+func (s *deviceSession) recordSent(seq uint8, now time.Time, query bool) {
+	s.queries[seq].Store(query)
+	s.sentAt[seq].Store(now.UnixNano())
+}
+
+// recordResponse reports the round trip time of a message received from the device,
+// if it is the first response to a message sent by the session. It returns the round trip
+// time, whether the message answered a query and whether it was measured.
+func (s *deviceSession) recordResponse(seq uint8, now time.Time) (rtt time.Duration, query, ok bool) {
+	sent := s.sentAt[seq].Swap(0)
+	if sent == 0 {
+		return 0, false, false
+	}
+	rtt = now.Sub(time.Unix(0, sent))
+	s.metrics().DeviceRTT(s.device.Serial, rtt)
+	return rtt, s.queries[seq].Load(), true
+}
+
+// sessionsChanged reports the current number of sessions.
+func (c *Controller) sessionsChanged() {
+	c.mu.RLock()
+	n := len(c.sessions)
+	c.mu.RUnlock()
+	c.metrics().Sessions(n)
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRecorder is a MetricsRecorder counting the calls it receives.
+type fakeRecorder struct {
+	mu                 sync.Mutex
+	sent, received     map[uint16]int
+	dropped            map[DropReason]int
+	sessions           int
+	discoveryRounds    int
+	discoveryResponses int
+	rtts               map[device.Serial]int
+}
+
+func newFakeRecorder() *fakeRecorder {
+	return &fakeRecorder{
+		sent:     make(map[uint16]int),
+		received: make(map[uint16]int),
+		dropped:  make(map[DropReason]int),
+		rtts:     make(map[device.Serial]int),
+	}
+}
+
+func (r *fakeRecorder) MessageSent(t uint16)             { r.do(func() { r.sent[t]++ }) }
+func (r *fakeRecorder) MessageReceived(t uint16)         { r.do(func() { r.received[t]++ }) }
+func (r *fakeRecorder) MessageDropped(reason DropReason) { r.do(func() { r.dropped[reason]++ }) }
+func (r *fakeRecorder) DecodeError()                     {}
+func (r *fakeRecorder) Sessions(n int)                   { r.do(func() { r.sessions = n }) }
+func (r *fakeRecorder) DiscoveryRound()                  { r.do(func() { r.discoveryRounds++ }) }
+func (r *fakeRecorder) DiscoveryResponse(time.Duration)  { r.do(func() { r.discoveryResponses++ }) }
+func (r *fakeRecorder) DeviceRTT(serial device.Serial, _ time.Duration) {
+	r.do(func() { r.rtts[serial]++ })
+}
+
+func (r *fakeRecorder) do(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f()
+}
+
+// get returns the value returned by f while holding the recorder lock.
+func get[T any](r *fakeRecorder, f func() T) T {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return f()
+}
+
+func TestMetrics(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	rec := newFakeRecorder()
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour), WithMetrics(rec))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	getService := uint16(packets.PayloadTypeDeviceGetService)
+	assert.Equal(t, 1, get(rec, func() int { return rec.discoveryRounds }))
+	assert.Equal(t, 1, get(rec, func() int { return rec.sent[getService] }))
+
+	t.Run("Records discovered sessions", func(t *testing.T) {
+		msg := protocol.NewMessage(&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP})
+		msg.SetTarget(serial0)
+		mockClient.inbound <- recvMsg{msg: msg, addr: addr0}
+
+		assert.Eventually(t, func() bool { return get(rec, func() int { return rec.sessions }) == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, 1, get(rec, func() int { return rec.discoveryResponses }))
+		assert.Equal(t, 1, get(rec, func() int { return rec.received[uint16(packets.PayloadTypeDeviceStateService)] }))
+	})
+
+	t.Run("Records device round trip times", func(t *testing.T) {
+		// The session sends the preflight handshake messages on start.
+		sent := <-mockClient.sends
+		assert.Eventually(t, func() bool { return get(rec, func() int { return rec.sent[sent.Type()] }) > 0 }, time.Second, time.Millisecond)
+
+		reply := protocol.NewMessage(&packets.DeviceStateLabel{})
+		reply.SetTarget(serial0)
+		reply.SetSequence(sent.Sequence())
+		mockClient.inbound <- recvMsg{msg: reply, addr: addr0}
+		assert.Eventually(t, func() bool { return get(rec, func() int { return rec.rtts[serial0] }) == 1 }, time.Second, time.Millisecond)
+
+		// Only the first response to a message is measured.
+		mockClient.inbound <- recvMsg{msg: reply, addr: addr0}
+		assert.Eventually(t, func() bool {
+			return get(rec, func() int { return rec.received[uint16(packets.PayloadTypeDeviceStateLabel)] }) == 2
+		}, time.Second, time.Millisecond)
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, 1, get(rec, func() int { return rec.rtts[serial0] }))
+	})
+
+	t.Run("Records terminated sessions", func(t *testing.T) {
+		ctrl.terminateSession(serial0)
+		assert.Equal(t, 0, get(rec, func() int { return rec.sessions }))
+	})
+}
+
+func TestMetricsRateLimited(t *testing.T) {
+	rec := newFakeRecorder()
+	s := &deviceSession{
+		sender:  newMockClient(),
+		logger:  discardLogger(),
+		device:  device.NewDevice(&net.UDPAddr{}, device.Serial{1}),
+		done:    make(chan struct{}),
+		cfg:     &Config{metrics: rec},
+		limiter: newRateLimiter(1, 1, time.Millisecond),
+	}
+
+	require.NoError(t, s.send(protocol.NewMessage(&packets.LightGet{})))
+	assert.ErrorIs(t, s.send(protocol.NewMessage(&packets.LightGet{})), ErrRateLimited)
+	assert.Equal(t, 1, rec.dropped[DropRateLimited])
+	assert.Equal(t, 1, rec.sent[uint16(packets.PayloadTypeLightGet)])
+}
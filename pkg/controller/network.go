@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// NetworkReport summarizes the WiFi signal of the devices of a Controller, to help finding
+// the weak spots of a network.
+type NetworkReport struct {
+	// Devices holds the devices that reported their signal, from the weakest to the strongest.
+	Devices []DeviceSignal
+	// Qualities counts the devices by signal description, e.g. device.SignalPoor.
+	Qualities map[string]int
+	// Unreported is the number of devices that have not reported their signal yet.
+	Unreported int
+}
+
+// DeviceSignal is the WiFi signal of a device.
+type DeviceSignal struct {
+	Serial   device.Serial
+	Label    string
+	Location string
+	Group    string
+	// RSSI is the last signal reported, and Quality its description.
+	RSSI    device.WifiRSSI
+	Quality string
+	// MinRSSI and MaxRSSI are the weakest and strongest samples in the signal history.
+	MinRSSI, MaxRSSI    device.WifiRSSI
+	WifiFirmwareVersion string
+	// LossRate is the estimated fraction of queries the device did not answer.
+	LossRate float64
+}
+
+// NetworkReport returns the WiFi signal of all the devices that have a session.
+func (c *Controller) NetworkReport() NetworkReport {
+	r := NetworkReport{Qualities: make(map[string]int)}
+	for d := range c.Devices() {
+		h := d.WifiInfo.SignalHistory
+		if len(h) == 0 {
+			r.Unreported++
+			continue
+		}
+		s := DeviceSignal{
+			Serial:              d.Serial,
+			Label:               d.Label,
+			Location:            d.Location,
+			Group:               d.Group,
+			RSSI:                d.WifiRSSI,
+			Quality:             d.WifiRSSI.String(),
+			MinRSSI:             slices.MinFunc(h, compareSignal),
+			MaxRSSI:             slices.MaxFunc(h, compareSignal),
+			WifiFirmwareVersion: d.WifiInfo.FirmwareVersion,
+			LossRate:            d.Health.LossRate,
+		}
+		r.Devices = append(r.Devices, s)
+		r.Qualities[s.Quality]++
+	}
+
+	slices.SortFunc(r.Devices, func(a, b DeviceSignal) int {
+		return cmp.Or(compareSignal(a.RSSI, b.RSSI), cmp.Compare(a.Label, b.Label))
+	})
+	return r
+}
+
+// compareSignal compares signals by quality, and by value if they have the same quality.
+// Values are only compared within the same quality, as they are either RSSI or SNR.
+func compareSignal(a, b device.WifiRSSI) int {
+	return cmp.Or(cmp.Compare(a.SignalQuality(), b.SignalQuality()), cmp.Compare(a, b))
+}
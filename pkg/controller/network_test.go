@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerNetworkReport(t *testing.T) {
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	for i, history := range [][]device.WifiRSSI{
+		{-45, -55},
+		{-60, -75},
+		nil,
+		{-40},
+	} {
+		serial := device.Serial([8]byte{byte(i + 1)})
+		d := device.NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, byte(i+1))}, serial)
+		d.Label = string(rune('A' + i))
+		d.WifiInfo = device.WifiInfo{FirmwareVersion: "1.2", SignalHistory: history}
+		if len(history) > 0 {
+			d.WifiRSSI = history[len(history)-1]
+		}
+		ctrl.sessions[serial] = &deviceSession{sender: mockClient, logger: discardLogger(), device: d, done: make(chan struct{})}
+	}
+
+	r := ctrl.NetworkReport()
+	assert.Equal(t, 1, r.Unreported)
+	assert.Equal(t, map[string]int{device.SignalPoor: 1, device.SignalGood: 1, device.SignalExcellent: 1}, r.Qualities)
+	require.Len(t, r.Devices, 3)
+	assert.Equal(t, DeviceSignal{
+		Serial: device.Serial([8]byte{2}), Label: "B",
+		RSSI: -75, Quality: device.SignalPoor, MinRSSI: -75, MaxRSSI: -60,
+		WifiFirmwareVersion: "1.2",
+	}, r.Devices[0])
+	assert.Equal(t, "A", r.Devices[1].Label)
+	assert.Equal(t, "D", r.Devices[2].Label)
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// isOffline reports whether the device is marked offline, see WithOfflineDevices.
+func (s *deviceSession) isOffline() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.device.Offline
+}
+
+// markOffline marks the device offline, keeping its session, and emits EventDeviceOffline.
+// The device is marked online again by the next message received from it.
+func (s *deviceSession) markOffline() {
+	s.mu.Lock()
+	s.device.Offline = true
+	s.device.StateVersion = s.cfg.nextStateVersion()
+	var events []Event
+	if s.events != nil {
+		events = s.newEvents(EventDeviceOffline)
+	}
+	s.mu.Unlock()
+
+	s.events.publish(events...)
+}
+
+// markedOnline wakes the run loop of a device seen again after being marked offline,
+// for its state to be refreshed right away.
+func (s *deviceSession) markedOnline() {
+	s.logger.Info("Device seen again, marking it online")
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// probe sends an echo request to a device marked offline, which marks it online if it responds.
+func (s *deviceSession) probe() {
+	if err := s.send(protocol.NewMessage(&packets.DeviceEchoRequest{})); err != nil {
+		s.logger.Debug("Failed to probe offline device", "error", err)
+	}
+}
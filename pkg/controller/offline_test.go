@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOfflineDevices(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour), WithOfflineDevices())
+	require.NoError(t, err)
+	defer ctrl.Close()
+	ctrl.cfg.preflightHandshakeTimeout = time.Millisecond
+	ctrl.cfg.preflightHandshakeWait = time.Millisecond
+	ctrl.cfg.deviceLivenessTimeout = 20 * time.Millisecond
+
+	events, cancel := ctrl.Subscribe(EventFilter{Types: []EventType{EventDeviceOffline, EventDeviceOnline}})
+	defer cancel()
+
+	ctrl.addSession(addr0, serial0)
+	nextEvent := func() Event {
+		select {
+		case e := <-events:
+			return e
+		case <-time.After(time.Second):
+			t.Fatal("no event received")
+			return Event{}
+		}
+	}
+
+	e := nextEvent()
+	assert.Equal(t, EventDeviceOffline, e.Type)
+	assert.True(t, e.Device.Offline)
+	d, ok := ctrl.GetDevice(serial0)
+	require.True(t, ok, "offline devices are kept")
+	assert.True(t, d.Offline)
+
+	// Offline devices are probed until they respond.
+	assert.Eventually(t, func() bool {
+		for {
+			select {
+			case msg := <-mockClient.sends:
+				if _, ok := msg.Payload.(*packets.DeviceEchoRequest); ok {
+					return true
+				}
+			default:
+				return false
+			}
+		}
+	}, time.Second, time.Millisecond)
+
+	msg := protocol.NewMessage(&packets.DeviceEchoResponse{})
+	msg.SetTarget(serial0)
+	mockClient.inbound <- recvMsg{addr: addr0, msg: msg}
+
+	e = nextEvent()
+	assert.Equal(t, EventDeviceOnline, e.Type)
+	assert.False(t, e.Device.Offline)
+}
+
+func TestDeviceLivenessTimeout(t *testing.T) {
+	tests := map[string]struct {
+		opts    []Option
+		want    time.Duration
+		wantErr bool
+	}{
+		"derived from the refresh periods": {
+			want: 50 * time.Second,
+		},
+		"configured": {
+			opts: []Option{WithDeviceLivenessTimeout(5 * time.Second)},
+			want: 5 * time.Second,
+		},
+		"invalid": {
+			opts:    []Option{WithDeviceLivenessTimeout(0)},
+			wantErr: true,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctrl, err := New(append(tt.opts, WithClient(newMockClient()), WithDiscoveryPeriod(time.Hour))...)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer ctrl.Close()
+			assert.Equal(t, tt.want, ctrl.cfg.deviceLivenessTimeout)
+		})
+	}
+}
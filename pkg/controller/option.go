@@ -1,9 +1,12 @@
 package controller
 
 import (
+	"fmt"
 	"io"
 	"log/slog"
 	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
 )
 
 // Option overrides configurable Controller's options.
@@ -68,3 +71,290 @@ func WithPreflightHandshakeTimeout(d time.Duration) Option {
 		return nil
 	}
 }
+
+// WithoutDiscovery disables the broadcast discovery and subnet sweeps run when the Controller
+// starts and periodically, e.g. for applications that only route messages to the devices they
+// provision with WithStaticDevices or AddDevice. Static devices are probed once when the Controller
+// starts, and Discover can still be called explicitly.
+func WithoutDiscovery() Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.discoveryDisabled = true
+		return nil
+	}
+}
+
+// WithoutStatePolling disables the handshake and the periodic state queries sent by device sessions,
+// for applications that send their own queries. Device state is still updated from the messages
+// received, and since devices are not expected to reply, sessions are never terminated for
+// inactivity.
+func WithoutStatePolling() Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.statePollingDisabled = true
+		return nil
+	}
+}
+
+// WithStaticDevices provisions devices by their IP address, optionally followed by a port,
+// so that their sessions are created without relying on broadcast discovery.
+// See Controller.AddDevice.
+func WithStaticDevices(ips ...string) Option {
+	return func(ctrl *Controller) error {
+		for _, ip := range ips {
+			addr, err := parseDeviceAddr(ip)
+			if err != nil {
+				return err
+			}
+			ctrl.addStaticAddr(addr)
+		}
+		return nil
+	}
+}
+
+// WithDiscoverySubnet adds an IPv4 subnet in CIDR notation (e.g. "192.168.1.0/24") whose
+// host addresses are periodically probed with unicast discover packets, for networks
+// where UDP broadcast is filtered. Subnets must be /16 or smaller.
+// Each sweep sends one packet per host address every 5 minutes, paced as set by WithDiscoverySweepRate.
+// The option can be applied multiple times to sweep multiple subnets.
+func WithDiscoverySubnet(cidr string) Option {
+	return func(ctrl *Controller) error {
+		p, err := parseDiscoverySubnet(cidr)
+		if err != nil {
+			return err
+		}
+		ctrl.cfg.discoverySubnets = append(ctrl.cfg.discoverySubnets, p)
+		return nil
+	}
+}
+
+// WithDiscoverySweepRate sets the maximum number of discover packets sent per second by a subnet sweep.
+// Sweeps send one packet per host address, e.g. 65534 for a /16 subnet, every 5 minutes;
+// the default of 1000 packets per second sweeps a /24 subnet in about 0.25s and a /16 in about 65s.
+func WithDiscoverySweepRate(perSecond int) Option {
+	return func(ctrl *Controller) error {
+		if perSecond < 1 {
+			return fmt.Errorf("invalid discovery sweep rate %d", perSecond)
+		}
+		ctrl.cfg.subnetSweepRate = perSecond
+		return nil
+	}
+}
+
+// WithDiscoverySweepConcurrency sets the maximum number of concurrent sends of a subnet sweep.
+func WithDiscoverySweepConcurrency(n int) Option {
+	return func(ctrl *Controller) error {
+		if n < 1 {
+			return fmt.Errorf("invalid discovery sweep concurrency %d", n)
+		}
+		ctrl.cfg.subnetSweepConcurrency = n
+		return nil
+	}
+}
+
+// WithRateLimit limits the messages sent to each device to perSecond, allowing bursts
+// of up to burst messages. LIFX devices may drop packets beyond DefaultRateLimit messages
+// per second. Rate limiting is disabled by default.
+// Sends exceeding the rate are deferred, blocking the caller, for at most the maximum wait
+// (see WithRateLimitMaxWait), after which they are dropped and ErrRateLimited is returned.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(ctrl *Controller) error {
+		if perSecond <= 0 || burst < 1 {
+			return fmt.Errorf("invalid rate limit %v/s with burst %d", perSecond, burst)
+		}
+		ctrl.cfg.rateLimit = perSecond
+		ctrl.cfg.rateLimitBurst = burst
+		return nil
+	}
+}
+
+// WithGlobalRateLimit limits the messages sent to all devices to perSecond, allowing bursts of up to
+// burst messages, e.g. to avoid flooding the network with the polling of many devices.
+// It applies on top of WithRateLimit, deferring and dropping messages in the same way.
+// Discovery broadcasts are not limited.
+func WithGlobalRateLimit(perSecond float64, burst int) Option {
+	return func(ctrl *Controller) error {
+		if perSecond <= 0 || burst < 1 {
+			return fmt.Errorf("invalid global rate limit %v/s with burst %d", perSecond, burst)
+		}
+		ctrl.cfg.globalRateLimit = perSecond
+		ctrl.cfg.globalRateLimitBurst = burst
+		return nil
+	}
+}
+
+// WithRateLimitMaxWait sets the maximum time a rate limited send is deferred before being dropped.
+// A zero duration drops messages exceeding the rate without waiting.
+func WithRateLimitMaxWait(d time.Duration) Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.rateLimitMaxWait = d
+		return nil
+	}
+}
+
+// WithDeviceLivenessTimeout sets the time after which a device that has not been seen is considered
+// offline. By default it is five times the shorter of the state refresh periods, and at least 30s.
+// Shorter timeouts detect devices going offline sooner, at the risk of dropping devices whose
+// responses are delayed, e.g. by a weak WiFi signal.
+func WithDeviceLivenessTimeout(d time.Duration) Option {
+	return func(ctrl *Controller) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid device liveness timeout %v", d)
+		}
+		ctrl.cfg.livenessTimeout = d
+		return nil
+	}
+}
+
+// WithOfflineDevices keeps the devices that are not seen within the liveness timeout, marking them
+// Offline, instead of terminating their sessions, so that brief network hiccups do not make devices
+// disappear from GetDevices. Offline devices are not polled, but probed every half liveness timeout,
+// and are marked online as soon as they respond. EventDeviceOffline and EventDeviceOnline are emitted
+// when a device goes offline and comes back.
+func WithOfflineDevices() Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.keepOfflineDevices = true
+		return nil
+	}
+}
+
+// WithInboundQueueSize sets the number of received messages each device session queues to be
+// processed, 10 by default. Larger queues absorb the bursts of responses of devices with many zones,
+// e.g. the TileState64 of long matrix chains.
+func WithInboundQueueSize(n int) Option {
+	return func(ctrl *Controller) error {
+		if n < 1 {
+			return fmt.Errorf("invalid inbound queue size %d", n)
+		}
+		ctrl.cfg.inboundQueueSize = n
+		return nil
+	}
+}
+
+// WithInboundOverflowPolicy sets what happens to received messages when the inbound queue of
+// their device session is full, dropping the received message by default.
+// The block timeout is the maximum time InboundBlock waits for room in the queue, and is ignored
+// by the other policies. Dropped messages are counted in SessionStatus and reported to the
+// MetricsRecorder with DropInboundFull.
+func WithInboundOverflowPolicy(policy InboundOverflowPolicy, blockTimeout time.Duration) Option {
+	return func(ctrl *Controller) error {
+		switch {
+		case policy < InboundDropNewest || policy > InboundBlock:
+			return fmt.Errorf("invalid inbound overflow policy %d", policy)
+		case policy == InboundBlock && blockTimeout <= 0:
+			return fmt.Errorf("invalid inbound block timeout %v", blockTimeout)
+		}
+		ctrl.cfg.inboundOverflow = policy
+		ctrl.cfg.inboundBlockTimeout = blockTimeout
+		return nil
+	}
+}
+
+// WithDuplicateWindow drops the received messages identical to the previous one received from the
+// same device within the window, e.g. State packets resent by the device, so that they are not
+// processed again. Messages are identical if they have the same source, sequence, payload type and
+// payload. Dropped messages are reported to the MetricsRecorder with DropDuplicate.
+// By default, duplicates are processed.
+func WithDuplicateWindow(d time.Duration) Option {
+	return func(ctrl *Controller) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid duplicate window %v", d)
+		}
+		ctrl.cfg.duplicateWindow = d
+		return nil
+	}
+}
+
+// WithExternalChangeWindow sets the window after a state-changing command is sent within which
+// power and color changes are attributed to the Controller. Changes observed outside of it mark
+// the device as externally modified (see device.Device.ExternallyModified).
+// It defaults to twice the high frequency state refresh period, so that changes are reported
+// by the polling following the command, and should be longer than the transitions used.
+func WithExternalChangeWindow(d time.Duration) Option {
+	return func(ctrl *Controller) error {
+		if d <= 0 {
+			return fmt.Errorf("invalid external change window %v", d)
+		}
+		ctrl.cfg.externalChangeWindow = d
+		return nil
+	}
+}
+
+// WithDeviceStore sets the store used to persist known devices between runs.
+// Saved devices get a session on startup, before discovery completes, and devices are
+// saved periodically and when the Controller is closed.
+func WithDeviceStore(s DeviceStore) Option {
+	return func(ctrl *Controller) error {
+		ctrl.store = s
+		return nil
+	}
+}
+
+// WithDeviceCache persists known devices to a JSON file at path, see WithDeviceStore.
+func WithDeviceCache(path string) Option {
+	return func(ctrl *Controller) error {
+		if path == "" {
+			return fmt.Errorf("invalid device cache path")
+		}
+		ctrl.store = NewFileStore(path)
+		return nil
+	}
+}
+
+// WithMetrics sets the recorder receiving the Controller instrumentation, such as the messages
+// sent and received, dropped messages, sessions and round trip times.
+// See the metrics package for an implementation exposing them to Prometheus.
+func WithMetrics(r MetricsRecorder) Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.metrics = r
+		return nil
+	}
+}
+
+// WithPacketTap sets a tap receiving every message sent and received by the Controller, for debugging.
+// It is only applied when the Controller creates its own client. See client.NewJSONLTap for
+// an implementation writing a trace file.
+func WithPacketTap(tap client.PacketTap) Option {
+	return func(ctrl *Controller) error {
+		ctrl.cfg.packetTap = tap
+		return nil
+	}
+}
+
+// WithInterfaces restricts discovery broadcasts to the named network interfaces, e.g. to avoid
+// container or VPN interfaces on multi-homed hosts. By default, broadcasts are sent on all the
+// interfaces that are up and support broadcast.
+// It is only applied when the Controller creates its own client.
+func WithInterfaces(names ...string) Option {
+	return func(ctrl *Controller) error {
+		if len(names) == 0 {
+			return fmt.Errorf("no interfaces given")
+		}
+		ctrl.cfg.interfaces = names
+		return nil
+	}
+}
+
+// WithBindAddr sets the local address and port the Controller listens on, e.g. to bind a specific
+// interface in a container. See client.Config.BindAddr.
+// It is only applied when the Controller creates its own client.
+func WithBindAddr(addr string) Option {
+	return func(ctrl *Controller) error {
+		if addr == "" {
+			return fmt.Errorf("invalid bind address")
+		}
+		ctrl.cfg.bindAddr = addr
+		return nil
+	}
+}
+
+// WithDecodeWorkers decodes the received packets on n goroutines, so that bursts of replies from many
+// devices, e.g. to TileGet64, are not serialized behind decoding. See client.Config.DecodeWorkers.
+// It is only applied when the Controller creates its own client.
+func WithDecodeWorkers(n int) Option {
+	return func(ctrl *Controller) error {
+		if n <= 0 {
+			return fmt.Errorf("decode workers must be positive")
+		}
+		ctrl.cfg.decodeWorkers = n
+		return nil
+	}
+}
@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// isStateCommand returns whether the payload changes the power or color state of a device.
+func isStateCommand(p packets.Payload) bool {
+	switch p.(type) {
+	case *packets.DeviceSetPower, *packets.LightSetPower, *packets.LightSetColor,
+		*packets.LightSetWaveform, *packets.LightSetWaveformOptional,
+		*packets.MultiZoneSetColorZones, *packets.MultiZoneExtendedSetColorZones, *packets.MultiZoneSetEffect,
+		*packets.TileSet64, *packets.TileCopyFrameBuffer, *packets.TileSetEffect,
+		*packets.RelaySetPower:
+		return true
+	}
+	return false
+}
+
+// commandSent records that a state-changing command was sent to the device,
+// which resets any previously observed external modification.
+func (s *deviceSession) commandSent(now time.Time) {
+	s.lastCommandAt.Store(now.UnixNano())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.device.ExternallyModified() {
+		s.device.ExternallyModifiedAt = time.Time{}
+		s.device.StateVersion = s.cfg.nextStateVersion()
+	}
+}
+
+// isExternalChange returns whether the given state changes were not caused by a command
+// sent by the controller within the external change window.
+// It must be called while holding the session lock.
+func (s *deviceSession) isExternalChange(changes []EventType, now time.Time) bool {
+	if !slices.Contains(changes, EventPowerChanged) && !slices.Contains(changes, EventColorChanged) {
+		return false
+	}
+	last := s.lastCommandAt.Load()
+	return last == 0 || now.Sub(time.Unix(0, last)) > s.cfg.externalChangeWindow
+}
@@ -0,0 +1,113 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalModification(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		window  = time.Minute
+	)
+
+	newSession := func() (*deviceSession, <-chan Event) {
+		bus := newEventBus()
+		ch, _ := bus.subscribe(EventFilter{})
+		s := &deviceSession{
+			sender:  newMockClient(),
+			logger:  discardLogger(),
+			device:  device.NewDevice(addr0, serial0),
+			inbound: make(chan *protocol.Message),
+			done:    make(chan struct{}),
+			cfg:     &Config{externalChangeWindow: window},
+			events:  bus,
+		}
+		go s.recvloop()
+		t.Cleanup(s.close)
+		return s, ch
+	}
+
+	// receive delivers a LightState to the session and returns the events it caused.
+	receive := func(s *deviceSession, ch <-chan Event, brightness uint16) []EventType {
+		s.inbound <- protocol.NewMessage(&packets.LightState{Color: packets.LightHsbk{Brightness: brightness}, Power: 65535})
+		var got []EventType
+		for {
+			select {
+			case e := <-ch:
+				got = append(got, e.Type)
+			case <-time.After(10 * time.Millisecond):
+				return got
+			}
+		}
+	}
+
+	testCases := map[string]struct {
+		// commandAgo is how long before the change a command was sent, if set.
+		commandAgo   time.Duration
+		wantModified bool
+	}{
+		"Change without a command": {
+			wantModified: true,
+		},
+		"Change within the command window": {
+			commandAgo: window / 2,
+		},
+		"Change outside the command window": {
+			commandAgo:   2 * window,
+			wantModified: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			s, ch := newSession()
+
+			// The first state report after connecting is never an external change.
+			got := receive(s, ch, 1000)
+			assert.Equal(t, []EventType{EventColorChanged, EventPowerChanged}, got)
+			d := s.deviceSnapshot()
+			assert.False(t, d.ExternallyModified())
+
+			if tc.commandAgo > 0 {
+				s.commandSent(time.Now().Add(-tc.commandAgo))
+			}
+
+			got = receive(s, ch, 2000)
+			d = s.deviceSnapshot()
+			assert.Equal(t, tc.wantModified, d.ExternallyModified())
+			if tc.wantModified {
+				assert.Equal(t, []EventType{EventColorChanged, EventExternallyModified}, got)
+				assert.WithinDuration(t, time.Now(), d.ExternallyModifiedAt, time.Second)
+			} else {
+				assert.Equal(t, []EventType{EventColorChanged}, got)
+			}
+		})
+	}
+
+	t.Run("New command resets the flag", func(t *testing.T) {
+		s, ch := newSession()
+		receive(s, ch, 1000)
+		receive(s, ch, 2000)
+		d := s.deviceSnapshot()
+		require.True(t, d.ExternallyModified())
+
+		// Get messages do not reset the flag.
+		require.NoError(t, s.send(protocol.NewMessage(&packets.LightGet{})))
+		d = s.deviceSnapshot()
+		assert.True(t, d.ExternallyModified())
+
+		require.NoError(t, s.send(messages.SetPowerOn()))
+		d = s.deviceSnapshot()
+		assert.False(t, d.ExternallyModified())
+	})
+}
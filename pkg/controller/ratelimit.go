@@ -0,0 +1,163 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+const (
+	// DefaultRateLimit is the rate of messages per second LIFX devices can reliably handle.
+	DefaultRateLimit = 20
+
+	defaultRateLimitMaxWait = time.Second
+)
+
+var (
+	// ErrRateLimited is returned when a message is dropped because the device
+	// rate limit would defer it for longer than the configured maximum wait.
+	ErrRateLimited = errors.New("rate limited")
+
+	errSessionClosed = errors.New("session closed")
+)
+
+// SendStats holds counters of the messages sent to a device.
+type SendStats struct {
+	// Sent is the number of messages handed to the client.
+	Sent uint64
+	// Deferred is the number of messages that waited for the rate limiter before being sent.
+	Deferred uint64
+	// Dropped is the number of messages discarded by the rate limiter.
+	Dropped uint64
+}
+
+// SendStats returns the send counters of the device with the given serial,
+// and whether the device has a session.
+func (c *Controller) SendStats(serial device.Serial) (SendStats, bool) {
+	s := c.session(serial)
+	if s == nil {
+		return SendStats{}, false
+	}
+	return s.stats.snapshot(), true
+}
+
+type sendStats struct {
+	sent, deferred, dropped atomic.Uint64
+}
+
+func (s *sendStats) snapshot() SendStats {
+	return SendStats{Sent: s.sent.Load(), Deferred: s.deferred.Load(), Dropped: s.dropped.Load()}
+}
+
+// rateLimiter is a token bucket limiting the rate of messages sent to a device.
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	maxWait time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns a rateLimiter allowing rate messages per second with the given burst.
+// It returns nil if rate is not positive, which disables rate limiting.
+func newRateLimiter(rate float64, burst int, maxWait time.Duration) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	b := float64(max(1, burst))
+	return &rateLimiter{rate: rate, burst: b, maxWait: maxWait, tokens: b, last: time.Now()}
+}
+
+// reserve takes a token and returns how long to wait before using it.
+// It returns false, without taking a token, if the wait would exceed maxWait.
+func (l *rateLimiter) reserve(now time.Time) (time.Duration, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	if l.tokens >= 1 {
+		l.tokens--
+		return 0, true
+	}
+
+	wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+	if wait > l.maxWait {
+		return 0, false
+	}
+	l.tokens--
+	return wait, true
+}
+
+// release returns a token taken by reserve that was not used.
+func (l *rateLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// wait blocks until a message can be sent according to the rate limit, updating stats.
+// It returns ErrRateLimited if the message should be dropped, or an error if ctx is
+// cancelled or done is closed while waiting, in which case the reserved token is returned.
+// It is safe to call on a nil rateLimiter.
+func (l *rateLimiter) wait(ctx context.Context, done <-chan struct{}, stats *sendStats) error {
+	if l == nil {
+		return nil
+	}
+
+	d, ok := l.reserve(time.Now())
+	if !ok {
+		stats.dropped.Add(1)
+		return ErrRateLimited
+	}
+	if d == 0 {
+		return nil
+	}
+
+	stats.deferred.Add(1)
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		l.release()
+		return ctx.Err()
+	case <-done:
+		l.release()
+		return errSessionClosed
+	}
+}
+
+// waitRateLimits waits for both the device and the Controller rate limits, if configured.
+func (s *deviceSession) waitRateLimits(ctx context.Context) error {
+	if err := s.limiter.wait(ctx, s.done, &s.stats); err != nil {
+		return err
+	}
+	if s.cfg == nil {
+		return nil
+	}
+	if err := s.cfg.globalLimiter.wait(ctx, s.done, &s.stats); err != nil {
+		if s.limiter != nil {
+			s.limiter.release()
+		}
+		return err
+	}
+	return nil
+}
+
+// staggered returns a random duration in (0, period], to spread periodic work started together.
+func staggered(period time.Duration) time.Duration {
+	if period <= 0 {
+		return period
+	}
+	return rand.N(period) + 1
+}
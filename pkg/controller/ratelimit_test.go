@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Run("Disabled with no rate", func(t *testing.T) {
+		var l *rateLimiter = newRateLimiter(0, 10, time.Second)
+		assert.Nil(t, l)
+		assert.NoError(t, l.wait(context.Background(), nil, &sendStats{}))
+	})
+
+	t.Run("Reserves tokens", func(t *testing.T) {
+		l := newRateLimiter(10, 2, 150*time.Millisecond)
+		now := l.last
+
+		// Burst
+		for range 2 {
+			d, ok := l.reserve(now)
+			assert.True(t, ok)
+			assert.Zero(t, d)
+		}
+		// Deferred
+		d, ok := l.reserve(now)
+		assert.True(t, ok)
+		assert.Equal(t, 100*time.Millisecond, d)
+		// Exceeds max wait
+		_, ok = l.reserve(now)
+		assert.False(t, ok)
+		// Refills over time
+		d, ok = l.reserve(now.Add(200 * time.Millisecond))
+		assert.True(t, ok)
+		assert.Zero(t, d)
+	})
+
+	t.Run("Stops waiting when done", func(t *testing.T) {
+		l := newRateLimiter(1, 1, time.Minute)
+		stats := &sendStats{}
+		require.NoError(t, l.wait(context.Background(), nil, stats))
+
+		done := make(chan struct{})
+		close(done)
+		assert.ErrorIs(t, l.wait(context.Background(), done, stats), errSessionClosed)
+		assert.Equal(t, SendStats{Deferred: 1}, stats.snapshot())
+	})
+
+	t.Run("Returns tokens of cancelled waits", func(t *testing.T) {
+		l := newRateLimiter(10, 1, time.Minute)
+		_, ok := l.reserve(time.Now())
+		require.True(t, ok)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, l.wait(ctx, nil, &sendStats{}), context.Canceled)
+
+		// The cancelled wait did not consume a token, so the next one is deferred
+		// by a single token interval.
+		d, ok := l.reserve(time.Now())
+		assert.True(t, ok)
+		assert.InDelta(t, 100*time.Millisecond, d, float64(10*time.Millisecond))
+	})
+}
+
+func TestSessionRateLimit(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithRateLimit(20, 2), WithRateLimitMaxWait(60*time.Millisecond))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	// Do not use newDeviceSession to prevent running state update goroutine.
+	session := &deviceSession{
+		sender:  mockClient,
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		done:    make(chan struct{}),
+		limiter: newRateLimiter(ctrl.cfg.rateLimit, ctrl.cfg.rateLimitBurst, ctrl.cfg.rateLimitMaxWait),
+	}
+	ctrl.sessions[serial0] = session
+
+	msgs := make([]*protocol.Message, 3)
+	for i := range msgs {
+		msgs[i] = protocol.NewMessage(&packets.LightGet{})
+	}
+	// 2 burst messages and 1 deferred by 50ms.
+	require.NoError(t, session.send(msgs...))
+	assert.Equal(t, 3, len(mockClient.sends))
+
+	// The next message would be deferred and is dropped instead.
+	session.limiter.maxWait = 0
+	assert.ErrorIs(t, session.send(protocol.NewMessage(&packets.LightGet{})), ErrRateLimited)
+	assert.Equal(t, 3, len(mockClient.sends))
+
+	stats, ok := ctrl.SendStats(serial0)
+	assert.True(t, ok)
+	assert.Equal(t, SendStats{Sent: 3, Deferred: 1, Dropped: 1}, stats)
+
+	_, ok = ctrl.SendStats(device.Serial{})
+	assert.False(t, ok)
+
+	_, err = New(WithClient(newMockClient()), WithRateLimit(0, 1))
+	assert.Error(t, err)
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithGlobalRateLimit(10, 2), WithRateLimitMaxWait(0))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	sessions := make([]*deviceSession, 3)
+	for i := range sessions {
+		sessions[i] = &deviceSession{
+			sender: mockClient,
+			logger: discardLogger(),
+			device: device.NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, byte(10+i))}, device.Serial{byte(i + 1)}),
+			done:   make(chan struct{}),
+			cfg:    ctrl.cfg,
+		}
+	}
+
+	// The burst is shared by all sessions.
+	require.NoError(t, sessions[0].send(protocol.NewMessage(&packets.LightGet{})))
+	require.NoError(t, sessions[1].send(protocol.NewMessage(&packets.LightGet{})))
+	assert.ErrorIs(t, sessions[2].send(protocol.NewMessage(&packets.LightGet{})), ErrRateLimited)
+	assert.Equal(t, SendStats{Dropped: 1}, sessions[2].stats.snapshot())
+
+	_, err = New(WithClient(mockClient), WithGlobalRateLimit(0, 1))
+	assert.Error(t, err)
+}
+
+func TestStaggered(t *testing.T) {
+	for range 100 {
+		d := staggered(time.Second)
+		assert.Greater(t, d, time.Duration(0))
+		assert.LessOrEqual(t, d, time.Second)
+	}
+	assert.Zero(t, staggered(0))
+}
@@ -0,0 +1,141 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+const (
+	// RoutineWakeUp and RoutineWindDown are the effect names of the routines, as reported
+	// by the effect runner status.
+	RoutineWakeUp   = "wake_up"
+	RoutineWindDown = "wind_down"
+
+	// routineMaxSteps is the number of colors a routine sends at most. Each color transitions
+	// over a step, so the ramp looks continuous while long routines send few messages.
+	routineMaxSteps = 100
+	// routineMinStep is the shortest step of a routine, which bounds the steps of short routines.
+	routineMinStep = 50 * time.Millisecond
+	// wakeUpStartKelvin is the white temperature a wake-up starts from when the device is off,
+	// unless the target is warmer.
+	wakeUpStartKelvin = 2000
+)
+
+// ErrInvalidRoutine is returned when a routine is started with a duration that is not positive.
+var ErrInvalidRoutine = errors.New("invalid routine duration")
+
+// WakeUp simulates a sunrise, ramping the device to the target color over the duration d.
+// If the device is off, it is turned on at zero brightness and with a warm white first, and
+// the hue and saturation of the target are reached along the way.
+//
+// The routine is software driven, so it works on devices without native sky effects, and
+// runs on the effect runner: it replaces any effect running on the device and can be paused,
+// resumed and stopped through Effects. Pausing holds the current color and extends the routine.
+func (c *Controller) WakeUp(serial device.Serial, d time.Duration, target device.Color) error {
+	saved, err := c.routineDevice(serial, d)
+	if err != nil {
+		return err
+	}
+
+	c.effects.Start(serial, RoutineWakeUp, func(ctx context.Context, send matrix.SendFunc) error {
+		start := saved.Color
+		if !saved.PoweredOn {
+			start = device.Color{Hue: target.Hue, Saturation: 0, Brightness: 0, Kelvin: min(wakeUpStartKelvin, target.Kelvin)}
+			for _, msg := range []*protocol.Message{setColorMessage(start, 0), messages.SetPowerOn()} {
+				if err := send(msg); err != nil {
+					return err
+				}
+			}
+		}
+		return rampColor(ctx, send, start, target, d)
+	})
+	return nil
+}
+
+// WindDown simulates a sunset, ramping the device from its current color to the target color
+// over the duration d, e.g. a dim warm white, and then turning it off.
+// It does nothing if the device is already off. Like WakeUp, it runs on the effect runner and
+// can be paused, resumed and stopped through Effects, in which case the device is left on.
+func (c *Controller) WindDown(serial device.Serial, d time.Duration, target device.Color) error {
+	saved, err := c.routineDevice(serial, d)
+	if err != nil {
+		return err
+	}
+
+	c.effects.Start(serial, RoutineWindDown, func(ctx context.Context, send matrix.SendFunc) error {
+		if !saved.PoweredOn {
+			return nil
+		}
+		if err := rampColor(ctx, send, saved.Color, target, d); err != nil {
+			return err
+		}
+		return send(messages.SetPowerOff())
+	})
+	return nil
+}
+
+// routineDevice validates the routine duration and returns the state of the light it runs on.
+func (c *Controller) routineDevice(serial device.Serial, d time.Duration) (device.Device, error) {
+	if d <= 0 {
+		return device.Device{}, fmt.Errorf("%w: %s", ErrInvalidRoutine, d)
+	}
+	dev, ok := c.GetDevice(serial)
+	if !ok {
+		return device.Device{}, fmt.Errorf("%w for device %s", ErrNoSession, serial)
+	}
+	if dev.Type == device.DeviceTypeSwitch {
+		return device.Device{}, ErrNotLight
+	}
+	return dev, nil
+}
+
+// rampColor sends the colors from start to target over the duration d in steps, each
+// transitioning over the step duration, and returns once the last transition is done.
+// Steps are counted rather than timed, so time spent paused in send does not skip any.
+func rampColor(ctx context.Context, send matrix.SendFunc, start, target device.Color, d time.Duration) error {
+	steps := max(1, min(routineMaxSteps, int(d/routineMinStep)))
+	step := d / time.Duration(steps)
+
+	t := time.NewTimer(step)
+	defer t.Stop()
+	for i := 1; i <= steps; i++ {
+		color := interpolateColor(start, target, float64(i)/float64(steps))
+		if err := send(setColorMessage(color, step)); err != nil {
+			return err
+		}
+		t.Reset(step)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	return nil
+}
+
+// interpolateColor returns the color at f, between 0 and 1, on the way from a to b,
+// with the hue following the shortest path around the color wheel.
+func interpolateColor(a, b device.Color, f float64) device.Color {
+	lerp := func(a, b float64) float64 { return a + (b-a)*f }
+	dh := math.Mod(b.Hue-a.Hue+540, 360) - 180
+	return device.Color{
+		Hue:        math.Mod(a.Hue+dh*f+360, 360),
+		Saturation: lerp(a.Saturation, b.Saturation),
+		Brightness: lerp(a.Brightness, b.Brightness),
+		Kelvin:     uint16(math.Round(lerp(float64(a.Kelvin), float64(b.Kelvin)))),
+	}
+}
+
+// setColorMessage returns the message setting the color of a light over the duration d.
+func setColorMessage(color device.Color, d time.Duration) *protocol.Message {
+	return protocol.NewMessage(&packets.LightSetColor{Color: color.ToDeviceColor(), Duration: uint32(d.Milliseconds())})
+}
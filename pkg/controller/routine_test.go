@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoutines(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		day     = device.Color{Brightness: 100, Kelvin: 5000}
+		dusk    = device.Color{Brightness: 10, Kelvin: 2200}
+	)
+
+	newController := func(t *testing.T, d *device.Device) (*Controller, *mockClient) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		t.Cleanup(func() { ctrl.Close() })
+
+		// Do not use newDeviceSession to prevent running state update goroutine.
+		ctrl.sessions[serial0] = &deviceSession{
+			sender: mockClient,
+			logger: discardLogger(),
+			device: d,
+			done:   make(chan struct{}),
+		}
+		return ctrl, mockClient
+	}
+
+	// waitState waits for the routine to reach the given state.
+	waitState := func(t *testing.T, ctrl *Controller, state matrix.EffectState) {
+		require.Eventually(t, func() bool {
+			st, ok := ctrl.Effects().Status(serial0)
+			return ok && st.State == state
+		}, time.Second, 5*time.Millisecond)
+	}
+
+	// sent returns the payloads sent so far.
+	sent := func(m *mockClient) []packets.Payload {
+		var payloads []packets.Payload
+		for len(m.sends) > 0 {
+			payloads = append(payloads, (<-m.sends).Payload)
+		}
+		return payloads
+	}
+
+	setColor := func(c device.Color, d time.Duration) *packets.LightSetColor {
+		return &packets.LightSetColor{Color: c.ToDeviceColor(), Duration: uint32(d.Milliseconds())}
+	}
+
+	t.Run("Wakes up a device that is off", func(t *testing.T) {
+		ctrl, mockClient := newController(t, device.NewDevice(addr0, serial0))
+		require.NoError(t, ctrl.WakeUp(serial0, 200*time.Millisecond, day))
+		waitState(t, ctrl, matrix.EffectCompleted)
+
+		status, _ := ctrl.Effects().Status(serial0)
+		assert.Equal(t, RoutineWakeUp, status.Name)
+		step := 50 * time.Millisecond
+		assert.Equal(t, []packets.Payload{
+			setColor(device.Color{Kelvin: 2000}, 0),
+			&packets.DeviceSetPower{Level: 65535},
+			setColor(device.Color{Brightness: 25, Kelvin: 2750}, step),
+			setColor(device.Color{Brightness: 50, Kelvin: 3500}, step),
+			setColor(device.Color{Brightness: 75, Kelvin: 4250}, step),
+			setColor(day, step),
+		}, sent(mockClient))
+	})
+
+	t.Run("Wakes up a device that is on from its color", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn, d.Color = true, dusk
+		ctrl, mockClient := newController(t, d)
+		require.NoError(t, ctrl.WakeUp(serial0, 50*time.Millisecond, day))
+		waitState(t, ctrl, matrix.EffectCompleted)
+		assert.Equal(t, []packets.Payload{setColor(day, 50*time.Millisecond)}, sent(mockClient))
+	})
+
+	t.Run("Winds down and turns off a device", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn, d.Color = true, day
+		ctrl, mockClient := newController(t, d)
+		require.NoError(t, ctrl.WindDown(serial0, 100*time.Millisecond, dusk))
+		waitState(t, ctrl, matrix.EffectCompleted)
+
+		step := 50 * time.Millisecond
+		assert.Equal(t, []packets.Payload{
+			setColor(device.Color{Brightness: 55, Kelvin: 3600}, step),
+			setColor(dusk, step),
+			&packets.DeviceSetPower{},
+		}, sent(mockClient))
+	})
+
+	t.Run("Does not wind down a device that is off", func(t *testing.T) {
+		ctrl, mockClient := newController(t, device.NewDevice(addr0, serial0))
+		require.NoError(t, ctrl.WindDown(serial0, time.Second, dusk))
+		waitState(t, ctrl, matrix.EffectCompleted)
+		assert.Empty(t, sent(mockClient))
+	})
+
+	t.Run("Pauses and resumes", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn, d.Color = true, dusk
+		ctrl, mockClient := newController(t, d)
+		require.NoError(t, ctrl.WakeUp(serial0, 200*time.Millisecond, day))
+		require.NoError(t, ctrl.Effects().Pause(serial0))
+		waitState(t, ctrl, matrix.EffectPaused)
+
+		time.Sleep(300 * time.Millisecond)
+		status, _ := ctrl.Effects().Status(serial0)
+		assert.Equal(t, matrix.EffectPaused, status.State)
+		paused := len(sent(mockClient))
+		assert.Less(t, paused, 4)
+
+		require.NoError(t, ctrl.Effects().Resume(serial0))
+		waitState(t, ctrl, matrix.EffectCompleted)
+		assert.Len(t, sent(mockClient), 4-paused)
+	})
+
+	t.Run("Leaves the device on when stopped", func(t *testing.T) {
+		d := device.NewDevice(addr0, serial0)
+		d.PoweredOn, d.Color = true, day
+		ctrl, mockClient := newController(t, d)
+		require.NoError(t, ctrl.WindDown(serial0, time.Hour, dusk))
+		require.NoError(t, ctrl.Effects().Stop(serial0))
+
+		for _, p := range sent(mockClient) {
+			assert.IsType(t, &packets.LightSetColor{}, p)
+		}
+	})
+
+	t.Run("Rejects invalid routines", func(t *testing.T) {
+		ctrl, mockClient := newController(t, device.NewDevice(addr0, serial0))
+		assert.ErrorIs(t, ctrl.WakeUp(serial0, 0, day), ErrInvalidRoutine)
+		assert.EqualError(t, ctrl.WindDown(device.Serial{9}, time.Second, dusk), "no session for device 090000000000")
+
+		ctrl.sessions[serial0].device.Type = device.DeviceTypeSwitch
+		assert.ErrorIs(t, ctrl.WakeUp(serial0, time.Second, day), ErrNotLight)
+		assert.Empty(t, mockClient.sends)
+		_, ok := ctrl.Effects().Status(serial0)
+		assert.False(t, ok)
+	})
+}
+
+func TestInterpolateColor(t *testing.T) {
+	testCases := map[string]struct {
+		a, b device.Color
+		f    float64
+		want device.Color
+	}{
+		"Start": {
+			a: device.Color{Hue: 10, Brightness: 20, Kelvin: 2000}, b: device.Color{Hue: 50, Brightness: 80, Kelvin: 4000},
+			f: 0, want: device.Color{Hue: 10, Brightness: 20, Kelvin: 2000},
+		},
+		"Middle": {
+			a: device.Color{Hue: 10, Brightness: 20, Kelvin: 2000}, b: device.Color{Hue: 50, Brightness: 80, Kelvin: 4000},
+			f: 0.5, want: device.Color{Hue: 30, Brightness: 50, Kelvin: 3000},
+		},
+		"Hue wraps forward": {
+			a: device.Color{Hue: 350, Saturation: 100}, b: device.Color{Hue: 30, Saturation: 100},
+			f: 0.5, want: device.Color{Hue: 10, Saturation: 100},
+		},
+		"Hue wraps backward": {
+			a: device.Color{Hue: 20}, b: device.Color{Hue: 340},
+			f: 0.75, want: device.Color{Hue: 350},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got := interpolateColor(tc.a, tc.b, tc.f)
+			assert.InDelta(t, tc.want.Hue, got.Hue, 1e-9)
+			assert.InDelta(t, tc.want.Saturation, got.Saturation, 1e-9)
+			assert.InDelta(t, tc.want.Brightness, got.Brightness, 1e-9)
+			assert.Equal(t, tc.want.Kelvin, got.Kelvin)
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// Scene is the state of a set of devices, applied together by ApplyScene.
+// Scenes are usually captured with CaptureScene, but can be built from devices holding only
+// a Serial, PoweredOn and Color.
+type Scene []device.Device
+
+// CaptureScene returns the current state of the lights that have a session and match all the
+// given filters.
+func (c *Controller) CaptureScene(filters ...DeviceFilter) Scene {
+	filters = append(filters, ByDeviceType(device.DeviceTypeLight, device.DeviceTypeHybrid))
+	return Scene(c.FindDevices(filters...))
+}
+
+// ApplyScene sets the devices of the scene to their colors and power over the duration d,
+// including the colors of each zone of multizone and matrix devices.
+// Devices without a session and switches are skipped, and the errors of the devices that could
+// not be set are returned.
+func (c *Controller) ApplyScene(scene Scene, d time.Duration) error {
+	var errs []error
+	for _, state := range scene {
+		if state.Type == device.DeviceTypeSwitch {
+			continue
+		}
+		s := c.session(state.Serial)
+		if s == nil {
+			continue
+		}
+		msgs := restoreMessages(state, d)
+		if state.PoweredOn {
+			msgs = append(msgs, powerMessage(true, d))
+		}
+		for _, msg := range msgs {
+			msg.SetAckRequired(true)
+			if err := s.send(msg); err != nil {
+				errs = append(errs, fmt.Errorf("device %s: %w", state.Serial, err))
+				break
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScene(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		addr1   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 11)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		serial1 = device.Serial([8]byte{2, 0, 0, 0, 0, 0, 0, 0})
+		serial2 = device.Serial([8]byte{3, 0, 0, 0, 0, 0, 0, 0})
+		white   = device.Color{Brightness: 50, Kelvin: 2700}
+	)
+
+	newController := func(t *testing.T) (*Controller, *mockClient) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		t.Cleanup(func() { ctrl.Close() })
+
+		light := device.NewDevice(addr0, serial0)
+		light.Label, light.Group, light.PoweredOn, light.Color = "Desk", "Office", true, white
+		lswitch := device.NewDevice(addr1, serial1)
+		lswitch.Label, lswitch.Group, lswitch.Type = "Switch", "Office", device.DeviceTypeSwitch
+		for _, d := range []*device.Device{light, lswitch} {
+			// Do not use newDeviceSession to prevent running state update goroutine.
+			ctrl.sessions[d.Serial] = &deviceSession{
+				sender: mockClient,
+				logger: discardLogger(),
+				device: d,
+				done:   make(chan struct{}),
+			}
+		}
+		return ctrl, mockClient
+	}
+
+	t.Run("Captures lights", func(t *testing.T) {
+		ctrl, _ := newController(t)
+
+		scene := ctrl.CaptureScene(ByGroup("Office"))
+		require.Len(t, scene, 1)
+		assert.Equal(t, serial0, scene[0].Serial)
+		assert.Equal(t, white, scene[0].Color)
+		assert.Empty(t, ctrl.CaptureScene(ByGroup("Kitchen")))
+	})
+
+	t.Run("Applies scene", func(t *testing.T) {
+		ctrl, mockClient := newController(t)
+
+		scene := Scene{
+			{Serial: serial0, Color: white, PoweredOn: true},
+			{Serial: serial1, Type: device.DeviceTypeSwitch},
+			{Serial: serial2, PoweredOn: true},
+		}
+		require.NoError(t, ctrl.ApplyScene(scene, time.Second))
+		require.Len(t, mockClient.sends, 2)
+
+		msg := <-mockClient.sends
+		assert.True(t, msg.AckRequired())
+		assert.Equal(t, &packets.LightSetColor{Color: white.ToDeviceColor(), Duration: 1000}, msg.Payload)
+		assert.Equal(t, &packets.LightSetPower{Level: 65535, Duration: 1000}, (<-mockClient.sends).Payload)
+	})
+
+	t.Run("Turns off lights", func(t *testing.T) {
+		ctrl, mockClient := newController(t)
+
+		require.NoError(t, ctrl.ApplyScene(Scene{{Serial: serial0, Color: white}}, 0))
+		require.Len(t, mockClient.sends, 2)
+		assert.Equal(t, &packets.LightSetColor{Color: white.ToDeviceColor()}, (<-mockClient.sends).Payload)
+		assert.Equal(t, &packets.DeviceSetPower{}, (<-mockClient.sends).Payload)
+	})
+}
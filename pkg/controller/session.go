@@ -1,15 +1,17 @@
 package controller
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
-	"math"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 )
@@ -30,27 +32,77 @@ type deviceSession struct {
 	inbound chan *protocol.Message
 	seq     atomic.Uint32
 	done    chan struct{}
-	cfg     *Config
+	// ctx is cancelled when the session is closed, stopping in-flight operations such as the preflight handshake.
+	ctx    context.Context
+	cancel context.CancelFunc
+	cfg    *Config
 	// onTimeout is a callback to terminate the session when the livenessTimeout is reached
 	onTimeout func(device.Serial)
+	// events publishes device state changes to the Controller subscribers.
+	events *eventBus
+	// limiter limits the rate of outbound messages, if configured.
+	limiter *rateLimiter
+	stats   sendStats
+	// lastCommandAt is the time, in unix nanoseconds, the last state-changing command was sent.
+	lastCommandAt atomic.Int64
+	// suspended is set while the host is asleep, pausing state refreshes and liveness checks.
+	suspended atomic.Bool
+	// resumedAt is the time, in unix nanoseconds, the session was last resumed.
+	resumedAt atomic.Int64
+	// wake signals the run loop to refresh the device state after a resume.
+	wake chan struct{}
+	// sentAt holds the time, in unix nanoseconds, of the last message sent with each sequence
+	// number, to measure the round trip time of responses.
+	sentAt [256]atomic.Int64
+	// queries reports whether the last message sent with each sequence number expects a response.
+	queries [256]atomic.Bool
+	// acks reports whether the last message sent with each sequence number requires an acknowledgement.
+	acks [256]atomic.Bool
+	// sending is the number of sendCtx calls in progress, including those waiting for the rate limiters.
+	sending atomic.Int32
+	// ackWaiters holds the channels closed when the message sent with each sequence number is
+	// acknowledged, for the batches waiting for it.
+	ackWaiters sync.Map
+	// inboundDropped is the number of received messages dropped because the inbound queue was full.
+	inboundDropped atomic.Uint64
+	// dedupe holds the last message received, see duplicate.
+	dedupe dedupeState
+	// offline is set when the session is terminated because the device was not seen within the liveness timeout.
+	offline atomic.Bool
+	// createdAt is the time the session was created, when the device was discovered.
+	createdAt time.Time
 
 	// mu protects read/write access of DeviceState
 	mu     sync.RWMutex
 	device *device.Device
+	// powerColorKnown reports whether the initial power and color state has been received,
+	// after which changes are checked for external modifications.
+	powerColorKnown bool
 }
 
 // newDeviceSession creates a new deviceSession for the given device.
+// The session logs are annotated with the device serial and address.
 // It spins up a goroutine to periodically query devices for state updates and
 // a second one to parse devices messages and update Device state.
-func newDeviceSession(addr *net.UDPAddr, serial device.Serial, sender sender, cfg *Config, wgDone func(), onTimeout func(device.Serial), logger *slog.Logger) *deviceSession {
+// The given init functions are applied to the device before the session starts, e.g. to restore
+// cached state, so that the preflight handshake skips the messages it fulfills.
+func newDeviceSession(addr *net.UDPAddr, serial device.Serial, sender sender, cfg *Config, wgDone func(), onTimeout func(device.Serial), events *eventBus, logger *slog.Logger, init ...func(*device.Device)) *deviceSession {
 	ds := &deviceSession{
 		sender:    sender,
-		logger:    logger,
+		logger:    logger.With("serial", serial, "address", addr),
 		device:    device.NewDevice(addr, serial),
-		inbound:   make(chan *protocol.Message, defaultRecvBufferSize),
+		inbound:   make(chan *protocol.Message, cfg.inboundSize()),
 		done:      make(chan struct{}),
+		wake:      make(chan struct{}, 1),
 		cfg:       cfg,
 		onTimeout: onTimeout,
+		events:    events,
+		limiter:   newRateLimiter(cfg.rateLimit, cfg.rateLimitBurst, cfg.rateLimitMaxWait),
+		createdAt: time.Now(),
+	}
+	ds.ctx, ds.cancel = context.WithCancel(context.Background())
+	for _, f := range init {
+		f(ds.device)
 	}
 
 	go ds.recvloop()
@@ -62,25 +114,121 @@ func newDeviceSession(addr *net.UDPAddr, serial device.Serial, sender sender, cf
 // close closes the deviceSession, stopping the recv loop and cleaning up resources.
 func (s *deviceSession) close() {
 	close(s.done)
+	if s.cancel != nil {
+		s.cancel()
+	}
 }
 
 // send sends one or more messages to the device.
+// If a rate limit is configured, it blocks until each message can be sent
+// and stops at the first message that is dropped.
 func (s *deviceSession) send(msgs ...*protocol.Message) error {
+	return s.sendCtx(context.Background(), msgs...)
+}
+
+// refresh sends state queries to the device, recording the error if they cannot be sent.
+func (s *deviceSession) refresh(msgs ...*protocol.Message) {
+	s.cfg.lastError.record(s.send(msgs...))
+}
+
+// sendCtx is like send but stops sending when ctx is cancelled.
+func (s *deviceSession) sendCtx(ctx context.Context, msgs ...*protocol.Message) error {
+	s.sending.Add(1)
+	defer s.sending.Add(-1)
+
 	for _, msg := range msgs {
-		msg.SetTarget(s.device.Serial)
-		msg.SetSequence(s.nextSeq())
-		if err := s.sender.Send(s.device.Address, msg); err != nil {
-			return fmt.Errorf("failed to send message to device %s: %v", s.device.Serial, err)
+		if err := s.sendOne(ctx, msg, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendOne sends a message to the device, calling onSequence, if set, with the sequence number
+// assigned to the message before it is sent.
+func (s *deviceSession) sendOne(ctx context.Context, msg *protocol.Message, onSequence func(seq uint8)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.waitRateLimits(ctx); err != nil {
+		if errors.Is(err, ErrRateLimited) {
+			s.metrics().MessageDropped(DropRateLimited)
+		}
+		if errors.Is(err, errSessionClosed) {
+			return s.closedError()
 		}
+		return err
+	}
+	seq := s.nextSeq()
+	msg.SetTarget(s.device.Serial)
+	msg.SetSequence(seq)
+	if onSequence != nil {
+		onSequence(seq)
+	}
+	now := time.Now()
+	if err := s.sender.Send(s.device.Address, msg); err != nil {
+		return fmt.Errorf("failed to send message to device %s: %w", s.device.Serial, err)
+	}
+	s.stats.sent.Add(1)
+	s.metrics().MessageSent(msg.Payload.PayloadType())
+	s.recordSent(seq, now, isQuery(msg.Payload))
+	s.acks[seq].Store(msg.AckRequired())
+	if isStateCommand(msg.Payload) {
+		s.commandSent(now)
+	}
+	return nil
+}
+
+// closedError returns the error of the sends interrupted by the session being closed,
+// wrapping ErrDeviceOffline if the device went offline.
+func (s *deviceSession) closedError() error {
+	if s.offline.Load() {
+		return fmt.Errorf("device %s: %w", s.device.Serial, ErrDeviceOffline)
+	}
+	return errSessionClosed
+}
+
+// validate returns an error wrapping ErrUnsupportedCapability if the device does not support
+// one of the messages. Messages sent before the device product is resolved are not validated.
+func (s *deviceSession) validate(msgs ...*protocol.Message) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.device.Profiled() {
+		return nil
+	}
+	if err := messages.Validate(s.device.Supports, msgs...); err != nil {
+		return fmt.Errorf("device %s: %w", s.device.Serial, err)
 	}
 	return nil
 }
 
-// deviceSnapshot returns a copy of a Device with its current device state.
+// deviceSnapshot returns a deep copy of a Device with its current device state,
+// so that callers can read zones state while the session keeps updating it.
 func (s *deviceSession) deviceSnapshot() device.Device {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	return *s.device
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.device.Clone()
+}
+
+// newEvent returns an Event of the given type with the current device state.
+func (s *deviceSession) newEvent(t EventType) Event {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.newEvents(t)[0]
+}
+
+// newEvents returns an Event for each of the given types with a deep copy of the current
+// device state, so that subscribers do not share zones state with the session.
+// It must be called while holding the session lock, for the events to be consistent with
+// the changes that caused them.
+func (s *deviceSession) newEvents(types ...EventType) []Event {
+	d := s.device.Clone()
+	now := time.Now()
+	events := make([]Event, len(types))
+	for i, t := range types {
+		events[i] = Event{Type: t, Serial: d.Serial, Device: d, At: now}
+	}
+	return events
 }
 
 // nextSeq increments the sequence number and returns the new value.
@@ -95,10 +243,17 @@ func (s *deviceSession) nextSeq() uint8 {
 func (s *deviceSession) run(wgDone func()) {
 	defer wgDone()
 
-	s.preflightHandshake(s.cfg.preflightHandshakeTimeout, s.cfg.preflightHandshakeWait)
+	if s.cfg.statePollingDisabled {
+		<-s.done
+		return
+	}
+
+	s.preflightHandshake(s.ctx, s.cfg.preflightHandshakeTimeout, s.cfg.preflightHandshakeWait)
 
-	hfTicker := time.NewTicker(s.cfg.highFrequencyStateRefreshPeriod)
-	lfTicker := time.NewTicker(s.cfg.lowFrequencyStateRefreshPeriod)
+	// Stagger the first refreshes, as tickers are reset to their period after each tick,
+	// so that sessions created together do not poll their devices in bursts.
+	hfTicker := time.NewTicker(staggered(s.cfg.highFrequencyStateRefreshPeriod))
+	lfTicker := time.NewTicker(staggered(s.cfg.lowFrequencyStateRefreshPeriod))
 	// Check twice inside liveness timeout window.
 	livenessTicker := time.NewTicker(s.cfg.deviceLivenessTimeout / 2)
 
@@ -107,21 +262,36 @@ func (s *deviceSession) run(wgDone func()) {
 		case <-s.done:
 			return
 		case <-hfTicker.C:
-			s.send(s.device.HighFreqStateMessages()...)
+			if !s.suspended.Load() && !s.isOffline() {
+				s.checkLostQueries(time.Now())
+				s.refresh(s.device.HighFreqStateMessages()...)
+			}
 			hfTicker.Reset(s.cfg.highFrequencyStateRefreshPeriod)
 		case <-lfTicker.C:
-			s.send(s.device.LowFreqStateMessages()...)
+			if !s.suspended.Load() && !s.isOffline() {
+				s.refresh(s.device.LowFreqStateMessages()...)
+			}
 			lfTicker.Reset(s.cfg.lowFrequencyStateRefreshPeriod)
+		case <-s.wake:
+			s.refresh(s.device.HighFreqStateMessages()...)
+			hfTicker.Reset(s.cfg.highFrequencyStateRefreshPeriod)
+			livenessTicker.Reset(s.cfg.deviceLivenessTimeout / 2)
 		case <-livenessTicker.C:
-			s.mu.RLock()
-			last := s.device.LastSeenAt
-			s.mu.RUnlock()
-
-			if time.Since(last) > s.cfg.deviceLivenessTimeout {
-				s.logger.Warn(
-					"Device not seen for too long, terminating session",
-					"serial", s.device.Serial,
-				)
+			if s.suspended.Load() {
+				continue
+			}
+			if s.isOffline() {
+				s.probe()
+				continue
+			}
+			if time.Since(s.lastSeen()) > s.cfg.deviceLivenessTimeout {
+				if s.cfg.keepOfflineDevices {
+					s.logger.Warn("Device not seen for too long, marking it offline")
+					s.markOffline()
+					continue
+				}
+				s.logger.Warn("Device not seen for too long, terminating session")
+				s.offline.Store(true)
 				s.onTimeout(s.device.Serial)
 				return
 			}
@@ -137,91 +307,199 @@ func (s *deviceSession) recvloop() {
 			if msg == nil {
 				continue
 			}
+			now := time.Now()
+			rtt, query, measured := s.recordResponse(msg.Sequence(), now)
+			if _, ok := msg.Payload.(*packets.DeviceAcknowledgement); ok {
+				s.acknowledged(msg.Sequence())
+			}
 
+			var (
+				changes []EventType
+				updated bool
+				pressed []int
+			)
 			s.mu.Lock()
-			switch p := msg.Payload.(type) {
-			case *packets.DeviceStateLabel:
-				label := device.ParseLabel(p.Label)
-				if shouldUpdate(s.device.Label, label) {
-					s.device.Label = label
-					s.device.LastUpdatedAt = time.Now()
+			backOnline := s.device.Offline
+			s.device.Offline = false
+			s.updateHealth(msg, now, rtt, query, measured)
+			if h := s.cfg.stateHandlers.get(msg.Type()); h != nil {
+				changes, updated = applyCustomState(s.device, msg.Payload, h)
+			} else {
+				var known bool
+				if changes, updated, known = applyState(s.device, msg.Payload); !known {
+					s.logger.Debug("Session: Unhandled message type", "payload", msg.Payload.PayloadType())
 				}
-			case *packets.LightState:
-				color := device.NewColor(p.Color)
-				poweredOn := p.Power > 0
-				if shouldUpdate(s.device.Color, color) || shouldUpdate(s.device.PoweredOn, poweredOn) {
-					s.device.Color = color
-					s.device.PoweredOn = poweredOn
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStateVersion:
-				if shouldUpdate(s.device.ProductID, p.Product) {
-					s.device.SetProductInfo(p.Product)
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStateHostFirmware:
-				fwVersion := fmt.Sprintf("%d.%d", p.VersionMajor, p.VersionMinor)
-				if shouldUpdate(s.device.FirmwareVersion, fwVersion) {
-					s.device.FirmwareVersion = fwVersion
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStateLocation:
-				label := device.ParseLabel(p.Label)
-				if shouldUpdate(s.device.Location, label) {
-					s.device.Location = label
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStateGroup:
-				label := device.ParseLabel(p.Label)
-				if shouldUpdate(s.device.Group, label) {
-					s.device.Group = device.ParseLabel(p.Label)
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.TileStateDeviceChain:
-				if updated := s.device.SetMatrixProperties(p); updated {
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.TileState64:
-				if updated := s.device.SetMatrixState(p); updated {
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.MultiZoneExtendedStateMultiZone:
-				if updated := s.device.SetMultizoneProperties(p); updated {
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.ButtonState:
-				if updated := s.device.SetButtons(p); updated {
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStatePower:
-				poweredOn := p.Level > 0
-				if shouldUpdate(s.device.PoweredOn, poweredOn) {
-					s.device.PoweredOn = poweredOn
-					s.device.LastUpdatedAt = time.Now()
-				}
-			case *packets.DeviceStateWifiInfo:
-				rssi := device.WifiRSSI(int(math.Floor(10*math.Log10(float64(p.Signal)) + 0.5)))
-				if shouldUpdate(s.device.WifiRSSI.String(), rssi.String()) {
-					s.device.WifiRSSI = rssi
-					s.device.LastUpdatedAt = time.Now()
+			}
+			if backOnline {
+				changes = append(changes, EventDeviceOnline)
+				updated = true
+			}
+			if updated {
+				s.device.LastUpdatedAt = time.Now()
+				s.device.StateVersion = s.cfg.nextStateVersion()
+			}
+			switch p := msg.Payload.(type) {
+			case *packets.LightState, *packets.DeviceStatePower, *packets.RelayStatePower:
+				if s.powerColorKnown && s.isExternalChange(changes, time.Now()) {
+					s.device.ExternallyModifiedAt = time.Now()
+					changes = append(changes, EventExternallyModified)
+					if p, ok := p.(*packets.RelayStatePower); ok {
+						pressed = buttonsTogglingRelay(s.device, int(p.RelayIndex))
+					}
 				}
-			case *packets.DeviceStateService, *packets.DeviceStateUnhandled: // Ignore these messages
-			default:
-				s.logger.Debug(
-					"Session: Unhandled message type",
-					"serial", s.device.Serial,
-					"payload", msg.Payload.PayloadType(),
-				)
+				s.powerColorKnown = true
 			}
 			s.device.LastSeenAt = time.Now()
+			var events []Event
+			if len(changes) > 0 && s.events != nil {
+				events = s.newEvents(changes...)
+				for _, b := range pressed {
+					e := events[0]
+					e.Type, e.Button = EventButtonPressed, b
+					events = append(events, e)
+				}
+			}
 			s.mu.Unlock()
+
+			s.events.publish(events...)
+			if backOnline {
+				s.markedOnline()
+			}
 		case <-s.done:
-			s.logger.Info("Exiting device recv loop", "serial", s.device.Serial)
+			s.logger.Info("Exiting device recv loop")
 			return
 		}
 	}
 }
 
+// applyState updates the device state from a received payload with the built-in handling.
+// It returns the resulting state change events, whether the device state was updated and
+// whether the payload type is known.
+func applyState(d *device.Device, payload packets.Payload) (changes []EventType, updated, known bool) {
+	known = true
+	switch p := payload.(type) {
+	case *packets.DeviceStateLabel:
+		label := device.ParseLabel(p.Label)
+		if shouldUpdate(d.Label, label) {
+			d.Label = label
+			updated = true
+			changes = append(changes, EventLabelChanged)
+		}
+	case *packets.LightState:
+		color := device.NewColor(p.Color)
+		poweredOn := p.Power > 0
+		if shouldUpdate(d.Color, color) {
+			changes = append(changes, EventColorChanged)
+		}
+		if shouldUpdate(d.PoweredOn, poweredOn) {
+			changes = append(changes, EventPowerChanged)
+		}
+		if len(changes) > 0 {
+			d.Color = color
+			d.PoweredOn = poweredOn
+			updated = true
+		}
+	case *packets.DeviceStateVersion:
+		// Re-profile devices whose product was not resolved, as the version reply might have been missed.
+		if shouldUpdate(d.ProductID, p.Product) || !d.Profiled() {
+			updated = d.SetProductInfo(p.Product)
+		}
+	case *packets.DeviceStateHostFirmware:
+		updated = d.SetHostFirmware(p)
+	case *packets.DeviceStateInfo:
+		var rebooted bool
+		if updated, rebooted = d.SetInfo(p); rebooted {
+			changes = append(changes, EventDeviceRebooted)
+		}
+	case *packets.DeviceStateLocation:
+		label := device.ParseLabel(p.Label)
+		if shouldUpdate(d.Location, label) || shouldUpdate(d.LocationID, p.Location) {
+			d.Location, d.LocationID = label, p.Location
+			updated = true
+		}
+	case *packets.DeviceStateGroup:
+		label := device.ParseLabel(p.Label)
+		if shouldUpdate(d.Group, label) || shouldUpdate(d.GroupID, p.Group) {
+			d.Group, d.GroupID = label, p.Group
+			updated = true
+		}
+	case *packets.TileStateDeviceChain:
+		if updated = d.SetMatrixProperties(p); updated {
+			changes = append(changes, EventMatrixStateChanged)
+		}
+	case *packets.TileState64:
+		if updated = d.SetMatrixState(p); updated {
+			changes = append(changes, EventMatrixStateChanged)
+		}
+	case *packets.MultiZoneExtendedStateMultiZone:
+		if updated = d.SetMultizoneProperties(p); updated {
+			changes = append(changes, EventMultizoneStateChanged)
+		}
+	case *packets.MultiZoneStateMultiZone:
+		if updated = d.SetMultizoneState(p); updated {
+			changes = append(changes, EventMultizoneStateChanged)
+		}
+	case *packets.MultiZoneStateZone:
+		if updated = d.SetZoneState(p); updated {
+			changes = append(changes, EventMultizoneStateChanged)
+		}
+	case *packets.TileStateEffect:
+		if updated = d.SetMatrixEffect(p); updated {
+			changes = append(changes, EventEffectChanged)
+		}
+	case *packets.MultiZoneStateEffect:
+		if updated = d.SetMultizoneEffect(p); updated {
+			changes = append(changes, EventEffectChanged)
+		}
+	case *packets.ButtonState:
+		if updated = d.SetButtons(p); updated {
+			changes = append(changes, EventButtonsChanged)
+		}
+	case *packets.ButtonStateConfig:
+		if updated = d.SetButtonConfig(p); updated {
+			changes = append(changes, EventButtonsChanged)
+		}
+	case *packets.DeviceStatePower:
+		poweredOn := p.Level > 0
+		if shouldUpdate(d.PoweredOn, poweredOn) {
+			d.PoweredOn = poweredOn
+			updated = true
+			changes = append(changes, EventPowerChanged)
+		}
+	case *packets.DeviceAcknowledgement:
+		// Acknowledgements carry no state but mark the device as seen.
+	case *packets.RelayStatePower:
+		if updated = d.SetRelayPower(p); updated {
+			changes = append(changes, EventPowerChanged)
+		}
+	case *packets.LightStateHevCycle:
+		updated = d.SetHevCycle(p)
+	case *packets.LightStateHevCycleConfiguration:
+		updated = d.SetHevCycleConfiguration(p)
+	case *packets.LightStateLastHevCycleResult:
+		updated = d.SetHevLastResult(p)
+	case *packets.DeviceStateWifiInfo:
+		updated = d.SetWifiInfo(p)
+	case *packets.DeviceStateWifiFirmware:
+		updated = d.SetWifiFirmware(p)
+	case *packets.DeviceStateService, *packets.DeviceStateUnhandled: // Ignore these messages
+	default:
+		known = false
+	}
+	return changes, updated, known
+}
+
+// buttonsTogglingRelay returns the indexes of the device buttons that toggle the given relay.
+func buttonsTogglingRelay(d *device.Device, relayIndex int) []int {
+	var buttons []int
+	for i, b := range d.Buttons {
+		if b.TogglesRelay(relayIndex) {
+			buttons = append(buttons, i)
+		}
+	}
+	return buttons
+}
+
 func shouldUpdate[T comparable](current, updated T) bool {
 	return current != updated
 }
@@ -229,58 +507,79 @@ func shouldUpdate[T comparable](current, updated T) bool {
 // preflightHandshake ensures the device session has a minimal known-good state
 // before starting the main periodic refresh loop.
 // It sends required state requests, waits for recvloop to update s.device,
-// and retries missing ones until all are satisfied or the deadline expires.
-func (s *deviceSession) preflightHandshake(timeout, wait time.Duration) {
+// and retries missing ones until all are satisfied, the deadline expires or ctx is cancelled.
+func (s *deviceSession) preflightHandshake(ctx context.Context, timeout, wait time.Duration) {
 	deadline := time.Now().Add(timeout)
-	required := requiredStateMessages()
+	// Skip the messages fulfilled by the state known beforehand, e.g. restored from the device cache
+	// or from the previous session of the device, see WithDeviceCache and Controller.resurrect.
+	required := s.pendingPreflight(requiredStateMessages(), true)
+	if skipped := len(requiredStateMessages()) - len(required); skipped > 0 {
+		s.logger.Debug("Preflight skipping messages fulfilled by the known device state", "skipped", skipped)
+	}
 
 	for len(required) > 0 {
-		s.send(required...)
+		s.sendCtx(ctx, required...)
 
 		select {
+		case <-ctx.Done():
+			return
 		case <-s.done:
 			return
 		case <-time.After(wait):
 			// shrink list of required messages after each wait
-			var retryMsgs []*protocol.Message
-			s.mu.RLock()
-			for _, m := range required {
-				if f := messageDoneFuncs[m.Payload]; f != nil {
-					if !f(s.device) {
-						retryMsgs = append(retryMsgs, m)
-					} else if m.Payload.PayloadType() == uint16(packets.PayloadTypeDeviceGetVersion) {
-						// Add device-specific preflight messages.
-
-						if s.device.Type == device.DeviceTypeHybrid || s.device.Type == device.DeviceTypeSwitch {
-							retryMsgs = append(retryMsgs, protocol.NewMessage(&packets.ButtonGet{}))
-						}
-
-						switch s.device.LightType {
-						case device.LightTypeMatrix:
-							retryMsgs = append(retryMsgs, protocol.NewMessage(&packets.TileGetDeviceChain{}))
-						case device.LightTypeMultiZone:
-							retryMsgs = append(retryMsgs, protocol.NewMessage(&packets.MultiZoneExtendedGetColorZones{}))
-						}
-					}
-				}
-			}
-			s.mu.RUnlock()
-			required = retryMsgs
+			required = s.pendingPreflight(required, false)
 		}
 
 		if time.Now().After(deadline) {
 			if len(required) > 0 {
-				s.logger.Warn(
-					"Preflight timed out with missing messages",
-					"serial", s.device.Serial,
-					"missing", len(required),
-				)
+				s.logger.Warn("Preflight timed out with missing messages", "missing", len(required))
 			}
 			return
 		}
 	}
 }
 
+// pendingPreflight returns the preflight messages not fulfilled by the device state, adding the
+// device-specific ones once the device version is known. Messages that cannot be checked are kept
+// if keepUnchecked is set, i.e. before they are first sent, and dropped otherwise.
+func (s *deviceSession) pendingPreflight(msgs []*protocol.Message, keepUnchecked bool) []*protocol.Message {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	pending := func(m *protocol.Message) bool {
+		f := messageDoneFuncs[m.Payload.PayloadType()]
+		return f == nil && keepUnchecked || f != nil && !f(s.device)
+	}
+	var retryMsgs []*protocol.Message
+	for _, m := range msgs {
+		if pending(m) {
+			retryMsgs = append(retryMsgs, m)
+			continue
+		}
+		if m.Payload.PayloadType() != uint16(packets.PayloadTypeDeviceGetVersion) {
+			continue
+		}
+
+		// Add device-specific preflight messages.
+		var specific []*protocol.Message
+		if s.device.Type == device.DeviceTypeHybrid || s.device.Type == device.DeviceTypeSwitch {
+			specific = append(specific, protocol.NewMessage(&packets.ButtonGet{}))
+		}
+		switch s.device.LightType {
+		case device.LightTypeMatrix:
+			specific = append(specific, protocol.NewMessage(&packets.TileGetDeviceChain{}))
+		case device.LightTypeMultiZone:
+			specific = append(specific, s.device.MultizoneStateMessage())
+		}
+		for _, m := range specific {
+			if f := messageDoneFuncs[m.Payload.PayloadType()]; f == nil || !f(s.device) {
+				retryMsgs = append(retryMsgs, m)
+			}
+		}
+	}
+	return retryMsgs
+}
+
 // requiredStateMessages returns a list of protocol messages to gather critical information
 // about the state of a Device.
 func requiredStateMessages() []*protocol.Message {
@@ -295,21 +594,28 @@ func requiredStateMessages() []*protocol.Message {
 	}
 }
 
-// messageDoneFuncs maps a message to a function to checks whether the message has been fulfilled.
-var messageDoneFuncs = map[packets.Payload]func(*device.Device) bool{
-	&packets.DeviceGetLabel{}:        func(d *device.Device) bool { return d.Label != "" },
-	&packets.DeviceGetVersion{}:      func(d *device.Device) bool { return d.ProductID > 0 },
-	&packets.DeviceGetHostFirmware{}: func(d *device.Device) bool { return d.FirmwareVersion != "" },
-	&packets.DeviceGetLocation{}:     func(d *device.Device) bool { return d.Location != "" },
-	&packets.DeviceGetGroup{}:        func(d *device.Device) bool { return d.Group != "" },
-	&packets.DeviceGetWifiInfo{}:     func(d *device.Device) bool { return d.WifiRSSI != 0 },
-	&packets.TileGetDeviceChain{}: func(d *device.Device) bool {
-		return d.LightType != device.LightTypeMatrix || d.MatrixProperties.ChainLength > 0
-	},
-	&packets.MultiZoneExtendedGetColorZones{}: func(d *device.Device) bool {
-		return d.LightType != device.LightTypeMultiZone || len(d.MultizoneProperties.Zones) > 0
-	},
-	&packets.ButtonGet{}: func(d *device.Device) bool {
+// messageDoneFuncs maps a message payload type to a function to checks whether the message
+// has been fulfilled. Keying by type rather than payload matches queries carrying fields,
+// such as the legacy multizone query.
+var messageDoneFuncs = map[uint16]func(*device.Device) bool{
+	uint16(packets.PayloadTypeDeviceGetLabel):                 func(d *device.Device) bool { return d.Label != "" },
+	uint16(packets.PayloadTypeDeviceGetVersion):               func(d *device.Device) bool { return d.ProductID > 0 },
+	uint16(packets.PayloadTypeDeviceGetHostFirmware):          func(d *device.Device) bool { return d.FirmwareVersion != "" },
+	uint16(packets.PayloadTypeDeviceGetLocation):              func(d *device.Device) bool { return d.Location != "" },
+	uint16(packets.PayloadTypeDeviceGetGroup):                 func(d *device.Device) bool { return d.Group != "" },
+	uint16(packets.PayloadTypeDeviceGetWifiInfo):              func(d *device.Device) bool { return d.WifiRSSI != 0 },
+	uint16(packets.PayloadTypeTileGetDeviceChain):             matrixDone,
+	uint16(packets.PayloadTypeMultiZoneExtendedGetColorZones): multizoneDone,
+	uint16(packets.PayloadTypeMultiZoneGetColorZones):         multizoneDone,
+	uint16(packets.PayloadTypeButtonGet): func(d *device.Device) bool {
 		return d.Type == device.DeviceTypeLight || len(d.Buttons) > 0
 	},
 }
+
+func matrixDone(d *device.Device) bool {
+	return d.LightType != device.LightTypeMatrix || d.MatrixProperties.ChainLength > 0
+}
+
+func multizoneDone(d *device.Device) bool {
+	return d.LightType != device.LightTypeMultiZone || len(d.MultizoneProperties.Zones) > 0
+}
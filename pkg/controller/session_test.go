@@ -1,6 +1,9 @@
 package controller
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"math"
 	"net"
 	"slices"
@@ -36,7 +39,7 @@ func TestSession(t *testing.T) {
 
 	t.Run("Sends initial state messages", func(t *testing.T) {
 		mockClient := newMockClient()
-		session := newDeviceSession(addr0, serial0, mockClient, cfg0, wgDone, onTimeout, discardLogger())
+		session := newDeviceSession(addr0, serial0, mockClient, cfg0, wgDone, onTimeout, nil, discardLogger())
 
 		var gotMsgs []packets.Payload
 	outer:
@@ -57,11 +60,38 @@ func TestSession(t *testing.T) {
 		session.close()
 	})
 
+	t.Run("Skips initial state messages fulfilled by the known state", func(t *testing.T) {
+		mockClient := newMockClient()
+		session := newDeviceSession(addr0, serial0, mockClient, cfg0, wgDone, onTimeout, nil, discardLogger(), func(d *device.Device) {
+			d.Label, d.Location, d.Group = "Strip", "Home", "Kitchen"
+			d.SetProductInfo(55)
+			d.SetMatrixLayout(8, 8, []device.TilePosition{{}}, []device.Orientation{device.OrientationRightSideUp})
+		})
+		defer session.close()
+
+		var gotTypes []uint16
+	outer:
+		for {
+			select {
+			case msg := <-mockClient.sends:
+				gotTypes = append(gotTypes, msg.Type())
+			case <-time.After(10 * time.Millisecond):
+				break outer
+			}
+		}
+
+		assert.Equal(t, []uint16{
+			uint16(packets.PayloadTypeLightGet),
+			uint16(packets.PayloadTypeDeviceGetHostFirmware),
+			uint16(packets.PayloadTypeDeviceGetWifiInfo),
+		}, gotTypes)
+	})
+
 	t.Run("It sends high frequency messages", func(t *testing.T) {
 		cfg := *cfg0
 		cfg.highFrequencyStateRefreshPeriod = time.Millisecond
 		mockClient := newMockClient()
-		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, onTimeout, discardLogger())
+		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, onTimeout, nil, discardLogger())
 
 		var gotMsgs int
 		timeout := time.After(10 * time.Millisecond)
@@ -85,7 +115,7 @@ func TestSession(t *testing.T) {
 		cfg := *cfg0
 		cfg.lowFrequencyStateRefreshPeriod = time.Millisecond
 		mockClient := newMockClient()
-		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, onTimeout, discardLogger())
+		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, onTimeout, nil, discardLogger())
 
 		var gotMsgs []packets.Payload
 		timeout := time.After(10 * time.Millisecond)
@@ -122,16 +152,29 @@ func TestSession(t *testing.T) {
 		cfg.deviceLivenessTimeout = time.Millisecond
 		mockClient := newMockClient()
 		rmChan := make(chan device.Serial, 1)
-		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, func(d device.Serial) { rmChan <- d }, discardLogger())
+		session := newDeviceSession(addr0, serial0, mockClient, &cfg, wgDone, func(d device.Serial) { rmChan <- d }, nil, discardLogger())
 
 		rmSerial := <-rmChan
 		assert.Equal(t, serial0, rmSerial)
 		session.close()
 	})
 
+	t.Run("Annotates logs with the device", func(t *testing.T) {
+		cfg := *cfg0
+		cfg.deviceLivenessTimeout = time.Millisecond
+		var logs bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&logs, nil))
+		rmChan := make(chan device.Serial, 1)
+		session := newDeviceSession(addr0, serial0, newMockClient(), &cfg, wgDone, func(d device.Serial) { rmChan <- d }, nil, logger)
+
+		<-rmChan
+		assert.Contains(t, logs.String(), "msg=\"Device not seen for too long, terminating session\" serial=010000000000 address=192.168.0.10:0")
+		session.close()
+	})
+
 	t.Run("Updates state", func(t *testing.T) {
 		mockClient := newMockClient()
-		session := newDeviceSession(addr0, serial0, mockClient, cfg0, wgDone, onTimeout, discardLogger())
+		session := newDeviceSession(addr0, serial0, mockClient, cfg0, wgDone, onTimeout, nil, discardLogger())
 
 		wantDevice := device.Device{
 			Serial: device.Serial(serial0), Address: addr0,
@@ -161,6 +204,17 @@ func TestSession(t *testing.T) {
 		assert.Equal(t, "LIFX Tile", deviceSnapshot.RegistryName)
 		assert.Equal(t, device.LightTypeMatrix, deviceSnapshot.LightType)
 
+		// Re-profiles a device whose product was not resolved
+		session.mu.Lock()
+		session.device.RegistryName = ""
+		session.device.LightType = device.LightTypeSingleZone
+		session.mu.Unlock()
+		session.inbound <- protocol.NewMessage(&packets.DeviceStateVersion{Product: 55})
+		time.Sleep(10 * time.Millisecond)
+		deviceSnapshot = session.deviceSnapshot()
+		assert.Equal(t, "LIFX Tile", deviceSnapshot.RegistryName)
+		assert.Equal(t, device.LightTypeMatrix, deviceSnapshot.LightType)
+
 		// Updates firmware version
 		session.inbound <- protocol.NewMessage(&packets.DeviceStateHostFirmware{VersionMajor: 3, VersionMinor: 50})
 		time.Sleep(10 * time.Millisecond)
@@ -172,9 +226,10 @@ func TestSession(t *testing.T) {
 		assert.Equal(t, "Home", session.deviceSnapshot().Location)
 
 		// Updates group
-		session.inbound <- protocol.NewMessage(&packets.DeviceStateGroup{Label: [32]byte{'B', 'e', 'd', 'r', 'o', 'o', 'm'}})
+		session.inbound <- protocol.NewMessage(&packets.DeviceStateGroup{Group: [16]byte{1}, Label: [32]byte{'B', 'e', 'd', 'r', 'o', 'o', 'm'}})
 		time.Sleep(10 * time.Millisecond)
 		assert.Equal(t, "Bedroom", session.deviceSnapshot().Group)
+		assert.Equal(t, [16]byte{1}, session.deviceSnapshot().GroupID)
 
 		// Updates matrix properties
 		tileDevices := [16]packets.TileStateDevice{{Width: 8, Height: 8}, {Width: 8, Height: 8}}
@@ -184,6 +239,12 @@ func TestSession(t *testing.T) {
 		assert.Equal(t, int(8), session.deviceSnapshot().MatrixProperties.Width)
 		assert.Equal(t, int(2), session.deviceSnapshot().MatrixProperties.ChainLength)
 
+		// Updates relay power
+		session.inbound <- protocol.NewMessage(&packets.RelayStatePower{RelayIndex: 1, Level: math.MaxUint16})
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, []device.Relay{{}, {Level: math.MaxUint16}}, session.deviceSnapshot().RelayProperties.Relays)
+		assert.True(t, session.deviceSnapshot().PoweredOn)
+
 		// Updates LastSeeenAt
 		nowBeforeUpdate := time.Now()
 		session.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
@@ -224,6 +285,7 @@ func Test_preflightHandshake(t *testing.T) {
 				Label: "SZ", ProductID: 225, FirmwareVersion: "3.90",
 				LightType: device.LightTypeSingleZone, Location: "L", Group: "G",
 				ColorProperties: device.ColorProperties{HasColor: true, TemperatureRange: device.TemperatureRange{Min: 1500, Max: 9000}},
+				Supports:        device.Capabilities{Light: true, Color: true},
 			},
 		},
 		"multizone": {
@@ -238,7 +300,9 @@ func Test_preflightHandshake(t *testing.T) {
 				Address: addr0, Serial: serial0,
 				Label: "MZ", ProductID: 214, FirmwareVersion: "3.90",
 				LightType: device.LightTypeMultiZone, Location: "L", Group: "G",
-				ColorProperties: device.ColorProperties{HasColor: true, TemperatureRange: device.TemperatureRange{Min: 1500, Max: 9000}},
+				ColorProperties:     device.ColorProperties{HasColor: true, TemperatureRange: device.TemperatureRange{Min: 1500, Max: 9000}},
+				MultizoneProperties: device.MultizoneProperties{Extended: true},
+				Supports:            device.Capabilities{Light: true, Color: true, Multizone: true, ExtendedMultizone: true},
 			},
 		},
 		"matrix < 64 zones (hybrid)": {
@@ -260,7 +324,10 @@ func Test_preflightHandshake(t *testing.T) {
 					ChainLength: 1, Width: 7, Height: 5, StatePackets: 1, NZones: 35,
 					ChainZones:        [][]packets.LightHsbk{make([]packets.LightHsbk, 35)},
 					ChainOrientations: []device.Orientation{device.OrientationRightSideUp},
+					ChainPositions:    []device.TilePosition{{}},
+					FrameBuffers:      true,
 				},
+				Supports: device.Capabilities{Light: true, Color: true, Matrix: true, FrameBuffers: true, Buttons: true},
 				Buttons: []device.Button{
 					{Actions: []packets.ButtonAction{}},
 					{Actions: []packets.ButtonAction{}},
@@ -287,7 +354,10 @@ func Test_preflightHandshake(t *testing.T) {
 					ChainLength: 1, Width: 16, Height: 8, StatePackets: 2, NZones: 128,
 					ChainZones:        [][]packets.LightHsbk{make([]packets.LightHsbk, 128)},
 					ChainOrientations: []device.Orientation{device.OrientationRightSideUp},
+					ChainPositions:    []device.TilePosition{{}},
+					FrameBuffers:      true,
 				},
+				Supports: device.Capabilities{Light: true, Color: true, Matrix: true, FrameBuffers: true},
 			},
 		},
 		"switch": {
@@ -303,6 +373,7 @@ func Test_preflightHandshake(t *testing.T) {
 				Address: addr0, Serial: serial0,
 				Label: "SW", ProductID: 116, FirmwareVersion: "3.90",
 				Type: device.DeviceTypeSwitch, Location: "L", Group: "G",
+				Supports: device.Capabilities{Relays: true, Buttons: true},
 				Buttons: []device.Button{
 					{Actions: []packets.ButtonAction{}},
 					{Actions: []packets.ButtonAction{}},
@@ -316,6 +387,7 @@ func Test_preflightHandshake(t *testing.T) {
 			wantDevice: &device.Device{
 				Address: addr0, Serial: serial0, ProductID: 225, LightType: device.LightTypeSingleZone,
 				ColorProperties: device.ColorProperties{HasColor: true, TemperatureRange: device.TemperatureRange{Min: 1500, Max: 9000}},
+				Supports:        device.Capabilities{Light: true, Color: true},
 			},
 		},
 	}
@@ -340,7 +412,7 @@ func Test_preflightHandshake(t *testing.T) {
 
 			done := make(chan struct{})
 			go func() {
-				session.preflightHandshake(preflightHandshakeTimeout, preflightHandshakeWait)
+				session.preflightHandshake(context.Background(), preflightHandshakeTimeout, preflightHandshakeWait)
 				close(done)
 			}()
 
@@ -359,4 +431,139 @@ func Test_preflightHandshake(t *testing.T) {
 			}
 		})
 	}
+
+	t.Run("Stops when ctx is cancelled", func(t *testing.T) {
+		session := &deviceSession{
+			sender: newMockClient(),
+			logger: discardLogger(),
+			device: device.NewDevice(addr0, serial0),
+			done:   make(chan struct{}),
+			cfg:    cfg0,
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			session.preflightHandshake(ctx, time.Minute, time.Minute)
+			close(done)
+		}()
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(10 * time.Millisecond):
+			t.Fatal("Timed out")
+		}
+	})
+}
+
+func Test_pendingPreflight(t *testing.T) {
+	payloadTypes := func(msgs []*protocol.Message) []uint16 {
+		var types []uint16
+		for _, m := range msgs {
+			types = append(types, m.Payload.PayloadType())
+		}
+		return types
+	}
+	versionOnly := []*protocol.Message{protocol.NewMessage(&packets.DeviceGetVersion{})}
+
+	testCases := map[string]struct {
+		device *device.Device
+		want   []uint16
+	}{
+		"legacy multizone without zones": {
+			device: &device.Device{ProductID: 31, LightType: device.LightTypeMultiZone},
+			want:   []uint16{uint16(packets.PayloadTypeMultiZoneGetColorZones)},
+		},
+		"legacy multizone with zones": {
+			device: &device.Device{
+				ProductID: 31, LightType: device.LightTypeMultiZone,
+				MultizoneProperties: device.MultizoneProperties{Zones: make([]packets.LightHsbk, 8)},
+			},
+		},
+		"extended multizone without zones": {
+			device: &device.Device{
+				ProductID: 31, LightType: device.LightTypeMultiZone,
+				MultizoneProperties: device.MultizoneProperties{Extended: true},
+			},
+			want: []uint16{uint16(packets.PayloadTypeMultiZoneExtendedGetColorZones)},
+		},
+		"matrix with chain": {
+			device: &device.Device{
+				ProductID: 55, LightType: device.LightTypeMatrix,
+				MatrixProperties: device.MatrixProperties{ChainLength: 1},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			session := &deviceSession{device: tc.device}
+			assert.Equal(t, tc.want, payloadTypes(session.pendingPreflight(versionOnly, false)))
+		})
+	}
+}
+
+func TestSessionDeviceSnapshot(t *testing.T) {
+	addr0 := &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	s := &deviceSession{
+		logger:  discardLogger(),
+		device:  device.NewDevice(addr0, serial0),
+		inbound: make(chan *protocol.Message),
+		done:    make(chan struct{}),
+		cfg:     &Config{},
+	}
+	s.device.MatrixProperties = device.MatrixProperties{
+		Width: 8, Height: 8, NZones: 64, ChainLength: 1,
+		ChainZones: [][]packets.LightHsbk{make([]packets.LightHsbk, 64)},
+	}
+	s.device.MultizoneProperties.Zones = make([]packets.LightHsbk, 8)
+	go s.recvloop()
+	defer s.close()
+
+	snapshot := s.deviceSnapshot()
+
+	tile := &packets.TileState64{Rect: packets.TileBufferRect{Width: 8}}
+	tile.Colors[0].Hue = 100
+	zones := &packets.MultiZoneExtendedStateMultiZone{Count: 8, ColorsCount: 1}
+	zones.Colors[0].Hue = 200
+	s.inbound <- protocol.NewMessage(tile)
+	s.inbound <- protocol.NewMessage(zones)
+	// Wait for the previous messages to be processed.
+	s.inbound <- protocol.NewMessage(&packets.DeviceStateUnhandled{})
+
+	assert.Equal(t, uint16(0), snapshot.MatrixProperties.ChainZones[0][0].Hue)
+	assert.Equal(t, uint16(0), snapshot.MultizoneProperties.Zones[0].Hue)
+
+	snapshot = s.deviceSnapshot()
+	assert.Equal(t, uint16(100), snapshot.MatrixProperties.ChainZones[0][0].Hue)
+	assert.Equal(t, uint16(200), snapshot.MultizoneProperties.Zones[0].Hue)
+}
+
+func BenchmarkSessionDeviceSnapshot(b *testing.B) {
+	benchmarks := map[string]func(*device.Device){
+		"Bulb": func(*device.Device) {},
+		"Strip": func(d *device.Device) {
+			d.MultizoneProperties.Zones = make([]packets.LightHsbk, 82)
+		},
+		"Tile chain": func(d *device.Device) {
+			d.MatrixProperties.ChainZones = make([][]packets.LightHsbk, 5)
+			for i := range d.MatrixProperties.ChainZones {
+				d.MatrixProperties.ChainZones[i] = make([]packets.LightHsbk, 64)
+			}
+		},
+	}
+
+	for name, setup := range benchmarks {
+		b.Run(name, func(b *testing.B) {
+			s := &deviceSession{device: device.NewDevice(&net.UDPAddr{}, device.Serial{1})}
+			setup(s.device)
+
+			for b.Loop() {
+				_ = s.deviceSnapshot()
+			}
+		})
+	}
 }
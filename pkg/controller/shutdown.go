@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"context"
+	"time"
+)
+
+// shutdownPollPeriod is how often Shutdown checks whether the messages being sent were
+// sent and acknowledged.
+const shutdownPollPeriod = 10 * time.Millisecond
+
+// Shutdown gracefully closes the Controller. It stops discovering devices and stops the software
+// effects after their current frame, then waits for the messages being sent, including those
+// waiting for the rate limiters, and for the acknowledgements of the messages that require one,
+// so that devices are not left mid-animation or with a command lost in flight.
+// Acknowledgements are not waited for longer than a query timeout.
+//
+// The Controller is closed once done or when ctx is done, in which case the context error is
+// returned. Like Close, Shutdown is idempotent.
+func (c *Controller) Shutdown(ctx context.Context) error {
+	c.shuttingDown.Store(true)
+
+	stopped := make(chan struct{})
+	go func() {
+		c.effects.StopAll()
+		close(stopped)
+	}()
+
+	var err error
+	select {
+	case <-stopped:
+		err = c.drain(ctx)
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.Close()
+	return err
+}
+
+// drain waits until no session is sending messages or waiting for acknowledgements,
+// or until ctx is done.
+func (c *Controller) drain(ctx context.Context) error {
+	ticker := time.NewTicker(shutdownPollPeriod)
+	defer ticker.Stop()
+
+	for {
+		if !c.inFlight(time.Now()) {
+			return nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// inFlight reports whether any session is sending messages or waiting for acknowledgements.
+func (c *Controller) inFlight(now time.Time) bool {
+	for _, s := range c.sessionList() {
+		if s.sending.Load() > 0 || s.awaitingAcks(now) {
+			return true
+		}
+	}
+	return false
+}
+
+// awaitingAcks reports whether an acknowledgement is expected for a message sent less than
+// queryTimeout before now.
+func (s *deviceSession) awaitingAcks(now time.Time) bool {
+	deadline := now.Add(-queryTimeout).UnixNano()
+	for i := range s.sentAt {
+		if sent := s.sentAt[i].Load(); sent > deadline && s.acks[i].Load() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerShutdown(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	newController := func(t *testing.T) (*Controller, *deviceSession) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		s := &deviceSession{sender: mockClient, logger: discardLogger(), device: device.NewDevice(addr0, serial0), done: make(chan struct{})}
+		ctrl.sessions[serial0] = s
+		return ctrl, s
+	}
+
+	t.Run("Waits for acknowledgements", func(t *testing.T) {
+		ctrl, s := newController(t)
+		msg := protocol.NewMessage(&packets.LightSetPower{Level: 65535})
+		msg.SetAckRequired(true)
+		require.NoError(t, ctrl.Send(serial0, msg))
+
+		go func() {
+			time.Sleep(30 * time.Millisecond)
+			s.recordResponse(msg.Sequence(), time.Now())
+		}()
+
+		start := time.Now()
+		require.NoError(t, ctrl.Shutdown(context.Background()))
+		assert.GreaterOrEqual(t, time.Since(start), 30*time.Millisecond)
+		assert.Error(t, ctrl.ctx.Err())
+	})
+
+	t.Run("Does not wait for messages without acknowledgement", func(t *testing.T) {
+		ctrl, _ := newController(t)
+		require.NoError(t, ctrl.Send(serial0, protocol.NewMessage(&packets.LightSetPower{Level: 65535})))
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		require.NoError(t, ctrl.Shutdown(ctx))
+	})
+
+	t.Run("Closes when the context is done", func(t *testing.T) {
+		ctrl, _ := newController(t)
+		msg := protocol.NewMessage(&packets.LightSetPower{Level: 65535})
+		msg.SetAckRequired(true)
+		require.NoError(t, ctrl.Send(serial0, msg))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, ctrl.Shutdown(ctx), context.DeadlineExceeded)
+		assert.Error(t, ctrl.ctx.Err())
+	})
+
+	t.Run("Stops effects after their frame", func(t *testing.T) {
+		ctrl, _ := newController(t)
+		frames := make(chan struct{}, 100)
+		ctrl.Effects().Start(serial0, "loop", func(ctx context.Context, send matrix.SendFunc) error {
+			for {
+				if err := send(protocol.NewMessage(&packets.LightGet{})); err != nil {
+					return err
+				}
+				frames <- struct{}{}
+				time.Sleep(time.Millisecond)
+			}
+		})
+		<-frames
+
+		require.NoError(t, ctrl.Shutdown(context.Background()))
+		status, ok := ctrl.Effects().Status(serial0)
+		require.True(t, ok)
+		assert.Equal(t, matrix.EffectStopped, status.State)
+	})
+}
@@ -0,0 +1,213 @@
+//go:build soak
+
+// The soak test runs a controller against simulated devices for a long period,
+// churning sessions, effects and discovery, and fails if goroutines or the live heap
+// keep growing. It is excluded from regular runs and enabled with the soak build tag:
+//
+//	go test -tags soak -run TestSoak -timeout 0 ./pkg/controller -soak.duration=4h
+package controller
+
+import (
+	"context"
+	"flag"
+	"math/rand/v2"
+	"net"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	soakDuration       = flag.Duration("soak.duration", time.Minute, "duration of the soak test")
+	soakDevices        = flag.Int("soak.devices", 50, "number of simulated devices")
+	soakChurnInterval  = flag.Duration("soak.churn", 50*time.Millisecond, "interval between session and effect churns")
+	soakSampleInterval = flag.Duration("soak.sample", 10*time.Second, "interval between runtime metrics samples")
+	soakMaxGoroutines  = flag.Int("soak.max-goroutines", 20, "allowed goroutines growth over the baseline")
+	soakMaxHeapGrowth  = flag.Float64("soak.max-heap-growth", 2, "allowed live heap growth factor over the baseline")
+)
+
+const (
+	metricGoroutines = "/sched/goroutines:goroutines"
+	metricLiveHeap   = "/gc/heap/live:bytes"
+)
+
+func TestSoak(t *testing.T) {
+	sc := newSoakClient(*soakDevices)
+	ctrl, err := New(
+		WithClient(sc),
+		WithDiscoveryPeriod(100*time.Millisecond),
+		WithHFStateRefreshPeriod(time.Second),
+		WithLFStateRefreshPeriod(5*time.Second),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wait for all devices to be discovered before taking the baseline.
+	assert.Eventually(t, func() bool { return len(ctrl.GetDevices()) == *soakDevices }, 30*time.Second, 100*time.Millisecond)
+	baseline := sampleRuntime()
+	t.Logf("baseline: %d goroutines, %d live heap bytes", baseline.goroutines, baseline.liveHeap)
+
+	ctx, cancel := context.WithTimeout(context.Background(), *soakDuration)
+	defer cancel()
+
+	churn := time.NewTicker(*soakChurnInterval)
+	defer churn.Stop()
+	sample := time.NewTicker(*soakSampleInterval)
+	defer sample.Stop()
+
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-churn.C:
+			serial := sc.serials[rand.IntN(len(sc.serials))]
+			switch rand.IntN(3) {
+			case 0:
+				// Simulate a device going offline, the session is recreated on the next discovery.
+				ctrl.terminateSession(serial)
+			case 1:
+				ctrl.Effects().Start(serial, "soak", func(ctx context.Context, send protocol.SendFunc) error {
+					return matrix.WaterfallCtx(ctx, matrix.New(8, 8, 1), send, 10, 0, matrix.ChainModeNone, packets.LightHsbk{Brightness: 65535})
+				})
+			case 2:
+				_ = ctrl.Effects().Stop(serial)
+			}
+		case <-sample.C:
+			s := sampleRuntime()
+			t.Logf("%s: %d goroutines, %d live heap bytes, %d sessions", time.Now().Format(time.TimeOnly), s.goroutines, s.liveHeap, len(ctrl.GetDevices()))
+		}
+	}
+
+	// Let sessions and effects settle before comparing with the baseline.
+	ctrl.Effects().StopAll()
+	assert.Eventually(t, func() bool { return len(ctrl.GetDevices()) == *soakDevices }, 30*time.Second, 100*time.Millisecond)
+	time.Sleep(time.Second)
+
+	final := sampleRuntime()
+	t.Logf("final: %d goroutines, %d live heap bytes", final.goroutines, final.liveHeap)
+	assert.LessOrEqual(t, final.goroutines, baseline.goroutines+uint64(*soakMaxGoroutines), "goroutines leaked")
+	assert.LessOrEqual(t, float64(final.liveHeap), float64(baseline.liveHeap)**soakMaxHeapGrowth, "live heap keeps growing")
+
+	ctrl.Close()
+}
+
+type runtimeSample struct {
+	goroutines uint64
+	liveHeap   uint64
+}
+
+// sampleRuntime returns the current runtime metrics after a garbage collection.
+func sampleRuntime() runtimeSample {
+	runtime.GC()
+	samples := []metrics.Sample{{Name: metricGoroutines}, {Name: metricLiveHeap}}
+	metrics.Read(samples)
+	return runtimeSample{goroutines: samples[0].Value.Uint64(), liveHeap: samples[1].Value.Uint64()}
+}
+
+// soakClient simulates a network of light devices replying to discovery and state messages.
+type soakClient struct {
+	serials []device.Serial
+	addrs   map[string]device.Serial
+	inbound chan recvMsg
+
+	once sync.Once
+	done chan struct{}
+}
+
+func newSoakClient(n int) *soakClient {
+	c := &soakClient{
+		addrs:   make(map[string]device.Serial, n),
+		inbound: make(chan recvMsg, 1024),
+		done:    make(chan struct{}),
+	}
+	for i := range n {
+		serial := device.Serial([8]byte{0xd0, 0x73, 0xd5, 0, byte(i >> 8), byte(i)})
+		c.serials = append(c.serials, serial)
+		c.addrs[c.addr(i).String()] = serial
+	}
+	return c
+}
+
+func (c *soakClient) addr(i int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(10, 0, byte(i>>8), byte(i)), Port: lifxPort}
+}
+
+func (c *soakClient) Send(dst *net.UDPAddr, msg *protocol.Message) error {
+	serial, ok := c.addrs[dst.String()]
+	if !ok {
+		return nil
+	}
+
+	var reply packets.Payload
+	switch msg.Payload.(type) {
+	case *packets.DeviceGetLabel:
+		reply = &packets.DeviceStateLabel{Label: [32]byte{'s', 'o', 'a', 'k'}}
+	case *packets.DeviceGetVersion:
+		reply = &packets.DeviceStateVersion{Vendor: 1, Product: 27}
+	case *packets.DeviceGetHostFirmware:
+		reply = &packets.DeviceStateHostFirmware{VersionMajor: 3, VersionMinor: 70}
+	case *packets.DeviceGetLocation:
+		reply = &packets.DeviceStateLocation{Label: [32]byte{'h', 'o', 'm', 'e'}}
+	case *packets.DeviceGetGroup:
+		reply = &packets.DeviceStateGroup{Label: [32]byte{'s', 'o', 'a', 'k'}}
+	case *packets.DeviceGetWifiInfo:
+		reply = &packets.DeviceStateWifiInfo{Signal: 0.0001}
+	case *packets.DeviceGetPower:
+		reply = &packets.DeviceStatePower{Level: 65535}
+	case *packets.LightGet:
+		reply = &packets.LightState{Power: 65535, Color: packets.LightHsbk{Brightness: 65535, Kelvin: 3500}}
+	default:
+		return nil
+	}
+	c.reply(dst, serial, reply)
+	return nil
+}
+
+func (c *soakClient) SendBroadcast(msg *protocol.Message) error {
+	for i, serial := range c.serials {
+		c.reply(c.addr(i), serial, &packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP, Port: lifxPort})
+	}
+	return nil
+}
+
+func (c *soakClient) reply(addr *net.UDPAddr, serial device.Serial, payload packets.Payload) {
+	msg := protocol.NewMessage(payload)
+	msg.SetTarget(serial)
+	select {
+	case c.inbound <- recvMsg{addr: addr, msg: msg}:
+	case <-c.done:
+	default:
+		// Drop replies when the controller falls behind, as a congested network would.
+	}
+}
+
+func (c *soakClient) Receive(timeout time.Duration, recvOne bool, handler client.HandlerFunc) error {
+	for {
+		select {
+		case r := <-c.inbound:
+			handler(r.msg, r.addr)
+		case <-c.done:
+			return nil
+		}
+	}
+}
+
+func (c *soakClient) SetConnDeadline(t time.Time) error {
+	c.once.Do(func() { close(c.done) })
+	return nil
+}
+
+func (c *soakClient) Close() error {
+	return nil
+}
@@ -0,0 +1,129 @@
+package controller
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// Status is a self-report of a Controller, e.g. for the health checks of daemons built on it.
+type Status struct {
+	// Sessions is the number of device sessions.
+	Sessions int
+	// DeviceTypes counts the devices by type, and LightTypes the lights and hybrids by light type.
+	DeviceTypes map[device.DeviceType]int
+	LightTypes  map[device.LightType]int
+	// Suspended is set between NotifySuspend and NotifyResume.
+	Suspended bool
+	// LastDiscoveryAt is the time discovery packets were last sent.
+	LastDiscoveryAt time.Time
+	// InboundSaturation is the highest fill ratio of the session inbound queues, between 0 and 1.
+	// Messages are dropped when a queue is full, see WithInboundOverflowPolicy.
+	InboundSaturation float64
+	// InboundDropped is the number of received messages dropped by all sessions because their queue was full.
+	InboundDropped uint64
+	// LastError is the last error met in the background, e.g. while polling devices or
+	// discovering them, and LastErrorAt the time it happened. LastError is nil if none.
+	LastError   error
+	LastErrorAt time.Time
+	// Devices holds the status of each session, sorted by serial.
+	Devices []SessionStatus
+}
+
+// SessionStatus is the status of a device session.
+type SessionStatus struct {
+	Serial   device.Serial
+	Label    string
+	Profiled bool
+	// DiscoveredAt is the time the session was created, and DiscoveryAge the time since then.
+	DiscoveredAt time.Time
+	DiscoveryAge time.Duration
+	LastSeenAt   time.Time
+	// Inbound is the number of received messages waiting to be processed, out of InboundCapacity,
+	// and InboundDropped the number of messages dropped because the queue was full.
+	Inbound         int
+	InboundCapacity int
+	InboundDropped  uint64
+}
+
+// Status returns a report of the Controller sessions and background activity.
+func (c *Controller) Status() Status {
+	st := Status{
+		DeviceTypes: make(map[device.DeviceType]int),
+		LightTypes:  make(map[device.LightType]int),
+		Suspended:   c.suspended.Load(),
+	}
+	if sent := c.discoveredAt.Load(); sent != 0 {
+		st.LastDiscoveryAt = time.Unix(0, sent)
+	}
+	st.LastError, st.LastErrorAt = c.cfg.lastError.get()
+
+	now := time.Now()
+	for _, s := range c.sessionList() {
+		ss := s.status(now)
+		st.Devices = append(st.Devices, ss)
+		st.InboundDropped += ss.InboundDropped
+		if ss.InboundCapacity > 0 {
+			st.InboundSaturation = max(st.InboundSaturation, float64(ss.Inbound)/float64(ss.InboundCapacity))
+		}
+
+		s.mu.RLock()
+		st.DeviceTypes[s.device.Type]++
+		if s.device.Type != device.DeviceTypeSwitch {
+			st.LightTypes[s.device.LightType]++
+		}
+		s.mu.RUnlock()
+	}
+	st.Sessions = len(st.Devices)
+	slices.SortFunc(st.Devices, func(a, b SessionStatus) int {
+		return bytes.Compare(a.Serial[:], b.Serial[:])
+	})
+	return st
+}
+
+// status returns the status of the session at the given time.
+func (s *deviceSession) status(now time.Time) SessionStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return SessionStatus{
+		Serial:          s.device.Serial,
+		Label:           s.device.Label,
+		Profiled:        s.device.Profiled(),
+		DiscoveredAt:    s.createdAt,
+		DiscoveryAge:    now.Sub(s.createdAt),
+		LastSeenAt:      s.device.LastSeenAt,
+		Inbound:         len(s.inbound),
+		InboundCapacity: cap(s.inbound),
+		InboundDropped:  s.inboundDropped.Load(),
+	}
+}
+
+// lastError records the last error met in the background, to be reported by Status.
+type lastError struct {
+	mu  sync.Mutex
+	err error
+	at  time.Time
+}
+
+// record records err, if not nil. It is a no-op on a nil lastError.
+func (l *lastError) record(err error) {
+	if l == nil || err == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.err, l.at = err, time.Now()
+}
+
+// get returns the last error recorded and the time it happened.
+func (l *lastError) get() (error, time.Time) {
+	if l == nil {
+		return nil, time.Time{}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.err, l.at
+}
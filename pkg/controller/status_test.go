@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestControllerStatus(t *testing.T) {
+	mockClient := newMockClient()
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	createdAt := time.Now().Add(-time.Minute)
+	for i, d := range []struct {
+		deviceType device.DeviceType
+		lightType  device.LightType
+		queued     int
+	}{
+		{deviceType: device.DeviceTypeLight, lightType: device.LightTypeMatrix, queued: 5},
+		{deviceType: device.DeviceTypeSwitch},
+		{deviceType: device.DeviceTypeLight, lightType: device.LightTypeMatrix},
+	} {
+		serial := device.Serial([8]byte{byte(3 - i)})
+		dev := device.NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, byte(i+1))}, serial)
+		dev.Type, dev.LightType = d.deviceType, d.lightType
+		s := &deviceSession{
+			sender:    mockClient,
+			logger:    discardLogger(),
+			device:    dev,
+			inbound:   make(chan *protocol.Message, defaultRecvBufferSize),
+			done:      make(chan struct{}),
+			createdAt: createdAt,
+		}
+		for range d.queued {
+			s.inbound <- protocol.NewMessage(&packets.LightGet{})
+		}
+		ctrl.sessions[serial] = s
+	}
+	ctrl.cfg.lastError.record(errors.New("boom"))
+
+	st := ctrl.Status()
+	assert.Equal(t, 3, st.Sessions)
+	assert.Equal(t, map[device.DeviceType]int{device.DeviceTypeLight: 2, device.DeviceTypeSwitch: 1}, st.DeviceTypes)
+	assert.Equal(t, map[device.LightType]int{device.LightTypeMatrix: 2}, st.LightTypes)
+	assert.InDelta(t, 0.5, st.InboundSaturation, 1e-9)
+	assert.EqualError(t, st.LastError, "boom")
+	assert.WithinDuration(t, time.Now(), st.LastErrorAt, time.Second)
+	assert.WithinDuration(t, time.Now(), st.LastDiscoveryAt, time.Second)
+	assert.False(t, st.Suspended)
+
+	require.Len(t, st.Devices, 3)
+	assert.Equal(t, device.Serial([8]byte{1}), st.Devices[0].Serial)
+	assert.Equal(t, device.Serial([8]byte{3}), st.Devices[2].Serial)
+	assert.Equal(t, 5, st.Devices[2].Inbound)
+	assert.Equal(t, defaultRecvBufferSize, st.Devices[2].InboundCapacity)
+	assert.Equal(t, createdAt, st.Devices[0].DiscoveredAt)
+	assert.GreaterOrEqual(t, st.Devices[0].DiscoveryAge, time.Minute)
+}
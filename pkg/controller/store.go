@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// DeviceStore persists the devices known to a Controller between runs.
+type DeviceStore interface {
+	// Load returns the devices saved by the last call to Save, if any.
+	Load() ([]device.Device, error)
+	// Save replaces the saved devices with the given ones.
+	Save(devices []device.Device) error
+}
+
+// FileStore is a DeviceStore saving the identity and layout of devices as JSON to a file.
+// It does not save the device state, such as power and colors, which is refreshed on startup.
+type FileStore struct {
+	path string
+}
+
+// NewFileStore returns a FileStore saving devices to the file at path.
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+// cachedDevice is the representation of a device saved by a FileStore.
+type cachedDevice struct {
+	Serial    string        `json:"serial"`
+	Address   string        `json:"address"`
+	Label     string        `json:"label,omitempty"`
+	ProductID uint32        `json:"product_id,omitempty"`
+	Matrix    *cachedMatrix `json:"matrix,omitempty"`
+}
+
+type cachedMatrix struct {
+	Width        int                   `json:"width"`
+	Height       int                   `json:"height"`
+	Positions    []device.TilePosition `json:"positions"`
+	Orientations []device.Orientation  `json:"orientations"`
+}
+
+// Load returns the devices saved to the file. It returns no devices if the file does not exist.
+func (s *FileStore) Load() ([]device.Device, error) {
+	b, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cached []cachedDevice
+	if err := json.Unmarshal(b, &cached); err != nil {
+		return nil, fmt.Errorf("failed to decode device cache %s: %w", s.path, err)
+	}
+
+	devices := make([]device.Device, 0, len(cached))
+	for _, cd := range cached {
+		serial, err := device.SerialFromHex(cd.Serial)
+		if err != nil {
+			return nil, fmt.Errorf("invalid serial %q in device cache: %w", cd.Serial, err)
+		}
+		addr, err := net.ResolveUDPAddr("udp", cd.Address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %q in device cache: %w", cd.Address, err)
+		}
+
+		d := device.NewDevice(addr, serial)
+		d.Label = cd.Label
+		if cd.ProductID != 0 {
+			d.SetProductInfo(cd.ProductID)
+		}
+		if m := cd.Matrix; m != nil {
+			d.SetMatrixLayout(m.Width, m.Height, m.Positions, m.Orientations)
+		}
+		devices = append(devices, *d)
+	}
+	return devices, nil
+}
+
+// Save writes the devices to the file, replacing it atomically.
+func (s *FileStore) Save(devices []device.Device) error {
+	cached := make([]cachedDevice, 0, len(devices))
+	for _, d := range devices {
+		if d.Address == nil {
+			continue
+		}
+		cd := cachedDevice{
+			Serial:    d.Serial.String(),
+			Address:   d.Address.String(),
+			Label:     d.Label,
+			ProductID: d.ProductID,
+		}
+		if m := d.MatrixProperties; m.ChainLength > 0 {
+			cd.Matrix = &cachedMatrix{Width: m.Width, Height: m.Height, Positions: m.ChainPositions, Orientations: m.ChainOrientations}
+		}
+		cached = append(cached, cd)
+	}
+
+	b, err := json.MarshalIndent(cached, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(s.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), s.path)
+}
+
+// restoreDevices creates a session for each device in the store, so that they are
+// available before discovery completes. Devices that are no longer reachable are
+// terminated by the liveness check.
+func (c *Controller) restoreDevices() {
+	devices, err := c.store.Load()
+	if err != nil {
+		c.logger.Warn("Failed to load device cache", "error", err)
+		return
+	}
+	for _, d := range devices {
+		if c.session(d.Serial) != nil {
+			continue
+		}
+		c.addSession(d.Address, d.Serial, func(sd *device.Device) {
+			sd.Label = d.Label
+			if d.ProductID != 0 {
+				sd.SetProductInfo(d.ProductID)
+			}
+			if m := d.MatrixProperties; m.ChainLength > 0 {
+				sd.SetMatrixLayout(m.Width, m.Height, m.ChainPositions, m.ChainOrientations)
+			}
+		})
+	}
+	c.logger.Debug("Restored devices from cache", "count", len(devices))
+}
+
+// saveDevices saves the devices known to the Controller to the store, if any.
+func (c *Controller) saveDevices() {
+	if c.store == nil {
+		return
+	}
+	if err := c.store.Save(c.GetDevices()); err != nil {
+		c.logger.Warn("Failed to save device cache", "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+		addr1   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 11), Port: lifxPort}
+		serial0 = device.Serial([8]byte{1, 2, 3, 4, 5, 6, 0, 0})
+		serial1 = device.Serial([8]byte{2, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	t.Run("Saves and loads devices", func(t *testing.T) {
+		tile := device.NewDevice(addr0, serial0)
+		tile.Label = "Tiles"
+		tile.SetProductInfo(55)
+		tile.SetMatrixLayout(8, 8,
+			[]device.TilePosition{{X: 0}, {X: 1, Y: 0.5}},
+			[]device.Orientation{device.OrientationRightSideUp, device.OrientationUpsideDown},
+		)
+		tile.PoweredOn = true
+		bulb := device.NewDevice(addr1, serial1)
+		bulb.Label = "Bulb"
+
+		store := NewFileStore(filepath.Join(t.TempDir(), "cache", "devices.json"))
+		require.NoError(t, store.Save([]device.Device{*tile, *bulb, {Serial: device.Serial{3}}}))
+
+		got, err := store.Load()
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+
+		// Only identity and layout are saved.
+		wantTile := *tile
+		wantTile.PoweredOn = false
+		assert.Equal(t, wantTile, got[0])
+		assert.Equal(t, *bulb, got[1])
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		got, err := NewFileStore(filepath.Join(t.TempDir(), "devices.json")).Load()
+		assert.NoError(t, err)
+		assert.Empty(t, got)
+	})
+
+	t.Run("Invalid file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "devices.json")
+		require.NoError(t, os.WriteFile(path, []byte(`[{"serial": "nope"}]`), 0o644))
+		_, err := NewFileStore(path).Load()
+		assert.Error(t, err)
+	})
+}
+
+func TestWithDeviceCache(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: lifxPort}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		path    = filepath.Join(t.TempDir(), "devices.json")
+	)
+
+	d := device.NewDevice(addr0, serial0)
+	d.Label = "Desk"
+	d.SetProductInfo(55)
+	require.NoError(t, NewFileStore(path).Save([]device.Device{*d}))
+
+	mockClient := newMockClient()
+	mockClient.acknowledge = func(msg *protocol.Message) {
+		ack := protocol.NewMessage(&packets.DeviceAcknowledgement{})
+		ack.SetTarget(serial0)
+		ack.SetSequence(msg.Sequence())
+		go func() { mockClient.inbound <- recvMsg{msg: ack, addr: addr0} }()
+	}
+	ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour), WithDeviceCache(path))
+	require.NoError(t, err)
+
+	devices := ctrl.GetDevices()
+	require.Len(t, devices, 1)
+	assert.Equal(t, serial0, devices[0].Serial)
+	assert.Equal(t, "Desk", devices[0].Label)
+	assert.Equal(t, device.LightTypeMatrix, devices[0].LightType)
+
+	require.NoError(t, ctrl.SetLabel(serial0, "Office"))
+	require.NoError(t, ctrl.Close())
+
+	saved, err := NewFileStore(path).Load()
+	require.NoError(t, err)
+	require.Len(t, saved, 1)
+	assert.Equal(t, "Office", saved[0].Label)
+
+	_, err = New(WithClient(newMockClient()), WithDeviceCache(""))
+	assert.Error(t, err)
+}
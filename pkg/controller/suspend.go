@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"time"
+)
+
+// NotifySuspend informs the Controller that the host is about to sleep.
+// Discovery, state refreshes and liveness checks are paused until NotifyResume is called,
+// so that sessions are not terminated because devices could not reply while the host was asleep.
+// Messages sent explicitly, e.g. with Send, are not affected.
+func (c *Controller) NotifySuspend() {
+	if !c.suspended.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.mu.RLock()
+	for _, s := range c.sessions {
+		s.suspend()
+	}
+	c.mu.RUnlock()
+
+	c.logger.Info("Controller suspended")
+}
+
+// NotifyResume informs the Controller that the host woke up from sleep.
+// Devices are rediscovered immediately and the liveness of each session is reset,
+// giving devices a full liveness timeout to reply to the state refresh sent on resume.
+func (c *Controller) NotifyResume() {
+	if !c.suspended.CompareAndSwap(true, false) {
+		return
+	}
+
+	now := time.Now()
+	c.mu.RLock()
+	for _, s := range c.sessions {
+		s.resume(now)
+	}
+	c.mu.RUnlock()
+
+	c.logger.Info("Controller resumed")
+	if c.cfg.discoveryDisabled {
+		return
+	}
+	if err := c.Discover(); err != nil {
+		c.logger.Debug("Discovery on resume failed", "error", err)
+	}
+}
+
+// suspend pauses the periodic state refreshes and liveness checks of the session.
+func (s *deviceSession) suspend() {
+	s.suspended.Store(true)
+}
+
+// resume resets the session liveness as of now, resumes its periodic checks
+// and wakes its run loop to refresh the device state immediately.
+func (s *deviceSession) resume(now time.Time) {
+	// Reset liveness before resuming checks, so that a check cannot see the stale last seen time.
+	s.resumedAt.Store(now.UnixNano())
+	s.suspended.Store(false)
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// lastSeen returns the time from which the session liveness is measured,
+// i.e. the latest of the last time the device was seen and the last resume.
+func (s *deviceSession) lastSeen() time.Time {
+	s.mu.RLock()
+	last := s.device.LastSeenAt
+	s.mu.RUnlock()
+
+	if r := s.resumedAt.Load(); r != 0 && time.Unix(0, r).After(last) {
+		return time.Unix(0, r)
+	}
+	return last
+}
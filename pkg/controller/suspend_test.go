@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuspendResume(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	t.Run("Suspended sessions are not terminated for liveness", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		ctrl.cfg.deviceLivenessTimeout = 2 * time.Millisecond
+		ctrl.cfg.preflightHandshakeTimeout = time.Millisecond
+		ctrl.cfg.preflightHandshakeWait = time.Millisecond
+
+		ctrl.NotifySuspend()
+		ctrl.addSession(addr0, serial0)
+		time.Sleep(20 * time.Millisecond)
+		assert.NotNil(t, ctrl.session(serial0))
+
+		ctrl.NotifyResume()
+		assert.Eventually(t, func() bool {
+			return ctrl.session(serial0) == nil
+		}, time.Second, time.Millisecond)
+	})
+
+	t.Run("Skips discovery while suspended and rediscovers on resume", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Millisecond))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		ctrl.NotifySuspend()
+		// Let any in-flight discovery complete before draining.
+		time.Sleep(5 * time.Millisecond)
+		drain(mockClient.broadcasts)
+
+		time.Sleep(20 * time.Millisecond)
+		assert.Empty(t, mockClient.broadcasts)
+
+		ctrl.NotifyResume()
+		assert.NotEmpty(t, mockClient.broadcasts)
+	})
+
+	t.Run("Notifications are idempotent", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoveryPeriod(time.Hour))
+		require.NoError(t, err)
+		defer ctrl.Close()
+		drain(mockClient.broadcasts)
+
+		// Resuming a running Controller does not rediscover.
+		ctrl.NotifyResume()
+		assert.Empty(t, mockClient.broadcasts)
+
+		ctrl.NotifySuspend()
+		ctrl.NotifySuspend()
+		assert.True(t, ctrl.suspended.Load())
+		ctrl.NotifyResume()
+		assert.False(t, ctrl.suspended.Load())
+		assert.Len(t, mockClient.broadcasts, 1)
+	})
+}
+
+func TestSessionResume(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		cfg     = &Config{
+			highFrequencyStateRefreshPeriod: time.Hour,
+			lowFrequencyStateRefreshPeriod:  time.Hour,
+			preflightHandshakeTimeout:       time.Millisecond,
+			preflightHandshakeWait:          time.Millisecond,
+			deviceLivenessTimeout:           time.Hour,
+		}
+	)
+
+	t.Run("Resets liveness", func(t *testing.T) {
+		session := &deviceSession{device: device.NewDevice(addr0, serial0)}
+		seenAt := time.Now().Add(-time.Hour)
+		session.device.LastSeenAt = seenAt
+		assert.Equal(t, seenAt, session.lastSeen())
+
+		now := time.Now()
+		session.suspend()
+		assert.True(t, session.suspended.Load())
+		session.resume(now)
+		assert.False(t, session.suspended.Load())
+		assert.True(t, now.Equal(session.lastSeen()))
+
+		// A device seen after the resume is measured from when it was seen.
+		session.device.LastSeenAt = now.Add(time.Second)
+		assert.Equal(t, now.Add(time.Second), session.lastSeen())
+	})
+
+	t.Run("Refreshes state on resume", func(t *testing.T) {
+		mockClient := newMockClient()
+		session := newDeviceSession(addr0, serial0, mockClient, cfg, func() {}, func(device.Serial) {}, nil, discardLogger())
+		defer session.close()
+
+		// Wait for the preflight handshake to complete.
+		time.Sleep(10 * time.Millisecond)
+		drain(mockClient.sends)
+
+		session.suspend()
+		session.resume(time.Now())
+
+		select {
+		case msg := <-mockClient.sends:
+			assert.Equal(t, uint16(packets.PayloadTypeLightGet), msg.Type())
+		case <-time.After(time.Second):
+			t.Fatal("no state refresh sent on resume")
+		}
+	})
+}
+
+// drain discards any value buffered in ch.
+func drain[T any](ch chan T) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"iter"
+	"net"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+const (
+	defaultSubnetSweepPeriod      = 5 * time.Minute
+	defaultSubnetSweepConcurrency = 16
+	// defaultSubnetSweepRate paces sweeps to 1000 packets per second,
+	// so that a /24 subnet takes about 0.25s and a /16 about 65s.
+	defaultSubnetSweepRate = 1000
+	// minSubnetSweepPrefix limits sweeps to at most 65536 addresses.
+	minSubnetSweepPrefix = 16
+)
+
+// SweepSubnets unicasts a LIFX discover packet to every host address of the
+// configured discovery subnets, with at most the configured number of concurrent sends
+// and paced at the configured rate. It stops when the Controller is closed.
+func (c *Controller) SweepSubnets() error {
+	if len(c.cfg.discoverySubnets) == 0 {
+		return nil
+	}
+
+	addrs := make(chan netip.Addr)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+	)
+	for range max(1, c.cfg.subnetSweepConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range addrs {
+				dst := net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr, lifxPort))
+				if err := c.sendDiscovery(dst); err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	var tick <-chan time.Time
+	if c.cfg.subnetSweepRate > 0 {
+		if interval := time.Second / time.Duration(c.cfg.subnetSweepRate); interval > 0 {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			tick = ticker.C
+		}
+	}
+
+sweep:
+	for _, subnet := range c.cfg.discoverySubnets {
+		for addr := range subnetHosts(subnet) {
+			if tick != nil {
+				select {
+				case <-tick:
+				case <-c.recvDone:
+					break sweep
+				}
+			}
+			// Check for closure first, as select picks randomly among ready cases.
+			select {
+			case <-c.recvDone:
+				break sweep
+			default:
+			}
+			select {
+			case addrs <- addr:
+			case <-c.recvDone:
+				break sweep
+			}
+		}
+	}
+	close(addrs)
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// parseDiscoverySubnet parses an IPv4 CIDR suitable for a discovery sweep.
+func parseDiscoverySubnet(cidr string) (netip.Prefix, error) {
+	p, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid discovery subnet: %w", err)
+	}
+	if !p.Addr().Is4() {
+		return netip.Prefix{}, fmt.Errorf("invalid discovery subnet %q: only IPv4 is supported", cidr)
+	}
+	if p.Bits() < minSubnetSweepPrefix {
+		return netip.Prefix{}, fmt.Errorf("invalid discovery subnet %q: prefix must be at least /%d", cidr, minSubnetSweepPrefix)
+	}
+	return p.Masked(), nil
+}
+
+// subnetHosts returns an iterator over the host addresses of an IPv4 subnet,
+// skipping the network and broadcast addresses for subnets larger than /31.
+func subnetHosts(p netip.Prefix) iter.Seq[netip.Addr] {
+	return func(yield func(netip.Addr) bool) {
+		first := p.Addr()
+		last := lastAddr(p)
+		if p.Bits() < 31 {
+			first, last = first.Next(), last.Prev()
+		}
+		for addr := first; addr.IsValid() && addr.Compare(last) <= 0; addr = addr.Next() {
+			if !yield(addr) {
+				return
+			}
+		}
+	}
+}
+
+// lastAddr returns the last address of an IPv4 subnet.
+func lastAddr(p netip.Prefix) netip.Addr {
+	b := p.Addr().As4()
+	hostBits := 32 - p.Bits()
+	for i := 3; i >= 0 && hostBits > 0; i-- {
+		n := min(hostBits, 8)
+		b[i] |= byte(1<<n - 1)
+		hostBits -= n
+	}
+	return netip.AddrFrom4(b)
+}
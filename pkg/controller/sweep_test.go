@@ -0,0 +1,148 @@
+package controller
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_parseDiscoverySubnet(t *testing.T) {
+	testCases := map[string]struct {
+		cidr    string
+		want    netip.Prefix
+		wantErr bool
+	}{
+		"valid": {
+			cidr: "192.168.1.0/24",
+			want: netip.MustParsePrefix("192.168.1.0/24"),
+		},
+		"masks host bits": {
+			cidr: "192.168.1.7/24",
+			want: netip.MustParsePrefix("192.168.1.0/24"),
+		},
+		"too large": {
+			cidr:    "10.0.0.0/8",
+			wantErr: true,
+		},
+		"IPv6": {
+			cidr:    "fe80::/120",
+			wantErr: true,
+		},
+		"invalid": {
+			cidr:    "192.168.1.0",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseDiscoverySubnet(tc.cidr)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func Test_subnetHosts(t *testing.T) {
+	testCases := map[string]struct {
+		cidr      string
+		wantCount int
+		wantFirst string
+		wantLast  string
+	}{
+		"/24": {cidr: "192.168.1.0/24", wantCount: 254, wantFirst: "192.168.1.1", wantLast: "192.168.1.254"},
+		"/20": {cidr: "10.0.16.0/20", wantCount: 4094, wantFirst: "10.0.16.1", wantLast: "10.0.31.254"},
+		"/30": {cidr: "10.0.0.4/30", wantCount: 2, wantFirst: "10.0.0.5", wantLast: "10.0.0.6"},
+		"/31": {cidr: "10.0.0.4/31", wantCount: 2, wantFirst: "10.0.0.4", wantLast: "10.0.0.5"},
+		"/32": {cidr: "10.0.0.4/32", wantCount: 1, wantFirst: "10.0.0.4", wantLast: "10.0.0.4"},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			hosts := slices.Collect(subnetHosts(netip.MustParsePrefix(tc.cidr)))
+			require.Len(t, hosts, tc.wantCount)
+			assert.Equal(t, tc.wantFirst, hosts[0].String())
+			assert.Equal(t, tc.wantLast, hosts[len(hosts)-1].String())
+		})
+	}
+}
+
+func TestSweepSubnets(t *testing.T) {
+	t.Run("Sweeps configured subnets on start", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl, err := New(WithClient(mockClient), WithDiscoverySubnet("10.0.0.0/30"), WithDiscoverySubnet("10.0.1.0/30"))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		// Sends are paced at the default sweep rate.
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, 4, len(mockClient.sends))
+		msg := <-mockClient.sends
+		assert.Equal(t, uint16(packets.PayloadTypeDeviceGetService), msg.Type())
+	})
+
+	t.Run("Limits concurrency", func(t *testing.T) {
+		mockClient := newMockClient()
+		// Do not use New to prevent the initial sweep from running.
+		ctrl := &Controller{
+			client:   mockClient,
+			recvDone: make(chan struct{}),
+			cfg: &Config{
+				discoverySubnets:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/27")},
+				subnetSweepConcurrency: 2,
+			},
+		}
+		require.NoError(t, ctrl.SweepSubnets())
+		assert.Equal(t, 30, len(mockClient.sends))
+	})
+
+	t.Run("Paces sends", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl := &Controller{
+			client:   mockClient,
+			recvDone: make(chan struct{}),
+			cfg: &Config{
+				discoverySubnets:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/27")},
+				subnetSweepConcurrency: 2,
+				subnetSweepRate:        1000,
+			},
+		}
+		start := time.Now()
+		require.NoError(t, ctrl.SweepSubnets())
+		assert.Equal(t, 30, len(mockClient.sends))
+		assert.GreaterOrEqual(t, time.Since(start), 29*time.Millisecond)
+	})
+
+	t.Run("Stops when the controller is closed", func(t *testing.T) {
+		mockClient := newMockClient()
+		ctrl := &Controller{
+			client:   mockClient,
+			recvDone: make(chan struct{}),
+			cfg: &Config{
+				discoverySubnets:       []netip.Prefix{netip.MustParsePrefix("10.0.0.0/16")},
+				subnetSweepConcurrency: 2,
+			},
+		}
+		close(ctrl.recvDone)
+		require.NoError(t, ctrl.SweepSubnets())
+		assert.Equal(t, 0, len(mockClient.sends))
+	})
+
+	t.Run("Fails on invalid options", func(t *testing.T) {
+		_, err := New(WithClient(newMockClient()), WithDiscoverySubnet("10.0.0.0/8"))
+		assert.Error(t, err)
+		_, err = New(WithClient(newMockClient()), WithDiscoverySweepConcurrency(0))
+		assert.Error(t, err)
+		_, err = New(WithClient(newMockClient()), WithDiscoverySweepRate(0))
+		assert.Error(t, err)
+	})
+}
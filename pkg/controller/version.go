@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// StateVersion returns the current state version of the Controller, which is bumped every time
+// the state of a device changes or a device is added. It can be passed to GetDevicesChangedSince.
+func (c *Controller) StateVersion() uint64 {
+	return c.cfg.stateVersion.Load()
+}
+
+// GetDevicesChangedSince returns the devices whose state changed after the given state version,
+// sorted like GetDevices, together with the current state version to pass to the next call.
+// Unchanged devices are not copied, so pollers can cheaply detect changes:
+//
+//	var version uint64
+//	for range ticker.C {
+//		var changed []device.Device
+//		changed, version = ctrl.GetDevicesChangedSince(version)
+//		...
+//	}
+//
+// Removed devices are not reported, use Subscribe to be notified of them.
+func (c *Controller) GetDevicesChangedSince(version uint64) ([]device.Device, uint64) {
+	// Read the version first so that changes made while collecting the devices are reported again.
+	current := c.StateVersion()
+	if current <= version {
+		return nil, current
+	}
+
+	var devices []device.Device
+	for _, s := range c.sessionList() {
+		if d, ok := s.deviceChangedSince(version); ok {
+			devices = append(devices, d)
+		}
+	}
+	device.SortDevices(devices)
+	return devices, current
+}
+
+// deviceChangedSince returns a snapshot of the device if its state changed after the given version.
+func (s *deviceSession) deviceChangedSince(version uint64) (device.Device, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.device.StateVersion <= version {
+		return device.Device{}, false
+	}
+	return s.device.Clone(), true
+}
+
+// nextStateVersion bumps the state version and returns it.
+// It returns 0 on a nil Config, e.g. for sessions not created by a Controller.
+func (c *Config) nextStateVersion() uint64 {
+	if c == nil || c.stateVersion == nil {
+		return 0
+	}
+	return c.stateVersion.Add(1)
+}
@@ -0,0 +1,63 @@
+package controller
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDevicesChangedSince(t *testing.T) {
+	var (
+		addr0   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}
+		addr1   = &net.UDPAddr{IP: net.IPv4(192, 168, 0, 11)}
+		serial0 = device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+		serial1 = device.Serial([8]byte{2, 0, 0, 0, 0, 0, 0, 0})
+	)
+
+	ctrl, err := New(WithClient(newMockClient()), WithDiscoveryPeriod(time.Hour))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	ctrl.addSession(addr0, serial0, func(d *device.Device) { d.Label = "A" })
+	ctrl.addSession(addr1, serial1, func(d *device.Device) { d.Label = "B" })
+
+	serials := func(devices []device.Device) []device.Serial {
+		var s []device.Serial
+		for _, d := range devices {
+			s = append(s, d.Serial)
+		}
+		return s
+	}
+
+	devices, version := ctrl.GetDevicesChangedSince(0)
+	assert.Equal(t, []device.Serial{serial0, serial1}, serials(devices))
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, version, ctrl.StateVersion())
+
+	devices, version = ctrl.GetDevicesChangedSince(version)
+	assert.Empty(t, devices)
+	assert.Equal(t, uint64(2), version)
+
+	label := func(l string) *protocol.Message {
+		var p packets.DeviceStateLabel
+		copy(p.Label[:], l)
+		return protocol.NewMessage(&p)
+	}
+
+	// Messages that do not change the state do not bump the version.
+	ctrl.session(serial1).inbound <- label("B")
+	ctrl.session(serial1).inbound <- label("C")
+	require.Eventually(t, func() bool { return ctrl.StateVersion() == 3 }, time.Second, time.Millisecond)
+
+	devices, version = ctrl.GetDevicesChangedSince(version)
+	assert.Equal(t, []device.Serial{serial1}, serials(devices))
+	assert.Equal(t, "C", devices[0].Label)
+	assert.Equal(t, uint64(3), devices[0].StateVersion)
+	assert.Equal(t, uint64(3), version)
+}
@@ -0,0 +1,45 @@
+package device
+
+// CapabilitySummary is a summary of what a device supports, the Capabilities of its product
+// completed with the sizes and counts of its reported state, e.g. for a UI to choose the
+// controls to show.
+type CapabilitySummary struct {
+	Capabilities
+	// TemperatureRange is the supported white color temperature range, zero for switches.
+	TemperatureRange TemperatureRange
+	// Zones is the number of zones of multizone devices, as reported by the device.
+	Zones int
+	// MatrixWidth and MatrixHeight are the size in zones of each device in the chain of matrix
+	// devices, and ChainLength the number of devices in the chain, as reported by the device.
+	MatrixWidth  int
+	MatrixHeight int
+	ChainLength  int
+	// RelayCount and ButtonCount are the number of relays and buttons of switch and hybrid devices.
+	RelayCount  int
+	ButtonCount int
+}
+
+// CapabilitySummary returns a summary of the features of the device. Counts and sizes reported
+// by the device are zero until its state is received.
+func (d *Device) CapabilitySummary() CapabilitySummary {
+	c := CapabilitySummary{
+		Capabilities:     d.Supports,
+		TemperatureRange: d.ColorProperties.TemperatureRange,
+		ButtonCount:      len(d.Buttons),
+	}
+	if d.Supports.Multizone {
+		c.Zones = len(d.MultizoneProperties.Zones)
+	}
+	if d.Supports.Matrix {
+		c.MatrixWidth = d.MatrixProperties.Width
+		c.MatrixHeight = d.MatrixProperties.Height
+		c.ChainLength = d.MatrixProperties.ChainLength
+	}
+	if d.Supports.Relays {
+		c.RelayCount = switchRelaysCount
+		if n := len(d.RelayProperties.Relays); n > 0 {
+			c.RelayCount = n
+		}
+	}
+	return c
+}
@@ -0,0 +1,60 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCapabilitySummary(t *testing.T) {
+	tests := map[string]struct {
+		pid uint32
+		// state sets the state reported by the device.
+		state func(d *Device)
+		want  CapabilitySummary
+	}{
+		"white light": {
+			pid:  88,
+			want: CapabilitySummary{Capabilities: Capabilities{Light: true}, TemperatureRange: TemperatureRange{Min: 2700, Max: 2700}},
+		},
+		"hev light": {
+			pid:  90,
+			want: CapabilitySummary{Capabilities: Capabilities{Light: true, Color: true, HEV: true}, TemperatureRange: TemperatureRange{Min: 1500, Max: 9000}},
+		},
+		"multizone light": {
+			pid: 117,
+			state: func(d *Device) {
+				d.MultizoneProperties.Zones = make([]packets.LightHsbk, 16)
+			},
+			want: CapabilitySummary{Capabilities: Capabilities{Light: true, Color: true, Multizone: true, ExtendedMultizone: true}, Zones: 16,
+				TemperatureRange: TemperatureRange{Min: 1500, Max: 9000}},
+		},
+		"matrix light": {
+			pid: 55,
+			state: func(d *Device) {
+				d.MatrixProperties.Width, d.MatrixProperties.Height, d.MatrixProperties.ChainLength = 8, 8, 5
+			},
+			want: CapabilitySummary{Capabilities: Capabilities{Light: true, Color: true, Matrix: true, Chain: true}, MatrixWidth: 8, MatrixHeight: 8, ChainLength: 5,
+				TemperatureRange: TemperatureRange{Min: 2500, Max: 9000}},
+		},
+		"switch": {
+			pid: 89,
+			state: func(d *Device) {
+				d.Buttons = make([]Button, 4)
+			},
+			want: CapabilitySummary{Capabilities: Capabilities{Relays: true, Buttons: true}, RelayCount: switchRelaysCount, ButtonCount: 4},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d := &Device{}
+			d.SetProductInfo(tt.pid)
+			if tt.state != nil {
+				tt.state(d)
+			}
+			assert.Equal(t, tt.want, d.CapabilitySummary())
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package device
+
+import (
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/sun"
+)
+
+// CircadianCurve maps the daylight of a position to a white color temperature and brightness.
+// Values are at their minimum before sunrise and after sunset and follow a sine
+// curve in between, peaking at solar noon.
+type CircadianCurve struct {
+	MinKelvin, MaxKelvin         uint16
+	MinBrightness, MaxBrightness float64
+}
+
+// DefaultCircadianCurve is a warm evening and cool daylight curve, used by CircadianColor
+// and by default by the circadian package.
+var DefaultCircadianCurve = CircadianCurve{
+	MinKelvin:     2200,
+	MaxKelvin:     5500,
+	MinBrightness: 30,
+	MaxBrightness: 100,
+}
+
+// At returns the kelvin and brightness (0-100) of the curve at time t for the given
+// latitude and longitude.
+func (c CircadianCurve) At(t time.Time, latitude, longitude float64) (kelvin uint16, brightness float64) {
+	f := sun.Daylight(t, latitude, longitude)
+	kelvin = uint16(math.Round(float64(c.MinKelvin) + (float64(c.MaxKelvin)-float64(c.MinKelvin))*f))
+	brightness = c.MinBrightness + (c.MaxBrightness-c.MinBrightness)*f
+	return kelvin, brightness
+}
+
+// CircadianColor returns the white Color of DefaultCircadianCurve at time t at the given
+// latitude and longitude (in degrees, north and east positive): warm and dim before sunrise
+// and after sunset, cool and bright at solar noon.
+// Use the circadian package to customise the curve or to apply it to devices periodically.
+func CircadianColor(t time.Time, latitude, longitude float64) Color {
+	kelvin, brightness := DefaultCircadianCurve.At(t, latitude, longitude)
+	return Color{Kelvin: kelvin, Brightness: brightness}
+}
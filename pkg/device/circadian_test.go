@@ -0,0 +1,56 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircadianColor(t *testing.T) {
+	const londonLat, londonLon = 51.5074, -0.1278
+
+	testCases := map[string]struct {
+		t              time.Time
+		lat, lon       float64
+		wantKelvin     uint16
+		wantBrightness float64
+	}{
+		"night": {
+			t:   time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			lat: londonLat, lon: londonLon,
+			wantKelvin: 2200, wantBrightness: 30,
+		},
+		"solar noon": {
+			t:   time.Date(2024, 6, 21, 12, 2, 0, 0, time.UTC),
+			lat: londonLat, lon: londonLon,
+			wantKelvin: 5500, wantBrightness: 100,
+		},
+		"polar night": {
+			t:   time.Date(2024, 12, 21, 12, 0, 0, 0, time.UTC),
+			lat: 80, lon: 0,
+			wantKelvin: 2200, wantBrightness: 30,
+		},
+		"midnight sun": {
+			t:   time.Date(2024, 6, 21, 0, 0, 0, 0, time.UTC),
+			lat: 80, lon: 0,
+			wantKelvin: 5500, wantBrightness: 100,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := CircadianColor(tc.t, tc.lat, tc.lon)
+			assert.Equal(t, tc.wantKelvin, c.Kelvin)
+			assert.InDelta(t, tc.wantBrightness, c.Brightness, 0.01)
+			assert.Zero(t, c.Saturation)
+		})
+	}
+
+	t.Run("warms up towards sunset", func(t *testing.T) {
+		afternoon := CircadianColor(time.Date(2024, 6, 21, 16, 0, 0, 0, time.UTC), londonLat, londonLon)
+		evening := CircadianColor(time.Date(2024, 6, 21, 19, 0, 0, 0, time.UTC), londonLat, londonLon)
+		assert.Less(t, evening.Kelvin, afternoon.Kelvin)
+		assert.Less(t, evening.Brightness, afternoon.Brightness)
+	})
+}
@@ -81,6 +81,33 @@ func (c *Color) HSBToRGB() (int, int, int) {
 	return 0, 0, 0
 }
 
+// NewColorFromRGB converts a color from Red, Green, Blue (RGB) components in the range [0,255]
+// to a Color with the given kelvin. Hue is returned in degrees [0,360),
+// Saturation and Brightness as percentages [0,100].
+func NewColorFromRGB(r, g, b uint8, kelvin uint16) Color {
+	rf, gf, bf := float64(r)/255, float64(g)/255, float64(b)/255
+	hi, lo := max(rf, gf, bf), min(rf, gf, bf)
+	delta := hi - lo
+
+	c := Color{Brightness: hi * 100, Kelvin: kelvin}
+	if hi == 0 || delta == 0 {
+		return c
+	}
+	c.Saturation = delta / hi * 100
+
+	var h float64
+	switch hi {
+	case rf:
+		h = math.Mod((gf-bf)/delta, 6)
+	case gf:
+		h = (bf-rf)/delta + 2
+	default:
+		h = (rf-gf)/delta + 4
+	}
+	c.Hue = math.Mod(h*60+360, 360)
+	return c
+}
+
 // KelvinToRGB converts a color temperature in Kelvin to an RGB color.
 // It uses a standard approximation suitable for many applications,
 // but accuracy is best between 1000K and 40000K.
@@ -132,3 +159,24 @@ func ConvertDeviceValueToExternal(v uint16, multiplier float64) float64 {
 func ConvertExternalToDeviceValue(v float64, multiplier float64) uint16 {
 	return uint16(math.Round(v * math.MaxUint16 / multiplier))
 }
+
+// ClampColor returns the color closest to c that the device can display: hue is wrapped into
+// [0, 360], saturation and brightness are clamped to [0, 100], kelvin to the device temperature
+// range and, for white only lights, saturation is set to 0.
+func (d *Device) ClampColor(c Color) Color {
+	if c.Hue < 0 || c.Hue > 360 {
+		c.Hue = math.Mod(c.Hue, 360)
+		if c.Hue < 0 {
+			c.Hue += 360
+		}
+	}
+	c.Saturation = min(max(c.Saturation, 0), 100)
+	c.Brightness = min(max(c.Brightness, 0), 100)
+	if !d.ColorProperties.HasColor {
+		c.Saturation = 0
+	}
+	if r := d.ColorProperties.TemperatureRange; r.Max > 0 {
+		c.Kelvin = uint16(min(max(int(c.Kelvin), r.Min), r.Max))
+	}
+	return c
+}
@@ -98,6 +98,32 @@ func TestHSBToRGB(t *testing.T) {
 	}
 }
 
+func TestNewColorFromRGB(t *testing.T) {
+	tests := []struct {
+		r, g, b uint8
+		want    Color
+	}{
+		{0, 0, 0, Color{Kelvin: 3500}},                                     // black
+		{255, 255, 255, Color{Brightness: 100, Kelvin: 3500}},              // white
+		{255, 0, 0, Color{Saturation: 100, Brightness: 100, Kelvin: 3500}}, // red
+		{0, 255, 0, Color{120, 100, 100, 3500}},                            // green
+		{0, 0, 255, Color{240, 100, 100, 3500}},                            // blue
+		{255, 255, 0, Color{60, 100, 100, 3500}},                           // yellow
+		{255, 0, 255, Color{300, 100, 100, 3500}},                          // magenta
+		{255, 0, 128, Color{329.88235294117646, 100, 100, 3500}},           // pink
+		{0, 51, 51, Color{180, 100, 20, 3500}},                             // dark cyan
+		{51, 102, 102, Color{180, 50, 40, 3500}},                           // muted cyan
+	}
+
+	for _, tt := range tests {
+		got := NewColorFromRGB(tt.r, tt.g, tt.b, 3500)
+		assert.InDelta(t, tt.want.Hue, got.Hue, 0.001, "hue of (%d,%d,%d)", tt.r, tt.g, tt.b)
+		assert.InDelta(t, tt.want.Saturation, got.Saturation, 0.001, "saturation of (%d,%d,%d)", tt.r, tt.g, tt.b)
+		assert.InDelta(t, tt.want.Brightness, got.Brightness, 0.001, "brightness of (%d,%d,%d)", tt.r, tt.g, tt.b)
+		assert.Equal(t, tt.want.Kelvin, got.Kelvin)
+	}
+}
+
 func TestKelvinToRGB(t *testing.T) {
 	tests := []struct {
 		kelvin int
@@ -122,3 +148,61 @@ func TestKelvinToRGB(t *testing.T) {
 		}
 	}
 }
+
+func TestClampColor(t *testing.T) {
+	colorLight := &Device{ColorProperties: ColorProperties{HasColor: true, TemperatureRange: TemperatureRange{Min: 1500, Max: 9000}}}
+	whiteLight := &Device{ColorProperties: ColorProperties{TemperatureRange: TemperatureRange{Min: 2700, Max: 2700}}}
+
+	tests := map[string]struct {
+		device *Device
+		color  Color
+		want   Color
+	}{
+		"in range": {
+			device: colorLight,
+			color:  Color{Hue: 360, Saturation: 100, Brightness: 50, Kelvin: 3500},
+			want:   Color{Hue: 360, Saturation: 100, Brightness: 50, Kelvin: 3500},
+		},
+		"hue wrapped": {
+			device: colorLight,
+			color:  Color{Hue: -90, Saturation: 100, Brightness: 50, Kelvin: 3500},
+			want:   Color{Hue: 270, Saturation: 100, Brightness: 50, Kelvin: 3500},
+		},
+		"hue over 360": {
+			device: colorLight,
+			color:  Color{Hue: 450, Saturation: 100, Brightness: 50, Kelvin: 3500},
+			want:   Color{Hue: 90, Saturation: 100, Brightness: 50, Kelvin: 3500},
+		},
+		"saturation and brightness clamped": {
+			device: colorLight,
+			color:  Color{Saturation: 150, Brightness: -10, Kelvin: 3500},
+			want:   Color{Saturation: 100, Brightness: 0, Kelvin: 3500},
+		},
+		"kelvin below range": {
+			device: colorLight,
+			color:  Color{Brightness: 50, Kelvin: 1000},
+			want:   Color{Brightness: 50, Kelvin: 1500},
+		},
+		"kelvin above range": {
+			device: colorLight,
+			color:  Color{Brightness: 50, Kelvin: 10000},
+			want:   Color{Brightness: 50, Kelvin: 9000},
+		},
+		"white light": {
+			device: whiteLight,
+			color:  Color{Hue: 120, Saturation: 100, Brightness: 50, Kelvin: 4000},
+			want:   Color{Hue: 120, Saturation: 0, Brightness: 50, Kelvin: 2700},
+		},
+		"unknown temperature range": {
+			device: &Device{},
+			color:  Color{Brightness: 50, Kelvin: 12000},
+			want:   Color{Brightness: 50, Kelvin: 12000},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.device.ClampColor(tt.color))
+		})
+	}
+}
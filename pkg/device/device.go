@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
 	"net"
 	"slices"
 	"strings"
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/alessio-palumbo/lifxregistry-go/gen/registry"
 )
@@ -154,24 +156,53 @@ type Device struct {
 	RegistryName    string
 	ProductID       uint32
 	FirmwareVersion string
+	// FirmwareBuildAt is the build time of the device firmware.
+	FirmwareBuildAt time.Time
 	Type            DeviceType
 	LightType       LightType
 	Location        string
 	Group           string
 	WifiRSSI        WifiRSSI
+	WifiInfo        WifiInfo
+	// LocationID and GroupID are the UUIDs shared by the devices in the same location and group.
+	LocationID [16]byte
+	GroupID    [16]byte
 
 	// Device specific properties.
 	MatrixProperties    MatrixProperties
 	MultizoneProperties MultizoneProperties
 	ColorProperties     ColorProperties
+	RelayProperties     RelayProperties
+	HevProperties       HevProperties
+	// Supports reports the features of the product at the device firmware version.
+	Supports Capabilities
 
-	Buttons []Button
+	Buttons      []Button
+	ButtonConfig ButtonConfig
 
 	// High Frequency updated fields.
 	Color         Color
 	PoweredOn     bool
 	LastSeenAt    time.Time
 	LastUpdatedAt time.Time
+	// Offline is set while the device is not seen within the liveness timeout, for the devices
+	// kept by the controller when they go offline, see controller.WithOfflineDevices.
+	Offline bool
+	// Effect is the firmware effect running on a multizone or matrix device.
+	Effect Effect
+	// StateVersion is the controller state version at which the device state last changed.
+	// Versions increase monotonically across all the devices of a controller.
+	StateVersion uint64
+	// ExternallyModifiedAt is the time a power or color change not originated by the
+	// controller was observed, e.g. from the LIFX app or a physical switch.
+	// It is reset when the controller sends a new state-changing command.
+	ExternallyModifiedAt time.Time
+	// Health reports the device round trip time and packet loss.
+	Health Health
+	// BootedAt is the time the device last booted, derived from the uptime it reports, and
+	// Downtime how long it was off before that. Both are zero until reported.
+	BootedAt time.Time
+	Downtime time.Duration
 }
 
 type MatrixProperties struct {
@@ -186,10 +217,60 @@ type MatrixProperties struct {
 	ChainZones [][]packets.LightHsbk
 	// ChainOrientations describe devices orientation according to accelerometer measurements, if supported.
 	ChainOrientations []Orientation
+	// ChainPositions describe the physical layout of the devices in the chain, as arranged in the LIFX app.
+	ChainPositions []TilePosition
+	// FrameBuffers reports whether colors can be loaded into hidden frame buffers and then copied
+	// into the visible one, as with the LIFX Ceiling. Chained devices such as the original LIFX Tile
+	// are instead set tile by tile, directly in the visible frame buffer.
+	FrameBuffers bool
+}
+
+// TilePosition is the position of the center of a device in a chain, in units of device width
+// and height, with Y increasing upwards.
+type TilePosition struct {
+	X, Y float32
 }
 
 type MultizoneProperties struct {
 	Zones []packets.LightHsbk
+	// Extended reports whether the device firmware supports the extended multizone messages.
+	// Otherwise zones are read 8 at a time and set by range with the legacy messages.
+	Extended bool
+}
+
+// RelayProperties holds the state of the relays of a switch device.
+type RelayProperties struct {
+	// Relays is indexed by the relay index as reported by the device.
+	Relays []Relay
+}
+
+// Relay is the state of a single switch relay.
+type Relay struct {
+	Level uint16
+}
+
+// PoweredOn returns whether the relay is powered on.
+func (r Relay) PoweredOn() bool {
+	return r.Level > 0
+}
+
+// HevProperties holds the HEV (Clean) cycle state of a device.
+type HevProperties struct {
+	// Supported is set for products with the HEV feature.
+	Supported bool
+	// CycleDuration is the configured duration of a cycle started without an explicit duration.
+	CycleDuration time.Duration
+	Indication    bool
+	// Duration and Remaining describe the current cycle and are zero when no cycle is running.
+	Duration   time.Duration
+	Remaining  time.Duration
+	LastPower  bool
+	LastResult enums.LightLastHevCycleResult
+}
+
+// Running returns whether a HEV cycle is in progress.
+func (h HevProperties) Running() bool {
+	return h.Remaining > 0
 }
 
 type ColorProperties struct {
@@ -197,49 +278,243 @@ type ColorProperties struct {
 	TemperatureRange TemperatureRange
 }
 
+// Button holds the actions performed by a device button for each gesture.
 type Button struct {
 	Actions []packets.ButtonAction
 }
 
+// TogglesRelay reports whether one of the button actions toggles the power of the device relay
+// at the given index.
+func (b Button) TogglesRelay(relayIndex int) bool {
+	for _, a := range b.Actions {
+		if a.TargetType != enums.ButtonTargetTypeBUTTONTARGETTYPEPOWERTOGGLERELAYS {
+			continue
+		}
+		r := a.Target.PowerToggleRelays()
+		if slices.Contains(r.Relays[:min(int(r.RelaysCount), len(r.Relays))], uint8(relayIndex)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ButtonConfig is the haptic feedback and backlight configuration shared by the buttons of a device.
+type ButtonConfig struct {
+	HapticDuration time.Duration
+	BacklightOn    packets.ButtonBacklightHsbk
+	BacklightOff   packets.ButtonBacklightHsbk
+}
+
 type TemperatureRange struct {
 	Min, Max int
 }
 
+// Capabilities are the features a device supports, according to the registry entry of its
+// product and the upgrades available up to its firmware version.
+type Capabilities struct {
+	// Light is set for lights and hybrid devices, and Color for lights that are not white only.
+	Light    bool
+	Color    bool
+	Infrared bool
+	HEV      bool
+	// Multizone devices support the legacy multizone messages, and ExtendedMultizone the
+	// extended ones, which require a minimum firmware version on older strips.
+	Multizone         bool
+	ExtendedMultizone bool
+	Matrix            bool
+	Chain             bool
+	// FrameBuffers is set for matrix devices that support hidden frame buffers.
+	FrameBuffers bool
+	Relays       bool
+	Buttons      bool
+}
+
+// switchRelaysCount is the number of relays a LIFX Switch exposes.
+const switchRelaysCount = 4
+
 func NewDevice(address *net.UDPAddr, serial [8]byte) *Device {
 	return &Device{Address: address, Serial: Serial(serial)}
 }
 
-func (d *Device) SetProductInfo(pid uint32) {
+// SetProductInfo sets the product properties according to the registry entry of the given product ID.
+// It reports whether the product ID or its registry name changed.
+func (d *Device) SetProductInfo(pid uint32) (updated bool) {
 	p := registry.ProductsByPID[int(pid)]
+	updated = d.ProductID != pid || d.RegistryName != p.Name
 	d.ProductID = pid
 	d.RegistryName = p.Name
+	d.setFeatures(productFeatures(p, d.FirmwareVersion))
+	return
+}
 
-	if p.Features.Relays {
+// SetFirmwareVersion sets the firmware version and updates the features of the product
+// that depend on it. It reports whether the firmware version changed.
+func (d *Device) SetFirmwareVersion(major, minor uint16) (updated bool) {
+	version := fmt.Sprintf("%d.%d", major, minor)
+	if d.FirmwareVersion == version {
+		return false
+	}
+	d.FirmwareVersion = version
+	if d.Profiled() {
+		d.setFeatures(productFeatures(registry.ProductsByPID[int(d.ProductID)], version))
+	}
+	return true
+}
+
+// SetHostFirmware sets the firmware version and build time reported by the device.
+// It reports whether either changed.
+func (d *Device) SetHostFirmware(p *packets.DeviceStateHostFirmware) (updated bool) {
+	updated = d.SetFirmwareVersion(p.VersionMajor, p.VersionMinor)
+	if build := buildTime(p.Build); !build.Equal(d.FirmwareBuildAt) {
+		d.FirmwareBuildAt = build
+		updated = true
+	}
+	return updated
+}
+
+// bootTimeTolerance is the drift allowed between the boot times derived from successive uptimes,
+// as they are measured against the local clock when the state is received.
+const bootTimeTolerance = 5 * time.Second
+
+// SetInfo sets the boot time and downtime of the device from its reported uptime.
+// It reports whether they changed and whether the device rebooted since the previous report.
+func (d *Device) SetInfo(p *packets.DeviceStateInfo) (updated, rebooted bool) {
+	bootedAt := time.Now().Add(-time.Duration(p.Uptime))
+	downtime := time.Duration(p.Downtime)
+	if !d.BootedAt.IsZero() && bootedAt.Sub(d.BootedAt).Abs() <= bootTimeTolerance && d.Downtime == downtime {
+		return false, false
+	}
+	rebooted = !d.BootedAt.IsZero() && bootedAt.After(d.BootedAt)
+	d.BootedAt, d.Downtime = bootedAt, downtime
+	return true, rebooted
+}
+
+// Uptime returns how long the device has been on since it last booted, or 0 if unknown.
+func (d *Device) Uptime() time.Duration {
+	if d.BootedAt.IsZero() {
+		return 0
+	}
+	return time.Since(d.BootedAt)
+}
+
+// buildTime converts a firmware build timestamp in nanoseconds since the epoch into a time.
+func buildTime(build uint64) time.Time {
+	if build == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(build)).UTC()
+}
+
+// setFeatures sets the device type and properties according to the product features.
+func (d *Device) setFeatures(f registry.FeatureSet) {
+	if f.Relays {
 		d.Type = DeviceTypeSwitch
-	} else if isLight(p.Features) && p.Features.Buttons {
+	} else if isLight(f) && f.Buttons {
 		d.Type = DeviceTypeHybrid
 	}
 
 	if d.Type != DeviceTypeSwitch {
-		if len(p.Features.TemperatureRange) < 2 {
-			p.Features.TemperatureRange = []int{1500, 9000}
+		if len(f.TemperatureRange) < 2 {
+			f.TemperatureRange = []int{1500, 9000}
 		}
 		d.ColorProperties = ColorProperties{
-			HasColor: p.Features.Color,
+			HasColor: f.Color,
 			TemperatureRange: TemperatureRange{
-				Min: p.Features.TemperatureRange[0],
-				Max: p.Features.TemperatureRange[1],
+				Min: f.TemperatureRange[0],
+				Max: f.TemperatureRange[1],
 			},
 		}
 	}
 
-	if p.Features.Multizone {
+	d.Supports = Capabilities{
+		Light:             isLight(f),
+		Color:             f.Color,
+		Infrared:          f.Infrared,
+		HEV:               f.HEV,
+		Multizone:         f.Multizone,
+		ExtendedMultizone: f.ExtendedMultizone,
+		Matrix:            f.Matrix,
+		Chain:             f.Chain,
+		FrameBuffers:      f.Matrix && !f.Chain,
+		Relays:            f.Relays,
+		Buttons:           f.Buttons,
+	}
+	d.HevProperties.Supported = f.HEV
+	d.MultizoneProperties.Extended = f.ExtendedMultizone
+	d.MatrixProperties.FrameBuffers = d.Supports.FrameBuffers
+
+	if f.Multizone {
 		d.LightType = LightTypeMultiZone
-	} else if p.Features.Matrix {
+	} else if f.Matrix {
 		d.LightType = LightTypeMatrix
 	}
 }
 
+// productFeatures returns the features of the product, including those enabled by the
+// upgrades available up to the given firmware version, if known.
+func productFeatures(p registry.Product, firmware string) registry.FeatureSet {
+	f := p.Features
+	var major, minor int
+	if _, err := fmt.Sscanf(firmware, "%d.%d", &major, &minor); err != nil {
+		return f
+	}
+	for _, u := range p.Upgrades {
+		if major < u.Major || major == u.Major && minor < u.Minor {
+			continue
+		}
+		f.HEV = f.HEV || u.Features.HEV
+		f.Color = f.Color || u.Features.Color
+		f.Chain = f.Chain || u.Features.Chain
+		f.Matrix = f.Matrix || u.Features.Matrix
+		f.Relays = f.Relays || u.Features.Relays
+		f.Buttons = f.Buttons || u.Features.Buttons
+		f.Infrared = f.Infrared || u.Features.Infrared
+		f.Multizone = f.Multizone || u.Features.Multizone
+		f.ExtendedMultizone = f.ExtendedMultizone || u.Features.ExtendedMultizone
+		if len(u.Features.TemperatureRange) >= 2 {
+			f.TemperatureRange = u.Features.TemperatureRange
+		}
+	}
+	return f
+}
+
+// Profiled returns whether the device product has been resolved in the registry.
+// Until then device type and capabilities are unknown and default to a single zone light.
+func (d *Device) Profiled() bool {
+	return d.RegistryName != ""
+}
+
+// ExternallyModified returns whether the device state was changed by someone else
+// since the controller last commanded it.
+func (d *Device) ExternallyModified() bool {
+	return !d.ExternallyModifiedAt.IsZero()
+}
+
+// Clone returns a deep copy of the device, which does not share zones, relays
+// or buttons state with the original.
+func (d *Device) Clone() Device {
+	c := *d
+	if d.MatrixProperties.ChainZones != nil {
+		c.MatrixProperties.ChainZones = make([][]packets.LightHsbk, len(d.MatrixProperties.ChainZones))
+		for i, zones := range d.MatrixProperties.ChainZones {
+			c.MatrixProperties.ChainZones[i] = slices.Clone(zones)
+		}
+	}
+	c.MatrixProperties.ChainOrientations = slices.Clone(d.MatrixProperties.ChainOrientations)
+	c.MatrixProperties.ChainPositions = slices.Clone(d.MatrixProperties.ChainPositions)
+	c.MultizoneProperties.Zones = slices.Clone(d.MultizoneProperties.Zones)
+	c.RelayProperties.Relays = slices.Clone(d.RelayProperties.Relays)
+	c.WifiInfo.SignalHistory = slices.Clone(d.WifiInfo.SignalHistory)
+	c.Effect.Palette = slices.Clone(d.Effect.Palette)
+	if d.Buttons != nil {
+		c.Buttons = make([]Button, len(d.Buttons))
+		for i, b := range d.Buttons {
+			c.Buttons[i] = Button{Actions: slices.Clone(b.Actions)}
+		}
+	}
+	return c
+}
+
 // SetMatrixProperties sets the matrix size and length properties
 // according to the first tile in the chain.
 // It also initialises the ChainZones slice or resizes it according to the length.
@@ -250,21 +525,37 @@ func (d *Device) SetMatrixProperties(p *packets.TileStateDeviceChain) (updated b
 	firstIdx := int(p.StartIndex)
 	w, h, l := int(p.TileDevices[firstIdx].Width), int(p.TileDevices[firstIdx].Height), int(p.TileDevicesCount)
 
-	if d.MatrixProperties.Width == w && d.MatrixProperties.Height == h && d.MatrixProperties.ChainLength == l {
-		return
+	positions := make([]TilePosition, l)
+	for i := range l {
+		positions[i] = TilePosition{X: p.TileDevices[i].UserX, Y: p.TileDevices[i].UserY}
 	}
 
-	d.MatrixProperties.Width = w
-	d.MatrixProperties.Height = h
-	d.MatrixProperties.NZones = w * h
-	d.MatrixProperties.ChainLength = l
-	d.MatrixProperties.StatePackets = 1 + (d.MatrixProperties.NZones-1)/64
+	if d.MatrixProperties.Width == w && d.MatrixProperties.Height == h && d.MatrixProperties.ChainLength == l &&
+		slices.Equal(d.MatrixProperties.ChainPositions, positions) {
+		return
+	}
 
-	d.MatrixProperties.ChainOrientations = make([]Orientation, l)
+	orientations := make([]Orientation, l)
 	for i := range l {
 		a := p.TileDevices[i].AccelMeas
-		d.MatrixProperties.ChainOrientations[i] = NearestOrientation(d.ProductID, a.X, a.Y, a.Z)
+		orientations[i] = NearestOrientation(d.ProductID, a.X, a.Y, a.Z)
 	}
+	d.SetMatrixLayout(w, h, positions, orientations)
+	return true
+}
+
+// SetMatrixLayout sets the size of the devices in a matrix chain, with one position and
+// orientation per device, and resizes the chain zones accordingly.
+func (d *Device) SetMatrixLayout(width, height int, positions []TilePosition, orientations []Orientation) {
+	l := len(positions)
+	d.MatrixProperties.Width = width
+	d.MatrixProperties.Height = height
+	d.MatrixProperties.NZones = width * height
+	d.MatrixProperties.ChainLength = l
+	// Invalid sizes leave no state packets to poll.
+	d.MatrixProperties.StatePackets, _ = MatrixPackets(width, height)
+	d.MatrixProperties.ChainPositions = positions
+	d.MatrixProperties.ChainOrientations = orientations
 
 	cl := len(d.MatrixProperties.ChainZones)
 	switch {
@@ -280,8 +571,6 @@ func (d *Device) SetMatrixProperties(p *packets.TileStateDeviceChain) (updated b
 	case cl > l:
 		d.MatrixProperties.ChainZones = slices.Delete(d.MatrixProperties.ChainZones, l, cl)
 	}
-
-	return true
 }
 
 // SetMatrixState sets the colors of the matrix at the given index.
@@ -289,8 +578,8 @@ func (d *Device) SetMatrixState(p *packets.TileState64) (updated bool) {
 	if int(p.TileIndex) > len(d.MatrixProperties.ChainZones)-1 {
 		return
 	}
-	zoneIndex := p.Rect.Y * uint8(d.MatrixProperties.Width)
-	if int(zoneIndex) >= len(d.MatrixProperties.ChainZones[p.TileIndex]) {
+	zoneIndex := int(p.Rect.Y) * d.MatrixProperties.Width
+	if zoneIndex >= len(d.MatrixProperties.ChainZones[p.TileIndex]) {
 		return
 	}
 
@@ -311,64 +600,155 @@ func (d *Device) SetMatrixState(p *packets.TileState64) (updated bool) {
 	return true
 }
 
+// SetMultizoneProperties sets the zones count and the colors of the zones reported by the message.
+// It reports whether the zones were resized or their colors changed.
 func (d *Device) SetMultizoneProperties(p *packets.MultiZoneExtendedStateMultiZone) (updated bool) {
-	if len(d.MultizoneProperties.Zones) != int(p.Count) {
-		d.MultizoneProperties.Zones = make([]packets.LightHsbk, p.Count)
-	}
+	return d.setZones(int(p.Count), int(p.Index), p.Colors[:min(int(p.ColorsCount), len(p.Colors))])
+}
+
+// SetMultizoneState sets the zones count and the colors of the zones reported by a legacy message,
+// which carries up to 8 zones. It reports whether the zones were resized or their colors changed.
+func (d *Device) SetMultizoneState(p *packets.MultiZoneStateMultiZone) (updated bool) {
+	return d.setZones(int(p.Count), int(p.Index), p.Colors[:])
+}
 
-	nZones := len(d.MultizoneProperties.Zones)
-	startIndex := int(p.Index)
-	if p.ColorsCount == 0 || startIndex >= nZones {
+// SetZoneState sets the zones count and the color of the single zone reported by a legacy message.
+// It reports whether the zones were resized or the color changed.
+func (d *Device) SetZoneState(p *packets.MultiZoneStateZone) (updated bool) {
+	return d.setZones(int(p.Count), int(p.Index), []packets.LightHsbk{p.Color})
+}
+
+// setZones resizes the zones to count and sets the given colors from index, ignoring those
+// past the last zone. It reports whether the zones were resized or their colors changed.
+func (d *Device) setZones(count, index int, colors []packets.LightHsbk) (updated bool) {
+	if len(d.MultizoneProperties.Zones) != count {
+		d.MultizoneProperties.Zones = make([]packets.LightHsbk, count)
+		updated = true
+	}
+	if len(colors) == 0 || index >= count {
 		return
 	}
 
-	copy(d.MultizoneProperties.Zones[startIndex:], p.Colors[:])
-	return true
+	zones := d.MultizoneProperties.Zones[index:]
+	if n := min(len(colors), len(zones)); !slices.Equal(zones[:n], colors[:n]) {
+		copy(zones, colors)
+		updated = true
+	}
+	return updated
 }
 
+// SetButtons sets the buttons and their actions from a button state message, which carries up to
+// 8 buttons starting at the message index. It reports whether the buttons or their actions changed.
 func (d *Device) SetButtons(p *packets.ButtonState) (updated bool) {
-	bCount := int(p.ButtonsCount)
-	if bCount != len(d.Buttons) {
+	index, count := int(p.Index), min(int(p.ButtonsCount), len(p.Buttons))
+	if total := max(int(p.Count), index+count); total != len(d.Buttons) {
+		buttons := make([]Button, total)
+		copy(buttons, d.Buttons)
+		d.Buttons = buttons
 		updated = true
-		d.Buttons = make([]Button, bCount)
-		for i := range bCount {
-			d.Buttons[i].Actions = p.Buttons[i].Actions[:p.Buttons[i].ActionsCount]
-		}
-		return
 	}
 
-	for i := range bCount {
-		pButton := p.Buttons[i]
-		dButton := d.Buttons[i]
-		aCount := int(pButton.ActionsCount)
-		if aCount != len(dButton.Actions) {
+	for i := range count {
+		b := p.Buttons[i]
+		actions := b.Actions[:min(int(b.ActionsCount), len(b.Actions))]
+		if dst := &d.Buttons[index+i]; dst.Actions == nil || !slices.Equal(dst.Actions, actions) {
+			dst.Actions = slices.Clone(actions)
 			updated = true
-			dButton.Actions = make([]packets.ButtonAction, aCount)
 		}
+	}
+	return
+}
 
-		for j := range aCount {
-			if pButton.Actions[j].Target != dButton.Actions[j].Target ||
-				pButton.Actions[j].Gesture != dButton.Actions[j].Gesture ||
-				pButton.Actions[j].TargetType != dButton.Actions[j].TargetType {
-				updated = true
-				dButton.Actions[j] = pButton.Actions[j]
-			}
-		}
+// SetButtonConfig sets the haptic feedback and backlight configuration of the buttons.
+func (d *Device) SetButtonConfig(p *packets.ButtonStateConfig) (updated bool) {
+	cfg := ButtonConfig{
+		HapticDuration: time.Duration(p.HapticDurationMs) * time.Millisecond,
+		BacklightOn:    p.BacklightOnColor,
+		BacklightOff:   p.BacklightOffColor,
+	}
+	if d.ButtonConfig == cfg {
+		return false
+	}
+	d.ButtonConfig = cfg
+	return true
+}
+
+// SetRelayPower sets the power level of the relay at the message relay index,
+// growing the relays slice if the index was not yet known.
+// PoweredOn is set when any of the relays is powered on.
+func (d *Device) SetRelayPower(p *packets.RelayStatePower) (updated bool) {
+	idx := int(p.RelayIndex)
+	if idx >= len(d.RelayProperties.Relays) {
+		d.RelayProperties.Relays = append(d.RelayProperties.Relays, make([]Relay, idx+1-len(d.RelayProperties.Relays))...)
+		updated = true
+	}
+
+	if d.RelayProperties.Relays[idx].Level != p.Level {
+		d.RelayProperties.Relays[idx].Level = p.Level
+		updated = true
 	}
+	d.PoweredOn = slices.ContainsFunc(d.RelayProperties.Relays, Relay.PoweredOn)
 	return
 }
 
+// SetHevCycle sets the current HEV cycle state.
+func (d *Device) SetHevCycle(p *packets.LightStateHevCycle) (updated bool) {
+	duration := time.Duration(p.DurationS) * time.Second
+	remaining := time.Duration(p.RemainingS) * time.Second
+	if d.HevProperties.Duration == duration && d.HevProperties.Remaining == remaining && d.HevProperties.LastPower == p.LastPower {
+		return
+	}
+
+	d.HevProperties.Duration = duration
+	d.HevProperties.Remaining = remaining
+	d.HevProperties.LastPower = p.LastPower
+	return true
+}
+
+// SetHevCycleConfiguration sets the default HEV cycle configuration.
+func (d *Device) SetHevCycleConfiguration(p *packets.LightStateHevCycleConfiguration) (updated bool) {
+	duration := time.Duration(p.DurationS) * time.Second
+	if d.HevProperties.CycleDuration == duration && d.HevProperties.Indication == p.Indication {
+		return
+	}
+
+	d.HevProperties.CycleDuration = duration
+	d.HevProperties.Indication = p.Indication
+	return true
+}
+
+// SetHevLastResult sets the result of the last HEV cycle.
+func (d *Device) SetHevLastResult(p *packets.LightStateLastHevCycleResult) (updated bool) {
+	if d.HevProperties.LastResult == p.Result {
+		return
+	}
+
+	d.HevProperties.LastResult = p.Result
+	return true
+}
+
 // HighFreqStateMessages returns a list of messages to gather state that
 // change often and should be polled frequently.
 // Messages differes according to device type.
-// TODO Handle switches.
 func (d *Device) HighFreqStateMessages() []*protocol.Message {
+	if d.Type == DeviceTypeSwitch {
+		return d.relayStateMessages()
+	}
+
+	if d.HevProperties.Supported {
+		return []*protocol.Message{
+			protocol.NewMessage(&packets.LightGet{}),
+			protocol.NewMessage(&packets.LightGetHevCycle{}),
+		}
+	}
+
 	switch d.LightType {
 	case LightTypeMultiZone:
 		return []*protocol.Message{
 			protocol.NewMessage(&packets.LightGet{}),
 			protocol.NewMessage(&packets.DeviceGetPower{}),
-			protocol.NewMessage(&packets.MultiZoneExtendedGetColorZones{}),
+			d.MultizoneStateMessage(),
+			protocol.NewMessage(&packets.MultiZoneGetEffect{}),
 		}
 	case LightTypeMatrix:
 		msgs := []*protocol.Message{
@@ -376,25 +756,34 @@ func (d *Device) HighFreqStateMessages() []*protocol.Message {
 			protocol.NewMessage(&packets.DeviceGetPower{}),
 		}
 
+		rows, _ := MatrixRowsPerPacket(d.MatrixProperties.Width)
 		for i := range d.MatrixProperties.ChainLength {
 			for j := range d.MatrixProperties.StatePackets {
 				msgs = append(msgs, protocol.NewMessage(&packets.TileGet64{
 					TileIndex: uint8(i),
 					Length:    1,
-					Rect:      packets.TileBufferRect{Width: uint8(d.MatrixProperties.Width), Y: uint8(j * 64 / d.MatrixProperties.Width)},
+					Rect:      packets.TileBufferRect{Width: uint8(d.MatrixProperties.Width), Y: uint8(j * rows)},
 				}))
 			}
 		}
-		return msgs
+		return append(msgs, protocol.NewMessage(&packets.TileGetEffect{}))
 	default:
 		return []*protocol.Message{protocol.NewMessage(&packets.LightGet{})}
 	}
 }
 
+// MultizoneStateMessage returns the message requesting the colors of all the zones,
+// with the extended or legacy multizone messages according to the device support.
+func (d *Device) MultizoneStateMessage() *protocol.Message {
+	if d.MultizoneProperties.Extended {
+		return protocol.NewMessage(&packets.MultiZoneExtendedGetColorZones{})
+	}
+	return protocol.NewMessage(&packets.MultiZoneGetColorZones{StartIndex: 0, EndIndex: math.MaxUint8})
+}
+
 // LowFreqStateMessages returns a list of messages to gather state that
 // does not change often and should be polled less frequently.
 // Messages differes according to device type.
-// TODO Handle switches.
 func (d *Device) LowFreqStateMessages() []*protocol.Message {
 	msg := []*protocol.Message{
 		protocol.NewMessage(&packets.DeviceGetLabel{}),
@@ -402,17 +791,46 @@ func (d *Device) LowFreqStateMessages() []*protocol.Message {
 		protocol.NewMessage(&packets.DeviceGetLocation{}),
 		protocol.NewMessage(&packets.DeviceGetGroup{}),
 		protocol.NewMessage(&packets.DeviceGetWifiInfo{}),
+		protocol.NewMessage(&packets.DeviceGetWifiFirmware{}),
+		protocol.NewMessage(&packets.DeviceGetInfo{}),
 	}
 
 	if d.LightType == LightTypeMatrix {
 		msg = append(msg, protocol.NewMessage(&packets.TileGetDeviceChain{}))
 	}
 	if d.Type != DeviceTypeLight {
-		msg = append(msg, protocol.NewMessage(&packets.ButtonGet{}))
+		msg = append(msg,
+			protocol.NewMessage(&packets.ButtonGet{}),
+			protocol.NewMessage(&packets.ButtonGetConfig{}),
+		)
+	}
+	if !d.Profiled() {
+		msg = append(msg, protocol.NewMessage(&packets.DeviceGetVersion{}))
+	}
+	if d.HevProperties.Supported {
+		msg = append(msg,
+			protocol.NewMessage(&packets.LightGetHevCycleConfiguration{}),
+			protocol.NewMessage(&packets.LightGetLastHevCycleResult{}),
+		)
 	}
 	return msg
 }
 
+// relayStateMessages returns a message to gather the power level of each relay.
+// If relays are not yet known it defaults to the number of relays of a LIFX Switch.
+func (d *Device) relayStateMessages() []*protocol.Message {
+	n := len(d.RelayProperties.Relays)
+	if n == 0 {
+		n = switchRelaysCount
+	}
+
+	msgs := make([]*protocol.Message, n)
+	for i := range n {
+		msgs[i] = protocol.NewMessage(&packets.RelayGetPower{RelayIndex: uint8(i)})
+	}
+	return msgs
+}
+
 // SortDevices sorts devices by label and if equal, by Serial.
 func SortDevices(devices []Device) {
 	slices.SortFunc(devices, func(a, b Device) int {
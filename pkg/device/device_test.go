@@ -2,11 +2,15 @@ package device
 
 import (
 	"math"
+	"slices"
 	"testing"
+	"time"
 
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetProductInfo(t *testing.T) {
@@ -23,6 +27,7 @@ func TestSetProductInfo(t *testing.T) {
 				ColorProperties: ColorProperties{
 					TemperatureRange: TemperatureRange{Min: 2700, Max: 2700},
 				},
+				Supports: Capabilities{Light: true},
 			},
 		},
 		"Single zone light": {
@@ -35,6 +40,21 @@ func TestSetProductInfo(t *testing.T) {
 					HasColor:         true,
 					TemperatureRange: TemperatureRange{Min: 1500, Max: 9000},
 				},
+				Supports: Capabilities{Light: true, Color: true},
+			},
+		},
+		"HEV light": {
+			pid: 90,
+			want: &Device{
+				ProductID:    90,
+				RegistryName: "LIFX Clean A19 1100lm",
+				LightType:    LightTypeSingleZone,
+				ColorProperties: ColorProperties{
+					HasColor:         true,
+					TemperatureRange: TemperatureRange{Min: 1500, Max: 9000},
+				},
+				HevProperties: HevProperties{Supported: true},
+				Supports:      Capabilities{Light: true, Color: true, HEV: true},
 			},
 		},
 		"Multizone light": {
@@ -47,6 +67,21 @@ func TestSetProductInfo(t *testing.T) {
 					HasColor:         true,
 					TemperatureRange: TemperatureRange{Min: 1500, Max: 9000},
 				},
+				MultizoneProperties: MultizoneProperties{Extended: true},
+				Supports:            Capabilities{Light: true, Color: true, Multizone: true, ExtendedMultizone: true},
+			},
+		},
+		"Legacy multizone light": {
+			pid: 32,
+			want: &Device{
+				ProductID:    32,
+				RegistryName: "LIFX Z",
+				LightType:    LightTypeMultiZone,
+				ColorProperties: ColorProperties{
+					HasColor:         true,
+					TemperatureRange: TemperatureRange{Min: 2500, Max: 9000},
+				},
+				Supports: Capabilities{Light: true, Color: true, Multizone: true},
 			},
 		},
 		"Matrix light": {
@@ -59,6 +94,7 @@ func TestSetProductInfo(t *testing.T) {
 					HasColor:         true,
 					TemperatureRange: TemperatureRange{Min: 2500, Max: 9000},
 				},
+				Supports: Capabilities{Light: true, Color: true, Matrix: true, Chain: true},
 			},
 		},
 		"Switch": {
@@ -67,6 +103,7 @@ func TestSetProductInfo(t *testing.T) {
 				ProductID:    89,
 				RegistryName: "LIFX Switch",
 				Type:         DeviceTypeSwitch,
+				Supports:     Capabilities{Relays: true, Buttons: true},
 			},
 		},
 		"Hybrid": {
@@ -80,6 +117,8 @@ func TestSetProductInfo(t *testing.T) {
 					HasColor:         true,
 					TemperatureRange: TemperatureRange{Min: 1500, Max: 9000},
 				},
+				MatrixProperties: MatrixProperties{FrameBuffers: true},
+				Supports:         Capabilities{Light: true, Color: true, Matrix: true, FrameBuffers: true, Buttons: true},
 			},
 		},
 	}
@@ -141,6 +180,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 			msg: &packets.TileStateDeviceChain{
@@ -152,6 +192,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 		},
@@ -166,6 +207,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 			wantUpdated: true,
@@ -181,6 +223,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 5, Width: 7, ChainLength: 2, NZones: 35, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64[:35], emptyZoneSlice64[:35]},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 			wantUpdated: true,
@@ -196,6 +239,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 16, ChainLength: 2, NZones: 128, StatePackets: 2,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice128, emptyZoneSlice128},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 			wantUpdated: true,
@@ -212,6 +256,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 1, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}},
 				},
 			},
 			wantUpdated: true,
@@ -228,6 +273,7 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {}},
 				},
 			},
 			wantUpdated: true,
@@ -244,6 +290,30 @@ func TestSetMatrixProperties(t *testing.T) {
 					Height: 8, Width: 8, ChainLength: 1, NZones: 64, StatePackets: 1,
 					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64},
 					ChainOrientations: []Orientation{OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}},
+				},
+			},
+			wantUpdated: true,
+		},
+		"updates when tiles are rearranged": {
+			device: &Device{
+				MatrixProperties: MatrixProperties{
+					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
+					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
+					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {X: 1}},
+				},
+			},
+			msg: &packets.TileStateDeviceChain{
+				TileDevices:      [16]packets.TileStateDevice{{Width: 8, Height: 8}, {Width: 8, Height: 8, UserY: 1}},
+				TileDevicesCount: 2,
+			},
+			want: &Device{
+				MatrixProperties: MatrixProperties{
+					Height: 8, Width: 8, ChainLength: 2, NZones: 64, StatePackets: 1,
+					ChainZones:        [][]packets.LightHsbk{emptyZoneSlice64, emptyZoneSlice64},
+					ChainOrientations: []Orientation{OrientationRightSideUp, OrientationRightSideUp},
+					ChainPositions:    []TilePosition{{}, {Y: 1}},
 				},
 			},
 			wantUpdated: true,
@@ -259,6 +329,23 @@ func TestSetMatrixProperties(t *testing.T) {
 	}
 }
 
+func TestSetMatrixLayout(t *testing.T) {
+	d := &Device{}
+	d.SetMatrixLayout(8, 8, []TilePosition{{X: 0}, {X: 1}}, []Orientation{OrientationRightSideUp, OrientationFaceUp})
+
+	assert.Equal(t, MatrixProperties{
+		Width: 8, Height: 8, NZones: 64, StatePackets: 1, ChainLength: 2,
+		ChainZones:        [][]packets.LightHsbk{make([]packets.LightHsbk, 64), make([]packets.LightHsbk, 64)},
+		ChainOrientations: []Orientation{OrientationRightSideUp, OrientationFaceUp},
+		ChainPositions:    []TilePosition{{X: 0}, {X: 1}},
+	}, d.MatrixProperties)
+
+	// Shrinking the chain drops the zones of the removed devices.
+	d.SetMatrixLayout(8, 8, []TilePosition{{X: 0}}, []Orientation{OrientationRightSideUp})
+	assert.Len(t, d.MatrixProperties.ChainZones, 1)
+	assert.Equal(t, 1, d.MatrixProperties.ChainLength)
+}
+
 func TestSetMatrixState(t *testing.T) {
 	emptyZoneSlice := func() []packets.LightHsbk { return make([]packets.LightHsbk, 64) }
 	color0 := packets.LightHsbk{Hue: 180, Saturation: math.MaxUint16, Brightness: math.MaxUint16, Kelvin: 3500}
@@ -371,6 +458,111 @@ func TestSetMatrixState(t *testing.T) {
 	}
 }
 
+func TestSetFirmwareVersion(t *testing.T) {
+	testCases := map[string]struct {
+		pid          uint32
+		major, minor uint16
+		wantExtended bool
+		wantRange    TemperatureRange
+	}{
+		"Legacy firmware": {
+			pid: 32, major: 2, minor: 76,
+			wantRange: TemperatureRange{Min: 2500, Max: 9000},
+		},
+		"Extended multizone upgrade": {
+			pid: 32, major: 2, minor: 77,
+			wantExtended: true,
+			wantRange:    TemperatureRange{Min: 2500, Max: 9000},
+		},
+		"Later upgrades": {
+			pid: 32, major: 3, minor: 70,
+			wantExtended: true,
+			wantRange:    TemperatureRange{Min: 1500, Max: 9000},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d := &Device{}
+			d.SetProductInfo(tc.pid)
+			assert.True(t, d.SetFirmwareVersion(tc.major, tc.minor))
+			assert.False(t, d.SetFirmwareVersion(tc.major, tc.minor))
+			assert.Equal(t, tc.wantExtended, d.MultizoneProperties.Extended)
+			assert.Equal(t, tc.wantRange, d.ColorProperties.TemperatureRange)
+
+			// The firmware version is also applied when the product is resolved later.
+			d2 := &Device{}
+			d2.SetFirmwareVersion(tc.major, tc.minor)
+			d2.SetProductInfo(tc.pid)
+			assert.Equal(t, d, d2)
+		})
+	}
+}
+
+func TestSetHostFirmware(t *testing.T) {
+	build := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	p := &packets.DeviceStateHostFirmware{Build: uint64(build.UnixNano()), VersionMajor: 3, VersionMinor: 90}
+
+	d := &Device{}
+	assert.True(t, d.SetHostFirmware(p))
+	assert.False(t, d.SetHostFirmware(p))
+	assert.Equal(t, "3.90", d.FirmwareVersion)
+	assert.Equal(t, build, d.FirmwareBuildAt)
+
+	// A new build of the same version is an update.
+	p.Build += uint64(time.Hour)
+	assert.True(t, d.SetHostFirmware(p))
+	assert.Equal(t, build.Add(time.Hour), d.FirmwareBuildAt)
+}
+
+func TestSetInfo(t *testing.T) {
+	d := &Device{}
+	assert.Zero(t, d.Uptime())
+
+	updated, rebooted := d.SetInfo(&packets.DeviceStateInfo{Uptime: uint64(time.Hour), Downtime: uint64(time.Minute)})
+	assert.True(t, updated)
+	assert.False(t, rebooted)
+	assert.InDelta(t, time.Hour, d.Uptime(), float64(time.Second))
+	assert.Equal(t, time.Minute, d.Downtime)
+
+	// Uptime reports consistent with the boot time are not updates.
+	updated, rebooted = d.SetInfo(&packets.DeviceStateInfo{Uptime: uint64(time.Hour + time.Second), Downtime: uint64(time.Minute)})
+	assert.False(t, updated)
+	assert.False(t, rebooted)
+
+	updated, rebooted = d.SetInfo(&packets.DeviceStateInfo{Uptime: uint64(time.Minute), Downtime: uint64(time.Second)})
+	assert.True(t, updated)
+	assert.True(t, rebooted)
+	assert.InDelta(t, time.Minute, d.Uptime(), float64(time.Second))
+	assert.Equal(t, time.Second, d.Downtime)
+}
+
+func TestSetMultizoneState(t *testing.T) {
+	color0 := packets.LightHsbk{Hue: 100, Kelvin: 3500}
+	d := &Device{}
+
+	assert.True(t, d.SetMultizoneState(&packets.MultiZoneStateMultiZone{Count: 10, Index: 8, Colors: [8]packets.LightHsbk{color0, color0}}))
+	want := make([]packets.LightHsbk, 10)
+	want[8], want[9] = color0, color0
+	assert.Equal(t, want, d.MultizoneProperties.Zones)
+	assert.False(t, d.SetMultizoneState(&packets.MultiZoneStateMultiZone{Count: 10, Index: 8, Colors: [8]packets.LightHsbk{color0, color0}}))
+
+	assert.True(t, d.SetZoneState(&packets.MultiZoneStateZone{Count: 10, Index: 0, Color: color0}))
+	assert.Equal(t, color0, d.MultizoneProperties.Zones[0])
+	assert.False(t, d.SetZoneState(&packets.MultiZoneStateZone{Count: 10, Index: 0, Color: color0}))
+}
+
+func TestMultizoneStateMessage(t *testing.T) {
+	d := &Device{LightType: LightTypeMultiZone}
+	assert.Equal(t, &packets.MultiZoneGetColorZones{StartIndex: 0, EndIndex: 255}, d.MultizoneStateMessage().Payload)
+	assert.Equal(t, &packets.MultiZoneGetColorZones{StartIndex: 0, EndIndex: 255}, d.HighFreqStateMessages()[2].Payload)
+	assert.Equal(t, &packets.MultiZoneGetEffect{}, d.HighFreqStateMessages()[3].Payload)
+
+	d.MultizoneProperties.Extended = true
+	assert.Equal(t, &packets.MultiZoneExtendedGetColorZones{}, d.MultizoneStateMessage().Payload)
+	assert.Equal(t, &packets.MultiZoneExtendedGetColorZones{}, d.HighFreqStateMessages()[2].Payload)
+}
+
 func TestSetMultizoneProperties(t *testing.T) {
 	color0 := packets.LightHsbk{Hue: 0, Saturation: math.MaxUint16, Brightness: math.MaxUint16, Kelvin: 3500}
 	withColors := func(index, count int, colors ...packets.LightHsbk) []packets.LightHsbk {
@@ -440,6 +632,30 @@ func TestSetMultizoneProperties(t *testing.T) {
 			},
 			wantUpdated: []bool{true, true},
 		},
+		"does not update unchanged colors": {
+			device: &Device{MultizoneProperties: MultizoneProperties{Zones: withColors(1, 8, color0)}},
+			msgs: []*packets.MultiZoneExtendedStateMultiZone{
+				{Index: 1, Count: 8, ColorsCount: 1, Colors: [82]packets.LightHsbk{color0}},
+			},
+			want: &Device{
+				MultizoneProperties: MultizoneProperties{
+					Zones: withColors(1, 8, color0),
+				},
+			},
+			wantUpdated: []bool{false},
+		},
+		"sets only the reported colors": {
+			device: &Device{MultizoneProperties: MultizoneProperties{Zones: withColors(0, 4, color0, color0, color0, color0)}},
+			msgs: []*packets.MultiZoneExtendedStateMultiZone{
+				{Index: 0, Count: 4, ColorsCount: 1, Colors: [82]packets.LightHsbk{{Kelvin: 2500}}},
+			},
+			want: &Device{
+				MultizoneProperties: MultizoneProperties{
+					Zones: []packets.LightHsbk{{Kelvin: 2500}, color0, color0, color0},
+				},
+			},
+			wantUpdated: []bool{true},
+		},
 	}
 
 	for name, tc := range tests {
@@ -549,6 +765,27 @@ func TestSetButtons(t *testing.T) {
 			want:        &Device{Buttons: []Button{button0, button1}},
 			wantUpdated: true,
 		},
+		"updates actions count": {
+			device: &Device{Buttons: []Button{button0, button1}},
+			msg: &packets.ButtonState{
+				ButtonsCount: 2, Buttons: [8]packets.Button{
+					{ActionsCount: 2, Actions: [5]packets.ButtonAction{button1.Actions[0], button1.Actions[1]}},
+					{ActionsCount: 1, Actions: [5]packets.ButtonAction{button0.Actions[0]}},
+				},
+			},
+			want:        &Device{Buttons: []Button{button1, button0}},
+			wantUpdated: true,
+		},
+		"sets buttons at index": {
+			device: &Device{Buttons: []Button{button0}},
+			msg: &packets.ButtonState{
+				Count: 3, Index: 2, ButtonsCount: 1, Buttons: [8]packets.Button{
+					{ActionsCount: 2, Actions: [5]packets.ButtonAction{button2.Actions[0], button2.Actions[1]}},
+				},
+			},
+			want:        &Device{Buttons: []Button{button0, {}, button2}},
+			wantUpdated: true,
+		},
 	}
 
 	for name, tc := range tests {
@@ -559,3 +796,197 @@ func TestSetButtons(t *testing.T) {
 		})
 	}
 }
+
+func TestSetButtonConfig(t *testing.T) {
+	d := &Device{}
+	p := &packets.ButtonStateConfig{HapticDurationMs: 50, BacklightOnColor: packets.ButtonBacklightHsbk{Brightness: 65535}}
+	assert.True(t, d.SetButtonConfig(p))
+	assert.False(t, d.SetButtonConfig(p))
+	assert.Equal(t, ButtonConfig{HapticDuration: 50 * time.Millisecond, BacklightOn: p.BacklightOnColor}, d.ButtonConfig)
+}
+
+func TestButtonTogglesRelay(t *testing.T) {
+	toggle := packets.ButtonAction{TargetType: enums.ButtonTargetTypeBUTTONTARGETTYPEPOWERTOGGLERELAYS}
+	toggle.Target.SetPowerToggleRelays(&packets.ButtonTargetRelays{RelaysCount: 2, Relays: [15]uint8{0, 2, 3}})
+	on := packets.ButtonAction{TargetType: enums.ButtonTargetTypeBUTTONTARGETTYPEPOWERONRELAYS}
+	on.Target.SetPowerToggleRelays(&packets.ButtonTargetRelays{RelaysCount: 1, Relays: [15]uint8{1}})
+	b := Button{Actions: []packets.ButtonAction{on, toggle}}
+
+	assert.True(t, b.TogglesRelay(0))
+	assert.True(t, b.TogglesRelay(2))
+	assert.False(t, b.TogglesRelay(1))
+	assert.False(t, b.TogglesRelay(3))
+}
+
+func TestSetRelayPower(t *testing.T) {
+	tests := map[string]struct {
+		device      *Device
+		msg         *packets.RelayStatePower
+		want        *Device
+		wantUpdated bool
+	}{
+		"adds relay": {
+			device:      &Device{},
+			msg:         &packets.RelayStatePower{RelayIndex: 1, Level: math.MaxUint16},
+			want:        &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{}, {Level: math.MaxUint16}}}},
+			wantUpdated: true,
+		},
+		"updates relay": {
+			device:      &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{}, {Level: math.MaxUint16}}}},
+			msg:         &packets.RelayStatePower{RelayIndex: 1, Level: 0},
+			want:        &Device{RelayProperties: RelayProperties{Relays: []Relay{{}, {}}}},
+			wantUpdated: true,
+		},
+		"other relay keeps device powered on": {
+			device:      &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{Level: math.MaxUint16}, {Level: math.MaxUint16}}}},
+			msg:         &packets.RelayStatePower{RelayIndex: 1, Level: 0},
+			want:        &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{Level: math.MaxUint16}, {}}}},
+			wantUpdated: true,
+		},
+		"no change": {
+			device:      &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{Level: math.MaxUint16}}}},
+			msg:         &packets.RelayStatePower{RelayIndex: 0, Level: math.MaxUint16},
+			want:        &Device{PoweredOn: true, RelayProperties: RelayProperties{Relays: []Relay{{Level: math.MaxUint16}}}},
+			wantUpdated: false,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			updated := tc.device.SetRelayPower(tc.msg)
+			assert.Equal(t, tc.want, tc.device)
+			assert.Equal(t, tc.wantUpdated, updated)
+		})
+	}
+}
+
+func TestHighFreqStateMessagesSwitch(t *testing.T) {
+	t.Run("defaults to switch relays count", func(t *testing.T) {
+		d := &Device{Type: DeviceTypeSwitch}
+		msgs := d.HighFreqStateMessages()
+		assert.Len(t, msgs, switchRelaysCount)
+		for i, msg := range msgs {
+			assert.Equal(t, &packets.RelayGetPower{RelayIndex: uint8(i)}, msg.Payload)
+		}
+	})
+
+	t.Run("uses known relays", func(t *testing.T) {
+		d := &Device{Type: DeviceTypeSwitch, RelayProperties: RelayProperties{Relays: make([]Relay, 2)}}
+		assert.Len(t, d.HighFreqStateMessages(), 2)
+	})
+}
+
+func TestHighFreqStateMessagesMatrix(t *testing.T) {
+	d := &Device{LightType: LightTypeMatrix}
+	d.SetMatrixProperties(&packets.TileStateDeviceChain{
+		TileDevicesCount: 1,
+		TileDevices:      [16]packets.TileStateDevice{{Width: 12, Height: 12}},
+	})
+	require.Equal(t, 3, d.MatrixProperties.StatePackets)
+
+	msgs := d.HighFreqStateMessages()
+	require.Len(t, msgs, 6)
+	for i, y := range []uint8{0, 5, 10} {
+		assert.Equal(t, &packets.TileGet64{Length: 1, Rect: packets.TileBufferRect{Width: 12, Y: y}}, msgs[2+i].Payload)
+	}
+	assert.Equal(t, &packets.TileGetEffect{}, msgs[5].Payload)
+}
+
+func TestSetHevCycle(t *testing.T) {
+	tests := map[string]struct {
+		device      *Device
+		msg         *packets.LightStateHevCycle
+		want        HevProperties
+		wantUpdated bool
+	}{
+		"running cycle": {
+			device:      &Device{},
+			msg:         &packets.LightStateHevCycle{DurationS: 7200, RemainingS: 3600, LastPower: true},
+			want:        HevProperties{Duration: 2 * time.Hour, Remaining: time.Hour, LastPower: true},
+			wantUpdated: true,
+		},
+		"no change": {
+			device: &Device{HevProperties: HevProperties{Duration: 2 * time.Hour, Remaining: time.Hour}},
+			msg:    &packets.LightStateHevCycle{DurationS: 7200, RemainingS: 3600},
+			want:   HevProperties{Duration: 2 * time.Hour, Remaining: time.Hour},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			updated := tc.device.SetHevCycle(tc.msg)
+			assert.Equal(t, tc.want, tc.device.HevProperties)
+			assert.Equal(t, tc.wantUpdated, updated)
+			assert.Equal(t, tc.want.Remaining > 0, tc.device.HevProperties.Running())
+		})
+	}
+}
+
+func TestSetHevCycleConfiguration(t *testing.T) {
+	d := &Device{}
+	assert.True(t, d.SetHevCycleConfiguration(&packets.LightStateHevCycleConfiguration{Indication: true, DurationS: 3600}))
+	assert.Equal(t, HevProperties{CycleDuration: time.Hour, Indication: true}, d.HevProperties)
+	assert.False(t, d.SetHevCycleConfiguration(&packets.LightStateHevCycleConfiguration{Indication: true, DurationS: 3600}))
+}
+
+func TestSetHevLastResult(t *testing.T) {
+	d := &Device{}
+	assert.False(t, d.SetHevLastResult(&packets.LightStateLastHevCycleResult{}))
+	assert.True(t, d.SetHevLastResult(&packets.LightStateLastHevCycleResult{Result: enums.LightLastHevCycleResultLIGHTLASTHEVCYCLERESULTINTERRUPTEDBYLAN}))
+	assert.Equal(t, enums.LightLastHevCycleResultLIGHTLASTHEVCYCLERESULTINTERRUPTEDBYLAN, d.HevProperties.LastResult)
+}
+
+func TestLowFreqStateMessagesProfiling(t *testing.T) {
+	hasGetVersion := func(msgs []*protocol.Message) bool {
+		return slices.ContainsFunc(msgs, func(m *protocol.Message) bool {
+			return m.Type() == uint16(packets.PayloadTypeDeviceGetVersion)
+		})
+	}
+
+	d := &Device{ProductID: 55}
+	assert.False(t, d.Profiled())
+	assert.True(t, hasGetVersion(d.LowFreqStateMessages()))
+
+	assert.True(t, d.SetProductInfo(55))
+	assert.True(t, d.Profiled())
+	assert.Equal(t, LightTypeMatrix, d.LightType)
+	assert.False(t, hasGetVersion(d.LowFreqStateMessages()))
+	assert.False(t, d.SetProductInfo(55))
+}
+
+func TestClone(t *testing.T) {
+	d := &Device{
+		Label: "Tile",
+		MatrixProperties: MatrixProperties{
+			ChainZones:        [][]packets.LightHsbk{{{Hue: 1}, {Hue: 2}}},
+			ChainOrientations: []Orientation{OrientationFaceUp},
+			ChainPositions:    []TilePosition{{X: 1}},
+		},
+		MultizoneProperties: MultizoneProperties{Zones: []packets.LightHsbk{{Hue: 3}}},
+		RelayProperties:     RelayProperties{Relays: []Relay{{Level: 65535}}},
+		Buttons:             []Button{{Actions: []packets.ButtonAction{{Gesture: 1}}}},
+		WifiInfo:            WifiInfo{SignalHistory: []WifiRSSI{-50}},
+	}
+
+	c := d.Clone()
+	assert.Equal(t, *d, c)
+
+	d.MatrixProperties.ChainZones[0][1].Hue = 20
+	d.MatrixProperties.ChainOrientations[0] = OrientationUpsideDown
+	d.MatrixProperties.ChainPositions[0].X = 2
+	d.MultizoneProperties.Zones[0].Hue = 30
+	d.RelayProperties.Relays[0].Level = 0
+	d.Buttons[0].Actions[0].Gesture = 2
+	d.WifiInfo.SignalHistory[0] = -80
+
+	assert.Equal(t, uint16(2), c.MatrixProperties.ChainZones[0][1].Hue)
+	assert.Equal(t, OrientationFaceUp, c.MatrixProperties.ChainOrientations[0])
+	assert.Equal(t, float32(1), c.MatrixProperties.ChainPositions[0].X)
+	assert.Equal(t, uint16(3), c.MultizoneProperties.Zones[0].Hue)
+	assert.Equal(t, uint16(65535), c.RelayProperties.Relays[0].Level)
+	assert.Equal(t, enums.ButtonGesture(1), c.Buttons[0].Actions[0].Gesture)
+	assert.Equal(t, WifiRSSI(-50), c.WifiInfo.SignalHistory[0])
+
+	// Nil slices stay nil.
+	assert.Equal(t, Device{}, (&Device{}).Clone())
+}
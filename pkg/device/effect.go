@@ -0,0 +1,130 @@
+package device
+
+import (
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// EffectType is a firmware effect, run by the device itself.
+type EffectType int
+
+const (
+	// EffectTypeOff is set when no firmware effect is running.
+	EffectTypeOff EffectType = iota
+	// EffectTypeMove moves the zone colors of a multizone device along the strip.
+	EffectTypeMove
+	// EffectTypeMorph transitions a matrix device through a palette.
+	EffectTypeMorph
+	// EffectTypeFlame renders flames on a matrix device.
+	EffectTypeFlame
+	// EffectTypeSky renders a sunrise, a sunset or clouds on a matrix device, see Effect.SkyType.
+	EffectTypeSky
+)
+
+// String converts an EffectType into a string.
+func (e EffectType) String() string {
+	switch e {
+	case EffectTypeOff:
+		return "off"
+	case EffectTypeMove:
+		return "move"
+	case EffectTypeMorph:
+		return "morph"
+	case EffectTypeFlame:
+		return "flame"
+	case EffectTypeSky:
+		return "sky"
+	}
+	return ""
+}
+
+// Effect is the firmware effect of a multizone or matrix device, as reported by the device.
+type Effect struct {
+	Type EffectType
+	// InstanceID identifies the run of the effect, as set by the client that started it.
+	InstanceID uint32
+	// Speed is the duration of a cycle of the effect, and Duration how long the effect runs for,
+	// 0 running it indefinitely.
+	Speed    time.Duration
+	Duration time.Duration
+	// Parameters are the effect specific parameters, see MoveForward and SkyType.
+	Parameters [8]uint32
+	// Palette holds the colors of the Morph effect.
+	Palette []packets.LightHsbk
+}
+
+// Running returns whether a firmware effect is running.
+func (e Effect) Running() bool {
+	return e.Type != EffectTypeOff
+}
+
+// MoveForward returns whether the Move effect moves the colors towards the end of the strip.
+func (e Effect) MoveForward() bool {
+	return e.Type == EffectTypeMove && e.Parameters[1] != 0
+}
+
+// SkyType returns the kind of Sky effect.
+func (e Effect) SkyType() enums.TileEffectSkyType {
+	return enums.TileEffectSkyType(e.Parameters[0])
+}
+
+// Equal reports whether the effects are the same.
+func (e Effect) Equal(o Effect) bool {
+	return e.Type == o.Type && e.InstanceID == o.InstanceID && e.Speed == o.Speed && e.Duration == o.Duration &&
+		e.Parameters == o.Parameters && slices.Equal(e.Palette, o.Palette)
+}
+
+// SetMatrixEffect sets the firmware effect reported by a matrix device.
+func (d *Device) SetMatrixEffect(p *packets.TileStateEffect) (updated bool) {
+	s := p.Settings
+	e := Effect{
+		InstanceID: s.Instanceid,
+		Speed:      time.Duration(s.Speed) * time.Millisecond,
+		Duration:   time.Duration(s.Duration),
+		Parameters: effectParameters(s.Parameter),
+	}
+	switch s.Type {
+	case enums.TileEffectTypeTILEEFFECTTYPEMORPH:
+		e.Type = EffectTypeMorph
+		e.Palette = slices.Clone(s.Palette[:min(int(s.PaletteCount), len(s.Palette))])
+	case enums.TileEffectTypeTILEEFFECTTYPEFLAME:
+		e.Type = EffectTypeFlame
+	case enums.TileEffectTypeTILEEFFECTTYPESKY:
+		e.Type = EffectTypeSky
+	default:
+		e = Effect{}
+	}
+	return d.setEffect(e)
+}
+
+// SetMultizoneEffect sets the firmware effect reported by a multizone device.
+func (d *Device) SetMultizoneEffect(p *packets.MultiZoneStateEffect) (updated bool) {
+	s := p.Settings
+	var e Effect
+	if s.Type == enums.MultiZoneEffectTypeMULTIZONEEFFECTTYPEMOVE {
+		e = Effect{
+			Type:       EffectTypeMove,
+			InstanceID: s.Instanceid,
+			Speed:      time.Duration(s.Speed) * time.Millisecond,
+			Duration:   time.Duration(s.Duration),
+			Parameters: effectParameters(packets.TileEffectParameter(s.Parameter)),
+		}
+	}
+	return d.setEffect(e)
+}
+
+func (d *Device) setEffect(e Effect) (updated bool) {
+	if d.Effect.Equal(e) {
+		return false
+	}
+	d.Effect = e
+	return true
+}
+
+// effectParameters converts the parameters of an effect, which multizone effects share with matrix ones.
+func effectParameters(p packets.TileEffectParameter) [8]uint32 {
+	return [8]uint32{p.Parameter0, p.Parameter1, p.Parameter2, p.Parameter3, p.Parameter4, p.Parameter5, p.Parameter6, p.Parameter7}
+}
@@ -0,0 +1,83 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetMatrixEffect(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		device      *Device
+		msg         *packets.TileStateEffect
+		want        Effect
+		wantUpdated bool
+	}{
+		"Morph": {
+			device: &Device{},
+			msg: &packets.TileStateEffect{Settings: packets.TileEffectSettings{
+				Instanceid: 7, Type: enums.TileEffectTypeTILEEFFECTTYPEMORPH, Speed: 5000,
+				PaletteCount: 1, Palette: [16]packets.LightHsbk{red, red},
+			}},
+			want:        Effect{Type: EffectTypeMorph, InstanceID: 7, Speed: 5 * time.Second, Palette: []packets.LightHsbk{red}},
+			wantUpdated: true,
+		},
+		"Sky": {
+			device: &Device{},
+			msg: &packets.TileStateEffect{Settings: packets.TileEffectSettings{
+				Type: enums.TileEffectTypeTILEEFFECTTYPESKY, Duration: uint64(time.Minute),
+				Parameter: packets.TileEffectParameter{Parameter0: uint32(enums.TileEffectSkyTypeTILEEFFECTSKYTYPECLOUDS), Parameter1: 50},
+			}},
+			want:        Effect{Type: EffectTypeSky, Duration: time.Minute, Parameters: [8]uint32{2, 50}},
+			wantUpdated: true,
+		},
+		"Off": {
+			device:      &Device{Effect: Effect{Type: EffectTypeFlame, InstanceID: 1}},
+			msg:         &packets.TileStateEffect{Settings: packets.TileEffectSettings{Instanceid: 2}},
+			want:        Effect{},
+			wantUpdated: true,
+		},
+		"No change": {
+			device: &Device{Effect: Effect{Type: EffectTypeFlame, InstanceID: 1}},
+			msg:    &packets.TileStateEffect{Settings: packets.TileEffectSettings{Instanceid: 1, Type: enums.TileEffectTypeTILEEFFECTTYPEFLAME}},
+			want:   Effect{Type: EffectTypeFlame, InstanceID: 1},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.wantUpdated, tc.device.SetMatrixEffect(tc.msg))
+			assert.Equal(t, tc.want, tc.device.Effect)
+		})
+	}
+}
+
+func TestSetMultizoneEffect(t *testing.T) {
+	d := &Device{}
+	msg := &packets.MultiZoneStateEffect{Settings: packets.MultiZoneEffectSettings{
+		Instanceid: 3, Type: enums.MultiZoneEffectTypeMULTIZONEEFFECTTYPEMOVE, Speed: 1000,
+		Parameter: packets.MultiZoneEffectParameter{Parameter1: 1},
+	}}
+
+	assert.True(t, d.SetMultizoneEffect(msg))
+	assert.Equal(t, Effect{Type: EffectTypeMove, InstanceID: 3, Speed: time.Second, Parameters: [8]uint32{0, 1}}, d.Effect)
+	assert.True(t, d.Effect.Running())
+	assert.True(t, d.Effect.MoveForward())
+	assert.False(t, d.SetMultizoneEffect(msg))
+
+	assert.True(t, d.SetMultizoneEffect(&packets.MultiZoneStateEffect{}))
+	assert.False(t, d.Effect.Running())
+}
+
+func TestEffectClone(t *testing.T) {
+	d := &Device{Effect: Effect{Type: EffectTypeMorph, Palette: []packets.LightHsbk{{Hue: 1}}}}
+	c := d.Clone()
+	c.Effect.Palette[0].Hue = 2
+	assert.Equal(t, uint16(1), d.Effect.Palette[0].Hue)
+	assert.Equal(t, enums.TileEffectSkyTypeTILEEFFECTSKYTYPESUNRISE, d.Effect.SkyType())
+}
@@ -0,0 +1,38 @@
+package device
+
+import "time"
+
+const (
+	// rttSmoothing is the weight of a new sample in the smoothed round trip time, as in TCP.
+	rttSmoothing = 1.0 / 8
+	// lossSmoothing is the weight of a new sample in the loss rate, about the last 10 messages.
+	lossSmoothing = 0.1
+)
+
+// Health summarizes the responsiveness of a device, as measured by the controller.
+type Health struct {
+	// RTT is the smoothed round trip time between messages sent to the device and their first response.
+	RTT time.Duration
+	// LossRate is the estimated fraction of queries that got no response, between 0 and 1.
+	LossRate float64
+	// LastAckAt is the time the last acknowledgement was received.
+	LastAckAt time.Time
+}
+
+// AddRTT adds a round trip time sample to the smoothed RTT.
+func (h *Health) AddRTT(rtt time.Duration) {
+	if h.RTT == 0 {
+		h.RTT = rtt
+		return
+	}
+	h.RTT += time.Duration(float64(rtt-h.RTT) * rttSmoothing)
+}
+
+// AddQueryResult adds the outcome of a query, whether it was answered or lost, to the loss rate.
+func (h *Health) AddQueryResult(lost bool) {
+	var sample float64
+	if lost {
+		sample = 1
+	}
+	h.LossRate += (sample - h.LossRate) * lossSmoothing
+}
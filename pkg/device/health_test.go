@@ -0,0 +1,30 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHealth(t *testing.T) {
+	t.Run("Smooths RTT", func(t *testing.T) {
+		var h Health
+		h.AddRTT(80 * time.Millisecond)
+		assert.Equal(t, 80*time.Millisecond, h.RTT)
+		h.AddRTT(160 * time.Millisecond)
+		assert.Equal(t, 90*time.Millisecond, h.RTT)
+	})
+
+	t.Run("Estimates loss rate", func(t *testing.T) {
+		var h Health
+		h.AddQueryResult(true)
+		assert.InDelta(t, 0.1, h.LossRate, 1e-9)
+		h.AddQueryResult(false)
+		assert.InDelta(t, 0.09, h.LossRate, 1e-9)
+		for range 100 {
+			h.AddQueryResult(true)
+		}
+		assert.InDelta(t, 1, h.LossRate, 1e-3)
+	})
+}
@@ -0,0 +1,197 @@
+package device
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// deviceJSON is the JSON encoding of a Device, see Device.MarshalJSON.
+type deviceJSON struct {
+	Serial               Serial                `json:"serial"`
+	Address              string                `json:"address,omitempty"`
+	Label                string                `json:"label"`
+	Product              string                `json:"product"`
+	ProductID            uint32                `json:"product_id"`
+	FirmwareVersion      string                `json:"firmware_version"`
+	FirmwareBuildAt      time.Time             `json:"firmware_build_at,omitzero"`
+	Type                 string                `json:"type"`
+	LightType            string                `json:"light_type,omitempty"`
+	Location             string                `json:"location"`
+	LocationID           string                `json:"location_id,omitempty"`
+	Group                string                `json:"group"`
+	GroupID              string                `json:"group_id,omitempty"`
+	Signal               string                `json:"signal,omitempty"`
+	Supports             []string              `json:"supports"`
+	PoweredOn            bool                  `json:"powered_on"`
+	Color                *colorJSON            `json:"color,omitempty"`
+	TemperatureRange     *temperatureRangeJSON `json:"temperature_range,omitempty"`
+	Multizone            *multizoneJSON        `json:"multizone,omitempty"`
+	Matrix               *matrixJSON           `json:"matrix,omitempty"`
+	Relays               []bool                `json:"relays,omitempty"`
+	Hev                  *hevJSON              `json:"hev,omitempty"`
+	Effect               string                `json:"effect,omitempty"`
+	Offline              bool                  `json:"offline"`
+	LastSeenAt           time.Time             `json:"last_seen_at,omitzero"`
+	LastUpdatedAt        time.Time             `json:"last_updated_at,omitzero"`
+	ExternallyModifiedAt time.Time             `json:"externally_modified_at,omitzero"`
+	BootedAt             time.Time             `json:"booted_at,omitzero"`
+	RTTMillis            float64               `json:"rtt_ms,omitempty"`
+	LossRate             float64               `json:"loss_rate,omitempty"`
+	StateVersion         uint64                `json:"state_version"`
+}
+
+// colorJSON is a Color in human units: hue in degrees, saturation and brightness in percent.
+type colorJSON struct {
+	Hue        float64 `json:"hue"`
+	Saturation float64 `json:"saturation"`
+	Brightness float64 `json:"brightness"`
+	Kelvin     uint16  `json:"kelvin"`
+}
+
+type temperatureRangeJSON struct {
+	Min int `json:"min"`
+	Max int `json:"max"`
+}
+
+type multizoneJSON struct {
+	ZoneCount int         `json:"zone_count"`
+	Extended  bool        `json:"extended"`
+	Zones     []colorJSON `json:"zones,omitempty"`
+}
+
+type matrixJSON struct {
+	Width       int           `json:"width"`
+	Height      int           `json:"height"`
+	ChainLength int           `json:"chain_length"`
+	Chain       [][]colorJSON `json:"chain,omitempty"`
+}
+
+type hevJSON struct {
+	Running          bool    `json:"running"`
+	RemainingSeconds float64 `json:"remaining_seconds,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler, encoding the device state for bridges and CLIs.
+// The field names are stable: enums are encoded as strings, the serial as hex and colors
+// in human units. Zone colors are summarized by their count, use MarshalJSONWithZones to
+// include them.
+func (d Device) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.toJSON(false))
+}
+
+// MarshalJSONWithZones encodes the device as MarshalJSON does, including the colors of the
+// zones of multizone and matrix devices.
+func (d Device) MarshalJSONWithZones() ([]byte, error) {
+	return json.Marshal(d.toJSON(true))
+}
+
+func (d Device) toJSON(zones bool) deviceJSON {
+	j := deviceJSON{
+		Serial:               d.Serial,
+		Label:                d.Label,
+		Product:              d.RegistryName,
+		ProductID:            d.ProductID,
+		FirmwareVersion:      d.FirmwareVersion,
+		FirmwareBuildAt:      d.FirmwareBuildAt,
+		Type:                 d.Type.String(),
+		Location:             d.Location,
+		Group:                d.Group,
+		Supports:             d.Supports.names(),
+		PoweredOn:            d.PoweredOn,
+		Offline:              d.Offline,
+		LastSeenAt:           d.LastSeenAt,
+		LastUpdatedAt:        d.LastUpdatedAt,
+		ExternallyModifiedAt: d.ExternallyModifiedAt,
+		BootedAt:             d.BootedAt,
+		RTTMillis:            float64(d.Health.RTT) / float64(time.Millisecond),
+		LossRate:             d.Health.LossRate,
+		StateVersion:         d.StateVersion,
+	}
+	if d.Address != nil {
+		j.Address = d.Address.String()
+	}
+	if d.LocationID != [16]byte{} {
+		j.LocationID = hex.EncodeToString(d.LocationID[:])
+	}
+	if d.GroupID != [16]byte{} {
+		j.GroupID = hex.EncodeToString(d.GroupID[:])
+	}
+	// A zero value means the signal has not been reported yet.
+	if d.WifiRSSI != 0 {
+		j.Signal = d.WifiRSSI.String()
+	}
+
+	if d.Type == DeviceTypeLight || d.Type == DeviceTypeHybrid {
+		c := newColorJSON(d.Color)
+		j.LightType, j.Color = d.LightType.String(), &c
+		if r := d.ColorProperties.TemperatureRange; r != (TemperatureRange{}) {
+			j.TemperatureRange = &temperatureRangeJSON{Min: r.Min, Max: r.Max}
+		}
+	}
+	if n := len(d.MultizoneProperties.Zones); n > 0 {
+		j.Multizone = &multizoneJSON{ZoneCount: n, Extended: d.MultizoneProperties.Extended}
+		if zones {
+			j.Multizone.Zones = newColorsJSON(d.MultizoneProperties.Zones)
+		}
+	}
+	if m := d.MatrixProperties; m.Width > 0 && m.Height > 0 {
+		j.Matrix = &matrixJSON{Width: m.Width, Height: m.Height, ChainLength: m.ChainLength}
+		if zones {
+			for _, z := range m.ChainZones {
+				j.Matrix.Chain = append(j.Matrix.Chain, newColorsJSON(z))
+			}
+		}
+	}
+	for _, r := range d.RelayProperties.Relays {
+		j.Relays = append(j.Relays, r.PoweredOn())
+	}
+	if h := d.HevProperties; h.Supported {
+		j.Hev = &hevJSON{Running: h.Running(), RemainingSeconds: h.Remaining.Seconds()}
+	}
+	if d.Effect.Running() {
+		j.Effect = d.Effect.Type.String()
+	}
+	return j
+}
+
+func newColorJSON(c Color) colorJSON {
+	return colorJSON{Hue: c.Hue, Saturation: c.Saturation, Brightness: c.Brightness, Kelvin: c.Kelvin}
+}
+
+func newColorsJSON(hsbks []packets.LightHsbk) []colorJSON {
+	colors := make([]colorJSON, len(hsbks))
+	for i, hsbk := range hsbks {
+		colors[i] = newColorJSON(NewColor(hsbk))
+	}
+	return colors
+}
+
+// names returns the snake case names of the supported features.
+func (c Capabilities) names() []string {
+	features := []struct {
+		name      string
+		supported bool
+	}{
+		{"light", c.Light},
+		{"color", c.Color},
+		{"infrared", c.Infrared},
+		{"hev", c.HEV},
+		{"multizone", c.Multizone},
+		{"extended_multizone", c.ExtendedMultizone},
+		{"matrix", c.Matrix},
+		{"chain", c.Chain},
+		{"frame_buffers", c.FrameBuffers},
+		{"relays", c.Relays},
+		{"buttons", c.Buttons},
+	}
+	names := []string{}
+	for _, f := range features {
+		if f.supported {
+			names = append(names, f.name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,79 @@
+package device
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceMarshalJSON(t *testing.T) {
+	lastSeen := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	strip := NewDevice(&net.UDPAddr{IP: net.IPv4(192, 168, 0, 10), Port: 56700}, [8]byte{0xd0, 0x73, 0xd5, 0, 0, 1})
+	strip.Label, strip.RegistryName, strip.ProductID = "Strip", "LIFX Z", 32
+	strip.LightType = LightTypeMultiZone
+	strip.Supports = Capabilities{Light: true, Color: true, Multizone: true}
+	strip.ColorProperties.TemperatureRange = TemperatureRange{Min: 2500, Max: 9000}
+	strip.Color = Color{Hue: 120, Saturation: 100, Brightness: 50, Kelvin: 3500}
+	strip.PoweredOn = true
+	strip.MultizoneProperties.Zones = []packets.LightHsbk{{Hue: 65535, Saturation: 65535, Brightness: 65535, Kelvin: 3500}}
+	strip.LastSeenAt = lastSeen
+	strip.Health.RTT = 20 * time.Millisecond
+
+	switchDevice := NewDevice(nil, [8]byte{0xd0, 0x73, 0xd5, 0, 0, 2})
+	switchDevice.Type = DeviceTypeSwitch
+	switchDevice.Supports = Capabilities{Relays: true, Buttons: true}
+	switchDevice.RelayProperties.Relays = []Relay{{Level: 65535}, {}}
+
+	tests := map[string]struct {
+		device Device
+		zones  bool
+		want   string
+	}{
+		"multizone light": {
+			device: *strip,
+			want: `{"serial":"d073d5000001","address":"192.168.0.10:56700","label":"Strip","product":"LIFX Z",
+				"product_id":32,"firmware_version":"","type":"light","light_type":"multi_zone","location":"",
+				"group":"","supports":["light","color","multizone"],"powered_on":true,
+				"color":{"hue":120,"saturation":100,"brightness":50,"kelvin":3500},
+				"temperature_range":{"min":2500,"max":9000},"multizone":{"zone_count":1,"extended":false},
+				"offline":false,"last_seen_at":"2025-01-02T03:04:05Z","rtt_ms":20,"state_version":0}`,
+		},
+		"multizone light with zones": {
+			device: *strip,
+			zones:  true,
+			want: `{"serial":"d073d5000001","address":"192.168.0.10:56700","label":"Strip","product":"LIFX Z",
+				"product_id":32,"firmware_version":"","type":"light","light_type":"multi_zone","location":"",
+				"group":"","supports":["light","color","multizone"],"powered_on":true,
+				"color":{"hue":120,"saturation":100,"brightness":50,"kelvin":3500},
+				"temperature_range":{"min":2500,"max":9000},
+				"multizone":{"zone_count":1,"extended":false,"zones":[{"hue":360,"saturation":100,"brightness":100,"kelvin":3500}]},
+				"offline":false,"last_seen_at":"2025-01-02T03:04:05Z","rtt_ms":20,"state_version":0}`,
+		},
+		"switch": {
+			device: *switchDevice,
+			want: `{"serial":"d073d5000002","label":"","product":"","product_id":0,"firmware_version":"",
+				"type":"switch","location":"","group":"","supports":["relays","buttons"],"powered_on":false,
+				"relays":[true,false],"offline":false,"state_version":0}`,
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var (
+				got []byte
+				err error
+			)
+			if tt.zones {
+				got, err = tt.device.MarshalJSONWithZones()
+			} else {
+				got, err = tt.device.MarshalJSON()
+			}
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(got))
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package device
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ZonesPerTilePacket is the number of zones carried by a single Tile 64 message.
+const ZonesPerTilePacket = 64
+
+// ErrInvalidMatrixSize is returned when a matrix size cannot be addressed by Tile 64 messages.
+var ErrInvalidMatrixSize = errors.New("invalid matrix size")
+
+// MatrixRowsPerPacket returns the number of whole rows of the given width carried by a single
+// Tile 64 message, so that each message starts at the beginning of a row.
+func MatrixRowsPerPacket(width int) (int, error) {
+	if width < 1 || width > ZonesPerTilePacket {
+		return 0, fmt.Errorf("%w: width %d", ErrInvalidMatrixSize, width)
+	}
+	return ZonesPerTilePacket / width, nil
+}
+
+// MatrixPackets returns the number of Tile 64 messages needed to get or set all the zones of a matrix
+// of the given size, each one covering MatrixRowsPerPacket rows.
+func MatrixPackets(width, height int) (int, error) {
+	rows, err := MatrixRowsPerPacket(width)
+	if err != nil {
+		return 0, err
+	}
+	if height < 1 {
+		return 0, fmt.Errorf("%w: height %d", ErrInvalidMatrixSize, height)
+	}
+	return (height + rows - 1) / rows, nil
+}
@@ -0,0 +1,55 @@
+package device
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatrixPackets(t *testing.T) {
+	testCases := map[string]struct {
+		width, height int
+		wantRows      int
+		wantPackets   int
+		wantErr       error
+	}{
+		"Tile": {
+			width: 8, height: 8, wantRows: 8, wantPackets: 1,
+		},
+		"Candle": {
+			width: 5, height: 6, wantRows: 12, wantPackets: 1,
+		},
+		"Ceiling": {
+			width: 16, height: 8, wantRows: 4, wantPackets: 2,
+		},
+		"Width not dividing 64": {
+			width: 12, height: 12, wantRows: 5, wantPackets: 3,
+		},
+		"Single row of 64": {
+			width: 64, height: 2, wantRows: 1, wantPackets: 2,
+		},
+		"Zero width": {
+			width: 0, height: 8, wantErr: ErrInvalidMatrixSize,
+		},
+		"Width over 64": {
+			width: 65, height: 1, wantErr: ErrInvalidMatrixSize,
+		},
+		"Zero height": {
+			width: 8, height: 0, wantRows: 8, wantErr: ErrInvalidMatrixSize,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rows, err := MatrixRowsPerPacket(tc.width)
+			if tc.wantRows > 0 {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tc.wantRows, rows)
+
+			packets, err := MatrixPackets(tc.width, tc.height)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.wantPackets, packets)
+		})
+	}
+}
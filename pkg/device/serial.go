@@ -0,0 +1,62 @@
+package device
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+)
+
+// LIFXOUI is the organizationally unique identifier assigned to LIFX, the first 3 bytes of
+// the MAC address, and serial, of LIFX devices.
+var LIFXOUI = [3]byte{0xd0, 0x73, 0xd5}
+
+// SerialFromMAC returns the Serial of the device with the given 6-byte MAC address.
+func SerialFromMAC(mac net.HardwareAddr) (Serial, error) {
+	if len(mac) != 6 {
+		return Serial{}, fmt.Errorf("expected a 6 bytes MAC address, got %d bytes", len(mac))
+	}
+	var s Serial
+	copy(s[:6], mac)
+	return s, nil
+}
+
+// MAC returns the MAC address of the device, which is the serial number.
+func (s Serial) MAC() net.HardwareAddr {
+	return net.HardwareAddr(bytes.Clone(s[:6]))
+}
+
+// IsLIFX returns whether the serial starts with the LIFX OUI, which is the case for
+// genuine LIFX devices.
+func (s Serial) IsLIFX() bool {
+	return [3]byte(s[:3]) == LIFXOUI
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the serial as in String,
+// e.g. as a JSON string.
+func (s Serial) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts serials encoded as
+// hex strings, e.g. d073d5000001, and MAC addresses, e.g. d0:73:d5:00:00:01.
+func (s *Serial) UnmarshalText(text []byte) error {
+	if len(text) == 12 {
+		serial, err := SerialFromHex(string(text))
+		if err != nil {
+			return err
+		}
+		*s = serial
+		return nil
+	}
+
+	mac, err := net.ParseMAC(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid serial %q", text)
+	}
+	serial, err := SerialFromMAC(mac)
+	if err != nil {
+		return err
+	}
+	*s = serial
+	return nil
+}
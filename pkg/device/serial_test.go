@@ -0,0 +1,72 @@
+package device
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSerialMAC(t *testing.T) {
+	serial := Serial{0xd0, 0x73, 0xd5, 0x01, 0x02, 0x03}
+	mac := serial.MAC()
+	assert.Equal(t, "d0:73:d5:01:02:03", mac.String())
+
+	got, err := SerialFromMAC(mac)
+	require.NoError(t, err)
+	assert.Equal(t, serial, got)
+
+	_, err = SerialFromMAC(net.HardwareAddr{1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestSerialIsLIFX(t *testing.T) {
+	assert.True(t, Serial{0xd0, 0x73, 0xd5, 0x01}.IsLIFX())
+	assert.False(t, Serial{0x00, 0x73, 0xd5, 0x01}.IsLIFX())
+	assert.False(t, Serial{}.IsLIFX())
+}
+
+func TestSerialText(t *testing.T) {
+	serial := Serial{0xd0, 0x73, 0xd5, 0x01, 0x02, 0x03}
+
+	tests := map[string]struct {
+		text    string
+		want    Serial
+		wantErr bool
+	}{
+		"hex":                {text: "d073d5010203", want: serial},
+		"mac":                {text: "d0:73:d5:01:02:03", want: serial},
+		"mac with dashes":    {text: "D0-73-D5-01-02-03", want: serial},
+		"invalid hex":        {text: "d073d501020z", wantErr: true},
+		"eui-64 mac":         {text: "d0:73:d5:01:02:03:04:05", wantErr: true},
+		"invalid":            {text: "lamp", wantErr: true},
+		"empty":              {text: "", wantErr: true},
+		"truncated hex":      {text: "d073d50102", wantErr: true},
+		"mac without colons": {text: "d073.d501.0203", want: serial},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got Serial
+			err := got.UnmarshalText([]byte(tt.text))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	t.Run("json round trip", func(t *testing.T) {
+		b, err := json.Marshal(map[string]Serial{"serial": serial})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"serial":"d073d5010203"}`, string(b))
+
+		var got map[string]Serial
+		require.NoError(t, json.Unmarshal(b, &got))
+		assert.Equal(t, serial, got["serial"])
+	})
+}
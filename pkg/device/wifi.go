@@ -0,0 +1,57 @@
+package device
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// wifiSignalHistorySize is the number of signal samples kept in the WiFi signal history.
+const wifiSignalHistorySize = 10
+
+// signalQualities lists the WifiRSSI descriptions from the weakest to the strongest signal.
+var signalQualities = []string{SignalNone, SignalVeryPoor, SignalPoor, SignalFair, SignalGood, SignalExcellent}
+
+// SignalQuality returns the rank of the signal description, from 0 for no signal to 5 for an
+// excellent one, which allows comparing RSSI and SNR values.
+func (w WifiRSSI) SignalQuality() int {
+	return max(slices.Index(signalQualities, w.String()), 0)
+}
+
+// WifiInfo holds the diagnostics of the WiFi module of a device.
+type WifiInfo struct {
+	// FirmwareVersion is the version of the WiFi module firmware, empty until reported,
+	// and FirmwareBuildAt its build time.
+	FirmwareVersion string
+	FirmwareBuildAt time.Time
+	// SignalHistory holds the last signal samples reported by the device, oldest first.
+	SignalHistory []WifiRSSI
+}
+
+// SetWifiInfo records the signal reported by the device in the signal history and sets WifiRSSI.
+// It reports whether the signal quality changed, as samples fluctuate within the same quality.
+func (d *Device) SetWifiInfo(p *packets.DeviceStateWifiInfo) (updated bool) {
+	rssi := WifiRSSI(int(math.Floor(10*math.Log10(float64(p.Signal)) + 0.5)))
+	h := append(d.WifiInfo.SignalHistory, rssi)
+	d.WifiInfo.SignalHistory = h[max(len(h)-wifiSignalHistorySize, 0):]
+
+	if d.WifiRSSI.String() == rssi.String() {
+		return false
+	}
+	d.WifiRSSI = rssi
+	return true
+}
+
+// SetWifiFirmware sets the version and build time of the WiFi module firmware.
+func (d *Device) SetWifiFirmware(p *packets.DeviceStateWifiFirmware) (updated bool) {
+	version := fmt.Sprintf("%d.%d", p.VersionMajor, p.VersionMinor)
+	build := buildTime(p.Build)
+	if d.WifiInfo.FirmwareVersion == version && d.WifiInfo.FirmwareBuildAt.Equal(build) {
+		return false
+	}
+	d.WifiInfo.FirmwareVersion, d.WifiInfo.FirmwareBuildAt = version, build
+	return true
+}
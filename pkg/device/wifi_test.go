@@ -0,0 +1,56 @@
+package device
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetWifiInfo(t *testing.T) {
+	d := &Device{}
+	assert.True(t, d.SetWifiInfo(&packets.DeviceStateWifiInfo{Signal: 1e-4}))
+	assert.Equal(t, WifiRSSI(-40), d.WifiRSSI)
+
+	// Samples within the same quality are recorded without reporting an update.
+	assert.False(t, d.SetWifiInfo(&packets.DeviceStateWifiInfo{Signal: 1e-5}))
+	assert.Equal(t, WifiRSSI(-40), d.WifiRSSI)
+	assert.True(t, d.SetWifiInfo(&packets.DeviceStateWifiInfo{Signal: 1e-7}))
+	assert.Equal(t, WifiRSSI(-70), d.WifiRSSI)
+	assert.Equal(t, []WifiRSSI{-40, -50, -70}, d.WifiInfo.SignalHistory)
+
+	for range 20 {
+		d.SetWifiInfo(&packets.DeviceStateWifiInfo{Signal: 1e-6})
+	}
+	assert.Len(t, d.WifiInfo.SignalHistory, wifiSignalHistorySize)
+	assert.Equal(t, WifiRSSI(-60), d.WifiInfo.SignalHistory[0])
+}
+
+func TestSetWifiFirmware(t *testing.T) {
+	d := &Device{}
+	build := time.Date(2023, 1, 2, 0, 0, 0, 0, time.UTC)
+	p := &packets.DeviceStateWifiFirmware{Build: uint64(build.UnixNano()), VersionMajor: 1, VersionMinor: 4}
+	assert.True(t, d.SetWifiFirmware(p))
+	assert.False(t, d.SetWifiFirmware(p))
+	assert.Equal(t, "1.4", d.WifiInfo.FirmwareVersion)
+	assert.Equal(t, build, d.WifiInfo.FirmwareBuildAt)
+}
+
+func TestWifiRSSISignalQuality(t *testing.T) {
+	testCases := map[string]struct {
+		rssi WifiRSSI
+		want int
+	}{
+		"Excellent RSSI": {rssi: -45, want: 5},
+		"Fair RSSI":      {rssi: -65, want: 3},
+		"Very poor RSSI": {rssi: -90, want: 1},
+		"Good SNR":       {rssi: 18, want: 4},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, tc.rssi.SignalQuality())
+		})
+	}
+}
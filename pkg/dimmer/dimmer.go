@@ -0,0 +1,171 @@
+// Package dimmer implements virtual dimmers that control the brightness of a group
+// of devices with a single level, preserving their relative brightness.
+package dimmer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+const (
+	// defaultRampStep is the interval between brightness updates while ramping.
+	defaultRampStep = 250 * time.Millisecond
+)
+
+var (
+	// ErrNoMembers is returned when a dimmer has no members.
+	ErrNoMembers = errors.New("dimmer has no members")
+	// ErrMissingSender is returned when a dimmer is created without a Sender.
+	ErrMissingSender = errors.New("missing sender")
+)
+
+// Sender sends a message to a device. *controller.Controller implements it.
+type Sender interface {
+	Send(serial device.Serial, msg *protocol.Message) error
+}
+
+// Member is a device bound to a dimmer.
+type Member struct {
+	Serial device.Serial
+	// Max is the brightness (0-100) of the member when the dimmer level is 100.
+	// Members are scaled proportionally, i.e. at level 50 a member with Max 80 is set to 40.
+	Max float64
+}
+
+// Dimmer exposes a single 0-100 brightness level for a group of devices.
+type Dimmer struct {
+	name   string
+	sender Sender
+
+	mu      sync.Mutex
+	members []Member
+	level   float64
+}
+
+// New returns a Dimmer controlling the given members.
+func New(name string, sender Sender, members ...Member) (*Dimmer, error) {
+	if sender == nil {
+		return nil, ErrMissingSender
+	}
+	if len(members) == 0 {
+		return nil, ErrNoMembers
+	}
+	ms := make([]Member, len(members))
+	for i, m := range members {
+		ms[i] = Member{Serial: m.Serial, Max: clamp(m.Max)}
+	}
+	return &Dimmer{name: name, sender: sender, members: ms}, nil
+}
+
+// FromDevices returns a Dimmer bound to the given devices that preserves their current
+// relative brightness. The brightest device sets the dimmer level and is scaled up to 100
+// when the dimmer is at 100, with all other devices scaled proportionally.
+// The brightness of powered off devices is considered too, as it is restored when they
+// are powered on. Devices that are all at 0 brightness are assigned a maximum of 100.
+func FromDevices(name string, sender Sender, devices ...device.Device) (*Dimmer, error) {
+	var brightest float64
+	for _, d := range devices {
+		brightest = max(brightest, d.Color.Brightness)
+	}
+
+	members := make([]Member, len(devices))
+	for i, d := range devices {
+		members[i] = Member{Serial: d.Serial, Max: 100}
+		if brightest > 0 {
+			members[i].Max = d.Color.Brightness / brightest * 100
+		}
+	}
+
+	dimmer, err := New(name, sender, members...)
+	if err != nil {
+		return nil, err
+	}
+	dimmer.level = brightest
+	return dimmer, nil
+}
+
+// Name returns the dimmer name.
+func (d *Dimmer) Name() string {
+	return d.name
+}
+
+// Members returns a copy of the dimmer members.
+func (d *Dimmer) Members() []Member {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Member(nil), d.members...)
+}
+
+// Level returns the last level set on the dimmer.
+func (d *Dimmer) Level() float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.level
+}
+
+// SetLevel sets the dimmer level (0-100), scaling each member brightness proportionally.
+// The transition is performed by the devices over the given duration.
+// The level is updated even if sending to some members fails, in which case the errors are returned.
+func (d *Dimmer) SetLevel(level float64, transition time.Duration) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.setLevel(clamp(level), transition)
+}
+
+// Ramp gradually moves the dimmer level to target over the given duration, updating
+// Level as it progresses. It returns early with the context error if ctx is cancelled,
+// leaving the dimmer at the level reached so far.
+func (d *Dimmer) Ramp(ctx context.Context, target float64, duration time.Duration) error {
+	target = clamp(target)
+	steps := max(1, int(duration/defaultRampStep))
+	step := duration / time.Duration(steps)
+	from := d.Level()
+
+	ticker := time.NewTicker(max(step, time.Millisecond))
+	defer ticker.Stop()
+
+	for i := 1; i <= steps; i++ {
+		level := from + (target-from)*float64(i)/float64(steps)
+		d.mu.Lock()
+		err := d.setLevel(level, step)
+		d.mu.Unlock()
+		if err != nil {
+			return err
+		}
+		if i == steps {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// setLevel must be called with d.mu held.
+func (d *Dimmer) setLevel(level float64, transition time.Duration) error {
+	d.level = level
+
+	var errs []error
+	for _, m := range d.members {
+		b := m.Max * level / 100
+		if err := d.sender.Send(m.Serial, messages.SetColor(nil, nil, &b, nil, transition, 0)); err != nil {
+			errs = append(errs, fmt.Errorf("failed to set brightness of %s: %w", m.Serial, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func clamp(v float64) float64 {
+	return max(0, min(v, 100))
+}
@@ -0,0 +1,170 @@
+package dimmer
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	serial0 = device.Serial{1, 0, 0, 0, 0, 0, 0, 0}
+	serial1 = device.Serial{2, 0, 0, 0, 0, 0, 0, 0}
+)
+
+type mockSender struct {
+	mu         sync.Mutex
+	brightness map[device.Serial][]float64
+	err        error
+}
+
+func newMockSender() *mockSender {
+	return &mockSender{brightness: make(map[device.Serial][]float64)}
+}
+
+func (m *mockSender) Send(serial device.Serial, msg *protocol.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p := msg.Payload.(*packets.LightSetWaveformOptional)
+	m.brightness[serial] = append(m.brightness[serial], device.ConvertDeviceValueToExternal(p.Color.Brightness, 100))
+	return m.err
+}
+
+func (m *mockSender) last(serial device.Serial) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := m.brightness[serial]
+	return b[len(b)-1]
+}
+
+func TestNew(t *testing.T) {
+	_, err := New("lounge", nil, Member{Serial: serial0})
+	assert.ErrorIs(t, err, ErrMissingSender)
+
+	_, err = New("lounge", newMockSender())
+	assert.ErrorIs(t, err, ErrNoMembers)
+
+	d, err := New("lounge", newMockSender(), Member{Serial: serial0, Max: 120})
+	require.NoError(t, err)
+	assert.Equal(t, "lounge", d.Name())
+	assert.Equal(t, []Member{{Serial: serial0, Max: 100}}, d.Members())
+}
+
+func TestFromDevices(t *testing.T) {
+	testCases := map[string]struct {
+		devices     []device.Device
+		wantMembers []Member
+		wantLevel   float64
+	}{
+		"preserves relative brightness": {
+			devices: []device.Device{
+				{Serial: serial0, PoweredOn: true, Color: device.Color{Brightness: 80}},
+				{Serial: serial1, PoweredOn: true, Color: device.Color{Brightness: 40}},
+			},
+			wantMembers: []Member{{Serial: serial0, Max: 100}, {Serial: serial1, Max: 50}},
+			wantLevel:   80,
+		},
+		"powered off devices keep their brightness": {
+			devices: []device.Device{
+				{Serial: serial0, PoweredOn: true, Color: device.Color{Brightness: 50}},
+				{Serial: serial1, Color: device.Color{Brightness: 25}},
+			},
+			wantMembers: []Member{{Serial: serial0, Max: 100}, {Serial: serial1, Max: 50}},
+			wantLevel:   50,
+		},
+		"powered off device brightest": {
+			devices: []device.Device{
+				{Serial: serial0, PoweredOn: true, Color: device.Color{Brightness: 50}},
+				{Serial: serial1, Color: device.Color{Brightness: 100}},
+			},
+			wantMembers: []Member{{Serial: serial0, Max: 50}, {Serial: serial1, Max: 100}},
+			wantLevel:   100,
+		},
+		"all at zero brightness": {
+			devices: []device.Device{
+				{Serial: serial0},
+				{Serial: serial1, PoweredOn: true},
+			},
+			wantMembers: []Member{{Serial: serial0, Max: 100}, {Serial: serial1, Max: 100}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			d, err := FromDevices("lounge", newMockSender(), tc.devices...)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantMembers, d.Members())
+			assert.Equal(t, tc.wantLevel, d.Level())
+		})
+	}
+}
+
+func TestSetLevel(t *testing.T) {
+	sender := newMockSender()
+	d, err := New("lounge", sender, Member{Serial: serial0, Max: 100}, Member{Serial: serial1, Max: 50})
+	require.NoError(t, err)
+
+	require.NoError(t, d.SetLevel(60, time.Second))
+	assert.Equal(t, 60.0, d.Level())
+	assert.InDelta(t, 60, sender.last(serial0), 0.01)
+	assert.InDelta(t, 30, sender.last(serial1), 0.01)
+
+	require.NoError(t, d.SetLevel(150, 0))
+	assert.Equal(t, 100.0, d.Level())
+
+	sender.err = errors.New("send failed")
+	assert.ErrorIs(t, d.SetLevel(10, 0), sender.err)
+	assert.Equal(t, 10.0, d.Level())
+}
+
+func TestRamp(t *testing.T) {
+	t.Run("Reaches target", func(t *testing.T) {
+		sender := newMockSender()
+		d, err := New("lounge", sender, Member{Serial: serial0, Max: 100})
+		require.NoError(t, err)
+
+		require.NoError(t, d.Ramp(context.Background(), 100, 4*defaultRampStep))
+		assert.Equal(t, 100.0, d.Level())
+		assert.Equal(t, []float64{25, 50, 75, 100}, sender.brightness[serial0])
+	})
+
+	t.Run("Stops when cancelled", func(t *testing.T) {
+		sender := newMockSender()
+		d, err := New("lounge", sender, Member{Serial: serial0, Max: 100})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, d.Ramp(ctx, 100, time.Minute), context.Canceled)
+		assert.Greater(t, d.Level(), 0.0)
+		assert.Less(t, d.Level(), 100.0)
+	})
+}
+
+func TestState(t *testing.T) {
+	d, err := New("lounge", newMockSender(), Member{Serial: serial0, Max: 100}, Member{Serial: serial1, Max: 50})
+	require.NoError(t, err)
+	require.NoError(t, d.SetLevel(40, 0))
+
+	b, err := json.Marshal(d.State())
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name":"lounge","level":40,"members":[{"serial":"010000000000","max":100},{"serial":"020000000000","max":50}]}`, string(b))
+
+	var s State
+	require.NoError(t, json.Unmarshal(b, &s))
+	restored, err := FromState(newMockSender(), s)
+	require.NoError(t, err)
+	assert.Equal(t, d.Members(), restored.Members())
+	assert.Equal(t, 40.0, restored.Level())
+
+	_, err = FromState(newMockSender(), State{Members: []MemberState{{Serial: "xyz"}}})
+	assert.Error(t, err)
+}
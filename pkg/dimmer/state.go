@@ -0,0 +1,53 @@
+package dimmer
+
+import (
+	"fmt"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// State is the persistable state of a Dimmer.
+type State struct {
+	Name    string        `json:"name"`
+	Level   float64       `json:"level"`
+	Members []MemberState `json:"members"`
+}
+
+// MemberState is the persistable state of a Member.
+type MemberState struct {
+	Serial string  `json:"serial"`
+	Max    float64 `json:"max"`
+}
+
+// State returns the current state of the dimmer, which can be encoded and later
+// used to restore the dimmer with FromState.
+func (d *Dimmer) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	s := State{Name: d.name, Level: d.level, Members: make([]MemberState, len(d.members))}
+	for i, m := range d.members {
+		s.Members[i] = MemberState{Serial: m.Serial.String(), Max: m.Max}
+	}
+	return s
+}
+
+// FromState restores a Dimmer from a previously saved State.
+// Restoring a dimmer does not send any message to its members.
+func FromState(sender Sender, s State) (*Dimmer, error) {
+	members := make([]Member, len(s.Members))
+	for i, m := range s.Members {
+		serial, err := device.SerialFromHex(m.Serial)
+		if err != nil {
+			return nil, fmt.Errorf("invalid member serial %q: %w", m.Serial, err)
+		}
+		members[i] = Member{Serial: serial, Max: m.Max}
+	}
+
+	d, err := New(s.Name, sender, members...)
+	if err != nil {
+		return nil, err
+	}
+	d.level = clamp(s.Level)
+	return d, nil
+}
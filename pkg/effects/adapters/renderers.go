@@ -23,10 +23,12 @@ var (
 )
 
 // SendFunc sends a target-bound protocol message.
-type SendFunc func(*protocol.Message) error
+//
+// Deprecated: use protocol.SendFunc, which it aliases.
+type SendFunc = protocol.SendFunc
 
 // NewRendererForDevice returns a renderer configured from device capabilities.
-func NewRendererForDevice(d device.Device, send SendFunc) effects.Renderer {
+func NewRendererForDevice(d device.Device, send protocol.SendFunc) effects.Renderer {
 	switch d.LightType {
 	case device.LightTypeMultiZone:
 		return NewMultiZoneRenderer(send, WithMultiZoneSurface(device.SurfaceFromDevice(d)))
@@ -45,7 +47,7 @@ func NewRendererForDevice(d device.Device, send SendFunc) effects.Renderer {
 
 // SingleZoneRenderer renders frames to a single-zone light.
 type SingleZoneRenderer struct {
-	send      SendFunc
+	send      protocol.SendFunc
 	waveform  enums.LightWaveform
 	reduction effects.ReductionStrategy
 }
@@ -68,7 +70,7 @@ func WithSingleZoneReduction(reduction effects.ReductionStrategy) SingleZoneOpti
 }
 
 // NewSingleZoneRenderer returns a single-zone renderer bound to send.
-func NewSingleZoneRenderer(send SendFunc, opts ...SingleZoneOption) *SingleZoneRenderer {
+func NewSingleZoneRenderer(send protocol.SendFunc, opts ...SingleZoneOption) *SingleZoneRenderer {
 	r := &SingleZoneRenderer{send: send}
 	for _, opt := range opts {
 		opt(r)
@@ -104,7 +106,7 @@ func (r *SingleZoneRenderer) RenderFrame(ctx context.Context, frame effects.Fram
 
 // MultiZoneRenderer renders frames to a multi-zone light.
 type MultiZoneRenderer struct {
-	send       SendFunc
+	send       protocol.SendFunc
 	startIndex int
 	surface    *device.Surface
 }
@@ -127,7 +129,7 @@ func WithMultiZoneSurface(surface device.Surface) MultiZoneOption {
 }
 
 // NewMultiZoneRenderer returns a multi-zone renderer bound to send.
-func NewMultiZoneRenderer(send SendFunc, opts ...MultiZoneOption) *MultiZoneRenderer {
+func NewMultiZoneRenderer(send protocol.SendFunc, opts ...MultiZoneOption) *MultiZoneRenderer {
 	r := &MultiZoneRenderer{send: send}
 	for _, opt := range opts {
 		opt(r)
@@ -159,7 +161,7 @@ func (r *MultiZoneRenderer) RenderFrame(ctx context.Context, frame effects.Frame
 
 // MatrixRenderer renders frames to a matrix light.
 type MatrixRenderer struct {
-	send        SendFunc
+	send        protocol.SendFunc
 	startIndex  int
 	length      int
 	orientation *device.Orientation
@@ -203,7 +205,7 @@ func WithMatrixTransitionDuration(duration time.Duration) MatrixOption {
 }
 
 // NewMatrixRenderer returns a matrix renderer bound to send.
-func NewMatrixRenderer(send SendFunc, opts ...MatrixOption) *MatrixRenderer {
+func NewMatrixRenderer(send protocol.SendFunc, opts ...MatrixOption) *MatrixRenderer {
 	r := &MatrixRenderer{send: send, length: 1}
 	for _, opt := range opts {
 		opt(r)
@@ -252,7 +254,7 @@ func (r *MatrixRenderer) RenderFrame(ctx context.Context, frame effects.Frame) e
 	return nil
 }
 
-func validateRenderer(ctx context.Context, send SendFunc) (context.Context, error) {
+func validateRenderer(ctx context.Context, send protocol.SendFunc) (context.Context, error) {
 	if ctx == nil {
 		ctx = context.Background()
 	}
@@ -356,7 +358,7 @@ func mapEffectsError(err error) error {
 	}
 }
 
-func sendAll(ctx context.Context, send SendFunc, msgs []*protocol.Message) error {
+func sendAll(ctx context.Context, send protocol.SendFunc, msgs []*protocol.Message) error {
 	for _, msg := range msgs {
 		if err := ctx.Err(); err != nil {
 			return err
@@ -5,9 +5,10 @@ import (
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/effects"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 )
 
 // RunEffects runs effects in order using a renderer configured from d.
-func RunEffects(ctx context.Context, d device.Device, send SendFunc, runs ...effects.RunConfig) error {
+func RunEffects(ctx context.Context, d device.Device, send protocol.SendFunc, runs ...effects.RunConfig) error {
 	return effects.RunSequence(ctx, NewRendererForDevice(d, send), runs...)
 }
@@ -0,0 +1,99 @@
+package effects
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// MatrixFramesConfig configures a MatrixFrames effect.
+type MatrixFramesConfig struct {
+	Capabilities Capabilities
+	// Frames draws the frames of the matrix package effect, e.g. matrix.FireFrames.
+	Frames matrix.FramesEffect
+	// Params are the parameters of the matrix effect. The Interval, Mode, Interpolation
+	// and Stagger are ignored, frames being paced by the runner.
+	Params matrix.EffectParams
+}
+
+// MatrixFrames adapts an effect of the matrix package to an Effect, drawing its frames on a
+// matrix with the logical size of the capabilities.
+type MatrixFrames struct {
+	cfg    MatrixFramesConfig
+	matrix *matrix.Matrix
+	frames *matrix.Frames
+}
+
+// NewMatrixFrames returns a MatrixFrames effect, or an error wrapping ErrInvalidConfig if the
+// matrix effect rejects its parameters, e.g. a pattern larger than the matrix.
+func NewMatrixFrames(cfg MatrixFramesConfig) (*MatrixFrames, error) {
+	e := &MatrixFrames{cfg: cfg}
+	if err := e.start(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// newMatrixFrames returns NewMatrixFrames as an Effect, nil on error.
+func newMatrixFrames(cfg MatrixFramesConfig) (Effect, error) {
+	e, err := NewMatrixFrames(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Next draws and returns the next frame of the matrix effect.
+func (e *MatrixFrames) Next(dt time.Duration) (Frame, bool) {
+	if e.frames == nil && e.start() != nil {
+		return Frame{}, false
+	}
+	if !e.frames.Next() {
+		return Frame{}, false
+	}
+
+	colors := make([]Color, 0, e.matrix.Size)
+	for _, row := range e.matrix.Colors {
+		for _, c := range row {
+			colors = append(colors, matrixPixelColor(c))
+		}
+	}
+	return Frame{Colors: colors, Width: e.matrix.Width, Height: e.matrix.Height, Duration: dt}, true
+}
+
+// Reset restarts the matrix effect on a blank matrix.
+func (e *MatrixFrames) Reset() {
+	e.frames = nil
+}
+
+func (e *MatrixFrames) start() error {
+	width, height := frameDimensions(e.cfg.Capabilities)
+	e.matrix = matrix.New(width, height, 1)
+	frames, err := e.cfg.Frames(e.matrix, e.cfg.Params)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	e.frames = frames
+	return nil
+}
+
+// matrixPixelColor returns the color of a matrix pixel, blank if it was never set.
+func matrixPixelColor(c packets.LightHsbk) Color {
+	if c == (packets.LightHsbk{}) {
+		return blankColor
+	}
+	return device.NewColor(c)
+}
+
+// matrixPalette returns the colors of the palette for a matrix effect.
+func matrixPalette(palette Palette) []packets.LightHsbk {
+	colors := paletteColors(palette)
+	hsbks := make([]packets.LightHsbk, len(colors))
+	for i, c := range colors {
+		hsbks[i] = c.ToDeviceColor()
+	}
+	return hsbks
+}
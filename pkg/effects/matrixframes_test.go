@@ -0,0 +1,77 @@
+package effects
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+)
+
+func TestMatrixFramesMatchesMatrixEffect(t *testing.T) {
+	params := matrix.NewEffectParams(
+		matrix.WithText("I", matrix.ScrollLeft),
+		matrix.WithPalette(color(10).ToDeviceColor()),
+		matrix.WithCycles(1),
+	)
+	effect, err := NewMatrixFrames(MatrixFramesConfig{Capabilities: matrixCaps(8, 8), Frames: matrix.ScrollTextFrames, Params: params})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := matrix.New(8, 8, 1)
+	frames, err := matrix.ScrollTextFrames(m, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []Frame
+	for frames.Next() {
+		colors := make([]Color, 0, 64)
+		for _, row := range m.Colors {
+			for _, c := range row {
+				colors = append(colors, matrixPixelColor(c))
+			}
+		}
+		want = append(want, Frame{Colors: colors, Width: 8, Height: 8, Duration: time.Second})
+	}
+
+	got := collectFrames(effect)
+	if len(got) == 0 || !reflect.DeepEqual(got, want) {
+		t.Fatalf("frames = %#v, want %#v", got, want)
+	}
+	if lit := coloredPoints(got[len(got)/2]); len(lit) == 0 {
+		t.Fatal("middle frame has no lit pixels")
+	}
+	if got[0].Colors[0] != blankColor {
+		t.Fatalf("unset pixel = %#v, want %#v", got[0].Colors[0], blankColor)
+	}
+
+	effect.Reset()
+	if again := collectFrames(effect); !reflect.DeepEqual(again, want) {
+		t.Fatalf("frames after Reset = %#v, want %#v", again, want)
+	}
+}
+
+func TestMatrixFramesRejectsInvalidParams(t *testing.T) {
+	_, err := NewMatrixFrames(MatrixFramesConfig{
+		Capabilities: Capabilities{LightType: device.LightTypeMatrix, Width: 4, Height: 4},
+		Frames:       matrix.ClockFrames,
+		Params:       matrix.NewEffectParams(matrix.WithPalette(color(10).ToDeviceColor())),
+	})
+	if !errors.Is(err, ErrInvalidConfig) || !errors.Is(err, matrix.ErrMatrixTooSmall) {
+		t.Fatalf("error = %v, want %v and %v", err, ErrInvalidConfig, matrix.ErrMatrixTooSmall)
+	}
+}
+
+func collectFrames(effect Effect) []Frame {
+	var frames []Frame
+	for {
+		frame, ok := effect.Next(time.Second)
+		if !ok {
+			return frames
+		}
+		frames = append(frames, frame)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
 )
 
 // EffectID identifies a registered effect.
@@ -33,6 +34,8 @@ const (
 	ParamPalette ParamKind = "palette"
 	// ParamDuration is a time.Duration parameter.
 	ParamDuration ParamKind = "duration"
+	// ParamString is a free text parameter.
+	ParamString ParamKind = "string"
 )
 
 const (
@@ -54,6 +57,22 @@ const (
 	EffectWave EffectID = "wave"
 	// EffectConcentricFrames identifies the ConcentricFrames matrix effect.
 	EffectConcentricFrames EffectID = "concentric_frames"
+	// EffectClock identifies the matrix.ClockFrames matrix effect.
+	EffectClock EffectID = "clock"
+	// EffectFire identifies the matrix.FireFrames matrix effect.
+	EffectFire EffectID = "fire"
+	// EffectPlasma identifies the matrix.PlasmaFrames matrix effect.
+	EffectPlasma EffectID = "plasma"
+	// EffectSparkle identifies the matrix.SparkleFrames matrix effect.
+	EffectSparkle EffectID = "sparkle"
+	// EffectRain identifies the matrix.RainFrames matrix effect.
+	EffectRain EffectID = "rain"
+	// EffectLife identifies the matrix.LifeFrames matrix effect.
+	EffectLife EffectID = "life"
+	// EffectMaze identifies the matrix.MazeFrames matrix effect.
+	EffectMaze EffectID = "maze"
+	// EffectScrollText identifies the matrix.ScrollTextFrames matrix effect.
+	EffectScrollText EffectID = "scroll_text"
 )
 
 var (
@@ -338,6 +357,185 @@ func init() {
 			return NewConcentricFrames(ConcentricFramesConfig{Capabilities: caps, Direction: direction, Colors: paletteColors(palette), Cycles: cycles}), nil
 		},
 	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectClock,
+		Label:       "Clock",
+		Description: "Show the current time, the palette coloring the hours, minutes and seconds.",
+		DeviceKinds: matrixLightTypes(),
+		Params: []ParamDefinition{
+			paletteParamDefinition(defaultPalette),
+			{Key: "hour12", Label: "12 Hour", Kind: ParamBool, Default: false},
+			{Key: "seconds", Label: "Seconds", Kind: ParamBool, Default: false},
+		},
+		New: func(config Config, caps Capabilities) (Effect, error) {
+			palette, err := paletteParam(config.Params, "palette")
+			if err != nil {
+				return nil, err
+			}
+			hour12, err := BoolParam(config.Params, "hour12")
+			if err != nil {
+				return nil, err
+			}
+			seconds, err := BoolParam(config.Params, "seconds")
+			if err != nil {
+				return nil, err
+			}
+			return newMatrixFrames(MatrixFramesConfig{Capabilities: caps, Frames: matrix.ClockFrames, Params: matrix.NewEffectParams(
+				matrix.WithPalette(matrixPalette(palette)...),
+				matrix.WithClockLayout(matrix.ClockLayout{Hour12: hour12, Seconds: seconds}),
+			)})
+		},
+	})
+
+	firePalette := Palette{Base: []Color{
+		{Hue: 0, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 30, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 55, Saturation: 100, Brightness: 100, Kelvin: 3500},
+	}}
+	mustRegister(EffectDefinition{
+		ID:          EffectFire,
+		Label:       "Fire",
+		Description: "Simulate flames rising from the bottom row, the palette going from the coolest to the hottest color.",
+		DeviceKinds: matrixLightTypes(),
+		Params:      proceduralParamDefinitions(firePalette),
+		New:         newProceduralEffect(matrix.FireFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectPlasma,
+		Label:       "Plasma",
+		Description: "Map drifting Perlin noise onto the palette.",
+		DeviceKinds: matrixLightTypes(),
+		Params:      proceduralParamDefinitions(defaultPalette),
+		New:         newProceduralEffect(matrix.PlasmaFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectSparkle,
+		Label:       "Sparkle",
+		Description: "Light random pixels with palette colors that fade out.",
+		DeviceKinds: matrixLightTypes(),
+		Params:      proceduralParamDefinitions(defaultPalette),
+		New:         newProceduralEffect(matrix.SparkleFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectRain,
+		Label:       "Rain",
+		Description: "Drop palette colors down the columns, each leaving a fading trail.",
+		DeviceKinds: matrixLightTypes(),
+		Params:      proceduralParamDefinitions(defaultPalette),
+		New:         newProceduralEffect(matrix.RainFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectLife,
+		Label:       "Life",
+		Description: "Run Conway's Game of Life from random cells, coloring cells by age.",
+		DeviceKinds: matrixLightTypes(),
+		Params: []ParamDefinition{
+			paletteParamDefinition(defaultPalette),
+			cyclesParamDefinition(),
+		},
+		New: newMatrixFramesEffect(matrix.LifeFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectMaze,
+		Label:       "Maze",
+		Description: "Carve a random maze, then walk its solution.",
+		DeviceKinds: matrixLightTypes(),
+		Params: []ParamDefinition{
+			paletteParamDefinition(defaultPalette),
+			cyclesParamDefinition(),
+		},
+		New: newMatrixFramesEffect(matrix.MazeFrames),
+	})
+
+	mustRegister(EffectDefinition{
+		ID:          EffectScrollText,
+		Label:       "Scroll Text",
+		Description: "Scroll text across the matrix.",
+		DeviceKinds: matrixLightTypes(),
+		Params: []ParamDefinition{
+			{Key: "text", Label: "Text", Kind: ParamString, Required: true},
+			colorParamDefinition(DefaultColor),
+			scrollDirectionParamDefinition(),
+			cyclesParamDefinition(),
+		},
+		New: func(config Config, caps Capabilities) (Effect, error) {
+			text, err := StringParam(config.Params, "text")
+			if err != nil {
+				return nil, err
+			}
+			color, err := colorParam(config.Params, "color")
+			if err != nil {
+				return nil, err
+			}
+			direction, err := scrollDirectionParam(config.Params, "direction")
+			if err != nil {
+				return nil, err
+			}
+			cycles, err := intParam(config.Params, "cycles")
+			if err != nil {
+				return nil, err
+			}
+			return newMatrixFrames(MatrixFramesConfig{Capabilities: caps, Frames: matrix.ScrollTextFrames, Params: matrix.NewEffectParams(
+				matrix.WithText(text, direction),
+				matrix.WithPalette(color.ToDeviceColor()),
+				matrix.WithCycles(cycles),
+			)})
+		},
+	})
+}
+
+// newMatrixFramesEffect returns the constructor of a matrix package effect reading a palette
+// and cycles.
+func newMatrixFramesEffect(frames matrix.FramesEffect) func(Config, Capabilities) (Effect, error) {
+	return func(config Config, caps Capabilities) (Effect, error) {
+		palette, err := paletteParam(config.Params, "palette")
+		if err != nil {
+			return nil, err
+		}
+		cycles, err := intParam(config.Params, "cycles")
+		if err != nil {
+			return nil, err
+		}
+		return newMatrixFrames(MatrixFramesConfig{Capabilities: caps, Frames: frames, Params: matrix.NewEffectParams(
+			matrix.WithPalette(matrixPalette(palette)...),
+			matrix.WithCycles(cycles),
+		)})
+	}
+}
+
+// newProceduralEffect returns the constructor of a procedural matrix package effect, which
+// reads a speed and an intensity besides a palette and cycles.
+func newProceduralEffect(frames matrix.FramesEffect) func(Config, Capabilities) (Effect, error) {
+	return func(config Config, caps Capabilities) (Effect, error) {
+		palette, err := paletteParam(config.Params, "palette")
+		if err != nil {
+			return nil, err
+		}
+		speed, err := NumberParam(config.Params, "speed")
+		if err != nil {
+			return nil, err
+		}
+		intensity, err := NumberParam(config.Params, "intensity")
+		if err != nil {
+			return nil, err
+		}
+		cycles, err := intParam(config.Params, "cycles")
+		if err != nil {
+			return nil, err
+		}
+		return newMatrixFrames(MatrixFramesConfig{Capabilities: caps, Frames: frames, Params: matrix.NewEffectParams(
+			matrix.WithPalette(matrixPalette(palette)...),
+			matrix.WithSpeed(speed),
+			matrix.WithIntensity(intensity),
+			matrix.WithCycles(cycles),
+		)})
+	}
 }
 
 // Register adds def to the global effect registry.
@@ -522,6 +720,15 @@ func validateParamValue(def ParamDefinition, value any) (any, error) {
 			return nil, fmt.Errorf("%w: parameter %q must be a duration", ErrInvalidConfig, def.Key)
 		}
 		return duration, nil
+	case ParamString:
+		text, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: parameter %q must be a string", ErrInvalidConfig, def.Key)
+		}
+		if def.Required && text == "" {
+			return nil, fmt.Errorf("%w: missing required parameter %q", ErrInvalidConfig, def.Key)
+		}
+		return text, nil
 	default:
 		return nil, fmt.Errorf("%w: unknown parameter kind %q", ErrInvalidConfig, def.Kind)
 	}
@@ -605,6 +812,19 @@ func DurationParam(params map[string]any, key string) (time.Duration, error) {
 	return duration, nil
 }
 
+// StringParam returns a validated string parameter.
+func StringParam(params map[string]any, key string) (string, error) {
+	value, err := requiredParam(params, key)
+	if err != nil {
+		return "", err
+	}
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: parameter %q must be a string", ErrInvalidConfig, key)
+	}
+	return text, nil
+}
+
 func colorParam(params map[string]any, key string) (Color, error) {
 	return ColorParam(params, key)
 }
@@ -643,6 +863,25 @@ func directionParam(params map[string]any, key string) (Direction, error) {
 	}
 }
 
+func scrollDirectionParam(params map[string]any, key string) (matrix.ScrollDirection, error) {
+	choice, err := ChoiceParam(params, key)
+	if err != nil {
+		return matrix.ScrollLeft, err
+	}
+	switch choice {
+	case "left":
+		return matrix.ScrollLeft, nil
+	case "right":
+		return matrix.ScrollRight, nil
+	case "up":
+		return matrix.ScrollUp, nil
+	case "down":
+		return matrix.ScrollDown, nil
+	default:
+		return matrix.ScrollLeft, fmt.Errorf("%w: parameter %q has invalid choice %q", ErrInvalidConfig, key, choice)
+	}
+}
+
 func requiredParam(params map[string]any, key string) (any, error) {
 	value, ok := params[key]
 	if !ok || value == nil {
@@ -857,7 +1096,7 @@ func matrixLightTypes() []device.LightType {
 
 func knownParamKind(kind ParamKind) bool {
 	switch kind {
-	case ParamNumber, ParamBool, ParamChoiceKind, ParamColor, ParamPalette, ParamDuration:
+	case ParamNumber, ParamBool, ParamChoiceKind, ParamColor, ParamPalette, ParamDuration, ParamString:
 		return true
 	default:
 		return false
@@ -952,6 +1191,46 @@ func directionParamDefinition() ParamDefinition {
 	}
 }
 
+func scrollDirectionParamDefinition() ParamDefinition {
+	return ParamDefinition{
+		Key:     "direction",
+		Label:   "Direction",
+		Kind:    ParamChoiceKind,
+		Default: "left",
+		Choices: []ParamChoice{
+			{Value: "left", Label: "Left"},
+			{Value: "right", Label: "Right"},
+			{Value: "up", Label: "Up"},
+			{Value: "down", Label: "Down"},
+		},
+	}
+}
+
+func proceduralParamDefinitions(defaultPalette Palette) []ParamDefinition {
+	return []ParamDefinition{
+		paletteParamDefinition(defaultPalette),
+		{
+			Key:     "speed",
+			Label:   "Speed",
+			Kind:    ParamNumber,
+			Default: 1,
+			Min:     float64Ptr(0.1),
+			Max:     float64Ptr(10),
+			Step:    float64Ptr(0.1),
+		},
+		{
+			Key:     "intensity",
+			Label:   "Intensity",
+			Kind:    ParamNumber,
+			Default: 1,
+			Min:     float64Ptr(0),
+			Max:     float64Ptr(1),
+			Step:    float64Ptr(0.05),
+		},
+		cyclesParamDefinition(),
+	}
+}
+
 func paletteColors(palette Palette) []Color {
 	colors := make([]Color, 0, len(palette.Base)+len(palette.Accents))
 	colors = append(colors, palette.Base...)
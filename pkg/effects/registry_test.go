@@ -44,6 +44,14 @@ func TestDefinitionsDeterministicAndIncludeBuiltins(t *testing.T) {
 		EffectWorm,
 		EffectWave,
 		EffectConcentricFrames,
+		EffectClock,
+		EffectFire,
+		EffectPlasma,
+		EffectSparkle,
+		EffectRain,
+		EffectLife,
+		EffectMaze,
+		EffectScrollText,
 	} {
 		if !slices.Contains(ids, id) {
 			t.Fatalf("missing built-in definition %q from %#v", id, ids)
@@ -150,6 +158,34 @@ func TestNewConstructsBuiltInMatrixEffects(t *testing.T) {
 			}},
 			want: &ConcentricFrames{},
 		},
+		"fire": {
+			config: Config{ID: EffectFire, Params: map[string]any{"speed": 2, "intensity": 0.5, "cycles": 1}},
+			want:   &MatrixFrames{},
+		},
+		"plasma": {
+			config: Config{ID: EffectPlasma, Params: map[string]any{"palette": Palette{Base: []Color{color(10), color(20)}}}},
+			want:   &MatrixFrames{},
+		},
+		"sparkle": {
+			config: Config{ID: EffectSparkle},
+			want:   &MatrixFrames{},
+		},
+		"rain": {
+			config: Config{ID: EffectRain},
+			want:   &MatrixFrames{},
+		},
+		"life": {
+			config: Config{ID: EffectLife, Params: map[string]any{"cycles": 1}},
+			want:   &MatrixFrames{},
+		},
+		"maze": {
+			config: Config{ID: EffectMaze, Params: map[string]any{"palette": Palette{Base: []Color{color(10), color(20)}}}},
+			want:   &MatrixFrames{},
+		},
+		"scroll text": {
+			config: Config{ID: EffectScrollText, Params: map[string]any{"text": "Hi", "direction": "up"}},
+			want:   &MatrixFrames{},
+		},
 	}
 
 	caps := Capabilities{LightType: device.LightTypeMatrix, Width: 3, Height: 3}
@@ -169,6 +205,26 @@ func TestNewConstructsBuiltInMatrixEffects(t *testing.T) {
 	}
 }
 
+func TestNewRejectsInvalidMatrixFramesParams(t *testing.T) {
+	tests := map[string]Config{
+		"missing text": {ID: EffectScrollText},
+		"empty text":   {ID: EffectScrollText, Params: map[string]any{"text": ""}},
+		"text type":    {ID: EffectScrollText, Params: map[string]any{"text": 42}},
+		"clock size":   {ID: EffectClock},
+		"speed range":  {ID: EffectFire, Params: map[string]any{"speed": 0}},
+	}
+
+	caps := Capabilities{LightType: device.LightTypeMatrix, Width: 3, Height: 3}
+	for name, config := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(config, caps)
+			if !errors.Is(err, ErrInvalidConfig) {
+				t.Fatalf("error = %v, want %v", err, ErrInvalidConfig)
+			}
+		})
+	}
+}
+
 func TestNewRejectsUnknownEffect(t *testing.T) {
 	_, err := New(Config{ID: "missing"}, Capabilities{LightType: device.LightTypeSingleZone})
 	if !errors.Is(err, ErrUnknownEffect) {
@@ -0,0 +1,515 @@
+// Package emulator provides virtual LIFX devices answering the LAN protocol over real UDP,
+// so that applications can be integration tested against a Controller without hardware.
+//
+//	d, err := emulator.New(emulator.Config{ProductID: 55})
+//	if err != nil {
+//		panic(err)
+//	}
+//	defer d.Close()
+//	ctrl, err := controller.New(controller.WithStaticDevices(d.Addr().String()))
+//
+// Devices answer broadcast GetService messages as real devices do, but only listen on their own
+// address: reach them by unicast (see controller.WithStaticDevices and controller.AddDevice), or
+// discover them with a client broadcasting to their addresses:
+//
+//	c, err := client.NewClient(&client.Config{BroadcastAddrs: []*net.UDPAddr{d.Addr()}})
+//	ctrl, err := controller.New(controller.WithClient(c))
+package emulator
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/testutil"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/alessio-palumbo/lifxregistry-go/gen/registry"
+)
+
+const (
+	// DefaultProductID is the product emulated when none is configured, a LIFX (A19) color bulb.
+	DefaultProductID = 27
+	// DefaultZones is the number of zones of multizone products when none is configured.
+	DefaultZones = 16
+	// DefaultMatrixSize is the width and height of matrix products when none is configured.
+	DefaultMatrixSize = 8
+
+	// Zone colors sent per extended multizone and legacy multizone state message.
+	extendedZonesPerMessage = 82
+	legacyZonesPerMessage   = 8
+	// Maximum number of devices in a matrix chain.
+	maxChainLength = 16
+)
+
+// ErrInvalidConfig is returned when the device configuration is invalid.
+var ErrInvalidConfig = errors.New("invalid emulator config")
+
+// nextSerial is used to assign unique serials to devices created without one.
+var nextSerial atomic.Uint32
+
+// Config describes the emulated device.
+type Config struct {
+	// Serial of the device. By default a unique serial with the LIFX prefix is assigned.
+	Serial device.Serial
+	// ProductID is the registry product ID, which determines the device capabilities.
+	// It defaults to DefaultProductID.
+	ProductID uint32
+	// Label, Location and Group are the initial device labels.
+	Label, Location, Group string
+	// Zones is the number of zones of multizone products, which defaults to DefaultZones.
+	Zones int
+	// MatrixWidth and MatrixHeight are the size of each device in a matrix chain,
+	// which default to DefaultMatrixSize.
+	MatrixWidth, MatrixHeight int
+	// ChainLength is the number of devices in a matrix chain. It defaults to 5 for
+	// products supporting chains and 1 for other matrix products.
+	ChainLength int
+	// Address is the UDP address the device listens on. It defaults to a random port on the loopback interface.
+	Address string
+}
+
+// State is the emulated device state.
+type State struct {
+	Label, Location, Group string
+	LocationID, GroupID    [16]byte
+	// Power is the power level, either 0 or 65535.
+	Power uint16
+	Color packets.LightHsbk
+	// Zones are the colors of a multizone device.
+	Zones []packets.LightHsbk
+	// Chain are the colors of each device of a matrix chain.
+	Chain [][]packets.LightHsbk
+}
+
+// clone returns a deep copy of the state.
+func (s State) clone() State {
+	s.Zones = slices.Clone(s.Zones)
+	s.Chain = slices.Clone(s.Chain)
+	for i, colors := range s.Chain {
+		s.Chain[i] = slices.Clone(colors)
+	}
+	return s
+}
+
+// Device is a virtual LIFX device.
+type Device struct {
+	conn      *net.UDPConn
+	serial    device.Serial
+	productID uint32
+	features  registry.FeatureSet
+	width     int
+	height    int
+	done      chan struct{}
+	startedAt time.Time
+
+	mu    sync.Mutex
+	state State
+	// pendingZones are extended multizone colors buffered until applied.
+	pendingZones []packets.LightHsbk
+}
+
+// New starts a device described by cfg, listening for messages until closed.
+func New(cfg Config) (*Device, error) {
+	if cfg.ProductID == 0 {
+		cfg.ProductID = DefaultProductID
+	}
+	p, ok := registry.ProductsByPID[int(cfg.ProductID)]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown product %d", ErrInvalidConfig, cfg.ProductID)
+	}
+	if cfg.Serial.IsNil() {
+		n := nextSerial.Add(1)
+		cfg.Serial = device.Serial{0xd0, 0x73, 0xd5, byte(n >> 16), byte(n >> 8), byte(n)}
+	}
+	if cfg.Label == "" {
+		cfg.Label = p.Name
+	}
+	if cfg.Location == "" {
+		cfg.Location = "Home"
+	}
+	if cfg.Group == "" {
+		cfg.Group = "Emulated"
+	}
+
+	d := &Device{
+		serial:    cfg.Serial,
+		productID: cfg.ProductID,
+		features:  p.Features,
+		done:      make(chan struct{}),
+		startedAt: time.Now(),
+		state: State{
+			Label:    cfg.Label,
+			Location: cfg.Location,
+			Group:    cfg.Group,
+			Color:    packets.LightHsbk{Brightness: 65535, Kelvin: 3500},
+		},
+	}
+
+	switch {
+	case p.Features.Multizone:
+		if cfg.Zones == 0 {
+			cfg.Zones = DefaultZones
+		}
+		if cfg.Zones < 1 || cfg.Zones > 255 {
+			return nil, fmt.Errorf("%w: %d zones", ErrInvalidConfig, cfg.Zones)
+		}
+		d.state.Zones = make([]packets.LightHsbk, cfg.Zones)
+		for i := range d.state.Zones {
+			d.state.Zones[i] = d.state.Color
+		}
+	case p.Features.Matrix:
+		d.width, d.height = cmp.Or(cfg.MatrixWidth, DefaultMatrixSize), cmp.Or(cfg.MatrixHeight, DefaultMatrixSize)
+		if d.width < 1 || d.height < 1 || d.width*d.height > 256 {
+			return nil, fmt.Errorf("%w: %dx%d matrix", ErrInvalidConfig, d.width, d.height)
+		}
+		if cfg.ChainLength == 0 {
+			cfg.ChainLength = 1
+			if p.Features.Chain {
+				cfg.ChainLength = 5
+			}
+		}
+		if cfg.ChainLength < 1 || cfg.ChainLength > maxChainLength {
+			return nil, fmt.Errorf("%w: chain length %d", ErrInvalidConfig, cfg.ChainLength)
+		}
+		d.state.Chain = make([][]packets.LightHsbk, cfg.ChainLength)
+		for i := range d.state.Chain {
+			d.state.Chain[i] = make([]packets.LightHsbk, d.width*d.height)
+		}
+	}
+
+	if cfg.Address == "" {
+		cfg.Address = "127.0.0.1:0"
+	}
+	addr, err := net.ResolveUDPAddr("udp", cfg.Address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+	if d.conn, err = net.ListenUDP("udp", addr); err != nil {
+		return nil, err
+	}
+
+	go d.serve()
+	return d, nil
+}
+
+// Addr returns the address the device listens on.
+func (d *Device) Addr() *net.UDPAddr {
+	return d.conn.LocalAddr().(*net.UDPAddr)
+}
+
+// Serial returns the device serial.
+func (d *Device) Serial() device.Serial {
+	return d.serial
+}
+
+// State returns a copy of the device state.
+func (d *Device) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state.clone()
+}
+
+// Update changes the device state with f, e.g. to simulate a change made by another application.
+// The number of zones and chain colors must not be changed.
+func (d *Device) Update(f func(*State)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	f(&d.state)
+}
+
+// Close stops the device.
+func (d *Device) Close() error {
+	err := d.conn.Close()
+	<-d.done
+	return err
+}
+
+// serve answers the messages received until the connection is closed.
+func (d *Device) serve() {
+	defer close(d.done)
+	testutil.ServeUDP(d.conn, d.answer)
+}
+
+// answer replies to a message addressed to the device or broadcast, e.g. discovery GetService.
+func (d *Device) answer(msg *protocol.Message, src *net.UDPAddr, err error) {
+	if msg.Target() != protocol.TargetBroadcast && device.Serial(msg.Target()) != d.serial {
+		return
+	}
+	var payloads []packets.Payload
+	if err != nil {
+		// Unknown message types are reported as unhandled, as devices do.
+		payloads = []packets.Payload{&packets.DeviceStateUnhandled{UnhandledType: msg.Type()}}
+	} else {
+		payloads = d.handle(msg.Payload, msg.ResponseRequired())
+	}
+
+	if msg.AckRequired() {
+		payloads = append([]packets.Payload{&packets.DeviceAcknowledgement{}}, payloads...)
+	}
+	for _, p := range payloads {
+		d.reply(src, msg, p)
+	}
+}
+
+// reply sends a response to the request.
+func (d *Device) reply(dst *net.UDPAddr, req *protocol.Message, payload packets.Payload) {
+	msg := protocol.NewMessage(payload)
+	msg.SetTarget(d.serial)
+	msg.SetSource(req.Source())
+	msg.SetSequence(req.Sequence())
+	data, err := msg.MarshalBinary()
+	if err != nil {
+		return
+	}
+	d.conn.WriteToUDP(data, dst)
+}
+
+// handle applies a received payload and returns the responses to send.
+// Set messages are only answered with the new state when a response is required.
+func (d *Device) handle(payload packets.Payload, resRequired bool) []packets.Payload {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	state := func(p ...packets.Payload) []packets.Payload {
+		if resRequired {
+			return p
+		}
+		return nil
+	}
+
+	switch p := payload.(type) {
+	case *packets.DeviceGetService:
+		return []packets.Payload{&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP, Port: uint32(d.Addr().Port)}}
+	case *packets.DeviceGetVersion:
+		return []packets.Payload{&packets.DeviceStateVersion{Vendor: 1, Product: d.productID}}
+	case *packets.DeviceGetHostFirmware:
+		return []packets.Payload{&packets.DeviceStateHostFirmware{VersionMajor: 3, VersionMinor: 70}}
+	case *packets.DeviceGetWifiInfo:
+		return []packets.Payload{&packets.DeviceStateWifiInfo{Signal: 0.0001}}
+	case *packets.DeviceGetInfo:
+		now := time.Now()
+		return []packets.Payload{&packets.DeviceStateInfo{Time: uint64(now.UnixNano()), Uptime: uint64(now.Sub(d.startedAt))}}
+	case *packets.DeviceGetWifiFirmware:
+		return []packets.Payload{&packets.DeviceStateWifiFirmware{VersionMajor: 1, VersionMinor: 1}}
+	case *packets.DeviceGetLabel:
+		return []packets.Payload{d.stateLabel()}
+	case *packets.DeviceSetLabel:
+		d.state.Label = device.ParseLabel(p.Label)
+		return state(d.stateLabel())
+	case *packets.DeviceGetLocation:
+		return []packets.Payload{d.stateLocation()}
+	case *packets.DeviceSetLocation:
+		d.state.Location, d.state.LocationID = device.ParseLabel(p.Label), p.Location
+		return state(d.stateLocation())
+	case *packets.DeviceGetGroup:
+		return []packets.Payload{d.stateGroup()}
+	case *packets.DeviceSetGroup:
+		d.state.Group, d.state.GroupID = device.ParseLabel(p.Label), p.Group
+		return state(d.stateGroup())
+	case *packets.DeviceGetPower:
+		return []packets.Payload{&packets.DeviceStatePower{Level: d.state.Power}}
+	case *packets.DeviceSetPower:
+		d.state.Power = powerLevel(p.Level)
+		return state(&packets.DeviceStatePower{Level: d.state.Power})
+	case *packets.LightGetPower:
+		return []packets.Payload{&packets.LightStatePower{Level: d.state.Power}}
+	case *packets.LightSetPower:
+		d.state.Power = powerLevel(p.Level)
+		return state(&packets.LightStatePower{Level: d.state.Power})
+	case *packets.LightGet:
+		return []packets.Payload{d.lightState()}
+	case *packets.LightSetColor:
+		d.setColor(p.Color)
+		return state(d.lightState())
+	}
+
+	if d.features.Multizone {
+		if resp, ok := d.handleMultizone(payload, resRequired); ok {
+			return resp
+		}
+	}
+	if d.features.Matrix {
+		if resp, ok := d.handleMatrix(payload, resRequired); ok {
+			return resp
+		}
+	}
+	return []packets.Payload{&packets.DeviceStateUnhandled{UnhandledType: payload.PayloadType()}}
+}
+
+// handleMultizone handles multizone messages, reporting whether the payload is a multizone message.
+func (d *Device) handleMultizone(payload packets.Payload, resRequired bool) ([]packets.Payload, bool) {
+	switch p := payload.(type) {
+	case *packets.MultiZoneExtendedGetColorZones:
+		return d.extendedZonesState(), true
+	case *packets.MultiZoneExtendedSetColorZones:
+		if d.pendingZones == nil {
+			d.pendingZones = slices.Clone(d.state.Zones)
+		}
+		if p.Apply != enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY {
+			start := min(int(p.Index), len(d.pendingZones))
+			copy(d.pendingZones[start:], p.Colors[:min(int(p.ColorsCount), len(p.Colors))])
+		}
+		if p.Apply != enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTNOAPPLY {
+			d.state.Zones, d.pendingZones = d.pendingZones, nil
+		}
+		if resRequired {
+			return d.extendedZonesState(), true
+		}
+		return nil, true
+	case *packets.MultiZoneGetColorZones:
+		return d.zonesState(int(p.StartIndex), int(p.EndIndex)), true
+	case *packets.MultiZoneSetColorZones:
+		start, end := int(p.StartIndex), min(int(p.EndIndex), len(d.state.Zones)-1)
+		for i := start; i <= end; i++ {
+			d.state.Zones[i] = p.Color
+		}
+		if resRequired {
+			return d.zonesState(start, end), true
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+// handleMatrix handles matrix messages, reporting whether the payload is a matrix message.
+// Only the visible frame buffer is emulated.
+func (d *Device) handleMatrix(payload packets.Payload, resRequired bool) ([]packets.Payload, bool) {
+	switch p := payload.(type) {
+	case *packets.TileGetDeviceChain:
+		return []packets.Payload{d.deviceChain()}, true
+	case *packets.TileGet64:
+		var resp []packets.Payload
+		for i := int(p.TileIndex); i < min(int(p.TileIndex)+int(p.Length), len(d.state.Chain)); i++ {
+			resp = append(resp, d.tileState(i, p.Rect))
+		}
+		return resp, true
+	case *packets.TileSet64:
+		if p.Rect.FbIndex != 0 {
+			return nil, true
+		}
+		for i := int(p.TileIndex); i < min(int(p.TileIndex)+int(p.Length), len(d.state.Chain)); i++ {
+			d.setTileRect(i, p.Rect, p.Colors[:])
+		}
+		return nil, true
+	}
+	return nil, false
+}
+
+func (d *Device) stateLabel() *packets.DeviceStateLabel {
+	p := &packets.DeviceStateLabel{}
+	copy(p.Label[:], d.state.Label)
+	return p
+}
+
+func (d *Device) stateLocation() *packets.DeviceStateLocation {
+	p := &packets.DeviceStateLocation{Location: d.state.LocationID}
+	copy(p.Label[:], d.state.Location)
+	return p
+}
+
+func (d *Device) stateGroup() *packets.DeviceStateGroup {
+	p := &packets.DeviceStateGroup{Group: d.state.GroupID}
+	copy(p.Label[:], d.state.Group)
+	return p
+}
+
+func (d *Device) lightState() *packets.LightState {
+	p := &packets.LightState{Color: d.state.Color, Power: d.state.Power}
+	copy(p.Label[:], d.state.Label)
+	return p
+}
+
+// setColor sets the device color, which also sets all the zones of multizone and matrix devices.
+func (d *Device) setColor(c packets.LightHsbk) {
+	d.state.Color = c
+	for i := range d.state.Zones {
+		d.state.Zones[i] = c
+	}
+	for _, colors := range d.state.Chain {
+		for i := range colors {
+			colors[i] = c
+		}
+	}
+}
+
+func (d *Device) extendedZonesState() []packets.Payload {
+	var resp []packets.Payload
+	for i := 0; i < len(d.state.Zones); i += extendedZonesPerMessage {
+		p := &packets.MultiZoneExtendedStateMultiZone{Count: uint16(len(d.state.Zones)), Index: uint16(i)}
+		p.ColorsCount = uint8(copy(p.Colors[:], d.state.Zones[i:]))
+		resp = append(resp, p)
+	}
+	return resp
+}
+
+// zonesState returns the legacy multizone states of the zones in the inclusive range [start, end].
+func (d *Device) zonesState(start, end int) []packets.Payload {
+	count := uint8(len(d.state.Zones))
+	end = min(end, len(d.state.Zones)-1)
+	if start == end {
+		return []packets.Payload{&packets.MultiZoneStateZone{Count: count, Index: uint8(start), Color: d.state.Zones[start]}}
+	}
+
+	var resp []packets.Payload
+	for i := start; i <= end; i += legacyZonesPerMessage {
+		p := &packets.MultiZoneStateMultiZone{Count: count, Index: uint8(i)}
+		copy(p.Colors[:], d.state.Zones[i:end+1])
+		resp = append(resp, p)
+	}
+	return resp
+}
+
+func (d *Device) deviceChain() *packets.TileStateDeviceChain {
+	p := &packets.TileStateDeviceChain{TileDevicesCount: uint8(len(d.state.Chain))}
+	for i := range d.state.Chain {
+		p.TileDevices[i] = packets.TileStateDevice{
+			// Devices are laid out side by side, and read as right side up without accelerometer data.
+			UserX:         float32(i),
+			Width:         uint8(d.width),
+			Height:        uint8(d.height),
+			DeviceVersion: packets.DeviceStateVersion{Vendor: 1, Product: d.productID},
+			Firmware:      packets.DeviceStateHostFirmware{VersionMajor: 3, VersionMinor: 70},
+		}
+	}
+	return p
+}
+
+// tileState returns up to 64 colors of the device at index, starting from the rect origin.
+func (d *Device) tileState(index int, rect packets.TileBufferRect) *packets.TileState64 {
+	p := &packets.TileState64{TileIndex: uint8(index), Rect: rect}
+	width := cmp.Or(int(rect.Width), d.width)
+	for i := range p.Colors {
+		x, y := int(rect.X)+i%width, int(rect.Y)+i/width
+		if x < d.width && y < d.height {
+			p.Colors[i] = d.state.Chain[index][y*d.width+x]
+		}
+	}
+	return p
+}
+
+// setTileRect sets the colors of the device at index, starting from the rect origin.
+func (d *Device) setTileRect(index int, rect packets.TileBufferRect, colors []packets.LightHsbk) {
+	width := cmp.Or(int(rect.Width), d.width)
+	for i, c := range colors {
+		x, y := int(rect.X)+i%width, int(rect.Y)+i/width
+		if x < d.width && y < d.height {
+			d.state.Chain[index][y*d.width+x] = c
+		}
+	}
+}
+
+// powerLevel normalizes a power level, as devices only report either off or on.
+func powerLevel(level uint16) uint16 {
+	if level > 0 {
+		return 65535
+	}
+	return 0
+}
@@ -0,0 +1,268 @@
+package emulator
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var red = packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+// exchange sends msg to the device and returns the payloads received in response.
+func exchange(t *testing.T, d *Device, msg *protocol.Message) []packets.Payload {
+	t.Helper()
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg.SetTarget(d.Serial())
+	msg.SetSequence(42)
+	require.NoError(t, c.Send(d.Addr(), msg))
+
+	var got []packets.Payload
+	require.NoError(t, c.Receive(100*time.Millisecond, false, func(m *protocol.Message, _ *net.UDPAddr) {
+		assert.Equal(t, [8]byte(d.Serial()), m.Target())
+		assert.Equal(t, uint8(42), m.Sequence())
+		got = append(got, m.Payload)
+	}))
+	return got
+}
+
+func TestDevice(t *testing.T) {
+	tests := map[string]struct {
+		cfg   Config
+		msg   func() *protocol.Message
+		want  []packets.Payload
+		state func(*testing.T, State)
+	}{
+		"Get version": {
+			cfg:  Config{ProductID: 38},
+			msg:  func() *protocol.Message { return protocol.NewMessage(&packets.DeviceGetVersion{}) },
+			want: []packets.Payload{&packets.DeviceStateVersion{Vendor: 1, Product: 38}},
+		},
+		"Set color with ack": {
+			msg: func() *protocol.Message {
+				m := protocol.NewMessage(&packets.LightSetColor{Color: red})
+				m.SetAckRequired(true)
+				return m
+			},
+			want: []packets.Payload{&packets.DeviceAcknowledgement{}},
+			state: func(t *testing.T, s State) {
+				assert.Equal(t, red, s.Color)
+			},
+		},
+		"Set power with response": {
+			msg: func() *protocol.Message {
+				m := protocol.NewMessage(&packets.LightSetPower{Level: 100})
+				m.SetResponseRequired(true)
+				return m
+			},
+			want: []packets.Payload{&packets.LightStatePower{Level: 65535}},
+		},
+		"Get extended color zones": {
+			cfg: Config{ProductID: 38, Zones: 90},
+			msg: func() *protocol.Message { return protocol.NewMessage(&packets.MultiZoneExtendedGetColorZones{}) },
+			want: []packets.Payload{
+				extendedZones(90, 0, 82),
+				extendedZones(90, 82, 8),
+			},
+		},
+		"Set extended color zones": {
+			cfg: Config{ProductID: 38, Zones: 4},
+			msg: func() *protocol.Message {
+				p := &packets.MultiZoneExtendedSetColorZones{
+					Apply:       enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLY,
+					Index:       2,
+					ColorsCount: 1,
+				}
+				p.Colors[0] = red
+				return protocol.NewMessage(p)
+			},
+			state: func(t *testing.T, s State) {
+				assert.Equal(t, []packets.LightHsbk{white, white, red, white}, s.Zones)
+			},
+		},
+		"Get color zones": {
+			cfg: Config{ProductID: 32, Zones: 10},
+			msg: func() *protocol.Message {
+				return protocol.NewMessage(&packets.MultiZoneGetColorZones{StartIndex: 0, EndIndex: 255})
+			},
+			want: []packets.Payload{
+				&packets.MultiZoneStateMultiZone{Count: 10, Index: 0, Colors: [8]packets.LightHsbk{white, white, white, white, white, white, white, white}},
+				&packets.MultiZoneStateMultiZone{Count: 10, Index: 8, Colors: [8]packets.LightHsbk{white, white}},
+			},
+		},
+		"Get device chain": {
+			cfg: Config{ProductID: 55, ChainLength: 2},
+			msg: func() *protocol.Message { return protocol.NewMessage(&packets.TileGetDeviceChain{}) },
+			want: []packets.Payload{func() packets.Payload {
+				p := &packets.TileStateDeviceChain{TileDevicesCount: 2}
+				for i := range 2 {
+					p.TileDevices[i] = packets.TileStateDevice{
+						UserX:         float32(i),
+						Width:         8,
+						Height:        8,
+						DeviceVersion: packets.DeviceStateVersion{Vendor: 1, Product: 55},
+						Firmware:      packets.DeviceStateHostFirmware{VersionMajor: 3, VersionMinor: 70},
+					}
+				}
+				return p
+			}()},
+		},
+		"Set and get tile": {
+			cfg: Config{ProductID: 55, ChainLength: 1},
+			msg: func() *protocol.Message {
+				p := &packets.TileSet64{Length: 1, Rect: packets.TileBufferRect{X: 1, Y: 1, Width: 2}}
+				p.Colors[0], p.Colors[3] = red, red
+				return protocol.NewMessage(p)
+			},
+			state: func(t *testing.T, s State) {
+				want := make([]packets.LightHsbk, 64)
+				want[9], want[18] = red, red
+				assert.Equal(t, want, s.Chain[0])
+			},
+		},
+		"Unhandled": {
+			msg:  func() *protocol.Message { return protocol.NewMessage(&packets.TileGetDeviceChain{}) },
+			want: []packets.Payload{&packets.DeviceStateUnhandled{UnhandledType: uint16((&packets.TileGetDeviceChain{}).PayloadType())}},
+		},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			d, err := New(tt.cfg)
+			require.NoError(t, err)
+			defer d.Close()
+
+			assert.Equal(t, tt.want, exchange(t, d, tt.msg()))
+			if tt.state != nil {
+				tt.state(t, d.State())
+			}
+		})
+	}
+}
+
+var white = packets.LightHsbk{Brightness: 65535, Kelvin: 3500}
+
+func extendedZones(count, index, n int) *packets.MultiZoneExtendedStateMultiZone {
+	p := &packets.MultiZoneExtendedStateMultiZone{Count: uint16(count), Index: uint16(index), ColorsCount: uint8(n)}
+	for i := range n {
+		p.Colors[i] = white
+	}
+	return p
+}
+
+func TestDevice_GetService(t *testing.T) {
+	d, err := New(Config{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	assert.Equal(t, []packets.Payload{
+		&packets.DeviceStateService{Service: enums.DeviceServiceDEVICESERVICEUDP, Port: uint32(d.Addr().Port)},
+	}, exchange(t, d, protocol.NewMessage(&packets.DeviceGetService{})))
+}
+
+func TestDevice_BroadcastDiscovery(t *testing.T) {
+	bulb, err := New(Config{Label: "Desk"})
+	require.NoError(t, err)
+	defer bulb.Close()
+
+	c, err := client.NewClient(&client.Config{BroadcastAddrs: []*net.UDPAddr{bulb.Addr()}})
+	require.NoError(t, err)
+	ctrl, err := controller.New(controller.WithClient(c), controller.WithDiscoveryPeriod(50*time.Millisecond))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	require.Eventually(t, func() bool {
+		d, ok := ctrl.GetDevice(bulb.Serial())
+		return ok && d.Label == "Desk"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestNew(t *testing.T) {
+	tests := map[string]Config{
+		"Unknown product":      {ProductID: 9999},
+		"Invalid zones":        {ProductID: 38, Zones: 300},
+		"Invalid matrix":       {ProductID: 55, MatrixWidth: 32, MatrixHeight: 32},
+		"Invalid chain length": {ProductID: 55, ChainLength: 17},
+		"Invalid address":      {Address: "nope"},
+	}
+
+	for name, cfg := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(cfg)
+			assert.ErrorIs(t, err, ErrInvalidConfig)
+		})
+	}
+}
+
+func TestDevice_IgnoresOtherTargets(t *testing.T) {
+	d, err := New(Config{})
+	require.NoError(t, err)
+	defer d.Close()
+
+	c, err := client.NewClient(nil)
+	require.NoError(t, err)
+	defer c.Close()
+
+	msg := protocol.NewMessage(&packets.LightGet{})
+	msg.SetTarget([8]byte{1})
+	require.NoError(t, c.Send(d.Addr(), msg))
+	require.NoError(t, c.Receive(50*time.Millisecond, false, func(*protocol.Message, *net.UDPAddr) {
+		t.Error("Unexpected response")
+	}))
+}
+
+func TestController(t *testing.T) {
+	bulb, err := New(Config{Label: "Desk"})
+	require.NoError(t, err)
+	defer bulb.Close()
+	tile, err := New(Config{ProductID: 55, ChainLength: 2})
+	require.NoError(t, err)
+	defer tile.Close()
+
+	ctrl, err := controller.New(
+		controller.WithDiscoveryPeriod(time.Hour),
+		controller.WithHFStateRefreshPeriod(50*time.Millisecond),
+		controller.WithStaticDevices(bulb.Addr().String(), tile.Addr().String()),
+	)
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	require.Eventually(t, func() bool {
+		devices := ctrl.GetDevices()
+		return len(devices) == 2 && devices[0].Profiled() && devices[1].MatrixProperties.ChainLength == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	devices := ctrl.GetDevices()
+	labels := []string{devices[0].Label, devices[1].Label}
+	assert.ElementsMatch(t, []string{"Desk", "LIFX Tile"}, labels)
+
+	require.NoError(t, ctrl.SetPower(bulb.Serial(), true, 0))
+	color := device.NewColor(red)
+	require.NoError(t, ctrl.SetColor(bulb.Serial(), color, 0))
+	assert.Eventually(t, func() bool {
+		s := bulb.State()
+		return s.Power == 65535 && s.Color == color.ToDeviceColor()
+	}, time.Second, 10*time.Millisecond)
+
+	// External changes are reported by state polling.
+	bulb.Update(func(s *State) { s.Power = 0 })
+	assert.Eventually(t, func() bool {
+		for _, d := range ctrl.GetDevices() {
+			if d.Serial == bulb.Serial() {
+				return !d.PoweredOn
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond)
+}
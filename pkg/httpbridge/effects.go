@@ -0,0 +1,128 @@
+package httpbridge
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/effects"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/effects/adapters"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// effectJSON describes a registered effect.
+type effectJSON struct {
+	ID          string      `json:"id"`
+	Label       string      `json:"label"`
+	Description string      `json:"description"`
+	DeviceKinds []string    `json:"device_kinds"`
+	Params      []paramJSON `json:"params"`
+}
+
+// paramJSON describes an effect parameter.
+type paramJSON struct {
+	Key      string                `json:"key"`
+	Label    string                `json:"label"`
+	Kind     effects.ParamKind     `json:"kind"`
+	Default  any                   `json:"default,omitempty"`
+	Min      *float64              `json:"min,omitempty"`
+	Max      *float64              `json:"max,omitempty"`
+	Step     *float64              `json:"step,omitempty"`
+	Choices  []effects.ParamChoice `json:"choices,omitempty"`
+	Required bool                  `json:"required,omitempty"`
+}
+
+// effectStatusJSON is the status of the last effect started on a device.
+type effectStatusJSON struct {
+	Name      string    `json:"name"`
+	State     string    `json:"state"`
+	StartedAt time.Time `json:"started_at"`
+	Error     string    `json:"error,omitempty"`
+}
+
+func newEffectStatusJSON(s matrix.EffectStatus) effectStatusJSON {
+	j := effectStatusJSON{Name: s.Name, State: s.State.String(), StartedAt: s.StartedAt}
+	if s.Err != nil {
+		j.Error = s.Err.Error()
+	}
+	return j
+}
+
+func (b *Bridge) listEffects(w http.ResponseWriter, _ *http.Request) {
+	defs := []effectJSON{}
+	for _, def := range effects.Definitions() {
+		j := effectJSON{
+			ID:          string(def.ID),
+			Label:       def.Label,
+			Description: def.Description,
+			DeviceKinds: []string{},
+			Params:      []paramJSON{},
+		}
+		for _, kind := range def.DeviceKinds {
+			j.DeviceKinds = append(j.DeviceKinds, kind.String())
+		}
+		for _, p := range def.Params {
+			j.Params = append(j.Params, paramJSON(p))
+		}
+		defs = append(defs, j)
+	}
+	writeJSON(w, http.StatusOK, defs)
+}
+
+func (b *Bridge) getEffect(w http.ResponseWriter, r *http.Request) {
+	d, err := b.device(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status, ok := b.ctrl.Effects().Status(d.Serial)
+	if !ok {
+		writeError(w, fmt.Errorf("%w: no effect started on device %s", errNotFound, d.Serial))
+		return
+	}
+	writeJSON(w, http.StatusOK, newEffectStatusJSON(status))
+}
+
+// startEffect starts a registered effect on a device, replacing the running one.
+// The effect runs until stopped, or for the requested duration.
+func (b *Bridge) startEffect(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID       effects.EffectID `json:"id"`
+		Params   map[string]any   `json:"params"`
+		Duration duration         `json:"duration"`
+		Step     duration         `json:"step"`
+	}
+	d, err := b.device(r)
+	if err == nil {
+		err = decodeBody(r, &req)
+	}
+	var effect effects.Effect
+	if err == nil {
+		effect, err = effects.New(effects.Config{ID: req.ID, Params: req.Params}, effects.CapabilitiesFromDevice(d))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	run := effects.RunConfig{Effect: effect, Duration: time.Duration(req.Duration), Step: time.Duration(req.Step)}
+	b.ctrl.Effects().Start(d.Serial, string(req.ID), func(ctx context.Context, send protocol.SendFunc) error {
+		return adapters.RunEffects(ctx, d, send, run)
+	})
+	status, _ := b.ctrl.Effects().Status(d.Serial)
+	writeJSON(w, http.StatusAccepted, newEffectStatusJSON(status))
+}
+
+func (b *Bridge) stopEffect(w http.ResponseWriter, r *http.Request) {
+	d, err := b.device(r)
+	if err == nil {
+		err = b.ctrl.Effects().Stop(d.Serial)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,69 @@
+package httpbridge
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffects(t *testing.T) {
+	b, bulb := newTestBridge(t)
+	path := "/devices/" + bulb.Serial().String() + "/effect"
+
+	t.Run("Lists effects", func(t *testing.T) {
+		var defs []effectJSON
+		rec := do(t, b, "GET", "/effects", "", &defs)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.NotEmpty(t, defs)
+
+		var solid effectJSON
+		for _, def := range defs {
+			if def.ID == "solid" {
+				solid = def
+			}
+		}
+		assert.Equal(t, "Solid", solid.Label)
+		assert.Contains(t, solid.DeviceKinds, device.LightTypeSingleZone.String())
+		require.Len(t, solid.Params, 1)
+		assert.Equal(t, "color", solid.Params[0].Key)
+	})
+
+	t.Run("Runs an effect", func(t *testing.T) {
+		rec := do(t, b, "GET", path, "", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+
+		body := `{"id": "solid", "params": {"color": {"hue": 240, "saturation": 100, "brightness": 80, "kelvin": 3500}}}`
+		var status effectStatusJSON
+		rec = do(t, b, "PUT", path, body, &status)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+		assert.Equal(t, "solid", status.Name)
+		assert.Equal(t, "running", status.State)
+		do(t, b, "GET", path, "", &status)
+		assert.Equal(t, "running", status.State)
+
+		rec = do(t, b, "DELETE", path, "", nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		do(t, b, "GET", path, "", &status)
+		assert.Equal(t, "stopped", status.State)
+		rec = do(t, b, "DELETE", path, "", nil)
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+
+	errorCases := map[string]struct {
+		body       string
+		wantStatus int
+	}{
+		"Unknown effect":     {`{"id": "fireworks"}`, http.StatusNotFound},
+		"Unsupported device": {`{"id": "waterfall"}`, http.StatusUnprocessableEntity},
+		"Invalid params":     {`{"id": "solid", "params": {"color": "blue"}}`, http.StatusBadRequest},
+	}
+	for name, tc := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			rec := do(t, b, "PUT", path, tc.body, nil)
+			assert.Equal(t, tc.wantStatus, rec.Code, rec.Body.String())
+		})
+	}
+}
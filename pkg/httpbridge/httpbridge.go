@@ -0,0 +1,243 @@
+// Package httpbridge exposes a Controller through a JSON HTTP API, so that applications not
+// written in Go can list and control devices, e.g. over localhost:
+//
+//	b := httpbridge.New(ctrl, httpbridge.Config{})
+//	err := http.ListenAndServe("localhost:8080", b)
+//
+// Devices are identified by their serial in hexadecimal, e.g. d073d5000001, and durations are
+// strings such as "1.5s". Devices are encoded by device.Device.MarshalJSON. The API serves:
+//
+//	GET    /devices                   list devices, optionally filtered by ?group= and ?location=
+//	GET    /devices/{serial}          read the state of a device
+//	PUT    /devices/{serial}/power    {"on": true, "duration": "1s"}
+//	PUT    /devices/{serial}/color    {"hue": 120, "saturation": 100, "brightness": 50, "kelvin": 3500, "duration": "1s"}
+//	GET    /devices/{serial}/effect   read the status of the last effect started on a device
+//	PUT    /devices/{serial}/effect   {"id": "waterfall", "params": {"cycles": 2}, "duration": "30s", "step": "100ms"}
+//	DELETE /devices/{serial}/effect   stop the effect running on a device
+//	GET    /effects                   list the effects, see effects.Definitions
+//	GET    /scenes                    list the scene names
+//	PUT    /scenes/{name}             capture the lights, optionally filtered by ?group= and ?location=
+//	POST   /scenes/{name}             apply a scene, {"duration": "1s"}
+//	DELETE /scenes/{name}             delete a scene
+//
+// Errors are returned as {"error": "..."} with a matching status code.
+// The API is neither authenticated nor encrypted: only expose it on trusted networks.
+package httpbridge
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/effects"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/matrix"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+)
+
+// maxBodySize is the size of the largest request body accepted.
+const maxBodySize = 1 << 20
+
+var (
+	// errNotFound is returned when a device, effect or scene does not exist.
+	errNotFound = errors.New("not found")
+	// errBadRequest is returned when a request is malformed.
+	errBadRequest = errors.New("bad request")
+)
+
+// Bridge is an http.Handler serving the API of a Controller.
+type Bridge struct {
+	ctrl *controller.Controller
+	mux  *http.ServeMux
+
+	mu     sync.RWMutex
+	scenes map[string]controller.Scene
+}
+
+// Config contains optional user-configurable fields.
+type Config struct {
+	// Scenes are the scenes initially available, by name.
+	// Scenes captured through the API are only kept in memory.
+	Scenes map[string]controller.Scene
+}
+
+// New returns a Bridge serving the API of ctrl.
+func New(ctrl *controller.Controller, cfg Config) *Bridge {
+	b := &Bridge{
+		ctrl:   ctrl,
+		mux:    http.NewServeMux(),
+		scenes: make(map[string]controller.Scene, len(cfg.Scenes)),
+	}
+	maps.Copy(b.scenes, cfg.Scenes)
+
+	b.mux.HandleFunc("GET /devices", b.listDevices)
+	b.mux.HandleFunc("GET /devices/{serial}", b.getDevice)
+	b.mux.HandleFunc("PUT /devices/{serial}/power", b.setPower)
+	b.mux.HandleFunc("PUT /devices/{serial}/color", b.setColor)
+	b.mux.HandleFunc("GET /devices/{serial}/effect", b.getEffect)
+	b.mux.HandleFunc("PUT /devices/{serial}/effect", b.startEffect)
+	b.mux.HandleFunc("DELETE /devices/{serial}/effect", b.stopEffect)
+	b.mux.HandleFunc("GET /effects", b.listEffects)
+	b.mux.HandleFunc("GET /scenes", b.listScenes)
+	b.mux.HandleFunc("PUT /scenes/{name}", b.captureScene)
+	b.mux.HandleFunc("POST /scenes/{name}", b.applyScene)
+	b.mux.HandleFunc("DELETE /scenes/{name}", b.deleteScene)
+	return b
+}
+
+// ServeHTTP implements http.Handler.
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// colorJSON is a color in the encoding of device.Device.MarshalJSON.
+type colorJSON struct {
+	Hue        float64 `json:"hue"`
+	Saturation float64 `json:"saturation"`
+	Brightness float64 `json:"brightness"`
+	Kelvin     uint16  `json:"kelvin"`
+}
+
+func (b *Bridge) listDevices(w http.ResponseWriter, r *http.Request) {
+	devices := []device.Device{}
+	devices = append(devices, b.ctrl.FindDevices(queryFilters(r)...)...)
+	writeJSON(w, http.StatusOK, devices)
+}
+
+func (b *Bridge) getDevice(w http.ResponseWriter, r *http.Request) {
+	d, err := b.device(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, d)
+}
+
+func (b *Bridge) setPower(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		On       bool     `json:"on"`
+		Duration duration `json:"duration"`
+	}
+	d, err := b.device(r)
+	if err == nil {
+		err = decodeBody(r, &req)
+	}
+	if err == nil {
+		err = b.ctrl.SetPower(d.Serial, req.On, time.Duration(req.Duration))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Bridge) setColor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		colorJSON
+		Duration duration `json:"duration"`
+	}
+	d, err := b.device(r)
+	if err == nil {
+		err = decodeBody(r, &req)
+	}
+	if err == nil {
+		color := device.Color{
+			Hue:        req.Hue,
+			Saturation: req.Saturation,
+			Brightness: req.Brightness,
+			Kelvin:     req.Kelvin,
+		}
+		err = b.ctrl.SetColor(d.Serial, color, time.Duration(req.Duration))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// device returns the device identified by the serial of the request path.
+func (b *Bridge) device(r *http.Request) (device.Device, error) {
+	serial, err := device.SerialFromHex(r.PathValue("serial"))
+	if err != nil {
+		return device.Device{}, fmt.Errorf("%w: invalid serial: %w", errBadRequest, err)
+	}
+	d, ok := b.ctrl.GetDevice(serial)
+	if !ok {
+		return device.Device{}, fmt.Errorf("%w: device %s", errNotFound, serial)
+	}
+	return d, nil
+}
+
+// queryFilters returns the device filters of the group and location query parameters.
+func queryFilters(r *http.Request) []controller.DeviceFilter {
+	var filters []controller.DeviceFilter
+	if group := r.URL.Query().Get("group"); group != "" {
+		filters = append(filters, controller.ByGroup(group))
+	}
+	if location := r.URL.Query().Get("location"); location != "" {
+		filters = append(filters, controller.ByLocation(location))
+	}
+	return filters
+}
+
+// duration is a time.Duration encoded as a string in JSON, e.g. "1.5s".
+type duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	if v < 0 {
+		return fmt.Errorf("negative duration %s", s)
+	}
+	*d = duration(v)
+	return nil
+}
+
+// decodeBody decodes the JSON body of a request into v. An empty body leaves v unchanged.
+func decodeBody(r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxBodySize))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%w: %w", errBadRequest, err)
+	}
+	return nil
+}
+
+// writeJSON writes v as the JSON response body with the given status.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes err as the JSON response body, with the status matching its cause.
+func writeError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errNotFound), errors.Is(err, effects.ErrUnknownEffect), errors.Is(err, matrix.ErrNoEffect):
+		status = http.StatusNotFound
+	case errors.Is(err, errBadRequest), errors.Is(err, effects.ErrInvalidConfig):
+		status = http.StatusBadRequest
+	case errors.Is(err, controller.ErrNotLight), errors.Is(err, messages.ErrUnsupported),
+		errors.Is(err, effects.ErrUnsupportedDeviceKind):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, controller.ErrSendTimeout):
+		status = http.StatusGatewayTimeout
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
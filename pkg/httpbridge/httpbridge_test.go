@@ -0,0 +1,127 @@
+package httpbridge
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevices(t *testing.T) {
+	b, bulb := newTestBridge(t)
+	serial := bulb.Serial().String()
+
+	t.Run("Lists devices", func(t *testing.T) {
+		var devices []deviceResponse
+		rec := do(t, b, "GET", "/devices", "", &devices)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, devices, 1)
+		assert.Equal(t, serial, devices[0].Serial)
+		assert.Equal(t, "Desk", devices[0].Label)
+		assert.Equal(t, "Office", devices[0].Group)
+		assert.Equal(t, "light", devices[0].Type)
+		assert.NotNil(t, devices[0].Color)
+
+		do(t, b, "GET", "/devices?group=Office", "", &devices)
+		assert.Len(t, devices, 1)
+		do(t, b, "GET", "/devices?group=Kitchen", "", &devices)
+		assert.Empty(t, devices)
+	})
+
+	t.Run("Gets a device", func(t *testing.T) {
+		var d deviceResponse
+		rec := do(t, b, "GET", "/devices/"+serial, "", &d)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "Desk", d.Label)
+		assert.Contains(t, d.Supports, "color")
+	})
+
+	t.Run("Sets power", func(t *testing.T) {
+		rec := do(t, b, "PUT", "/devices/"+serial+"/power", `{"on": true}`, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Eventually(t, func() bool { return bulb.State().Power == 65535 }, time.Second, 10*time.Millisecond)
+
+		var d deviceResponse
+		do(t, b, "GET", "/devices/"+serial, "", &d)
+		assert.True(t, d.PoweredOn)
+	})
+
+	t.Run("Sets color", func(t *testing.T) {
+		body := `{"hue": 120, "saturation": 100, "brightness": 50, "kelvin": 3500, "duration": "10ms"}`
+		rec := do(t, b, "PUT", "/devices/"+serial+"/color", body, nil)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		want := device.Color{Hue: 120, Saturation: 100, Brightness: 50, Kelvin: 3500}.ToDeviceColor()
+		assert.Eventually(t, func() bool { return bulb.State().Color == want }, time.Second, 10*time.Millisecond)
+	})
+
+	errorCases := map[string]struct {
+		method, path, body string
+		wantStatus         int
+	}{
+		"Invalid serial":   {"GET", "/devices/lamp", "", http.StatusBadRequest},
+		"Unknown device":   {"GET", "/devices/d073d5ffffff", "", http.StatusNotFound},
+		"Malformed body":   {"PUT", "/devices/" + serial + "/power", `{"on": "yes"}`, http.StatusBadRequest},
+		"Unknown field":    {"PUT", "/devices/" + serial + "/power", `{"power": true}`, http.StatusBadRequest},
+		"Invalid duration": {"PUT", "/devices/" + serial + "/power", `{"on": true, "duration": "soon"}`, http.StatusBadRequest},
+		"Unknown route":    {"GET", "/lights", "", http.StatusNotFound},
+	}
+	for name, tc := range errorCases {
+		t.Run(name, func(t *testing.T) {
+			rec := do(t, b, tc.method, tc.path, tc.body, nil)
+			assert.Equal(t, tc.wantStatus, rec.Code)
+			if strings.HasPrefix(tc.path, "/devices") {
+				var resp map[string]string
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+				assert.NotEmpty(t, resp["error"])
+			}
+		})
+	}
+}
+
+// newTestBridge returns a Bridge whose Controller has a session for an emulated bulb,
+// once the bulb is profiled.
+// deviceResponse holds the fields of a device encoded by device.Device.MarshalJSON read by the tests.
+type deviceResponse struct {
+	Serial    string     `json:"serial"`
+	Label     string     `json:"label"`
+	Group     string     `json:"group"`
+	Type      string     `json:"type"`
+	Supports  []string   `json:"supports"`
+	PoweredOn bool       `json:"powered_on"`
+	Color     *colorJSON `json:"color"`
+}
+
+func newTestBridge(t *testing.T) (*Bridge, *emulator.Device) {
+	bulb, err := emulator.New(emulator.Config{Label: "Desk", Group: "Office"})
+	require.NoError(t, err)
+	t.Cleanup(func() { bulb.Close() })
+
+	ctrl, err := controller.New(controller.WithoutDiscovery(), controller.WithStaticDevices(bulb.Addr().String()))
+	require.NoError(t, err)
+	t.Cleanup(func() { ctrl.Close() })
+
+	require.Eventually(t, func() bool {
+		d, ok := ctrl.GetDevice(bulb.Serial())
+		return ok && d.Profiled() && d.Label != ""
+	}, 2*time.Second, 10*time.Millisecond)
+	return New(ctrl, Config{}), bulb
+}
+
+// do serves a request with the given JSON body and decodes the response body into v, if not nil.
+func do(t *testing.T, b *Bridge, method, path, body string, v any) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	b.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+	if v != nil {
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), v), rec.Body.String())
+	}
+	return rec
+}
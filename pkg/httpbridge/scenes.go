@@ -0,0 +1,93 @@
+package httpbridge
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+)
+
+// sceneJSON summarizes a scene.
+type sceneJSON struct {
+	Name    string   `json:"name"`
+	Devices []string `json:"devices"`
+}
+
+func newSceneJSON(name string, scene controller.Scene) sceneJSON {
+	j := sceneJSON{Name: name, Devices: []string{}}
+	for _, d := range scene {
+		j.Devices = append(j.Devices, d.Serial.String())
+	}
+	return j
+}
+
+func (b *Bridge) listScenes(w http.ResponseWriter, _ *http.Request) {
+	b.mu.RLock()
+	scenes := []sceneJSON{}
+	for name, scene := range b.scenes {
+		scenes = append(scenes, newSceneJSON(name, scene))
+	}
+	b.mu.RUnlock()
+
+	slices.SortFunc(scenes, func(a, b sceneJSON) int {
+		return cmp.Compare(a.Name, b.Name)
+	})
+	writeJSON(w, http.StatusOK, scenes)
+}
+
+// captureScene saves the current state of the lights as a scene, replacing any scene
+// with the same name.
+func (b *Bridge) captureScene(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	scene := b.ctrl.CaptureScene(queryFilters(r)...)
+
+	b.mu.Lock()
+	b.scenes[name] = scene
+	b.mu.Unlock()
+	writeJSON(w, http.StatusOK, newSceneJSON(name, scene))
+}
+
+func (b *Bridge) applyScene(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Duration duration `json:"duration"`
+	}
+	scene, err := b.scene(r.PathValue("name"))
+	if err == nil {
+		err = decodeBody(r, &req)
+	}
+	if err == nil {
+		err = b.ctrl.ApplyScene(scene, time.Duration(req.Duration))
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (b *Bridge) deleteScene(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	if _, err := b.scene(name); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	b.mu.Lock()
+	delete(b.scenes, name)
+	b.mu.Unlock()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// scene returns the scene with the given name.
+func (b *Bridge) scene(name string) (controller.Scene, error) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	scene, ok := b.scenes[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: scene %q", errNotFound, name)
+	}
+	return scene, nil
+}
@@ -0,0 +1,56 @@
+package httpbridge
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScenes(t *testing.T) {
+	b, bulb := newTestBridge(t)
+	serial := bulb.Serial().String()
+	blue := packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 32768, Kelvin: 3500}
+
+	// Capture the bulb while on and blue, then turn it off.
+	rec := do(t, b, "PUT", "/devices/"+serial+"/color", `{"hue": 240, "saturation": 100, "brightness": 50, "kelvin": 3500}`, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, b, "PUT", "/devices/"+serial+"/power", `{"on": true}`, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	var scene sceneJSON
+	rec = do(t, b, "PUT", "/scenes/evening?group=Office", "", &scene)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, sceneJSON{Name: "evening", Devices: []string{serial}}, scene)
+
+	rec = do(t, b, "PUT", "/devices/"+serial+"/power", `{"on": false}`, nil)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.Eventually(t, func() bool { return bulb.State().Power == 0 }, time.Second, 10*time.Millisecond)
+
+	var scenes []sceneJSON
+	do(t, b, "GET", "/scenes", "", &scenes)
+	assert.Equal(t, []sceneJSON{scene}, scenes)
+
+	rec = do(t, b, "POST", "/scenes/evening", `{"duration": "10ms"}`, nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Eventually(t, func() bool {
+		s := bulb.State()
+		return s.Power == 65535 && closeColor(s.Color, blue)
+	}, time.Second, 10*time.Millisecond)
+
+	rec = do(t, b, "DELETE", "/scenes/evening", "", nil)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	rec = do(t, b, "POST", "/scenes/evening", "", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	rec = do(t, b, "DELETE", "/scenes/evening", "", nil)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+// closeColor reports whether the colors differ by rounding errors only.
+func closeColor(a, b packets.LightHsbk) bool {
+	near := func(x, y uint16) bool { return max(x, y)-min(x, y) <= 2 }
+	return near(a.Hue, b.Hue) && near(a.Saturation, b.Saturation) && near(a.Brightness, b.Brightness) && a.Kelvin == b.Kelvin
+}
@@ -0,0 +1,265 @@
+// Package inventory renders a snapshot of the devices known to a Controller
+// as text, markdown or JSON, grouped by location and group.
+package inventory
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// ErrUnknownFormat is returned when parsing an unsupported format name.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// Format is the output format of an inventory.
+type Format int
+
+const (
+	// FormatText renders aligned plain text.
+	FormatText Format = iota
+	// FormatMarkdown renders a markdown table per group.
+	FormatMarkdown
+	// FormatJSON renders the groups as a JSON array.
+	FormatJSON
+)
+
+// String converts a Format into a string.
+func (f Format) String() string {
+	switch f {
+	case FormatText:
+		return "text"
+	case FormatMarkdown:
+		return "markdown"
+	case FormatJSON:
+		return "json"
+	}
+	return ""
+}
+
+// ParseFormat parses a format name as returned by Format.String.
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(s) {
+	case "text", "":
+		return FormatText, nil
+	case "markdown", "md":
+		return FormatMarkdown, nil
+	case "json":
+		return FormatJSON, nil
+	}
+	return 0, fmt.Errorf("%w: %q", ErrUnknownFormat, s)
+}
+
+// Capability names as reported in an Entry.
+const (
+	CapabilityColor     = "color"
+	CapabilityMultizone = "multizone"
+	CapabilityMatrix    = "matrix"
+	CapabilityRelays    = "relays"
+	CapabilityButtons   = "buttons"
+	CapabilityHev       = "hev"
+)
+
+var capabilityIcons = map[string]string{
+	CapabilityColor:     "🎨",
+	CapabilityMultizone: "📏",
+	CapabilityMatrix:    "🔲",
+	CapabilityRelays:    "🔌",
+	CapabilityButtons:   "🔘",
+	CapabilityHev:       "🧼",
+}
+
+// Entry is the summary of a single device.
+type Entry struct {
+	Label        string   `json:"label"`
+	Serial       string   `json:"serial"`
+	Address      string   `json:"address"`
+	Product      string   `json:"product"`
+	Firmware     string   `json:"firmware"`
+	Signal       string   `json:"signal"`
+	PoweredOn    bool     `json:"powered_on"`
+	Capabilities []string `json:"capabilities"`
+}
+
+// Group is a set of devices sharing the same location and group.
+type Group struct {
+	Location string  `json:"location"`
+	Group    string  `json:"group"`
+	Devices  []Entry `json:"devices"`
+}
+
+// Name returns the group display name.
+func (g Group) Name() string {
+	return orDash(g.Location) + " / " + orDash(g.Group)
+}
+
+// NewEntry returns the summary of a device.
+func NewEntry(d device.Device) Entry {
+	var addr, signal string
+	if d.Address != nil {
+		addr = d.Address.IP.String()
+	}
+	// A zero value means the signal has not been reported yet.
+	if d.WifiRSSI != 0 {
+		signal = d.WifiRSSI.String()
+	}
+	return Entry{
+		Label:        d.Label,
+		Serial:       d.Serial.String(),
+		Address:      addr,
+		Product:      d.RegistryName,
+		Firmware:     d.FirmwareVersion,
+		Signal:       signal,
+		PoweredOn:    d.PoweredOn,
+		Capabilities: Capabilities(d),
+	}
+}
+
+// Capabilities returns the names of the capabilities of a device.
+func Capabilities(d device.Device) []string {
+	caps := []string{}
+	if d.ColorProperties.HasColor {
+		caps = append(caps, CapabilityColor)
+	}
+	switch d.LightType {
+	case device.LightTypeMultiZone:
+		caps = append(caps, CapabilityMultizone)
+	case device.LightTypeMatrix:
+		caps = append(caps, CapabilityMatrix)
+	}
+	if d.Type == device.DeviceTypeSwitch {
+		caps = append(caps, CapabilityRelays)
+	}
+	if d.Type != device.DeviceTypeLight {
+		caps = append(caps, CapabilityButtons)
+	}
+	if d.HevProperties.Supported {
+		caps = append(caps, CapabilityHev)
+	}
+	return caps
+}
+
+// Groups groups devices by location and group, sorted by name.
+// Devices within a group are sorted by label and serial.
+func Groups(devices []device.Device) []Group {
+	devices = slices.Clone(devices)
+	device.SortDevices(devices)
+
+	var groups []Group
+	for _, d := range devices {
+		i := slices.IndexFunc(groups, func(g Group) bool {
+			return g.Location == d.Location && g.Group == d.Group
+		})
+		if i < 0 {
+			groups = append(groups, Group{Location: d.Location, Group: d.Group})
+			i = len(groups) - 1
+		}
+		groups[i].Devices = append(groups[i].Devices, NewEntry(d))
+	}
+
+	slices.SortFunc(groups, func(a, b Group) int {
+		if n := strings.Compare(a.Location, b.Location); n != 0 {
+			return n
+		}
+		return strings.Compare(a.Group, b.Group)
+	})
+	return groups
+}
+
+// Write renders the devices inventory to w in the given format.
+func Write(w io.Writer, devices []device.Device, f Format) error {
+	groups := Groups(devices)
+
+	switch f {
+	case FormatText:
+		return writeText(w, groups)
+	case FormatMarkdown:
+		return writeMarkdown(w, groups)
+	case FormatJSON:
+		if groups == nil {
+			groups = []Group{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(groups)
+	}
+	return fmt.Errorf("%w: %d", ErrUnknownFormat, f)
+}
+
+func writeText(w io.Writer, groups []Group) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for i, g := range groups {
+		if i > 0 {
+			if _, err := fmt.Fprintln(tw); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintln(tw, g.Name()); err != nil {
+			return err
+		}
+		for _, e := range g.Devices {
+			if _, err := fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+				power(e.PoweredOn), orDash(e.Label), e.Serial, orDash(e.Address), orDash(e.Product),
+				orDash(e.Firmware), orDash(e.Signal), icons(e.Capabilities)); err != nil {
+				return err
+			}
+		}
+	}
+	return tw.Flush()
+}
+
+func writeMarkdown(w io.Writer, groups []Group) error {
+	for i, g := range groups {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "### %s\n\n", g.Name()); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprint(w, "| Power | Label | Serial | Address | Product | Firmware | Signal | Capabilities |\n"+
+			"|---|---|---|---|---|---|---|---|\n"); err != nil {
+			return err
+		}
+		for _, e := range g.Devices {
+			if _, err := fmt.Fprintf(w, "| %s | %s | %s | %s | %s | %s | %s | %s |\n",
+				power(e.PoweredOn), escapeMarkdown(orDash(e.Label)), e.Serial, orDash(e.Address),
+				orDash(e.Product), orDash(e.Firmware), orDash(e.Signal), icons(e.Capabilities)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func icons(caps []string) string {
+	s := make([]string, len(caps))
+	for i, c := range caps {
+		s[i] = capabilityIcons[c]
+	}
+	return strings.Join(s, "")
+}
+
+func power(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func escapeMarkdown(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
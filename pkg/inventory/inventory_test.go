@@ -0,0 +1,122 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testDevices = []device.Device{
+	{
+		Address: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 11)}, Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 2},
+		Label: "Strip", RegistryName: "LIFX Z", FirmwareVersion: "3.70", Location: "Home", Group: "Lounge",
+		LightType: device.LightTypeMultiZone, ColorProperties: device.ColorProperties{HasColor: true}, WifiRSSI: -45,
+	},
+	{
+		Address: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 10)}, Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 1},
+		Label: "Lamp", RegistryName: "LIFX Clean", FirmwareVersion: "3.90", Location: "Home", Group: "Bedroom",
+		PoweredOn: true, HevProperties: device.HevProperties{Supported: true}, WifiRSSI: -65,
+	},
+	{
+		Address: &net.UDPAddr{IP: net.IPv4(192, 168, 0, 12)}, Serial: device.Serial{0xd0, 0x73, 0xd5, 0, 0, 3},
+		Label: "Switch", RegistryName: "LIFX Switch", Location: "Home", Group: "Bedroom",
+		Type: device.DeviceTypeSwitch,
+	},
+}
+
+func TestParseFormat(t *testing.T) {
+	testCases := map[string]struct {
+		input   string
+		want    Format
+		wantErr error
+	}{
+		"default":  {input: "", want: FormatText},
+		"text":     {input: "text", want: FormatText},
+		"markdown": {input: "MD", want: FormatMarkdown},
+		"json":     {input: "json", want: FormatJSON},
+		"unknown":  {input: "yaml", wantErr: ErrUnknownFormat},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseFormat(tc.input)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestGroups(t *testing.T) {
+	groups := Groups(testDevices)
+	require.Len(t, groups, 2)
+
+	assert.Equal(t, "Home / Bedroom", groups[0].Name())
+	require.Len(t, groups[0].Devices, 2)
+	assert.Equal(t, "Lamp", groups[0].Devices[0].Label)
+	assert.Equal(t, []string{CapabilityHev}, groups[0].Devices[0].Capabilities)
+	assert.Equal(t, "Switch", groups[0].Devices[1].Label)
+	assert.Equal(t, []string{CapabilityRelays, CapabilityButtons}, groups[0].Devices[1].Capabilities)
+
+	assert.Equal(t, "Home / Lounge", groups[1].Name())
+	assert.Equal(t, Entry{
+		Label: "Strip", Serial: "d073d5000002", Address: "192.168.0.11", Product: "LIFX Z", Firmware: "3.70",
+		Signal: device.SignalExcellent, Capabilities: []string{CapabilityColor, CapabilityMultizone},
+	}, groups[1].Devices[0])
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("text", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, testDevices, FormatText))
+		want := "Home / Bedroom\n" +
+			"  on   Lamp    d073d5000001  192.168.0.10  LIFX Clean   3.90  Fair  🧼\n" +
+			"  off  Switch  d073d5000003  192.168.0.12  LIFX Switch  -     -     🔌🔘\n" +
+			"\n" +
+			"Home / Lounge\n" +
+			"  off  Strip  d073d5000002  192.168.0.11  LIFX Z  3.70  Excellent  🎨📏\n"
+		assert.Equal(t, want, buf.String())
+	})
+
+	t.Run("markdown", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, testDevices[:1], FormatMarkdown))
+		want := "### Home / Lounge\n\n" +
+			"| Power | Label | Serial | Address | Product | Firmware | Signal | Capabilities |\n" +
+			"|---|---|---|---|---|---|---|---|\n" +
+			"| off | Strip | d073d5000002 | 192.168.0.11 | LIFX Z | 3.70 | Excellent | 🎨📏 |\n"
+		assert.Equal(t, want, buf.String())
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, testDevices, FormatJSON))
+		var got []Group
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &got))
+		assert.Equal(t, Groups(testDevices), got)
+	})
+
+	t.Run("write errors", func(t *testing.T) {
+		for _, f := range []Format{FormatText, FormatMarkdown, FormatJSON} {
+			assert.ErrorIs(t, Write(failingWriter{}, testDevices, f), errWrite, f)
+		}
+	})
+
+	t.Run("json empty", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, Write(&buf, nil, FormatJSON))
+		assert.Equal(t, "[]\n", buf.String())
+	})
+}
+
+var errWrite = errors.New("write failed")
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errWrite }
@@ -0,0 +1,287 @@
+package matrix
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+const (
+	// lifeMaxGenerations is the number of generations after which a Game of Life cycle ends
+	// if the pattern has not settled, e.g. a glider on a wrapping matrix.
+	lifeMaxGenerations = 200
+	// lifeDensity is the share of cells alive in a random seed.
+	lifeDensity = 0.35
+)
+
+// Life runs Conway's Game of Life on the matrix, whose edges wrap around, showing a generation
+// on each interval. A cycle starts from the pattern, centered on the matrix, or from random cells
+// if pattern is nil, and ends once the cells die out or repeat a state of the previous two
+// generations, or after 200 generations.
+// Pattern rows are in the plaintext format, where '.' and ' ' are dead cells and any other
+// character a live one, e.g. a glider is []string{".O.", "..O", "OOO"}.
+// Cells are colored by age, the first color being used for newborn cells and the last one for
+// cells that lived as many generations as there are colors.
+// It repeats for n cycles, if cycles is set to 0 it repeats indefinitely.
+func Life(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, pattern []string, colors ...packets.LightHsbk) error {
+	return LifeCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, pattern, colors...)
+}
+
+// LifeCtx is like Life but stops when ctx is cancelled, returning the context error.
+func LifeCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, pattern []string, colors ...packets.LightHsbk) error {
+	cycle, err := lifeCycle(m, pattern, colors)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func lifeCycle(m *Matrix, pattern []string, colors []packets.LightHsbk) (cycleFunc, error) {
+	if len(colors) == 0 {
+		return nil, ErrMissingColors
+	}
+	if h, w := len(pattern), longestRow(pattern); w > m.Width || h > m.Height {
+		return nil, fmt.Errorf("%w: %dx%d cannot fit a %dx%d pattern", ErrMatrixTooSmall, m.Width, m.Height, w, h)
+	}
+
+	return func() func() bool {
+		// ages holds for how many generations each cell has been alive, 0 for dead cells,
+		// and is nil once the cycle is over.
+		ages := seedLife(m, pattern)
+		var previous [][]int
+		var generations int
+
+		return func() bool {
+			if ages == nil || generations == lifeMaxGenerations {
+				return false
+			}
+			m.Clear()
+			for y, row := range ages {
+				for x, age := range row {
+					if age > 0 {
+						m.SetPixel(x, y, colors[min(age, len(colors))-1])
+					}
+				}
+			}
+			generations++
+
+			next := lifeGeneration(ages)
+			if !anyAlive(next) || sameCells(next, ages) || sameCells(next, previous) {
+				ages = nil
+			} else {
+				previous, ages = ages, next
+			}
+			return true
+		}
+	}, nil
+}
+
+// seedLife returns the cells of the first generation, from the pattern centered on the matrix
+// or from random cells if pattern is nil.
+func seedLife(m *Matrix, pattern []string) [][]int {
+	cells := make([][]int, m.Height)
+	for y := range cells {
+		cells[y] = make([]int, m.Width)
+	}
+	if pattern == nil {
+		for _, row := range cells {
+			for x := range row {
+				if rand.Float64() < lifeDensity {
+					row[x] = 1
+				}
+			}
+		}
+		return cells
+	}
+
+	x0, y0 := (m.Width-longestRow(pattern))/2, (m.Height-len(pattern))/2
+	for y, row := range pattern {
+		for x, c := range []rune(row) {
+			if c != '.' && c != ' ' {
+				cells[y0+y][x0+x] = 1
+			}
+		}
+	}
+	return cells
+}
+
+// lifeGeneration returns the next generation of cells, on a grid wrapping around its edges.
+func lifeGeneration(ages [][]int) [][]int {
+	h := len(ages)
+	next := make([][]int, h)
+	for y, row := range ages {
+		w := len(row)
+		next[y] = make([]int, w)
+		for x, age := range row {
+			var neighbours int
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					if (dx != 0 || dy != 0) && ages[(y+dy+h)%h][(x+dx+w)%w] > 0 {
+						neighbours++
+					}
+				}
+			}
+			switch {
+			case age > 0 && (neighbours == 2 || neighbours == 3):
+				next[y][x] = age + 1
+			case age == 0 && neighbours == 3:
+				next[y][x] = 1
+			}
+		}
+	}
+	return next
+}
+
+// anyAlive reports whether any cell is alive.
+func anyAlive(ages [][]int) bool {
+	for _, row := range ages {
+		if slices.ContainsFunc(row, func(age int) bool { return age > 0 }) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameCells reports whether the same cells are alive in a and b, whatever their age.
+func sameCells(a, b [][]int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for y := range a {
+		for x := range a[y] {
+			if (a[y][x] > 0) != (b[y][x] > 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// longestRow returns the length of the longest row of the pattern.
+func longestRow(pattern []string) int {
+	var n int
+	for _, row := range pattern {
+		n = max(n, len([]rune(row)))
+	}
+	return n
+}
+
+// Maze carves a random maze on the matrix one passage at a time, then walks its solution from
+// the top left to the bottom right corner, showing a step on each interval.
+// Passages are lit with the first color and the solution with the second one, or the first one
+// if only one is given, while walls are off. Cells are on even rows and columns, so matrices
+// of even size leave their last row and column as walls.
+// It repeats for n cycles, each with a new maze. If cycles is set to 0 it repeats indefinitely.
+func Maze(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	return MazeCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, colors...)
+}
+
+// MazeCtx is like Maze but stops when ctx is cancelled, returning the context error.
+func MazeCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	cycle, err := mazeCycle(m, colors)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func mazeCycle(m *Matrix, colors []packets.LightHsbk) (cycleFunc, error) {
+	if len(colors) == 0 {
+		return nil, ErrMissingColors
+	}
+	palette := NewColorSlice(2, colors...)
+	passage, solution := palette[0], palette[1]
+
+	return func() func() bool {
+		m.Clear()
+		open := make([][]bool, m.Height)
+		for y := range open {
+			open[y] = make([]bool, m.Width)
+		}
+		carve := func(p Pixel) {
+			open[p.Y][p.X] = true
+			m.SetPixel(p.X, p.Y, passage)
+		}
+
+		// Carve the maze depth first, backtracking from dead ends, from the top left cell,
+		// then walk its solution.
+		cw, ch := (m.Width+1)/2, (m.Height+1)/2
+		visited := make([]bool, cw*ch)
+		var stack, path []Pixel
+		started, solved := false, false
+
+		return func() bool {
+			if !started {
+				started = true
+				visited[0] = true
+				stack = []Pixel{{}}
+				carve(Pixel{})
+				return true
+			}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				var next []Pixel
+				for _, n := range []Pixel{{c.X, c.Y - 1}, {c.X + 1, c.Y}, {c.X, c.Y + 1}, {c.X - 1, c.Y}} {
+					if n.X >= 0 && n.X < cw && n.Y >= 0 && n.Y < ch && !visited[n.Y*cw+n.X] {
+						next = append(next, n)
+					}
+				}
+				if len(next) == 0 {
+					stack = stack[:len(stack)-1]
+					continue
+				}
+
+				n := next[rand.IntN(len(next))]
+				visited[n.Y*cw+n.X] = true
+				stack = append(stack, n)
+				carve(Pixel{c.X + n.X, c.Y + n.Y})
+				carve(Pixel{2 * n.X, 2 * n.Y})
+				return true
+			}
+
+			if !solved {
+				solved = true
+				path = solveMaze(open, Pixel{}, Pixel{2 * (cw - 1), 2 * (ch - 1)})
+			}
+			if len(path) == 0 {
+				return false
+			}
+			m.SetPixel(path[0].X, path[0].Y, solution)
+			path = path[1:]
+			return true
+		}
+	}, nil
+}
+
+// solveMaze returns the shortest path of open pixels from start to end, both included,
+// or nil if there is none.
+func solveMaze(open [][]bool, start, end Pixel) []Pixel {
+	from := map[Pixel]Pixel{start: start}
+	queue := []Pixel{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if p == end {
+			path := []Pixel{p}
+			for p != start {
+				p = from[p]
+				path = append(path, p)
+			}
+			slices.Reverse(path)
+			return path
+		}
+		for _, n := range []Pixel{{p.X, p.Y - 1}, {p.X + 1, p.Y}, {p.X, p.Y + 1}, {p.X - 1, p.Y}} {
+			if n.Y < 0 || n.Y >= len(open) || n.X < 0 || n.X >= len(open[n.Y]) || !open[n.Y][n.X] {
+				continue
+			}
+			if _, ok := from[n]; !ok {
+				from[n] = p
+				queue = append(queue, n)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package matrix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// cellsString renders cells with '#' for live ones, row by row.
+func cellsString(ages [][]int) string {
+	var rows []string
+	for _, row := range ages {
+		var b strings.Builder
+		for _, age := range row {
+			if age > 0 {
+				b.WriteByte('#')
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		rows = append(rows, b.String())
+	}
+	return strings.Join(rows, "\n")
+}
+
+func TestLifeGeneration(t *testing.T) {
+	testCases := map[string]struct {
+		pattern []string
+		want    []string
+	}{
+		"Block is still": {
+			pattern: []string{"##", "##"},
+			want:    []string{".....", ".##..", ".##..", ".....", "....."},
+		},
+		"Blinker oscillates": {
+			pattern: []string{"###"},
+			want:    []string{".....", "..#..", "..#..", "..#..", "....."},
+		},
+		"Glider moves": {
+			pattern: []string{".O.", "..O", "OOO"},
+			want:    []string{".....", ".....", ".#.#.", "..##.", "..#.."},
+		},
+		"Lone cell dies": {
+			pattern: []string{"#"},
+			want:    []string{".....", ".....", ".....", ".....", "....."},
+		},
+		"Cells wrap around the edges": {
+			pattern: []string{"#....", "#....", "#...."},
+			want:    []string{".....", ".....", "##..#", ".....", "....."},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			next := lifeGeneration(seedLife(New(5, 5, 1), tc.pattern))
+			assert.Equal(t, strings.Join(tc.want, "\n"), cellsString(next))
+		})
+	}
+
+	t.Run("Counts the age of cells", func(t *testing.T) {
+		ages := seedLife(New(4, 4, 1), []string{"##", "##"})
+		ages = lifeGeneration(lifeGeneration(ages))
+		assert.Equal(t, 3, ages[1][1])
+	})
+}
+
+func TestLife(t *testing.T) {
+	var (
+		young = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		old   = packets.LightHsbk{Hue: 2, Brightness: 65535}
+	)
+
+	testCases := map[string]struct {
+		pattern    []string
+		wantFrames int
+	}{
+		"Ends when still":       {pattern: []string{"##", "##"}, wantFrames: 1},
+		"Ends when oscillating": {pattern: []string{"###"}, wantFrames: 2},
+		"Ends when dead":        {pattern: []string{"#"}, wantFrames: 1},
+		// A glider keeps moving on the wrapping matrix.
+		"Ends after the maximum generations": {pattern: []string{".O.", "..O", "OOO"}, wantFrames: lifeMaxGenerations},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var frames int
+			send := func(*protocol.Message) error {
+				frames++
+				return nil
+			}
+			require.NoError(t, Life(New(8, 8, 1), send, 0, 1, ChainModeNone, tc.pattern, young, old))
+			assert.Equal(t, tc.wantFrames, frames)
+		})
+	}
+
+	t.Run("Colors cells by age", func(t *testing.T) {
+		m := New(8, 8, 1)
+		require.NoError(t, Life(m, func(*protocol.Message) error { return nil }, 0, 1, ChainModeNone, []string{"###"}, young, old))
+		// The blinker center survives while its ends are born again.
+		assert.Equal(t, old, m.Colors[3][3])
+		assert.Equal(t, young, m.Colors[2][3])
+		assert.Equal(t, young, m.Colors[4][3])
+	})
+
+	t.Run("Seeds random cells", func(t *testing.T) {
+		assert.True(t, anyAlive(seedLife(New(8, 8, 1), nil)))
+	})
+
+	t.Run("Rejects invalid arguments", func(t *testing.T) {
+		send := func(*protocol.Message) error { return nil }
+		assert.ErrorIs(t, Life(New(8, 8, 1), send, 0, 1, ChainModeNone, nil), ErrMissingColors)
+		assert.EqualError(t, Life(New(4, 4, 1), send, 0, 1, ChainModeNone, []string{"#####"}, young),
+			"matrix too small: 4x4 cannot fit a 5x1 pattern")
+	})
+}
+
+func TestMaze(t *testing.T) {
+	var (
+		passage  = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		solution = packets.LightHsbk{Hue: 2, Brightness: 65535}
+	)
+
+	for _, size := range [][2]int{{8, 8}, {7, 5}, {16, 8}} {
+		m := New(size[0], size[1], 1)
+		var frames int
+		send := func(*protocol.Message) error {
+			frames++
+			return nil
+		}
+		require.NoError(t, Maze(m, send, 0, 1, ChainModeNone, passage, solution))
+
+		// Every cell is carved, the walls between cells are either carved or not, and the
+		// pixels between walls are never carved.
+		var carved, solved int
+		for y, row := range m.Colors {
+			for x, c := range row {
+				switch {
+				case x%2 == 0 && y%2 == 0:
+					assert.NotZero(t, c.Brightness, "cell %d,%d", x, y)
+				case x%2 == 1 && y%2 == 1:
+					assert.Zero(t, c.Brightness, "corner %d,%d", x, y)
+				}
+				if c == solution {
+					solved++
+				}
+				if c.Brightness > 0 {
+					carved++
+				}
+			}
+		}
+		cells := (m.Width + 1) / 2 * ((m.Height + 1) / 2)
+		// A perfect maze has one passage less than its cells.
+		assert.Equal(t, 2*cells-1, carved, "size %v", size)
+		assert.Equal(t, solution, m.Colors[0][0])
+		assert.Equal(t, solution, m.Colors[2*((m.Height+1)/2-1)][2*((m.Width+1)/2-1)])
+		// The first frame, one per carved passage and one per solution step.
+		perFrame := len(m.colorsMessages(0, 1, m.Flatten(), 0))
+		assert.Equal(t, cells+solved, frames/perFrame)
+	}
+}
+
+func TestSolveMaze(t *testing.T) {
+	open := [][]bool{
+		{true, true, true},
+		{false, false, true},
+		{true, true, true},
+	}
+	assert.Equal(t, []Pixel{{0, 0}, {1, 0}, {2, 0}, {2, 1}, {2, 2}, {1, 2}, {0, 2}}, solveMaze(open, Pixel{0, 0}, Pixel{0, 2}))
+
+	open[1][2] = false
+	assert.Nil(t, solveMaze(open, Pixel{0, 0}, Pixel{0, 2}))
+}
@@ -0,0 +1,46 @@
+package matrix
+
+import (
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// DoubleBuffer draws frames off-screen and presents them whole, so that a frame drawn in several
+// steps, e.g. cleared and then drawn, never shows partially on the device.
+type DoubleBuffer struct {
+	// Back is the off-screen matrix the next frame is drawn into.
+	Back *Matrix
+
+	mIdx, mLength int
+	front         []packets.LightHsbk
+}
+
+// NewDoubleBuffer returns a DoubleBuffer drawing into m and presenting its frames on mLength tiles from mIdx.
+func NewDoubleBuffer(m *Matrix, mIdx, mLength int) *DoubleBuffer {
+	return &DoubleBuffer{Back: m, mIdx: mIdx, mLength: mLength}
+}
+
+// Present sends the back buffer as a single frame with a transition of duration d. Frames needing more
+// than one message are loaded into a hidden frame buffer and then copied into the visible one at once,
+// if the device supports frame buffers.
+// The back buffer is left as is to be drawn over, and frames identical to the last one presented are not sent.
+func (b *DoubleBuffer) Present(send SendFunc, d time.Duration) error {
+	frame := b.Back.Flatten()
+	if b.front != nil && slices.Equal(frame, b.front) {
+		return nil
+	}
+	for _, msg := range b.Back.colorsMessages(b.mIdx, b.mLength, frame, d) {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	b.front = frame
+	return nil
+}
+
+// Front returns the colors of the last frame presented, or nil if none was.
+func (b *DoubleBuffer) Front() []packets.LightHsbk {
+	return slices.Clone(b.front)
+}
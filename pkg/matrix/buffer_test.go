@@ -0,0 +1,89 @@
+package matrix
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoubleBufferPresent(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		matrix *Matrix
+		want   []any
+	}{
+		"Single message": {
+			matrix: New(8, 8, 1),
+			want:   []any{&packets.TileSet64{}},
+		},
+		"Frame buffer": {
+			matrix: New(16, 8, 1),
+			want:   []any{&packets.TileSet64{}, &packets.TileSet64{}, &packets.TileCopyFrameBuffer{}},
+		},
+		"Without frame buffers": {
+			matrix: func() *Matrix {
+				m := New(16, 8, 1)
+				m.FrameBuffers = false
+				return m
+			}(),
+			want: []any{&packets.TileSet64{}, &packets.TileSet64{}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var sent []*protocol.Message
+			send := func(msg *protocol.Message) error {
+				sent = append(sent, msg)
+				return nil
+			}
+			b := NewDoubleBuffer(tc.matrix, 2, 1)
+			assert.Nil(t, b.Front())
+
+			// Drawing in several steps sends nothing until presented.
+			b.Back.Clear()
+			b.Back.SetPixel(1, 1, color)
+			assert.Empty(t, sent)
+
+			require.NoError(t, b.Present(send, 0))
+			require.Len(t, sent, len(tc.want))
+			for i, msg := range sent {
+				assert.IsType(t, tc.want[i], msg.Payload)
+			}
+			assert.Equal(t, uint8(2), sent[0].Payload.(*packets.TileSet64).TileIndex)
+			front := b.Front()
+			assert.Equal(t, color, front[tc.matrix.Width+1])
+
+			t.Run("Skips unchanged frames", func(t *testing.T) {
+				sent = nil
+				b.Back.Clear()
+				b.Back.SetPixel(1, 1, color)
+				require.NoError(t, b.Present(send, 0))
+				assert.Empty(t, sent)
+			})
+
+			t.Run("Keeps the front until presented", func(t *testing.T) {
+				b.Back.Clear()
+				assert.Equal(t, front, b.Front())
+				require.NoError(t, b.Present(send, 0))
+				assert.Equal(t, make([]packets.LightHsbk, tc.matrix.Size), b.Front())
+			})
+		})
+	}
+
+	t.Run("Presents again after a failed send", func(t *testing.T) {
+		b := NewDoubleBuffer(New(8, 8, 1), 0, 1)
+		errSend := errors.New("send failed")
+		assert.ErrorIs(t, b.Present(func(*protocol.Message) error { return errSend }, 0), errSend)
+		assert.Nil(t, b.Front())
+
+		var sent int
+		require.NoError(t, b.Present(func(*protocol.Message) error { sent++; return nil }, 0))
+		assert.Equal(t, 1, sent)
+	})
+}
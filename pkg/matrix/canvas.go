@@ -0,0 +1,133 @@
+package matrix
+
+import (
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// CompositeCanvas is a single pixel canvas spanning all the tiles of a chain,
+// laid out according to their physical position as arranged in the LIFX app.
+// Pixels written to the canvas are sent to the tile they fall on, rotated
+// according to its orientation, so that effects can be drawn across tiles as one surface.
+type CompositeCanvas struct {
+	Width  int
+	Height int
+
+	tileWidth, tileHeight int
+	// origins are the canvas coordinates of the top-left pixel of each tile.
+	origins      []Pixel
+	orientations []device.Orientation
+	colors       []packets.LightHsbk
+}
+
+// NewCompositeCanvas returns a CompositeCanvas for the chain described by p.
+// Tiles without a known position are laid out left to right.
+func NewCompositeCanvas(p device.MatrixProperties) *CompositeCanvas {
+	w, h := p.Width, p.Height
+	c := &CompositeCanvas{
+		tileWidth:    w,
+		tileHeight:   h,
+		origins:      make([]Pixel, p.ChainLength),
+		orientations: make([]device.Orientation, p.ChainLength),
+	}
+
+	// Positions are tile centers in units of tile size, with Y increasing upwards.
+	lefts, tops := make([]int, p.ChainLength), make([]int, p.ChainLength)
+	minLeft, maxTop := math.MaxInt, math.MinInt
+	for i := range p.ChainLength {
+		pos := device.TilePosition{X: float32(i)}
+		if i < len(p.ChainPositions) {
+			pos = p.ChainPositions[i]
+		}
+		lefts[i] = int(math.Round(float64(pos.X)*float64(w) - float64(w)/2))
+		tops[i] = int(math.Round(float64(pos.Y)*float64(h) + float64(h)/2))
+		minLeft, maxTop = min(minLeft, lefts[i]), max(maxTop, tops[i])
+
+		if i < len(p.ChainOrientations) {
+			c.orientations[i] = p.ChainOrientations[i]
+		}
+	}
+
+	for i := range p.ChainLength {
+		c.origins[i] = Pixel{X: lefts[i] - minLeft, Y: maxTop - tops[i]}
+		c.Width = max(c.Width, c.origins[i].X+w)
+		c.Height = max(c.Height, c.origins[i].Y+h)
+	}
+	c.colors = make([]packets.LightHsbk, c.Width*c.Height)
+	return c
+}
+
+// SetPixel sets the pixel at (x, y) to the given color.
+// Pixels outside of the canvas are ignored.
+func (c *CompositeCanvas) SetPixel(x, y int, color packets.LightHsbk) {
+	if c.inBounds(x, y) {
+		c.colors[y*c.Width+x] = color
+	}
+}
+
+// Pixel returns the color of the pixel at (x, y), or the zero value if it is outside of the canvas.
+func (c *CompositeCanvas) Pixel(x, y int) packets.LightHsbk {
+	if !c.inBounds(x, y) {
+		return packets.LightHsbk{}
+	}
+	return c.colors[y*c.Width+x]
+}
+
+// Clear sets all pixels to their default value.
+func (c *CompositeCanvas) Clear() {
+	clear(c.colors)
+}
+
+// DrawMatrix draws m onto the canvas with its top-left corner at (x, y),
+// so that Matrix helpers can be used to compose canvas frames.
+func (c *CompositeCanvas) DrawMatrix(x, y int, m *Matrix) {
+	for my, row := range m.Colors {
+		for mx, color := range row {
+			c.SetPixel(x+mx, y+my, color)
+		}
+	}
+}
+
+// TileOrigin returns the canvas coordinates of the top-left pixel of the tile at index i.
+func (c *CompositeCanvas) TileOrigin(i int) Pixel {
+	return c.origins[i]
+}
+
+// TileColors returns the colors of the tile at index i, row by row, rotated according to its orientation.
+func (c *CompositeCanvas) TileColors(i int) []packets.LightHsbk {
+	o := c.origins[i]
+	colors := make([]packets.LightHsbk, 0, c.tileWidth*c.tileHeight)
+	for y := range c.tileHeight {
+		start := (o.Y+y)*c.Width + o.X
+		colors = append(colors, c.colors[start:start+c.tileWidth]...)
+	}
+	return device.ReorientMatrix(c.tileWidth, c.tileHeight, c.orientations[i], colors)
+}
+
+// Messages returns the messages that set each tile of the chain to the canvas colors.
+func (c *CompositeCanvas) Messages(d time.Duration) []*protocol.Message {
+	var msgs []*protocol.Message
+	for i := range c.origins {
+		msgs = append(msgs, messages.SetMatrixColorsFromSlice(i, 1, c.tileWidth, c.TileColors(i), d)...)
+	}
+	return msgs
+}
+
+// Send sends the canvas to each tile of the chain with the given transition duration.
+func (c *CompositeCanvas) Send(send SendFunc, d time.Duration) error {
+	for _, msg := range c.Messages(d) {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *CompositeCanvas) inBounds(x, y int) bool {
+	return x >= 0 && x < c.Width && y >= 0 && y < c.Height
+}
@@ -0,0 +1,117 @@
+package matrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewCompositeCanvas(t *testing.T) {
+	testCases := map[string]struct {
+		props       device.MatrixProperties
+		wantWidth   int
+		wantHeight  int
+		wantOrigins []Pixel
+	}{
+		"tiles side by side": {
+			props: device.MatrixProperties{
+				Width: 8, Height: 8, ChainLength: 2,
+				ChainPositions: []device.TilePosition{{X: 0}, {X: 1}},
+			},
+			wantWidth: 16, wantHeight: 8,
+			wantOrigins: []Pixel{{0, 0}, {8, 0}},
+		},
+		"tile above": {
+			props: device.MatrixProperties{
+				Width: 8, Height: 8, ChainLength: 2,
+				ChainPositions: []device.TilePosition{{X: 0}, {Y: 1}},
+			},
+			wantWidth: 8, wantHeight: 16,
+			wantOrigins: []Pixel{{0, 8}, {0, 0}},
+		},
+		"offset tiles": {
+			props: device.MatrixProperties{
+				Width: 8, Height: 8, ChainLength: 3,
+				ChainPositions: []device.TilePosition{{X: 0, Y: 0}, {X: 1, Y: 0.5}, {X: -1, Y: -1}},
+			},
+			wantWidth: 24, wantHeight: 20,
+			wantOrigins: []Pixel{{8, 4}, {16, 0}, {0, 12}},
+		},
+		"unknown positions are laid out left to right": {
+			props:     device.MatrixProperties{Width: 8, Height: 8, ChainLength: 3},
+			wantWidth: 24, wantHeight: 8,
+			wantOrigins: []Pixel{{0, 0}, {8, 0}, {16, 0}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := NewCompositeCanvas(tc.props)
+			assert.Equal(t, tc.wantWidth, c.Width)
+			assert.Equal(t, tc.wantHeight, c.Height)
+			for i, want := range tc.wantOrigins {
+				assert.Equal(t, want, c.TileOrigin(i))
+			}
+		})
+	}
+}
+
+func TestCompositeCanvas(t *testing.T) {
+	c1, c2, c3, c4 := packets.LightHsbk{Kelvin: 1}, packets.LightHsbk{Kelvin: 2}, packets.LightHsbk{Kelvin: 3}, packets.LightHsbk{Kelvin: 4}
+	props := device.MatrixProperties{
+		Width: 2, Height: 2, ChainLength: 2,
+		ChainPositions:    []device.TilePosition{{X: 0}, {X: 1}},
+		ChainOrientations: []device.Orientation{device.OrientationRightSideUp, device.OrientationUpsideDown},
+	}
+
+	t.Run("Splits canvas into tiles", func(t *testing.T) {
+		c := NewCompositeCanvas(props)
+		m := New(4, 2, 1)
+		m.SetColors(0, 0, c1, c2, c3, c4, c1, c2, c3, c4)
+		c.DrawMatrix(0, 0, m)
+
+		assert.Equal(t, []packets.LightHsbk{c1, c2, c1, c2}, c.TileColors(0))
+		// The second tile is upside down.
+		assert.Equal(t, []packets.LightHsbk{c4, c3, c4, c3}, c.TileColors(1))
+	})
+
+	t.Run("Ignores pixels outside of the canvas", func(t *testing.T) {
+		c := NewCompositeCanvas(props)
+		c.SetPixel(-1, 0, c1)
+		c.SetPixel(4, 0, c1)
+		c.SetPixel(3, 1, c2)
+
+		assert.Equal(t, packets.LightHsbk{}, c.Pixel(4, 0))
+		assert.Equal(t, c2, c.Pixel(3, 1))
+
+		c.Clear()
+		assert.Equal(t, packets.LightHsbk{}, c.Pixel(3, 1))
+	})
+
+	t.Run("Sends a message per tile", func(t *testing.T) {
+		c := NewCompositeCanvas(props)
+		c.SetPixel(0, 0, c1)
+		c.SetPixel(2, 0, c2)
+
+		var got []*packets.TileSet64
+		err := c.Send(func(msg *protocol.Message) error {
+			got = append(got, msg.Payload.(*packets.TileSet64))
+			return nil
+		}, time.Second)
+		require.NoError(t, err)
+
+		require.Len(t, got, 2)
+		for i, p := range got {
+			assert.Equal(t, uint8(i), p.TileIndex)
+			assert.Equal(t, uint8(1), p.Length)
+			assert.Equal(t, uint32(1000), p.Duration)
+		}
+		assert.Equal(t, c1, got[0].Colors[0])
+		assert.Equal(t, c2, got[1].Colors[3])
+	})
+}
@@ -0,0 +1,163 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrMatrixTooSmall is returned when the time does not fit the matrix.
+var ErrMatrixTooSmall = errors.New("matrix too small")
+
+// ClockLayout configures how Clock renders the time.
+type ClockLayout struct {
+	// Hour12 shows the hours from 1 to 12 instead of 0 to 23.
+	Hour12 bool
+	// Seconds shows the seconds elapsed in the minute as a growing bar along the free edge
+	// of the matrix, updating it every second instead of every minute.
+	Seconds bool
+	// Location is the time zone of the clock. If nil the local time zone is used.
+	Location *time.Location
+}
+
+// Clock renders the current time as HH:MM on the matrix, updating it at the start of every
+// minute, or every second with the seconds indicator, until an error occurs.
+// The colors are used in turn for the hours, the minutes and the seconds indicator.
+// Matrices at least 16 pixels wide, e.g. the LIFX Ceiling 26", show the time on one line
+// with 3x5 digits, while 8x8 matrices show the hours above the minutes with 3x4 digits.
+// Each device of the chain shows the time.
+func Clock(m *Matrix, send SendFunc, layout ClockLayout, colors ...packets.LightHsbk) error {
+	return ClockCtx(context.Background(), m, send, layout, colors...)
+}
+
+// ClockCtx is like Clock but stops when ctx is cancelled, returning the context error.
+func ClockCtx(ctx context.Context, m *Matrix, send SendFunc, layout ClockLayout, colors ...packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
+	if err := validateClock(m, colors); err != nil {
+		return err
+	}
+	period := time.Minute
+	if layout.Seconds {
+		period = time.Second
+	}
+
+	for {
+		now := clockTime(layout)
+		drawClock(m, now, layout, colors)
+		for _, msg := range m.colorsMessages(0, max(m.ChainLength, 1), m.Flatten(), minInterval) {
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+		if err := sleep(ctx, now.Truncate(period).Add(period).Sub(now)); err != nil {
+			return err
+		}
+	}
+}
+
+// validateClock returns an error if there are no colors or the matrix cannot fit the time.
+func validateClock(m *Matrix, colors []packets.LightHsbk) error {
+	if len(colors) == 0 {
+		return ErrMissingColors
+	}
+	if !wideClock(m) && (m.Width < 7 || m.Height < 8) {
+		return fmt.Errorf("%w: %dx%d cannot fit a clock", ErrMatrixTooSmall, m.Width, m.Height)
+	}
+	return nil
+}
+
+// clockTime returns the current time in the time zone of the layout.
+func clockTime(layout ClockLayout) time.Time {
+	now := time.Now()
+	if layout.Location != nil {
+		now = now.In(layout.Location)
+	}
+	return now
+}
+
+// wideClock reports whether the matrix fits the time on one line.
+func wideClock(m *Matrix) bool {
+	return m.Width >= 16 && m.Height >= 5
+}
+
+// drawClock draws the time t on the matrix.
+func drawClock(m *Matrix, t time.Time, layout ClockLayout, colors []packets.LightHsbk) {
+	palette := NewColorSlice(3, colors...)
+	hour := t.Hour()
+	if layout.Hour12 {
+		hour = (hour+11)%12 + 1
+	}
+	hours, minutes := fmt.Sprintf("%02d", hour), fmt.Sprintf("%02d", t.Minute())
+	if layout.Hour12 {
+		hours = fmt.Sprintf("%2d", hour)
+	}
+
+	m.Clear()
+	if wideClock(m) {
+		// HH MM with the hours and minutes apart by two columns.
+		x0, y0 := (m.Width-16)/2, (m.Height-5)/2
+		drawBitmap(m, x0, y0, textBitmap(hours, clockFont3x5, false), palette[0])
+		drawBitmap(m, x0+9, y0, textBitmap(minutes, clockFont3x5, false), palette[1])
+		if layout.Seconds {
+			m.SetHorizontalSegment(0, m.MaxY(), t.Second()*m.Width/60, palette[2])
+		}
+		return
+	}
+
+	// The hours above the minutes, leaving the last column for the seconds.
+	x0, y0 := (m.Width-8)/2, (m.Height-8)/2
+	drawBitmap(m, x0, y0, textBitmap(hours, clockFont3x4, false), palette[0])
+	drawBitmap(m, x0, y0+4, textBitmap(minutes, clockFont3x4, false), palette[1])
+	if layout.Seconds {
+		lit := t.Second() * m.Height / 60
+		m.SetVerticalSegment(m.MaxX(), m.Height-lit, lit, palette[2])
+	}
+}
+
+// clockFont3x5 and clockFont3x4 are the digits of the clock.
+var (
+	clockFont3x5 = newDigitFont(3, [10][]string{
+		{"###", "#.#", "#.#", "#.#", "###"},
+		{".#.", "##.", ".#.", ".#.", "###"},
+		{"###", "..#", "###", "#..", "###"},
+		{"###", "..#", "###", "..#", "###"},
+		{"#.#", "#.#", "###", "..#", "..#"},
+		{"###", "#..", "###", "..#", "###"},
+		{"###", "#..", "###", "#.#", "###"},
+		{"###", "..#", "..#", "..#", "..#"},
+		{"###", "#.#", "###", "#.#", "###"},
+		{"###", "#.#", "###", "..#", "###"},
+	})
+	clockFont3x4 = newDigitFont(3, [10][]string{
+		{"###", "#.#", "#.#", "###"},
+		{"##.", ".#.", ".#.", "###"},
+		{"##.", "..#", ".#.", "###"},
+		{"###", ".##", "..#", "###"},
+		{"#.#", "#.#", "###", "..#"},
+		{"###", "##.", "..#", "##."},
+		{"#..", "###", "#.#", "###"},
+		{"###", "..#", ".#.", ".#."},
+		{"###", "###", "#.#", "###"},
+		{"###", "#.#", "###", "..#"},
+	})
+)
+
+// newDigitFont returns a font of the digits drawn by rows, with '#' for lit pixels.
+func newDigitFont(width int, digits [10][]string) *Font {
+	f := &Font{Width: width, Height: len(digits[0]), Glyphs: make(map[rune][]uint16, len(digits))}
+	for d, rows := range digits {
+		cols := make([]uint16, width)
+		for y, row := range rows {
+			for x, c := range row {
+				if c == '#' {
+					cols[x] |= 1 << y
+				}
+			}
+		}
+		f.Glyphs[rune('0'+d)] = cols
+	}
+	return f
+}
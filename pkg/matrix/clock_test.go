@@ -0,0 +1,139 @@
+package matrix
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrawClock(t *testing.T) {
+	var (
+		hours   = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		minutes = packets.LightHsbk{Hue: 2, Brightness: 65535}
+		seconds = packets.LightHsbk{Hue: 3, Brightness: 65535}
+		colors  = []packets.LightHsbk{hours, minutes, seconds}
+		t0      = time.Date(2024, 5, 1, 13, 47, 30, 0, time.UTC)
+	)
+
+	// render returns the matrix with H, M and S for the pixels lit with each color.
+	render := func(m *Matrix) string {
+		symbols := map[packets.LightHsbk]byte{{}: '.', hours: 'H', minutes: 'M', seconds: 'S'}
+		var rows []string
+		for _, row := range m.Colors {
+			var b strings.Builder
+			for _, c := range row {
+				b.WriteByte(symbols[c])
+			}
+			rows = append(rows, b.String())
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	testCases := map[string]struct {
+		matrix *Matrix
+		layout ClockLayout
+		want   []string
+	}{
+		"Wide 24h": {
+			matrix: New(16, 8, 1),
+			want: []string{
+				"................",
+				".H..HHH..M.M.MMM",
+				"HH....H..M.M...M",
+				".H..HHH..MMM...M",
+				".H....H....M...M",
+				"HHH.HHH....M...M",
+				"................",
+				"................",
+			},
+		},
+		"Wide 12h with seconds": {
+			matrix: New(16, 8, 1),
+			layout: ClockLayout{Hour12: true, Seconds: true},
+			want: []string{
+				"................",
+				".....H...M.M.MMM",
+				"....HH...M.M...M",
+				".....H...MMM...M",
+				".....H.....M...M",
+				"....HHH....M...M",
+				"................",
+				"SSSSSSSS........",
+			},
+		},
+		"Square with seconds": {
+			matrix: New(8, 8, 1),
+			layout: ClockLayout{Seconds: true},
+			want: []string{
+				"HH..HHH.",
+				".H...HH.",
+				".H....H.",
+				"HHH.HHH.",
+				"M.M.MMMS",
+				"M.M...MS",
+				"MMM..M.S",
+				"..M..M.S",
+			},
+		},
+		"Location": {
+			matrix: New(8, 8, 1),
+			layout: ClockLayout{Location: time.FixedZone("UTC+10", 10*60*60)},
+			want: []string{
+				"HH..HHH.",
+				"..H..HH.",
+				".H....H.",
+				"HHH.HHH.",
+				"M.M.MMM.",
+				"M.M...M.",
+				"MMM..M..",
+				"..M..M..",
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			now := t0
+			if tc.layout.Location != nil {
+				now = now.In(tc.layout.Location)
+			}
+			drawClock(tc.matrix, now, tc.layout, colors)
+			assert.Equal(t, strings.Join(tc.want, "\n"), render(tc.matrix))
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	color := packets.LightHsbk{Brightness: 65535}
+
+	t.Run("Sends a frame and waits for the next minute", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		sent := make(chan *protocol.Message, 10)
+		send := func(msg *protocol.Message) error {
+			sent <- msg
+			return nil
+		}
+
+		errCh := make(chan error, 1)
+		go func() { errCh <- ClockCtx(ctx, New(16, 8, 1), send, ClockLayout{}, color) }()
+		msg := <-sent
+		cancel()
+		require.ErrorIs(t, <-errCh, context.Canceled)
+
+		// A 16x8 frame is loaded into a hidden frame buffer then copied.
+		assert.IsType(t, &packets.TileSet64{}, msg.Payload)
+		assert.Len(t, sent, 2)
+	})
+
+	t.Run("Rejects invalid arguments", func(t *testing.T) {
+		send := func(*protocol.Message) error { return nil }
+		assert.ErrorIs(t, Clock(New(8, 8, 1), send, ClockLayout{}), ErrMissingColors)
+		assert.EqualError(t, Clock(New(5, 5, 1), send, ClockLayout{}, color), "matrix too small: 5x5 cannot fit a clock")
+	})
+}
@@ -0,0 +1,169 @@
+package matrix
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// Snapshot is a copy of the colors output by a matrix, as returned by Matrix.Snapshot,
+// to be compared with later frames with Matrix.Diff.
+type Snapshot struct {
+	Width  int
+	Height int
+	// Colors holds the flattened colors, corrected as by Matrix.Flatten.
+	Colors []packets.LightHsbk
+}
+
+// Region is a rectangle of pixels whose top-left corner is at (X, Y), with its colors laid out row by row.
+type Region struct {
+	X, Y   int
+	Width  int
+	Colors []packets.LightHsbk
+}
+
+// Height returns the number of rows of the region.
+func (r Region) Height() int {
+	return len(r.Colors) / r.Width
+}
+
+// Snapshot returns a copy of the current colors of the matrix.
+func (m *Matrix) Snapshot() Snapshot {
+	return Snapshot{Width: m.Width, Height: m.Height, Colors: m.Flatten()}
+}
+
+// Diff returns the regions of the matrix that changed since prev, in order of rows.
+// Each run of consecutive rows with changes is returned as a single region spanning the changed
+// columns of all of its rows. If prev has a different size, the whole matrix is returned.
+func (m *Matrix) Diff(prev Snapshot) []Region {
+	colors := m.Flatten()
+	if prev.Width != m.Width || prev.Height != m.Height || len(prev.Colors) != len(colors) {
+		if len(colors) == 0 {
+			return nil
+		}
+		return []Region{{Width: m.Width, Colors: colors}}
+	}
+
+	var regions []Region
+	// Columns x0 to x1 of rows y0 to the current one have changed, if y0 is not negative.
+	x0, x1, y0 := 0, 0, -1
+	flush := func(y1 int) {
+		if y0 < 0 {
+			return
+		}
+		r := Region{X: x0, Y: y0, Width: x1 - x0 + 1}
+		for y := y0; y <= y1; y++ {
+			r.Colors = append(r.Colors, colors[y*m.Width+x0:y*m.Width+x1+1]...)
+		}
+		regions = append(regions, r)
+		y0 = -1
+	}
+
+	for y := range m.Height {
+		first, last := -1, -1
+		for x := range m.Width {
+			if i := y*m.Width + x; colors[i] != prev.Colors[i] {
+				if first < 0 {
+					first = x
+				}
+				last = x
+			}
+		}
+		switch {
+		case first < 0:
+			flush(y - 1)
+		case y0 < 0:
+			x0, x1, y0 = first, last, y
+		default:
+			x0, x1 = min(x0, first), max(x1, last)
+		}
+	}
+	flush(m.MaxY())
+	return regions
+}
+
+// DiffSender sends the frames of a matrix as TileSet64 updates of the regions changed since the
+// previous frame, instead of always sending whole frames, which saves bandwidth for sparse animations.
+// Unchanged frames are not sent, and frames are sent whole when their changes do not need fewer
+// messages, as well as the first frame.
+// Unlike whole frames, regions are set in the visible frame buffer, so a frame split into several
+// messages may briefly show partially.
+type DiffSender struct {
+	send          SendFunc
+	mIdx, mLength int
+	prev          Snapshot
+}
+
+// NewDiffSender returns a DiffSender sending frames through send to mLength tiles from mIdx.
+func NewDiffSender(send SendFunc, mIdx, mLength int) *DiffSender {
+	return &DiffSender{send: send, mIdx: mIdx, mLength: mLength}
+}
+
+// Send sends the changes of m since the previous frame, with a transition of duration d.
+func (s *DiffSender) Send(m *Matrix, d time.Duration) error {
+	msgs, err := s.messages(m, d)
+	if err != nil {
+		return err
+	}
+	s.prev = m.Snapshot()
+	for _, msg := range msgs {
+		if err := s.send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Reset makes the next frame be sent whole, e.g. after the device colors were changed by other means.
+func (s *DiffSender) Reset() {
+	s.prev = Snapshot{}
+}
+
+// messages returns the messages updating the device to the colors of m.
+func (s *DiffSender) messages(m *Matrix, d time.Duration) ([]*protocol.Message, error) {
+	full := m.colorsMessages(s.mIdx, s.mLength, m.Flatten(), d)
+	if s.prev.Colors == nil {
+		return full, nil
+	}
+
+	// TileSet64 messages have a fixed size, so the changes are only worth sending in fewer messages
+	// than the pages of the whole frame.
+	pages := len(m.FlattenPages())
+	colors := m.Flatten()
+	var msgs []*protocol.Message
+	for _, r := range m.Diff(s.prev) {
+		r = alignRegion(r, colors, m.Width, m.Height)
+		rect, err := messages.SetMatrixRectColors(s.mIdx, s.mLength, r.X, r.Y, r.Width, m.Height, r.Colors, d)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, rect...)
+		if len(msgs) >= pages {
+			return full, nil
+		}
+	}
+	return msgs, nil
+}
+
+// alignRegion extends r down to a whole number of TileSet64 messages rows, or to the bottom of the
+// matrix, with the current colors, so that setting it does not overwrite the zones below it,
+// see messages.SetMatrixRectColors.
+func alignRegion(r Region, colors []packets.LightHsbk, width, height int) Region {
+	end := height
+	if device.ZonesPerTilePacket%r.Width == 0 {
+		rowsPerPacket := device.ZonesPerTilePacket / r.Width
+		end = min(r.Y+(r.Height()+rowsPerPacket-1)/rowsPerPacket*rowsPerPacket, height)
+	}
+	if end == r.Y+r.Height() {
+		return r
+	}
+
+	aligned := Region{X: r.X, Y: r.Y, Width: r.Width}
+	for y := r.Y; y < end; y++ {
+		aligned.Colors = append(aligned.Colors, colors[y*width+r.X:y*width+r.X+r.Width]...)
+	}
+	return aligned
+}
@@ -0,0 +1,169 @@
+package matrix
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiff(t *testing.T) {
+	var (
+		a = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		b = packets.LightHsbk{Hue: 2, Brightness: 65535}
+		o = packets.LightHsbk{}
+	)
+
+	testCases := map[string]struct {
+		prev   func(m *Matrix) Snapshot
+		change func(m *Matrix)
+		want   []Region
+	}{
+		"Unchanged": {
+			change: func(m *Matrix) {},
+		},
+		"Single pixel": {
+			change: func(m *Matrix) { m.SetPixel(2, 1, a) },
+			want:   []Region{{X: 2, Y: 1, Width: 1, Colors: []packets.LightHsbk{a}}},
+		},
+		"Consecutive rows": {
+			change: func(m *Matrix) {
+				m.SetPixel(1, 1, a)
+				m.SetPixel(3, 2, b)
+			},
+			want: []Region{{X: 1, Y: 1, Width: 3, Colors: []packets.LightHsbk{a, o, o, o, o, b}}},
+		},
+		"Separate rows": {
+			change: func(m *Matrix) {
+				m.SetPixel(0, 0, a)
+				m.SetPixel(3, 3, b)
+			},
+			want: []Region{
+				{X: 0, Y: 0, Width: 1, Colors: []packets.LightHsbk{a}},
+				{X: 3, Y: 3, Width: 1, Colors: []packets.LightHsbk{b}},
+			},
+		},
+		"Cleared pixel": {
+			prev: func(m *Matrix) Snapshot {
+				m.SetPixel(3, 0, a)
+				s := m.Snapshot()
+				m.Clear()
+				return s
+			},
+			change: func(m *Matrix) {},
+			want:   []Region{{X: 3, Y: 0, Width: 1, Colors: []packets.LightHsbk{o}}},
+		},
+		"Different size": {
+			prev: func(m *Matrix) Snapshot {
+				return New(2, 2, 1).Snapshot()
+			},
+			change: func(m *Matrix) {},
+			want:   []Region{{Width: 4, Colors: make([]packets.LightHsbk, 16)}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(4, 4, 1)
+			prev := m.Snapshot()
+			if tc.prev != nil {
+				prev = tc.prev(m)
+			}
+			tc.change(m)
+			assert.Equal(t, tc.want, m.Diff(prev))
+		})
+	}
+
+	t.Run("Snapshot is a copy", func(t *testing.T) {
+		m := New(4, 4, 1)
+		s := m.Snapshot()
+		m.SetPixel(0, 0, a)
+		assert.Equal(t, o, s.Colors[0])
+		assert.Equal(t, 1, m.Diff(s)[0].Height())
+	})
+}
+
+func TestDiffSender(t *testing.T) {
+	a := packets.LightHsbk{Hue: 1, Brightness: 65535}
+	var sent []*protocol.Message
+	s := NewDiffSender(func(msg *protocol.Message) error {
+		sent = append(sent, msg)
+		return nil
+	}, 1, 1)
+	m := New(16, 8, 3)
+
+	send := func(t *testing.T, change func()) []*protocol.Message {
+		sent = nil
+		change()
+		require.NoError(t, s.Send(m, time.Millisecond))
+		return sent
+	}
+
+	t.Run("Sends the first frame whole", func(t *testing.T) {
+		// Two messages loading a hidden frame buffer and one copying it.
+		assert.Len(t, send(t, func() {}), 3)
+	})
+
+	t.Run("Sends the changed region", func(t *testing.T) {
+		msgs := send(t, func() { m.SetPixel(5, 6, a) })
+		require.Len(t, msgs, 1)
+		var colors [64]packets.LightHsbk
+		colors[0] = a
+		assert.Equal(t, &packets.TileSet64{
+			TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{X: 5, Y: 6, Width: 1}, Duration: 1, Colors: colors,
+		}, msgs[0].Payload)
+	})
+
+	t.Run("Sends a changed row without overwriting the rows below", func(t *testing.T) {
+		b := packets.LightHsbk{Hue: 2, Brightness: 65535}
+		for y := range m.Height {
+			for x := range m.Width {
+				m.SetPixel(x, y, b)
+			}
+		}
+		send(t, func() {})
+
+		msgs := send(t, func() {
+			for x := range m.Width {
+				m.SetPixel(x, 3, a)
+			}
+		})
+		// 16 columns wide messages set 4 rows, so the row is sent with the 3 unchanged rows below it.
+		require.Len(t, msgs, 1)
+		var colors [64]packets.LightHsbk
+		for i := range colors {
+			colors[i] = b
+			if i < 16 {
+				colors[i] = a
+			}
+		}
+		assert.Equal(t, &packets.TileSet64{
+			TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{Y: 3, Width: 16}, Duration: 1, Colors: colors,
+		}, msgs[0].Payload)
+		m.Clear()
+		send(t, func() {})
+	})
+
+	t.Run("Sends nothing when unchanged", func(t *testing.T) {
+		assert.Empty(t, send(t, func() {}))
+	})
+
+	t.Run("Sends whole frames needing fewer messages", func(t *testing.T) {
+		msgs := send(t, func() {
+			for y := range m.Height {
+				m.SetPixel(0, y, a)
+				m.SetPixel(m.MaxX(), y, a)
+			}
+		})
+		assert.Len(t, msgs, 3)
+		assert.IsType(t, &packets.TileCopyFrameBuffer{}, msgs[2].Payload)
+	})
+
+	t.Run("Sends the whole frame after a reset", func(t *testing.T) {
+		s.Reset()
+		assert.Len(t, send(t, func() {}), 3)
+	})
+}
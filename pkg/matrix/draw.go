@@ -0,0 +1,107 @@
+package matrix
+
+import "github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+
+// DrawLine draws a line from (x0, y0) to (x1, y1) with Bresenham's algorithm, rotating through
+// the palette along the line. Pixels outside of the matrix are clipped.
+func (m *Matrix) DrawLine(x0, y0, x1, y1 int, palette ...packets.LightHsbk) {
+	if len(palette) == 0 {
+		return
+	}
+
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := 1, 1
+	if x0 > x1 {
+		sx = -1
+	}
+	if y0 > y1 {
+		sy = -1
+	}
+
+	e := dx + dy
+	for i := 0; ; i++ {
+		m.setPixelClipped(x0, y0, palette[i%len(palette)])
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		e2 := 2 * e
+		if e2 >= dy {
+			e += dy
+			x0 += sx
+		}
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// DrawRect draws a rectangle of the given size whose top-left corner is at (x, y), rotating through
+// the palette along each side or, if filled, along each row. Pixels outside of the matrix are clipped.
+func (m *Matrix) DrawRect(x, y, width, height int, filled bool, palette ...packets.LightHsbk) {
+	if width < 1 || height < 1 {
+		return
+	}
+	x1, y1 := x+width-1, y+height-1
+
+	if filled {
+		for row := y; row <= y1; row++ {
+			m.DrawLine(x, row, x1, row, palette...)
+		}
+		return
+	}
+	m.DrawLine(x, y, x1, y, palette...)
+	m.DrawLine(x, y1, x1, y1, palette...)
+	m.DrawLine(x, y, x, y1, palette...)
+	m.DrawLine(x1, y, x1, y1, palette...)
+}
+
+// DrawCircle draws a circle of the given radius centered at (cx, cy) with the midpoint algorithm.
+// The outline rotates through the palette from the axes towards the diagonals, keeping the circle
+// symmetric, while a filled circle rotates through it along each row. Pixels outside of the matrix are clipped.
+func (m *Matrix) DrawCircle(cx, cy, radius int, filled bool, palette ...packets.LightHsbk) {
+	if len(palette) == 0 || radius < 0 {
+		return
+	}
+
+	x, y := radius, 0
+	e := 1 - radius
+	for i := 0; x >= y; i++ {
+		if filled {
+			m.DrawLine(cx-x, cy+y, cx+x, cy+y, palette...)
+			m.DrawLine(cx-x, cy-y, cx+x, cy-y, palette...)
+			m.DrawLine(cx-y, cy+x, cx+y, cy+x, palette...)
+			m.DrawLine(cx-y, cy-x, cx+y, cy-x, palette...)
+		} else {
+			c := palette[i%len(palette)]
+			for _, p := range [...]Pixel{
+				{cx + x, cy + y}, {cx - x, cy + y}, {cx + x, cy - y}, {cx - x, cy - y},
+				{cx + y, cy + x}, {cx - y, cy + x}, {cx + y, cy - x}, {cx - y, cy - x},
+			} {
+				m.setPixelClipped(p.X, p.Y, c)
+			}
+		}
+
+		y++
+		if e < 0 {
+			e += 2*y + 1
+		} else {
+			x--
+			e += 2*(y-x) + 1
+		}
+	}
+}
+
+// setPixelClipped sets the pixel at (x, y) if it is within the matrix.
+func (m *Matrix) setPixelClipped(x, y int, c packets.LightHsbk) {
+	if x >= 0 && x < m.Width && y >= 0 && y < m.Height {
+		m.SetPixel(x, y, c)
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,96 @@
+package matrix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDraw(t *testing.T) {
+	var (
+		a = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		b = packets.LightHsbk{Hue: 2, Brightness: 65535}
+	)
+
+	// render returns the matrix with A and B for the pixels lit with each color.
+	render := func(m *Matrix) []string {
+		symbols := map[packets.LightHsbk]byte{{}: '.', a: 'A', b: 'B'}
+		var rows []string
+		for _, row := range m.Colors {
+			var sb strings.Builder
+			for _, c := range row {
+				sb.WriteByte(symbols[c])
+			}
+			rows = append(rows, sb.String())
+		}
+		return rows
+	}
+
+	testCases := map[string]struct {
+		draw func(m *Matrix)
+		want []string
+	}{
+		"Horizontal line": {
+			draw: func(m *Matrix) { m.DrawLine(1, 2, 5, 2, a, b) },
+			want: []string{"......", "......", ".ABABA", "......", "......", "......"},
+		},
+		"Reversed vertical line": {
+			draw: func(m *Matrix) { m.DrawLine(0, 4, 0, 1, a) },
+			want: []string{"......", "A.....", "A.....", "A.....", "A.....", "......"},
+		},
+		"Diagonal line": {
+			draw: func(m *Matrix) { m.DrawLine(0, 0, 5, 5, a, b) },
+			want: []string{"A.....", ".B....", "..A...", "...B..", "....A.", ".....B"},
+		},
+		"Shallow line": {
+			draw: func(m *Matrix) { m.DrawLine(0, 1, 5, 3, a) },
+			want: []string{"......", "AA....", "..AA..", "....AA", "......", "......"},
+		},
+		"Clipped line": {
+			draw: func(m *Matrix) { m.DrawLine(-2, 2, 8, 2, a) },
+			want: []string{"......", "......", "AAAAAA", "......", "......", "......"},
+		},
+		"Empty palette": {
+			draw: func(m *Matrix) { m.DrawLine(0, 0, 5, 5) },
+			want: []string{"......", "......", "......", "......", "......", "......"},
+		},
+		"Rectangle": {
+			draw: func(m *Matrix) { m.DrawRect(1, 1, 4, 3, false, a) },
+			want: []string{"......", ".AAAA.", ".A..A.", ".AAAA.", "......", "......"},
+		},
+		"Filled rectangle": {
+			draw: func(m *Matrix) { m.DrawRect(1, 1, 4, 3, true, a, b) },
+			want: []string{"......", ".ABAB.", ".ABAB.", ".ABAB.", "......", "......"},
+		},
+		"Clipped rectangle": {
+			draw: func(m *Matrix) { m.DrawRect(3, 3, 5, 5, false, a) },
+			want: []string{"......", "......", "......", "...AAA", "...A..", "...A.."},
+		},
+		"Circle": {
+			draw: func(m *Matrix) { m.DrawCircle(2, 2, 2, false, a, b) },
+			want: []string{".BAB..", "B...B.", "A...A.", "B...B.", ".BAB..", "......"},
+		},
+		"Filled circle": {
+			draw: func(m *Matrix) { m.DrawCircle(2, 2, 2, true, a) },
+			want: []string{".AAA..", "AAAAA.", "AAAAA.", "AAAAA.", ".AAA..", "......"},
+		},
+		"Clipped circle": {
+			draw: func(m *Matrix) { m.DrawCircle(0, 0, 2, false, a) },
+			want: []string{"..A...", "..A...", "AA....", "......", "......", "......"},
+		},
+		"Single pixel circle": {
+			draw: func(m *Matrix) { m.DrawCircle(3, 3, 0, false, a) },
+			want: []string{"......", "......", "......", "...A..", "......", "......"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(6, 6, 1)
+			tc.draw(m)
+			assert.Equal(t, tc.want, render(m))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package matrix
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"math"
@@ -9,7 +10,6 @@ import (
 	"time"
 
 	"github.com/alessio-palumbo/lifxlan-go/pkg/iterator"
-	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 )
@@ -20,6 +20,7 @@ var (
 	minInterval = time.Millisecond
 )
 
+// ChainMode selects the tiles of a chain an effect runs on.
 type ChainMode int
 
 const (
@@ -29,32 +30,43 @@ const (
 	ChainModeSequential
 	// ChainModeSynced applies the effect to the whole chain.
 	ChainModeSynced
+	// ChainModeStaggered applies the effect to each tile of the chain, each starting the
+	// Stagger of the matrix after the previous one, e.g. cascading a waterfall across tiles.
+	ChainModeStaggered
 )
 
 // ParseChainMode converts an int to chainmode.
-// If invalid it return ChainModeNone.
+// If invalid it return ChainModeNone. Use ChainModeFromInt or ParseChainModeString to detect invalid modes.
 func ParseChainMode(m int) ChainMode {
 	switch m {
 	case 1:
 		return ChainModeSequential
 	case 2:
 		return ChainModeSynced
+	case 3:
+		return ChainModeStaggered
 	default:
 		return ChainModeNone
 	}
 }
 
+// AnimationDirection is the direction of ConcentricFrames.
 type AnimationDirection int
 
 const (
+	// AnimationDirectionInwards draws frames from the border to the center.
 	AnimationDirectionInwards AnimationDirection = iota
+	// AnimationDirectionOutwards draws frames from the center to the border.
 	AnimationDirectionOutwards
+	// AnimationDirectionInOut draws frames inwards and then back outwards.
 	AnimationDirectionInOut
+	// AnimationDirectionOutIn draws frames outwards and then back inwards.
 	AnimationDirectionOutIn
 )
 
 // ParseAnimationDirection converts an int to AnimationDirection.
-// If invalid it return AnimationDirectionInwards.
+// If invalid it return AnimationDirectionInwards. Use AnimationDirectionFromInt or
+// ParseAnimationDirectionString to detect invalid directions.
 func ParseAnimationDirection(m int) AnimationDirection {
 	switch m {
 	case 1:
@@ -69,7 +81,9 @@ func ParseAnimationDirection(m int) AnimationDirection {
 }
 
 // SendFunc is an interface for sending protocol messages.
-type SendFunc = func(msg *protocol.Message) error
+//
+// Deprecated: use protocol.SendFunc, which it aliases.
+type SendFunc = protocol.SendFunc
 
 // ErrStopped is the error returned when manually stopping an effect.
 var ErrStopped = fmt.Errorf("manual stop")
@@ -86,6 +100,17 @@ func SendWithStop(send SendFunc) (SendFunc, *atomic.Bool) {
 	}, &stopped
 }
 
+// SendWithContext wraps a SendFunc so that it returns the context error once ctx is cancelled,
+// stopping any effect using it at the next send.
+func SendWithContext(ctx context.Context, send SendFunc) SendFunc {
+	return func(msg *protocol.Message) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return send(msg)
+	}
+}
+
 // Waterfall applies the given colors sequentially on each row centering them, if possible.
 // It waits for the given interval before setting the next row.
 // It repeats for n cycles, if cycles is set to 0 it repeats indefinitely.
@@ -93,6 +118,15 @@ func SendWithStop(send SendFunc) (SendFunc, *atomic.Bool) {
 // Deprecated: use effects.NewWaterfall with effects.Render for offline frames,
 // or effects/adapters.RunEffects for live rendering.
 func Waterfall(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	return WaterfallCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, colors...)
+}
+
+// WaterfallCtx is like Waterfall but stops when ctx is cancelled, returning the context error.
+//
+// Deprecated: use effects.NewWaterfall with effects.Render for offline frames,
+// or effects/adapters.RunEffects for live rendering.
+func WaterfallCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
 	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
 	if len(colors) == 0 {
 		return ErrMissingColors
@@ -104,34 +138,32 @@ func Waterfall(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode
 	// Try to center the colors if possible.
 	x := (m.Width - len(colors)) / 2
 
-	return repeatForCycles(cycles, func() error {
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
 		switch mode {
 		case ChainModeSequential:
 			for ti := range m.ChainLength {
-				if err := waterfall(m, send, d, x, ti, 1, colors...); err != nil {
+				if err := waterfall(ctx, m, send, d, x, ti, 1, colors...); err != nil {
 					return err
 				}
 			}
 			return nil
 		case ChainModeSynced:
-			return waterfall(m, send, d, x, 0, m.ChainLength, colors...)
+			return waterfall(ctx, m, send, d, x, 0, m.ChainLength, colors...)
 		default:
-			return waterfall(m, send, d, x, 0, 1, colors...)
+			return waterfall(ctx, m, send, d, x, 0, 1, colors...)
 		}
-	})
+	}))
 }
 
-func waterfall(m *Matrix, send SendFunc, d time.Duration, x, mIdx, mLength int, colors ...packets.LightHsbk) error {
+func waterfall(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, x, mIdx, mLength int, colors ...packets.LightHsbk) error {
 	m.Clear()
 
 	for i := range m.Height {
 		m.SetColors(x, i, colors...)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 	return nil
 }
@@ -144,29 +176,39 @@ func waterfall(m *Matrix, send SendFunc, d time.Duration, x, mIdx, mLength int,
 // Deprecated: use effects.NewRockets with effects.Render for offline frames,
 // or effects/adapters.RunEffects for live rendering.
 func Rockets(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	return RocketsCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, colors...)
+}
+
+// RocketsCtx is like Rockets but stops when ctx is cancelled, returning the context error.
+//
+// Deprecated: use effects.NewRockets with effects.Render for offline frames,
+// or effects/adapters.RunEffects for live rendering.
+func RocketsCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, colors ...packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
 	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
 	if len(colors) == 0 {
 		return ErrMissingColors
 	}
 
-	return repeatForCycles(cycles, func() error {
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
 		switch mode {
 		case ChainModeSequential:
 			for ti := range m.ChainLength {
-				if err := rockets(m, send, d, ti, 1, colors...); err != nil {
+				if err := rockets(ctx, m, send, d, ti, 1, colors...); err != nil {
 					return err
 				}
 			}
 			return nil
 		case ChainModeSynced:
-			return rockets(m, send, d, 0, m.ChainLength, colors...)
+			return rockets(ctx, m, send, d, 0, m.ChainLength, colors...)
 		default:
-			return rockets(m, send, d, 0, 1, colors...)
+			return rockets(ctx, m, send, d, 0, 1, colors...)
 		}
-	})
+	}))
 }
 
-func rockets(m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, colors ...packets.LightHsbk) error {
+func rockets(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, colors ...packets.LightHsbk) error {
 	m.Clear()
 
 	color := colors[0]
@@ -180,12 +222,9 @@ func rockets(m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, color
 		m.Clear()
 
 		m.SetPixel(x, y, color)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 	return nil
 }
@@ -196,27 +235,37 @@ func rockets(m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, color
 // Deprecated: use effects.NewWorm with effects.Render for offline frames,
 // or effects/adapters.RunEffects for live rendering.
 func Worm(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, size int, color packets.LightHsbk) error {
+	return WormCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, size, color)
+}
+
+// WormCtx is like Worm but stops when ctx is cancelled, returning the context error.
+//
+// Deprecated: use effects.NewWorm with effects.Render for offline frames,
+// or effects/adapters.RunEffects for live rendering.
+func WormCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, size int, color packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
 	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
 	wormSize := min(max(size, 1), m.Width)
 
-	return repeatForCycles(cycles, func() error {
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
 		switch mode {
 		case ChainModeSequential:
 			for ti := range m.ChainLength {
-				if err := worm(m, send, d, wormSize, ti, 1, color); err != nil {
+				if err := worm(ctx, m, send, d, wormSize, ti, 1, color); err != nil {
 					return err
 				}
 			}
 			return nil
 		case ChainModeSynced:
-			return worm(m, send, d, wormSize, 0, m.ChainLength, color)
+			return worm(ctx, m, send, d, wormSize, 0, m.ChainLength, color)
 		default:
-			return worm(m, send, d, wormSize, 0, 1, color)
+			return worm(ctx, m, send, d, wormSize, 0, 1, color)
 		}
-	})
+	}))
 }
 
-func worm(m *Matrix, send SendFunc, d time.Duration, wormSize, mIdx, mLength int, color packets.LightHsbk) error {
+func worm(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, wormSize, mIdx, mLength int, color packets.LightHsbk) error {
 	m.Clear()
 
 	pxCache := NewPixelCache(wormSize)
@@ -234,23 +283,17 @@ func worm(m *Matrix, send SendFunc, d time.Duration, wormSize, mIdx, mLength int
 		pxCache.SetPixel(i%wormSize, x, y)
 
 		m.SetPixel(x, y, color)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 
 	// Clear the tail and turn off all pixels.
 	for _, p := range pxCache.Pixels() {
 		m.Clear(p)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 	return nil
 }
@@ -261,27 +304,37 @@ func worm(m *Matrix, send SendFunc, d time.Duration, wormSize, mIdx, mLength int
 // Deprecated: use effects.NewSnake with effects.Render for offline frames,
 // or effects/adapters.RunEffects for live rendering.
 func Snake(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, size int, color packets.LightHsbk) error {
+	return SnakeCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, size, color)
+}
+
+// SnakeCtx is like Snake but stops when ctx is cancelled, returning the context error.
+//
+// Deprecated: use effects.NewSnake with effects.Render for offline frames,
+// or effects/adapters.RunEffects for live rendering.
+func SnakeCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, size int, color packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
 	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
 	snakeSize := min(max(size, 1), m.Width)
 
-	return repeatForCycles(cycles, func() error {
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
 		switch mode {
 		case ChainModeSequential:
 			for ti := range m.ChainLength {
-				if err := snake(m, send, d, snakeSize, ti, 1, color); err != nil {
+				if err := snake(ctx, m, send, d, snakeSize, ti, 1, color); err != nil {
 					return err
 				}
 			}
 			return nil
 		case ChainModeSynced:
-			return snake(m, send, d, snakeSize, 0, m.ChainLength, color)
+			return snake(ctx, m, send, d, snakeSize, 0, m.ChainLength, color)
 		default:
-			return snake(m, send, d, snakeSize, 0, 1, color)
+			return snake(ctx, m, send, d, snakeSize, 0, 1, color)
 		}
-	})
+	}))
 }
 
-func snake(m *Matrix, send SendFunc, d time.Duration, snakeSize, mIdx, mLength int, color packets.LightHsbk) error {
+func snake(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, snakeSize, mIdx, mLength int, color packets.LightHsbk) error {
 	m.Clear()
 
 	pxCache := NewPixelCache(snakeSize)
@@ -298,23 +351,17 @@ func snake(m *Matrix, send SendFunc, d time.Duration, snakeSize, mIdx, mLength i
 		pxCache.SetPixel(v, x, y)
 
 		m.SetPixel(x, y, color)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 
 	// Clear the tail and turn off all pixels.
 	for _, p := range pxCache.Pixels() {
 		m.Clear(p)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
-				return err
-			}
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
 		}
-		time.Sleep(d)
 	}
 	return nil
 }
@@ -327,6 +374,15 @@ func snake(m *Matrix, send SendFunc, d time.Duration, snakeSize, mIdx, mLength i
 // Deprecated: use effects.NewConcentricFrames with effects.Render for offline
 // frames, or effects/adapters.RunEffects for live rendering.
 func ConcentricFrames(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, direction AnimationDirection, colors ...packets.LightHsbk) error {
+	return ConcentricFramesCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, direction, colors...)
+}
+
+// ConcentricFramesCtx is like ConcentricFrames but stops when ctx is cancelled, returning the context error.
+//
+// Deprecated: use effects.NewConcentricFrames with effects.Render for offline
+// frames, or effects/adapters.RunEffects for live rendering.
+func ConcentricFramesCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, direction AnimationDirection, colors ...packets.LightHsbk) error {
+	send = SendWithContext(ctx, send)
 	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
 	var iterFunc func(yield func(int) bool)
 	maxSteps := m.MaxPadding() + 1
@@ -360,38 +416,75 @@ func ConcentricFrames(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int
 		return &color
 	}
 
-	return repeatForCycles(cycles, func() error {
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
 		switch mode {
 		case ChainModeSequential:
 			for ti := range m.ChainLength {
-				if err := concentricFrames(m, send, d, ti, 1, iterFunc, nextColor()); err != nil {
+				if err := concentricFrames(ctx, m, send, d, ti, 1, iterFunc, nextColor()); err != nil {
 					return err
 				}
 			}
 			return nil
 		case ChainModeSynced:
-			return concentricFrames(m, send, d, 0, m.ChainLength, iterFunc, nextColor())
+			return concentricFrames(ctx, m, send, d, 0, m.ChainLength, iterFunc, nextColor())
 		default:
-			return concentricFrames(m, send, d, 0, 1, iterFunc, nextColor())
+			return concentricFrames(ctx, m, send, d, 0, 1, iterFunc, nextColor())
 		}
-	})
+	}))
 }
 
-func concentricFrames(m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, iterator func(yield func(int) bool), color *packets.LightHsbk) error {
+func concentricFrames(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int, iterator func(yield func(int) bool), color *packets.LightHsbk) error {
 	m.Clear()
 
 	for p := range iterator {
 		m.Clear()
 		m.SetBorder(p, *color)
-		for _, m := range messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, m.Flatten(), minInterval) {
-			if err := send(m); err != nil {
+		if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// forChainMode runs f on the tiles selected by mode, with the index of the first tile and
+// the number of tiles showing the same frames.
+func forChainMode(m *Matrix, mode ChainMode, f func(mIdx, mLength int) error) error {
+	switch mode {
+	case ChainModeSequential:
+		for ti := range m.ChainLength {
+			if err := f(ti, 1); err != nil {
 				return err
 			}
 		}
-		time.Sleep(d)
+		return nil
+	case ChainModeSynced:
+		return f(0, m.ChainLength)
+	default:
+		return f(0, 1)
 	}
+}
 
-	return nil
+// sendFrame sends the matrix colors to the given tiles and waits for d,
+// transitioning from the previous frame if the matrix has an Interpolation.
+func sendFrame(ctx context.Context, m *Matrix, send SendFunc, d time.Duration, mIdx, mLength int) error {
+	if m.Interpolation.Easing != nil {
+		return m.sendInterpolated(ctx, send, d, mIdx, mLength, m.Flatten())
+	}
+	return sendColors(ctx, m, send, minInterval, d, mIdx, mLength, m.Flatten())
+}
+
+// sleep pauses for d or until ctx is cancelled, in which case it returns the context error.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
 
 // repeatForCycles repeats the given function for n cycles or indefinitely if cycles is 0.
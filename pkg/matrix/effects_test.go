@@ -1,12 +1,17 @@
 package matrix
 
 import (
+	"context"
+	"path/filepath"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/alessio-palumbo/lifxlan-go/internal/testutil"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseChainMode(t *testing.T) {
@@ -23,6 +28,9 @@ func TestParseChainMode(t *testing.T) {
 		"mode synced": {
 			value: 2, want: ChainModeSynced,
 		},
+		"mode staggered": {
+			value: 3, want: ChainModeStaggered,
+		},
 		"default to mode none": {
 			value: 100, want: ChainModeNone,
 		},
@@ -93,6 +101,84 @@ func TestSendWithStop(t *testing.T) {
 	}
 }
 
+func TestSendWithContext(t *testing.T) {
+	var sent int
+	ctx, cancel := context.WithCancel(context.Background())
+	send := SendWithContext(ctx, func(msg *protocol.Message) error {
+		sent++
+		return nil
+	})
+
+	assert.NoError(t, send(nil))
+	cancel()
+	assert.ErrorIs(t, send(nil), context.Canceled)
+	assert.Equal(t, 1, sent)
+}
+
+func TestEffectsCtx(t *testing.T) {
+	color := packets.LightHsbk{Brightness: 65535}
+	effects := map[string]func(ctx context.Context, send SendFunc) error{
+		"Waterfall": func(ctx context.Context, send SendFunc) error {
+			return WaterfallCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, color)
+		},
+		"Rockets": func(ctx context.Context, send SendFunc) error {
+			return RocketsCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, color)
+		},
+		"Worm": func(ctx context.Context, send SendFunc) error {
+			return WormCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 2, color)
+		},
+		"Snake": func(ctx context.Context, send SendFunc) error {
+			return SnakeCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 2, color)
+		},
+		"ConcentricFrames": func(ctx context.Context, send SendFunc) error {
+			return ConcentricFramesCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, AnimationDirectionInwards, color)
+		},
+		"Plasma": func(ctx context.Context, send SendFunc) error {
+			return PlasmaCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 1, 1, color)
+		},
+		"Fire": func(ctx context.Context, send SendFunc) error {
+			return FireCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 1, 1, color)
+		},
+		"Sparkle": func(ctx context.Context, send SendFunc) error {
+			return SparkleCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 1, 1, color)
+		},
+		"Rain": func(ctx context.Context, send SendFunc) error {
+			return RainCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, 1, 1, color)
+		},
+		"Life": func(ctx context.Context, send SendFunc) error {
+			return LifeCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, nil, color)
+		},
+		"Maze": func(ctx context.Context, send SendFunc) error {
+			return MazeCtx(ctx, New(4, 4, 1), send, 1000, 0, ChainModeNone, color)
+		},
+	}
+
+	for name, run := range effects {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			var sent atomic.Int32
+			send := func(*protocol.Message) error {
+				sent.Add(1)
+				return nil
+			}
+
+			errCh := make(chan error, 1)
+			go func() { errCh <- run(ctx, send) }()
+			time.Sleep(5 * time.Millisecond)
+			cancel()
+
+			// The effect stops while waiting for the 1s interval.
+			select {
+			case err := <-errCh:
+				assert.ErrorIs(t, err, context.Canceled)
+			case <-time.After(100 * time.Millisecond):
+				t.Fatal("Effect did not stop")
+			}
+			assert.Equal(t, int32(1), sent.Load())
+		})
+	}
+}
+
 func TestWaterfall(t *testing.T) {
 	testCases := map[string]struct {
 		mode    ChainMode
@@ -1771,3 +1857,64 @@ func TestConcentricFrames(t *testing.T) {
 		})
 	}
 }
+
+func TestEffectsGolden(t *testing.T) {
+	color := packets.LightHsbk{Hue: 21845, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	accent := packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 32768, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		width, height, chainLength int
+		run                        func(m *Matrix, send SendFunc) error
+	}{
+		"waterfall_16x8": {
+			width: 16, height: 8, chainLength: 1,
+			run: func(m *Matrix, send SendFunc) error {
+				return Waterfall(m, send, 0, 1, ChainModeNone, color, accent)
+			},
+		},
+		"rockets_16x8": {
+			width: 16, height: 8, chainLength: 1,
+			run: func(m *Matrix, send SendFunc) error {
+				return Rockets(m, send, 0, 1, ChainModeNone, color, accent)
+			},
+		},
+		"worm_8x8_sequential": {
+			width: 8, height: 8, chainLength: 2,
+			run: func(m *Matrix, send SendFunc) error {
+				return Worm(m, send, 0, 1, ChainModeSequential, 3, color)
+			},
+		},
+		"snake_16x8": {
+			width: 16, height: 8, chainLength: 1,
+			run: func(m *Matrix, send SendFunc) error {
+				return Snake(m, send, 0, 1, ChainModeNone, 3, color)
+			},
+		},
+		"waterfall_16x8_chain_synced": {
+			width: 16, height: 8, chainLength: 3,
+			run: func(m *Matrix, send SendFunc) error {
+				return Waterfall(m, send, 0, 1, ChainModeSynced, color, accent)
+			},
+		},
+		"waterfall_16x8_chain_sequential": {
+			width: 16, height: 8, chainLength: 2,
+			run: func(m *Matrix, send SendFunc) error {
+				return Waterfall(m, send, 0, 1, ChainModeSequential, color, accent)
+			},
+		},
+		"concentric_frames_8x8_synced": {
+			width: 8, height: 8, chainLength: 2,
+			run: func(m *Matrix, send SendFunc) error {
+				return ConcentricFrames(m, send, 0, 1, ChainModeSynced, AnimationDirectionInOut, color, accent)
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := testutil.NewFrameRecorder(tc.width, tc.height, tc.chainLength)
+			require.NoError(t, tc.run(New(tc.width, tc.height, tc.chainLength), rec.Send))
+			testutil.AssertFrames(t, rec, filepath.Join("testdata", name+".golden"))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package matrix
+
+import (
+	"context"
+	"time"
+)
+
+// cycleFunc returns the function drawing the frames of a new cycle of an effect, each call
+// drawing the next frame on the matrix and reporting false once the cycle is over.
+type cycleFunc func() func() bool
+
+// runCycles sends the frames of the cycles on the tiles selected by mode, one on each interval.
+func runCycles(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, cycle cycleFunc) error {
+	send = SendWithContext(ctx, send)
+	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
+
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(cycles, func() error {
+		return forChainMode(m, mode, func(mIdx, mLength int) error {
+			for next := cycle(); next(); {
+				if err := sendFrame(ctx, m, send, d, mIdx, mLength); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}))
+}
+
+// Frames draws the frames of an effect on a matrix one at a time, leaving the pacing and the
+// sending of the frames to the caller, e.g. the effects package adapting them to its Effect.
+type Frames struct {
+	// next draws the next frame, reporting false once the effect is over.
+	next func() bool
+}
+
+// Next draws the next frame on the matrix, reporting false once the effect is over.
+func (f *Frames) Next() bool {
+	return f.next()
+}
+
+// newFrames returns the Frames of the given number of cycles, repeating indefinitely if 0.
+func newFrames(cycles int, cycle cycleFunc) *Frames {
+	var n int
+	var next func() bool
+	return &Frames{next: func() bool {
+		for cycles == 0 || n < cycles {
+			if next == nil {
+				next = cycle()
+			}
+			if next() {
+				return true
+			}
+			next = nil
+			n++
+		}
+		return false
+	}}
+}
+
+// FramesEffect returns the Frames of an effect on m with the given parameters.
+// The Interval, Mode, Interpolation and Stagger are left to the caller.
+type FramesEffect func(m *Matrix, p EffectParams) (*Frames, error)
+
+// The matrix effects drawing Frames.
+var (
+	// PlasmaFrames draws the frames of PlasmaEffect.
+	PlasmaFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := plasmaCycle(m, p.Speed, p.Intensity, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// FireFrames draws the frames of FireEffect.
+	FireFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := fireCycle(m, p.Speed, p.Intensity, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// SparkleFrames draws the frames of SparkleEffect.
+	SparkleFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := sparkleCycle(m, p.Speed, p.Intensity, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// RainFrames draws the frames of RainEffect.
+	RainFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := rainCycle(m, p.Speed, p.Intensity, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// LifeFrames draws the frames of LifeEffect.
+	LifeFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := lifeCycle(m, p.Pattern, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// MazeFrames draws the frames of MazeEffect.
+	MazeFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		cycle, err := mazeCycle(m, p.palette())
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// ScrollTextFrames draws the frames of ScrollTextEffect.
+	ScrollTextFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		color, err := p.color()
+		if err != nil {
+			return nil, err
+		}
+		cycle, err := scrollTextCycle(m, p.ScrollDirection, p.Font, p.Text, color)
+		if err != nil {
+			return nil, err
+		}
+		return newFrames(p.Cycles, cycle), nil
+	}
+	// ClockFrames draws the current time on each frame, see Clock. It never ends.
+	ClockFrames FramesEffect = func(m *Matrix, p EffectParams) (*Frames, error) {
+		colors := p.palette()
+		if err := validateClock(m, colors); err != nil {
+			return nil, err
+		}
+		return &Frames{next: func() bool {
+			drawClock(m, clockTime(p.ClockLayout), p.ClockLayout, colors)
+			return true
+		}}, nil
+	}
+)
@@ -0,0 +1,117 @@
+package matrix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFramesEffects(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		frames     FramesEffect
+		opts       []EffectOption
+		wantFrames int
+		wantErr    error
+	}{
+		"Plasma": {
+			frames: PlasmaFrames, opts: []EffectOption{WithCycles(2), WithPalette(color)}, wantFrames: 2 * proceduralCycleFrames,
+		},
+		"Fire": {
+			frames: FireFrames, opts: []EffectOption{WithCycles(1), WithPalette(color)}, wantFrames: proceduralCycleFrames,
+		},
+		"Sparkle": {
+			frames: SparkleFrames, opts: []EffectOption{WithCycles(1), WithPalette(color)}, wantFrames: proceduralCycleFrames,
+		},
+		"Rain": {
+			frames: RainFrames, opts: []EffectOption{WithCycles(1), WithPalette(color)}, wantFrames: proceduralCycleFrames,
+		},
+		"Life ends when the cells die out": {
+			// A single cell dies in the next generation.
+			frames: LifeFrames, opts: []EffectOption{WithCycles(3), WithPattern("O"), WithPalette(color)}, wantFrames: 3,
+		},
+		"Scroll text": {
+			// "I" is 5 pixels wide, entering and leaving an 8 pixels wide matrix.
+			frames: ScrollTextFrames, opts: []EffectOption{WithCycles(1), WithText("I", ScrollLeft), WithPalette(color)}, wantFrames: 12,
+		},
+		"Missing colors": {
+			frames: FireFrames, opts: []EffectOption{WithCycles(1)}, wantErr: ErrMissingColors,
+		},
+		"Missing text": {
+			frames: ScrollTextFrames, opts: []EffectOption{WithCycles(1), WithPalette(color)}, wantErr: ErrMissingText,
+		},
+		"Pattern too large": {
+			frames: LifeFrames, opts: []EffectOption{WithPattern("OOOOOOOOO"), WithPalette(color)}, wantErr: ErrMatrixTooSmall,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			f, err := tc.frames(New(8, 8, 1), NewEffectParams(tc.opts...))
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var n int
+			for f.Next() {
+				n++
+			}
+			assert.Equal(t, tc.wantFrames, n)
+			assert.False(t, f.Next())
+		})
+	}
+
+	t.Run("Draws the frames sent by the effect", func(t *testing.T) {
+		p := NewEffectParams(WithInterval(0), WithCycles(1), WithText("Hi", ScrollUp), WithPalette(color))
+
+		var want [][]packets.LightHsbk
+		err := ScrollTextEffect(context.Background(), New(8, 8, 1), func(msg *protocol.Message) error {
+			if set, ok := msg.Payload.(*packets.TileSet64); ok {
+				want = append(want, set.Colors[:])
+			}
+			return nil
+		}, p)
+		require.NoError(t, err)
+
+		m := New(8, 8, 1)
+		f, err := ScrollTextFrames(m, p)
+		require.NoError(t, err)
+		var got [][]packets.LightHsbk
+		for f.Next() {
+			got = append(got, m.Flatten())
+		}
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("Maze carves then walks its solution", func(t *testing.T) {
+		m := New(5, 5, 1)
+		f, err := MazeFrames(m, NewEffectParams(WithCycles(1), WithPalette(color, color)))
+		require.NoError(t, err)
+		var n int
+		for f.Next() {
+			n++
+		}
+		// 9 cells carved one at a time, then a solution of at least 9 pixels.
+		assert.GreaterOrEqual(t, n, 18)
+	})
+
+	t.Run("Clock never ends", func(t *testing.T) {
+		m := New(16, 8, 1)
+		f, err := ClockFrames(m, NewEffectParams(WithPalette(color)))
+		require.NoError(t, err)
+		for range 3 {
+			assert.True(t, f.Next())
+		}
+		assert.NotEqual(t, New(16, 8, 1).Colors, m.Colors)
+
+		_, err = ClockFrames(New(4, 4, 1), NewEffectParams(WithPalette(color)))
+		assert.ErrorIs(t, err, ErrMatrixTooSmall)
+	})
+}
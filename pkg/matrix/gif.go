@@ -0,0 +1,131 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"io"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// maxPreloadedFrames is the number of hidden frame buffers available to preload animation frames.
+const maxPreloadedFrames = 7
+
+var ErrEmptyGIF = errors.New("gif has no frames")
+
+// PlayGIF decodes an animated GIF from r and plays it on the matrix, fitting each frame to the matrix size
+// and respecting the per-frame delays, with a minimum of 1ms.
+// Animations of up to 7 frames are preloaded into the device hidden frame buffers so that each frame
+// is shown with a single message, longer ones are sent frame by frame.
+// It repeats for n loops, if loopCount is set to 0 it repeats indefinitely.
+func PlayGIF(m *Matrix, send SendFunc, r io.Reader, loopCount int, mode ChainMode) error {
+	return PlayGIFCtx(context.Background(), m, send, r, loopCount, mode)
+}
+
+// PlayGIFCtx is like PlayGIF but stops when ctx is cancelled, returning the context error.
+func PlayGIFCtx(ctx context.Context, m *Matrix, send SendFunc, r io.Reader, loopCount int, mode ChainMode) error {
+	send = SendWithContext(ctx, send)
+	g, err := gif.DecodeAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode gif: %w", err)
+	}
+	if len(g.Image) == 0 {
+		return ErrEmptyGIF
+	}
+
+	frames := gifFrames(m, g)
+	delays := make([]time.Duration, len(g.Delay))
+	for i, d := range g.Delay {
+		delays[i] = max(time.Duration(d)*10*time.Millisecond, minInterval)
+	}
+
+	send, done := staggerChain(ctx, m, mode, send)
+	return done(repeatForCycles(loopCount, func() error {
+		switch mode {
+		case ChainModeSequential:
+			for ti := range m.ChainLength {
+				if err := playFrames(ctx, m, send, ti, 1, frames, delays); err != nil {
+					return err
+				}
+			}
+			return nil
+		case ChainModeSynced:
+			return playFrames(ctx, m, send, 0, m.ChainLength, frames, delays)
+		default:
+			return playFrames(ctx, m, send, 0, 1, frames, delays)
+		}
+	}))
+}
+
+func playFrames(ctx context.Context, m *Matrix, send SendFunc, mIdx, mLength int, frames [][]packets.LightHsbk, delays []time.Duration) error {
+	// Frames are preloaded into hidden frame buffers if the device supports them.
+	if m.FrameBuffers && len(frames) <= maxPreloadedFrames {
+		preload, next := messages.SetMatrixFrameAnimation(mIdx, mLength, m.Width, frames, 100, 0)
+		for _, msg := range preload {
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+		for i := range frames {
+			if err := send(next()); err != nil {
+				return err
+			}
+			if err := sleep(ctx, delays[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, colors := range frames {
+		for _, msg := range m.colorsMessages(mIdx, mLength, colors, 0) {
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+		if err := sleep(ctx, delays[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// gifFrames composes the frames of g, which may only cover part of the image and are
+// disposed of according to their disposal method, and maps them onto the matrix.
+func gifFrames(m *Matrix, g *gif.GIF) [][]packets.LightHsbk {
+	bounds := image.Rect(0, 0, g.Config.Width, g.Config.Height)
+	if bounds.Empty() {
+		bounds = g.Image[0].Bounds()
+	}
+	canvas := image.NewRGBA(bounds)
+
+	frames := make([][]packets.LightHsbk, len(g.Image))
+	for i, img := range g.Image {
+		var previous *image.RGBA
+		if i < len(g.Disposal) && g.Disposal[i] == gif.DisposalPrevious {
+			previous = image.NewRGBA(bounds)
+			copy(previous.Pix, canvas.Pix)
+		}
+
+		draw.Draw(canvas, img.Bounds(), img, img.Bounds().Min, draw.Over)
+		m.Clear()
+		DrawImage(m, canvas, ImageOptions{})
+		frames[i] = m.Flatten()
+
+		if i < len(g.Disposal) {
+			switch g.Disposal[i] {
+			case gif.DisposalBackground:
+				draw.Draw(canvas, img.Bounds(), image.Transparent, image.Point{}, draw.Src)
+			case gif.DisposalPrevious:
+				canvas = previous
+			}
+		}
+	}
+	return frames
+}
@@ -0,0 +1,109 @@
+package matrix
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/gif"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/testutil"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlayGIF(t *testing.T) {
+	var (
+		red  = packets.LightHsbk{Hue: 0, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		blue = packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	)
+	palette := color.Palette{color.Transparent, color.RGBA{R: 255, A: 255}, color.RGBA{B: 255, A: 255}, color.Black}
+
+	// newGIF returns an encoded 2x2 GIF with n frames, each one setting a single pixel
+	// on top of the previous frame, alternating red and blue.
+	newGIF := func(t *testing.T, n int, disposal byte) *bytes.Buffer {
+		g := &gif.GIF{Config: image.Config{Width: 2, Height: 2, ColorModel: palette}}
+		for i := range n {
+			x, y := i%2, i/2%2
+			img := image.NewPaletted(image.Rect(x, y, x+1, y+1), palette)
+			img.SetColorIndex(x, y, uint8(1+i%2))
+			g.Image = append(g.Image, img)
+			g.Delay = append(g.Delay, 0)
+			g.Disposal = append(g.Disposal, disposal)
+		}
+		var b bytes.Buffer
+		require.NoError(t, gif.EncodeAll(&b, g))
+		return &b
+	}
+
+	t.Run("Fails on invalid gifs", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(2, 2, 1)
+		assert.Error(t, PlayGIF(New(2, 2, 1), rec.Send, bytes.NewBufferString("not a gif"), 1, ChainModeNone))
+	})
+
+	testCases := map[string]struct {
+		frames     int
+		disposal   byte
+		chain      int
+		mode       ChainMode
+		want       [][]packets.LightHsbk
+		wantCopies bool
+	}{
+		"Preloads short animations": {
+			frames: 3, disposal: gif.DisposalNone, chain: 1,
+			want: [][]packets.LightHsbk{
+				{red, {}, {}, {}},
+				{red, blue, {}, {}},
+				{red, blue, red, {}},
+			},
+			wantCopies: true,
+		},
+		"Streams long animations": {
+			frames: 8, disposal: gif.DisposalBackground, chain: 1,
+			want: [][]packets.LightHsbk{
+				{red, {}, {}, {}},
+				{{}, blue, {}, {}},
+				{{}, {}, red, {}},
+				{{}, {}, {}, blue},
+				{red, {}, {}, {}},
+				{{}, blue, {}, {}},
+				{{}, {}, red, {}},
+				{{}, {}, {}, blue},
+			},
+		},
+		"Plays on each tile of a chain": {
+			frames: 2, disposal: gif.DisposalPrevious, chain: 2, mode: ChainModeSequential,
+			want: [][]packets.LightHsbk{
+				{red, {}, {}, {}},
+				{{}, blue, {}, {}},
+				{red, {}, {}, {}},
+				{{}, blue, {}, {}},
+			},
+			wantCopies: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := testutil.NewFrameRecorder(2, 2, tc.chain)
+			var copies int
+			send := func(msg *protocol.Message) error {
+				if _, ok := msg.Payload.(*packets.TileCopyFrameBuffer); ok {
+					copies++
+				}
+				return rec.Send(msg)
+			}
+			require.NoError(t, PlayGIF(New(2, 2, tc.chain), send, newGIF(t, tc.frames, tc.disposal), 1, tc.mode))
+
+			var got [][]packets.LightHsbk
+			for i, f := range rec.Frames() {
+				// Sequential mode plays on one tile at a time.
+				got = append(got, f.Tiles[i/tc.frames])
+			}
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, tc.wantCopies, copies > 0)
+		})
+	}
+}
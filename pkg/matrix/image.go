@@ -0,0 +1,155 @@
+package matrix
+
+import (
+	"image"
+	"math"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+const (
+	defaultImageKelvin     = 3500
+	defaultDitheringLevels = 4
+)
+
+// ScaleMode defines how an image is mapped onto the matrix.
+type ScaleMode int
+
+const (
+	// ScaleFit scales the image to fit within the matrix preserving its aspect ratio,
+	// leaving uncovered pixels untouched.
+	ScaleFit ScaleMode = iota
+	// ScaleFill scales the image to cover the whole matrix preserving its aspect ratio,
+	// cropping the overflowing edges.
+	ScaleFill
+	// ScaleCenter draws the image at its original size centered on the matrix,
+	// cropping the overflowing edges.
+	ScaleCenter
+)
+
+// ImageOptions configures how DrawImage renders an image.
+type ImageOptions struct {
+	Scale ScaleMode
+	// Kelvin is the kelvin of the converted colors. If not set 3500 is used.
+	Kelvin uint16
+	// Dither quantizes each RGB channel to DitheringLevels levels, diffusing the
+	// quantization error to neighboring pixels (Floyd-Steinberg).
+	Dither bool
+	// DitheringLevels is the number of levels per channel when dithering. If not set 4 is used.
+	DitheringLevels int
+}
+
+// DrawImage maps img onto the matrix pixel grid, averaging the source pixels covered
+// by each matrix pixel and converting them from RGB to HSBK.
+// Transparent areas of the image leave matrix pixels untouched.
+// Use Flatten with messages.SetMatrixColorsFromSlice to send the result to a device.
+func DrawImage(m *Matrix, img image.Image, opts ImageOptions) {
+	if opts.Kelvin == 0 {
+		opts.Kelvin = defaultImageKelvin
+	}
+
+	bounds := img.Bounds()
+	iw, ih := bounds.Dx(), bounds.Dy()
+	if iw == 0 || ih == 0 {
+		return
+	}
+
+	var scale float64
+	switch opts.Scale {
+	case ScaleFill:
+		scale = max(float64(m.Width)/float64(iw), float64(m.Height)/float64(ih))
+	case ScaleCenter:
+		scale = 1
+	default:
+		scale = min(float64(m.Width)/float64(iw), float64(m.Height)/float64(ih))
+	}
+	dw, dh := max(int(math.Round(float64(iw)*scale)), 1), max(int(math.Round(float64(ih)*scale)), 1)
+	ox, oy := (m.Width-dw)/2, (m.Height-dh)/2
+
+	// Sample the image into a grid of the matrix size, with alpha 0 for uncovered pixels.
+	samples := make([][4]float64, m.Size)
+	for y := range m.Height {
+		for x := range m.Width {
+			u, v := x-ox, y-oy
+			if u < 0 || v < 0 || u >= dw || v >= dh {
+				continue
+			}
+			x0 := bounds.Min.X + int(float64(u)*float64(iw)/float64(dw))
+			y0 := bounds.Min.Y + int(float64(v)*float64(ih)/float64(dh))
+			x1 := max(bounds.Min.X+int(float64(u+1)*float64(iw)/float64(dw)), x0+1)
+			y1 := max(bounds.Min.Y+int(float64(v+1)*float64(ih)/float64(dh)), y0+1)
+			samples[y*m.Width+x] = averageRGBA(img, x0, y0, x1, y1)
+		}
+	}
+
+	if opts.Dither {
+		levels := opts.DitheringLevels
+		if levels < 2 {
+			levels = defaultDitheringLevels
+		}
+		dither(samples, m.Width, m.Height, levels)
+	}
+
+	for i, s := range samples {
+		if s[3] == 0 {
+			continue
+		}
+		c := device.NewColorFromRGB(channel(s[0]), channel(s[1]), channel(s[2]), opts.Kelvin)
+		m.SetPixel(i%m.Width, i/m.Width, c.ToDeviceColor())
+	}
+}
+
+// averageRGBA returns the average premultiplied RGBA values in [0,255] of the pixels
+// in the rectangle [x0,x1) x [y0,y1).
+func averageRGBA(img image.Image, x0, y0, x1, y1 int) [4]float64 {
+	var sum [4]float64
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			r, g, b, a := img.At(x, y).RGBA()
+			sum[0] += float64(r >> 8)
+			sum[1] += float64(g >> 8)
+			sum[2] += float64(b >> 8)
+			sum[3] += float64(a >> 8)
+		}
+	}
+	n := float64((x1 - x0) * (y1 - y0))
+	for i := range sum {
+		sum[i] /= n
+	}
+	return sum
+}
+
+// dither quantizes the RGB channels of samples to the given number of levels
+// using Floyd-Steinberg error diffusion.
+func dither(samples [][4]float64, width, height, levels int) {
+	step := 255 / float64(levels-1)
+	diffuse := func(x, y, c int, err float64) {
+		if x < 0 || x >= width || y >= height {
+			return
+		}
+		samples[y*width+x][c] += err
+	}
+
+	for y := range height {
+		for x := range width {
+			s := &samples[y*width+x]
+			if s[3] == 0 {
+				continue
+			}
+			for c := range 3 {
+				old := s[c]
+				s[c] = math.Round(min(max(old, 0), 255)/step) * step
+				err := old - s[c]
+				diffuse(x+1, y, c, err*7/16)
+				diffuse(x-1, y+1, c, err*3/16)
+				diffuse(x, y+1, c, err*5/16)
+				diffuse(x+1, y+1, c, err*1/16)
+			}
+		}
+	}
+}
+
+// channel converts a channel value to uint8, clamping it to [0,255].
+func channel(v float64) uint8 {
+	return uint8(math.Round(min(max(v, 0), 255)))
+}
@@ -0,0 +1,128 @@
+package matrix
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrawImage(t *testing.T) {
+	var (
+		red   = packets.LightHsbk{Hue: 0, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		blue  = packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		white = packets.LightHsbk{Brightness: 65535, Kelvin: 3500}
+		mark  = packets.LightHsbk{Kelvin: 9000}
+	)
+
+	// newImage returns a w x h image whose left half is red and right half blue.
+	newImage := func(w, h int) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, w, h))
+		for y := range h {
+			for x := range w {
+				c := color.RGBA{R: 255, A: 255}
+				if x >= w/2 {
+					c = color.RGBA{B: 255, A: 255}
+				}
+				img.Set(x, y, c)
+			}
+		}
+		return img
+	}
+
+	testCases := map[string]struct {
+		img  image.Image
+		opts ImageOptions
+		want [][]packets.LightHsbk
+	}{
+		"fit downsamples and letterboxes": {
+			img: newImage(8, 4),
+			want: [][]packets.LightHsbk{
+				{mark, mark, mark, mark},
+				{red, red, blue, blue},
+				{red, red, blue, blue},
+				{mark, mark, mark, mark},
+			},
+		},
+		"fill crops the edges": {
+			img:  newImage(8, 2),
+			opts: ImageOptions{Scale: ScaleFill},
+			want: [][]packets.LightHsbk{
+				{red, red, blue, blue},
+				{red, red, blue, blue},
+				{red, red, blue, blue},
+				{red, red, blue, blue},
+			},
+		},
+		"center keeps the original size": {
+			img:  newImage(2, 2),
+			opts: ImageOptions{Scale: ScaleCenter},
+			want: [][]packets.LightHsbk{
+				{mark, mark, mark, mark},
+				{mark, red, blue, mark},
+				{mark, red, blue, mark},
+				{mark, mark, mark, mark},
+			},
+		},
+		"uses the given kelvin": {
+			img:  image.NewUniform(color.White),
+			opts: ImageOptions{Kelvin: 2700},
+			want: [][]packets.LightHsbk{
+				{{Brightness: 65535, Kelvin: 2700}},
+			},
+		},
+		"transparent pixels are untouched": {
+			img: image.NewRGBA(image.Rect(0, 0, 4, 4)),
+			want: [][]packets.LightHsbk{
+				{mark, mark, mark, mark},
+				{mark, mark, mark, mark},
+				{mark, mark, mark, mark},
+				{mark, mark, mark, mark},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(len(tc.want[0]), len(tc.want), 1)
+			for y := range m.Height {
+				m.SetHorizontalSegment(0, y, m.Width, mark)
+			}
+			if u, ok := tc.img.(*image.Uniform); ok {
+				// Uniform images are unbounded, bound them to the matrix size.
+				tc.img = boundedImage{u, image.Rect(0, 0, m.Width, m.Height)}
+			}
+			DrawImage(m, tc.img, tc.opts)
+			assert.Equal(t, tc.want, m.Colors)
+		})
+	}
+
+	t.Run("Dithers to the given levels", func(t *testing.T) {
+		// A uniform 50% gray is not representable with 2 levels, so dithering
+		// alternates black and white pixels.
+		img := boundedImage{image.NewUniform(color.Gray{Y: 128}), image.Rect(0, 0, 4, 4)}
+		m := New(4, 4, 1)
+		DrawImage(m, img, ImageOptions{Dither: true, DitheringLevels: 2})
+
+		var lit int
+		for _, c := range m.Flatten() {
+			assert.Contains(t, []packets.LightHsbk{white, {Kelvin: 3500}}, c)
+			if c == white {
+				lit++
+			}
+		}
+		assert.InDelta(t, 8, lit, 1)
+	})
+}
+
+// boundedImage bounds an image to the given rectangle.
+type boundedImage struct {
+	image.Image
+	bounds image.Rectangle
+}
+
+func (b boundedImage) Bounds() image.Rectangle {
+	return b.bounds
+}
@@ -0,0 +1,100 @@
+package matrix
+
+import (
+	"context"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// Easing maps the progress of a transition, between 0 and 1, to the progress of its colors.
+type Easing func(t float64) float64
+
+var (
+	// EaseLinear changes the colors at a constant rate.
+	EaseLinear Easing = func(t float64) float64 { return t }
+	// EaseInQuad starts slowly and accelerates.
+	EaseInQuad Easing = func(t float64) float64 { return t * t }
+	// EaseOutQuad starts quickly and decelerates.
+	EaseOutQuad Easing = func(t float64) float64 { return t * (2 - t) }
+	// EaseInOutQuad accelerates until halfway and then decelerates.
+	EaseInOutQuad Easing = func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return -1 + (4-2*t)*t
+	}
+	// EaseInOutSine accelerates and decelerates along a sine curve.
+	EaseInOutSine Easing = func(t float64) float64 { return (1 - math.Cos(math.Pi*t)) / 2 }
+)
+
+// Interpolation configures the transitions between the frames of the effects, which
+// otherwise jump from one frame to the next.
+type Interpolation struct {
+	// Easing shapes the transitions. Interpolation is disabled if nil.
+	Easing Easing
+	// FrameRate is the number of intermediate frames per second rendered along the easing
+	// curve, each faded by the device over the time until the next one.
+	// If 0, each frame is sent once and faded linearly by the device over the whole
+	// interval, which is the cheapest on the network.
+	FrameRate int
+}
+
+// WithEasing sets the easing curve and the rate of the intermediate frames rendered between
+// the frames of the effect, see Interpolation.
+func WithEasing(easing Easing, frameRate int) EffectOption {
+	return func(p *EffectParams) { p.Interpolation = Interpolation{Easing: easing, FrameRate: frameRate} }
+}
+
+// sendInterpolated sends the transition from the last frame sent to the given tiles to frame,
+// lasting d. The first frame is sent as is and unchanged frames are not sent again.
+func (m *Matrix) sendInterpolated(ctx context.Context, send SendFunc, d time.Duration, mIdx, mLength int, frame []packets.LightHsbk) error {
+	if m.lastFrames == nil {
+		m.lastFrames = make(map[int][]packets.LightHsbk)
+	}
+	prev, ok := m.lastFrames[mIdx]
+	m.lastFrames[mIdx] = frame
+
+	switch {
+	case !ok || len(prev) != len(frame):
+		return sendColors(ctx, m, send, minInterval, d, mIdx, mLength, frame)
+	case slices.Equal(prev, frame):
+		return sleep(ctx, d)
+	case m.Interpolation.FrameRate <= 0:
+		return sendColors(ctx, m, send, d, d, mIdx, mLength, frame)
+	}
+
+	steps := max(int(d.Seconds()*float64(m.Interpolation.FrameRate)), 1)
+	step := d / time.Duration(steps)
+	for i := 1; i <= steps; i++ {
+		colors := frame
+		if i < steps {
+			colors = blendFrames(prev, frame, m.Interpolation.Easing(float64(i)/float64(steps)))
+		}
+		if err := sendColors(ctx, m, send, max(step, minInterval), step, mIdx, mLength, colors); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sendColors sends the colors to the given tiles with a transition of duration and waits for d.
+func sendColors(ctx context.Context, m *Matrix, send SendFunc, duration, d time.Duration, mIdx, mLength int, colors []packets.LightHsbk) error {
+	for _, msg := range m.colorsMessages(mIdx, mLength, colors, duration) {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	return sleep(ctx, d)
+}
+
+// blendFrames returns the colors at t on the way from the frame a to b.
+func blendFrames(a, b []packets.LightHsbk, t float64) []packets.LightHsbk {
+	colors := make([]packets.LightHsbk, len(b))
+	for i := range b {
+		colors[i] = blendColors(a[i], b[i], t)
+	}
+	return colors
+}
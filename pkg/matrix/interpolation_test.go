@@ -0,0 +1,131 @@
+package matrix
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEasings(t *testing.T) {
+	easings := map[string]Easing{
+		"Linear":    EaseLinear,
+		"InQuad":    EaseInQuad,
+		"OutQuad":   EaseOutQuad,
+		"InOutQuad": EaseInOutQuad,
+		"InOutSine": EaseInOutSine,
+	}
+
+	for name, ease := range easings {
+		t.Run(name, func(t *testing.T) {
+			assert.InDelta(t, 0, ease(0), 1e-9)
+			assert.InDelta(t, 1, ease(1), 1e-9)
+			for i := range 10 {
+				assert.LessOrEqual(t, ease(float64(i)/10), ease(float64(i+1)/10))
+			}
+		})
+	}
+
+	assert.Less(t, EaseInQuad(0.5), 0.5)
+	assert.Greater(t, EaseOutQuad(0.5), 0.5)
+	assert.InDelta(t, 0.5, EaseInOutSine(0.5), 1e-9)
+}
+
+func TestSendFrameInterpolation(t *testing.T) {
+	off := packets.LightHsbk{Kelvin: 3500}
+	on := packets.LightHsbk{Saturation: 65535, Brightness: 40000, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		interpolation Interpolation
+		// The brightness of the lit pixel and the duration of each message after the first frame.
+		wantBrightness []uint16
+		wantDurations  []uint32
+	}{
+		"Disabled": {
+			wantBrightness: []uint16{40000},
+			wantDurations:  []uint32{1},
+		},
+		"Faded by the device": {
+			interpolation:  Interpolation{Easing: EaseLinear},
+			wantBrightness: []uint16{40000},
+			wantDurations:  []uint32{40},
+		},
+		"Linear frames": {
+			interpolation:  Interpolation{Easing: EaseLinear, FrameRate: 100},
+			wantBrightness: []uint16{10000, 20000, 30000, 40000},
+			wantDurations:  []uint32{10, 10, 10, 10},
+		},
+		"Eased frames": {
+			interpolation:  Interpolation{Easing: EaseInQuad, FrameRate: 100},
+			wantBrightness: []uint16{2500, 10000, 22500, 40000},
+			wantDurations:  []uint32{10, 10, 10, 10},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var sent []*packets.TileSet64
+			send := func(msg *protocol.Message) error {
+				sent = append(sent, msg.Payload.(*packets.TileSet64))
+				return nil
+			}
+			m := New(8, 8, 1)
+			m.Interpolation = tc.interpolation
+			m.SetPixel(0, 0, off)
+			require.NoError(t, sendFrame(context.Background(), m, send, 40*time.Millisecond, 0, 1))
+			require.Len(t, sent, 1)
+
+			sent = nil
+			m.SetPixel(0, 0, on)
+			require.NoError(t, sendFrame(context.Background(), m, send, 40*time.Millisecond, 0, 1))
+			var brightness []uint16
+			var durations []uint32
+			for _, p := range sent {
+				brightness = append(brightness, p.Colors[0].Brightness)
+				durations = append(durations, p.Duration)
+			}
+			assert.Equal(t, tc.wantBrightness, brightness)
+			assert.Equal(t, tc.wantDurations, durations)
+		})
+	}
+
+	t.Run("Does not resend unchanged frames", func(t *testing.T) {
+		var sent int
+		send := func(*protocol.Message) error {
+			sent++
+			return nil
+		}
+		m := New(8, 8, 2)
+		m.Interpolation = Interpolation{Easing: EaseLinear, FrameRate: 100}
+		require.NoError(t, sendFrame(context.Background(), m, send, 0, 0, 1))
+		require.NoError(t, sendFrame(context.Background(), m, send, 0, 0, 1))
+		assert.Equal(t, 1, sent)
+		// The frames of each tile are interpolated separately.
+		require.NoError(t, sendFrame(context.Background(), m, send, 0, 1, 1))
+		assert.Equal(t, 2, sent)
+	})
+}
+
+func TestEffectInterpolation(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	var durations []uint32
+	send := func(msg *protocol.Message) error {
+		durations = append(durations, msg.Payload.(*packets.TileSet64).Duration)
+		return nil
+	}
+	m := New(8, 8, 1)
+	p := NewEffectParams(WithInterval(10*time.Millisecond), WithCycles(1), WithPalette(color), WithEasing(EaseOutQuad, 0))
+	require.NoError(t, WaterfallEffect(context.Background(), m, send, p))
+
+	require.Len(t, durations, 8)
+	assert.Equal(t, uint32(1), durations[0])
+	for _, d := range durations[1:] {
+		assert.Equal(t, uint32(10), d)
+	}
+	// The interpolation of the matrix is restored.
+	assert.Nil(t, m.Interpolation.Easing)
+}
@@ -1,6 +1,11 @@
 package matrix
 
 import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 )
 
@@ -20,9 +25,30 @@ type Matrix struct {
 	Size        int
 	Colors      [][]packets.LightHsbk
 	ChainLength int
+	// FrameBuffers reports whether the device supports hidden frame buffers, see device.MatrixProperties.
+	FrameBuffers bool
+	// Interpolation configures the transitions between the frames of the effects.
+	Interpolation Interpolation
+	// Stagger is the delay between the starts of an effect on consecutive tiles in ChainModeStaggered,
+	// 500ms if not set.
+	Stagger time.Duration
+
+	// brightness and gamma correct the colors output by Flatten, see SetGlobalBrightness and SetGamma.
+	brightness float64
+	gamma      float64
+
+	// lastFrames holds the last frame sent by the effects by first tile index, to interpolate from.
+	lastFrames map[int][]packets.LightHsbk
 }
 
-// New creates a Matrix of the given size and chain length.
+// NewFromDevice creates a Matrix with the size, chain length and frame buffer support of the device.
+func NewFromDevice(p device.MatrixProperties) *Matrix {
+	m := New(p.Width, p.Height, p.ChainLength)
+	m.FrameBuffers = p.FrameBuffers
+	return m
+}
+
+// New creates a Matrix of the given size and chain length, for a device supporting frame buffers.
 func New(width, height, chainLength int) *Matrix {
 	colors := make([][]packets.LightHsbk, height)
 	for i := range colors {
@@ -30,11 +56,14 @@ func New(width, height, chainLength int) *Matrix {
 	}
 
 	return &Matrix{
-		Width:       width,
-		Height:      height,
-		Size:        int(width * height),
-		Colors:      colors,
-		ChainLength: chainLength,
+		Width:        width,
+		Height:       height,
+		Size:         int(width * height),
+		Colors:       colors,
+		ChainLength:  chainLength,
+		FrameBuffers: true,
+		brightness:   100,
+		gamma:        1,
 	}
 }
 
@@ -176,30 +205,83 @@ func (m *Matrix) MaxPadding() int {
 	return min(m.MaxX()/2, m.MaxY()/2)
 }
 
+// SetGlobalBrightness scales the brightness of the colors output by Flatten and FlattenColors to the
+// given percentage, between 0 and 100, so that effects and images can be dimmed without changing
+// their palettes. It defaults to 100.
+func (m *Matrix) SetGlobalBrightness(percent float64) {
+	m.brightness = min(max(percent, 0), 100)
+}
+
+// SetGamma sets the gamma correction applied to the brightness of the colors output by Flatten
+// and FlattenColors, before the global brightness, see messages.ScaleBrightness.
+// It defaults to 1, leaving the colors unchanged, which values not above 0 restore.
+func (m *Matrix) SetGamma(gamma float64) {
+	if gamma <= 0 {
+		gamma = 1
+	}
+	m.gamma = gamma
+}
+
 // FlattenColors converts the Colors' matrix into a 64-byte array that can be
 // used with the LIFX protocol.
+// Matrices of more than 64 zones are truncated to the rows fitting in the first page, see FlattenPages.
 // DEPRECATED Use Flatten instead.
 func (m *Matrix) FlattenColors() [64]packets.LightHsbk {
-	var colors [64]packets.LightHsbk
-	for y := range m.Height {
-		for x := range m.Width {
-			colors[y*m.Width+x] = m.Colors[y][x]
-		}
+	pages := m.FlattenPages()
+	if len(pages) == 0 {
+		return [64]packets.LightHsbk{}
 	}
-	return colors
+	return pages[0]
+}
+
+// FlattenPages splits the flattened colors into pages of up to 64 colors, one per TileSet64 message.
+// Each page holds the whole rows fitting in a message, as given by device.MatrixRowsPerPacket, so that
+// page i starts at row i*rows. Matrices wider than 64 zones are split every 64 colors.
+func (m *Matrix) FlattenPages() [][64]packets.LightHsbk {
+	colors := m.Flatten()
+	pageSize := device.ZonesPerTilePacket
+	if rows, err := device.MatrixRowsPerPacket(m.Width); err == nil {
+		pageSize = rows * m.Width
+	}
+
+	var pages [][64]packets.LightHsbk
+	for offset := 0; offset < len(colors); offset += pageSize {
+		var page [64]packets.LightHsbk
+		copy(page[:], colors[offset:min(len(colors), offset+pageSize)])
+		pages = append(pages, page)
+	}
+	return pages
 }
 
-// Flatten flattens the matrix into a slice of LightHsbk colors.
+// Flatten flattens the matrix into a slice of LightHsbk colors,
+// corrected by the global brightness and gamma.
 func (m *Matrix) Flatten() []packets.LightHsbk {
 	colors := make([]packets.LightHsbk, m.Height*m.Width)
 	for y := range m.Height {
 		for x := range m.Width {
-			colors[y*m.Width+x] = m.Colors[y][x]
+			colors[y*m.Width+x] = m.correct(m.Colors[y][x])
 		}
 	}
 	return colors
 }
 
+// correct returns c with the global brightness and gamma applied.
+func (m *Matrix) correct(c packets.LightHsbk) packets.LightHsbk {
+	if m.brightness == 100 && m.gamma == 1 {
+		return c
+	}
+	return messages.ScaleBrightness(c, m.brightness, m.gamma)
+}
+
 func (m *Matrix) ParseColors(colors [64]packets.LightHsbk) {
 	m.SetColors(0, 0, colors[:]...)
 }
+
+// colorsMessages returns the messages setting the given colors on the tiles from mIdx, loading them into
+// a hidden frame buffer when they need more than one message and the device supports frame buffers.
+func (m *Matrix) colorsMessages(mIdx, mLength int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	if m.FrameBuffers {
+		return messages.SetMatrixColorsFromSlice(mIdx, mLength, m.Width, colors, d)
+	}
+	return messages.SetMatrixVisibleColors(mIdx, mLength, m.Width, colors, d)
+}
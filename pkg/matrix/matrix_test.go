@@ -3,6 +3,7 @@ package matrix
 import (
 	"testing"
 
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/stretchr/testify/assert"
 )
@@ -326,6 +327,16 @@ func TestSetXForAlignment(t *testing.T) {
 	}
 }
 
+func TestNewFromDevice(t *testing.T) {
+	m := NewFromDevice(device.MatrixProperties{Width: 8, Height: 8, NZones: 64, ChainLength: 5})
+	assert.Equal(t, 64, m.Size)
+	assert.Equal(t, 5, m.ChainLength)
+	assert.Len(t, m.Colors, 8)
+	assert.False(t, m.FrameBuffers)
+
+	assert.True(t, New(16, 8, 1).FrameBuffers)
+}
+
 func TestNewColorsSlice(t *testing.T) {
 	testCases := map[string]struct {
 		length  int
@@ -627,6 +638,19 @@ func TestFlattenColors(t *testing.T) {
 				{}, color0,
 			},
 		},
+		"irregular: odd width": {
+			matrix: New(7, 9, 0),
+			setter: func(m *Matrix) { m.SetColors(6, 8, color0) },
+			want:   [64]packets.LightHsbk{62: color0},
+		},
+		"more than 64 zones: first page": {
+			matrix: New(16, 8, 0),
+			setter: func(m *Matrix) {
+				m.SetColors(15, 3, color0)
+				m.SetColors(0, 4, color0)
+			},
+			want: [64]packets.LightHsbk{63: color0},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -637,6 +661,55 @@ func TestFlattenColors(t *testing.T) {
 	}
 }
 
+func TestFlattenPages(t *testing.T) {
+	color0 := packets.LightHsbk{Kelvin: 3500}
+	testCases := map[string]struct {
+		matrix *Matrix
+		setter func(m *Matrix)
+		want   [][64]packets.LightHsbk
+	}{
+		"single page": {
+			matrix: New(8, 8, 1),
+			setter: func(m *Matrix) { m.SetColors(7, 7, color0) },
+			want:   [][64]packets.LightHsbk{{63: color0}},
+		},
+		"irregular: 16x8": {
+			matrix: New(16, 8, 1),
+			setter: func(m *Matrix) {
+				m.SetColors(15, 3, color0)
+				m.SetColors(1, 4, color0)
+			},
+			want: [][64]packets.LightHsbk{{63: color0}, {1: color0}},
+		},
+		"irregular: rows not filling a page": {
+			matrix: New(12, 7, 1),
+			setter: func(m *Matrix) {
+				m.SetColors(11, 4, color0)
+				m.SetColors(0, 5, color0)
+				m.SetColors(11, 6, color0)
+			},
+			want: [][64]packets.LightHsbk{{59: color0}, {0: color0, 23: color0}},
+		},
+		"irregular: larger height": {
+			matrix: New(4, 20, 1),
+			setter: func(m *Matrix) { m.SetColors(0, 16, color0) },
+			want:   [][64]packets.LightHsbk{{}, {0: color0}},
+		},
+		"wider than a page": {
+			matrix: New(70, 1, 1),
+			setter: func(m *Matrix) { m.SetColors(69, 0, color0) },
+			want:   [][64]packets.LightHsbk{{}, {5: color0}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tc.setter(tc.matrix)
+			assert.Equal(t, tc.want, tc.matrix.FlattenPages())
+		})
+	}
+}
+
 func TestFlatten(t *testing.T) {
 	color0 := packets.LightHsbk{Kelvin: 3500}
 	testCases := map[string]struct {
@@ -710,6 +783,37 @@ func TestFlatten(t *testing.T) {
 	}
 }
 
+func TestBrightnessCorrection(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 32768, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		brightness float64
+		gamma      float64
+		want       uint16
+	}{
+		"Default":                {brightness: 100, gamma: 1, want: 32768},
+		"Dimmed":                 {brightness: 25, gamma: 1, want: 8192},
+		"Clamped brightness":     {brightness: -10, gamma: 1, want: 0},
+		"Gamma corrected":        {brightness: 100, gamma: 2, want: 16384},
+		"Gamma corrected dimmed": {brightness: 50, gamma: 2, want: 8192},
+		"Gamma reset":            {brightness: 100, gamma: -1, want: 32768},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(8, 8, 1)
+			m.SetColors(0, 0, color)
+			m.SetGlobalBrightness(tc.brightness)
+			m.SetGamma(tc.gamma)
+
+			assert.InDelta(t, tc.want, m.Flatten()[0].Brightness, 1)
+			assert.InDelta(t, tc.want, m.FlattenColors()[0].Brightness, 1)
+			// The palette of the matrix is unchanged.
+			assert.Equal(t, color, m.Colors[0][0])
+		})
+	}
+}
+
 func TestParseColors(t *testing.T) {
 	testCases := map[string]struct {
 		matrix *Matrix
@@ -0,0 +1,60 @@
+package matrix
+
+import (
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// Remap returns a copy of the matrix rotated to be displayed upright on a tile
+// with the given orientation, e.g. as reported in device.MatrixProperties.ChainOrientations.
+// Width and height are swapped for tiles rotated left or right.
+func (m *Matrix) Remap(o device.Orientation) *Matrix {
+	width, height := m.Width, m.Height
+	if o == device.OrientationLeft || o == device.OrientationRight {
+		width, height = height, width
+	}
+
+	out := New(width, height, m.ChainLength)
+	out.SetColors(0, 0, device.ReorientMatrix(m.Width, m.Height, o, m.Flatten())...)
+	return out
+}
+
+// OrientSend wraps send so that frames sent to a chain of square tiles of the given size
+// are rotated according to the orientation of each tile, so that effects render upright
+// on rotated or upside-down tiles.
+// Frames addressed to tiles with different orientations are split into one message per tile.
+// Messages other than whole tile TileSet64 frames are sent unchanged.
+func OrientSend(send SendFunc, width, height int, orientations []device.Orientation) SendFunc {
+	return func(msg *protocol.Message) error {
+		p, ok := msg.Payload.(*packets.TileSet64)
+		if !ok || width != height || width*height > len(p.Colors) || p.Rect.X != 0 || p.Rect.Y != 0 {
+			return send(msg)
+		}
+
+		orientation := func(i int) device.Orientation {
+			if i < len(orientations) {
+				return orientations[i]
+			}
+			return device.OrientationRightSideUp
+		}
+
+		start, end := int(p.TileIndex), int(p.TileIndex)+max(int(p.Length), 1)
+		for i := start; i < end; {
+			// Group consecutive tiles with the same orientation in a single message.
+			o, j := orientation(i), i+1
+			for j < end && orientation(j) == o {
+				j++
+			}
+
+			tp := *p
+			tp.TileIndex, tp.Length = uint8(i), uint8(j-i)
+			copy(tp.Colors[:], device.ReorientMatrix(width, height, o, p.Colors[:width*height]))
+			if err := send(protocol.NewMessage(&tp)); err != nil {
+				return err
+			}
+			i = j
+		}
+		return nil
+	}
+}
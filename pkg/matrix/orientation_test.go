@@ -0,0 +1,111 @@
+package matrix
+
+import (
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemap(t *testing.T) {
+	c1, c2, c3, c4 := packets.LightHsbk{Kelvin: 1}, packets.LightHsbk{Kelvin: 2}, packets.LightHsbk{Kelvin: 3}, packets.LightHsbk{Kelvin: 4}
+
+	testCases := map[string]struct {
+		orientation device.Orientation
+		want        [][]packets.LightHsbk
+	}{
+		"right side up": {
+			orientation: device.OrientationRightSideUp,
+			want:        [][]packets.LightHsbk{{c1, c2}, {c3, c4}},
+		},
+		"face up": {
+			orientation: device.OrientationFaceUp,
+			want:        [][]packets.LightHsbk{{c1, c2}, {c3, c4}},
+		},
+		"upside down": {
+			orientation: device.OrientationUpsideDown,
+			want:        [][]packets.LightHsbk{{c4, c3}, {c2, c1}},
+		},
+		"left": {
+			orientation: device.OrientationLeft,
+			want:        [][]packets.LightHsbk{{c3, c1}, {c4, c2}},
+		},
+		"right": {
+			orientation: device.OrientationRight,
+			want:        [][]packets.LightHsbk{{c2, c4}, {c1, c3}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(2, 2, 1)
+			m.SetColors(0, 0, c1, c2, c3, c4)
+
+			got := m.Remap(tc.orientation)
+			assert.Equal(t, tc.want, got.Colors)
+			// The original matrix is left unchanged.
+			assert.Equal(t, [][]packets.LightHsbk{{c1, c2}, {c3, c4}}, m.Colors)
+		})
+	}
+
+	t.Run("swaps size when rotated sideways", func(t *testing.T) {
+		got := New(3, 2, 1).Remap(device.OrientationLeft)
+		assert.Equal(t, 2, got.Width)
+		assert.Equal(t, 3, got.Height)
+		assert.Equal(t, 6, got.Size)
+	})
+}
+
+func TestOrientSend(t *testing.T) {
+	c1, c2, c3, c4 := packets.LightHsbk{Kelvin: 1}, packets.LightHsbk{Kelvin: 2}, packets.LightHsbk{Kelvin: 3}, packets.LightHsbk{Kelvin: 4}
+	frame := func(index, length uint8, colors ...packets.LightHsbk) *packets.TileSet64 {
+		p := &packets.TileSet64{TileIndex: index, Length: length, Rect: packets.TileBufferRect{Width: 2}}
+		copy(p.Colors[:], colors)
+		return p
+	}
+
+	testCases := map[string]struct {
+		orientations []device.Orientation
+		msg          packets.Payload
+		want         []packets.Payload
+	}{
+		"groups tiles with the same orientation": {
+			orientations: []device.Orientation{device.OrientationRightSideUp, device.OrientationUpsideDown, device.OrientationUpsideDown},
+			msg:          frame(0, 3, c1, c2, c3, c4),
+			want: []packets.Payload{
+				frame(0, 1, c1, c2, c3, c4),
+				frame(1, 2, c4, c3, c2, c1),
+			},
+		},
+		"single tile": {
+			orientations: []device.Orientation{device.OrientationRightSideUp, device.OrientationLeft},
+			msg:          frame(1, 1, c1, c2, c3, c4),
+			want:         []packets.Payload{frame(1, 1, c3, c1, c4, c2)},
+		},
+		"unknown orientations are upright": {
+			msg:  frame(0, 1, c1, c2, c3, c4),
+			want: []packets.Payload{frame(0, 1, c1, c2, c3, c4)},
+		},
+		"other messages are unchanged": {
+			orientations: []device.Orientation{device.OrientationUpsideDown},
+			msg:          &packets.TileCopyFrameBuffer{Length: 1},
+			want:         []packets.Payload{&packets.TileCopyFrameBuffer{Length: 1}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var got []packets.Payload
+			send := OrientSend(func(msg *protocol.Message) error {
+				got = append(got, msg.Payload)
+				return nil
+			}, 2, 2, tc.orientations)
+
+			require.NoError(t, send(protocol.NewMessage(tc.msg)))
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -0,0 +1,260 @@
+package matrix
+
+import (
+	"context"
+	"time"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// Default effect parameters set by NewEffectParams.
+const (
+	defaultEffectInterval = 100 * time.Millisecond
+	defaultEffectSize     = 3
+)
+
+// EffectParams holds the parameters shared by the matrix effects.
+// Each effect reads the parameters it supports and ignores the others.
+type EffectParams struct {
+	// Interval is the time between frames.
+	Interval time.Duration
+	// Cycles is the number of times the effect repeats, 0 repeating it indefinitely.
+	Cycles int
+	// Mode selects the tiles of a chain the effect runs on.
+	Mode ChainMode
+	// Direction is the direction of ConcentricFramesEffect.
+	Direction AnimationDirection
+	// Palette is the colors of the effect. Effects drawing a single color use the first one.
+	Palette []packets.LightHsbk
+	// Brightness scales the brightness of the palette, between 0 and 1.
+	Brightness float64
+	// Size is the number of pixels of WormEffect and SnakeEffect.
+	Size int
+	// Speed and Intensity tune the procedural effects, see Plasma.
+	Speed     float64
+	Intensity float64
+	// Pattern is the initial generation of LifeEffect, see Life.
+	Pattern []string
+	// Interpolation, if its Easing is set, overrides the Interpolation of the matrix while
+	// the effect runs.
+	Interpolation Interpolation
+	// Stagger, if set, overrides the Stagger of the matrix while the effect runs.
+	Stagger time.Duration
+	// Text, ScrollDirection and Font configure ScrollTextEffect, see ScrollText.
+	Text            string
+	ScrollDirection ScrollDirection
+	Font            *Font
+	// ClockLayout configures ClockEffect, see Clock.
+	ClockLayout ClockLayout
+}
+
+// EffectOption sets a parameter of an effect.
+type EffectOption func(*EffectParams)
+
+// NewEffectParams returns the default effect parameters, an interval of 100ms repeating
+// indefinitely on the first tile at full brightness, with the given options applied.
+func NewEffectParams(opts ...EffectOption) EffectParams {
+	p := EffectParams{
+		Interval:   defaultEffectInterval,
+		Brightness: 1,
+		Size:       defaultEffectSize,
+		Speed:      1,
+		Intensity:  1,
+	}
+	for _, opt := range opts {
+		opt(&p)
+	}
+	return p
+}
+
+// WithInterval sets the time between frames.
+func WithInterval(d time.Duration) EffectOption {
+	return func(p *EffectParams) { p.Interval = d }
+}
+
+// WithCycles sets the number of times the effect repeats, 0 repeating it indefinitely.
+func WithCycles(n int) EffectOption {
+	return func(p *EffectParams) { p.Cycles = n }
+}
+
+// WithChainMode sets the tiles of a chain the effect runs on.
+func WithChainMode(mode ChainMode) EffectOption {
+	return func(p *EffectParams) { p.Mode = mode }
+}
+
+// WithDirection sets the direction of the effect.
+func WithDirection(direction AnimationDirection) EffectOption {
+	return func(p *EffectParams) { p.Direction = direction }
+}
+
+// WithPalette sets the colors of the effect.
+func WithPalette(colors ...packets.LightHsbk) EffectOption {
+	return func(p *EffectParams) { p.Palette = colors }
+}
+
+// WithBrightness scales the brightness of the palette, between 0 and 1.
+func WithBrightness(f float64) EffectOption {
+	return func(p *EffectParams) { p.Brightness = f }
+}
+
+// WithSize sets the number of pixels of the effect.
+func WithSize(n int) EffectOption {
+	return func(p *EffectParams) { p.Size = n }
+}
+
+// WithSpeed sets the speed of the procedural effects.
+func WithSpeed(speed float64) EffectOption {
+	return func(p *EffectParams) { p.Speed = speed }
+}
+
+// WithIntensity sets the intensity of the procedural effects.
+func WithIntensity(intensity float64) EffectOption {
+	return func(p *EffectParams) { p.Intensity = intensity }
+}
+
+// WithPattern sets the initial generation of the Game of Life.
+func WithPattern(pattern ...string) EffectOption {
+	return func(p *EffectParams) { p.Pattern = pattern }
+}
+
+// WithStagger sets the delay between the starts of the effect on consecutive tiles in ChainModeStaggered.
+func WithStagger(d time.Duration) EffectOption {
+	return func(p *EffectParams) { p.Stagger = d }
+}
+
+// WithText sets the text of the effect and the direction it scrolls in.
+func WithText(text string, direction ScrollDirection) EffectOption {
+	return func(p *EffectParams) { p.Text, p.ScrollDirection = text, direction }
+}
+
+// WithFont sets the font of the text.
+func WithFont(font *Font) EffectOption {
+	return func(p *EffectParams) { p.Font = font }
+}
+
+// WithClockLayout sets how the clock renders the time.
+func WithClockLayout(layout ClockLayout) EffectOption {
+	return func(p *EffectParams) { p.ClockLayout = layout }
+}
+
+// intervalMs returns the interval in milliseconds, as taken by the effect functions.
+func (p EffectParams) intervalMs() int64 {
+	return p.Interval.Milliseconds()
+}
+
+// palette returns the palette with its brightness scaled.
+func (p EffectParams) palette() []packets.LightHsbk {
+	palette := make([]packets.LightHsbk, len(p.Palette))
+	for i, c := range p.Palette {
+		palette[i] = scaleBrightness(c, p.Brightness)
+	}
+	return palette
+}
+
+// color returns the first color of the palette with its brightness scaled.
+func (p EffectParams) color() (packets.LightHsbk, error) {
+	if len(p.Palette) == 0 {
+		return packets.LightHsbk{}, ErrMissingColors
+	}
+	return scaleBrightness(p.Palette[0], p.Brightness), nil
+}
+
+// Effect runs a matrix effect with the given parameters until it completes or ctx is cancelled.
+// Unlike the positional effect functions, its signature does not change when effects gain
+// parameters, e.g.
+//
+//	p := matrix.NewEffectParams(matrix.WithInterval(50*time.Millisecond), matrix.WithPalette(colors...))
+//	err := matrix.WaterfallEffect(ctx, m, send, p)
+type Effect func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error
+
+// Bind returns an EffectFunc running the effect on m with the default parameters and the
+// given options, e.g. to be started with an EffectRunner.
+func (e Effect) Bind(m *Matrix, opts ...EffectOption) EffectFunc {
+	p := NewEffectParams(opts...)
+	return func(ctx context.Context, send SendFunc) error {
+		return e(ctx, m, send, p)
+	}
+}
+
+// newEffect returns an Effect running run with the Interpolation and Stagger of the params, if set.
+func newEffect(run func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error) Effect {
+	return func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		if p.Interpolation.Easing != nil {
+			defer func(i Interpolation) { m.Interpolation = i }(m.Interpolation)
+			m.Interpolation = p.Interpolation
+		}
+		if p.Stagger > 0 {
+			defer func(d time.Duration) { m.Stagger = d }(m.Stagger)
+			m.Stagger = p.Stagger
+		}
+		return run(ctx, m, send, p)
+	}
+}
+
+// The matrix effects taking EffectParams.
+var (
+	// WaterfallEffect runs WaterfallCtx.
+	WaterfallEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return WaterfallCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.palette()...)
+	})
+	// RocketsEffect runs RocketsCtx.
+	RocketsEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return RocketsCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.palette()...)
+	})
+	// WormEffect runs WormCtx with the first color of the palette.
+	WormEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		color, err := p.color()
+		if err != nil {
+			return err
+		}
+		return WormCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Size, color)
+	})
+	// SnakeEffect runs SnakeCtx with the first color of the palette.
+	SnakeEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		color, err := p.color()
+		if err != nil {
+			return err
+		}
+		return SnakeCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Size, color)
+	})
+	// ConcentricFramesEffect runs ConcentricFramesCtx.
+	ConcentricFramesEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return ConcentricFramesCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Direction, p.palette()...)
+	})
+	// PlasmaEffect runs PlasmaCtx.
+	PlasmaEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return PlasmaCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Speed, p.Intensity, p.palette()...)
+	})
+	// FireEffect runs FireCtx.
+	FireEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return FireCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Speed, p.Intensity, p.palette()...)
+	})
+	// SparkleEffect runs SparkleCtx.
+	SparkleEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return SparkleCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Speed, p.Intensity, p.palette()...)
+	})
+	// RainEffect runs RainCtx.
+	RainEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return RainCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Speed, p.Intensity, p.palette()...)
+	})
+	// LifeEffect runs LifeCtx.
+	LifeEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return LifeCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.Pattern, p.palette()...)
+	})
+	// ScrollTextEffect runs ScrollTextCtx with the first color of the palette.
+	ScrollTextEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		color, err := p.color()
+		if err != nil {
+			return err
+		}
+		return ScrollTextCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.ScrollDirection, p.Font, p.Text, color)
+	})
+	// ClockEffect runs ClockCtx.
+	ClockEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return ClockCtx(ctx, m, send, p.ClockLayout, p.palette()...)
+	})
+	// MazeEffect runs MazeCtx.
+	MazeEffect = newEffect(func(ctx context.Context, m *Matrix, send SendFunc, p EffectParams) error {
+		return MazeCtx(ctx, m, send, p.intervalMs(), p.Cycles, p.Mode, p.palette()...)
+	})
+)
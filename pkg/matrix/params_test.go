@@ -0,0 +1,151 @@
+package matrix
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewEffectParams(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		opts []EffectOption
+		want EffectParams
+	}{
+		"Defaults": {
+			want: EffectParams{Interval: 100 * time.Millisecond, Brightness: 1, Size: 3, Speed: 1, Intensity: 1},
+		},
+		"Options": {
+			opts: []EffectOption{
+				WithInterval(50 * time.Millisecond), WithCycles(2), WithChainMode(ChainModeSynced),
+				WithDirection(AnimationDirectionOutIn), WithPalette(color), WithBrightness(0.5),
+				WithSize(4), WithSpeed(2), WithIntensity(0.3), WithPattern(".#", "#."),
+			},
+			want: EffectParams{
+				Interval: 50 * time.Millisecond, Cycles: 2, Mode: ChainModeSynced,
+				Direction: AnimationDirectionOutIn, Palette: []packets.LightHsbk{color}, Brightness: 0.5,
+				Size: 4, Speed: 2, Intensity: 0.3, Pattern: []string{".#", "#."},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, NewEffectParams(tc.opts...))
+		})
+	}
+}
+
+func TestEffectParamsPalette(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 40000, Kelvin: 3500}
+	p := NewEffectParams(WithPalette(color), WithBrightness(0.5))
+
+	assert.Equal(t, []packets.LightHsbk{{Hue: 1000, Saturation: 65535, Brightness: 20000, Kelvin: 3500}}, p.palette())
+	// The palette set by the caller is left unchanged.
+	assert.Equal(t, uint16(40000), p.Palette[0].Brightness)
+}
+
+func TestEffects(t *testing.T) {
+	var (
+		red  = packets.LightHsbk{Hue: 0, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		blue = packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	)
+	opts := []EffectOption{WithInterval(0), WithCycles(1), WithChainMode(ChainModeSequential), WithPalette(red, blue)}
+
+	testCases := map[string]struct {
+		effect Effect
+		run    func(m *Matrix, send SendFunc) error
+	}{
+		"Waterfall": {
+			effect: WaterfallEffect,
+			run: func(m *Matrix, send SendFunc) error {
+				return Waterfall(m, send, 0, 1, ChainModeSequential, red, blue)
+			},
+		},
+		"Worm": {
+			effect: WormEffect,
+			run: func(m *Matrix, send SendFunc) error {
+				return Worm(m, send, 0, 1, ChainModeSequential, 3, red)
+			},
+		},
+		"Snake": {
+			effect: SnakeEffect,
+			run: func(m *Matrix, send SendFunc) error {
+				return Snake(m, send, 0, 1, ChainModeSequential, 3, red)
+			},
+		},
+		"ConcentricFrames": {
+			effect: ConcentricFramesEffect,
+			run: func(m *Matrix, send SendFunc) error {
+				return ConcentricFrames(m, send, 0, 1, ChainModeSequential, AnimationDirectionInwards, red, blue)
+			},
+		},
+		"Plasma": {
+			effect: PlasmaEffect,
+			run: func(m *Matrix, send SendFunc) error {
+				return Plasma(m, send, 0, 1, ChainModeSequential, 1, 1, red, blue)
+			},
+		},
+	}
+
+	record := func(payloads *[]any) SendFunc {
+		return func(msg *protocol.Message) error {
+			*payloads = append(*payloads, msg.Payload)
+			return nil
+		}
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var want, got []any
+			require.NoError(t, tc.run(New(8, 8, 2), record(&want)))
+			require.NoError(t, tc.effect(context.Background(), New(8, 8, 2), record(&got), NewEffectParams(opts...)))
+			assert.Equal(t, want, got)
+		})
+	}
+
+	t.Run("Requires a palette", func(t *testing.T) {
+		for _, effect := range []Effect{WormEffect, SnakeEffect, WaterfallEffect} {
+			err := effect(context.Background(), New(8, 8, 1), nil, NewEffectParams())
+			assert.ErrorIs(t, err, ErrMissingColors)
+		}
+	})
+
+	t.Run("Scales the palette brightness", func(t *testing.T) {
+		var lit int
+		send := func(msg *protocol.Message) error {
+			for _, c := range msg.Payload.(*packets.TileSet64).Colors {
+				if c.Brightness > 0 {
+					lit++
+					assert.Equal(t, uint16(32767), c.Brightness)
+				}
+			}
+			return nil
+		}
+		p := NewEffectParams(WithInterval(0), WithCycles(1), WithSize(1), WithPalette(red), WithBrightness(0.5))
+		require.NoError(t, WormEffect(context.Background(), New(8, 8, 1), send, p))
+		assert.NotZero(t, lit)
+	})
+}
+
+func TestEffectBind(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	fn := PlasmaEffect.Bind(New(8, 8, 1), WithInterval(0), WithPalette(color))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var sent int
+	err := fn(ctx, func(*protocol.Message) error {
+		if sent++; sent == 10 {
+			cancel()
+		}
+		return nil
+	})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 10, sent)
+}
@@ -0,0 +1,131 @@
+package matrix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidChainMode is returned when parsing an unknown chain mode.
+	ErrInvalidChainMode = errors.New("invalid chain mode")
+	// ErrInvalidAnimationDirection is returned when parsing an unknown animation direction.
+	ErrInvalidAnimationDirection = errors.New("invalid animation direction")
+)
+
+// chainModeNames and animationDirectionNames are the names of the modes and directions, by value.
+var (
+	chainModeNames          = [...]string{"none", "sequential", "synced", "staggered"}
+	animationDirectionNames = [...]string{"inwards", "outwards", "in-out", "out-in"}
+)
+
+// String returns the name of the mode, e.g. "synced".
+func (m ChainMode) String() string {
+	if m < 0 || int(m) >= len(chainModeNames) {
+		return fmt.Sprintf("ChainMode(%d)", int(m))
+	}
+	return chainModeNames[m]
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the mode by name.
+func (m ChainMode) MarshalText() ([]byte, error) {
+	if _, err := ChainModeFromInt(int(m)); err != nil {
+		return nil, err
+	}
+	return []byte(m.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see ParseChainModeString.
+func (m *ChainMode) UnmarshalText(text []byte) error {
+	mode, err := ParseChainModeString(string(text))
+	if err != nil {
+		return err
+	}
+	*m = mode
+	return nil
+}
+
+// ChainModeFromInt returns the mode with the given value, or ErrInvalidChainMode if there is none.
+func ChainModeFromInt(n int) (ChainMode, error) {
+	if n < 0 || n >= len(chainModeNames) {
+		return ChainModeNone, fmt.Errorf("%w: %d", ErrInvalidChainMode, n)
+	}
+	return ChainMode(n), nil
+}
+
+// ParseChainModeString returns the mode with the given name, e.g. "synced", or value, e.g. "2".
+// Names are case insensitive. It returns ErrInvalidChainMode for unknown modes.
+func ParseChainModeString(s string) (ChainMode, error) {
+	n, ok := parseName(s, chainModeNames[:])
+	if !ok {
+		return ChainModeNone, fmt.Errorf("%w: %q", ErrInvalidChainMode, s)
+	}
+	return ChainMode(n), nil
+}
+
+// String returns the name of the direction, e.g. "in-out".
+func (d AnimationDirection) String() string {
+	if d < 0 || int(d) >= len(animationDirectionNames) {
+		return fmt.Sprintf("AnimationDirection(%d)", int(d))
+	}
+	return animationDirectionNames[d]
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the direction by name.
+func (d AnimationDirection) MarshalText() ([]byte, error) {
+	if _, err := AnimationDirectionFromInt(int(d)); err != nil {
+		return nil, err
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, see ParseAnimationDirectionString.
+func (d *AnimationDirection) UnmarshalText(text []byte) error {
+	direction, err := ParseAnimationDirectionString(string(text))
+	if err != nil {
+		return err
+	}
+	*d = direction
+	return nil
+}
+
+// AnimationDirectionFromInt returns the direction with the given value, or ErrInvalidAnimationDirection
+// if there is none.
+func AnimationDirectionFromInt(n int) (AnimationDirection, error) {
+	if n < 0 || n >= len(animationDirectionNames) {
+		return AnimationDirectionInwards, fmt.Errorf("%w: %d", ErrInvalidAnimationDirection, n)
+	}
+	return AnimationDirection(n), nil
+}
+
+// ParseAnimationDirectionString returns the direction with the given name, e.g. "out-in", or value,
+// e.g. "3". Names are case insensitive and may be written with underscores or without separators,
+// e.g. "OUT_IN" or "outin". It returns ErrInvalidAnimationDirection for unknown directions.
+func ParseAnimationDirectionString(s string) (AnimationDirection, error) {
+	n, ok := parseName(s, animationDirectionNames[:])
+	if !ok {
+		return AnimationDirectionInwards, fmt.Errorf("%w: %q", ErrInvalidAnimationDirection, s)
+	}
+	return AnimationDirection(n), nil
+}
+
+// parseName returns the index of the name matching s, ignoring case and separators,
+// or the value of s if it is a valid index.
+func parseName(s string, names []string) (int, bool) {
+	if n, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return n, n >= 0 && n < len(names)
+	}
+	key := normalizeName(s)
+	for i, name := range names {
+		if normalizeName(name) == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// normalizeName lowercases s and removes its separators.
+func normalizeName(s string) string {
+	return strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(s)))
+}
@@ -0,0 +1,97 @@
+package matrix
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseChainModeString(t *testing.T) {
+	testCases := map[string]struct {
+		value   string
+		want    ChainMode
+		wantErr error
+	}{
+		"name":           {value: "synced", want: ChainModeSynced},
+		"case":           {value: " Staggered ", want: ChainModeStaggered},
+		"value":          {value: "1", want: ChainModeSequential},
+		"unknown name":   {value: "random", wantErr: ErrInvalidChainMode},
+		"unknown value":  {value: "4", wantErr: ErrInvalidChainMode},
+		"negative value": {value: "-1", wantErr: ErrInvalidChainMode},
+		"empty":          {value: "", wantErr: ErrInvalidChainMode},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseChainModeString(tc.value)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestParseAnimationDirectionString(t *testing.T) {
+	testCases := map[string]struct {
+		value   string
+		want    AnimationDirection
+		wantErr error
+	}{
+		"name":          {value: "outwards", want: AnimationDirectionOutwards},
+		"hyphen":        {value: "in-out", want: AnimationDirectionInOut},
+		"underscore":    {value: "OUT_IN", want: AnimationDirectionOutIn},
+		"no separator":  {value: "outin", want: AnimationDirectionOutIn},
+		"value":         {value: "3", want: AnimationDirectionOutIn},
+		"unknown name":  {value: "sideways", wantErr: ErrInvalidAnimationDirection},
+		"unknown value": {value: "4", wantErr: ErrInvalidAnimationDirection},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := ParseAnimationDirectionString(tc.value)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+func TestFromInt(t *testing.T) {
+	for n, want := range []ChainMode{ChainModeNone, ChainModeSequential, ChainModeSynced, ChainModeStaggered} {
+		got, err := ChainModeFromInt(n)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ChainModeFromInt(4)
+	assert.ErrorIs(t, err, ErrInvalidChainMode)
+
+	for n, want := range []AnimationDirection{AnimationDirectionInwards, AnimationDirectionOutwards, AnimationDirectionInOut, AnimationDirectionOutIn} {
+		got, err := AnimationDirectionFromInt(n)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err = AnimationDirectionFromInt(-1)
+	assert.ErrorIs(t, err, ErrInvalidAnimationDirection)
+}
+
+func TestModesText(t *testing.T) {
+	type settings struct {
+		Mode      ChainMode          `json:"mode"`
+		Direction AnimationDirection `json:"direction"`
+	}
+
+	b, err := json.Marshal(settings{Mode: ChainModeSynced, Direction: AnimationDirectionOutIn})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"mode":"synced","direction":"out-in"}`, string(b))
+
+	var s settings
+	require.NoError(t, json.Unmarshal([]byte(`{"mode":"staggered","direction":"in_out"}`), &s))
+	assert.Equal(t, settings{Mode: ChainModeStaggered, Direction: AnimationDirectionInOut}, s)
+
+	assert.ErrorIs(t, json.Unmarshal([]byte(`{"mode":"random"}`), &s), ErrInvalidChainMode)
+	_, err = json.Marshal(settings{Mode: ChainMode(9)})
+	assert.ErrorIs(t, err, ErrInvalidChainMode)
+
+	assert.Equal(t, "ChainMode(9)", ChainMode(9).String())
+	assert.Equal(t, "AnimationDirection(-1)", AnimationDirection(-1).String())
+}
@@ -0,0 +1,353 @@
+package matrix
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+const (
+	// proceduralCycleFrames is the number of frames of a cycle of the procedural effects.
+	proceduralCycleFrames = 100
+
+	// plasmaScale is the noise frequency per pixel, and plasmaStep how far the noise moves in
+	// time on each frame at speed 1.
+	plasmaScale = 0.25
+	plasmaStep  = 0.05
+	// fireCooling is the most heat a pixel loses as it rises by a row.
+	fireCooling = 0.2
+	// sparkleRate is the chance of a pixel sparkling on a frame at full intensity, and
+	// sparkleDecay the brightness a sparkle loses on each frame at speed 1.
+	sparkleRate  = 0.1
+	sparkleDecay = 0.15
+	// rainRate is the chance of a drop starting in a column on a step at full intensity,
+	// and rainFade the fraction of brightness a trail keeps on each step.
+	rainRate = 0.3
+	rainFade = 0.6
+)
+
+// Plasma renders a Perlin noise field slowly drifting over time, mapping its values onto the palette.
+// Speed scales how fast the field drifts, 1 being the default, and intensity, between 0 and 1,
+// the brightness of the colors.
+// A cycle lasts 100 frames, sent every interval. It repeats for n cycles, if cycles is set to 0
+// it repeats indefinitely.
+func Plasma(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	return PlasmaCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, speed, intensity, palette...)
+}
+
+// PlasmaCtx is like Plasma but stops when ctx is cancelled, returning the context error.
+func PlasmaCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	cycle, err := plasmaCycle(m, speed, intensity, palette)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func plasmaCycle(m *Matrix, speed, intensity float64, palette []packets.LightHsbk) (cycleFunc, error) {
+	speed, intensity = proceduralParams(speed, intensity)
+
+	var t float64
+	return proceduralCycle(palette, func() {
+		for y := range m.Height {
+			for x := range m.Width {
+				// The noise rarely exceeds ±0.5, so it is stretched over the palette from there.
+				v := 0.5 + perlinNoise(float64(x)*plasmaScale, float64(y)*plasmaScale, t)
+				m.SetPixel(x, y, scaleBrightness(paletteColor(palette, v), intensity))
+			}
+		}
+		t += plasmaStep * speed
+	})
+}
+
+// Fire simulates flames rising from the bottom row, which is fed with random heat and cools
+// as it rises. The palette maps the heat from the coolest to the hottest color, e.g. red,
+// orange and yellow, and cold pixels are off.
+// Speed scales how many simulation steps run on each frame, 1 being one step, and intensity,
+// between 0 and 1, the heat fed to the flames and thus their height.
+// A cycle lasts 100 frames, sent every interval. It repeats for n cycles, if cycles is set to 0
+// it repeats indefinitely.
+func Fire(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	return FireCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, speed, intensity, palette...)
+}
+
+// FireCtx is like Fire but stops when ctx is cancelled, returning the context error.
+func FireCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	cycle, err := fireCycle(m, speed, intensity, palette)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func fireCycle(m *Matrix, speed, intensity float64, palette []packets.LightHsbk) (cycleFunc, error) {
+	speed, intensity = proceduralParams(speed, intensity)
+	heat := newGrid(m)
+	steps := stepper(speed)
+
+	return proceduralCycle(palette, func() {
+		for range steps() {
+			// Each row takes the heat of the pixels below it, cooled by a random amount.
+			for y := range m.MaxY() {
+				for x := range m.Width {
+					below := heat[y+1][max(x-1, 0)] + heat[y+1][x] + heat[y+1][min(x+1, m.MaxX())]
+					heat[y][x] = max(below/3-rand.Float64()*fireCooling, 0)
+				}
+			}
+			for x := range m.Width {
+				heat[m.MaxY()][x] = intensity * (0.6 + 0.4*rand.Float64())
+			}
+		}
+		for y, row := range heat {
+			for x, h := range row {
+				m.SetPixel(x, y, scaleBrightness(paletteColor(palette, h), h))
+			}
+		}
+	})
+}
+
+// Sparkle lights random pixels with random colors of the palette, which then fade out.
+// Speed scales how fast sparkles fade, 1 being the default, and intensity, between 0 and 1,
+// how many pixels sparkle.
+// A cycle lasts 100 frames, sent every interval. It repeats for n cycles, if cycles is set to 0
+// it repeats indefinitely.
+func Sparkle(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	return SparkleCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, speed, intensity, palette...)
+}
+
+// SparkleCtx is like Sparkle but stops when ctx is cancelled, returning the context error.
+func SparkleCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	cycle, err := sparkleCycle(m, speed, intensity, palette)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func sparkleCycle(m *Matrix, speed, intensity float64, palette []packets.LightHsbk) (cycleFunc, error) {
+	speed, intensity = proceduralParams(speed, intensity)
+	level := newGrid(m)
+	colors := make([][]packets.LightHsbk, m.Height)
+	for y := range colors {
+		colors[y] = make([]packets.LightHsbk, m.Width)
+	}
+
+	return proceduralCycle(palette, func() {
+		for y, row := range level {
+			for x := range row {
+				row[x] = max(row[x]-sparkleDecay*speed, 0)
+				if rand.Float64() < intensity*sparkleRate {
+					row[x], colors[y][x] = 1, palette[rand.IntN(len(palette))]
+				}
+				m.SetPixel(x, y, scaleBrightness(colors[y][x], row[x]))
+			}
+		}
+	})
+}
+
+// Rain drops colors of the palette down the columns of the matrix, each leaving a fading trail.
+// Speed scales how many rows drops fall on each frame, 1 being one row, and intensity,
+// between 0 and 1, how many drops fall.
+// A cycle lasts 100 frames, sent every interval. It repeats for n cycles, if cycles is set to 0
+// it repeats indefinitely.
+func Rain(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	return RainCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, speed, intensity, palette...)
+}
+
+// RainCtx is like Rain but stops when ctx is cancelled, returning the context error.
+func RainCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, speed, intensity float64, palette ...packets.LightHsbk) error {
+	cycle, err := rainCycle(m, speed, intensity, palette)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func rainCycle(m *Matrix, speed, intensity float64, palette []packets.LightHsbk) (cycleFunc, error) {
+	speed, intensity = proceduralParams(speed, intensity)
+	level := newGrid(m)
+	colors := make([][]packets.LightHsbk, m.Height)
+	for y := range colors {
+		colors[y] = make([]packets.LightHsbk, m.Width)
+	}
+	type drop struct {
+		x, y  int
+		color packets.LightHsbk
+	}
+	var drops []drop
+	steps := stepper(speed)
+
+	return proceduralCycle(palette, func() {
+		for range steps() {
+			for _, row := range level {
+				for x := range row {
+					row[x] *= rainFade
+				}
+			}
+			// Move the drops down, dropping those past the bottom row, then start new ones.
+			moved := drops[:0]
+			for _, d := range drops {
+				if d.y++; d.y < m.Height {
+					moved = append(moved, d)
+				}
+			}
+			drops = moved
+			for x := range m.Width {
+				if rand.Float64() < intensity*rainRate {
+					drops = append(drops, drop{x: x, color: palette[rand.IntN(len(palette))]})
+				}
+			}
+			for _, d := range drops {
+				level[d.y][d.x], colors[d.y][d.x] = 1, d.color
+			}
+		}
+		for y, row := range level {
+			for x, l := range row {
+				m.SetPixel(x, y, scaleBrightness(colors[y][x], l))
+			}
+		}
+	})
+}
+
+// proceduralCycle returns the cycles of proceduralCycleFrames frames drawn by draw.
+func proceduralCycle(palette []packets.LightHsbk, draw func()) (cycleFunc, error) {
+	if len(palette) == 0 {
+		return nil, ErrMissingColors
+	}
+	return func() func() bool {
+		var n int
+		return func() bool {
+			if n == proceduralCycleFrames {
+				return false
+			}
+			n++
+			draw()
+			return true
+		}
+	}, nil
+}
+
+// proceduralParams returns the speed, defaulting to 1 if not positive, and the intensity
+// clamped between 0 and 1.
+func proceduralParams(speed, intensity float64) (float64, float64) {
+	if speed <= 0 {
+		speed = 1
+	}
+	return speed, min(max(intensity, 0), 1)
+}
+
+// stepper returns a function reporting how many simulation steps to run on each frame at
+// the given speed, accumulating the fractions of steps.
+func stepper(speed float64) func() int {
+	var acc float64
+	return func() int {
+		acc += speed
+		n := int(acc)
+		acc -= float64(n)
+		return n
+	}
+}
+
+// newGrid returns a grid of values with the size of the matrix, indexed by row.
+func newGrid(m *Matrix) [][]float64 {
+	grid := make([][]float64, m.Height)
+	for y := range grid {
+		grid[y] = make([]float64, m.Width)
+	}
+	return grid
+}
+
+// paletteColor returns the color at v, between 0 and 1, on a gradient through the palette colors.
+func paletteColor(palette []packets.LightHsbk, v float64) packets.LightHsbk {
+	if len(palette) == 1 {
+		return palette[0]
+	}
+	pos := min(max(v, 0), 1) * float64(len(palette)-1)
+	i := min(int(pos), len(palette)-2)
+	return blendColors(palette[i], palette[i+1], pos-float64(i))
+}
+
+// blendColors returns the color at t, between 0 and 1, on the way from a to b.
+func blendColors(a, b packets.LightHsbk, t float64) packets.LightHsbk {
+	lerp := func(a, b uint16) uint16 {
+		return uint16(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return packets.LightHsbk{
+		// Hue wraps around, so the difference is taken modulo 2^16 to follow the shortest path.
+		Hue:        a.Hue + uint16(int32(float64(int16(b.Hue-a.Hue))*t)),
+		Saturation: lerp(a.Saturation, b.Saturation),
+		Brightness: lerp(a.Brightness, b.Brightness),
+		Kelvin:     lerp(a.Kelvin, b.Kelvin),
+	}
+}
+
+// scaleBrightness returns c with its brightness scaled by f, between 0 and 1.
+func scaleBrightness(c packets.LightHsbk, f float64) packets.LightHsbk {
+	c.Brightness = uint16(float64(c.Brightness) * min(max(f, 0), 1))
+	return c
+}
+
+// perlinPerm is the permutation of the Perlin noise, shuffled with a fixed seed so that
+// the noise is the same on every run, repeated to avoid wrapping indices.
+var perlinPerm = func() [512]uint8 {
+	var p [512]uint8
+	for i, v := range rand.New(rand.NewPCG(1, 2)).Perm(256) {
+		p[i], p[i+256] = uint8(v), uint8(v)
+	}
+	return p
+}()
+
+// perlinNoise returns the improved Perlin noise at x, y, z, between -1 and 1.
+// It is 0 at integer coordinates and varies smoothly in between.
+func perlinNoise(x, y, z float64) float64 {
+	fx, fy, fz := math.Floor(x), math.Floor(y), math.Floor(z)
+	xi, yi, zi := int(fx)&255, int(fy)&255, int(fz)&255
+	x, y, z = x-fx, y-fy, z-fz
+	u, v, w := fade(x), fade(y), fade(z)
+
+	p := &perlinPerm
+	a := int(p[xi]) + yi
+	aa, ab := int(p[a])+zi, int(p[a+1])+zi
+	b := int(p[xi+1]) + yi
+	ba, bb := int(p[b])+zi, int(p[b+1])+zi
+
+	return lerp(w,
+		lerp(v,
+			lerp(u, grad(p[aa], x, y, z), grad(p[ba], x-1, y, z)),
+			lerp(u, grad(p[ab], x, y-1, z), grad(p[bb], x-1, y-1, z))),
+		lerp(v,
+			lerp(u, grad(p[aa+1], x, y, z-1), grad(p[ba+1], x-1, y, z-1)),
+			lerp(u, grad(p[ab+1], x, y-1, z-1), grad(p[bb+1], x-1, y-1, z-1))))
+}
+
+// fade eases t, between 0 and 1, so that the noise is smooth across the lattice cells.
+func fade(t float64) float64 {
+	return t * t * t * (t*(t*6-15) + 10)
+}
+
+func lerp(t, a, b float64) float64 {
+	return a + t*(b-a)
+}
+
+// grad returns the dot product of x, y, z with one of 12 gradient directions picked by hash.
+func grad(hash uint8, x, y, z float64) float64 {
+	h := hash & 15
+	u, v := y, z
+	if h < 8 {
+		u = x
+	}
+	if h < 4 {
+		v = y
+	} else if h == 12 || h == 14 {
+		v = x
+	}
+	if h&1 != 0 {
+		u = -u
+	}
+	if h&2 != 0 {
+		v = -v
+	}
+	return u + v
+}
@@ -0,0 +1,140 @@
+package matrix
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPerlinNoise(t *testing.T) {
+	assert.Zero(t, perlinNoise(3, 5, 7))
+	assert.Equal(t, perlinNoise(1.3, 2.7, 0.4), perlinNoise(1.3, 2.7, 0.4))
+	for i := range 1000 {
+		x, y, z := float64(i)*0.37, float64(i)*0.11, float64(i)*0.05
+		v := perlinNoise(x, y, z)
+		assert.True(t, v >= -1 && v <= 1, "noise %f out of range", v)
+		// The noise is continuous.
+		assert.InDelta(t, v, perlinNoise(x+0.001, y, z), 0.01)
+	}
+}
+
+func TestPaletteColor(t *testing.T) {
+	var (
+		red    = packets.LightHsbk{Hue: 0, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		yellow = packets.LightHsbk{Hue: 10922, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+		blue   = packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	)
+
+	testCases := map[string]struct {
+		palette []packets.LightHsbk
+		v       float64
+		want    packets.LightHsbk
+	}{
+		"Single color":  {palette: []packets.LightHsbk{blue}, v: 0.7, want: blue},
+		"First":         {palette: []packets.LightHsbk{red, yellow, blue}, v: 0, want: red},
+		"Last":          {palette: []packets.LightHsbk{red, yellow, blue}, v: 1, want: blue},
+		"Middle":        {palette: []packets.LightHsbk{red, yellow, blue}, v: 0.5, want: yellow},
+		"Blended":       {palette: []packets.LightHsbk{red, yellow}, v: 0.5, want: packets.LightHsbk{Hue: 5461, Saturation: 65535, Brightness: 65535, Kelvin: 3500}},
+		"Hue wraps":     {palette: []packets.LightHsbk{blue, red}, v: 0.5, want: packets.LightHsbk{Hue: 54613, Saturation: 65535, Brightness: 65535, Kelvin: 3500}},
+		"Clamped below": {palette: []packets.LightHsbk{red, blue}, v: -1, want: red},
+		"Clamped above": {palette: []packets.LightHsbk{red, blue}, v: 2, want: blue},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, paletteColor(tc.palette, tc.v))
+		})
+	}
+}
+
+func TestProceduralEffects(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	type effectFunc func(m *Matrix, send SendFunc, mode ChainMode, intensity float64) error
+	effects := map[string]effectFunc{
+		"Plasma": func(m *Matrix, send SendFunc, mode ChainMode, intensity float64) error {
+			return Plasma(m, send, 0, 1, mode, 1, intensity, color)
+		},
+		"Fire": func(m *Matrix, send SendFunc, mode ChainMode, intensity float64) error {
+			return Fire(m, send, 0, 1, mode, 1, intensity, color)
+		},
+		"Sparkle": func(m *Matrix, send SendFunc, mode ChainMode, intensity float64) error {
+			return Sparkle(m, send, 0, 1, mode, 1, intensity, color)
+		},
+		"Rain": func(m *Matrix, send SendFunc, mode ChainMode, intensity float64) error {
+			return Rain(m, send, 0, 1, mode, 1.5, intensity, color)
+		},
+	}
+
+	for name, run := range effects {
+		t.Run(name, func(t *testing.T) {
+			t.Run("Sends a cycle of frames", func(t *testing.T) {
+				var frames []*packets.TileSet64
+				send := func(msg *protocol.Message) error {
+					frames = append(frames, msg.Payload.(*packets.TileSet64))
+					return nil
+				}
+				m := New(8, 8, 1)
+				require.NoError(t, run(m, send, ChainModeNone, 1))
+				require.Len(t, frames, proceduralCycleFrames)
+
+				var lit int
+				for _, c := range m.Flatten() {
+					if c.Brightness > 0 {
+						lit++
+						assert.Equal(t, color.Hue, c.Hue)
+					}
+				}
+				assert.NotZero(t, lit)
+			})
+
+			t.Run("Is dark at zero intensity", func(t *testing.T) {
+				m := New(8, 8, 1)
+				require.NoError(t, run(m, func(*protocol.Message) error { return nil }, ChainModeNone, 0))
+				for _, c := range m.Flatten() {
+					assert.Zero(t, c.Brightness)
+				}
+			})
+
+			t.Run("Runs a cycle on each tile in sequence", func(t *testing.T) {
+				var tiles []uint8
+				send := func(msg *protocol.Message) error {
+					tiles = append(tiles, msg.Payload.(*packets.TileSet64).TileIndex)
+					return nil
+				}
+				require.NoError(t, run(New(8, 8, 2), send, ChainModeSequential, 1))
+				require.Len(t, tiles, 2*proceduralCycleFrames)
+				assert.Equal(t, uint8(0), tiles[0])
+				assert.Equal(t, uint8(1), tiles[len(tiles)-1])
+			})
+
+			t.Run("Splits frames of more than 64 zones", func(t *testing.T) {
+				var sent int
+				send := func(*protocol.Message) error {
+					sent++
+					return nil
+				}
+				require.NoError(t, run(New(16, 8, 1), send, ChainModeNone, 1))
+				// Each frame is loaded into a hidden frame buffer in two messages then copied.
+				assert.Equal(t, 3*proceduralCycleFrames, sent)
+			})
+
+			t.Run("Requires a palette", func(t *testing.T) {
+				err := PlasmaCtx(context.Background(), New(8, 8, 1), nil, 0, 1, ChainModeNone, 1, 1)
+				assert.ErrorIs(t, err, ErrMissingColors)
+			})
+		})
+	}
+}
+
+func TestStepper(t *testing.T) {
+	steps := stepper(0.4)
+	var got []int
+	for range 5 {
+		got = append(got, steps())
+	}
+	assert.Equal(t, []int{0, 0, 1, 0, 1}, got)
+}
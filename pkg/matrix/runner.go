@@ -0,0 +1,281 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+)
+
+// ErrNoEffect is returned when no effect is running on a device.
+var ErrNoEffect = errors.New("no effect running")
+
+// EffectFunc runs a software effect, sending its frames through send,
+// until it completes or ctx is cancelled.
+// The ...Ctx effects can be bound to an EffectFunc with a closure, e.g.
+//
+//	func(ctx context.Context, send protocol.SendFunc) error {
+//		return matrix.WaterfallCtx(ctx, m, send, 100, 0, matrix.ChainModeNone, colors...)
+//	}
+type EffectFunc func(ctx context.Context, send SendFunc) error
+
+// EffectState is the lifecycle state of an effect.
+type EffectState int
+
+const (
+	// EffectStopped is the state of an effect stopped by the caller.
+	EffectStopped EffectState = iota
+	// EffectRunning is the state of an effect currently sending frames.
+	EffectRunning
+	// EffectPaused is the state of an effect waiting to be resumed.
+	EffectPaused
+	// EffectCompleted is the state of an effect that ran all its cycles.
+	EffectCompleted
+	// EffectFailed is the state of an effect that returned an error.
+	EffectFailed
+)
+
+// String returns the name of the state.
+func (s EffectState) String() string {
+	switch s {
+	case EffectRunning:
+		return "running"
+	case EffectPaused:
+		return "paused"
+	case EffectCompleted:
+		return "completed"
+	case EffectFailed:
+		return "failed"
+	default:
+		return "stopped"
+	}
+}
+
+// EffectStatus describes the last effect started on a device.
+type EffectStatus struct {
+	Name      string
+	State     EffectState
+	StartedAt time.Time
+	// Err is the error returned by a failed effect.
+	Err error
+}
+
+// EffectRunner runs software effects on devices, guaranteeing that at most one
+// effect runs on each device at a time.
+// It owns the effect goroutines so that callers only need to start and stop effects.
+type EffectRunner struct {
+	send func(serial device.Serial, msg *protocol.Message) error
+
+	mu      sync.Mutex
+	effects map[device.Serial]*runningEffect
+}
+
+// runningEffect is an effect started by an EffectRunner.
+type runningEffect struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// status and resume are guarded by the runner mutex.
+	status EffectStatus
+	// resume is non-nil while the effect is paused and closed when it is resumed.
+	resume chan struct{}
+}
+
+// NewEffectRunner returns an EffectRunner sending effect frames through send.
+func NewEffectRunner(send func(serial device.Serial, msg *protocol.Message) error) *EffectRunner {
+	return &EffectRunner{
+		send:    send,
+		effects: make(map[device.Serial]*runningEffect),
+	}
+}
+
+// Start runs the given effect on the device identified by serial,
+// stopping and waiting for any effect already running on it.
+func (r *EffectRunner) Start(serial device.Serial, name string, fn EffectFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopLocked(serial)
+	r.startLocked(serial, name, fn, false)
+}
+
+// Stop stops the effect running on the device and waits for it to return.
+// It returns ErrNoEffect if no effect is running.
+func (r *EffectRunner) Stop(serial device.Serial) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.stopLocked(serial) {
+		return ErrNoEffect
+	}
+	return nil
+}
+
+// StopAll stops all running effects.
+func (r *EffectRunner) StopAll() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, serial := range slices.Collect(maps.Keys(r.effects)) {
+		r.stopLocked(serial)
+	}
+}
+
+// Pause suspends the effect running on the device before its next frame.
+// It returns ErrNoEffect if no effect is running.
+func (r *EffectRunner) Pause(serial device.Serial) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.active(serial)
+	if !ok {
+		return ErrNoEffect
+	}
+	if e.resume == nil {
+		e.resume = make(chan struct{})
+		e.status.State = EffectPaused
+	}
+	return nil
+}
+
+// Resume resumes a paused effect.
+// It returns ErrNoEffect if no effect is running.
+func (r *EffectRunner) Resume(serial device.Serial) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.active(serial)
+	if !ok {
+		return ErrNoEffect
+	}
+	if e.resume != nil {
+		close(e.resume)
+		e.resume = nil
+		e.status.State = EffectRunning
+	}
+	return nil
+}
+
+// Update restarts the effect running on the device with fn, keeping its name
+// and paused state, e.g. to change its parameters.
+// It returns ErrNoEffect if no effect is running.
+func (r *EffectRunner) Update(serial device.Serial, fn EffectFunc) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.active(serial)
+	if !ok {
+		return ErrNoEffect
+	}
+	paused := e.resume != nil
+	r.stopLocked(serial)
+	r.startLocked(serial, e.status.Name, fn, paused)
+	return nil
+}
+
+// Status returns the status of the last effect started on the device, if any.
+func (r *EffectRunner) Status(serial device.Serial) (EffectStatus, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.effects[serial]
+	if !ok {
+		return EffectStatus{}, false
+	}
+	return e.status, true
+}
+
+// active returns the effect running or paused on the device.
+// It must be called with r.mu held.
+func (r *EffectRunner) active(serial device.Serial) (*runningEffect, bool) {
+	e, ok := r.effects[serial]
+	if !ok || (e.status.State != EffectRunning && e.status.State != EffectPaused) {
+		return nil, false
+	}
+	return e, true
+}
+
+// startLocked starts fn on the device.
+// It must be called with r.mu held.
+func (r *EffectRunner) startLocked(serial device.Serial, name string, fn EffectFunc, paused bool) {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &runningEffect{
+		cancel: cancel,
+		done:   make(chan struct{}),
+		status: EffectStatus{Name: name, State: EffectRunning, StartedAt: time.Now()},
+	}
+	if paused {
+		e.resume = make(chan struct{})
+		e.status.State = EffectPaused
+	}
+	r.effects[serial] = e
+
+	send := func(msg *protocol.Message) error {
+		if err := r.waitResume(ctx, e); err != nil {
+			return err
+		}
+		return r.send(serial, msg)
+	}
+
+	go func() {
+		defer close(e.done)
+		err := fn(ctx, send)
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		switch {
+		case ctx.Err() != nil:
+			// Stopped by the caller, which sets the status.
+		case err != nil:
+			e.status.State = EffectFailed
+			e.status.Err = err
+		default:
+			e.status.State = EffectCompleted
+		}
+	}()
+}
+
+// stopLocked cancels the effect running on the device and waits for it to return.
+// It reports whether an effect was running.
+// It must be called with r.mu held, which is released while waiting.
+func (r *EffectRunner) stopLocked(serial device.Serial) bool {
+	var stopped bool
+	// Loop as another effect may have been started while the mutex was released.
+	for {
+		e, ok := r.active(serial)
+		if !ok {
+			return stopped
+		}
+		e.cancel()
+		e.status.State = EffectStopped
+		e.resume = nil
+		stopped = true
+
+		// The effect goroutine acquires the mutex before returning.
+		r.mu.Unlock()
+		<-e.done
+		r.mu.Lock()
+	}
+}
+
+// waitResume blocks while the effect is paused.
+func (r *EffectRunner) waitResume(ctx context.Context, e *runningEffect) error {
+	r.mu.Lock()
+	resume := e.resume
+	r.mu.Unlock()
+
+	if resume == nil {
+		return ctx.Err()
+	}
+	select {
+	case <-resume:
+		return ctx.Err()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,137 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectRunner(t *testing.T) {
+	serial0 := device.Serial([8]byte{1, 0, 0, 0, 0, 0, 0, 0})
+
+	// loop returns an effect sending a message every millisecond until cancelled,
+	// counting running instances in running.
+	loop := func(running *atomic.Int32) EffectFunc {
+		return func(ctx context.Context, send SendFunc) error {
+			running.Add(1)
+			defer running.Add(-1)
+			for {
+				if err := send(protocol.NewMessage(&packets.LightGet{})); err != nil {
+					return err
+				}
+				if err := sleep(ctx, time.Millisecond); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	newRunner := func() (*EffectRunner, *atomic.Int32) {
+		var sends atomic.Int32
+		return NewEffectRunner(func(serial device.Serial, msg *protocol.Message) error {
+			sends.Add(1)
+			return nil
+		}), &sends
+	}
+
+	t.Run("Runs one effect per device", func(t *testing.T) {
+		r, sends := newRunner()
+		var running atomic.Int32
+
+		r.Start(serial0, "first", loop(&running))
+		r.Start(serial0, "second", loop(&running))
+		assert.Eventually(t, func() bool { return sends.Load() > 0 }, time.Second, time.Millisecond)
+		assert.Equal(t, int32(1), running.Load())
+
+		status, ok := r.Status(serial0)
+		require.True(t, ok)
+		assert.Equal(t, "second", status.Name)
+		assert.Equal(t, EffectRunning, status.State)
+
+		require.NoError(t, r.Stop(serial0))
+		assert.Equal(t, int32(0), running.Load())
+		status, _ = r.Status(serial0)
+		assert.Equal(t, EffectStopped, status.State)
+		assert.ErrorIs(t, r.Stop(serial0), ErrNoEffect)
+	})
+
+	t.Run("Pauses and resumes an effect", func(t *testing.T) {
+		r, sends := newRunner()
+		var running atomic.Int32
+		r.Start(serial0, "loop", loop(&running))
+		defer r.StopAll()
+
+		require.NoError(t, r.Pause(serial0))
+		status, _ := r.Status(serial0)
+		assert.Equal(t, EffectPaused, status.State)
+
+		// Allow an in-flight send to complete.
+		time.Sleep(5 * time.Millisecond)
+		paused := sends.Load()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, paused, sends.Load())
+
+		require.NoError(t, r.Resume(serial0))
+		assert.Eventually(t, func() bool { return sends.Load() > paused }, time.Second, time.Millisecond)
+	})
+
+	t.Run("Updates a running effect", func(t *testing.T) {
+		r, _ := newRunner()
+		var first, second atomic.Int32
+		r.Start(serial0, "loop", loop(&first))
+		require.NoError(t, r.Pause(serial0))
+
+		require.NoError(t, r.Update(serial0, loop(&second)))
+		assert.Equal(t, int32(0), first.Load())
+		assert.Eventually(t, func() bool { return second.Load() == 1 }, time.Second, time.Millisecond)
+
+		status, _ := r.Status(serial0)
+		assert.Equal(t, "loop", status.Name)
+		assert.Equal(t, EffectPaused, status.State)
+
+		r.StopAll()
+		assert.Equal(t, int32(0), second.Load())
+		assert.ErrorIs(t, r.Update(serial0, loop(&second)), ErrNoEffect)
+	})
+
+	t.Run("Reports completed and failed effects", func(t *testing.T) {
+		r, _ := newRunner()
+		errFailed := errors.New("failed")
+
+		testCases := map[string]struct {
+			err       error
+			wantState EffectState
+		}{
+			"completed": {wantState: EffectCompleted},
+			"failed":    {err: errFailed, wantState: EffectFailed},
+		}
+
+		for name, tc := range testCases {
+			r.Start(serial0, name, func(ctx context.Context, send SendFunc) error {
+				return tc.err
+			})
+			assert.Eventually(t, func() bool {
+				status, _ := r.Status(serial0)
+				return status.State == tc.wantState
+			}, time.Second, time.Millisecond, name)
+
+			status, _ := r.Status(serial0)
+			assert.Equal(t, tc.err, status.Err, name)
+			assert.ErrorIs(t, r.Pause(serial0), ErrNoEffect, name)
+		}
+	})
+
+	t.Run("Returns no status for unknown devices", func(t *testing.T) {
+		r, _ := newRunner()
+		_, ok := r.Status(serial0)
+		assert.False(t, ok)
+	})
+}
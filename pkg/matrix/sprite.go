@@ -0,0 +1,67 @@
+package matrix
+
+import "github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+
+// Sprite is a small grid of colors drawn over a matrix with Blit, e.g. an icon moving over a
+// background. Its transparent pixels leave the matrix colors underneath unchanged.
+type Sprite struct {
+	Width  int
+	Height int
+	// Colors holds the color of each pixel by row, and Opaque whether it is drawn.
+	Colors [][]packets.LightHsbk
+	Opaque [][]bool
+}
+
+// NewSprite returns a transparent sprite of the given size.
+func NewSprite(width, height int) *Sprite {
+	s := &Sprite{
+		Width:  width,
+		Height: height,
+		Colors: make([][]packets.LightHsbk, height),
+		Opaque: make([][]bool, height),
+	}
+	for y := range height {
+		s.Colors[y] = make([]packets.LightHsbk, width)
+		s.Opaque[y] = make([]bool, width)
+	}
+	return s
+}
+
+// ParseSprite returns a sprite drawn as rows of runes, each mapped to a color. Runes without
+// a color, e.g. '.', are transparent, and rows shorter than the longest one are padded with
+// transparent pixels.
+func ParseSprite(rows []string, colors map[rune]packets.LightHsbk) *Sprite {
+	s := NewSprite(longestRow(rows), len(rows))
+	for y, row := range rows {
+		for x, r := range []rune(row) {
+			if c, ok := colors[r]; ok {
+				s.SetPixel(x, y, c)
+			}
+		}
+	}
+	return s
+}
+
+// SetPixel sets the pixel at (x, y) to the given opaque color.
+func (s *Sprite) SetPixel(x, y int, c packets.LightHsbk) {
+	s.Colors[y][x] = c
+	s.Opaque[y][x] = true
+}
+
+// ClearPixel makes the pixel at (x, y) transparent.
+func (s *Sprite) ClearPixel(x, y int) {
+	s.Colors[y][x] = packets.LightHsbk{}
+	s.Opaque[y][x] = false
+}
+
+// Blit draws the opaque pixels of the sprite with its top-left corner at (x, y), which may be
+// outside of the matrix. Pixels falling outside of the matrix are clipped.
+func (m *Matrix) Blit(s *Sprite, x, y int) {
+	for sy := range s.Height {
+		for sx := range s.Width {
+			if s.Opaque[sy][sx] {
+				m.setPixelClipped(x+sx, y+sy, s.Colors[sy][sx])
+			}
+		}
+	}
+}
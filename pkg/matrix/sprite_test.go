@@ -0,0 +1,81 @@
+package matrix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSprite(t *testing.T) {
+	var (
+		a = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		b = packets.LightHsbk{Hue: 2, Brightness: 65535}
+	)
+
+	s := ParseSprite([]string{"A.B", "B"}, map[rune]packets.LightHsbk{'A': a, 'B': b})
+	assert.Equal(t, 3, s.Width)
+	assert.Equal(t, 2, s.Height)
+	assert.Equal(t, [][]packets.LightHsbk{{a, {}, b}, {b, {}, {}}}, s.Colors)
+	assert.Equal(t, [][]bool{{true, false, true}, {true, false, false}}, s.Opaque)
+
+	s.ClearPixel(0, 0)
+	assert.Equal(t, packets.LightHsbk{}, s.Colors[0][0])
+	assert.False(t, s.Opaque[0][0])
+}
+
+func TestBlit(t *testing.T) {
+	var (
+		background = packets.LightHsbk{Hue: 1, Brightness: 65535}
+		black      = packets.LightHsbk{Kelvin: 3500}
+		icon       = packets.LightHsbk{Hue: 2, Brightness: 65535}
+	)
+	// The black pixel is opaque, unlike the transparent corners.
+	sprite := ParseSprite([]string{".X.", "XOX", ".X."}, map[rune]packets.LightHsbk{'X': icon, 'O': black})
+
+	// render returns the matrix with '-' for the background, X for the icon and O for black.
+	render := func(m *Matrix) string {
+		symbols := map[packets.LightHsbk]byte{background: '-', icon: 'X', black: 'O'}
+		var rows []string
+		for _, row := range m.Colors {
+			var sb strings.Builder
+			for _, c := range row {
+				sb.WriteByte(symbols[c])
+			}
+			rows = append(rows, sb.String())
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	testCases := map[string]struct {
+		x, y int
+		want []string
+	}{
+		"Inside": {
+			x: 1, y: 1,
+			want: []string{"-----", "--X--", "-XOX-", "--X--", "-----"},
+		},
+		"Clipped top left": {
+			x: -1, y: -1,
+			want: []string{"OX---", "X----", "-----", "-----", "-----"},
+		},
+		"Clipped bottom right": {
+			x: 3, y: 4,
+			want: []string{"-----", "-----", "-----", "-----", "----X"},
+		},
+		"Outside": {
+			x: 5, y: -3,
+			want: []string{"-----", "-----", "-----", "-----", "-----"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			m := New(5, 5, 1)
+			m.DrawRect(0, 0, 5, 5, true, background)
+			m.Blit(sprite, tc.x, tc.y)
+			assert.Equal(t, strings.Join(tc.want, "\n"), render(m))
+		})
+	}
+}
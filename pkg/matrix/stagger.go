@@ -0,0 +1,152 @@
+package matrix
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+const (
+	// defaultStagger is the delay between consecutive tiles in ChainModeStaggered if the matrix has no Stagger.
+	defaultStagger = 500 * time.Millisecond
+	// staggerQueueSize is the number of messages each tile can have waiting to be sent.
+	staggerQueueSize = 256
+)
+
+// staggerChain returns the send function for an effect running in the given mode and a function to
+// call with the result of the effect.
+// In ChainModeStaggered, the effect runs on the first tile and each message it sends is sent again to
+// each next tile of the chain, delayed by one more Stagger of the matrix, and done waits for them.
+func staggerChain(ctx context.Context, m *Matrix, mode ChainMode, send SendFunc) (SendFunc, func(error) error) {
+	if mode != ChainModeStaggered || m.ChainLength < 2 {
+		return send, func(err error) error { return err }
+	}
+	delay := m.Stagger
+	if delay <= 0 {
+		delay = defaultStagger
+	}
+	s := newStaggerSender(ctx, send, m.ChainLength, delay)
+	return s.sendFirst, s.close
+}
+
+// staggerSender sends the messages addressed to the first tile to the other tiles of a chain,
+// each tile keeping its own queue of delayed messages.
+type staggerSender struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	delay  time.Duration
+	queues []chan staggeredMessage
+	wg     sync.WaitGroup
+
+	// mu serializes the sends of the tiles and guards err, the first error met.
+	mu   sync.Mutex
+	send SendFunc
+	err  error
+}
+
+// staggeredMessage is a message to be sent to a tile once due.
+type staggeredMessage struct {
+	due time.Time
+	msg *protocol.Message
+}
+
+func newStaggerSender(ctx context.Context, send SendFunc, chainLength int, delay time.Duration) *staggerSender {
+	ctx, cancel := context.WithCancel(ctx)
+	s := &staggerSender{ctx: ctx, cancel: cancel, delay: delay, send: send}
+	for range chainLength - 1 {
+		q := make(chan staggeredMessage, staggerQueueSize)
+		s.queues = append(s.queues, q)
+		s.wg.Add(1)
+		go s.run(q)
+	}
+	return s
+}
+
+// sendFirst sends msg to the first tile and queues it for the other tiles.
+func (s *staggerSender) sendFirst(msg *protocol.Message) error {
+	if err := s.sendNow(msg, nil); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for i, q := range s.queues {
+		tileMsg := retarget(msg, i+1)
+		if tileMsg == nil {
+			continue
+		}
+		select {
+		case q <- staggeredMessage{due: now.Add(time.Duration(i+1) * s.delay), msg: tileMsg}:
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		}
+	}
+	return nil
+}
+
+// sendNow sends msg unless an error was met, recording the error of the send or, if not nil, ctxErr.
+func (s *staggerSender) sendNow(msg *protocol.Message, ctxErr error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	if ctxErr != nil {
+		s.err = ctxErr
+		return ctxErr
+	}
+	if err := s.send(msg); err != nil {
+		s.err = err
+		return err
+	}
+	return nil
+}
+
+// run sends the messages of a tile once due. After an error, the remaining messages are discarded.
+func (s *staggerSender) run(q <-chan staggeredMessage) {
+	defer s.wg.Done()
+	for sm := range q {
+		if err := s.sendNow(sm.msg, sleep(s.ctx, time.Until(sm.due))); err != nil {
+			s.cancel()
+		}
+	}
+}
+
+// close waits for the queued messages to be sent, or discards them if err is not nil,
+// and returns err or the first error met while sending them.
+func (s *staggerSender) close(err error) error {
+	if err != nil {
+		s.cancel()
+	}
+	for _, q := range s.queues {
+		close(q)
+	}
+	s.wg.Wait()
+	s.cancel()
+
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// retarget returns a copy of a tile message addressed to the given tile only,
+// or nil if msg is not addressed to tiles.
+func retarget(msg *protocol.Message, tile int) *protocol.Message {
+	switch p := msg.Payload.(type) {
+	case *packets.TileSet64:
+		c := *p
+		c.TileIndex, c.Length = uint8(tile), 1
+		return protocol.NewMessage(&c)
+	case *packets.TileCopyFrameBuffer:
+		c := *p
+		c.TileIndex, c.Length = uint8(tile), 1
+		return protocol.NewMessage(&c)
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,130 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tileMessages records the payloads sent to each tile and the time of the first one.
+type tileMessages struct {
+	mu       sync.Mutex
+	payloads map[uint8][]any
+	firstAt  map[uint8]time.Time
+}
+
+func newTileMessages() *tileMessages {
+	return &tileMessages{payloads: make(map[uint8][]any), firstAt: make(map[uint8]time.Time)}
+}
+
+func (r *tileMessages) send(msg *protocol.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var tile uint8
+	var p any
+	switch payload := msg.Payload.(type) {
+	case *packets.TileSet64:
+		c := *payload
+		tile, c.TileIndex = c.TileIndex, 0
+		p = c
+	case *packets.TileCopyFrameBuffer:
+		c := *payload
+		tile, c.TileIndex = c.TileIndex, 0
+		p = c
+	}
+	if _, ok := r.firstAt[tile]; !ok {
+		r.firstAt[tile] = time.Now()
+	}
+	r.payloads[tile] = append(r.payloads[tile], p)
+	return nil
+}
+
+func TestChainModeStaggered(t *testing.T) {
+	color := packets.LightHsbk{Hue: 1000, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		matrix          *Matrix
+		messagesPerTile int
+	}{
+		"Single message frames": {matrix: New(8, 8, 3), messagesPerTile: 8},
+		"Frame buffers":         {matrix: New(16, 8, 3), messagesPerTile: 8 * 3},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			r := newTileMessages()
+			tc.matrix.Stagger = 30 * time.Millisecond
+			require.NoError(t, Waterfall(tc.matrix, r.send, 5, 1, ChainModeStaggered, color))
+
+			require.Len(t, r.payloads, 3)
+			for tile := range uint8(3) {
+				assert.Len(t, r.payloads[tile], tc.messagesPerTile)
+				// Each tile shows the same frames, in the same order.
+				assert.Equal(t, r.payloads[0], r.payloads[tile])
+			}
+			assert.GreaterOrEqual(t, r.firstAt[1].Sub(r.firstAt[0]), 30*time.Millisecond)
+			assert.GreaterOrEqual(t, r.firstAt[2].Sub(r.firstAt[0]), 60*time.Millisecond)
+		})
+	}
+
+	t.Run("Runs like ChainModeNone on a single tile", func(t *testing.T) {
+		r := newTileMessages()
+		require.NoError(t, Waterfall(New(8, 8, 1), r.send, 0, 1, ChainModeStaggered, color))
+		assert.Len(t, r.payloads, 1)
+		assert.Len(t, r.payloads[0], 8)
+	})
+
+	t.Run("Returns the errors of delayed tiles", func(t *testing.T) {
+		errSend := errors.New("send failed")
+		send := func(msg *protocol.Message) error {
+			if msg.Payload.(*packets.TileSet64).TileIndex == 2 {
+				return errSend
+			}
+			return nil
+		}
+		m := New(8, 8, 3)
+		m.Stagger = time.Millisecond
+		err := Waterfall(m, send, 0, 1, ChainModeStaggered, color)
+		assert.ErrorIs(t, err, errSend)
+	})
+
+	t.Run("Stops when ctx is cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		r := newTileMessages()
+		m := New(8, 8, 2)
+		m.Stagger = time.Hour
+
+		start := time.Now()
+		err := PlasmaCtx(ctx, m, r.send, 1, 0, ChainModeStaggered, 1, 1, color)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Empty(t, r.payloads[1])
+	})
+
+	t.Run("Applies the stagger of the params", func(t *testing.T) {
+		r := newTileMessages()
+		m := New(8, 8, 2)
+		p := NewEffectParams(WithInterval(0), WithCycles(1), WithChainMode(ChainModeStaggered), WithPalette(color), WithStagger(20*time.Millisecond))
+		require.NoError(t, WaterfallEffect(context.Background(), m, r.send, p))
+		assert.GreaterOrEqual(t, r.firstAt[1].Sub(r.firstAt[0]), 20*time.Millisecond)
+		assert.Zero(t, m.Stagger)
+	})
+}
+
+func TestRetarget(t *testing.T) {
+	msg := retarget(protocol.NewMessage(&packets.TileSet64{TileIndex: 0, Length: 3, Duration: 5}), 2)
+	assert.Equal(t, &packets.TileSet64{TileIndex: 2, Length: 1, Duration: 5}, msg.Payload)
+
+	msg = retarget(protocol.NewMessage(&packets.TileCopyFrameBuffer{Length: 3, SrcFbIndex: 1}), 1)
+	assert.Equal(t, &packets.TileCopyFrameBuffer{TileIndex: 1, Length: 1, SrcFbIndex: 1}, msg.Payload)
+
+	assert.Nil(t, retarget(protocol.NewMessage(&packets.LightSetPower{Level: 65535}), 1))
+}
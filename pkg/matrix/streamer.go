@@ -0,0 +1,138 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+var ErrInvalidFPS = errors.New("fps must be positive")
+
+// StreamStats reports the frames handled by a FrameStreamer.
+type StreamStats struct {
+	// Sent is the number of frames sent to the device.
+	Sent uint64
+	// Dropped is the number of frames replaced by a newer one before they could be sent.
+	Dropped uint64
+}
+
+// FrameStreamer sends frames produced by an external source, e.g. a music visualizer
+// or a screen mirroring app, to a matrix device at a target frame rate.
+//
+// Frames received between two ticks are coalesced, only the latest one being sent,
+// so that producers faster than the device or the network do not build up latency.
+// Frames are laid out row by row and either cover a single tile, in which case they are
+// applied to the whole chain, or the whole chain, in which case each tile is sent its own slice.
+// Matrices with more than 64 zones are double buffered: frames are alternately loaded into two
+// hidden frame buffers and then copied into the visible one, so a frame is never shown partially.
+// Devices without frame buffers, such as the original LIFX Tile, are set directly.
+type FrameStreamer struct {
+	m    *Matrix
+	send SendFunc
+	fps  int
+
+	sent    atomic.Uint64
+	dropped atomic.Uint64
+	// fb is the hidden frame buffer the next frame is loaded into.
+	fb int
+}
+
+// NewFrameStreamer returns a FrameStreamer sending frames for m at the given frame rate.
+func NewFrameStreamer(m *Matrix, send SendFunc, fps int) (*FrameStreamer, error) {
+	if fps <= 0 {
+		return nil, ErrInvalidFPS
+	}
+	return &FrameStreamer{m: m, send: send, fps: fps, fb: 1}, nil
+}
+
+// Run sends the frames received from frames until the channel is closed,
+// ctx is cancelled, in which case it returns the context error, or a send fails.
+func (s *FrameStreamer) Run(ctx context.Context, frames <-chan []packets.LightHsbk) error {
+	send := SendWithContext(ctx, s.send)
+	interval := time.Second / time.Duration(s.fps)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var pending []packets.LightHsbk
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case f, ok := <-frames:
+			if !ok {
+				// Flush the last frame before returning.
+				if pending != nil {
+					return s.sendFrame(send, pending, interval)
+				}
+				return nil
+			}
+			if pending != nil {
+				s.dropped.Add(1)
+			}
+			pending = f
+		case <-ticker.C:
+			if pending == nil {
+				continue
+			}
+			if err := s.sendFrame(send, pending, interval); err != nil {
+				return err
+			}
+			pending = nil
+		}
+	}
+}
+
+// Stats returns the number of frames sent and dropped so far.
+func (s *FrameStreamer) Stats() StreamStats {
+	return StreamStats{Sent: s.sent.Load(), Dropped: s.dropped.Load()}
+}
+
+// sendFrame sends a frame to each tile it covers, transitioning over d.
+func (s *FrameStreamer) sendFrame(send SendFunc, frame []packets.LightHsbk, d time.Duration) error {
+	if len(frame) >= s.m.Size*s.m.ChainLength && s.m.ChainLength > 1 {
+		for ti := range s.m.ChainLength {
+			if err := s.sendTile(send, ti, 1, frame[ti*s.m.Size:(ti+1)*s.m.Size], d); err != nil {
+				return err
+			}
+		}
+	} else if err := s.sendTile(send, 0, max(s.m.ChainLength, 1), frame, d); err != nil {
+		return err
+	}
+
+	s.sent.Add(1)
+	if s.doubleBuffered() {
+		// Swap hidden buffers so the next frame does not overwrite the one being copied.
+		s.fb = 3 - s.fb
+	}
+	return nil
+}
+
+func (s *FrameStreamer) sendTile(send SendFunc, mIdx, mLength int, colors []packets.LightHsbk, d time.Duration) error {
+	colors = colors[:min(len(colors), s.m.Size)]
+
+	var msgs []*protocol.Message
+	if s.doubleBuffered() {
+		msgs = append(messages.SetMatrixFrameBufferColors(mIdx, mLength, s.fb, s.m.Width, colors),
+			messages.SetMatrixVisibleFrameBuffer(mIdx, mLength, s.fb, s.m.Width, s.m.Height, d))
+	} else {
+		msgs = messages.SetMatrixVisibleColors(mIdx, mLength, s.m.Width, colors, d)
+	}
+
+	for _, msg := range msgs {
+		if err := send(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// doubleBuffered reports whether frames are loaded into hidden frame buffers before being shown.
+func (s *FrameStreamer) doubleBuffered() bool {
+	return s.m.Size > device.ZonesPerTilePacket && s.m.FrameBuffers
+}
@@ -0,0 +1,152 @@
+package matrix
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/testutil"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrameStreamer(t *testing.T) {
+	// frame returns a frame of n colors with the given hue.
+	frame := func(n int, hue uint16) []packets.LightHsbk {
+		colors := make([]packets.LightHsbk, n)
+		for i := range colors {
+			colors[i] = packets.LightHsbk{Hue: hue, Brightness: 65535}
+		}
+		return colors
+	}
+
+	t.Run("Fails with an invalid fps", func(t *testing.T) {
+		_, err := NewFrameStreamer(New(8, 8, 1), nil, 0)
+		assert.ErrorIs(t, err, ErrInvalidFPS)
+	})
+
+	t.Run("Drops frames the device cannot keep up with", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(2, 2, 1)
+		s, err := NewFrameStreamer(New(2, 2, 1), rec.Send, 1)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk, 3)
+		for i := range 3 {
+			frames <- frame(4, uint16(i))
+		}
+		close(frames)
+
+		require.NoError(t, s.Run(context.Background(), frames))
+		assert.Equal(t, StreamStats{Sent: 1, Dropped: 2}, s.Stats())
+		require.Len(t, rec.Frames(), 1)
+		assert.Equal(t, frame(4, 2), rec.Frames()[0].Tiles[0])
+	})
+
+	t.Run("Paces frames at the target fps", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(2, 2, 1)
+		s, err := NewFrameStreamer(New(2, 2, 1), rec.Send, 20)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.Run(context.Background(), frames) }()
+
+		frames <- frame(4, 1)
+		assert.Empty(t, rec.Frames())
+		assert.Eventually(t, func() bool { return len(rec.Frames()) == 1 }, time.Second, time.Millisecond)
+		assert.Equal(t, 50*time.Millisecond, rec.Frames()[0].Duration)
+
+		close(frames)
+		require.NoError(t, <-errCh)
+	})
+
+	t.Run("Double buffers large matrices", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(16, 8, 1)
+		var srcFbs []uint8
+		send := func(msg *protocol.Message) error {
+			if p, ok := msg.Payload.(*packets.TileCopyFrameBuffer); ok {
+				srcFbs = append(srcFbs, p.SrcFbIndex)
+			}
+			return rec.Send(msg)
+		}
+		s, err := NewFrameStreamer(New(16, 8, 1), send, 100)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk)
+		errCh := make(chan error, 1)
+		go func() { errCh <- s.Run(context.Background(), frames) }()
+		for i := range 3 {
+			frames <- frame(128, uint16(i))
+			assert.Eventually(t, func() bool { return len(rec.Frames()) == i+1 }, time.Second, time.Millisecond)
+		}
+		close(frames)
+		require.NoError(t, <-errCh)
+
+		assert.Equal(t, []uint8{1, 2, 1}, srcFbs)
+		for i, f := range rec.Frames() {
+			assert.Equal(t, frame(128, uint16(i)), f.Tiles[0])
+		}
+	})
+
+	t.Run("Sets devices without frame buffers directly", func(t *testing.T) {
+		var fbs []uint8
+		send := func(msg *protocol.Message) error {
+			switch p := msg.Payload.(type) {
+			case *packets.TileCopyFrameBuffer:
+				t.Error("Unexpected frame buffer copy")
+			case *packets.TileSet64:
+				fbs = append(fbs, p.Rect.FbIndex)
+			}
+			return nil
+		}
+		m := NewFromDevice(device.MatrixProperties{Width: 16, Height: 8, ChainLength: 1})
+		s, err := NewFrameStreamer(m, send, 100)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk, 1)
+		frames <- frame(128, 1)
+		close(frames)
+		require.NoError(t, s.Run(context.Background(), frames))
+		assert.Equal(t, []uint8{0, 0}, fbs)
+	})
+
+	t.Run("Fans out chain frames to each tile", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(2, 2, 2)
+		s, err := NewFrameStreamer(New(2, 2, 2), rec.Send, 1)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk, 2)
+		frames <- append(frame(4, 1), frame(4, 2)...)
+		close(frames)
+		require.NoError(t, s.Run(context.Background(), frames))
+
+		// A frame is recorded for each tile.
+		got := rec.Frames()
+		require.Len(t, got, 2)
+		assert.Equal(t, [][]packets.LightHsbk{frame(4, 1), frame(4, 2)}, got[1].Tiles)
+	})
+
+	t.Run("Applies single tile frames to the whole chain", func(t *testing.T) {
+		rec := testutil.NewFrameRecorder(2, 2, 2)
+		s, err := NewFrameStreamer(New(2, 2, 2), rec.Send, 1)
+		require.NoError(t, err)
+
+		frames := make(chan []packets.LightHsbk, 1)
+		frames <- frame(4, 1)
+		close(frames)
+		require.NoError(t, s.Run(context.Background(), frames))
+		assert.Equal(t, [][]packets.LightHsbk{frame(4, 1), frame(4, 1)}, rec.Frames()[0].Tiles)
+	})
+
+	t.Run("Stops when ctx is cancelled", func(t *testing.T) {
+		s, err := NewFrameStreamer(New(2, 2, 1), func(*protocol.Message) error { return nil }, 1)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		assert.ErrorIs(t, s.Run(ctx, make(chan []packets.LightHsbk)), context.Canceled)
+	})
+}
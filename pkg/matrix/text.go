@@ -0,0 +1,125 @@
+package matrix
+
+import (
+	"context"
+	"errors"
+
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+var ErrMissingText = errors.New("missing text")
+
+// ScrollDirection is the direction text scrolls in.
+type ScrollDirection int
+
+const (
+	ScrollLeft ScrollDirection = iota
+	ScrollRight
+	ScrollUp
+	ScrollDown
+)
+
+// ScrollText renders text with the given font and scrolls it across the matrix in the given direction,
+// moving by one pixel on each interval. If font is nil Font5x7 is used.
+// Horizontally scrolling text is laid out on a single line and vertically centered, while vertically
+// scrolling text is laid out one character per line and horizontally centered.
+// Matrices with more than 64 zones are updated through a hidden frame buffer so each step is applied at once.
+// It repeats for n cycles, if cycles is set to 0 it repeats indefinitely.
+func ScrollText(m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, direction ScrollDirection, font *Font, text string, color packets.LightHsbk) error {
+	return ScrollTextCtx(context.Background(), m, send, sendIntervalMs, cycles, mode, direction, font, text, color)
+}
+
+// ScrollTextCtx is like ScrollText but stops when ctx is cancelled, returning the context error.
+func ScrollTextCtx(ctx context.Context, m *Matrix, send SendFunc, sendIntervalMs int64, cycles int, mode ChainMode, direction ScrollDirection, font *Font, text string, color packets.LightHsbk) error {
+	cycle, err := scrollTextCycle(m, direction, font, text, color)
+	if err != nil {
+		return err
+	}
+	return runCycles(ctx, m, send, sendIntervalMs, cycles, mode, cycle)
+}
+
+func scrollTextCycle(m *Matrix, direction ScrollDirection, font *Font, text string, color packets.LightHsbk) (cycleFunc, error) {
+	if text == "" {
+		return nil, ErrMissingText
+	}
+	if font == nil {
+		font = Font5x7
+	}
+	bitmap := textBitmap(text, font, direction == ScrollUp || direction == ScrollDown)
+	bh, bw := len(bitmap), len(bitmap[0])
+
+	// Each step moves the text by one pixel, from its first line entering
+	// the matrix to its last line leaving it.
+	steps := bw + m.Width - 1
+	if direction == ScrollUp || direction == ScrollDown {
+		steps = bh + m.Height - 1
+	}
+
+	return func() func() bool {
+		var s int
+		return func() bool {
+			if s == steps {
+				return false
+			}
+			x0, y0 := (m.Width-bw)/2, (m.Height-bh)/2
+			switch direction {
+			case ScrollLeft:
+				x0 = m.MaxX() - s
+			case ScrollRight:
+				x0 = s - bw + 1
+			case ScrollUp:
+				y0 = m.MaxY() - s
+			case ScrollDown:
+				y0 = s - bh + 1
+			}
+
+			m.Clear()
+			drawBitmap(m, x0, y0, bitmap, color)
+			s++
+			return true
+		}
+	}, nil
+}
+
+// drawBitmap sets the lit pixels of bitmap to color, with its top left corner at x0, y0.
+func drawBitmap(m *Matrix, x0, y0 int, bitmap [][]bool, color packets.LightHsbk) {
+	for by, row := range bitmap {
+		for bx, lit := range row {
+			x, y := x0+bx, y0+by
+			if lit && x >= 0 && x < m.Width && y >= 0 && y < m.Height {
+				m.SetPixel(x, y, color)
+			}
+		}
+	}
+}
+
+// textBitmap returns the lit pixels of text rendered with font, row by row.
+// Characters are separated by a blank column, or by a blank row if vertical is set,
+// in which case each character is laid out on its own line.
+func textBitmap(text string, font *Font, vertical bool) [][]bool {
+	runes := []rune(text)
+	width, height := len(runes)*(font.Width+1)-1, font.Height
+	if vertical {
+		width, height = font.Width, len(runes)*(font.Height+1)-1
+	}
+
+	bitmap := make([][]bool, height)
+	for y := range bitmap {
+		bitmap[y] = make([]bool, width)
+	}
+
+	for i, r := range runes {
+		ox, oy := i*(font.Width+1), 0
+		if vertical {
+			ox, oy = 0, i*(font.Height+1)
+		}
+		for x, col := range font.glyph(r) {
+			for y := range font.Height {
+				if x < font.Width && col>>y&1 == 1 {
+					bitmap[oy+y][ox+x] = true
+				}
+			}
+		}
+	}
+	return bitmap
+}
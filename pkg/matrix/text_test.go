@@ -0,0 +1,117 @@
+package matrix
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/internal/testutil"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextBitmap(t *testing.T) {
+	render := func(bitmap [][]bool) string {
+		var rows []string
+		for _, row := range bitmap {
+			var b strings.Builder
+			for _, lit := range row {
+				if lit {
+					b.WriteByte('#')
+				} else {
+					b.WriteByte('.')
+				}
+			}
+			rows = append(rows, b.String())
+		}
+		return strings.Join(rows, "\n")
+	}
+
+	testCases := map[string]struct {
+		text     string
+		vertical bool
+		want     string
+	}{
+		"horizontal": {
+			text: "Hi",
+			want: strings.Join([]string{
+				"#...#...#..",
+				"#...#......",
+				"#...#..##..",
+				"#####...#..",
+				"#...#...#..",
+				"#...#...#..",
+				"#...#..###.",
+			}, "\n"),
+		},
+		"vertical": {
+			text:     "T1",
+			vertical: true,
+			want: strings.Join([]string{
+				"#####",
+				"..#..",
+				"..#..",
+				"..#..",
+				"..#..",
+				"..#..",
+				"..#..",
+				".....",
+				"..#..",
+				".##..",
+				"..#..",
+				"..#..",
+				"..#..",
+				"..#..",
+				".###.",
+			}, "\n"),
+		},
+		"unsupported characters fall back to question mark": {
+			text: "é",
+			want: strings.Join([]string{
+				".###.",
+				"#...#",
+				"....#",
+				"...#.",
+				"..#..",
+				".....",
+				"..#..",
+			}, "\n"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, render(textBitmap(tc.text, Font5x7, tc.vertical)))
+		})
+	}
+}
+
+func TestScrollText(t *testing.T) {
+	color := packets.LightHsbk{Hue: 21845, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	t.Run("Fails without text", func(t *testing.T) {
+		assert.ErrorIs(t, ScrollText(New(8, 8, 1), func(*protocol.Message) error { return nil }, 0, 1, ChainModeNone, ScrollLeft, nil, "", color), ErrMissingText)
+	})
+
+	testCases := map[string]struct {
+		width, height, chainLength int
+		mode                       ChainMode
+		direction                  ScrollDirection
+	}{
+		"scroll_left_8x8":         {width: 8, height: 8, chainLength: 1, direction: ScrollLeft},
+		"scroll_right_8x8_synced": {width: 8, height: 8, chainLength: 2, mode: ChainModeSynced, direction: ScrollRight},
+		"scroll_up_16x8":          {width: 16, height: 8, chainLength: 1, direction: ScrollUp},
+		"scroll_down_8x8":         {width: 8, height: 8, chainLength: 1, direction: ScrollDown},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := testutil.NewFrameRecorder(tc.width, tc.height, tc.chainLength)
+			m := New(tc.width, tc.height, tc.chainLength)
+			require.NoError(t, ScrollText(m, rec.Send, 0, 1, tc.mode, tc.direction, nil, "Hi", color))
+			testutil.AssertFrames(t, rec, filepath.Join("testdata", name+".golden"))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrInvalidButtons is returned when buttons do not fit in a single button message.
+var ErrInvalidButtons = errors.New("invalid buttons")
+
+// GetButtons returns a message requesting the buttons of a device and their actions.
+func GetButtons() *protocol.Message {
+	return protocol.NewMessage(&packets.ButtonGet{})
+}
+
+// SetButtons sets the actions of the device buttons starting at the given index.
+// A message holds up to 8 buttons, each with up to 5 actions.
+func SetButtons(index int, buttons []device.Button) (*protocol.Message, error) {
+	p := &packets.ButtonSet{Index: uint8(index), ButtonsCount: uint8(len(buttons))}
+	if index < 0 || index > 255 || len(buttons) > len(p.Buttons) {
+		return nil, fmt.Errorf("%w: %d buttons at index %d", ErrInvalidButtons, len(buttons), index)
+	}
+	for i, b := range buttons {
+		if len(b.Actions) > len(p.Buttons[i].Actions) {
+			return nil, fmt.Errorf("%w: button %d has %d actions", ErrInvalidButtons, index+i, len(b.Actions))
+		}
+		p.Buttons[i].ActionsCount = uint8(copy(p.Buttons[i].Actions[:], b.Actions))
+	}
+	return protocol.NewMessage(p), nil
+}
+
+// GetButtonConfig returns a message requesting the haptic feedback and backlight configuration of the buttons.
+func GetButtonConfig() *protocol.Message {
+	return protocol.NewMessage(&packets.ButtonGetConfig{})
+}
+
+// SetButtonConfig sets the haptic feedback duration and the backlight colors the buttons show
+// when their target is on and off.
+func SetButtonConfig(cfg device.ButtonConfig) *protocol.Message {
+	return protocol.NewMessage(&packets.ButtonSetConfig{
+		HapticDurationMs:  uint16(min(cfg.HapticDuration.Milliseconds(), math.MaxUint16)),
+		BacklightOnColor:  cfg.BacklightOn,
+		BacklightOffColor: cfg.BacklightOff,
+	})
+}
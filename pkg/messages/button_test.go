@@ -0,0 +1,83 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetButtons(t *testing.T) {
+	press := packets.ButtonAction{
+		Gesture:    enums.ButtonGestureBUTTONGESTUREPRESS,
+		TargetType: enums.ButtonTargetTypeBUTTONTARGETTYPEPOWERTOGGLERELAYS,
+	}
+
+	testCases := map[string]struct {
+		index   int
+		buttons []device.Button
+		want    *protocol.Message
+		wantErr bool
+	}{
+		"Buttons": {
+			index:   1,
+			buttons: []device.Button{{Actions: []packets.ButtonAction{press}}, {}},
+			want: protocol.NewMessage(&packets.ButtonSet{
+				Index: 1, ButtonsCount: 2,
+				Buttons: [8]packets.Button{{ActionsCount: 1, Actions: [5]packets.ButtonAction{press}}},
+			}),
+		},
+		"Too many buttons": {
+			buttons: make([]device.Button, 9),
+			wantErr: true,
+		},
+		"Too many actions": {
+			buttons: []device.Button{{Actions: make([]packets.ButtonAction, 6)}},
+			wantErr: true,
+		},
+		"Invalid index": {
+			index:   -1,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			msg, err := SetButtons(tc.index, tc.buttons)
+			if tc.wantErr {
+				assert.ErrorIs(t, err, ErrInvalidButtons)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, msg)
+		})
+	}
+}
+
+func TestSetButtonConfig(t *testing.T) {
+	on := packets.ButtonBacklightHsbk{Brightness: 65535, Kelvin: 3500}
+	testCases := map[string]struct {
+		cfg  device.ButtonConfig
+		want *protocol.Message
+	}{
+		"Config": {
+			cfg:  device.ButtonConfig{HapticDuration: 50 * time.Millisecond, BacklightOn: on},
+			want: protocol.NewMessage(&packets.ButtonSetConfig{HapticDurationMs: 50, BacklightOnColor: on}),
+		},
+		"Haptic duration is capped": {
+			cfg:  device.ButtonConfig{HapticDuration: time.Hour},
+			want: protocol.NewMessage(&packets.ButtonSetConfig{HapticDurationMs: 65535}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SetButtonConfig(tc.cfg))
+		})
+	}
+}
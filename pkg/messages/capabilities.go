@@ -0,0 +1,62 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrUnsupported is returned when a message requires a feature the device does not support.
+var ErrUnsupported = errors.New("unsupported by device")
+
+// Validate returns an ErrUnsupported error describing the first message that a device with
+// the given capabilities cannot handle, e.g. an HEV cycle sent to a bulb without HEV.
+// Messages that do not depend on a product feature, such as power and label, are always valid.
+func Validate(c device.Capabilities, msgs ...*protocol.Message) error {
+	for _, msg := range msgs {
+		if feature, ok := requiredFeature(c, msg.Payload); !ok {
+			name := strings.TrimPrefix(fmt.Sprintf("%T", msg.Payload), "*packets.")
+			return fmt.Errorf("%w: %s requires %s", ErrUnsupported, name, feature)
+		}
+	}
+	return nil
+}
+
+// requiredFeature returns the feature needed to handle the payload and whether it is supported.
+func requiredFeature(c device.Capabilities, p packets.Payload) (string, bool) {
+	switch p := p.(type) {
+	case *packets.LightGetInfrared, *packets.LightSetInfrared:
+		return "infrared", c.Infrared
+	case *packets.LightGetHevCycle, *packets.LightSetHevCycle,
+		*packets.LightGetHevCycleConfiguration, *packets.LightSetHevCycleConfiguration,
+		*packets.LightGetLastHevCycleResult:
+		return "HEV", c.HEV
+	case *packets.LightGet, *packets.LightSetColor, *packets.LightSetWaveform, *packets.LightSetWaveformOptional,
+		*packets.LightGetPower, *packets.LightSetPower:
+		return "a light", c.Light
+	case *packets.MultiZoneExtendedGetColorZones, *packets.MultiZoneExtendedSetColorZones:
+		return "extended multizone firmware", c.ExtendedMultizone
+	case *packets.MultiZoneGetColorZones, *packets.MultiZoneSetColorZones,
+		*packets.MultiZoneGetEffect, *packets.MultiZoneSetEffect:
+		return "multizone", c.Multizone
+	case *packets.TileSet64:
+		if p.Rect.FbIndex != 0 {
+			return "frame buffers", c.FrameBuffers
+		}
+		return "matrix", c.Matrix
+	case *packets.TileCopyFrameBuffer:
+		return "frame buffers", c.FrameBuffers
+	case *packets.TileGetDeviceChain, *packets.TileSetUserPosition, *packets.TileGet64,
+		*packets.TileGetEffect, *packets.TileSetEffect:
+		return "matrix", c.Matrix
+	case *packets.RelayGetPower, *packets.RelaySetPower:
+		return "relays", c.Relays
+	case *packets.ButtonGet, *packets.ButtonSet, *packets.ButtonGetConfig, *packets.ButtonSetConfig:
+		return "buttons", c.Buttons
+	}
+	return "", true
+}
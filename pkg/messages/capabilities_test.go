@@ -0,0 +1,106 @@
+package messages
+
+import (
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	var (
+		bulb      = device.Capabilities{Light: true, Color: true}
+		hevBulb   = device.Capabilities{Light: true, Color: true, HEV: true}
+		legacyZ   = device.Capabilities{Light: true, Color: true, Multizone: true}
+		tileChain = device.Capabilities{Light: true, Color: true, Matrix: true, Chain: true}
+		ceiling   = device.Capabilities{Light: true, Color: true, Matrix: true, FrameBuffers: true}
+		lswitch   = device.Capabilities{Relays: true, Buttons: true}
+	)
+
+	testCases := map[string]struct {
+		caps    device.Capabilities
+		msgs    []*protocol.Message
+		wantErr string
+	}{
+		"Power is always supported": {
+			caps: lswitch,
+			msgs: []*protocol.Message{SetPowerOn()},
+		},
+		"Color on a light": {
+			caps: bulb,
+			msgs: []*protocol.Message{protocol.NewMessage(&packets.LightSetColor{})},
+		},
+		"Color on a switch": {
+			caps:    lswitch,
+			msgs:    []*protocol.Message{protocol.NewMessage(&packets.LightSetColor{})},
+			wantErr: "unsupported by device: LightSetColor requires a light",
+		},
+		"HEV": {
+			caps: hevBulb,
+			msgs: []*protocol.Message{SetHevCycle(true, 0), GetHevCycle()},
+		},
+		"HEV without support": {
+			caps:    bulb,
+			msgs:    []*protocol.Message{SetPowerOn(), SetHevCycle(true, 0)},
+			wantErr: "unsupported by device: LightSetHevCycle requires HEV",
+		},
+		"Infrared without support": {
+			caps:    bulb,
+			msgs:    []*protocol.Message{protocol.NewMessage(&packets.LightSetInfrared{})},
+			wantErr: "unsupported by device: LightSetInfrared requires infrared",
+		},
+		"Legacy multizone": {
+			caps: legacyZ,
+			msgs: []*protocol.Message{protocol.NewMessage(&packets.MultiZoneGetColorZones{})},
+		},
+		"Extended multizone on legacy firmware": {
+			caps:    legacyZ,
+			msgs:    []*protocol.Message{protocol.NewMessage(&packets.MultiZoneExtendedGetColorZones{})},
+			wantErr: "unsupported by device: MultiZoneExtendedGetColorZones requires extended multizone firmware",
+		},
+		"Visible frame buffer on a chain": {
+			caps: tileChain,
+			msgs: []*protocol.Message{protocol.NewMessage(&packets.TileSet64{})},
+		},
+		"Hidden frame buffer on a chain": {
+			caps:    tileChain,
+			msgs:    []*protocol.Message{protocol.NewMessage(&packets.TileSet64{Rect: packets.TileBufferRect{FbIndex: 1}})},
+			wantErr: "unsupported by device: TileSet64 requires frame buffers",
+		},
+		"Frame buffers": {
+			caps: ceiling,
+			msgs: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{Rect: packets.TileBufferRect{FbIndex: 1}}),
+				protocol.NewMessage(&packets.TileCopyFrameBuffer{SrcFbIndex: 1}),
+			},
+		},
+		"Matrix on a bulb": {
+			caps:    bulb,
+			msgs:    []*protocol.Message{protocol.NewMessage(&packets.TileGetDeviceChain{})},
+			wantErr: "unsupported by device: TileGetDeviceChain requires matrix",
+		},
+		"Relays and buttons": {
+			caps: lswitch,
+			msgs: []*protocol.Message{SetRelayPower(0, true), protocol.NewMessage(&packets.ButtonGet{})},
+		},
+		"Relays on a light": {
+			caps:    bulb,
+			msgs:    []*protocol.Message{SetRelayPower(0, true)},
+			wantErr: "unsupported by device: RelaySetPower requires relays",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(tc.caps, tc.msgs...)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrUnsupported)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
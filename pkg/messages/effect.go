@@ -0,0 +1,225 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrInvalidEffect is returned when the parameters of a firmware effect are out of range.
+var ErrInvalidEffect = errors.New("invalid effect")
+
+const (
+	// MinEffectSpeed and MaxEffectSpeed bound the speed of the firmware effects, the duration of
+	// a cycle, which is sent in milliseconds.
+	MinEffectSpeed = time.Millisecond
+	MaxEffectSpeed = time.Duration(math.MaxUint32) * time.Millisecond
+	// MaxEffectPaletteSize is the number of colors the Morph effect transitions through at most.
+	MaxEffectPaletteSize = 16
+	// maxCloudsMinSaturation is the highest minimum saturation of the Clouds effect, in percent.
+	maxCloudsMinSaturation = 100
+)
+
+// effectPalettes are the preset palettes returned by EffectPalette.
+var effectPalettes = map[string][]device.Color{
+	"autumn": {
+		{Hue: 20, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 35, Saturation: 100, Brightness: 90, Kelvin: 3500},
+		{Hue: 5, Saturation: 90, Brightness: 70, Kelvin: 3500},
+		{Hue: 50, Saturation: 80, Brightness: 90, Kelvin: 3500},
+	},
+	"christmas": {
+		{Hue: 0, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 120, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 0, Saturation: 0, Brightness: 100, Kelvin: 4000},
+		{Hue: 45, Saturation: 80, Brightness: 100, Kelvin: 3500},
+	},
+	"forest": {
+		{Hue: 100, Saturation: 100, Brightness: 80, Kelvin: 3500},
+		{Hue: 130, Saturation: 90, Brightness: 60, Kelvin: 3500},
+		{Hue: 80, Saturation: 70, Brightness: 90, Kelvin: 3500},
+		{Hue: 40, Saturation: 60, Brightness: 50, Kelvin: 3500},
+	},
+	"halloween": {
+		{Hue: 25, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 275, Saturation: 100, Brightness: 80, Kelvin: 3500},
+		{Hue: 90, Saturation: 100, Brightness: 80, Kelvin: 3500},
+		{Hue: 30, Saturation: 100, Brightness: 40, Kelvin: 3500},
+	},
+	"ocean": {
+		{Hue: 190, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 210, Saturation: 100, Brightness: 80, Kelvin: 3500},
+		{Hue: 170, Saturation: 80, Brightness: 90, Kelvin: 3500},
+		{Hue: 230, Saturation: 100, Brightness: 60, Kelvin: 3500},
+	},
+	"sunset": {
+		{Hue: 10, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 35, Saturation: 100, Brightness: 100, Kelvin: 3500},
+		{Hue: 330, Saturation: 80, Brightness: 80, Kelvin: 3500},
+		{Hue: 270, Saturation: 70, Brightness: 60, Kelvin: 3500},
+	},
+}
+
+// EffectPalette returns the preset palette with the given name, case insensitive, e.g. to run
+// SetMatrixMorphEffect with the colors of "Halloween", and whether it exists.
+func EffectPalette(name string) ([]packets.LightHsbk, bool) {
+	colors, ok := effectPalettes[strings.ToLower(name)]
+	if !ok {
+		return nil, false
+	}
+	palette := make([]packets.LightHsbk, len(colors))
+	for i, c := range colors {
+		palette[i] = c.ToDeviceColor()
+	}
+	return palette, true
+}
+
+// EffectPaletteNames returns the names of the preset palettes, sorted.
+func EffectPaletteNames() []string {
+	names := make([]string, 0, len(effectPalettes))
+	for name := range effectPalettes {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ValidateMatrixEffect returns an ErrInvalidEffect error describing the first parameter of the
+// matrix firmware effect out of range, as the device ignores or misrenders them:
+//   - the speed must be within MinEffectSpeed and MaxEffectSpeed, or 0 for the Sky effects
+//     to run at the device default speed;
+//   - the duration must not be negative, 0 running the effect indefinitely;
+//   - Morph needs a palette of 1 to MaxEffectPaletteSize colors;
+//   - Sky needs a known sky type and a Clouds minimum saturation of at most 100.
+func ValidateMatrixEffect(e device.Effect) error {
+	switch e.Type {
+	case device.EffectTypeOff:
+		return nil
+	case device.EffectTypeMorph, device.EffectTypeFlame, device.EffectTypeSky:
+	default:
+		return fmt.Errorf("%w: %s is not a matrix effect", ErrInvalidEffect, e.Type)
+	}
+
+	if (e.Speed != 0 || e.Type != device.EffectTypeSky) && (e.Speed < MinEffectSpeed || e.Speed > MaxEffectSpeed) {
+		return fmt.Errorf("%w: speed %s not within %s and %s", ErrInvalidEffect, e.Speed, MinEffectSpeed, MaxEffectSpeed)
+	}
+	if e.Duration < 0 {
+		return fmt.Errorf("%w: negative duration %s", ErrInvalidEffect, e.Duration)
+	}
+
+	switch e.Type {
+	case device.EffectTypeMorph:
+		if len(e.Palette) == 0 || len(e.Palette) > MaxEffectPaletteSize {
+			return fmt.Errorf("%w: palette of %d colors, expected 1 to %d", ErrInvalidEffect, len(e.Palette), MaxEffectPaletteSize)
+		}
+	case device.EffectTypeSky:
+		switch e.SkyType() {
+		case enums.TileEffectSkyTypeTILEEFFECTSKYTYPECLOUDS:
+			if e.Parameters[1] > maxCloudsMinSaturation {
+				return fmt.Errorf("%w: clouds minimum saturation %d above %d", ErrInvalidEffect, e.Parameters[1], maxCloudsMinSaturation)
+			}
+		case enums.TileEffectSkyTypeTILEEFFECTSKYTYPESUNRISE, enums.TileEffectSkyTypeTILEEFFECTSKYTYPESUNSET:
+		default:
+			return fmt.Errorf("%w: unknown sky type %d", ErrInvalidEffect, e.Parameters[0])
+		}
+	}
+	return nil
+}
+
+// SetMatrixEffect returns a message instructing a matrix device to run the firmware effect,
+// after validating it with ValidateMatrixEffect. An instance ID is generated if not set.
+// Firmware effects run on every device of a chain, as TileSetEffect, unlike TileSet64, carries no
+// tile index or length; the software effects of the matrix package run on part of a chain instead.
+func SetMatrixEffect(e device.Effect) (*protocol.Message, error) {
+	if err := ValidateMatrixEffect(e); err != nil {
+		return nil, err
+	}
+	if e.InstanceID == 0 {
+		e.InstanceID = rand.Uint32()
+	}
+	return SetEffect(device.LightTypeMatrix, e)
+}
+
+// SetMatrixEffectWithDuration returns a copy of a matrix or multizone effect message, as returned by
+// SetMatrixFlameEffect or SetMultizoneMoveEffect, that stops the effect after the given duration,
+// 0 running it indefinitely.
+// The device stops the effect itself, so it does not depend on the client staying connected.
+func SetMatrixEffectWithDuration(msg *protocol.Message, d time.Duration) (*protocol.Message, error) {
+	if d < 0 {
+		return nil, fmt.Errorf("%w: negative duration %s", ErrInvalidEffect, d)
+	}
+
+	c := msg.Clone()
+	switch p := c.Payload.(type) {
+	case *packets.TileSetEffect:
+		p.Settings.Duration = uint64(d)
+	case *packets.MultiZoneSetEffect:
+		p.Settings.Duration = uint64(d)
+	default:
+		return nil, fmt.Errorf("%w: %T is not an effect message", ErrInvalidEffect, msg.Payload)
+	}
+	return c, nil
+}
+
+// SetEffect returns a message instructing a device of the given light type to run the firmware effect,
+// e.g. to resume the effect reported in device.Device.Effect with the same instance ID and parameters.
+// It returns ErrUnsupported if the effect cannot run on the light type, e.g. Move on a matrix device.
+func SetEffect(lightType device.LightType, e device.Effect) (*protocol.Message, error) {
+	switch {
+	case lightType == device.LightTypeMultiZone && (e.Type == device.EffectTypeOff || e.Type == device.EffectTypeMove):
+		t := enums.MultiZoneEffectTypeMULTIZONEEFFECTTYPEOFF
+		if e.Type == device.EffectTypeMove {
+			t = enums.MultiZoneEffectTypeMULTIZONEEFFECTTYPEMOVE
+		}
+		return protocol.NewMessage(&packets.MultiZoneSetEffect{
+			Settings: packets.MultiZoneEffectSettings{
+				Instanceid: e.InstanceID,
+				Type:       t,
+				Speed:      uint32(e.Speed.Milliseconds()),
+				Duration:   uint64(e.Duration),
+				Parameter:  packets.MultiZoneEffectParameter(effectParameter(e.Parameters)),
+			},
+		}), nil
+	case lightType == device.LightTypeMatrix && e.Type != device.EffectTypeMove:
+		var t enums.TileEffectType
+		switch e.Type {
+		case device.EffectTypeMorph:
+			t = enums.TileEffectTypeTILEEFFECTTYPEMORPH
+		case device.EffectTypeFlame:
+			t = enums.TileEffectTypeTILEEFFECTTYPEFLAME
+		case device.EffectTypeSky:
+			t = enums.TileEffectTypeTILEEFFECTTYPESKY
+		}
+		var palette [16]packets.LightHsbk
+		n := copy(palette[:], e.Palette)
+		return protocol.NewMessage(&packets.TileSetEffect{
+			Settings: packets.TileEffectSettings{
+				Instanceid:   e.InstanceID,
+				Type:         t,
+				Speed:        uint32(e.Speed.Milliseconds()),
+				Duration:     uint64(e.Duration),
+				Parameter:    effectParameter(e.Parameters),
+				PaletteCount: uint8(n),
+				Palette:      palette,
+			},
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: %s effect on a %s light", ErrUnsupported, e.Type, lightType)
+}
+
+// effectParameter converts the effect parameters, which multizone effects share with matrix ones.
+func effectParameter(p [8]uint32) packets.TileEffectParameter {
+	return packets.TileEffectParameter{
+		Parameter0: p[0], Parameter1: p[1], Parameter2: p[2], Parameter3: p[3],
+		Parameter4: p[4], Parameter5: p[5], Parameter6: p[6], Parameter7: p[7],
+	}
+}
@@ -0,0 +1,191 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetEffect(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		lightType device.LightType
+		effect    device.Effect
+		want      packets.Payload
+		wantErr   string
+	}{
+		"Move": {
+			lightType: device.LightTypeMultiZone,
+			effect:    device.Effect{Type: device.EffectTypeMove, InstanceID: 3, Speed: time.Second, Parameters: [8]uint32{0, 1}},
+			want: &packets.MultiZoneSetEffect{Settings: packets.MultiZoneEffectSettings{
+				Instanceid: 3, Type: enums.MultiZoneEffectTypeMULTIZONEEFFECTTYPEMOVE, Speed: 1000,
+				Parameter: packets.MultiZoneEffectParameter{Parameter1: 1},
+			}},
+		},
+		"Multizone off": {
+			lightType: device.LightTypeMultiZone,
+			want:      &packets.MultiZoneSetEffect{},
+		},
+		"Morph": {
+			lightType: device.LightTypeMatrix,
+			effect:    device.Effect{Type: device.EffectTypeMorph, InstanceID: 7, Duration: time.Minute, Palette: []packets.LightHsbk{red}},
+			want: &packets.TileSetEffect{Settings: packets.TileEffectSettings{
+				Instanceid: 7, Type: enums.TileEffectTypeTILEEFFECTTYPEMORPH, Duration: uint64(time.Minute),
+				PaletteCount: 1, Palette: [16]packets.LightHsbk{red},
+			}},
+		},
+		"Matrix off": {
+			lightType: device.LightTypeMatrix,
+			want:      &packets.TileSetEffect{},
+		},
+		"Move on a matrix": {
+			lightType: device.LightTypeMatrix,
+			effect:    device.Effect{Type: device.EffectTypeMove},
+			wantErr:   "unsupported by device: move effect on a matrix light",
+		},
+		"Flame on a strip": {
+			lightType: device.LightTypeMultiZone,
+			effect:    device.Effect{Type: device.EffectTypeFlame},
+			wantErr:   "unsupported by device: flame effect on a multi_zone light",
+		},
+		"Single zone": {
+			lightType: device.LightTypeSingleZone,
+			wantErr:   "unsupported by device: off effect on a single_zone light",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			msg, err := SetEffect(tc.lightType, tc.effect)
+			if tc.wantErr != "" {
+				assert.ErrorIs(t, err, ErrUnsupported)
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, msg.Payload)
+		})
+	}
+}
+
+func TestValidateMatrixEffect(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		effect  device.Effect
+		wantErr string
+	}{
+		"Off": {},
+		"Flame": {
+			effect: device.Effect{Type: device.EffectTypeFlame, Speed: 3 * time.Second},
+		},
+		"Morph": {
+			effect: device.Effect{Type: device.EffectTypeMorph, Speed: time.Second, Palette: []packets.LightHsbk{red}},
+		},
+		"Sunrise at the default speed": {
+			effect: device.Effect{Type: device.EffectTypeSky, Duration: time.Hour},
+		},
+		"Move": {
+			effect:  device.Effect{Type: device.EffectTypeMove, Speed: time.Second},
+			wantErr: "invalid effect: move is not a matrix effect",
+		},
+		"Zero speed": {
+			effect:  device.Effect{Type: device.EffectTypeFlame},
+			wantErr: "invalid effect: speed 0s not within 1ms and 1193h2m47.295s",
+		},
+		"Speed too high": {
+			effect:  device.Effect{Type: device.EffectTypeSky, Speed: MaxEffectSpeed + time.Millisecond},
+			wantErr: "invalid effect: speed 1193h2m47.296s not within 1ms and 1193h2m47.295s",
+		},
+		"Negative duration": {
+			effect:  device.Effect{Type: device.EffectTypeFlame, Speed: time.Second, Duration: -time.Second},
+			wantErr: "invalid effect: negative duration -1s",
+		},
+		"Empty palette": {
+			effect:  device.Effect{Type: device.EffectTypeMorph, Speed: time.Second},
+			wantErr: "invalid effect: palette of 0 colors, expected 1 to 16",
+		},
+		"Palette too large": {
+			effect:  device.Effect{Type: device.EffectTypeMorph, Speed: time.Second, Palette: make([]packets.LightHsbk, 17)},
+			wantErr: "invalid effect: palette of 17 colors, expected 1 to 16",
+		},
+		"Clouds saturation too high": {
+			effect:  device.Effect{Type: device.EffectTypeSky, Parameters: [8]uint32{2, 101}},
+			wantErr: "invalid effect: clouds minimum saturation 101 above 100",
+		},
+		"Unknown sky type": {
+			effect:  device.Effect{Type: device.EffectTypeSky, Parameters: [8]uint32{3}},
+			wantErr: "invalid effect: unknown sky type 3",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateMatrixEffect(tc.effect)
+			if tc.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidEffect)
+			assert.EqualError(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestSetMatrixEffect(t *testing.T) {
+	msg, err := SetMatrixEffect(device.Effect{Type: device.EffectTypeFlame, Speed: time.Second})
+	require.NoError(t, err)
+	p := msg.Payload.(*packets.TileSetEffect)
+	assert.Equal(t, enums.TileEffectTypeTILEEFFECTTYPEFLAME, p.Settings.Type)
+	assert.NotZero(t, p.Settings.Instanceid)
+
+	_, err = SetMatrixEffect(device.Effect{Type: device.EffectTypeFlame})
+	assert.ErrorIs(t, err, ErrInvalidEffect)
+}
+
+func TestSetMatrixEffectWithDuration(t *testing.T) {
+	t.Run("Matrix", func(t *testing.T) {
+		flame := SetMatrixFlameEffect(time.Second)
+		msg, err := SetMatrixEffectWithDuration(flame, time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(time.Minute), msg.Payload.(*packets.TileSetEffect).Settings.Duration)
+		// The original message is left unchanged.
+		assert.Zero(t, flame.Payload.(*packets.TileSetEffect).Settings.Duration)
+	})
+
+	t.Run("Multizone", func(t *testing.T) {
+		msg, err := SetMatrixEffectWithDuration(SetMultizoneMoveEffect(time.Second, true), time.Minute)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(time.Minute), msg.Payload.(*packets.MultiZoneSetEffect).Settings.Duration)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := SetMatrixEffectWithDuration(SetMatrixFlameEffect(time.Second), -time.Second)
+		assert.EqualError(t, err, "invalid effect: negative duration -1s")
+		_, err = SetMatrixEffectWithDuration(SetPowerOn(), time.Second)
+		assert.EqualError(t, err, "invalid effect: *packets.DeviceSetPower is not an effect message")
+	})
+}
+
+func TestEffectPalette(t *testing.T) {
+	assert.Equal(t, []string{"autumn", "christmas", "forest", "halloween", "ocean", "sunset"}, EffectPaletteNames())
+
+	for _, name := range EffectPaletteNames() {
+		palette, ok := EffectPalette(name)
+		require.True(t, ok, name)
+		assert.NoError(t, ValidateMatrixEffect(device.Effect{Type: device.EffectTypeMorph, Speed: time.Second, Palette: palette}), name)
+	}
+
+	palette, ok := EffectPalette("Halloween")
+	require.True(t, ok)
+	assert.Equal(t, packets.LightHsbk{Hue: 4551, Saturation: 65535, Brightness: 65535, Kelvin: 3500}, palette[0])
+
+	_, ok = EffectPalette("disco")
+	assert.False(t, ok)
+}
@@ -0,0 +1,53 @@
+package messages
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrInvalidLabel is returned when a label is empty or longer than the 32 bytes a device can store.
+var ErrInvalidLabel = errors.New("invalid label")
+
+// SetGroup moves a device to the group with the given id and label.
+// Devices sharing the same id are in the same group, which takes the label with the latest updatedAt.
+func SetGroup(label string, id [16]byte, updatedAt time.Time) (*protocol.Message, error) {
+	l, err := encodeLabel(label)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewMessage(&packets.DeviceSetGroup{Group: id, Label: l, UpdatedAt: uint64(updatedAt.UnixNano())}), nil
+}
+
+// SetLocation moves a device to the location with the given id and label.
+// Devices sharing the same id are in the same location, which takes the label with the latest updatedAt.
+func SetLocation(label string, id [16]byte, updatedAt time.Time) (*protocol.Message, error) {
+	l, err := encodeLabel(label)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewMessage(&packets.DeviceSetLocation{Location: id, Label: l, UpdatedAt: uint64(updatedAt.UnixNano())}), nil
+}
+
+// NewID returns a random version 4 UUID to identify a new group or location.
+func NewID() [16]byte {
+	var id [16]byte
+	rand.Read(id[:])
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// encodeLabel returns the label in the fixed size format used by devices.
+func encodeLabel(label string) ([32]byte, error) {
+	var l [32]byte
+	if label == "" || len(label) > len(l) {
+		return l, fmt.Errorf("%w: %q", ErrInvalidLabel, label)
+	}
+	copy(l[:], label)
+	return l, nil
+}
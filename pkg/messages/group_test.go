@@ -0,0 +1,76 @@
+package messages
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetGroupAndLocation(t *testing.T) {
+	id := [16]byte{1, 2, 3}
+	updatedAt := time.Unix(1700000000, 5)
+
+	testCases := map[string]struct {
+		build   func(label string, id [16]byte, updatedAt time.Time) (*protocol.Message, error)
+		label   string
+		want    packets.Payload
+		wantErr error
+	}{
+		"group": {
+			build: SetGroup,
+			label: "Bedroom",
+			want: &packets.DeviceSetGroup{
+				Group: id, Label: [32]byte{'B', 'e', 'd', 'r', 'o', 'o', 'm'}, UpdatedAt: 1700000000000000005,
+			},
+		},
+		"location": {
+			build: SetLocation,
+			label: "Home",
+			want: &packets.DeviceSetLocation{
+				Location: id, Label: [32]byte{'H', 'o', 'm', 'e'}, UpdatedAt: 1700000000000000005,
+			},
+		},
+		"label fills the field": {
+			build: SetGroup,
+			label: strings.Repeat("a", 32),
+			want: &packets.DeviceSetGroup{
+				Group: id, Label: [32]byte([]byte(strings.Repeat("a", 32))), UpdatedAt: 1700000000000000005,
+			},
+		},
+		"empty label": {
+			build:   SetGroup,
+			wantErr: ErrInvalidLabel,
+		},
+		"label too long": {
+			build:   SetLocation,
+			label:   strings.Repeat("a", 33),
+			wantErr: ErrInvalidLabel,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.build(tc.label, id, updatedAt)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Payload)
+		})
+	}
+}
+
+func TestNewID(t *testing.T) {
+	a, b := NewID(), NewID()
+	assert.NotEqual(t, a, b)
+	for _, id := range [][16]byte{a, b} {
+		assert.Equal(t, byte(0x40), id[6]&0xf0, "version")
+		assert.Equal(t, byte(0x80), id[8]&0xc0, "variant")
+	}
+}
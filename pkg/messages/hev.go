@@ -0,0 +1,35 @@
+package messages
+
+import (
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// GetHevCycle returns a message requesting the state of the current HEV cycle.
+func GetHevCycle() *protocol.Message {
+	return protocol.NewMessage(&packets.LightGetHevCycle{})
+}
+
+// SetHevCycle starts or stops a HEV cycle.
+// If d is 0 the device uses its configured default cycle duration.
+func SetHevCycle(enable bool, d time.Duration) *protocol.Message {
+	return protocol.NewMessage(&packets.LightSetHevCycle{Enable: enable, DurationS: uint32(d.Seconds())})
+}
+
+// GetHevCycleConfiguration returns a message requesting the default HEV cycle configuration.
+func GetHevCycleConfiguration() *protocol.Message {
+	return protocol.NewMessage(&packets.LightGetHevCycleConfiguration{})
+}
+
+// SetHevCycleConfiguration sets the default HEV cycle duration and whether the device
+// briefly flashes at the end of a cycle to indicate its completion.
+func SetHevCycleConfiguration(indication bool, d time.Duration) *protocol.Message {
+	return protocol.NewMessage(&packets.LightSetHevCycleConfiguration{Indication: indication, DurationS: uint32(d.Seconds())})
+}
+
+// GetLastHevCycleResult returns a message requesting the result of the last HEV cycle.
+func GetLastHevCycleResult() *protocol.Message {
+	return protocol.NewMessage(&packets.LightGetLastHevCycleResult{})
+}
@@ -0,0 +1,42 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetHevCycle(t *testing.T) {
+	testCases := map[string]struct {
+		enable bool
+		d      time.Duration
+		want   *protocol.Message
+	}{
+		"start with default duration": {
+			enable: true,
+			want:   protocol.NewMessage(&packets.LightSetHevCycle{Enable: true}),
+		},
+		"start with duration": {
+			enable: true,
+			d:      2 * time.Hour,
+			want:   protocol.NewMessage(&packets.LightSetHevCycle{Enable: true, DurationS: 7200}),
+		},
+		"stop": {
+			want: protocol.NewMessage(&packets.LightSetHevCycle{}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SetHevCycle(tc.enable, tc.d))
+		})
+	}
+}
+
+func TestSetHevCycleConfiguration(t *testing.T) {
+	want := protocol.NewMessage(&packets.LightSetHevCycleConfiguration{Indication: true, DurationS: 3600})
+	assert.Equal(t, want, SetHevCycleConfiguration(true, time.Hour))
+}
@@ -1,9 +1,12 @@
 package messages
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
 	"time"
 
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -20,11 +23,13 @@ func SetMatrixColors(startIndex, length, width int, colors [64]packets.LightHsbk
 
 // SetMatrixColorsFromSlice returns one or more TileSet64 messages according to a slice of packets.LightHsbk.
 // If the slice does not contain all the 64 colors then the default zero value is used.
+// Use PlanMatrixColors to validate the input and know which message applies the colors.
 func SetMatrixColorsFromSlice(startIndex, length, width int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
-	var msgs []*protocol.Message
-	hsbk := [64]packets.LightHsbk{}
-	var tileIndex int
+	return matrixColorsPlan(startIndex, length, width, colors, d).Messages
+}
 
+func matrixColorsPlan(startIndex, length, width int, colors []packets.LightHsbk, d time.Duration) Plan {
+	var msgs []*protocol.Message
 	var fb int
 	var flipDuration time.Duration
 	if len(colors) > 64 {
@@ -33,26 +38,86 @@ func SetMatrixColorsFromSlice(startIndex, length, width int, colors []packets.Li
 		d = 0
 	}
 
-	for i, c := range colors {
-		hsbk[i%64] = c
-
-		if (i+1)%64 == 0 || i == len(colors)-1 {
-			y := tileIndex * 64 / width
-			msgs = append(msgs, newTileSet64Msg(startIndex, length, fb, width, 0, y, hsbk, d))
-			hsbk = [64]packets.LightHsbk{}
-			tileIndex++
-		}
-	}
+	forEachTilePacket(width, colors, func(y int, hsbk [64]packets.LightHsbk) {
+		msgs = append(msgs, newTileSet64Msg(startIndex, length, fb, width, 0, y, hsbk, d))
+	})
 
 	if fb == 1 {
-		// Compute height based on the width and length of colors
-		height := len(colors) / width
+		// Compute height based on the width and length of colors, including a partial last row.
+		height := (len(colors) + width - 1) / width
 		msgs = append(msgs, SetMatrixVisibleFrameBuffer(startIndex, length, fb, width, height, flipDuration))
 	}
 
+	return Plan{Messages: msgs, ApplyIndex: len(msgs) - 1}
+}
+
+// SetMatrixDeviceColors returns the messages setting the colors of a matrix device from the tile at startIndex,
+// with the strategy supported by the device as reported by its properties.
+// Colors covering more than one tile are split into a slice of NZones colors per tile, each set with its own messages.
+// Tiles of devices supporting frame buffers that need more than one message are loaded into a hidden frame buffer
+// and made visible at once, while the tiles of other devices, such as the original LIFX Tile, are set directly.
+func SetMatrixDeviceColors(p device.MatrixProperties, startIndex int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	tileSize := len(colors)
+	if p.NZones > 0 {
+		tileSize = p.NZones
+	}
+
+	var msgs []*protocol.Message
+	for offset, ti := 0, startIndex; offset < len(colors); offset, ti = offset+tileSize, ti+1 {
+		tile := colors[offset:min(len(colors), offset+tileSize)]
+		if p.FrameBuffers {
+			msgs = append(msgs, SetMatrixColorsFromSlice(ti, 1, p.Width, tile, d)...)
+		} else {
+			msgs = append(msgs, SetMatrixVisibleColors(ti, 1, p.Width, tile, d)...)
+		}
+	}
 	return msgs
 }
 
+// SetMatrixVisibleColors returns one or more TileSet64 messages that set colors directly in the visible frame buffer,
+// for devices without hidden frame buffers. Colors needing more than one message are shown as each message is handled.
+func SetMatrixVisibleColors(startIndex, length, width int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	var msgs []*protocol.Message
+	forEachTilePacket(width, colors, func(y int, hsbk [64]packets.LightHsbk) {
+		msgs = append(msgs, newTileSet64Msg(startIndex, length, 0, width, 0, y, hsbk, d))
+	})
+	return msgs
+}
+
+// SetMatrixRectColors returns one or more TileSet64 messages that set the colors of a rectangle of the given
+// width whose top-left corner is at (x, y), on tiles of the given height. This allows narrow segments,
+// such as a column of a few pixels, to be updated without resending whole rows.
+// Colors are laid out row by row and are split into messages of 64/width rows. Each message sets 64 colors,
+// so the rectangle must be a whole number of 64 colors messages tall, or reach the bottom of the tile, for the
+// zones outside of it to be left untouched. Otherwise ErrInvalidRect is returned.
+// The number of colors must be a multiple of width and the rectangle must fit within the tile.
+func SetMatrixRectColors(startIndex, length, x, y, width, tileHeight int, colors []packets.LightHsbk, d time.Duration) ([]*protocol.Message, error) {
+	if len(colors) == 0 {
+		return nil, ErrNoColors
+	}
+	if startIndex < 0 || startIndex > math.MaxUint8 || length < 1 || length > math.MaxUint8 {
+		return nil, fmt.Errorf("%w: tile index %d, length %d", ErrInvalidIndex, startIndex, length)
+	}
+	if width < 1 || width > 64 || len(colors)%width != 0 {
+		return nil, fmt.Errorf("%w: %d for %d colors", ErrInvalidWidth, width, len(colors))
+	}
+	rows := len(colors) / width
+	if x < 0 || y < 0 || x+width-1 > math.MaxUint8 || y+rows > min(tileHeight, math.MaxUint8+1) {
+		return nil, fmt.Errorf("%w: %dx%d rectangle at (%d, %d)", ErrInvalidIndex, width, rows, x, y)
+	}
+	// The colors past the rectangle in the last message are set in the rows below it, unless beyond the tile.
+	if rowsPerPacket := device.ZonesPerTilePacket / width; y+rows < tileHeight &&
+		(device.ZonesPerTilePacket%width != 0 || rows%rowsPerPacket != 0) {
+		return nil, fmt.Errorf("%w: %dx%d rectangle at (%d, %d) is not a multiple of %d rows", ErrInvalidRect, width, rows, x, y, rowsPerPacket)
+	}
+
+	var msgs []*protocol.Message
+	forEachTilePacket(width, colors, func(row int, hsbk [64]packets.LightHsbk) {
+		msgs = append(msgs, newTileSet64Msg(startIndex, length, 0, width, x, y+row, hsbk, d))
+	})
+	return msgs, nil
+}
+
 // SetMatrixEffectOff returns a message instructing the device to turn any running matrix effect off.
 func SetMatrixEffectOff() *protocol.Message {
 	return protocol.NewMessage(&packets.TileSetEffect{
@@ -169,22 +234,12 @@ func SetMatrixFrameAnimation(startIndex, length, width int, frames [][]packets.L
 
 	// Load each frame into fb 1..N
 	for fb := range frameCount {
-		colors := frames[fb]
-
-		hsbk := [64]packets.LightHsbk{}
-		tileIndex := 0
-
-		for i, c := range colors {
-			c.Brightness = uint16(float64(c.Brightness) / 100 * brightness)
-			hsbk[i%64] = c
-
-			if (i+1)%64 == 0 || i == len(colors)-1 {
-				y := tileIndex * 64 / width
-				msgs = append(msgs, newTileSet64Msg(startIndex, length, fb+1, width, 0, y, hsbk, 0))
-				hsbk = [64]packets.LightHsbk{}
-				tileIndex++
-			}
+		colors := make([]packets.LightHsbk, len(frames[fb]))
+		for i, c := range frames[fb] {
+			colors[i] = ScaleBrightness(c, brightness, 1)
 		}
+
+		msgs = append(msgs, SetMatrixFrameBufferColors(startIndex, length, fb+1, width, colors)...)
 	}
 
 	// activeFrame is the index of the last frame copied into the visible buffer (0).
@@ -194,10 +249,32 @@ func SetMatrixFrameAnimation(startIndex, length, width int, frames [][]packets.L
 		// nextFrameFb is the frame buffer that will be copied into the visible frame buffer (0).
 		nextFrameFb := activeFrame + 1
 		activeFrame = (nextFrameFb) % frameCount
-		return SetMatrixVisibleFrameBuffer(startIndex, length, nextFrameFb, width, len(frames[0])/width, d)
+		return SetMatrixVisibleFrameBuffer(startIndex, length, nextFrameFb, width, (len(frames[0])+width-1)/width, d)
 	}
 }
 
+// ScaleBrightness returns c with its brightness gamma corrected and then scaled to the given percentage.
+// A gamma of 1 leaves the brightness linear, while greater values darken the dim colors so that brightness
+// steps look even to the eye.
+func ScaleBrightness(c packets.LightHsbk, percent, gamma float64) packets.LightHsbk {
+	b := float64(c.Brightness)
+	if gamma > 0 && gamma != 1 {
+		b = math.Pow(b/math.MaxUint16, gamma) * math.MaxUint16
+	}
+	c.Brightness = uint16(b / 100 * min(max(percent, 0), 100))
+	return c
+}
+
+// SetMatrixFrameBufferColors returns one or more TileSet64 messages that load colors into the given frame buffer (fb),
+// which can then be made visible at once with SetMatrixVisibleFrameBuffer.
+func SetMatrixFrameBufferColors(startIndex, length, fb, width int, colors []packets.LightHsbk) []*protocol.Message {
+	var msgs []*protocol.Message
+	forEachTilePacket(width, colors, func(y int, hsbk [64]packets.LightHsbk) {
+		msgs = append(msgs, newTileSet64Msg(startIndex, length, fb, width, 0, y, hsbk, 0))
+	})
+	return msgs
+}
+
 // SetMatrixVisibleFrameBuffer copies the given frame buffer (fb) into the visible frame buffer (0).
 // This can be used to switch between previously stored frame buffers for animations or smooth transitions (as in the case
 // of matrix that exceeds 64 colors and therefore needs multiple messages to be set).
@@ -213,6 +290,24 @@ func SetMatrixVisibleFrameBuffer(startIndex, length, fb, width, height int, d ti
 	})
 }
 
+// forEachTilePacket splits colors laid out in rows of the given width into the colors of consecutive
+// TileSet64 messages, each starting at the beginning of a row, and calls f with the colors of each
+// message and the row it starts at.
+// Widths that cannot be split into rows are split every 64 colors.
+func forEachTilePacket(width int, colors []packets.LightHsbk, f func(y int, hsbk [64]packets.LightHsbk)) {
+	width = max(width, 1)
+	chunkSize := device.ZonesPerTilePacket
+	if rows, err := device.MatrixRowsPerPacket(width); err == nil {
+		chunkSize = rows * width
+	}
+
+	for offset := 0; offset < len(colors); offset += chunkSize {
+		var hsbk [64]packets.LightHsbk
+		copy(hsbk[:], colors[offset:min(len(colors), offset+chunkSize)])
+		f(offset/width, hsbk)
+	}
+}
+
 func newTileSet64Msg(startIndex, length, fb, width, x, y int, colors [64]packets.LightHsbk, d time.Duration) *protocol.Message {
 	m := &packets.TileSet64{
 		TileIndex: uint8(startIndex),
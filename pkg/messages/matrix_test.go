@@ -2,12 +2,15 @@ package messages
 
 import (
 	"math/rand/v2"
+	"slices"
 	"testing"
 	"time"
 
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetMatrixColorsFromSlice(t *testing.T) {
@@ -113,6 +116,67 @@ func TestSetMatrixColorsFromSlice(t *testing.T) {
 				}),
 			},
 		},
+		"greater than 64 colors (with partial row)": {
+			length: 1,
+			width:  16,
+			colors: greaterThan64PartialSlice[:90],
+			d:      time.Millisecond,
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 0, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16, X: 0, Y: 0},
+					Duration: 0, Colors: greaterThan64PartialArray1,
+				}),
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 0, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16, X: 0, Y: 4},
+					Duration: 0, Colors: [64]packets.LightHsbk(slices.Concat(greaterThan64PartialSlice[64:90], make([]packets.LightHsbk, 38))),
+				}),
+				protocol.NewMessage(&packets.TileCopyFrameBuffer{
+					TileIndex: 0, Length: 1, SrcFbIndex: 1, DstFbIndex: 0,
+					Width: 16, Height: 6, Duration: 1,
+				}),
+			},
+		},
+		"greater than 64 colors with a width not dividing 64": {
+			length: 1,
+			width:  12,
+			colors: greaterThan64PartialSlice,
+			d:      time.Millisecond,
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 0, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 12, X: 0, Y: 0},
+					Duration: 0, Colors: [64]packets.LightHsbk(slices.Concat(greaterThan64PartialSlice[:60], make([]packets.LightHsbk, 4))),
+				}),
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 0, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 12, X: 0, Y: 5},
+					Duration: 0, Colors: [64]packets.LightHsbk(slices.Concat(greaterThan64PartialSlice[60:], make([]packets.LightHsbk, 28))),
+				}),
+				protocol.NewMessage(&packets.TileCopyFrameBuffer{
+					TileIndex: 0, Length: 1, SrcFbIndex: 1, DstFbIndex: 0,
+					Width: 12, Height: 8, Duration: 1,
+				}),
+			},
+		},
+		"greater than 64 colors on a chain": {
+			startIndex: 2,
+			length:     3,
+			width:      16,
+			colors:     greaterThan64Slice,
+			d:          time.Millisecond,
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 2, Length: 3, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16, X: 0, Y: 0},
+					Duration: 0, Colors: greaterThan64Array1,
+				}),
+				protocol.NewMessage(&packets.TileSet64{
+					TileIndex: 2, Length: 3, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16, X: 0, Y: 4},
+					Duration: 0, Colors: greaterThan64Array2,
+				}),
+				protocol.NewMessage(&packets.TileCopyFrameBuffer{
+					TileIndex: 2, Length: 3, SrcFbIndex: 1, DstFbIndex: 0,
+					Width: 16, Height: 8, Duration: 1,
+				}),
+			},
+		},
 	}
 
 	for name, tc := range testCases {
@@ -123,6 +187,237 @@ func TestSetMatrixColorsFromSlice(t *testing.T) {
 	}
 }
 
+func TestSetMatrixRectColors(t *testing.T) {
+	column := make([]packets.LightHsbk, 2*40)
+	for i := range column {
+		column[i] = packets.LightHsbk{Hue: uint16(i)}
+	}
+	var columnArray1, columnArray2 [64]packets.LightHsbk
+	copy(columnArray1[:], column[:64])
+	copy(columnArray2[:], column[64:])
+
+	testCases := map[string]struct {
+		x, y       int
+		width      int
+		tileHeight int
+		colors     []packets.LightHsbk
+		want       []*protocol.Message
+		wantErr    error
+	}{
+		"no colors": {
+			width:   2,
+			wantErr: ErrNoColors,
+		},
+		"invalid width": {
+			colors:  column,
+			wantErr: ErrInvalidWidth,
+		},
+		"colors not a multiple of width": {
+			width:   3,
+			colors:  column[:65],
+			wantErr: ErrInvalidWidth,
+		},
+		"rectangle exceeding x": {
+			x:       250,
+			width:   8,
+			colors:  column[:64],
+			wantErr: ErrInvalidIndex,
+		},
+		"rectangle exceeding y": {
+			y:          250,
+			width:      2,
+			tileHeight: 256,
+			colors:     column,
+			wantErr:    ErrInvalidIndex,
+		},
+		"rectangle exceeding the tile": {
+			y:          8,
+			width:      8,
+			tileHeight: 8,
+			colors:     column[:64],
+			wantErr:    ErrInvalidIndex,
+		},
+		"rectangle overwriting the rows below": {
+			x:          2,
+			y:          2,
+			width:      3,
+			tileHeight: 8,
+			colors:     column[:3],
+			wantErr:    ErrInvalidRect,
+		},
+		"rectangle not a multiple of the message rows": {
+			width:      8,
+			tileHeight: 16,
+			colors:     column[:72],
+			wantErr:    ErrInvalidRect,
+		},
+		"width not dividing 64 above the bottom": {
+			width:      3,
+			tileHeight: 32,
+			colors:     column[:63],
+			wantErr:    ErrInvalidRect,
+		},
+		"whole message rows": {
+			y:          8,
+			width:      8,
+			tileHeight: 24,
+			colors:     column[:64],
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					Length: 1, Rect: packets.TileBufferRect{Width: 8, Y: 8},
+					Duration: 1, Colors: columnArray1,
+				}),
+			},
+		},
+		"narrow column": {
+			x:          5,
+			y:          2,
+			width:      2,
+			tileHeight: 42,
+			colors:     column,
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					Length: 1, Rect: packets.TileBufferRect{Width: 2, X: 5, Y: 2},
+					Duration: 1, Colors: columnArray1,
+				}),
+				protocol.NewMessage(&packets.TileSet64{
+					Length: 1, Rect: packets.TileBufferRect{Width: 2, X: 5, Y: 34},
+					Duration: 1, Colors: columnArray2,
+				}),
+			},
+		},
+		"width not dividing 64": {
+			x:          1,
+			width:      3,
+			tileHeight: 22,
+			colors:     column[:66],
+			want: []*protocol.Message{
+				protocol.NewMessage(&packets.TileSet64{
+					Length: 1, Rect: packets.TileBufferRect{Width: 3, X: 1},
+					Duration: 1, Colors: [64]packets.LightHsbk(append(slices.Clone(column[:63]), packets.LightHsbk{})),
+				}),
+				protocol.NewMessage(&packets.TileSet64{
+					Length: 1, Rect: packets.TileBufferRect{Width: 3, X: 1, Y: 21},
+					Duration: 1, Colors: [64]packets.LightHsbk{column[63], column[64], column[65]},
+				}),
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := SetMatrixRectColors(0, 1, tc.x, tc.y, tc.width, tc.tileHeight, tc.colors, time.Millisecond)
+			assert.ErrorIs(t, err, tc.wantErr)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+
+	t.Run("leaves the zones outside the rectangle untouched", func(t *testing.T) {
+		bg, fg := packets.LightHsbk{Hue: 1}, packets.LightHsbk{Hue: 2}
+		rects := map[string]struct {
+			tileWidth, tileHeight int
+			x, y, width, rows     int
+		}{
+			"reaching the bottom": {tileWidth: 8, tileHeight: 8, x: 2, y: 5, width: 3, rows: 3},
+			"whole message rows":  {tileWidth: 8, tileHeight: 32, x: 2, y: 8, width: 4, rows: 16},
+		}
+		for name, r := range rects {
+			t.Run(name, func(t *testing.T) {
+				tile := slices.Repeat([]packets.LightHsbk{bg}, r.tileWidth*r.tileHeight)
+				msgs, err := SetMatrixRectColors(0, 1, r.x, r.y, r.width, r.tileHeight,
+					slices.Repeat([]packets.LightHsbk{fg}, r.width*r.rows), 0)
+				require.NoError(t, err)
+				for _, msg := range msgs {
+					applyTileSet64(tile, r.tileWidth, msg.Payload.(*packets.TileSet64))
+				}
+
+				for y := range r.tileHeight {
+					for x := range r.tileWidth {
+						want := bg
+						if x >= r.x && x < r.x+r.width && y >= r.y && y < r.y+r.rows {
+							want = fg
+						}
+						assert.Equal(t, want, tile[y*r.tileWidth+x], "zone (%d, %d)", x, y)
+					}
+				}
+			})
+		}
+	})
+}
+
+// applyTileSet64 sets the colors of p in the zones of tile as a device does, ignoring the colors
+// falling outside of the tile.
+func applyTileSet64(tile []packets.LightHsbk, tileWidth int, p *packets.TileSet64) {
+	width := int(p.Rect.Width)
+	for i, c := range p.Colors {
+		x, y := int(p.Rect.X)+i%width, int(p.Rect.Y)+i/width
+		if x < tileWidth && y*tileWidth+x < len(tile) {
+			tile[y*tileWidth+x] = c
+		}
+	}
+}
+
+func TestSetMatrixDeviceColors(t *testing.T) {
+	colors := make([]packets.LightHsbk, 128)
+	for i := range colors {
+		colors[i] = packets.LightHsbk{Hue: uint16(i)}
+	}
+
+	testCases := map[string]struct {
+		props device.MatrixProperties
+		want  []packets.Payload
+	}{
+		"tile chain": {
+			props: device.MatrixProperties{Width: 8, Height: 8, NZones: 64, ChainLength: 2},
+			want: []packets.Payload{
+				&packets.TileSet64{TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{Width: 8}, Duration: 1000, Colors: [64]packets.LightHsbk(colors[:64])},
+				&packets.TileSet64{TileIndex: 2, Length: 1, Rect: packets.TileBufferRect{Width: 8}, Duration: 1000, Colors: [64]packets.LightHsbk(colors[64:])},
+			},
+		},
+		"frame buffers": {
+			props: device.MatrixProperties{Width: 16, Height: 8, NZones: 128, ChainLength: 1, FrameBuffers: true},
+			want: []packets.Payload{
+				&packets.TileSet64{TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16}, Colors: [64]packets.LightHsbk(colors[:64])},
+				&packets.TileSet64{TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{FbIndex: 1, Width: 16, Y: 4}, Colors: [64]packets.LightHsbk(colors[64:])},
+				&packets.TileCopyFrameBuffer{TileIndex: 1, Length: 1, SrcFbIndex: 1, Width: 16, Height: 8, Duration: 1000},
+			},
+		},
+		"large matrix without frame buffers": {
+			props: device.MatrixProperties{Width: 16, Height: 8, NZones: 128, ChainLength: 1},
+			want: []packets.Payload{
+				&packets.TileSet64{TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{Width: 16}, Duration: 1000, Colors: [64]packets.LightHsbk(colors[:64])},
+				&packets.TileSet64{TileIndex: 1, Length: 1, Rect: packets.TileBufferRect{Width: 16, Y: 4}, Duration: 1000, Colors: [64]packets.LightHsbk(colors[64:])},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, payloads(SetMatrixDeviceColors(tc.props, 1, colors, time.Second)))
+		})
+	}
+}
+
+func TestSetMatrixFrameBufferColors(t *testing.T) {
+	colors := make([]packets.LightHsbk, 128)
+	for i := range colors {
+		colors[i] = packets.LightHsbk{Hue: uint16(i)}
+	}
+
+	got := SetMatrixFrameBufferColors(1, 2, 3, 16, colors)
+	want := []*protocol.Message{
+		protocol.NewMessage(&packets.TileSet64{
+			TileIndex: 1, Length: 2, Rect: packets.TileBufferRect{FbIndex: 3, Width: 16},
+			Colors: [64]packets.LightHsbk(colors[:64]),
+		}),
+		protocol.NewMessage(&packets.TileSet64{
+			TileIndex: 1, Length: 2, Rect: packets.TileBufferRect{FbIndex: 3, Width: 16, Y: 4},
+			Colors: [64]packets.LightHsbk(colors[64:]),
+		}),
+	}
+	assert.Equal(t, want, got)
+}
+
 func TestSetMatrixFrameAnimation(t *testing.T) {
 	newNColors := func(n int) []packets.LightHsbk {
 		s := make([]packets.LightHsbk, n)
@@ -250,3 +545,30 @@ func TestSetMatrixFrameAnimation(t *testing.T) {
 		})
 	}
 }
+
+func TestScaleBrightness(t *testing.T) {
+	testCases := map[string]struct {
+		brightness uint16
+		percent    float64
+		gamma      float64
+		want       uint16
+	}{
+		"Unchanged":          {brightness: 40000, percent: 100, gamma: 1, want: 40000},
+		"Halved":             {brightness: 40000, percent: 50, gamma: 1, want: 20000},
+		"Off":                {brightness: 40000, percent: 0, gamma: 1, want: 0},
+		"Clamped percentage": {brightness: 40000, percent: 150, gamma: 1, want: 40000},
+		"Gamma":              {brightness: 32768, percent: 100, gamma: 2, want: 16384},
+		"Gamma then scaled":  {brightness: 32768, percent: 50, gamma: 2, want: 8192},
+		"Gamma keeps full":   {brightness: 65535, percent: 100, gamma: 2.2, want: 65535},
+		"Invalid gamma":      {brightness: 40000, percent: 100, gamma: 0, want: 40000},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			c := packets.LightHsbk{Hue: 100, Saturation: 200, Brightness: tc.brightness, Kelvin: 3500}
+			got := ScaleBrightness(c, tc.percent, tc.gamma)
+			assert.InDelta(t, tc.want, got.Brightness, 1)
+			assert.Equal(t, packets.LightHsbk{Hue: 100, Saturation: 200, Brightness: got.Brightness, Kelvin: 3500}, got)
+		})
+	}
+}
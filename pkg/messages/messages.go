@@ -28,6 +28,15 @@ func SetPowerOff(d ...time.Duration) *protocol.Message {
 	return protocol.NewMessage(&packets.DeviceSetPower{Level: 0})
 }
 
+// SetLabel sets a device label, which must not be empty nor longer than 32 bytes.
+func SetLabel(label string) (*protocol.Message, error) {
+	l, err := encodeLabel(label)
+	if err != nil {
+		return nil, err
+	}
+	return protocol.NewMessage(&packets.DeviceSetLabel{Label: l}), nil
+}
+
 // SetColor sets a device color with no required fields which allows keeping certain
 // parts of the original HSBK color.
 func SetColor(h, s, b *float64, k *uint16, d time.Duration, waveform enums.LightWaveform) *protocol.Message {
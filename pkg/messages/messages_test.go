@@ -9,6 +9,7 @@ import (
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetPowerOn(t *testing.T) {
@@ -55,6 +56,15 @@ func TestSetPowerOff(t *testing.T) {
 	}
 }
 
+func TestSetLabel(t *testing.T) {
+	got, err := SetLabel("Desk")
+	require.NoError(t, err)
+	assert.Equal(t, protocol.NewMessage(&packets.DeviceSetLabel{Label: [32]byte{'D', 'e', 's', 'k'}}), got)
+
+	_, err = SetLabel("")
+	assert.ErrorIs(t, err, ErrInvalidLabel)
+}
+
 func TestSetColor(t *testing.T) {
 	testCases := map[string]struct {
 		h, s, b *float64
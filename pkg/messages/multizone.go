@@ -1,9 +1,11 @@
 package messages
 
 import (
+	"fmt"
 	"math/rand"
 	"time"
 
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
 	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -11,14 +13,53 @@ import (
 
 const (
 	extendedMultizoneMsgMaxZones = 82
+	legacyMultizoneMaxZones      = 256
 )
 
+// SetMultizoneColors returns the messages setting the given colors from startIndex, using the
+// extended multizone messages if the device supports them, as reported by its properties,
+// otherwise the legacy ones.
+func SetMultizoneColors(p device.MultizoneProperties, startIndex int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	if p.Extended {
+		return SetMultizoneExtendedColors(startIndex, colors, d)
+	}
+	return SetMultizoneLegacyColors(startIndex, colors, d)
+}
+
 // SetMultizoneExtendedColors accepts a variable length list of colors and returns multiple MultiZoneExtendedSetColorZones
 // messages to cater for devices with more than the message maximum supported zones.
 // If a single message is needed than the Apply directive is set on the message itself, otherwise an extra message
 // is produced to Apply the colors previously buffered by the device.
 // The startIndex refers to the zone the colors should apply from, if set to 0 colors will be applied from the first zone.
+// Use PlanMultizoneExtendedColors to validate the input and know which message applies the colors.
 func SetMultizoneExtendedColors(startIndex int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	return multizoneExtendedColorsPlan(startIndex, colors, d, enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLY).Messages
+}
+
+// SetMultizoneExtendedColorsWithApply is like SetMultizoneExtendedColors with an explicit apply request:
+//   - APPLY shows the colors once set, as SetMultizoneExtendedColors does;
+//   - NO_APPLY only buffers the colors on the device, to be shown by a later message, e.g. to update
+//     several ranges of zones at once;
+//   - APPLY_ONLY ignores the colors and returns a single message showing those previously buffered
+//     from startIndex, with a transition of the given duration.
+//
+// Use PlanMultizoneExtendedColorsWithApply to validate the input and know which message applies the colors.
+func SetMultizoneExtendedColorsWithApply(startIndex int, colors []packets.LightHsbk, d time.Duration, apply enums.MultiZoneExtendedApplicationRequest) []*protocol.Message {
+	return multizoneExtendedColorsPlan(startIndex, colors, d, apply).Messages
+}
+
+func multizoneExtendedColorsPlan(startIndex int, colors []packets.LightHsbk, d time.Duration, apply enums.MultiZoneExtendedApplicationRequest) Plan {
+	applyOnly := func() *protocol.Message {
+		return protocol.NewMessage(&packets.MultiZoneExtendedSetColorZones{
+			Index:    uint16(startIndex),
+			Duration: uint32(d.Milliseconds()),
+			Apply:    enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY,
+		})
+	}
+	if apply == enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY {
+		return Plan{Messages: []*protocol.Message{applyOnly()}}
+	}
+
 	var msgs []*protocol.Message
 	nColors := len(colors)
 
@@ -35,14 +76,67 @@ func SetMultizoneExtendedColors(startIndex int, colors []packets.LightHsbk, d ti
 		msgs = append(msgs, protocol.NewMessage(m))
 	}
 
-	if len(msgs) == 1 {
-		msgs[0].Payload.(*packets.MultiZoneExtendedSetColorZones).Apply = enums.MultiZoneExtendedApplicationRequest(enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY)
-	} else {
-		m := &packets.MultiZoneExtendedSetColorZones{Apply: enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY}
-		msgs = append(msgs, protocol.NewMessage(m))
+	switch {
+	case apply == enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTNOAPPLY:
+		return Plan{Messages: msgs, ApplyIndex: -1}
+	case len(msgs) == 1:
+		msgs[0].Payload.(*packets.MultiZoneExtendedSetColorZones).Apply = apply
+	default:
+		// The chunks are buffered and then shown at once, with the same transition.
+		msgs = append(msgs, applyOnly())
 	}
+	return Plan{Messages: msgs, ApplyIndex: len(msgs) - 1}
+}
 
-	return msgs
+// SetMultizoneLegacyColors returns MultiZoneSetColorZones messages setting the given colors from startIndex,
+// for devices whose firmware does not support the extended multizone messages.
+// A message is produced for each run of consecutive zones with the same color, and only the last one
+// applies the colors buffered by the device. Colors past the last legacy zone index of 255 are ignored.
+// Use PlanMultizoneLegacyColors to validate the input and know which message applies the colors.
+func SetMultizoneLegacyColors(startIndex int, colors []packets.LightHsbk, d time.Duration) []*protocol.Message {
+	return multizoneLegacyColorsPlan(startIndex, colors, d).Messages
+}
+
+func multizoneLegacyColorsPlan(startIndex int, colors []packets.LightHsbk, d time.Duration) Plan {
+	colors = colors[:max(min(len(colors), legacyMultizoneMaxZones-startIndex), 0)]
+
+	var msgs []*protocol.Message
+	for i := 0; i < len(colors); {
+		j := i + 1
+		for j < len(colors) && colors[j] == colors[i] {
+			j++
+		}
+		msgs = append(msgs, protocol.NewMessage(&packets.MultiZoneSetColorZones{
+			StartIndex: uint8(startIndex + i),
+			EndIndex:   uint8(startIndex + j - 1),
+			Color:      colors[i],
+			Duration:   uint32(d.Milliseconds()),
+			Apply:      enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTNOAPPLY,
+		}))
+		i = j
+	}
+
+	if len(msgs) > 0 {
+		msgs[len(msgs)-1].Payload.(*packets.MultiZoneSetColorZones).Apply = enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY
+	}
+	return Plan{Messages: msgs, ApplyIndex: len(msgs) - 1}
+}
+
+// SetZoneRange returns a MultiZoneSetColorZones message setting the zones from start to end, inclusive,
+// to a single color, e.g. to light up part of a strip without building an extended multizone payload.
+// The apply request tells the device whether to show the color now or buffer it until a later message
+// applies it, which allows setting several ranges at once.
+func SetZoneRange(start, end int, color packets.LightHsbk, d time.Duration, apply enums.MultiZoneApplicationRequest) (*protocol.Message, error) {
+	if start < 0 || end < start || end >= legacyMultizoneMaxZones {
+		return nil, fmt.Errorf("%w: zones %d to %d", ErrInvalidIndex, start, end)
+	}
+	return protocol.NewMessage(&packets.MultiZoneSetColorZones{
+		StartIndex: uint8(start),
+		EndIndex:   uint8(end),
+		Color:      color,
+		Duration:   uint32(d.Milliseconds()),
+		Apply:      apply,
+	}), nil
 }
 
 // SetMultizoneEffectOff returns a message instructing the device to turn any running multizone effect off.
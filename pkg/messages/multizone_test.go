@@ -0,0 +1,125 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetMultizoneLegacyColors(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	blue := packets.LightHsbk{Hue: 43690, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	const (
+		noApply = enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTNOAPPLY
+		apply   = enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY
+	)
+
+	testCases := map[string]struct {
+		startIndex int
+		colors     []packets.LightHsbk
+		want       []packets.Payload
+	}{
+		"single color": {
+			startIndex: 2,
+			colors:     []packets.LightHsbk{red, red, red},
+			want: []packets.Payload{
+				&packets.MultiZoneSetColorZones{StartIndex: 2, EndIndex: 4, Color: red, Duration: 1000, Apply: apply},
+			},
+		},
+		"runs of colors": {
+			colors: []packets.LightHsbk{red, red, blue, red},
+			want: []packets.Payload{
+				&packets.MultiZoneSetColorZones{StartIndex: 0, EndIndex: 1, Color: red, Duration: 1000, Apply: noApply},
+				&packets.MultiZoneSetColorZones{StartIndex: 2, EndIndex: 2, Color: blue, Duration: 1000, Apply: noApply},
+				&packets.MultiZoneSetColorZones{StartIndex: 3, EndIndex: 3, Color: red, Duration: 1000, Apply: apply},
+			},
+		},
+		"colors past the last zone": {
+			startIndex: 254,
+			colors:     []packets.LightHsbk{red, blue, blue},
+			want: []packets.Payload{
+				&packets.MultiZoneSetColorZones{StartIndex: 254, EndIndex: 254, Color: red, Duration: 1000, Apply: noApply},
+				&packets.MultiZoneSetColorZones{StartIndex: 255, EndIndex: 255, Color: blue, Duration: 1000, Apply: apply},
+			},
+		},
+		"start index past the last zone": {
+			startIndex: 300,
+			colors:     []packets.LightHsbk{red},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, payloads(SetMultizoneLegacyColors(tc.startIndex, tc.colors, time.Second)))
+		})
+	}
+}
+
+func TestSetZoneRange(t *testing.T) {
+	red := packets.LightHsbk{Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	apply := enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY
+
+	testCases := map[string]struct {
+		start, end int
+		want       packets.Payload
+		wantErr    string
+	}{
+		"Range": {
+			start: 10, end: 20,
+			want: &packets.MultiZoneSetColorZones{StartIndex: 10, EndIndex: 20, Color: red, Duration: 1000, Apply: apply},
+		},
+		"Single zone": {
+			start: 255, end: 255,
+			want: &packets.MultiZoneSetColorZones{StartIndex: 255, EndIndex: 255, Color: red, Duration: 1000, Apply: apply},
+		},
+		"Negative start": {
+			start: -1, end: 2,
+			wantErr: "invalid index: zones -1 to 2",
+		},
+		"Reversed range": {
+			start: 5, end: 4,
+			wantErr: "invalid index: zones 5 to 4",
+		},
+		"Past the last zone": {
+			start: 0, end: 256,
+			wantErr: "invalid index: zones 0 to 256",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			msg, err := SetZoneRange(tc.start, tc.end, red, time.Second, apply)
+			if tc.wantErr != "" {
+				assert.ErrorIs(t, err, ErrInvalidIndex)
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, msg.Payload)
+		})
+	}
+}
+
+func TestSetMultizoneColors(t *testing.T) {
+	colors := make([]packets.LightHsbk, 10)
+
+	msgs := SetMultizoneColors(device.MultizoneProperties{Extended: true}, 0, colors, 0)
+	assert.IsType(t, &packets.MultiZoneExtendedSetColorZones{}, msgs[0].Payload)
+
+	msgs = SetMultizoneColors(device.MultizoneProperties{}, 0, colors, 0)
+	assert.IsType(t, &packets.MultiZoneSetColorZones{}, msgs[0].Payload)
+}
+
+func payloads(msgs []*protocol.Message) []packets.Payload {
+	var p []packets.Payload
+	for _, m := range msgs {
+		p = append(p, m.Payload)
+	}
+	return p
+}
@@ -0,0 +1,100 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+var (
+	// ErrNoColors is returned when a builder is given no colors to set.
+	ErrNoColors = errors.New("no colors")
+	// ErrInvalidWidth is returned when a matrix width is out of range or does not fit the colors.
+	ErrInvalidWidth = errors.New("invalid width")
+	// ErrInvalidIndex is returned when a start index or length is out of range for the message.
+	ErrInvalidIndex = errors.New("invalid index")
+	// ErrInvalidRect is returned when setting a matrix rectangle would overwrite the zones below it.
+	ErrInvalidRect = errors.New("invalid rectangle")
+	// ErrInvalidApply is returned when an apply request is not one of the protocol values.
+	ErrInvalidApply = errors.New("invalid apply request")
+)
+
+// Plan is an ordered list of messages that set colors on a device.
+// Messages before ApplyIndex only buffer colors on the device, which become
+// visible once the message at ApplyIndex is handled.
+type Plan struct {
+	Messages   []*protocol.Message
+	ApplyIndex int
+}
+
+// Apply returns the message that makes the buffered colors visible.
+func (p Plan) Apply() *protocol.Message {
+	if p.ApplyIndex < 0 || p.ApplyIndex >= len(p.Messages) {
+		return nil
+	}
+	return p.Messages[p.ApplyIndex]
+}
+
+// Retry returns the messages to resend when the message at index i failed:
+// the message itself followed by the apply message, if any and distinct.
+func (p Plan) Retry(i int) []*protocol.Message {
+	if i < 0 || i >= len(p.Messages) {
+		return nil
+	}
+	if i == p.ApplyIndex || p.Apply() == nil {
+		return []*protocol.Message{p.Messages[i]}
+	}
+	return []*protocol.Message{p.Messages[i], p.Apply()}
+}
+
+// PlanMultizoneExtendedColors validates its input and returns the Plan produced by SetMultizoneExtendedColors.
+func PlanMultizoneExtendedColors(startIndex int, colors []packets.LightHsbk, d time.Duration) (Plan, error) {
+	return PlanMultizoneExtendedColorsWithApply(startIndex, colors, d, enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLY)
+}
+
+// PlanMultizoneExtendedColorsWithApply validates its input and returns the Plan produced by
+// SetMultizoneExtendedColorsWithApply. Plans that do not apply the colors have an ApplyIndex of -1,
+// and colors are not required with APPLY_ONLY.
+func PlanMultizoneExtendedColorsWithApply(startIndex int, colors []packets.LightHsbk, d time.Duration, apply enums.MultiZoneExtendedApplicationRequest) (Plan, error) {
+	if apply > enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY {
+		return Plan{}, fmt.Errorf("%w: %d", ErrInvalidApply, apply)
+	}
+	if len(colors) == 0 && apply != enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY {
+		return Plan{}, ErrNoColors
+	}
+	if startIndex < 0 || startIndex+len(colors) > math.MaxUint16 {
+		return Plan{}, fmt.Errorf("%w: %d zones from %d", ErrInvalidIndex, len(colors), startIndex)
+	}
+	return multizoneExtendedColorsPlan(startIndex, colors, d, apply), nil
+}
+
+// PlanMultizoneLegacyColors validates its input and returns the Plan produced by SetMultizoneLegacyColors.
+func PlanMultizoneLegacyColors(startIndex int, colors []packets.LightHsbk, d time.Duration) (Plan, error) {
+	if len(colors) == 0 {
+		return Plan{}, ErrNoColors
+	}
+	if startIndex < 0 || startIndex+len(colors) > legacyMultizoneMaxZones {
+		return Plan{}, fmt.Errorf("%w: %d zones from %d", ErrInvalidIndex, len(colors), startIndex)
+	}
+	return multizoneLegacyColorsPlan(startIndex, colors, d), nil
+}
+
+// PlanMatrixColors validates its input and returns the Plan produced by SetMatrixColorsFromSlice.
+// The number of colors must be a multiple of width.
+func PlanMatrixColors(startIndex, length, width int, colors []packets.LightHsbk, d time.Duration) (Plan, error) {
+	if len(colors) == 0 {
+		return Plan{}, ErrNoColors
+	}
+	if startIndex < 0 || startIndex > math.MaxUint8 || length < 1 || length > math.MaxUint8 {
+		return Plan{}, fmt.Errorf("%w: tile index %d, length %d", ErrInvalidIndex, startIndex, length)
+	}
+	if width < 1 || width > math.MaxUint8 || len(colors)%width != 0 || len(colors)/width > math.MaxUint8 {
+		return Plan{}, fmt.Errorf("%w: %d for %d colors", ErrInvalidWidth, width, len(colors))
+	}
+	return matrixColorsPlan(startIndex, length, width, colors, d), nil
+}
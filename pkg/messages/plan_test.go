@@ -0,0 +1,268 @@
+package messages
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanMultizoneExtendedColors(t *testing.T) {
+	testCases := map[string]struct {
+		startIndex     int
+		nColors        int
+		wantErr        error
+		wantMsgs       int
+		wantApplyIndex int
+	}{
+		"no colors": {
+			wantErr: ErrNoColors,
+		},
+		"negative index": {
+			startIndex: -1,
+			nColors:    10,
+			wantErr:    ErrInvalidIndex,
+		},
+		"index overflow": {
+			startIndex: 65530,
+			nColors:    10,
+			wantErr:    ErrInvalidIndex,
+		},
+		"single message": {
+			nColors:  82,
+			wantMsgs: 1,
+		},
+		"multiple messages": {
+			startIndex:     10,
+			nColors:        100,
+			wantMsgs:       3,
+			wantApplyIndex: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			plan, err := PlanMultizoneExtendedColors(tc.startIndex, make([]packets.LightHsbk, tc.nColors), time.Second)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, plan.Messages, tc.wantMsgs)
+			assert.Equal(t, tc.wantApplyIndex, plan.ApplyIndex)
+
+			apply := plan.Apply().Payload.(*packets.MultiZoneExtendedSetColorZones)
+			assert.Equal(t, uint16(tc.startIndex), apply.Index)
+			if tc.wantMsgs == 1 {
+				assert.Equal(t, enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLY, apply.Apply)
+			} else {
+				assert.Equal(t, enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY, apply.Apply)
+			}
+		})
+	}
+}
+
+func TestPlanMultizoneExtendedColorsWithApply(t *testing.T) {
+	const (
+		noApply   = enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTNOAPPLY
+		apply     = enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLY
+		applyOnly = enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTAPPLYONLY
+	)
+
+	type chunk struct {
+		index, count int
+		apply        enums.MultiZoneExtendedApplicationRequest
+	}
+	testCases := map[string]struct {
+		startIndex     int
+		nColors        int
+		apply          enums.MultiZoneExtendedApplicationRequest
+		wantErr        error
+		want           []chunk
+		wantApplyIndex int
+	}{
+		"single message": {
+			nColors: 30,
+			apply:   apply,
+			want:    []chunk{{0, 30, apply}},
+		},
+		"244 zones": {
+			nColors:        244,
+			apply:          apply,
+			want:           []chunk{{0, 82, noApply}, {82, 82, noApply}, {164, 80, noApply}, {0, 0, applyOnly}},
+			wantApplyIndex: 3,
+		},
+		"244 zones from an offset": {
+			startIndex:     6,
+			nColors:        244,
+			apply:          apply,
+			want:           []chunk{{6, 82, noApply}, {88, 82, noApply}, {170, 80, noApply}, {6, 0, applyOnly}},
+			wantApplyIndex: 3,
+		},
+		"no apply": {
+			nColors:        244,
+			apply:          noApply,
+			want:           []chunk{{0, 82, noApply}, {82, 82, noApply}, {164, 80, noApply}},
+			wantApplyIndex: -1,
+		},
+		"apply only": {
+			startIndex: 10,
+			apply:      applyOnly,
+			want:       []chunk{{10, 0, applyOnly}},
+		},
+		"no colors": {
+			apply:   noApply,
+			wantErr: ErrNoColors,
+		},
+		"invalid apply": {
+			nColors: 10,
+			apply:   3,
+			wantErr: ErrInvalidApply,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			plan, err := PlanMultizoneExtendedColorsWithApply(tc.startIndex, make([]packets.LightHsbk, tc.nColors), time.Second, tc.apply)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantApplyIndex, plan.ApplyIndex)
+
+			var got []chunk
+			for _, msg := range plan.Messages {
+				p := msg.Payload.(*packets.MultiZoneExtendedSetColorZones)
+				// Every message, including the one only applying the colors, carries the transition.
+				assert.Equal(t, uint32(1000), p.Duration)
+				got = append(got, chunk{int(p.Index), int(p.ColorsCount), p.Apply})
+			}
+			assert.Equal(t, tc.want, got)
+			assert.Equal(t, plan.Messages, SetMultizoneExtendedColorsWithApply(tc.startIndex, make([]packets.LightHsbk, tc.nColors), time.Second, tc.apply))
+		})
+	}
+}
+
+func TestPlanMatrixColors(t *testing.T) {
+	testCases := map[string]struct {
+		length         int
+		width          int
+		nColors        int
+		wantErr        error
+		wantMsgs       int
+		wantApplyIndex int
+	}{
+		"no colors": {
+			length:  1,
+			width:   8,
+			wantErr: ErrNoColors,
+		},
+		"zero width": {
+			length:  1,
+			nColors: 64,
+			wantErr: ErrInvalidWidth,
+		},
+		"colors not multiple of width": {
+			length:  1,
+			width:   8,
+			nColors: 60,
+			wantErr: ErrInvalidWidth,
+		},
+		"zero length": {
+			width:   8,
+			nColors: 64,
+			wantErr: ErrInvalidIndex,
+		},
+		"single message": {
+			length:   1,
+			width:    8,
+			nColors:  64,
+			wantMsgs: 1,
+		},
+		"frame buffer messages": {
+			length:         1,
+			width:          16,
+			nColors:        128,
+			wantMsgs:       3,
+			wantApplyIndex: 2,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			plan, err := PlanMatrixColors(0, tc.length, tc.width, make([]packets.LightHsbk, tc.nColors), time.Second)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, plan.Messages, tc.wantMsgs)
+			assert.Equal(t, tc.wantApplyIndex, plan.ApplyIndex)
+		})
+	}
+}
+
+func TestPlanRetry(t *testing.T) {
+	msgs := []*protocol.Message{
+		protocol.NewMessage(&packets.TileSet64{}),
+		protocol.NewMessage(&packets.TileSet64{}),
+		protocol.NewMessage(&packets.TileCopyFrameBuffer{}),
+	}
+	plan := Plan{Messages: msgs, ApplyIndex: 2}
+
+	assert.Equal(t, []*protocol.Message{msgs[1], msgs[2]}, plan.Retry(1))
+	assert.Equal(t, []*protocol.Message{msgs[2]}, plan.Retry(2))
+	assert.Nil(t, plan.Retry(3))
+	assert.Nil(t, Plan{}.Apply())
+
+	// Plans that do not apply the colors only resend the failed message.
+	plan.ApplyIndex = -1
+	assert.Equal(t, []*protocol.Message{msgs[1]}, plan.Retry(1))
+}
+
+func TestPlanMultizoneLegacyColors(t *testing.T) {
+	testCases := map[string]struct {
+		startIndex     int
+		nColors        int
+		wantErr        error
+		wantApplyIndex int
+	}{
+		"no colors": {
+			wantErr: ErrNoColors,
+		},
+		"negative index": {
+			startIndex: -1,
+			nColors:    10,
+			wantErr:    ErrInvalidIndex,
+		},
+		"index overflow": {
+			startIndex: 250,
+			nColors:    10,
+			wantErr:    ErrInvalidIndex,
+		},
+		"single run": {
+			startIndex: 246,
+			nColors:    10,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			plan, err := PlanMultizoneLegacyColors(tc.startIndex, make([]packets.LightHsbk, tc.nColors), time.Second)
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantApplyIndex, plan.ApplyIndex)
+			apply := plan.Apply().Payload.(*packets.MultiZoneSetColorZones)
+			assert.Equal(t, uint8(tc.startIndex), apply.StartIndex)
+			assert.Equal(t, enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY, apply.Apply)
+		})
+	}
+}
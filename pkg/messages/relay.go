@@ -0,0 +1,22 @@
+package messages
+
+import (
+	"math"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// GetRelayPower returns a message requesting the power level of the relay at the given index.
+func GetRelayPower(relayIndex int) *protocol.Message {
+	return protocol.NewMessage(&packets.RelayGetPower{RelayIndex: uint8(relayIndex)})
+}
+
+// SetRelayPower sets the relay at the given index on (65535) or off (0).
+func SetRelayPower(relayIndex int, on bool) *protocol.Message {
+	var level uint16
+	if on {
+		level = math.MaxUint16
+	}
+	return protocol.NewMessage(&packets.RelaySetPower{RelayIndex: uint8(relayIndex), Level: level})
+}
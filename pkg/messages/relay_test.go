@@ -0,0 +1,38 @@
+package messages
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRelayPower(t *testing.T) {
+	assert.Equal(t, protocol.NewMessage(&packets.RelayGetPower{RelayIndex: 2}), GetRelayPower(2))
+}
+
+func TestSetRelayPower(t *testing.T) {
+	testCases := map[string]struct {
+		index int
+		on    bool
+		want  *protocol.Message
+	}{
+		"on": {
+			index: 1,
+			on:    true,
+			want:  protocol.NewMessage(&packets.RelaySetPower{RelayIndex: 1, Level: math.MaxUint16}),
+		},
+		"off": {
+			index: 3,
+			want:  protocol.NewMessage(&packets.RelaySetPower{RelayIndex: 3, Level: 0}),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, SetRelayPower(tc.index, tc.on))
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package messages
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// ErrInvalidWaveform is returned when a waveform period, cycles or skew ratio is out of range.
+// Periods are sent in milliseconds, so they must be at least a millisecond.
+var ErrInvalidWaveform = errors.New("invalid waveform")
+
+// Pulse returns a message switching the device between its current color and the given one
+// for the given number of cycles, each lasting period.
+// skewRatio, between 0 and 1, is the fraction of each cycle spent on the current color.
+// If transient is true the device returns to its current color at the end, otherwise it keeps the given one.
+func Pulse(color packets.LightHsbk, period time.Duration, cycles, skewRatio float64, transient bool) (*protocol.Message, error) {
+	return setWaveform(enums.LightWaveformLIGHTWAVEFORMPULSE, color, period, cycles, skewRatio, transient)
+}
+
+// Breathe returns a message fading the device to the given color and back with a sine curve,
+// for the given number of cycles, each lasting period.
+// If transient is true the device returns to its current color at the end, otherwise it keeps the given one.
+func Breathe(color packets.LightHsbk, period time.Duration, cycles float64, transient bool) (*protocol.Message, error) {
+	return setWaveform(enums.LightWaveformLIGHTWAVEFORMSINE, color, period, cycles, 0.5, transient)
+}
+
+// Sine is like Breathe but uses skewRatio, between 0 and 1, to shift the peak of each cycle:
+// 0.5 peaks in the middle, lower values peak earlier and higher values later.
+func Sine(color packets.LightHsbk, period time.Duration, cycles, skewRatio float64, transient bool) (*protocol.Message, error) {
+	return setWaveform(enums.LightWaveformLIGHTWAVEFORMSINE, color, period, cycles, skewRatio, transient)
+}
+
+// Triangle returns a message fading the device linearly to the given color and back,
+// for the given number of cycles, each lasting period.
+// If transient is true the device returns to its current color at the end, otherwise it keeps the given one.
+func Triangle(color packets.LightHsbk, period time.Duration, cycles float64, transient bool) (*protocol.Message, error) {
+	return setWaveform(enums.LightWaveformLIGHTWAVEFORMTRIANGLE, color, period, cycles, 0.5, transient)
+}
+
+// HalfSine returns a message fading the device to the given color and back with the first half
+// of a sine curve, for the given number of cycles, each lasting period.
+// If transient is true the device returns to its current color at the end, otherwise it keeps the given one.
+func HalfSine(color packets.LightHsbk, period time.Duration, cycles float64, transient bool) (*protocol.Message, error) {
+	return setWaveform(enums.LightWaveformLIGHTWAVEFORMHALFSINE, color, period, cycles, 0.5, transient)
+}
+
+func setWaveform(waveform enums.LightWaveform, color packets.LightHsbk, period time.Duration, cycles, skewRatio float64, transient bool) (*protocol.Message, error) {
+	if period < time.Millisecond || period.Milliseconds() > math.MaxUint32 {
+		return nil, fmt.Errorf("%w: period %s", ErrInvalidWaveform, period)
+	}
+	if !(cycles > 0 && cycles <= math.MaxFloat32) {
+		return nil, fmt.Errorf("%w: cycles %g", ErrInvalidWaveform, cycles)
+	}
+	if !(skewRatio >= 0 && skewRatio <= 1) {
+		return nil, fmt.Errorf("%w: skew ratio %g", ErrInvalidWaveform, skewRatio)
+	}
+
+	return protocol.NewMessage(&packets.LightSetWaveform{
+		Transient: transient,
+		Color:     color,
+		Period:    uint32(period.Milliseconds()),
+		Cycles:    float32(cycles),
+		SkewRatio: skewRatioToDevice(skewRatio),
+		Waveform:  waveform,
+	}), nil
+}
+
+// skewRatioToDevice converts a ratio between 0 and 1 to the device range of -32768 to 32767.
+func skewRatioToDevice(r float64) int16 {
+	return int16(math.Round(r*math.MaxUint16) + math.MinInt16)
+}
@@ -0,0 +1,106 @@
+package messages
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWaveforms(t *testing.T) {
+	red := packets.LightHsbk{Saturation: math.MaxUint16, Brightness: math.MaxUint16, Kelvin: 3500}
+
+	testCases := map[string]struct {
+		build   func() (*protocol.Message, error)
+		want    *packets.LightSetWaveform
+		wantErr error
+	}{
+		"pulse": {
+			build: func() (*protocol.Message, error) { return Pulse(red, time.Second, 3, 0.25, true) },
+			want: &packets.LightSetWaveform{
+				Transient: true, Color: red, Period: 1000, Cycles: 3, SkewRatio: -16384,
+				Waveform: enums.LightWaveformLIGHTWAVEFORMPULSE,
+			},
+		},
+		"breathe": {
+			build: func() (*protocol.Message, error) { return Breathe(red, 2*time.Second, 0.5, false) },
+			want: &packets.LightSetWaveform{
+				Color: red, Period: 2000, Cycles: 0.5,
+				Waveform: enums.LightWaveformLIGHTWAVEFORMSINE,
+			},
+		},
+		"sine with skew": {
+			build: func() (*protocol.Message, error) { return Sine(red, time.Second, 1, 1, true) },
+			want: &packets.LightSetWaveform{
+				Transient: true, Color: red, Period: 1000, Cycles: 1, SkewRatio: math.MaxInt16,
+				Waveform: enums.LightWaveformLIGHTWAVEFORMSINE,
+			},
+		},
+		"triangle": {
+			build: func() (*protocol.Message, error) { return Triangle(red, 500*time.Millisecond, 4, true) },
+			want: &packets.LightSetWaveform{
+				Transient: true, Color: red, Period: 500, Cycles: 4,
+				Waveform: enums.LightWaveformLIGHTWAVEFORMTRIANGLE,
+			},
+		},
+		"half sine": {
+			build: func() (*protocol.Message, error) { return HalfSine(red, time.Second, 2, true) },
+			want: &packets.LightSetWaveform{
+				Transient: true, Color: red, Period: 1000, Cycles: 2,
+				Waveform: enums.LightWaveformLIGHTWAVEFORMHALFSINE,
+			},
+		},
+		"zero period": {
+			build:   func() (*protocol.Message, error) { return Pulse(red, 0, 1, 0.5, true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"period under a millisecond": {
+			build:   func() (*protocol.Message, error) { return Triangle(red, 999*time.Microsecond, 1, true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"period too long": {
+			build:   func() (*protocol.Message, error) { return Breathe(red, math.MaxInt64, 1, true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"no cycles": {
+			build:   func() (*protocol.Message, error) { return Triangle(red, time.Second, 0, true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"infinite cycles": {
+			build:   func() (*protocol.Message, error) { return HalfSine(red, time.Second, math.Inf(1), true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"skew ratio out of range": {
+			build:   func() (*protocol.Message, error) { return Pulse(red, time.Second, 1, 1.5, true) },
+			wantErr: ErrInvalidWaveform,
+		},
+		"skew ratio NaN": {
+			build:   func() (*protocol.Message, error) { return Sine(red, time.Second, 1, math.NaN(), true) },
+			wantErr: ErrInvalidWaveform,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := tc.build()
+			if tc.wantErr != nil {
+				assert.ErrorIs(t, err, tc.wantErr)
+				assert.Nil(t, got)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got.Payload)
+		})
+	}
+}
+
+func TestSkewRatioToDevice(t *testing.T) {
+	assert.Equal(t, int16(math.MinInt16), skewRatioToDevice(0))
+	assert.Equal(t, int16(0), skewRatioToDevice(0.5))
+	assert.Equal(t, int16(math.MaxInt16), skewRatioToDevice(1))
+}
@@ -0,0 +1,234 @@
+// Package metrics collects the instrumentation of a Controller in memory and exposes it
+// in the Prometheus text format, without depending on a Prometheus client library.
+//
+//	m := metrics.New()
+//	ctrl, err := controller.New(controller.WithMetrics(m))
+//	http.Handle("/metrics", m)
+package metrics
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+// namespace prefixes the name of all metrics.
+const namespace = "lifxlan"
+
+// Collector is a controller.MetricsRecorder keeping counters and gauges in memory.
+// It is safe for concurrent use.
+type Collector struct {
+	mu                 sync.Mutex
+	sent               map[uint16]uint64
+	received           map[uint16]uint64
+	dropped            map[controller.DropReason]uint64
+	decodeErrors       uint64
+	sessions           int
+	discoveryRounds    uint64
+	discoveryResponses uint64
+	discoveryRTT       time.Duration
+	devices            map[device.Serial]*rtt
+}
+
+// rtt summarizes the round trip times of a device.
+type rtt struct {
+	count uint64
+	sum   time.Duration
+	last  time.Duration
+}
+
+// Snapshot is a copy of the values of a Collector.
+type Snapshot struct {
+	Sent               map[uint16]uint64
+	Received           map[uint16]uint64
+	Dropped            map[controller.DropReason]uint64
+	DecodeErrors       uint64
+	Sessions           int
+	DiscoveryRounds    uint64
+	DiscoveryResponses uint64
+	// DeviceRTT is the last round trip time measured for each device.
+	DeviceRTT map[device.Serial]time.Duration
+}
+
+var _ controller.MetricsRecorder = (*Collector)(nil)
+
+// New returns an empty Collector.
+func New() *Collector {
+	return &Collector{
+		sent:     make(map[uint16]uint64),
+		received: make(map[uint16]uint64),
+		dropped:  make(map[controller.DropReason]uint64),
+		devices:  make(map[device.Serial]*rtt),
+	}
+}
+
+// MessageSent implements controller.MetricsRecorder.
+func (c *Collector) MessageSent(payloadType uint16) {
+	c.mu.Lock()
+	c.sent[payloadType]++
+	c.mu.Unlock()
+}
+
+// MessageReceived implements controller.MetricsRecorder.
+func (c *Collector) MessageReceived(payloadType uint16) {
+	c.mu.Lock()
+	c.received[payloadType]++
+	c.mu.Unlock()
+}
+
+// MessageDropped implements controller.MetricsRecorder.
+func (c *Collector) MessageDropped(reason controller.DropReason) {
+	c.mu.Lock()
+	c.dropped[reason]++
+	c.mu.Unlock()
+}
+
+// DecodeError implements controller.MetricsRecorder.
+func (c *Collector) DecodeError() {
+	c.mu.Lock()
+	c.decodeErrors++
+	c.mu.Unlock()
+}
+
+// Sessions implements controller.MetricsRecorder.
+func (c *Collector) Sessions(n int) {
+	c.mu.Lock()
+	c.sessions = n
+	c.mu.Unlock()
+}
+
+// DiscoveryRound implements controller.MetricsRecorder.
+func (c *Collector) DiscoveryRound() {
+	c.mu.Lock()
+	c.discoveryRounds++
+	c.mu.Unlock()
+}
+
+// DiscoveryResponse implements controller.MetricsRecorder.
+func (c *Collector) DiscoveryResponse(d time.Duration) {
+	c.mu.Lock()
+	c.discoveryResponses++
+	c.discoveryRTT += d
+	c.mu.Unlock()
+}
+
+// DeviceRTT implements controller.MetricsRecorder.
+func (c *Collector) DeviceRTT(serial device.Serial, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	r, ok := c.devices[serial]
+	if !ok {
+		r = &rtt{}
+		c.devices[serial] = r
+	}
+	r.count++
+	r.sum += d
+	r.last = d
+}
+
+// Snapshot returns a copy of the current values.
+func (c *Collector) Snapshot() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Snapshot{
+		Sent:               maps.Clone(c.sent),
+		Received:           maps.Clone(c.received),
+		Dropped:            maps.Clone(c.dropped),
+		DecodeErrors:       c.decodeErrors,
+		Sessions:           c.sessions,
+		DiscoveryRounds:    c.discoveryRounds,
+		DiscoveryResponses: c.discoveryResponses,
+		DeviceRTT:          make(map[device.Serial]time.Duration, len(c.devices)),
+	}
+	for serial, r := range c.devices {
+		s.DeviceRTT[serial] = r.last
+	}
+	return s
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (c *Collector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format to w.
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	p := &printer{w: w}
+	p.family("messages_sent_total", "counter", "Messages sent by payload type.")
+	for _, t := range sortedKeys(c.sent) {
+		p.sample("messages_sent_total", `type="`+strconv.Itoa(int(t))+`"`, c.sent[t])
+	}
+	p.family("messages_received_total", "counter", "Messages received by payload type.")
+	for _, t := range sortedKeys(c.received) {
+		p.sample("messages_received_total", `type="`+strconv.Itoa(int(t))+`"`, c.received[t])
+	}
+	p.family("messages_dropped_total", "counter", "Messages dropped by reason.")
+	for _, r := range sortedKeys(c.dropped) {
+		p.sample("messages_dropped_total", `reason="`+string(r)+`"`, c.dropped[r])
+	}
+	p.family("decode_errors_total", "counter", "Received packets that could not be decoded.")
+	p.sample("decode_errors_total", "", c.decodeErrors)
+	p.family("sessions", "gauge", "Number of device sessions.")
+	p.sample("sessions", "", c.sessions)
+	p.family("discovery_rounds_total", "counter", "Discovery rounds sent.")
+	p.sample("discovery_rounds_total", "", c.discoveryRounds)
+	p.family("discovery_response_seconds", "summary", "Time between a discovery round and its responses.")
+	p.sample("discovery_response_seconds_sum", "", c.discoveryRTT.Seconds())
+	p.sample("discovery_response_seconds_count", "", c.discoveryResponses)
+	p.family("device_rtt_seconds", "summary", "Round trip time of messages sent to a device.")
+	for _, serial := range slices.SortedFunc(maps.Keys(c.devices), compareSerials) {
+		r, label := c.devices[serial], `serial="`+serial.String()+`"`
+		p.sample("device_rtt_seconds_sum", label, r.sum.Seconds())
+		p.sample("device_rtt_seconds_count", label, r.count)
+	}
+	return p.n, p.err
+}
+
+// printer writes metric samples, keeping track of the bytes written and the first error.
+type printer struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (p *printer) printf(format string, args ...any) {
+	if p.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(p.w, format, args...)
+	p.n += int64(n)
+	p.err = err
+}
+
+func (p *printer) family(name, typ, help string) {
+	p.printf("# HELP %s_%s %s\n# TYPE %s_%s %s\n", namespace, name, help, namespace, name, typ)
+}
+
+func (p *printer) sample(name, labels string, value any) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+	p.printf("%s_%s%s %v\n", namespace, name, labels, value)
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	return slices.Sorted(maps.Keys(m))
+}
+
+func compareSerials(a, b device.Serial) int {
+	return slices.Compare(a[:], b[:])
+}
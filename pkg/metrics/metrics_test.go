@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollector(t *testing.T) {
+	serial := device.Serial{0xd0, 0x73, 0xd5, 0, 0, 1}
+
+	c := New()
+	c.MessageSent(101)
+	c.MessageSent(101)
+	c.MessageSent(2)
+	c.MessageReceived(107)
+	c.MessageDropped(controller.DropInboundFull)
+	c.DecodeError()
+	c.Sessions(3)
+	c.DiscoveryRound()
+	c.DiscoveryResponse(20 * time.Millisecond)
+	c.DeviceRTT(serial, 10*time.Millisecond)
+	c.DeviceRTT(serial, 30*time.Millisecond)
+
+	t.Run("Snapshot", func(t *testing.T) {
+		assert.Equal(t, Snapshot{
+			Sent:               map[uint16]uint64{2: 1, 101: 2},
+			Received:           map[uint16]uint64{107: 1},
+			Dropped:            map[controller.DropReason]uint64{controller.DropInboundFull: 1},
+			DecodeErrors:       1,
+			Sessions:           3,
+			DiscoveryRounds:    1,
+			DiscoveryResponses: 1,
+			DeviceRTT:          map[device.Serial]time.Duration{serial: 30 * time.Millisecond},
+		}, c.Snapshot())
+	})
+
+	t.Run("Prometheus format", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+		assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+		body := rec.Body.String()
+		for _, want := range []string{
+			"# TYPE lifxlan_messages_sent_total counter\n" +
+				"lifxlan_messages_sent_total{type=\"2\"} 1\n" +
+				"lifxlan_messages_sent_total{type=\"101\"} 2\n",
+			"lifxlan_messages_received_total{type=\"107\"} 1\n",
+			"lifxlan_messages_dropped_total{reason=\"inbound_full\"} 1\n",
+			"lifxlan_decode_errors_total 1\n",
+			"# TYPE lifxlan_sessions gauge\nlifxlan_sessions 3\n",
+			"lifxlan_discovery_rounds_total 1\n",
+			"lifxlan_discovery_response_seconds_sum 0.02\nlifxlan_discovery_response_seconds_count 1\n",
+			"lifxlan_device_rtt_seconds_sum{serial=\"d073d5000001\"} 0.04\n" +
+				"lifxlan_device_rtt_seconds_count{serial=\"d073d5000001\"} 2\n",
+		} {
+			assert.True(t, strings.Contains(body, want), "missing %q in:\n%s", want, body)
+		}
+	})
+}
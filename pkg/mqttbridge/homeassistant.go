@@ -0,0 +1,162 @@
+package mqttbridge
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+// Home Assistant color modes.
+const (
+	colorModeHS        = "hs"
+	colorModeColorTemp = "color_temp"
+
+	stateOn  = "ON"
+	stateOff = "OFF"
+)
+
+// discoveryConfig is the Home Assistant MQTT discovery config of a light, in the JSON schema.
+type discoveryConfig struct {
+	Name                string     `json:"name"`
+	UniqueID            string     `json:"unique_id"`
+	Schema              string     `json:"schema"`
+	StateTopic          string     `json:"state_topic"`
+	CommandTopic        string     `json:"command_topic"`
+	AvailabilityTopic   string     `json:"availability_topic"`
+	Brightness          bool       `json:"brightness"`
+	BrightnessScale     int        `json:"brightness_scale"`
+	SupportedColorModes []string   `json:"supported_color_modes"`
+	ColorTempKelvin     bool       `json:"color_temp_kelvin"`
+	MinKelvin           int        `json:"min_kelvin,omitempty"`
+	MaxKelvin           int        `json:"max_kelvin,omitempty"`
+	Device              deviceInfo `json:"device"`
+}
+
+// deviceInfo describes the device of a light in the Home Assistant device registry.
+type deviceInfo struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+func (b *Bridge) discoveryConfig(d device.Device) discoveryConfig {
+	modes := []string{colorModeColorTemp}
+	if d.ColorProperties.HasColor {
+		modes = append([]string{colorModeHS}, modes...)
+	}
+	id := uniqueID(d.Serial)
+	return discoveryConfig{
+		Name:                d.Label,
+		UniqueID:            id,
+		Schema:              "json",
+		StateTopic:          b.deviceTopic(d.Serial, "state"),
+		CommandTopic:        b.deviceTopic(d.Serial, "set"),
+		AvailabilityTopic:   b.deviceTopic(d.Serial, "availability"),
+		Brightness:          true,
+		BrightnessScale:     100,
+		SupportedColorModes: modes,
+		ColorTempKelvin:     true,
+		MinKelvin:           d.ColorProperties.TemperatureRange.Min,
+		MaxKelvin:           d.ColorProperties.TemperatureRange.Max,
+		Device: deviceInfo{
+			Identifiers:  []string{id},
+			Name:         d.Label,
+			Manufacturer: "LIFX",
+			Model:        d.RegistryName,
+			SWVersion:    d.FirmwareVersion,
+		},
+	}
+}
+
+// lightState is the state of a light, or a command, in the Home Assistant JSON schema.
+// Brightness ranges from 0 to 100, as announced with brightness_scale, and ColorTemp is in
+// kelvin, as announced with color_temp_kelvin.
+type lightState struct {
+	State      string   `json:"state"`
+	Brightness *int     `json:"brightness,omitempty"`
+	ColorMode  string   `json:"color_mode,omitempty"`
+	Color      *hsColor `json:"color,omitempty"`
+	ColorTemp  *int     `json:"color_temp,omitempty"`
+	// Transition is the duration of a command, in seconds.
+	Transition float64 `json:"transition,omitempty"`
+}
+
+// hsColor is a hue in degrees and a saturation in percent.
+type hsColor struct {
+	H float64 `json:"h"`
+	S float64 `json:"s"`
+}
+
+func newLightState(d device.Device) lightState {
+	s := lightState{State: stateOff}
+	if d.PoweredOn {
+		s.State = stateOn
+	}
+	brightness := int(math.Round(d.Color.Brightness))
+	s.Brightness = &brightness
+	if d.ColorProperties.HasColor && d.Color.Saturation > 0 {
+		s.ColorMode = colorModeHS
+		s.Color = &hsColor{H: d.Color.Hue, S: d.Color.Saturation}
+	} else {
+		kelvin := int(d.Color.Kelvin)
+		s.ColorMode = colorModeColorTemp
+		s.ColorTemp = &kelvin
+	}
+	return s
+}
+
+// apply applies a command to a light: the color is set first, so that lights turned on
+// show the requested color straight away.
+func (b *Bridge) apply(d device.Device, cmd lightState) error {
+	transition := time.Duration(cmd.Transition * float64(time.Second))
+	if cmd.State == stateOff {
+		return b.ctrl.SetPower(d.Serial, false, transition)
+	}
+	if cmd.State != stateOn {
+		return errors.New("state must be ON or OFF")
+	}
+
+	color, changed := d.Color, false
+	if cmd.Brightness != nil {
+		color.Brightness, changed = float64(min(max(*cmd.Brightness, 0), 100)), true
+	}
+	if cmd.Color != nil {
+		color.Hue, color.Saturation, changed = cmd.Color.H, cmd.Color.S, true
+	}
+	if cmd.ColorTemp != nil {
+		color.Saturation, color.Kelvin, changed = 0, uint16(min(max(*cmd.ColorTemp, 0), math.MaxUint16)), true
+	}
+	if changed {
+		if err := b.ctrl.SetColor(d.Serial, color, transition); err != nil {
+			return err
+		}
+	}
+	if !d.PoweredOn {
+		return b.ctrl.SetPower(d.Serial, true, transition)
+	}
+	return nil
+}
+
+// colorJSON is a zone color, encoded as the zones of device.Device.MarshalJSONWithZones.
+// The zones and matrix topics carry the zone colors alone rather than the device, whose
+// state Home Assistant reads from the state topic in its own schema.
+type colorJSON struct {
+	Hue        float64 `json:"hue"`
+	Saturation float64 `json:"saturation"`
+	Brightness float64 `json:"brightness"`
+	Kelvin     uint16  `json:"kelvin"`
+}
+
+func newColors(zones []packets.LightHsbk) []colorJSON {
+	colors := make([]colorJSON, 0, len(zones))
+	for _, z := range zones {
+		c := device.NewColor(z)
+		colors = append(colors, colorJSON{Hue: c.Hue, Saturation: c.Saturation, Brightness: c.Brightness, Kelvin: c.Kelvin})
+	}
+	return colors
+}
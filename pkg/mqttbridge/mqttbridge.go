@@ -0,0 +1,229 @@
+// Package mqttbridge publishes the state of the lights of a Controller to MQTT and applies the
+// commands received on MQTT, announcing the lights through Home Assistant MQTT discovery so that
+// they appear in Home Assistant without further configuration.
+//
+// The bridge does not depend on an MQTT library: it publishes and subscribes through a Client,
+// which is usually a thin adapter of a library client such as Eclipse Paho.
+//
+//	b := mqttbridge.New(ctrl, pahoAdapter, mqttbridge.Config{})
+//	err := b.Run(ctx)
+//
+// For each light, with the default topic prefix:
+//
+//	lifx/<serial>/state         retained state, in the Home Assistant JSON schema
+//	lifx/<serial>/set           commands, in the Home Assistant JSON schema
+//	lifx/<serial>/availability  retained "online" or "offline"
+//	lifx/<serial>/zones         retained zone colors of multizone lights
+//	lifx/<serial>/matrix        retained zone colors of each device of matrix lights
+//
+// and the discovery config is published, retained, to homeassistant/light/lifx_<serial>/config.
+// Switches are not published.
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+)
+
+const (
+	// DefaultTopicPrefix is the prefix of the device topics when none is configured.
+	DefaultTopicPrefix = "lifx"
+	// DefaultDiscoveryPrefix is the Home Assistant discovery prefix when none is configured.
+	DefaultDiscoveryPrefix = "homeassistant"
+
+	availabilityOnline  = "online"
+	availabilityOffline = "offline"
+)
+
+// Client is the MQTT client used by a Bridge. Implementations must be safe for concurrent use.
+type Client interface {
+	// Publish publishes payload to topic, retaining it on the broker if retained is set.
+	Publish(topic string, payload []byte, retained bool) error
+	// Subscribe calls handler with the messages published to the topic filter,
+	// which may contain wildcards.
+	Subscribe(filter string, handler func(topic string, payload []byte)) error
+}
+
+// Config contains optional user-configurable fields.
+type Config struct {
+	// TopicPrefix is the prefix of the device topics, DefaultTopicPrefix by default.
+	TopicPrefix string
+	// DiscoveryPrefix is the Home Assistant discovery prefix, DefaultDiscoveryPrefix by default.
+	DiscoveryPrefix string
+	// Logger receives the bridge logs. By default, logs are discarded.
+	Logger *slog.Logger
+}
+
+// Bridge publishes the state of the lights of a Controller and applies the commands received.
+type Bridge struct {
+	ctrl   *controller.Controller
+	client Client
+	cfg    Config
+	logger *slog.Logger
+
+	// announced holds the last discovery config published for each light, so that it is
+	// published again only when it changes, e.g. with the label. It is only accessed by Run.
+	announced map[device.Serial]string
+}
+
+// New returns a Bridge between ctrl and the MQTT client.
+func New(ctrl *controller.Controller, client Client, cfg Config) *Bridge {
+	if cfg.TopicPrefix == "" {
+		cfg.TopicPrefix = DefaultTopicPrefix
+	}
+	if cfg.DiscoveryPrefix == "" {
+		cfg.DiscoveryPrefix = DefaultDiscoveryPrefix
+	}
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return &Bridge{
+		ctrl:      ctrl,
+		client:    client,
+		cfg:       cfg,
+		logger:    logger,
+		announced: make(map[device.Serial]string),
+	}
+}
+
+// Run subscribes to the command topics and publishes the lights state as it changes, until ctx is
+// done or the Controller is closed. The lights are marked offline when Run returns.
+func (b *Bridge) Run(ctx context.Context) error {
+	events, cancel := b.ctrl.Subscribe(controller.EventFilter{})
+	defer cancel()
+	defer b.publishOffline()
+
+	if err := b.client.Subscribe(b.topic("+", "set"), b.handleCommand); err != nil {
+		return fmt.Errorf("failed to subscribe to commands: %w", err)
+	}
+	for d := range b.ctrl.Devices() {
+		b.publishDevice(d)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e.Type == controller.EventDeviceOffline {
+				if _, ok := b.announced[e.Serial]; ok {
+					b.publish(b.deviceTopic(e.Serial, "availability"), []byte(availabilityOffline))
+				}
+				continue
+			}
+			b.publishDevice(e.Device)
+		}
+	}
+}
+
+// publishDevice publishes the discovery config of a light once profiled, and its state.
+func (b *Bridge) publishDevice(d device.Device) {
+	if d.Type == device.DeviceTypeSwitch || !d.Profiled() || d.Label == "" {
+		return
+	}
+	if cfg, err := json.Marshal(b.discoveryConfig(d)); err == nil && string(cfg) != b.announced[d.Serial] {
+		b.publish(b.discoveryTopic(d.Serial), cfg)
+		b.announced[d.Serial] = string(cfg)
+	}
+	b.publish(b.deviceTopic(d.Serial, "availability"), []byte(availabilityOnline))
+	b.publishJSON(b.deviceTopic(d.Serial, "state"), newLightState(d))
+
+	switch {
+	case d.LightType == device.LightTypeMultiZone && len(d.MultizoneProperties.Zones) > 0:
+		b.publishJSON(b.deviceTopic(d.Serial, "zones"), newColors(d.MultizoneProperties.Zones))
+	case d.LightType == device.LightTypeMatrix && len(d.MatrixProperties.ChainZones) > 0:
+		chain := make([][]colorJSON, 0, len(d.MatrixProperties.ChainZones))
+		for _, zones := range d.MatrixProperties.ChainZones {
+			chain = append(chain, newColors(zones))
+		}
+		b.publishJSON(b.deviceTopic(d.Serial, "matrix"), chain)
+	}
+}
+
+// publishOffline marks the announced lights offline.
+func (b *Bridge) publishOffline() {
+	for serial := range b.announced {
+		b.publish(b.deviceTopic(serial, "availability"), []byte(availabilityOffline))
+	}
+}
+
+// handleCommand applies a command received on the command topic of a light.
+func (b *Bridge) handleCommand(topic string, payload []byte) {
+	logger := b.logger.With("topic", topic)
+	serial, err := b.topicSerial(topic)
+	if err != nil {
+		logger.Warn("Ignoring command", "error", err)
+		return
+	}
+	d, ok := b.ctrl.GetDevice(serial)
+	if !ok {
+		logger.Warn("Ignoring command for unknown device")
+		return
+	}
+
+	var cmd lightState
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		logger.Warn("Ignoring malformed command", "error", err)
+		return
+	}
+	if err := b.apply(d, cmd); err != nil {
+		logger.Error("Failed to apply command", "error", err)
+	}
+}
+
+// publishJSON publishes v encoded in JSON, retained.
+func (b *Bridge) publishJSON(topic string, v any) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		b.logger.Error("Failed to encode payload", "topic", topic, "error", err)
+		return
+	}
+	b.publish(topic, payload)
+}
+
+// publish publishes payload to topic, retained.
+func (b *Bridge) publish(topic string, payload []byte) {
+	if err := b.client.Publish(topic, payload, true); err != nil {
+		b.logger.Error("Failed to publish", "topic", topic, "error", err)
+	}
+}
+
+// topic joins the topic prefix with the given levels.
+func (b *Bridge) topic(levels ...string) string {
+	return strings.Join(append([]string{b.cfg.TopicPrefix}, levels...), "/")
+}
+
+// deviceTopic returns the topic of a light with the given name.
+func (b *Bridge) deviceTopic(serial device.Serial, name string) string {
+	return b.topic(serial.String(), name)
+}
+
+// discoveryTopic returns the Home Assistant discovery config topic of a light.
+func (b *Bridge) discoveryTopic(serial device.Serial) string {
+	return b.cfg.DiscoveryPrefix + "/light/" + uniqueID(serial) + "/config"
+}
+
+// topicSerial returns the serial of the light of a device topic.
+func (b *Bridge) topicSerial(topic string) (device.Serial, error) {
+	levels := strings.Split(strings.TrimPrefix(topic, b.cfg.TopicPrefix+"/"), "/")
+	if len(levels) != 2 {
+		return device.Serial{}, fmt.Errorf("unexpected topic %q", topic)
+	}
+	return device.SerialFromHex(levels[0])
+}
+
+// uniqueID returns the Home Assistant unique ID of a light.
+func uniqueID(serial device.Serial) string {
+	return "lifx_" + serial.String()
+}
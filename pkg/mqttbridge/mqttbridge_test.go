@@ -0,0 +1,167 @@
+package mqttbridge
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/emulator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBridge(t *testing.T) {
+	bulb, err := emulator.New(emulator.Config{Label: "Desk"})
+	require.NoError(t, err)
+	defer bulb.Close()
+	strip, err := emulator.New(emulator.Config{ProductID: 32, Label: "Shelf", Zones: 8})
+	require.NoError(t, err)
+	defer strip.Close()
+
+	ctrl, err := controller.New(controller.WithoutDiscovery(),
+		controller.WithStaticDevices(bulb.Addr().String(), strip.Addr().String()))
+	require.NoError(t, err)
+	defer ctrl.Close()
+
+	client := newFakeClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- New(ctrl, client, Config{}).Run(ctx) }()
+
+	bulbTopic := "lifx/" + bulb.Serial().String()
+	stripTopic := "lifx/" + strip.Serial().String()
+
+	t.Run("Announces lights", func(t *testing.T) {
+		var cfg discoveryConfig
+		client.waitFor(t, "homeassistant/light/lifx_"+bulb.Serial().String()+"/config", &cfg)
+		assert.Equal(t, "Desk", cfg.Name)
+		assert.Equal(t, "json", cfg.Schema)
+		assert.Equal(t, bulbTopic+"/set", cfg.CommandTopic)
+		assert.Equal(t, []string{colorModeHS, colorModeColorTemp}, cfg.SupportedColorModes)
+		assert.Equal(t, []string{"lifx_" + bulb.Serial().String()}, cfg.Device.Identifiers)
+		assert.Equal(t, availabilityOnline, client.last(bulbTopic+"/availability"))
+	})
+
+	t.Run("Publishes zones", func(t *testing.T) {
+		var zones []colorJSON
+		client.waitFor(t, stripTopic+"/zones", &zones)
+		assert.Len(t, zones, 8)
+	})
+
+	t.Run("Applies commands", func(t *testing.T) {
+		client.receive(bulbTopic+"/set", `{"state": "ON", "brightness": 40, "color": {"h": 120, "s": 100}}`)
+
+		want := device.Color{Hue: 120, Saturation: 100, Brightness: 40, Kelvin: bulb.State().Color.Kelvin}.ToDeviceColor()
+		require.Eventually(t, func() bool {
+			s := bulb.State()
+			return s.Power == 65535 && s.Color == want
+		}, time.Second, 10*time.Millisecond)
+
+		assert.Eventually(t, func() bool {
+			var state lightState
+			if !client.decode(bulbTopic+"/state", &state) || state.Brightness == nil || state.Color == nil {
+				return false
+			}
+			return state.State == stateOn && *state.Brightness == 40 && state.ColorMode == colorModeHS
+		}, time.Second, 10*time.Millisecond)
+
+		client.receive(bulbTopic+"/set", `{"state": "ON", "color_temp": 2700}`)
+		assert.Eventually(t, func() bool {
+			s := bulb.State()
+			return s.Color.Saturation == 0 && s.Color.Kelvin == 2700
+		}, time.Second, 10*time.Millisecond)
+
+		client.receive(bulbTopic+"/set", `{"state": "OFF"}`)
+		assert.Eventually(t, func() bool { return bulb.State().Power == 0 }, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Marks lights offline when stopped", func(t *testing.T) {
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+		assert.Equal(t, availabilityOffline, client.last(bulbTopic+"/availability"))
+		assert.Equal(t, availabilityOffline, client.last(stripTopic+"/availability"))
+	})
+}
+
+func TestBridge_topicSerial(t *testing.T) {
+	b := New(nil, nil, Config{TopicPrefix: "home/lifx"})
+	serial := device.Serial{0xd0, 0x73, 0xd5, 0, 0, 1}
+
+	testCases := map[string]struct {
+		topic   string
+		want    device.Serial
+		wantErr bool
+	}{
+		"Command topic":  {topic: "home/lifx/d073d5000001/set", want: serial},
+		"Other prefix":   {topic: "lifx/d073d5000001/set", wantErr: true},
+		"Invalid serial": {topic: "home/lifx/lamp/set", wantErr: true},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			got, err := b.topicSerial(tc.topic)
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
+
+// fakeClient is an in-memory Client recording the last payload published to each topic.
+type fakeClient struct {
+	mu       sync.Mutex
+	retained map[string][]byte
+	handlers map[string]func(topic string, payload []byte)
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		retained: make(map[string][]byte),
+		handlers: make(map[string]func(string, []byte)),
+	}
+}
+
+func (c *fakeClient) Publish(topic string, payload []byte, retained bool) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.retained[topic] = payload
+	return nil
+}
+
+func (c *fakeClient) Subscribe(filter string, handler func(topic string, payload []byte)) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[filter] = handler
+	return nil
+}
+
+// receive delivers a message published to the command topic of a light.
+func (c *fakeClient) receive(topic, payload string) {
+	c.mu.Lock()
+	handler := c.handlers["lifx/+/set"]
+	c.mu.Unlock()
+	handler(topic, []byte(payload))
+}
+
+func (c *fakeClient) last(topic string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return string(c.retained[topic])
+}
+
+// decode decodes the last payload published to topic into v, reporting whether there is one.
+func (c *fakeClient) decode(topic string, v any) bool {
+	payload := c.last(topic)
+	return payload != "" && json.Unmarshal([]byte(payload), v) == nil
+}
+
+// waitFor waits for a payload to be published to topic and decodes it into v.
+func (c *fakeClient) waitFor(t *testing.T, topic string, v any) {
+	require.Eventually(t, func() bool { return c.decode(topic, v) }, 2*time.Second, 10*time.Millisecond)
+}
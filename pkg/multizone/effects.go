@@ -0,0 +1,281 @@
+// Package multizone contains blocking software effects for multizone devices, such as
+// strips and beams, which send each frame as MultiZoneExtendedSetColorZones messages.
+//
+// Effects share the send and stop mechanics of the matrix effects: frames are sent through
+// a protocol.SendFunc, which can be wrapped with SendWithStop or SendWithContext, and
+// each effect has a ...Ctx variant that stops when its context is cancelled.
+package multizone
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/iterator"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/messages"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+)
+
+var (
+	// ErrMissingColors is returned when an effect is started without colors.
+	ErrMissingColors = errors.New("missing colors")
+	// ErrInvalidZones is returned when an effect is started with no zones.
+	ErrInvalidZones = errors.New("invalid zones count")
+	// ErrStopped is the error returned when manually stopping an effect.
+	ErrStopped = errors.New("manual stop")
+)
+
+var minInterval = time.Millisecond
+
+// twinkleDecay is the factor by which the brightness of twinkling zones decreases on each frame.
+const twinkleDecay = 0.7
+
+// SendWithStop wraps a SendFunc with an atomic.Bool to manually stop the sender
+// at the next frame.
+func SendWithStop(send protocol.SendFunc) (protocol.SendFunc, *atomic.Bool) {
+	var stopped atomic.Bool
+	return func(msg *protocol.Message) error {
+		if stopped.Load() {
+			return ErrStopped
+		}
+		return send(msg)
+	}, &stopped
+}
+
+// SendWithContext wraps a SendFunc so that it returns the context error once ctx is cancelled,
+// stopping any effect using it at the next send.
+func SendWithContext(ctx context.Context, send protocol.SendFunc) protocol.SendFunc {
+	return func(msg *protocol.Message) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return send(msg)
+	}
+}
+
+// Chase moves a segment made of the given colors along the zones, wrapping at the end.
+// It waits for the given interval before moving the segment by one zone.
+// It repeats for n cycles, each moving the segment across all zones; if cycles is set to 0 it repeats indefinitely.
+func Chase(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return ChaseCtx(context.Background(), zones, send, sendIntervalMs, cycles, colors...)
+}
+
+// ChaseCtx is like Chase but stops when ctx is cancelled, returning the context error.
+func ChaseCtx(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return run(ctx, zones, send, sendIntervalMs, cycles, colors, func(frame []packets.LightHsbk, emit func() error) error {
+		for offset := range zones {
+			clear(frame)
+			for i, c := range colors {
+				frame[(offset+i)%zones] = c
+			}
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Bounce moves a segment made of the given colors from the first to the last zone and back.
+// It waits for the given interval before moving the segment by one zone.
+// It repeats for n cycles, each a full bounce; if cycles is set to 0 it repeats indefinitely.
+func Bounce(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return BounceCtx(context.Background(), zones, send, sendIntervalMs, cycles, colors...)
+}
+
+// BounceCtx is like Bounce but stops when ctx is cancelled, returning the context error.
+func BounceCtx(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	if zones > 0 && len(colors) > zones {
+		colors = colors[:zones]
+	}
+	return run(ctx, zones, send, sendIntervalMs, cycles, colors, func(frame []packets.LightHsbk, emit func() error) error {
+		for offset := range iterator.BounceUp(zones - len(colors) + 1) {
+			clear(frame)
+			copy(frame[offset:], colors)
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GradientSweep spreads a gradient between the given colors across the zones and shifts it
+// by one zone on each frame, so that it sweeps along the device.
+// It waits for the given interval before shifting the gradient.
+// It repeats for n cycles, each shifting the gradient across all zones; if cycles is set to 0 it repeats indefinitely.
+func GradientSweep(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return GradientSweepCtx(context.Background(), zones, send, sendIntervalMs, cycles, colors...)
+}
+
+// GradientSweepCtx is like GradientSweep but stops when ctx is cancelled, returning the context error.
+func GradientSweepCtx(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return run(ctx, zones, send, sendIntervalMs, cycles, colors, func(frame []packets.LightHsbk, emit func() error) error {
+		gradient := Gradient(zones, colors...)
+		for offset := range zones {
+			for i := range frame {
+				frame[i] = gradient[(i+offset)%zones]
+			}
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Twinkle lights random zones with one of the given colors and fades them out over the following frames.
+// It waits for the given interval before lighting the next zone.
+// It repeats for n cycles, each lighting as many zones as the device has; if cycles is set to 0 it repeats indefinitely.
+func Twinkle(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return TwinkleCtx(context.Background(), zones, send, sendIntervalMs, cycles, colors...)
+}
+
+// TwinkleCtx is like Twinkle but stops when ctx is cancelled, returning the context error.
+func TwinkleCtx(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return run(ctx, zones, send, sendIntervalMs, cycles, colors, func(frame []packets.LightHsbk, emit func() error) error {
+		for range zones {
+			for i := range frame {
+				frame[i].Brightness = uint16(float64(frame[i].Brightness) * twinkleDecay)
+			}
+			frame[rand.IntN(zones)] = colors[rand.IntN(len(colors))]
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Fill sets the zones one by one, rotating through the given colors, until the device is filled.
+// It waits for the given interval before setting the next zone.
+// It repeats for n cycles, clearing the zones at the start of each; if cycles is set to 0 it repeats indefinitely.
+func Fill(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return FillCtx(context.Background(), zones, send, sendIntervalMs, cycles, colors...)
+}
+
+// FillCtx is like Fill but stops when ctx is cancelled, returning the context error.
+func FillCtx(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error {
+	return run(ctx, zones, send, sendIntervalMs, cycles, colors, func(frame []packets.LightHsbk, emit func() error) error {
+		clear(frame)
+		for i := range zones {
+			frame[i] = colors[i%len(colors)]
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Gradient returns the colors of n zones blending linearly between the given colors,
+// with hues blended along the shortest path around the color wheel.
+func Gradient(n int, colors ...packets.LightHsbk) []packets.LightHsbk {
+	out := make([]packets.LightHsbk, n)
+	if len(colors) == 0 {
+		return out
+	}
+	if len(colors) == 1 || n == 1 {
+		for i := range out {
+			out[i] = colors[0]
+		}
+		return out
+	}
+
+	segments := float64(len(colors) - 1)
+	for i := range out {
+		pos := float64(i) / float64(n-1) * segments
+		idx := min(int(pos), len(colors)-2)
+		out[i] = blend(colors[idx], colors[idx+1], pos-float64(idx))
+	}
+	return out
+}
+
+// SetZoneRangeGradient returns the MultiZoneSetColorZones messages setting the zones from start to end,
+// inclusive, to a Gradient between the given colors, the last one applying them all.
+// It returns messages.ErrNoColors and messages.ErrInvalidIndex for invalid arguments, see messages.SetZoneRange.
+func SetZoneRangeGradient(start, end int, d time.Duration, colors ...packets.LightHsbk) ([]*protocol.Message, error) {
+	if len(colors) == 0 {
+		return nil, messages.ErrNoColors
+	}
+	if start < 0 || end < start || end > math.MaxUint8 {
+		return nil, fmt.Errorf("%w: zones %d to %d", messages.ErrInvalidIndex, start, end)
+	}
+	return messages.SetMultizoneLegacyColors(start, Gradient(end-start+1, colors...), d), nil
+}
+
+// blend returns the color at t, between 0 and 1, on the way from a to b.
+func blend(a, b packets.LightHsbk, t float64) packets.LightHsbk {
+	lerp := func(a, b uint16) uint16 {
+		return uint16(float64(a) + (float64(b)-float64(a))*t)
+	}
+	return packets.LightHsbk{
+		// Hue wraps around, so the difference is taken modulo 2^16 to follow the shortest path.
+		Hue:        a.Hue + uint16(int32(float64(int16(b.Hue-a.Hue))*t)),
+		Saturation: lerp(a.Saturation, b.Saturation),
+		Brightness: lerp(a.Brightness, b.Brightness),
+		Kelvin:     lerp(a.Kelvin, b.Kelvin),
+	}
+}
+
+// run validates the effect arguments and repeats cycle for the given number of cycles.
+// cycle updates frame and calls emit to send it and wait for the interval.
+func run(ctx context.Context, zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors []packets.LightHsbk,
+	cycle func(frame []packets.LightHsbk, emit func() error) error) error {
+	send = SendWithContext(ctx, send)
+	d := max(time.Duration(sendIntervalMs)*time.Millisecond, minInterval)
+	if zones <= 0 {
+		return ErrInvalidZones
+	}
+	if len(colors) == 0 {
+		return ErrMissingColors
+	}
+
+	frame := make([]packets.LightHsbk, zones)
+	emit := func() error {
+		for _, msg := range messages.SetMultizoneExtendedColors(0, frame, minInterval) {
+			if err := send(msg); err != nil {
+				return err
+			}
+		}
+		return sleep(ctx, d)
+	}
+
+	return repeatForCycles(cycles, func() error {
+		return cycle(frame, emit)
+	})
+}
+
+// sleep pauses for d or until ctx is cancelled, in which case it returns the context error.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// repeatForCycles repeats the given function for n cycles or indefinitely if cycles is 0.
+func repeatForCycles(cycles int, f func() error) error {
+	if cycles > 0 {
+		for range cycles {
+			if err := f(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for {
+		if err := f(); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package multizone
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/enums"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	red   = packets.LightHsbk{Hue: 0, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	green = packets.LightHsbk{Hue: 21845, Saturation: 65535, Brightness: 65535, Kelvin: 3500}
+	off   = packets.LightHsbk{}
+)
+
+// frameRecorder applies MultiZoneExtendedSetColorZones messages to the zones of a device
+// and records the zones each time colors are applied.
+type frameRecorder struct {
+	zones  []packets.LightHsbk
+	frames [][]packets.LightHsbk
+}
+
+func newFrameRecorder(zones int) *frameRecorder {
+	return &frameRecorder{zones: make([]packets.LightHsbk, zones)}
+}
+
+func (r *frameRecorder) send(msg *protocol.Message) error {
+	p, ok := msg.Payload.(*packets.MultiZoneExtendedSetColorZones)
+	if !ok {
+		return nil
+	}
+	copy(r.zones[min(int(p.Index), len(r.zones)):], p.Colors[:p.ColorsCount])
+	if p.Apply != enums.MultiZoneExtendedApplicationRequestMULTIZONEEXTENDEDAPPLICATIONREQUESTNOAPPLY {
+		r.frames = append(r.frames, append([]packets.LightHsbk(nil), r.zones...))
+	}
+	return nil
+}
+
+func TestEffects(t *testing.T) {
+	testCases := map[string]struct {
+		effect     func(zones int, send protocol.SendFunc, sendIntervalMs int64, cycles int, colors ...packets.LightHsbk) error
+		zones      int
+		colors     []packets.LightHsbk
+		wantFrames [][]packets.LightHsbk
+	}{
+		"chase wraps around": {
+			effect: Chase,
+			zones:  3,
+			colors: []packets.LightHsbk{red, green},
+			wantFrames: [][]packets.LightHsbk{
+				{red, green, off},
+				{off, red, green},
+				{green, off, red},
+			},
+		},
+		"bounce": {
+			effect: Bounce,
+			zones:  4,
+			colors: []packets.LightHsbk{red, green},
+			wantFrames: [][]packets.LightHsbk{
+				{red, green, off, off},
+				{off, red, green, off},
+				{off, off, red, green},
+				{off, red, green, off},
+			},
+		},
+		"bounce with more colors than zones": {
+			effect:     Bounce,
+			zones:      1,
+			colors:     []packets.LightHsbk{red, green},
+			wantFrames: [][]packets.LightHsbk{{red}},
+		},
+		"gradient sweep": {
+			effect: GradientSweep,
+			zones:  3,
+			colors: []packets.LightHsbk{{Brightness: 0}, {Brightness: 100}},
+			wantFrames: [][]packets.LightHsbk{
+				{{Brightness: 0}, {Brightness: 50}, {Brightness: 100}},
+				{{Brightness: 50}, {Brightness: 100}, {Brightness: 0}},
+				{{Brightness: 100}, {Brightness: 0}, {Brightness: 50}},
+			},
+		},
+		"fill": {
+			effect: Fill,
+			zones:  3,
+			colors: []packets.LightHsbk{red, green},
+			wantFrames: [][]packets.LightHsbk{
+				{red, off, off},
+				{red, green, off},
+				{red, green, red},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			rec := newFrameRecorder(tc.zones)
+			require.NoError(t, tc.effect(tc.zones, rec.send, 0, 1, tc.colors...))
+			assert.Equal(t, tc.wantFrames, rec.frames)
+		})
+	}
+}
+
+func TestTwinkle(t *testing.T) {
+	rec := newFrameRecorder(4)
+	require.NoError(t, Twinkle(4, rec.send, 0, 2, red))
+
+	require.Len(t, rec.frames, 8)
+	for _, f := range rec.frames {
+		// The zone lit on each frame is at full brightness, the others are fading.
+		assert.Contains(t, f, red)
+		for _, c := range f {
+			assert.LessOrEqual(t, c.Brightness, red.Brightness)
+		}
+	}
+}
+
+func TestEffectsErrors(t *testing.T) {
+	rec := newFrameRecorder(4)
+	assert.ErrorIs(t, Chase(4, rec.send, 0, 1), ErrMissingColors)
+	assert.ErrorIs(t, Bounce(0, rec.send, 0, 1, red), ErrInvalidZones)
+	assert.Empty(t, rec.frames)
+}
+
+func TestEffectsStop(t *testing.T) {
+	t.Run("SendWithStop", func(t *testing.T) {
+		rec := newFrameRecorder(4)
+		send, stop := SendWithStop(rec.send)
+		stop.Store(true)
+		assert.ErrorIs(t, Chase(4, send, 0, 0, red), ErrStopped)
+	})
+
+	t.Run("Context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+
+		rec := newFrameRecorder(4)
+		assert.ErrorIs(t, FillCtx(ctx, 4, rec.send, 1, 0, red), context.DeadlineExceeded)
+		assert.NotEmpty(t, rec.frames)
+	})
+}
+
+func TestGradient(t *testing.T) {
+	testCases := map[string]struct {
+		n      int
+		colors []packets.LightHsbk
+		want   []packets.LightHsbk
+	}{
+		"no colors": {
+			n:    2,
+			want: []packets.LightHsbk{{}, {}},
+		},
+		"single color": {
+			n:      2,
+			colors: []packets.LightHsbk{red},
+			want:   []packets.LightHsbk{red, red},
+		},
+		"multiple stops": {
+			n:      5,
+			colors: []packets.LightHsbk{{Kelvin: 2000}, {Kelvin: 4000}, {Kelvin: 2000}},
+			want:   []packets.LightHsbk{{Kelvin: 2000}, {Kelvin: 3000}, {Kelvin: 4000}, {Kelvin: 3000}, {Kelvin: 2000}},
+		},
+		"hue follows the shortest path": {
+			n:      3,
+			colors: []packets.LightHsbk{{Hue: 65000}, {Hue: 1000}},
+			want:   []packets.LightHsbk{{Hue: 65000}, {Hue: 232}, {Hue: 1000}},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, Gradient(tc.n, tc.colors...))
+		})
+	}
+}
+
+func TestSetZoneRangeGradient(t *testing.T) {
+	const (
+		noApply = enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTNOAPPLY
+		apply   = enums.MultiZoneApplicationRequestMULTIZONEAPPLICATIONREQUESTAPPLY
+	)
+
+	testCases := map[string]struct {
+		start, end int
+		colors     []packets.LightHsbk
+		want       []packets.Payload
+		wantErr    string
+	}{
+		"Gradient": {
+			start: 10, end: 12,
+			colors: []packets.LightHsbk{{Kelvin: 2000}, {Kelvin: 4000}},
+			want: []packets.Payload{
+				&packets.MultiZoneSetColorZones{StartIndex: 10, EndIndex: 10, Color: packets.LightHsbk{Kelvin: 2000}, Duration: 500, Apply: noApply},
+				&packets.MultiZoneSetColorZones{StartIndex: 11, EndIndex: 11, Color: packets.LightHsbk{Kelvin: 3000}, Duration: 500, Apply: noApply},
+				&packets.MultiZoneSetColorZones{StartIndex: 12, EndIndex: 12, Color: packets.LightHsbk{Kelvin: 4000}, Duration: 500, Apply: apply},
+			},
+		},
+		"Single color": {
+			start: 10, end: 20,
+			colors: []packets.LightHsbk{red},
+			want: []packets.Payload{
+				&packets.MultiZoneSetColorZones{StartIndex: 10, EndIndex: 20, Color: red, Duration: 500, Apply: apply},
+			},
+		},
+		"No colors": {
+			start: 0, end: 1,
+			wantErr: "no colors",
+		},
+		"Reversed range": {
+			start: 5, end: 4,
+			colors:  []packets.LightHsbk{red},
+			wantErr: "invalid index: zones 5 to 4",
+		},
+		"Past the last zone": {
+			start: 250, end: 256,
+			colors:  []packets.LightHsbk{red},
+			wantErr: "invalid index: zones 250 to 256",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			msgs, err := SetZoneRangeGradient(tc.start, tc.end, 500*time.Millisecond, tc.colors...)
+			if tc.wantErr != "" {
+				assert.EqualError(t, err, tc.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			var got []packets.Payload
+			for _, msg := range msgs {
+				got = append(got, msg.Payload)
+			}
+			assert.Equal(t, tc.want, got)
+		})
+	}
+}
@@ -3,7 +3,9 @@ package protocol
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"reflect"
 
 	"github.com/alessio-palumbo/lifxlan-go/internal/protocol"
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -20,6 +22,13 @@ type Message struct {
 	Payload packets.Payload
 }
 
+// SendFunc sends a message to a device.
+// It is the canonical signature of the send functions accepted by the matrix,
+// effects and messages helpers; *controller.Controller methods can be bound to it, e.g.
+//
+//	send := func(msg *protocol.Message) error { return ctrl.Send(serial, msg) }
+type SendFunc = func(msg *Message) error
+
 // NewMessage returns a new Message with the given payload.
 func NewMessage(payload packets.Payload) *Message {
 	var h protocol.Header
@@ -73,6 +82,11 @@ func (m *Message) SetTarget(target [8]byte) {
 	m.header.SetTagged(target == TargetBroadcast)
 }
 
+// AckRequired returns whether an Ack is required.
+func (m *Message) AckRequired() bool {
+	return m.header.AckRequired()
+}
+
 // SetAckRequired sets whether an Ack is required.
 func (m *Message) SetAckRequired(v bool) {
 	m.header.SetAckRequired(v)
@@ -83,6 +97,39 @@ func (m *Message) SetResponseRequired(v bool) {
 	m.header.SetResponseRequired(v)
 }
 
+// ResponseRequired returns whether a response is required.
+func (m *Message) ResponseRequired() bool {
+	return m.header.ResponseRequired()
+}
+
+// IsTagged returns whether the message is a broadcast to all devices, see SetTarget.
+func (m *Message) IsTagged() bool {
+	return m.header.IsTagged()
+}
+
+// Size returns the size of the message set in the header, including the header.
+func (m *Message) Size() uint16 {
+	return m.header.Size
+}
+
+// Protocol returns the protocol number set in the header, 1024 for LIFX messages.
+func (m *Message) Protocol() uint16 {
+	return m.header.Protocol()
+}
+
+// Clone returns a copy of the message, with its header and a copy of its payload, so that
+// either can be modified, e.g. resequenced or retargeted, without affecting the other.
+// Payloads are copied by value, which is a deep copy for the lifxprotocol-go payloads.
+func (m *Message) Clone() *Message {
+	c := &Message{header: m.header, Payload: m.Payload}
+	if v := reflect.ValueOf(m.Payload); v.Kind() == reflect.Pointer && !v.IsNil() {
+		p := reflect.New(v.Elem().Type())
+		p.Elem().Set(v.Elem())
+		c.Payload = p.Interface().(packets.Payload)
+	}
+	return c
+}
+
 // String implements Stringer interface for easy logging.
 func (m *Message) String() string {
 	return fmt.Sprintf("Message{Type: %d, Size: %d, Payload: %#v}", m.header.Type, m.header.Size, m.Payload)
@@ -93,26 +140,82 @@ func (m *Message) MarshalBinary() ([]byte, error) {
 	if m.Payload == nil {
 		return nil, fmt.Errorf("cannot marshal message with nil payload")
 	}
+	size := binary.Size(m.Payload)
+	if size < 0 {
+		size = m.Payload.Size()
+	}
+	return m.AppendBinary(make([]byte, 0, protocol.HeaderSize+size))
+}
 
-	payloadBytes, err := m.Payload.MarshalBinary()
-	if err != nil {
-		return nil, err
+// AppendBinary appends the binary wire format of the Message to b, implementing
+// encoding.BinaryAppender. Unlike MarshalBinary it does not allocate when b has enough capacity,
+// e.g. when reusing a buffer to send frames at a high rate.
+func (m *Message) AppendBinary(b []byte) ([]byte, error) {
+	if m.Payload == nil {
+		return nil, fmt.Errorf("cannot marshal message with nil payload")
 	}
 
-	m.header.Type = m.Payload.PayloadType()
-	m.header.Size = uint16(len(payloadBytes) + protocol.HeaderSize)
+	// The lifxprotocol-go payloads are fixed-size structs laid out as on the wire, so they are
+	// appended directly rather than through their MarshalBinary, which allocates.
+	size := binary.Size(m.Payload)
+	var payloadBytes []byte
+	if size < 0 {
+		var err error
+		if payloadBytes, err = m.Payload.MarshalBinary(); err != nil {
+			return nil, err
+		}
+		size = len(payloadBytes)
+	}
 
-	var buf bytes.Buffer
+	m.header.Type = m.Payload.PayloadType()
+	m.header.Size = uint16(size + protocol.HeaderSize)
 
-	if err := binary.Write(&buf, binary.LittleEndian, m.header); err != nil {
+	b, err := m.header.AppendBinary(b)
+	if err != nil {
 		return nil, err
 	}
-	buf.Write(payloadBytes)
+	if payloadBytes != nil {
+		return append(b, payloadBytes...), nil
+	}
+	return binary.Append(b, binary.LittleEndian, m.Payload)
+}
+
+// MarshalBinaryTo encodes the Message into buf and returns the number of bytes written,
+// or an error if buf is too small.
+func (m *Message) MarshalBinaryTo(buf []byte) (int, error) {
+	// Limit the capacity so that messages larger than buf are appended to a new slice.
+	b, err := m.AppendBinary(buf[:0:len(buf)])
+	if err != nil {
+		return 0, err
+	}
+	if len(b) > len(buf) {
+		return 0, fmt.Errorf("buffer too small: got %d, want %d", len(buf), len(b))
+	}
+	return len(b), nil
+}
 
-	return buf.Bytes(), nil
+// ErrUnknownPayloadType is wrapped by the DecodeError of messages whose payload type is not
+// registered in packets.Payloads.
+var ErrUnknownPayloadType = errors.New("unknown payload type")
+
+// DecodeError is returned by UnmarshalBinary when the payload of a message cannot be decoded.
+type DecodeError struct {
+	// PayloadType is the payload type set in the message header.
+	PayloadType uint16
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("decode payload type %d: %v", e.PayloadType, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
 }
 
 // UnmarshalBinary decodes a message from its binary wire format.
+// Errors decoding the payload are returned as a *DecodeError, in which case the header is still
+// decoded and its accessors report the header fields, e.g. to reply to unknown messages.
 func (m *Message) UnmarshalBinary(data []byte) error {
 	hSize := protocol.HeaderSize
 	if len(data) < hSize {
@@ -126,12 +229,12 @@ func (m *Message) UnmarshalBinary(data []byte) error {
 	payloadType := m.header.Type
 	newPayload, ok := packets.Payloads[payloadType]
 	if !ok {
-		return fmt.Errorf("unknown payload type: %d", payloadType)
+		return &DecodeError{PayloadType: payloadType, Err: ErrUnknownPayloadType}
 	}
 
 	payload := newPayload()
 	if err := payload.UnmarshalBinary(data[hSize:]); err != nil {
-		return err
+		return &DecodeError{PayloadType: payloadType, Err: err}
 	}
 
 	m.Payload = payload
@@ -1,6 +1,9 @@
 package protocol
 
 import (
+	"bytes"
+	"encoding/binary"
+	"errors"
 	"testing"
 
 	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
@@ -19,6 +22,7 @@ func TestMessage_MarshalUnmarshal(t *testing.T) {
 	original := NewMessage(payload)
 	original.SetTarget([8]byte{0xd0, 0x73, 0xd5, 0x00, 0x13, 0x37})
 	original.SetSource(1234)
+	original.SetAckRequired(true)
 
 	data, err := original.MarshalBinary()
 	if err != nil {
@@ -34,7 +38,8 @@ func TestMessage_MarshalUnmarshal(t *testing.T) {
 	// Assert header round-trip
 	if original.Type() != decoded.Type() ||
 		original.Source() != decoded.Source() ||
-		original.Sequence() != decoded.Sequence() {
+		original.Sequence() != decoded.Sequence() ||
+		!decoded.AckRequired() {
 		t.Errorf("Header mismatch: got %+v, want %+v", decoded, original)
 	}
 
@@ -49,3 +54,173 @@ func TestMessage_MarshalUnmarshal(t *testing.T) {
 		t.Errorf("Payload mismatch:\n got: %#v\nwant: %#v", gotPayload, wantPayload)
 	}
 }
+
+func TestMessage_UnmarshalDecodeError(t *testing.T) {
+	valid, err := NewMessage(&packets.LightSetColor{}).MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+	unknown := bytes.Clone(valid)
+	binary.LittleEndian.PutUint16(unknown[32:], 9999)
+
+	testCases := map[string]struct {
+		data            []byte
+		wantPayloadType uint16
+		wantUnknown     bool
+	}{
+		"unknown payload type": {
+			data:            unknown,
+			wantPayloadType: 9999,
+			wantUnknown:     true,
+		},
+		"truncated payload": {
+			data:            valid[:len(valid)-4],
+			wantPayloadType: uint16(packets.PayloadTypeLightSetColor),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var msg Message
+			err := msg.UnmarshalBinary(tc.data)
+			var decodeErr *DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("got error %v, want a *DecodeError", err)
+			}
+			if decodeErr.PayloadType != tc.wantPayloadType {
+				t.Errorf("got payload type %d, want %d", decodeErr.PayloadType, tc.wantPayloadType)
+			}
+			if msg.Type() != tc.wantPayloadType {
+				t.Errorf("got header type %d, want %d", msg.Type(), tc.wantPayloadType)
+			}
+			if got := errors.Is(err, ErrUnknownPayloadType); got != tc.wantUnknown {
+				t.Errorf("got errors.Is(err, ErrUnknownPayloadType) %v, want %v", got, tc.wantUnknown)
+			}
+		})
+	}
+}
+
+func TestMessage_HeaderAccessors(t *testing.T) {
+	msg := NewMessage(&packets.LightSetColor{})
+	if msg.Protocol() != lifxProtocol {
+		t.Errorf("got protocol %d, want %d", msg.Protocol(), lifxProtocol)
+	}
+	if want := uint16(36 + (&packets.LightSetColor{}).Size()); msg.Size() != want {
+		t.Errorf("got size %d, want %d", msg.Size(), want)
+	}
+	if msg.ResponseRequired() {
+		t.Error("response required by default")
+	}
+	msg.SetResponseRequired(true)
+	if !msg.ResponseRequired() {
+		t.Error("response not required after SetResponseRequired")
+	}
+
+	msg.SetTarget(TargetBroadcast)
+	if !msg.IsTagged() {
+		t.Error("broadcast message not tagged")
+	}
+	msg.SetTarget([8]byte{0xd0, 0x73, 0xd5})
+	if msg.IsTagged() {
+		t.Error("targeted message tagged")
+	}
+}
+
+func TestMessage_Clone(t *testing.T) {
+	original := NewMessage(&packets.LightSetColor{Color: packets.LightHsbk{Hue: 100}})
+	original.SetTarget([8]byte{0xd0, 0x73, 0xd5})
+	original.SetAckRequired(true)
+	original.SetSequence(7)
+
+	clone := original.Clone()
+	if clone.Target() != original.Target() || clone.Sequence() != 7 || !clone.AckRequired() {
+		t.Errorf("header not copied: got %+v, want %+v", clone, original)
+	}
+	if *clone.Payload.(*packets.LightSetColor) != *original.Payload.(*packets.LightSetColor) {
+		t.Errorf("payload not copied: got %#v, want %#v", clone.Payload, original.Payload)
+	}
+
+	clone.SetSequence(8)
+	clone.Payload.(*packets.LightSetColor).Color.Hue = 200
+	if original.Sequence() != 7 || original.Payload.(*packets.LightSetColor).Color.Hue != 100 {
+		t.Errorf("original modified by changes to the clone: %+v", original)
+	}
+}
+
+func TestMessage_AppendBinary(t *testing.T) {
+	// Every payload must encode as its MarshalBinary does.
+	for payloadType, newPayload := range packets.Payloads {
+		payload := newPayload()
+		data := make([]byte, payload.Size())
+		for i := range data {
+			data[i] = byte(i*31 + int(payloadType))
+		}
+		if err := payload.UnmarshalBinary(data); err != nil {
+			t.Fatalf("payload type %d: UnmarshalBinary failed: %v", payloadType, err)
+		}
+		payloadBytes, err := payload.MarshalBinary()
+		if err != nil {
+			t.Fatalf("payload type %d: MarshalBinary failed: %v", payloadType, err)
+		}
+
+		msg := NewMessage(payload)
+		prefix := []byte{0xff}
+		got, err := msg.AppendBinary(prefix)
+		if err != nil {
+			t.Fatalf("payload type %d: AppendBinary failed: %v", payloadType, err)
+		}
+		if !bytes.Equal(got[1+36:], payloadBytes) || got[0] != 0xff {
+			t.Errorf("payload type %d: got %x, want payload %x", payloadType, got, payloadBytes)
+		}
+
+		marshaled, err := msg.MarshalBinary()
+		if err != nil {
+			t.Fatalf("payload type %d: MarshalBinary failed: %v", payloadType, err)
+		}
+		if !bytes.Equal(marshaled, got[1:]) {
+			t.Errorf("payload type %d: MarshalBinary got %x, want %x", payloadType, marshaled, got[1:])
+		}
+	}
+}
+
+func TestMessage_MarshalBinaryTo(t *testing.T) {
+	msg := NewMessage(&packets.LightSetColor{Duration: 1000})
+	want, err := msg.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v", err)
+	}
+
+	buf := make([]byte, 128)
+	n, err := msg.MarshalBinaryTo(buf)
+	if err != nil {
+		t.Fatalf("MarshalBinaryTo failed: %v", err)
+	}
+	if !bytes.Equal(buf[:n], want) {
+		t.Errorf("got %x, want %x", buf[:n], want)
+	}
+
+	if _, err := msg.MarshalBinaryTo(make([]byte, len(want)-1, 128)); err == nil {
+		t.Error("expected an error for a buffer too small")
+	}
+}
+
+func BenchmarkMessage_MarshalBinary(b *testing.B) {
+	msg := NewMessage(&packets.TileSet64{})
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := msg.MarshalBinary(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMessage_AppendBinary(b *testing.B) {
+	msg := NewMessage(&packets.TileSet64{})
+	buf := make([]byte, 0, 1024)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := msg.AppendBinary(buf[:0]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+// Package relay tunnels LIFX LAN messages over a stream connection, such as TCP, so that a
+// Controller running in the cloud or on another VLAN can drive devices through a relay agent
+// running on their LAN.
+//
+// The agent runs a Server on the LAN:
+//
+//	srv := &relay.Server{}
+//	if err := srv.ListenAndServe(":56800"); err != nil {
+//		panic(err)
+//	}
+//
+// and the Controller sends and receives through a Transport dialled to it:
+//
+//	tr, err := relay.Dial("tcp", "relay.example.com:56800")
+//	if err != nil {
+//		panic(err)
+//	}
+//	c, err := client.NewClient(&client.Config{Transport: tr})
+//	if err != nil {
+//		panic(err)
+//	}
+//	ctrl, err := controller.New(controller.WithClient(c))
+//
+// Any stream works as a tunnel: Server.Serve accepts any net.Listener and NewTransport any
+// net.Conn, e.g. TLS or WebSocket connections. Tunnels are neither authenticated nor encrypted,
+// so wrap them with TLS when they cross untrusted networks.
+package relay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+)
+
+// maxPacketSize is the size of the largest LIFX packet forwarded.
+const maxPacketSize = 1024
+
+// ErrInvalidFrame is returned when a tunnel carries data that is not a relay frame.
+var ErrInvalidFrame = errors.New("invalid relay frame")
+
+// Each packet travels through a tunnel in a frame holding the address of the device it is sent
+// to, or received from:
+//
+//	| length (uint16) | address length (uint8) | address | packet |
+//
+// where length is the number of bytes following it and the address is the binary form of
+// a netip.AddrPort.
+
+// writeFrame writes the frame of a packet to w, in a single Write.
+// IPv4-mapped IPv6 addresses are written as IPv4 addresses.
+func writeFrame(w io.Writer, addr netip.AddrPort, packet []byte) error {
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	a, err := addr.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	if len(packet) > maxPacketSize {
+		return fmt.Errorf("%w: packet of %d bytes", ErrInvalidFrame, len(packet))
+	}
+
+	frame := make([]byte, 0, 3+len(a)+len(packet))
+	frame = binary.BigEndian.AppendUint16(frame, uint16(1+len(a)+len(packet)))
+	frame = append(frame, byte(len(a)))
+	frame = append(frame, a...)
+	frame = append(frame, packet...)
+	_, err = w.Write(frame)
+	return err
+}
+
+// readFrame reads a frame from r and returns its address and packet.
+func readFrame(r io.Reader) (netip.AddrPort, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return netip.AddrPort{}, nil, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(header[:]))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return netip.AddrPort{}, nil, err
+	}
+
+	if len(frame) == 0 || int(frame[0]) >= len(frame) {
+		return netip.AddrPort{}, nil, ErrInvalidFrame
+	}
+	var addr netip.AddrPort
+	if err := addr.UnmarshalBinary(frame[1 : 1+frame[0]]); err != nil {
+		return netip.AddrPort{}, nil, fmt.Errorf("%w: %w", ErrInvalidFrame, err)
+	}
+	if !addr.Addr().IsValid() {
+		return netip.AddrPort{}, nil, fmt.Errorf("%w: missing address", ErrInvalidFrame)
+	}
+	return addr, frame[1+frame[0]:], nil
+}
@@ -0,0 +1,70 @@
+package relay
+
+import (
+	"bytes"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrame(t *testing.T) {
+	testCases := map[string]struct {
+		addr     netip.AddrPort
+		packet   []byte
+		wantAddr netip.AddrPort
+	}{
+		"IPv4": {
+			addr:     netip.MustParseAddrPort("192.168.0.10:56700"),
+			packet:   []byte{1, 2, 3},
+			wantAddr: netip.MustParseAddrPort("192.168.0.10:56700"),
+		},
+		"IPv4-mapped IPv6": {
+			addr:     netip.MustParseAddrPort("[::ffff:192.168.0.10]:56700"),
+			packet:   []byte{1, 2, 3},
+			wantAddr: netip.MustParseAddrPort("192.168.0.10:56700"),
+		},
+		"IPv6 with zone": {
+			addr:     netip.MustParseAddrPort("[ff02::1%eth0]:56700"),
+			packet:   []byte{1},
+			wantAddr: netip.MustParseAddrPort("[ff02::1%eth0]:56700"),
+		},
+		"Empty packet": {
+			addr:     netip.MustParseAddrPort("10.0.0.1:1"),
+			packet:   []byte{},
+			wantAddr: netip.MustParseAddrPort("10.0.0.1:1"),
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, writeFrame(&buf, tc.addr, tc.packet))
+			addr, packet, err := readFrame(&buf)
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantAddr, addr)
+			assert.Equal(t, tc.packet, packet)
+		})
+	}
+}
+
+func TestFrame_Invalid(t *testing.T) {
+	t.Run("Oversized packet", func(t *testing.T) {
+		err := writeFrame(&bytes.Buffer{}, netip.MustParseAddrPort("10.0.0.1:1"), make([]byte, maxPacketSize+1))
+		assert.ErrorIs(t, err, ErrInvalidFrame)
+	})
+
+	testCases := map[string][]byte{
+		"Empty frame":      {0, 0},
+		"Address overflow": {0, 2, 5, 0},
+		"Missing address":  {0, 3, 2, 0, 0},
+		"Invalid address":  {0, 4, 3, 1, 2, 3},
+	}
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := readFrame(bytes.NewReader(data))
+			assert.ErrorIs(t, err, ErrInvalidFrame)
+		})
+	}
+}
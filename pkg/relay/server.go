@@ -0,0 +1,280 @@
+package relay
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sync"
+)
+
+// lifxPort is the port LIFX devices listen on.
+const lifxPort = 56700
+
+// limitedBroadcast is the destination of the broadcasts sent through a tunnel by a Client
+// without explicit broadcast addresses.
+var limitedBroadcast = netip.AddrFrom4([4]byte{255, 255, 255, 255})
+
+// Server is a relay agent, which forwards the packets received from each tunnel to the LAN
+// and the replies back through the tunnel. Each tunnel uses its own UDP socket, so that replies
+// are only sent back to the tunnel that sent the request.
+//
+// Tunnels are not authenticated, so packets are only forwarded to the LIFX port of the addresses
+// in the LAN subnets and to the broadcast addresses, and the others are dropped, so that the
+// Server cannot be used to send datagrams to arbitrary hosts.
+//
+// The zero value is ready to use.
+type Server struct {
+	// BroadcastAddrs, if set, are used instead of the limited broadcast address 255.255.255.255
+	// when forwarding broadcasts, e.g. the broadcast address of the LAN subnet.
+	BroadcastAddrs []*net.UDPAddr
+	// Subnets, if set, are the subnets packets may be forwarded to. By default, they are the subnets
+	// of the addresses of the host network interfaces that are up, excluding loopback, as
+	// resolved when each tunnel is opened.
+	Subnets []netip.Prefix
+	// DevicePort, if set, is the only port packets may be forwarded to instead of the LIFX port 56700,
+	// e.g. for emulated devices.
+	DevicePort uint16
+	// Logger receives the server logs. By default, logs are discarded.
+	Logger *slog.Logger
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	wg        sync.WaitGroup
+}
+
+// ListenAndServe listens on the TCP address addr and serves the tunnels connecting to it.
+func (s *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve accepts the tunnels connecting to l until the Server is closed, in which case
+// net.ErrClosed is returned, or l fails. l is closed when Serve returns.
+func (s *Server) Serve(l net.Listener) error {
+	if !s.track(l, nil) {
+		l.Close()
+		return net.ErrClosed
+	}
+	defer s.untrack(l, nil)
+	defer l.Close()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return net.ErrClosed
+			}
+			return err
+		}
+		if !s.track(nil, conn) {
+			conn.Close()
+			return net.ErrClosed
+		}
+		go func() {
+			defer s.untrack(nil, conn)
+			s.serveConn(conn)
+		}()
+	}
+}
+
+// Close stops the listeners and closes the tunnels, then waits for them to be released.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	s.closed = true
+	var errs []error
+	for l := range s.listeners {
+		errs = append(errs, l.Close())
+	}
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+	return errors.Join(errs...)
+}
+
+// serveConn forwards the packets of a tunnel until it is closed.
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+	logger := s.logger().With("tunnel", conn.RemoteAddr())
+
+	udp, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4zero})
+	if err != nil {
+		logger.Error("Failed to open UDP socket", "error", err)
+		return
+	}
+	defer udp.Close()
+	logger.Info("Tunnel opened", "address", udp.LocalAddr())
+
+	subnets := s.Subnets
+	if subnets == nil {
+		if subnets, err = interfaceSubnets(); err != nil {
+			logger.Error("Failed to resolve the LAN subnets", "error", err)
+			return
+		}
+	}
+
+	// Forward the packets received on the LAN through the tunnel.
+	go func() {
+		defer conn.Close()
+		buf := make([]byte, maxPacketSize)
+		for {
+			n, addr, err := udp.ReadFromUDPAddrPort(buf)
+			if err != nil {
+				return
+			}
+			if err := writeFrame(conn, addr, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		dst, packet, err := readFrame(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				logger.Warn("Closing tunnel", "error", err)
+			}
+			logger.Info("Tunnel closed")
+			return
+		}
+		if !s.allowed(dst, subnets) {
+			logger.Warn("Dropping packet to a destination outside of the LAN", "address", dst)
+			continue
+		}
+		for _, addr := range s.destinations(dst) {
+			if _, err := udp.WriteToUDPAddrPort(packet, addr); err != nil {
+				logger.Debug("Failed to forward packet", "address", addr, "error", err)
+			}
+		}
+	}
+}
+
+// destinations returns the addresses a packet sent to dst is forwarded to.
+func (s *Server) destinations(dst netip.AddrPort) []netip.AddrPort {
+	if dst.Addr() != limitedBroadcast || len(s.BroadcastAddrs) == 0 {
+		return []netip.AddrPort{dst}
+	}
+	addrs := make([]netip.AddrPort, 0, len(s.BroadcastAddrs))
+	for _, b := range s.BroadcastAddrs {
+		addrs = append(addrs, b.AddrPort())
+	}
+	return addrs
+}
+
+// allowed returns whether packets sent to dst may be forwarded: to the LIFX port of the limited
+// broadcast address, the broadcast addresses or the device port of the addresses in subnets.
+func (s *Server) allowed(dst netip.AddrPort, subnets []netip.Prefix) bool {
+	addr := dst.Addr().Unmap()
+	if addr == limitedBroadcast && dst.Port() == lifxPort {
+		return true
+	}
+	for _, b := range s.BroadcastAddrs {
+		if b.AddrPort() == netip.AddrPortFrom(addr, dst.Port()) {
+			return true
+		}
+	}
+
+	port := s.DevicePort
+	if port == 0 {
+		port = lifxPort
+	}
+	if dst.Port() != port {
+		return false
+	}
+	for _, p := range subnets {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// interfaceSubnets returns the IPv4 subnets of the network interfaces that are up, excluding loopback.
+func interfaceSubnets() ([]netip.Prefix, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	subnets := []netip.Prefix{}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || ipNet.IP.To4() == nil {
+				continue
+			}
+			addr, _ := netip.AddrFromSlice(ipNet.IP.To4())
+			ones, bits := ipNet.Mask.Size()
+			if bits == 8*net.IPv6len {
+				ones -= 8 * (net.IPv6len - net.IPv4len)
+			}
+			subnets = append(subnets, netip.PrefixFrom(addr, ones).Masked())
+		}
+	}
+	return subnets, nil
+}
+
+// track records a listener or connection to close with the Server, unless it is closed already.
+// Connections are waited for by Close until they are untracked.
+func (s *Server) track(l net.Listener, conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	if l != nil {
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	}
+	if conn != nil {
+		if s.conns == nil {
+			s.conns = make(map[net.Conn]struct{})
+		}
+		s.conns[conn] = struct{}{}
+		s.wg.Add(1)
+	}
+	return true
+}
+
+// untrack removes a listener or connection recorded by track.
+func (s *Server) untrack(l net.Listener, conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.listeners, l)
+	if conn != nil {
+		delete(s.conns, conn)
+		s.wg.Done()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) logger() *slog.Logger {
+	if s.Logger == nil {
+		return slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	return s.Logger
+}
@@ -0,0 +1,159 @@
+package relay
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/alessio-palumbo/lifxlan-go/pkg/client"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/controller"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/device"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/emulator"
+	"github.com/alessio-palumbo/lifxlan-go/pkg/protocol"
+	"github.com/alessio-palumbo/lifxprotocol-go/gen/protocol/packets"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay(t *testing.T) {
+	bulb, err := emulator.New(emulator.Config{Label: "Desk"})
+	require.NoError(t, err)
+	defer bulb.Close()
+
+	t.Run("Forwards messages to and from devices", func(t *testing.T) {
+		srv, addr := startServer(t, bulb, nil)
+		defer srv.Close()
+		c := dialClient(t, addr)
+		defer c.Close()
+
+		msg := protocol.NewMessage(&packets.DeviceGetLabel{})
+		msg.SetTarget(bulb.Serial())
+		require.NoError(t, c.Send(bulb.Addr(), msg))
+
+		var (
+			reply *protocol.Message
+			from  *net.UDPAddr
+		)
+		require.NoError(t, c.Receive(time.Second, true, func(msg *protocol.Message, addr *net.UDPAddr) {
+			reply, from = msg, addr
+		}))
+		require.NotNil(t, reply)
+		assert.Equal(t, bulb.Addr().String(), from.String())
+		state, ok := reply.Payload.(*packets.DeviceStateLabel)
+		require.True(t, ok)
+		assert.Equal(t, "Desk", device.ParseLabel(state.Label))
+	})
+
+	t.Run("Forwards broadcasts to the configured addresses", func(t *testing.T) {
+		srv, addr := startServer(t, bulb, []*net.UDPAddr{bulb.Addr()})
+		defer srv.Close()
+		c := dialClient(t, addr)
+		defer c.Close()
+
+		require.NoError(t, c.SendBroadcast(protocol.NewMessage(&packets.DeviceGetService{})))
+
+		var reply *protocol.Message
+		require.NoError(t, c.Receive(time.Second, true, func(msg *protocol.Message, _ *net.UDPAddr) {
+			reply = msg
+		}))
+		require.NotNil(t, reply)
+		assert.Equal(t, bulb.Serial(), device.Serial(reply.Target()))
+		assert.IsType(t, &packets.DeviceStateService{}, reply.Payload)
+	})
+
+	t.Run("Drives devices from a Controller", func(t *testing.T) {
+		srv, addr := startServer(t, bulb, nil)
+		defer srv.Close()
+		c := dialClient(t, addr)
+
+		ctrl, err := controller.New(controller.WithClient(c), controller.WithoutDiscovery(),
+			controller.WithStaticDevices(bulb.Addr().String()))
+		require.NoError(t, err)
+		defer ctrl.Close()
+
+		assert.Eventually(t, func() bool {
+			d, ok := ctrl.GetDevice(bulb.Serial())
+			return ok && d.Label == "Desk"
+		}, 2*time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Drops packets to destinations outside of the LAN", func(t *testing.T) {
+		srv, addr := startServer(t, bulb, nil)
+		defer srv.Close()
+		c := dialClient(t, addr)
+		defer c.Close()
+
+		// The bulb listens on the loopback subnet, but on another port than the device port.
+		other, err := emulator.New(emulator.Config{Label: "Other"})
+		require.NoError(t, err)
+		defer other.Close()
+		msg := protocol.NewMessage(&packets.DeviceGetLabel{})
+		require.NoError(t, c.Send(other.Addr(), msg))
+
+		var reply *protocol.Message
+		require.NoError(t, c.Receive(100*time.Millisecond, true, func(msg *protocol.Message, _ *net.UDPAddr) {
+			reply = msg
+		}))
+		assert.Nil(t, reply)
+	})
+
+	t.Run("Fails reads once the server closes", func(t *testing.T) {
+		srv, addr := startServer(t, bulb, nil)
+		c := dialClient(t, addr)
+		defer c.Close()
+
+		require.NoError(t, srv.Close())
+		err := c.Receive(time.Second, false, func(*protocol.Message, *net.UDPAddr) {})
+		assert.ErrorContains(t, err, "relay tunnel")
+	})
+}
+
+// startServer starts a Server on a random loopback port, forwarding packets to the emulated
+// device, and returns its address.
+func startServer(t *testing.T, bulb *emulator.Device, broadcastAddrs []*net.UDPAddr) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	srv := &Server{
+		BroadcastAddrs: broadcastAddrs,
+		Subnets:        []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+		DevicePort:     uint16(bulb.Addr().Port),
+	}
+	go srv.Serve(l)
+	return srv, l.Addr().String()
+}
+
+// dialClient returns a Client sending and receiving through the Server at addr.
+func dialClient(t *testing.T, addr string) *client.Client {
+	tr, err := Dial("tcp", addr)
+	require.NoError(t, err)
+	c, err := client.NewClient(&client.Config{Transport: tr})
+	require.NoError(t, err)
+	return c
+}
+
+func TestServerAllowed(t *testing.T) {
+	srv := &Server{BroadcastAddrs: []*net.UDPAddr{{IP: net.IPv4(192, 168, 0, 255), Port: 56700}}}
+	subnets := []netip.Prefix{netip.MustParsePrefix("192.168.0.0/24")}
+
+	tests := map[string]struct {
+		dst  string
+		want bool
+	}{
+		"device in the LAN":            {dst: "192.168.0.10:56700", want: true},
+		"mapped device in the LAN":     {dst: "[::ffff:192.168.0.10]:56700", want: true},
+		"limited broadcast":            {dst: "255.255.255.255:56700", want: true},
+		"broadcast address":            {dst: "192.168.0.255:56700", want: true},
+		"other port in the LAN":        {dst: "192.168.0.10:53", want: false},
+		"limited broadcast other port": {dst: "255.255.255.255:53", want: false},
+		"host outside of the LAN":      {dst: "8.8.8.8:56700", want: false},
+		"other subnet":                 {dst: "10.0.0.10:56700", want: false},
+		"ipv6 multicast":               {dst: "[ff02::1]:56700", want: false},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.want, srv.allowed(netip.MustParseAddrPort(tt.dst), subnets))
+		})
+	}
+}
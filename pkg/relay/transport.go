@@ -0,0 +1,151 @@
+package relay
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Transport is a client.Transport sending and receiving packets through the tunnel to a Server.
+// It is not reconnected if the tunnel is lost: reads then fail and a Controller using it closes.
+type Transport struct {
+	conn    net.Conn
+	packets chan packet
+
+	// err is the error that stopped reading the tunnel, set before done is closed.
+	err       error
+	done      chan struct{}
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// wmu serializes the frames written to the tunnel.
+	wmu sync.Mutex
+
+	mu       sync.Mutex
+	deadline time.Time
+	// wake is closed when the read deadline changes, to unblock pending reads.
+	wake chan struct{}
+}
+
+type packet struct {
+	addr *net.UDPAddr
+	data []byte
+}
+
+// Dial connects to the Server listening on addr and returns a Transport through it,
+// see net.Dial for the supported networks.
+func Dial(network, addr string) (*Transport, error) {
+	conn, err := net.Dial(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransport(conn), nil
+}
+
+// NewTransport returns a Transport through the tunnel conn, already connected to a Server.
+// The Transport owns conn and closes it when closed.
+func NewTransport(conn net.Conn) *Transport {
+	t := &Transport{
+		conn:    conn,
+		packets: make(chan packet, 64),
+		done:    make(chan struct{}),
+		closed:  make(chan struct{}),
+		wake:    make(chan struct{}),
+	}
+	go t.readLoop()
+	return t
+}
+
+// readLoop queues the packets received through the tunnel until it fails.
+func (t *Transport) readLoop() {
+	defer close(t.done)
+
+	r := bufio.NewReader(t.conn)
+	for {
+		addr, data, err := readFrame(r)
+		if err != nil {
+			t.err = fmt.Errorf("relay tunnel: %w", err)
+			return
+		}
+		select {
+		case t.packets <- packet{addr: net.UDPAddrFromAddrPort(addr), data: data}:
+		case <-t.closed:
+			return
+		}
+	}
+}
+
+// ReadFrom reads the next packet received through the tunnel and the address of its sender.
+func (t *Transport) ReadFrom(p []byte) (int, net.Addr, error) {
+	for {
+		t.mu.Lock()
+		deadline, wake := t.deadline, t.wake
+		t.mu.Unlock()
+
+		var timeout <-chan time.Time
+		if !deadline.IsZero() {
+			timeout = time.After(time.Until(deadline))
+		}
+		select {
+		case pkt := <-t.packets:
+			return copy(p, pkt.data), pkt.addr, nil
+		case <-timeout:
+			return 0, nil, os.ErrDeadlineExceeded
+		case <-t.closed:
+			return 0, nil, net.ErrClosed
+		case <-t.done:
+			// Deliver the packets received before the tunnel failed.
+			select {
+			case pkt := <-t.packets:
+				return copy(p, pkt.data), pkt.addr, nil
+			default:
+				return 0, nil, t.err
+			}
+		case <-wake:
+		}
+	}
+}
+
+// WriteTo sends the packet p to the device at addr through the tunnel.
+// addr must be a *net.UDPAddr.
+func (t *Transport) WriteTo(p []byte, addr net.Addr) (int, error) {
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, fmt.Errorf("relay: unsupported address type %T", addr)
+	}
+	select {
+	case <-t.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+
+	t.wmu.Lock()
+	defer t.wmu.Unlock()
+	if err := writeFrame(t.conn, udpAddr.AddrPort(), p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// SetReadDeadline sets the deadline of ReadFrom, see net.PacketConn.
+func (t *Transport) SetReadDeadline(deadline time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.deadline = deadline
+	close(t.wake)
+	t.wake = make(chan struct{})
+	return nil
+}
+
+// Close closes the tunnel.
+func (t *Transport) Close() error {
+	var err error
+	t.closeOnce.Do(func() {
+		close(t.closed)
+		err = t.conn.Close()
+	})
+	return err
+}